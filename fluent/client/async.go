@@ -0,0 +1,185 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const DefaultMaxQueueMessages = 1000
+
+// AsyncErrorHandler is called with a message queued by SendMessageAsync
+// that could not be sent.
+type AsyncErrorHandler func(e msgp.Encodable, err error)
+
+// QueueStats describes the messages queued by SendMessageAsync that have
+// not been written yet.
+type QueueStats struct {
+	// Messages is the number of messages, including the one being written.
+	Messages int
+	// Bytes is the estimated encoded size of the messages, from their
+	// Msgsize method. Messages without one count as zero bytes.
+	Bytes int64
+}
+
+// SendMessageAsync queues e to be sent by a background writer and returns
+// at once. If the queue already holds MaxQueueMessages messages, or e would
+// take it past MaxQueueBytes, ErrQueueFull is returned. Messages are sent
+// in the order they were queued; errors are passed to OnAsyncError. The
+// writer is stopped by Disconnect, and started again by the next
+// SendMessageAsync.
+func (c *WSClient) SendMessageAsync(e msgp.Encodable) error {
+	size := estimateSize(e)
+
+	c.asyncLock.Lock()
+	defer c.asyncLock.Unlock()
+
+	if c.asyncQueue == nil {
+		c.startAsyncWriter()
+	}
+
+	if c.MaxQueueBytes > 0 && c.asyncBytes+size > c.MaxQueueBytes {
+		return ErrQueueFull
+	}
+
+	if c.asyncPending == 0 {
+		c.asyncIdle = make(chan struct{})
+	}
+
+	select {
+	case c.asyncQueue <- queuedMessage{e: e, size: size}:
+		c.asyncPending++
+		c.asyncBytes += size
+
+		return nil
+	default:
+		if c.asyncPending == 0 {
+			close(c.asyncIdle)
+		}
+
+		return ErrQueueFull
+	}
+}
+
+// Stats returns the current size of the SendMessageAsync queue, e.g. to
+// alert before its limits are reached.
+func (c *WSClient) Stats() QueueStats {
+	c.asyncLock.Lock()
+	defer c.asyncLock.Unlock()
+
+	return QueueStats{Messages: c.asyncPending, Bytes: c.asyncBytes}
+}
+
+// queuedMessage is a message queued by SendMessageAsync, with the size it
+// was counted as.
+type queuedMessage struct {
+	e    msgp.Encodable
+	size int64
+}
+
+func estimateSize(e msgp.Encodable) int64 {
+	if s, ok := e.(msgp.Sizer); ok {
+		return int64(s.Msgsize())
+	}
+
+	return 0
+}
+
+// Flush waits until every message queued by SendMessageAsync has been
+// written, or the context is done.
+func (c *WSClient) Flush(ctx context.Context) error {
+	c.asyncLock.Lock()
+	if c.asyncPending == 0 {
+		c.asyncLock.Unlock()
+		return nil
+	}
+
+	idle := c.asyncIdle
+	c.asyncLock.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flush: %w", ctx.Err())
+	}
+}
+
+// startAsyncWriter starts the writer of a new queue. It must be called
+// with asyncLock held. The writer waits for that of the previous queue, so
+// that their messages are not written concurrently either.
+func (c *WSClient) startAsyncWriter() {
+	size := c.MaxQueueMessages
+	if size <= 0 {
+		size = DefaultMaxQueueMessages
+	}
+
+	previous, stopped := c.asyncStopped, make(chan struct{})
+	c.asyncQueue, c.asyncStopped = make(chan queuedMessage, size), stopped
+
+	go func(queue chan queuedMessage) {
+		defer close(stopped)
+
+		if previous != nil {
+			<-previous
+		}
+
+		c.asyncWriter(queue)
+	}(c.asyncQueue)
+}
+
+// stopAsyncWriter closes the queue, so that its writer returns once it has
+// sent, or failed to send, the messages left in it.
+func (c *WSClient) stopAsyncWriter() {
+	c.asyncLock.Lock()
+	defer c.asyncLock.Unlock()
+
+	if c.asyncQueue != nil {
+		close(c.asyncQueue)
+		c.asyncQueue = nil
+	}
+}
+
+// asyncWriter is the only goroutine that writes the messages of queue, so
+// they are never written concurrently with each other.
+func (c *WSClient) asyncWriter(queue chan queuedMessage) {
+	for qm := range queue {
+		if err := c.SendMessageContext(context.Background(), qm.e); err != nil && c.OnAsyncError != nil {
+			c.OnAsyncError(qm.e, err)
+		}
+
+		c.asyncLock.Lock()
+		c.asyncPending--
+		c.asyncBytes -= qm.size
+
+		if c.asyncPending == 0 {
+			close(c.asyncIdle)
+		}
+		c.asyncLock.Unlock()
+	}
+}
@@ -0,0 +1,203 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultIAMRefreshRetryInterval is how long IAMAuthInfo waits before
+// calling RefreshFunc again after a failure.
+const DefaultIAMRefreshRetryInterval = 5 * time.Second
+
+// IAMRefreshFunc returns a new token and the time at which it expires.
+// A zero expiry means the token does not expire.
+type IAMRefreshFunc func(ctx context.Context) (token string, expiresAt time.Time, err error)
+
+type IAMAuthInfo struct {
+	// ExpiresAt is when the current token expires. A zero value means the
+	// token never expires. It is read by DefaultWSConnectionFactory before
+	// every dial; use SetIAMTokenWithExpiry to change it while in use.
+	ExpiresAt time.Time
+	// OnExpired, if not nil, is called before a dial when the token has
+	// expired. It is expected to update the token, e.g. by calling
+	// SetIAMTokenWithExpiry, and the dial is aborted if it returns an error.
+	OnExpired func() error
+	// RefreshFunc is called by the goroutine started by StartAutoRefresh
+	// to obtain new tokens.
+	RefreshFunc IAMRefreshFunc
+	// OnRefreshError, if not nil, is called with every error returned by
+	// RefreshFunc.
+	OnRefreshError func(err error)
+	// RefreshRetryInterval is how long to wait after a failed refresh, and
+	// the shortest wait between refreshes. If zero,
+	// DefaultIAMRefreshRetryInterval is used.
+	RefreshRetryInterval time.Duration
+	token                string
+	mutex                sync.RWMutex
+	refreshLock          sync.Mutex
+	stopRefresh          context.CancelFunc
+	refreshDone          chan struct{}
+}
+
+// IAMToken returns the current token value. It is thread safe.
+func (ai *IAMAuthInfo) IAMToken() string {
+	ai.mutex.RLock()
+	defer ai.mutex.RUnlock()
+
+	return ai.token
+}
+
+// SetIAMToken updates the token returned by IAMToken(). It is thread safe.
+func (ai *IAMAuthInfo) SetIAMToken(token string) {
+	ai.mutex.Lock()
+	defer ai.mutex.Unlock()
+
+	ai.token = token
+}
+
+// SetIAMTokenWithExpiry updates the token returned by IAMToken() and the
+// time at which it expires. It is thread safe.
+func (ai *IAMAuthInfo) SetIAMTokenWithExpiry(token string, expiresAt time.Time) {
+	ai.mutex.Lock()
+	defer ai.mutex.Unlock()
+
+	ai.token = token
+	ai.ExpiresAt = expiresAt
+}
+
+// Expired reports whether the token has expired. It is thread safe.
+func (ai *IAMAuthInfo) Expired() bool {
+	ai.mutex.RLock()
+	defer ai.mutex.RUnlock()
+
+	return !ai.ExpiresAt.IsZero() && time.Now().After(ai.ExpiresAt)
+}
+
+// ensureFresh calls OnExpired if the token has expired. Without an
+// OnExpired hook, an expired token results in ErrTokenExpired rather than
+// a dial that the server will reject.
+func (ai *IAMAuthInfo) ensureFresh() error {
+	if !ai.Expired() {
+		return nil
+	}
+
+	if ai.OnExpired == nil {
+		return ErrTokenExpired
+	}
+
+	if err := ai.OnExpired(); err != nil {
+		return fmt.Errorf("refresh expired token: %w", err)
+	}
+
+	if ai.Expired() {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+func NewIAMAuthInfo(token string) *IAMAuthInfo {
+	return &IAMAuthInfo{token: token}
+}
+
+// StartAutoRefresh starts a goroutine that calls RefreshFunc and sets the
+// token it returns, then does so again refreshBeforeExpiry ahead of each
+// token's expiry. It stops when the context is done, StopAutoRefresh is
+// called, or RefreshFunc returns a token that does not expire. Calling it
+// while a refresh goroutine is running replaces that goroutine.
+func (ai *IAMAuthInfo) StartAutoRefresh(ctx context.Context, refreshBeforeExpiry time.Duration) {
+	ai.StopAutoRefresh()
+
+	ai.refreshLock.Lock()
+	defer ai.refreshLock.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	ai.stopRefresh = cancel
+	ai.refreshDone = done
+
+	go func() {
+		defer close(done)
+		ai.autoRefresh(ctx, refreshBeforeExpiry)
+	}()
+}
+
+// StopAutoRefresh stops the goroutine started by StartAutoRefresh and
+// waits for it to exit. It is safe to call more than once.
+func (ai *IAMAuthInfo) StopAutoRefresh() {
+	ai.refreshLock.Lock()
+	cancel, done := ai.stopRefresh, ai.refreshDone
+	ai.stopRefresh, ai.refreshDone = nil, nil
+	ai.refreshLock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (ai *IAMAuthInfo) autoRefresh(ctx context.Context, refreshBeforeExpiry time.Duration) {
+	retryInterval := ai.RefreshRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultIAMRefreshRetryInterval
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		wait := retryInterval
+
+		token, expiresAt, err := ai.RefreshFunc(ctx)
+		if err != nil {
+			if ai.OnRefreshError != nil {
+				ai.OnRefreshError(err)
+			}
+		} else {
+			ai.SetIAMTokenWithExpiry(token, expiresAt)
+
+			if expiresAt.IsZero() {
+				return
+			}
+
+			if d := time.Until(expiresAt) - refreshBeforeExpiry; d > wait {
+				wait = d
+			}
+		}
+
+		timer.Reset(wait)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	DefaultAPIKeyHeader = "X-API-Key"
+
+	HMACKeyIDHeader     = "X-Auth-Key-Id"
+	HMACTimestampHeader = "X-Timestamp"
+	HMACSignatureHeader = "X-Signature"
+)
+
+// AuthProvider supplies the headers that authenticate a connection. It is
+// consulted by DefaultWSConnectionFactory before every dial.
+//
+//counterfeiter:generate . AuthProvider
+type AuthProvider interface {
+	Headers(ctx context.Context) (http.Header, error)
+}
+
+type dialURLKey struct{}
+
+func withDialURL(ctx context.Context, rawURL string) context.Context {
+	return context.WithValue(ctx, dialURLKey{}, rawURL)
+}
+
+// DialURL returns the URL being dialed, from the context that
+// DefaultWSConnectionFactory passes to AuthProvider.Headers, e.g. for a
+// provider that signs the host. It is empty for other contexts.
+func DialURL(ctx context.Context) string {
+	rawURL, _ := ctx.Value(dialURLKey{}).(string)
+	return rawURL
+}
+
+// IAMBearerAuth sends the token held by AuthInfo in the Authorization
+// header. An expired token is refreshed with AuthInfo.OnExpired first.
+type IAMBearerAuth struct {
+	AuthInfo *IAMAuthInfo
+}
+
+func (a *IAMBearerAuth) Headers(_ context.Context) (http.Header, error) {
+	header := http.Header{}
+
+	if a.AuthInfo == nil {
+		return header, nil
+	}
+
+	if err := a.AuthInfo.ensureFresh(); err != nil {
+		return nil, err
+	}
+
+	if token := a.AuthInfo.IAMToken(); len(token) > 0 {
+		header.Set(AuthorizationHeader, token)
+	}
+
+	return header, nil
+}
+
+// StaticAPIKeyAuth sends a fixed API key in a custom header. The header
+// and key cannot be changed once it is constructed; use RotatingAPIKeyAuth
+// for keys that change. It must be created with NewStaticAPIKeyAuth; the
+// zero value fails to produce headers.
+type StaticAPIKeyAuth struct {
+	headerName string
+	apiKey     string
+}
+
+// NewStaticAPIKeyAuth returns a StaticAPIKeyAuth that sends apiKey in
+// headerName. If headerName is empty, DefaultAPIKeyHeader is used.
+func NewStaticAPIKeyAuth(headerName, apiKey string) *StaticAPIKeyAuth {
+	if headerName == "" {
+		headerName = DefaultAPIKeyHeader
+	}
+
+	return &StaticAPIKeyAuth{headerName: headerName, apiKey: apiKey}
+}
+
+func (a *StaticAPIKeyAuth) HeaderName() string {
+	return a.headerName
+}
+
+func (a *StaticAPIKeyAuth) Headers(_ context.Context) (http.Header, error) {
+	if a.headerName == "" {
+		return nil, errors.New("StaticAPIKeyAuth has no header; use NewStaticAPIKeyAuth")
+	}
+
+	header := http.Header{}
+	header.Set(a.headerName, a.apiKey)
+
+	return header, nil
+}
+
+// RotatingAPIKeyAuth sends the API key returned by KeyFunc, which is
+// called before every dial and never cached, e.g. to read the current key
+// from a secrets manager. If HeaderName is empty, DefaultAPIKeyHeader is
+// used. KeyFunc is required.
+type RotatingAPIKeyAuth struct {
+	HeaderName string
+	KeyFunc    func() string
+}
+
+func (a *RotatingAPIKeyAuth) Headers(_ context.Context) (http.Header, error) {
+	name := a.HeaderName
+	if name == "" {
+		name = DefaultAPIKeyHeader
+	}
+
+	if a.KeyFunc == nil {
+		return nil, errors.New("RotatingAPIKeyAuth has no KeyFunc")
+	}
+
+	key := a.KeyFunc()
+	if key == "" {
+		return nil, errors.New("API key is empty")
+	}
+
+	header := http.Header{}
+	header.Set(name, key)
+
+	return header, nil
+}
+
+// BasicAuth sends HTTP basic authentication credentials, as RFC 7617
+// describes, e.g. for a Fluentd behind a proxy that requires them. The
+// Username must not contain a colon.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a *BasicAuth) Headers(_ context.Context) (http.Header, error) {
+	creds := base64.StdEncoding.EncodeToString([]byte(a.Username + ":" + a.Password))
+
+	header := http.Header{}
+	header.Set(AuthorizationHeader, "Basic "+creds)
+
+	return header, nil
+}
+
+// HMACSigningAuth signs each dial with a shared secret, without sending
+// it. The HMAC-SHA256, keyed with Secret, of
+//
+//	<date>\n<host>\n<hex SHA-256 of the request body>
+//
+// is sent hex-encoded in HMACSignatureHeader, and the date, in HTTP date
+// format, in HMACTimestampHeader. A websocket upgrade has no body, so the
+// hash is that of no bytes. KeyID, if set, is sent in HMACKeyIDHeader to
+// tell the server which secret to verify with. The server should reject
+// dates outside an acceptable skew.
+type HMACSigningAuth struct {
+	KeyID  string
+	Secret []byte
+	// Host is the host signed. If empty, the host of the URL being dialed
+	// is used.
+	Host string
+	// Now returns the time used for the date. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+func (a *HMACSigningAuth) Headers(ctx context.Context) (http.Header, error) {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+
+	host := a.Host
+	if host == "" {
+		u, err := url.Parse(DialURL(ctx))
+		if err != nil || u.Host == "" {
+			return nil, errors.New("HMAC signing needs a host")
+		}
+
+		host = u.Host
+	}
+
+	date := now().UTC().Format(http.TimeFormat)
+
+	header := http.Header{}
+	if a.KeyID != "" {
+		header.Set(HMACKeyIDHeader, a.KeyID)
+	}
+
+	header.Set(HMACTimestampHeader, date)
+	header.Set(HMACSignatureHeader, HMACSignature(a.Secret, date, host, nil))
+
+	return header, nil
+}
+
+// HMACSignature returns the signature HMACSigningAuth sends for the given
+// date, host and request body, e.g. so that a server can verify it.
+func HMACSignature(secret []byte, date, host string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(date + "\n" + host + "\n" + hex.EncodeToString(bodyHash[:])))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
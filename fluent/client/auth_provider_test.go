@@ -0,0 +1,242 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+// The providers are interchangeable.
+var (
+	_ AuthProvider = &IAMBearerAuth{}
+	_ AuthProvider = &StaticAPIKeyAuth{}
+	_ AuthProvider = &RotatingAPIKeyAuth{}
+	_ AuthProvider = &BasicAuth{}
+	_ AuthProvider = &HMACSigningAuth{}
+	_ AuthProvider = &JWTAuth{}
+)
+
+var _ = Describe("AuthProvider", func() {
+	var (
+		ctx = context.Background()
+	)
+
+	Describe("IAMBearerAuth", func() {
+		It("sends the token in the Authorization header", func() {
+			auth := &IAMBearerAuth{AuthInfo: NewIAMAuthInfo("oi")}
+
+			header, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(AuthorizationHeader)).To(Equal("oi"))
+		})
+
+		It("omits an empty token", func() {
+			auth := &IAMBearerAuth{AuthInfo: NewIAMAuthInfo("")}
+
+			header, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header).To(BeEmpty())
+		})
+
+		It("fails for an expired token", func() {
+			info := NewIAMAuthInfo("oi")
+			info.ExpiresAt = time.Now().Add(-time.Second)
+
+			_, err := (&IAMBearerAuth{AuthInfo: info}).Headers(ctx)
+			Expect(err).To(MatchError(ErrTokenExpired))
+		})
+	})
+
+	Describe("StaticAPIKeyAuth", func() {
+		It("uses the default header", func() {
+			auth := NewStaticAPIKeyAuth("", "k")
+			Expect(auth.HeaderName()).To(Equal(DefaultAPIKeyHeader))
+
+			header, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(DefaultAPIKeyHeader)).To(Equal("k"))
+		})
+
+		It("uses the configured header", func() {
+			header, err := NewStaticAPIKeyAuth("X-Key", "k").Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get("X-Key")).To(Equal("k"))
+			Expect(header).ToNot(HaveKey(DefaultAPIKeyHeader))
+		})
+
+		It("fails when it was not created with NewStaticAPIKeyAuth", func() {
+			_, err := (&StaticAPIKeyAuth{}).Headers(ctx)
+			Expect(err).To(MatchError(ContainSubstring("no header")))
+		})
+	})
+
+	Describe("RotatingAPIKeyAuth", func() {
+		It("calls KeyFunc for every dial", func() {
+			keys := []string{"k1", "k2"}
+			auth := &RotatingAPIKeyAuth{
+				HeaderName: "X-Key",
+				KeyFunc: func() string {
+					key := keys[0]
+					keys = keys[1:]
+
+					return key
+				},
+			}
+
+			header, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get("X-Key")).To(Equal("k1"))
+
+			header, err = auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get("X-Key")).To(Equal("k2"))
+		})
+
+		It("uses the default header", func() {
+			header, err := (&RotatingAPIKeyAuth{KeyFunc: func() string { return "k" }}).Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(DefaultAPIKeyHeader)).To(Equal("k"))
+		})
+
+		It("fails when there is no key", func() {
+			_, err := (&RotatingAPIKeyAuth{KeyFunc: func() string { return "" }}).Headers(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails without a KeyFunc", func() {
+			_, err := (&RotatingAPIKeyAuth{}).Headers(ctx)
+			Expect(err).To(MatchError(ContainSubstring("no KeyFunc")))
+		})
+	})
+
+	Describe("BasicAuth", func() {
+		It("encodes the credentials", func() {
+			header, err := (&BasicAuth{Username: "user", Password: "pass"}).Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(AuthorizationHeader)).To(Equal("Basic dXNlcjpwYXNz"))
+		})
+
+		It("matches the RFC 7617 example", func() {
+			header, err := (&BasicAuth{Username: "Aladdin", Password: "open sesame"}).Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(AuthorizationHeader)).To(Equal("Basic QWxhZGRpbjpvcGVuIHNlc2FtZQ=="))
+		})
+	})
+
+	Describe("HMACSigningAuth", func() {
+		at := func(unix int64) func() time.Time {
+			return func() time.Time { return time.Unix(unix, 0) }
+		}
+
+		// The signatures were made by this reference implementation:
+		//
+		//	def sign(secret, date, host, body):
+		//	    to_sign = "\n".join([date, host, hashlib.sha256(body).hexdigest()])
+		//	    return hmac.new(secret, to_sign.encode(), hashlib.sha256).hexdigest()
+		//
+		// with the date from email.utils.formatdate(ts, usegmt=True).
+		DescribeTable("matches the reference signatures",
+			func(secret, date, host, body, signature string) {
+				Expect(HMACSignature([]byte(secret), date, host, []byte(body))).To(Equal(signature))
+			},
+			Entry("an upgrade", "secret", "Mon, 10 May 2021 17:00:00 GMT", "logs.example.com", "",
+				"c8262ed3e500130558600317f8b30f4680fcd7d3e9755ee244f41bc650e29a35"),
+			Entry("a host with a port", "secret", "Mon, 10 May 2021 17:00:00 GMT", "logs.example.com:8443", "",
+				"0df4031fddf60cea56b152b24e0b28ecc5aa0d754b1412ad3d313e2b7f3e982b"),
+			Entry("a request with a body", "another secret", "Tue, 14 Nov 2023 22:13:20 GMT", "127.0.0.1:9880", `{"event":"oi"}`,
+				"528c8bd047ad53776a861698c992d419b7cb0274857e8dcf73246df8c43f9091"),
+		)
+
+		It("signs the date and host", func() {
+			auth := &HMACSigningAuth{
+				KeyID:  "key-1",
+				Secret: []byte("secret"),
+				Host:   "logs.example.com:8443",
+				Now:    at(1620666000),
+			}
+
+			header, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Get(HMACKeyIDHeader)).To(Equal("key-1"))
+			Expect(header.Get(HMACTimestampHeader)).To(Equal("Mon, 10 May 2021 17:00:00 GMT"))
+			Expect(header.Get(HMACSignatureHeader)).To(Equal("0df4031fddf60cea56b152b24e0b28ecc5aa0d754b1412ad3d313e2b7f3e982b"))
+		})
+
+		It("signs a fresh date for every dial", func() {
+			now := int64(1620666000)
+			auth := &HMACSigningAuth{
+				Secret: []byte("secret"),
+				Host:   "logs.example.com",
+				Now:    func() time.Time { now++; return time.Unix(now, 0) },
+			}
+
+			first, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(first).ToNot(HaveKey(HMACKeyIDHeader))
+
+			second, err := auth.Headers(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(second.Get(HMACTimestampHeader)).ToNot(Equal(first.Get(HMACTimestampHeader)))
+			Expect(second.Get(HMACSignatureHeader)).ToNot(Equal(first.Get(HMACSignatureHeader)))
+		})
+
+		It("fails without a host", func() {
+			_, err := (&HMACSigningAuth{Secret: []byte("secret")}).Headers(ctx)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("signs the upgrade request of DefaultWSConnectionFactory", func() {
+			requests := make(chan *http.Request, 1)
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests <- r
+				http.Error(w, "nope", http.StatusForbidden)
+			}))
+			defer svr.Close()
+
+			factory := &DefaultWSConnectionFactory{
+				URL:  "ws" + strings.TrimPrefix(svr.URL, "http"),
+				Auth: &HMACSigningAuth{Secret: []byte("secret")},
+			}
+
+			_, err := factory.New(ctx)
+			Expect(err).To(HaveOccurred())
+
+			var r *http.Request
+			Expect(requests).To(Receive(&r))
+
+			date := r.Header.Get(HMACTimestampHeader)
+			Expect(r.Header.Get(HMACSignatureHeader)).To(Equal(HMACSignature([]byte("secret"), date, r.Host, nil)))
+		})
+	})
+})
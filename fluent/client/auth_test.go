@@ -0,0 +1,142 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+var _ = Describe("IAMAuthInfo", func() {
+	It("gets and sets an IAM token", func() {
+		iai := NewIAMAuthInfo("a")
+		Expect(iai.IAMToken()).To(Equal("a"))
+		iai.SetIAMToken("b")
+		Expect(iai.IAMToken()).To(Equal("b"))
+	})
+
+	It("reports whether the token has expired", func() {
+		iai := NewIAMAuthInfo("a")
+		Expect(iai.Expired()).To(BeFalse())
+
+		iai.SetIAMTokenWithExpiry("b", time.Now().Add(-time.Second))
+		Expect(iai.IAMToken()).To(Equal("b"))
+		Expect(iai.Expired()).To(BeTrue())
+
+		iai.SetIAMTokenWithExpiry("c", time.Now().Add(time.Minute))
+		Expect(iai.Expired()).To(BeFalse())
+	})
+
+	Describe("StartAutoRefresh", func() {
+		var (
+			iai   *IAMAuthInfo
+			calls int32
+		)
+
+		BeforeEach(func() {
+			atomic.StoreInt32(&calls, 0)
+
+			iai = NewIAMAuthInfo("a")
+			iai.RefreshRetryInterval = 10 * time.Millisecond
+			iai.RefreshFunc = func(ctx context.Context) (string, time.Time, error) {
+				n := atomic.AddInt32(&calls, 1)
+				return string(rune('a' + n)), time.Now().Add(300 * time.Millisecond), nil
+			}
+		})
+
+		AfterEach(func() {
+			iai.StopAutoRefresh()
+		})
+
+		It("refreshes the token ahead of its expiry", func() {
+			iai.StartAutoRefresh(context.Background(), 200*time.Millisecond)
+
+			Eventually(iai.IAMToken, time.Second, time.Millisecond).Should(Equal("b"))
+			Consistently(iai.IAMToken, 30*time.Millisecond, time.Millisecond).Should(Equal("b"))
+			Eventually(iai.IAMToken).Should(Equal("c"))
+		})
+
+		It("retries after a failed refresh", func() {
+			var reported int32
+
+			iai.OnRefreshError = func(err error) {
+				atomic.AddInt32(&reported, 1)
+			}
+			iai.RefreshFunc = func(ctx context.Context) (string, time.Time, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					return "", time.Time{}, errors.New("nope")
+				}
+
+				return "b", time.Time{}, nil
+			}
+
+			iai.StartAutoRefresh(context.Background(), 0)
+
+			Eventually(iai.IAMToken).Should(Equal("b"))
+			Expect(atomic.LoadInt32(&reported)).To(Equal(int32(1)))
+		})
+
+		It("stops when the token does not expire", func() {
+			iai.RefreshFunc = func(ctx context.Context) (string, time.Time, error) {
+				atomic.AddInt32(&calls, 1)
+				return "b", time.Time{}, nil
+			}
+
+			iai.StartAutoRefresh(context.Background(), 0)
+
+			Eventually(iai.IAMToken).Should(Equal("b"))
+			Consistently(func() int32 { return atomic.LoadInt32(&calls) }, 50*time.Millisecond).Should(Equal(int32(1)))
+		})
+
+		It("stops when StopAutoRefresh is called", func() {
+			iai.StartAutoRefresh(context.Background(), 50*time.Millisecond)
+			Eventually(iai.IAMToken).Should(Equal("b"))
+
+			iai.StopAutoRefresh()
+			iai.StopAutoRefresh()
+
+			n := atomic.LoadInt32(&calls)
+			Consistently(func() int32 { return atomic.LoadInt32(&calls) }, 50*time.Millisecond).Should(Equal(n))
+		})
+
+		It("stops when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			iai.StartAutoRefresh(ctx, 50*time.Millisecond)
+			Eventually(iai.IAMToken).Should(Equal("b"))
+
+			cancel()
+
+			n := atomic.LoadInt32(&calls)
+			Consistently(func() int32 { return atomic.LoadInt32(&calls) }, 50*time.Millisecond).Should(Equal(n))
+		})
+	})
+})
@@ -0,0 +1,225 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+)
+
+// BatchingClient collects events by tag and sends each tag's events as a
+// single PackedForward message once BatchSize of them have been collected,
+// every FlushInterval, and on Flush and Close. Each tag's events are sent
+// in the order they were added.
+//
+// A batch that fails to send is kept, and sent with the events added
+// since at the next flush.
+type BatchingClient struct {
+	// Sender sends the batches.
+	Sender MessageSender
+	// BatchSize is the number of events per batch. If zero,
+	// DefaultBatchSize is used.
+	BatchSize int
+	// FlushInterval is how often batches are sent whatever their size. If
+	// zero, DefaultFlushInterval is used.
+	FlushInterval time.Duration
+	// CompressionPolicy chooses how each batch is compressed, from its tag,
+	// e.g. to compress verbose logs but not compact metrics that would gain
+	// little. If nil, batches are not compressed.
+	CompressionPolicy func(tag string) protocol.CompressionAlgorithm
+	// CompressionLevel is passed to the compressor. If zero, the
+	// algorithm's default is used.
+	CompressionLevel int
+	// OnFlushError, if not nil, is called when a batch sent every
+	// FlushInterval fails.
+	OnFlushError func(tag string, err error)
+	batches      map[string]*protocol.PackedForwardWriter
+	lock         sync.Mutex
+	stop         chan struct{}
+	flushes      sync.WaitGroup
+}
+
+func NewBatchingClient(sender MessageSender) *BatchingClient {
+	return &BatchingClient{
+		Sender:        sender,
+		BatchSize:     DefaultBatchSize,
+		FlushInterval: DefaultFlushInterval,
+	}
+}
+
+// SendMessage adds an event to the batch for tag, timestamped now, and
+// sends the batch if it is full.
+func (c *BatchingClient) SendMessage(tag string, record interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.batches == nil {
+		c.batches = map[string]*protocol.PackedForwardWriter{}
+	}
+
+	pw, ok := c.batches[tag]
+	if !ok {
+		pw = protocol.NewPackedForwardWriter(tag)
+		c.batches[tag] = pw
+	}
+
+	err := pw.Append(protocol.EntryExt{
+		Timestamp: protocol.EventTimeNow(),
+		Record:    record,
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+		c.flushes.Add(1)
+
+		go c.flushEvery(c.stop)
+	}
+
+	if pw.Len() >= c.batchSize() {
+		return c.send(tag, pw)
+	}
+
+	return nil
+}
+
+func (c *BatchingClient) batchSize() int {
+	if c.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+
+	return c.BatchSize
+}
+
+// send sends the batch for tag, compressed as the CompressionPolicy
+// chooses. It must be called with the lock held.
+func (c *BatchingClient) send(tag string, pw *protocol.PackedForwardWriter) error {
+	if pw.Len() == 0 {
+		return nil
+	}
+
+	algorithm := protocol.CompressionNone
+	if c.CompressionPolicy != nil {
+		algorithm = c.CompressionPolicy(tag)
+	}
+
+	msg, err := pw.Message()
+	if err != nil {
+		return err
+	}
+
+	// the sender may keep the message, e.g. in a WSClient's buffer, after
+	// the writer's storage is reused
+	msg.EventStream = append([]byte(nil), msg.EventStream...)
+
+	err = c.Sender.Send(&protocol.CompressedPackedForwardMessage{
+		PackedForwardMessage: msg,
+		CompressionAlgorithm: algorithm,
+		CompressionLevel:     c.CompressionLevel,
+	})
+	if err != nil {
+		return err
+	}
+
+	pw.Reset()
+
+	return nil
+}
+
+// Flush sends every batch now. A failure does not stop the other batches
+// being sent; the first error is returned.
+func (c *BatchingClient) Flush() error {
+	var err error
+
+	c.flush(func(_ string, ferr error) {
+		if err == nil {
+			err = ferr
+		}
+	})
+
+	return err
+}
+
+func (c *BatchingClient) flush(onError func(tag string, err error)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for tag, pw := range c.batches {
+		if err := c.send(tag, pw); err != nil {
+			onError(tag, err)
+		}
+	}
+}
+
+func (c *BatchingClient) flushEvery(stop chan struct{}) {
+	defer c.flushes.Done()
+
+	interval := c.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.flush(func(tag string, err error) {
+			if c.OnFlushError != nil {
+				c.OnFlushError(tag, err)
+			}
+		})
+	}
+}
+
+// Close stops the periodic flushes and sends every batch. The client may
+// be used again afterwards.
+func (c *BatchingClient) Close() error {
+	c.lock.Lock()
+	stop := c.stop
+	c.stop = nil
+	c.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		c.flushes.Wait()
+	}
+
+	return c.Flush()
+}
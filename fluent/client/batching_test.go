@@ -0,0 +1,235 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("BatchingClient", func() {
+	var (
+		lock    sync.Mutex
+		sent    []*protocol.CompressedPackedForwardMessage
+		sendErr error
+		sender  *clientfakes.FakeMessageSender
+		bc      *BatchingClient
+	)
+
+	sentMessages := func() []*protocol.CompressedPackedForwardMessage {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]*protocol.CompressedPackedForwardMessage(nil), sent...)
+	}
+
+	entries := func(msg *protocol.CompressedPackedForwardMessage) protocol.EntryList {
+		var el protocol.EntryList
+		_, err := el.UnmarshalPacked(msg.EventStream)
+		Expect(err).ToNot(HaveOccurred())
+
+		return el
+	}
+
+	BeforeEach(func() {
+		sent, sendErr = nil, nil
+		sender = &clientfakes.FakeMessageSender{}
+		sender.SendStub = func(e protocol.ChunkEncoder) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			if sendErr != nil {
+				return sendErr
+			}
+
+			sent = append(sent, e.(*protocol.CompressedPackedForwardMessage))
+
+			return nil
+		}
+
+		bc = NewBatchingClient(sender)
+		bc.BatchSize = 3
+		bc.FlushInterval = time.Hour
+	})
+
+	AfterEach(func() {
+		Expect(bc.Close()).To(Succeed())
+	})
+
+	It("sends a batch when it is full", func() {
+		for i := 0; i < 2; i++ {
+			Expect(bc.SendMessage("app.logs", map[string]interface{}{"i": i})).To(Succeed())
+		}
+
+		Expect(sentMessages()).To(BeEmpty())
+		Expect(bc.SendMessage("app.logs", map[string]interface{}{"i": 2})).To(Succeed())
+		Expect(sentMessages()).To(HaveLen(1))
+
+		msg := sentMessages()[0]
+		Expect(msg.Tag).To(Equal("app.logs"))
+		Expect(*msg.Options.Size).To(Equal(3))
+		Expect(entries(msg)).To(HaveLen(3))
+		Expect(entries(msg)[2].Record).To(HaveKeyWithValue("i", BeNumerically("==", 2)))
+	})
+
+	It("sends partial batches of each tag on Flush", func() {
+		Expect(bc.SendMessage("app.logs", "a")).To(Succeed())
+		Expect(bc.SendMessage("app.metrics", "b")).To(Succeed())
+		Expect(bc.Flush()).To(Succeed())
+
+		Expect(sentMessages()).To(HaveLen(2))
+		Expect([]string{sentMessages()[0].Tag, sentMessages()[1].Tag}).To(ConsistOf("app.logs", "app.metrics"))
+
+		Expect(bc.Flush()).To(Succeed())
+		Expect(sentMessages()).To(HaveLen(2))
+	})
+
+	It("sends batches every FlushInterval", func() {
+		bc.FlushInterval = 10 * time.Millisecond
+
+		Expect(bc.SendMessage("app.logs", "a")).To(Succeed())
+		Eventually(sentMessages).Should(HaveLen(1))
+	})
+
+	It("keeps a batch that fails to send", func() {
+		sendErr = errors.New("nope")
+
+		Expect(bc.SendMessage("app.logs", "a")).To(Succeed())
+		Expect(bc.Flush()).To(MatchError("nope"))
+
+		sendErr = nil
+		Expect(bc.SendMessage("app.logs", "b")).To(Succeed())
+		Expect(bc.Flush()).To(Succeed())
+
+		msgs := sentMessages()
+		Expect(entries(msgs[len(msgs)-1])).To(HaveLen(2))
+	})
+
+	Describe("CompressionPolicy", func() {
+		BeforeEach(func() {
+			bc.CompressionPolicy = func(tag string) protocol.CompressionAlgorithm {
+				switch {
+				case strings.HasPrefix(tag, "logs."):
+					return protocol.CompressionGzip
+				case strings.HasPrefix(tag, "audit."):
+					return protocol.CompressionZstd
+				default:
+					return protocol.CompressionNone
+				}
+			}
+		})
+
+		It("is consulted for each batch", func() {
+			for _, tag := range []string{"logs.app", "audit.app", "metrics.cpu"} {
+				Expect(bc.SendMessage(tag, "oi")).To(Succeed())
+				Expect(bc.Flush()).To(Succeed())
+			}
+
+			msgs := sentMessages()
+			Expect(msgs[0].CompressionAlgorithm).To(Equal(protocol.CompressionGzip))
+			Expect(msgs[1].CompressionAlgorithm).To(Equal(protocol.CompressionZstd))
+			Expect(msgs[2].CompressionAlgorithm).To(Equal(protocol.CompressionNone))
+		})
+
+		It("defaults to no compression", func() {
+			bc.CompressionPolicy = nil
+
+			Expect(bc.SendMessage("logs.app", "oi")).To(Succeed())
+			Expect(bc.Flush()).To(Succeed())
+			Expect(sentMessages()[0].CompressionAlgorithm).To(Equal(protocol.CompressionNone))
+		})
+	})
+
+	Describe("compressed sizes", func() {
+		random := rand.New(rand.NewSource(1)) //#nosec
+
+		shapes := map[string]func(i int) interface{}{
+			"structured log": func(i int) interface{} {
+				return map[string]interface{}{
+					"level":   "info",
+					"msg":     fmt.Sprintf("handled request %d", i),
+					"service": "checkout",
+					"path":    "/api/v1/orders",
+					"status":  200,
+				}
+			},
+			"binary metric": func(int) interface{} {
+				sample := make([]byte, 64)
+				random.Read(sample)
+
+				return map[string]interface{}{"s": sample}
+			},
+		}
+
+		// sizes returns the encoded size of a batch of shape with each
+		// algorithm.
+		sizes := func(shape string) map[protocol.CompressionAlgorithm]int {
+			bc.BatchSize = 500
+
+			out := map[protocol.CompressionAlgorithm]int{}
+
+			for _, alg := range []protocol.CompressionAlgorithm{protocol.CompressionNone, protocol.CompressionGzip, protocol.CompressionZstd} {
+				alg := alg
+				bc.CompressionPolicy = func(string) protocol.CompressionAlgorithm { return alg }
+
+				for i := 0; i < bc.BatchSize; i++ {
+					Expect(bc.SendMessage(shape, shapes[shape](i))).To(Succeed())
+				}
+
+				msgs := sentMessages()
+				bits, err := msgp.AppendIntf(nil, msgs[len(msgs)-1])
+				Expect(err).ToNot(HaveOccurred())
+
+				out[alg] = len(bits)
+				GinkgoWriter.Printf("%s, %s: %d bytes\n", shape, alg, len(bits))
+			}
+
+			return out
+		}
+
+		It("are much smaller for structured logs", func() {
+			s := sizes("structured log")
+			Expect(s[protocol.CompressionGzip]).To(BeNumerically("<", s[protocol.CompressionNone]/5))
+			Expect(s[protocol.CompressionZstd]).To(BeNumerically("<", s[protocol.CompressionNone]/5))
+		})
+
+		It("are less than a fifth smaller for binary metrics", func() {
+			s := sizes("binary metric")
+			Expect(s[protocol.CompressionGzip]).To(BeNumerically(">", s[protocol.CompressionNone]*4/5))
+			Expect(s[protocol.CompressionZstd]).To(BeNumerically(">", s[protocol.CompressionNone]*4/5))
+		})
+	})
+})
@@ -0,0 +1,262 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+type bidiWrite struct {
+	data   []byte
+	result chan error
+}
+
+// BiDiSession exchanges messages in both directions over a websocket
+// connection, matching each response to the request it answers by the
+// request's chunk ID, as Fluentd does with acks. It runs one loop that
+// reads the connection and one that writes it, so any number of callers
+// may wait on responses at once.
+//
+// Frames that are not a response to a pending request are passed to the
+// connection's previous ReadHandler.
+type BiDiSession struct {
+	conn    ws.Connection
+	next    ws.ReadHandler
+	writes  chan bidiWrite
+	pending map[string]chan []byte
+	lock    sync.Mutex
+	err     error
+	done    chan struct{}
+	start   sync.Once
+	loops   sync.WaitGroup
+}
+
+// NewBiDiSession takes over reading conn, which must not already be
+// listened on. Call Start to begin the exchange.
+func NewBiDiSession(conn ws.Connection) *BiDiSession {
+	s := &BiDiSession{
+		conn:    conn,
+		next:    conn.ReadHandler(),
+		writes:  make(chan bidiWrite),
+		pending: make(map[string]chan []byte),
+		done:    make(chan struct{}),
+	}
+
+	conn.SetReadHandler(s.read)
+
+	return s
+}
+
+// Start starts the read and write loops. It may be called more than once.
+func (s *BiDiSession) Start() {
+	s.start.Do(func() {
+		s.loops.Add(2)
+
+		go func() {
+			defer s.loops.Done()
+
+			s.shutdown(s.conn.Listen())
+		}()
+
+		go s.writeLoop()
+	})
+}
+
+func (s *BiDiSession) writeLoop() {
+	defer s.loops.Done()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case w := <-s.writes:
+			_, err := s.conn.Write(w.data)
+			w.result <- err
+		}
+	}
+}
+
+func (s *BiDiSession) read(conn ws.Connection, messageType int, p []byte, err error) error {
+	if err == nil {
+		var ack protocol.AckMessage
+		if _, uerr := ack.UnmarshalMsg(p); uerr == nil && ack.Ack != "" && s.resolve(ack.Ack, p) {
+			return nil
+		}
+	}
+
+	if s.next != nil {
+		return s.next(conn, messageType, p, err)
+	}
+
+	if err != nil {
+		_ = conn.Close()
+	}
+
+	return err
+}
+
+// resolve hands resp to the caller waiting on chunk, and reports whether
+// there was one.
+func (s *BiDiSession) resolve(chunk string, resp []byte) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ch, ok := s.pending[chunk]
+	if ok {
+		delete(s.pending, chunk)
+		// the read buffer may be reused for the next frame
+		ch <- append([]byte(nil), resp...)
+	}
+
+	return ok
+}
+
+// shutdown fails the pending and future calls once the connection has
+// closed.
+func (s *BiDiSession) shutdown(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return
+	}
+
+	if err != nil {
+		s.err = fmt.Errorf("%w: %v", ErrSessionClosed, err)
+	} else {
+		s.err = ErrSessionClosed
+	}
+
+	s.pending = nil
+	close(s.done)
+}
+
+func (s *BiDiSession) closedErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.err
+}
+
+func (s *BiDiSession) await(chunk string) (<-chan []byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if _, ok := s.pending[chunk]; ok {
+		return nil, fmt.Errorf("chunk %q is already awaiting a response", chunk)
+	}
+
+	ch := make(chan []byte, 1)
+	s.pending[chunk] = ch
+
+	return ch, nil
+}
+
+func (s *BiDiSession) cancel(chunk string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.pending, chunk)
+}
+
+// SendAndReceive sends req and returns the frame that answers it, which
+// is the first whose "ack" matches req's chunk ID. req must have a Chunk
+// method, such as the protocol package's messages do, which sets the ID
+// if it is not already set. It returns ErrSessionClosed if the connection
+// closes first.
+func (s *BiDiSession) SendAndReceive(ctx context.Context, req msgp.Encodable) ([]byte, error) {
+	ce, ok := req.(protocol.ChunkEncoder)
+	if !ok {
+		return nil, errors.New("request has no chunk ID")
+	}
+
+	chunk, err := ce.Chunk()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err = msgp.Encode(&buf, req); err != nil {
+		return nil, err
+	}
+
+	respCh, err := s.await(chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	defer s.cancel(chunk)
+
+	w := bidiWrite{data: buf.Bytes(), result: make(chan error, 1)}
+
+	select {
+	case s.writes <- w:
+	case <-s.done:
+		return nil, s.closedErr()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case err = <-w.result:
+		if err != nil {
+			return nil, err
+		}
+	case <-s.done:
+		return nil, s.closedErr()
+	}
+
+	select {
+	case resp := <-respCh:
+		return resp, nil
+	case <-s.done:
+		return nil, s.closedErr()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("await response: %w", ctx.Err())
+	}
+}
+
+// Close closes the connection and waits for the loops to stop. Calls
+// still waiting return ErrSessionClosed.
+func (s *BiDiSession) Close() error {
+	err := s.conn.Close()
+
+	s.shutdown(nil)
+	s.loops.Wait()
+
+	return err
+}
@@ -0,0 +1,200 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BiDiSession", func() {
+	var (
+		svr     *httptest.Server
+		session *BiDiSession
+		others  chan []byte
+	)
+
+	BeforeEach(func() {
+		// acks each message after a random delay, so acks arrive out of
+		// order; says hello first, ignores tag "ignore" and hangs up on tag
+		// "close"
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+
+			defer wc.Close()
+
+			var writeLock sync.Mutex
+
+			write := func(data []byte) {
+				writeLock.Lock()
+				defer writeLock.Unlock()
+
+				_ = wc.WriteMessage(websocket.BinaryMessage, data)
+			}
+
+			write([]byte("hello"))
+
+			for {
+				_, p, err := wc.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				var msg protocol.Message
+				if _, err := msg.UnmarshalMsg(p); err != nil {
+					return
+				}
+
+				switch msg.Tag {
+				case "close":
+					return
+				case "ignore":
+					continue
+				}
+
+				go func(chunk string) {
+					time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond) //#nosec
+
+					ack, _ := (&protocol.AckMessage{Ack: chunk}).MarshalMsg(nil)
+					write(ack)
+				}(msg.Options.Chunk)
+			}
+		}))
+
+		factory := &DefaultWSConnectionFactory{URL: "ws" + strings.TrimPrefix(svr.URL, "http")}
+
+		conn, err := factory.New(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		others = make(chan []byte, 10)
+
+		wsc, err := ws.NewConnection(conn, ws.ConnectionOptions{
+			ReadHandler: func(_ ws.Connection, _ int, p []byte, err error) error {
+				if err == nil {
+					others <- append([]byte(nil), p...)
+				}
+
+				return err
+			},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		session = NewBiDiSession(wsc)
+		session.Start()
+	})
+
+	AfterEach(func() {
+		_ = session.Close()
+		svr.Close()
+	})
+
+	It("matches each response to its request", func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				msg := protocol.NewMessage("foo", i)
+				msg.Options = &protocol.MessageOptions{Chunk: fmt.Sprintf("chunk-%d", i)}
+
+				resp, err := session.SendAndReceive(context.Background(), msg)
+				Expect(err).ToNot(HaveOccurred())
+
+				var ack protocol.AckMessage
+				_, err = ack.UnmarshalMsg(resp)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ack.Ack).To(Equal(msg.Options.Chunk))
+			}(i)
+		}
+
+		wg.Wait()
+	})
+
+	It("generates a chunk ID if the request has none", func() {
+		msg := protocol.NewMessage("foo", "oi")
+
+		_, err := session.SendAndReceive(context.Background(), msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(msg.Options.Chunk).ToNot(BeEmpty())
+	})
+
+	It("passes other frames to the previous ReadHandler", func() {
+		Eventually(others).Should(Receive(Equal([]byte("hello"))))
+	})
+
+	It("gives up when the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := session.SendAndReceive(ctx, protocol.NewMessage("ignore", "oi"))
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+
+		_, err = session.SendAndReceive(context.Background(), protocol.NewMessage("foo", "oi"))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("fails the waiting calls when the connection closes", func() {
+		_, err := session.SendAndReceive(context.Background(), protocol.NewMessage("close", "oi"))
+		Expect(err).To(MatchError(ErrSessionClosed))
+
+		_, err = session.SendAndReceive(context.Background(), protocol.NewMessage("foo", "oi"))
+		Expect(err).To(MatchError(ErrSessionClosed))
+	})
+
+	It("fails the waiting calls on Close", func() {
+		errs := make(chan error, 1)
+
+		go func() {
+			_, err := session.SendAndReceive(context.Background(), protocol.NewMessage("ignore", "oi"))
+			errs <- err
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		Expect(session.Close()).To(Succeed())
+		Eventually(errs).Should(Receive(MatchError(ErrSessionClosed)))
+	})
+})
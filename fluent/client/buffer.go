@@ -0,0 +1,344 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const DefaultMaxBufferSize = 1000
+
+// BufferFullPolicy decides what happens to a message sent while the
+// client is disconnected and its buffer is full.
+type BufferFullPolicy int
+
+const (
+	// BufferDropOldest discards the oldest buffered message to make room.
+	BufferDropOldest BufferFullPolicy = iota
+	// BufferDropNewest discards the message being sent.
+	BufferDropNewest
+	// BufferReturnError discards the message being sent and returns
+	// ErrBufferFull to the caller.
+	BufferReturnError
+	// BufferSpillToDisk writes the message being sent, and those sent after
+	// it until the buffer has room, to files in SpillDir. They are read back
+	// into the buffer as it is drained.
+	BufferSpillToDisk
+)
+
+// DefaultLowWaterMark is the fraction of MaxBufferSize below which the
+// buffer is refilled from disk, if LowWaterMark is zero.
+const DefaultLowWaterMark = 0.5
+
+// BufferOptions configures the queuing of messages sent while WSClient
+// has no active session.
+type BufferOptions struct {
+	// Enabled turns buffering on. When it is off, sending without an
+	// active session returns an error.
+	Enabled bool
+	// MaxBufferSize is the number of messages that can be buffered. If
+	// zero, DefaultMaxBufferSize is used.
+	MaxBufferSize int
+	// OnBufferFull is the policy applied when the buffer is full.
+	OnBufferFull BufferFullPolicy
+	// SpillDir is the directory BufferSpillToDisk writes to. If empty,
+	// os.TempDir is used. Files are deleted as they are read back, and on
+	// Disconnect, which discards the messages still in them.
+	SpillDir string
+	// SpillDirMaxBytes, if greater than zero, limits the bytes spilled to
+	// disk. Sends block until there is room, or their context is done.
+	SpillDirMaxBytes int64
+	// LowWaterMark is the number of buffered messages below which spilled
+	// messages are read back into the buffer, in the background, as it is
+	// drained. If zero, DefaultLowWaterMark of MaxBufferSize is used.
+	LowWaterMark int
+}
+
+func (o BufferOptions) size() int {
+	if o.MaxBufferSize <= 0 {
+		return DefaultMaxBufferSize
+	}
+
+	return o.MaxBufferSize
+}
+
+func (o BufferOptions) lowWaterMark() int {
+	mark := o.LowWaterMark
+	if mark <= 0 {
+		mark = int(float64(o.size()) * DefaultLowWaterMark)
+	}
+
+	// an empty buffer is always refilled
+	if mark < 1 {
+		return 1
+	}
+
+	return mark
+}
+
+// Buffered returns the number of messages waiting for a connection,
+// including those spilled to disk.
+func (c *WSClient) Buffered() int {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+
+	if c.spill != nil {
+		return len(c.buffer) + c.spill.count
+	}
+
+	return len(c.buffer)
+}
+
+// enqueue adds e to the buffer, applying the OnBufferFull policy if
+// there is no room.
+func (c *WSClient) enqueue(ctx context.Context, e msgp.Encodable) error {
+	dropped, err := c.bufferMessage(ctx, e)
+	if dropped != "" {
+		c.logger().Warnf("message buffer is full, dropped the %s message", dropped)
+	}
+
+	return err
+}
+
+// bufferMessage applies the OnBufferFull policy if the buffer is full,
+// and reports which message, "oldest" or "newest", if any, was dropped.
+func (c *WSClient) bufferMessage(ctx context.Context, e msgp.Encodable) (dropped string, err error) {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+
+	if c.buffer == nil {
+		c.buffer = make(chan msgp.Encodable, c.Buffer.size())
+	}
+
+	// once messages have spilled, new ones must follow them to disk to
+	// keep their order
+	if c.spill == nil || c.spill.count == 0 {
+		select {
+		case c.buffer <- e:
+			return "", nil
+		default:
+		}
+	}
+
+	switch c.Buffer.OnBufferFull {
+	case BufferSpillToDisk:
+		return "", c.spillMessage(ctx, e)
+	case BufferDropNewest:
+		return "newest", nil
+	case BufferReturnError:
+		return "", ErrBufferFull
+	default:
+		<-c.buffer
+		c.buffer <- e
+
+		return "oldest", nil
+	}
+}
+
+// errSpillFreed is returned by spillMessage when it has waited for room
+// on disk, to have the caller try the send again.
+var errSpillFreed = errors.New("spill files freed")
+
+// spillMessage writes e to disk. If SpillDirMaxBytes has been reached, it
+// waits for room and returns errSpillFreed. It must be called with
+// bufferLock held, which it releases while waiting.
+func (c *WSClient) spillMessage(ctx context.Context, e msgp.Encodable) error {
+	var data bytes.Buffer
+	if err := c.encode(&data, e); err != nil {
+		return err
+	}
+
+	if c.spill == nil {
+		c.spill = newSpill(c.Buffer.SpillDir, c.Buffer.SpillDirMaxBytes)
+		go c.refiller(c.spill)
+	}
+
+	if c.spill.count > 0 && !c.spill.fits(data.Len()) {
+		freed := c.spill.freed
+
+		c.bufferLock.Unlock()
+		defer c.bufferLock.Lock()
+
+		select {
+		case <-freed:
+			// files are only freed as the buffer drains, or on
+			// Disconnect, so the send can be tried again
+			return errSpillFreed
+		case <-ctx.Done():
+			return fmt.Errorf("spill to disk: %w", ctx.Err())
+		}
+	}
+
+	if err := c.spill.write(data.Bytes()); err != nil {
+		return fmt.Errorf("spill to disk: %w", err)
+	}
+
+	return nil
+}
+
+// refill moves spilled messages into the buffer once it has drained below
+// the low-water mark. It must be called with bufferLock held.
+func (c *WSClient) refill() error {
+	if c.spill == nil || c.spill.count == 0 || len(c.buffer) >= c.Buffer.lowWaterMark() {
+		return nil
+	}
+
+	for c.spill.count > 0 && len(c.buffer) < cap(c.buffer) {
+		data, err := c.spill.read()
+		if err != nil {
+			return fmt.Errorf("read spilled message: %w", err)
+		}
+
+		c.buffer <- customEncodable{MessageEncoder: encodedMessage(data)}
+	}
+
+	return nil
+}
+
+// refiller refills the buffer from s each time it is woken, until s is
+// closed.
+func (c *WSClient) refiller(s *spill) {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.closed:
+			return
+		}
+
+		c.bufferLock.Lock()
+		if err := c.refill(); err != nil {
+			c.logger().Warnf("refill buffer: %v", err)
+		}
+		c.bufferLock.Unlock()
+	}
+}
+
+// closeSpill deletes the spill files, if any, discarding the messages in
+// them.
+func (c *WSClient) closeSpill() {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+
+	if c.spill == nil {
+		return
+	}
+
+	if c.spill.count > 0 {
+		c.logger().Warnf("discarded %d spilled messages", c.spill.count)
+	}
+
+	c.spill.close()
+	c.spill = nil
+}
+
+// encodedMessage is a message that has already been encoded.
+type encodedMessage []byte
+
+func (m encodedMessage) EncodeTo(w io.Writer) error {
+	_, err := w.Write(m)
+	return err
+}
+
+// drain writes the buffered messages to conn in the order they were
+// sent. It must be called within the scope of an acquired
+// 'c.sessionLock.Lock()', and with the writes lock of session held so
+// that new sends wait until it is done. A message that fails to encode or
+// write is logged and dropped, and draining goes on; if the connection
+// has closed, the rest stay buffered. Only the context being done fails
+// the drain.
+func (c *WSClient) drain(ctx context.Context, session *WSSession) error {
+	var data bytes.Buffer
+
+	for {
+		e, err := c.nextBuffered()
+		if err != nil {
+			return fmt.Errorf("drain buffer: %w", err)
+		}
+
+		if e == nil {
+			return nil
+		}
+
+		data.Reset()
+
+		if err := c.encode(&data, session.Capabilities.gate(e)); err != nil {
+			c.logger().Warnf("drain buffer: dropped a message that failed to encode: %v", err)
+			continue
+		}
+
+		if err := c.writeContext(ctx, session.Connection, data.Bytes()); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("drain buffer: %w", err)
+			}
+
+			c.logger().Warnf("drain buffer: dropped a message that failed to write: %v", err)
+
+			if session.Connection.Closed() {
+				return nil
+			}
+
+			continue
+		}
+
+		if c.AckMode {
+			c.keepDrained(data.Bytes())
+		}
+	}
+}
+
+// nextBuffered removes the oldest buffered message, or returns nil if
+// there is none. Once the buffer is below the low-water mark, the refiller
+// is woken to read spilled messages back while this one is written; if it
+// has not kept up, they are read back at once.
+func (c *WSClient) nextBuffered() (msgp.Encodable, error) {
+	c.bufferLock.Lock()
+	defer c.bufferLock.Unlock()
+
+	if len(c.buffer) == 0 {
+		if err := c.refill(); err != nil {
+			return nil, err
+		}
+	}
+
+	select {
+	case e := <-c.buffer:
+		if c.spill != nil && c.spill.count > 0 && len(c.buffer) < c.Buffer.lowWaterMark() {
+			select {
+			case c.spill.wake <- struct{}{}:
+			default:
+			}
+		}
+
+		return e, nil
+	default:
+		return nil, nil
+	}
+}
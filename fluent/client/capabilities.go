@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// DefaultHandshakeTimeout is how long Handshake waits for the server's
+// capabilities, if HandshakeTimeout is zero.
+const DefaultHandshakeTimeout = 2 * time.Second
+
+// ServerCapabilities are the optional features a server advertised in
+// reply to Handshake. If Negotiated is false, the server did not reply,
+// nothing is known of it, and no feature is withheld from it.
+type ServerCapabilities struct {
+	Negotiated bool
+	// Compression lists the compression algorithms the server accepts,
+	// e.g. "gzip".
+	Compression []string
+	// Ack is true if the server acknowledges chunks.
+	Ack bool
+}
+
+// SupportsCompression reports whether messages compressed with algorithm
+// may be sent to the server.
+func (sc ServerCapabilities) SupportsCompression(algorithm protocol.CompressionAlgorithm) bool {
+	if !sc.Negotiated || algorithm == protocol.CompressionNone {
+		return true
+	}
+
+	for _, supported := range sc.Compression {
+		if supported == algorithm.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SupportsAck reports whether the server acknowledges chunks.
+func (sc ServerCapabilities) SupportsAck() bool {
+	return !sc.Negotiated || sc.Ack
+}
+
+// Handshake sends a HELO CapabilityRequest on conn, advertising what the
+// client supports, and returns the capabilities the server replies with.
+// A server that does not reply within HandshakeTimeout, such as an older
+// Fluentd, is given no-capabilities mode: the result is not Negotiated and
+// the error is nil. As a timed out read leaves a websocket connection
+// unusable, conn must then be replaced, which Connect does by dialing
+// again.
+func (c *WSClient) Handshake(conn ext.Conn) (ServerCapabilities, error) {
+	req := protocol.NewCapabilityRequest(&protocol.CapabilityOpts{
+		Compression: []string{protocol.CompressionGzip.String(), protocol.CompressionZstd.String()},
+		Ack:         c.AckMode,
+	})
+
+	data, err := req.MarshalMsg(nil)
+	if err != nil {
+		return ServerCapabilities{}, err
+	}
+
+	if err = conn.WriteMessage(BinaryMessage, data); err != nil {
+		return ServerCapabilities{}, err
+	}
+
+	timeout := c.HandshakeTimeout
+	if timeout <= 0 {
+		timeout = DefaultHandshakeTimeout
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return ServerCapabilities{}, err
+	}
+
+	_, data, err = conn.ReadMessage()
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ServerCapabilities{}, nil
+	}
+
+	if err != nil {
+		return ServerCapabilities{}, err
+	}
+
+	if err = conn.SetReadDeadline(time.Time{}); err != nil {
+		return ServerCapabilities{}, err
+	}
+
+	var caps protocol.Capabilities
+	if _, err = caps.UnmarshalMsg(data); err != nil || caps.MessageType != protocol.MsgTypeCapabilities {
+		// some other reply; the server does not negotiate
+		return ServerCapabilities{}, nil
+	}
+
+	sc := ServerCapabilities{Negotiated: true}
+	if caps.Options != nil {
+		sc.Compression = caps.Options.Compression
+		sc.Ack = caps.Options.Ack
+	}
+
+	return sc, nil
+}
+
+// negotiate runs the Handshake on conn, dialing again without one if
+// the server does not reply. It must be called within the scope of an
+// acquired 'c.sessionLock.Lock()'.
+func (c *WSClient) negotiate(ctx context.Context, conn ext.Conn) (ext.Conn, ServerCapabilities, error) {
+	caps, err := c.Handshake(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, ServerCapabilities{}, err
+	}
+
+	if caps.Negotiated {
+		return conn, caps, nil
+	}
+
+	_ = conn.Close()
+
+	conn, err = c.ConnectionFactory.New(ctx)
+
+	return conn, caps, err
+}
+
+// gate returns e as it may be sent to a server with caps: a message
+// compressed with an algorithm the server does not accept is sent
+// uncompressed instead.
+func (caps ServerCapabilities) gate(e msgp.Encodable) msgp.Encodable {
+	cpfm, ok := e.(*protocol.CompressedPackedForwardMessage)
+	if !ok || caps.SupportsCompression(cpfm.CompressionAlgorithm) {
+		return e
+	}
+
+	return &protocol.CompressedPackedForwardMessage{
+		PackedForwardMessage: cpfm.PackedForwardMessage,
+		CompressionAlgorithm: protocol.CompressionNone,
+	}
+}
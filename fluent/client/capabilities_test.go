@@ -0,0 +1,276 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ServerCapabilities", func() {
+	It("withholds nothing when not negotiated", func() {
+		var caps client.ServerCapabilities
+
+		Expect(caps.SupportsAck()).To(BeTrue())
+		Expect(caps.SupportsCompression(protocol.CompressionZstd)).To(BeTrue())
+	})
+
+	It("withholds what the server did not advertise", func() {
+		caps := client.ServerCapabilities{
+			Negotiated:  true,
+			Compression: []string{protocol.OptValGZIP},
+		}
+
+		Expect(caps.SupportsAck()).To(BeFalse())
+		Expect(caps.SupportsCompression(protocol.CompressionGzip)).To(BeTrue())
+		Expect(caps.SupportsCompression(protocol.CompressionZstd)).To(BeFalse())
+		Expect(caps.SupportsCompression(protocol.CompressionNone)).To(BeTrue())
+	})
+})
+
+var _ = Describe("WSClient Handshake", func() {
+	var (
+		cli  *client.WSClient
+		conn *extfakes.FakeConn
+	)
+
+	BeforeEach(func() {
+		cli = client.NewWS(client.WSConnectionOptions{
+			AckMode:          true,
+			HandshakeTimeout: 10 * time.Millisecond,
+		})
+		conn = &extfakes.FakeConn{}
+	})
+
+	It("advertises what the client supports", func() {
+		conn.ReadMessageReturns(websocket.BinaryMessage, nil, &timeoutError{})
+
+		_, err := cli.Handshake(conn)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(conn.WriteMessageCallCount()).To(Equal(1))
+		_, data := conn.WriteMessageArgsForCall(0)
+
+		var req protocol.CapabilityRequest
+		_, err = req.UnmarshalMsg(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(req.MessageType).To(Equal(protocol.MsgTypeHelo))
+		Expect(req.Options.Compression).To(ConsistOf(protocol.OptValGZIP, protocol.OptValZSTD))
+		Expect(req.Options.Ack).To(BeTrue())
+	})
+
+	It("returns the server's capabilities", func() {
+		data, err := protocol.NewCapabilities(&protocol.CapabilityOpts{
+			Compression: []string{protocol.OptValGZIP},
+			Ack:         true,
+		}).MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		conn.ReadMessageReturns(websocket.BinaryMessage, data, nil)
+
+		caps, err := cli.Handshake(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps).To(Equal(client.ServerCapabilities{
+			Negotiated:  true,
+			Compression: []string{protocol.OptValGZIP},
+			Ack:         true,
+		}))
+
+		// the read deadline is set, then cleared
+		Expect(conn.SetReadDeadlineCallCount()).To(Equal(2))
+		Expect(conn.SetReadDeadlineArgsForCall(1)).To(BeZero())
+	})
+
+	It("falls back to no capabilities when the server does not reply", func() {
+		conn.ReadMessageReturns(websocket.BinaryMessage, nil, &timeoutError{})
+
+		caps, err := cli.Handshake(conn)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(caps.Negotiated).To(BeFalse())
+	})
+})
+
+var _ = Describe("WSClient with Negotiate", func() {
+	var (
+		svr      *httptest.Server
+		reply    *protocol.Capabilities
+		upgrades int64
+		lock     sync.Mutex
+		received [][]byte
+	)
+
+	messages := func() [][]byte {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([][]byte(nil), received...)
+	}
+
+	BeforeEach(func() {
+		reply, upgrades, received = nil, 0, nil
+
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer wc.Close()
+
+			atomic.AddInt64(&upgrades, 1)
+
+			for {
+				_, data, err := wc.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				var req protocol.CapabilityRequest
+				if _, err := req.UnmarshalMsg(data); err == nil && req.MessageType == protocol.MsgTypeHelo {
+					if reply != nil {
+						data, _ := reply.MarshalMsg(nil)
+						_ = wc.WriteMessage(websocket.BinaryMessage, data)
+					}
+
+					continue
+				}
+
+				lock.Lock()
+				received = append(received, data)
+				lock.Unlock()
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		svr.Close()
+	})
+
+	newClient := func(ackMode bool) *client.WSClient {
+		return client.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+			AckMode:          ackMode,
+			Negotiate:        true,
+			HandshakeTimeout: 50 * time.Millisecond,
+		})
+	}
+
+	When("the server replies", func() {
+		BeforeEach(func() {
+			reply = protocol.NewCapabilities(&protocol.CapabilityOpts{
+				Compression: []string{protocol.OptValGZIP},
+			})
+		})
+
+		It("keeps the capabilities in the session", func() {
+			cli := newClient(false)
+			Expect(cli.Connect()).To(Succeed())
+			defer cli.Disconnect()
+
+			Expect(cli.Session().Capabilities).To(Equal(client.ServerCapabilities{
+				Negotiated:  true,
+				Compression: []string{protocol.OptValGZIP},
+			}))
+			Expect(atomic.LoadInt64(&upgrades)).To(BeEquivalentTo(1))
+		})
+
+		It("sends messages compressed with an unsupported algorithm uncompressed", func() {
+			cli := newClient(false)
+			Expect(cli.Connect()).To(Succeed())
+			defer cli.Disconnect()
+
+			pw := protocol.NewPackedForwardWriter("tag")
+			Expect(pw.Append(protocol.EntryExt{
+				Timestamp: protocol.EventTimeNow(),
+				Record:    map[string]interface{}{"a": "b"},
+			})).To(Succeed())
+
+			msg, err := pw.Message()
+			Expect(err).ToNot(HaveOccurred())
+
+			eventStream := append([]byte(nil), msg.EventStream...)
+
+			Expect(cli.Send(&protocol.CompressedPackedForwardMessage{
+				PackedForwardMessage: msg,
+				CompressionAlgorithm: protocol.CompressionZstd,
+			})).To(Succeed())
+
+			Eventually(messages).Should(HaveLen(1))
+
+			var pfm protocol.PackedForwardMessage
+			_, err = pfm.UnmarshalMsg(messages()[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pfm.EventStream).To(Equal(eventStream))
+			if pfm.Options != nil {
+				Expect(pfm.Options.Compressed).To(BeEmpty())
+			}
+		})
+
+		It("does not send messages that need acks", func() {
+			cli := newClient(true)
+			Expect(cli.Connect()).To(Succeed())
+			defer cli.Disconnect()
+
+			_, err := cli.SendMessageAck(context.Background(), protocol.NewMessage("tag", map[string]interface{}{"a": "b"}))
+			Expect(err).To(MatchError(client.ErrAckUnsupported))
+			Expect(messages()).To(BeEmpty())
+		})
+	})
+
+	When("the server does not reply", func() {
+		It("dials again and withholds nothing", func() {
+			cli := newClient(false)
+			Expect(cli.Connect()).To(Succeed())
+			defer cli.Disconnect()
+
+			Expect(cli.Session().Capabilities.Negotiated).To(BeFalse())
+			Expect(atomic.LoadInt64(&upgrades)).To(BeEquivalentTo(2))
+
+			Expect(cli.Send(protocol.NewMessage("tag", map[string]interface{}{"a": "b"}))).To(Succeed())
+			Eventually(messages).Should(HaveLen(1))
+		})
+	})
+})
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// FingerprintFromCert returns the SHA-256 fingerprint of cert's DER
+// encoding as lowercase hex, the form PinCertificateSHA256 takes.
+func FingerprintFromCert(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// PinCertificateSHA256 makes New reject a server unless one of the
+// certificates it presents has one of fingerprints, as FingerprintFromCert
+// returns them. Colons and case are ignored, so the output of
+// "openssl x509 -fingerprint -sha256" can be used as is. The pins are
+// checked in addition to the usual verification of the chain, not instead
+// of it. Calling it again replaces the pins, and calling it with none
+// removes them. It is thread safe, and affects dials from the next call
+// to New onward.
+//
+// Pins are not rotated automatically. Before the server's certificate is
+// renewed, pin the new fingerprint alongside the old one, or every dial
+// will fail once it is.
+func (wcf *DefaultWSConnectionFactory) PinCertificateSHA256(fingerprints ...string) error {
+	pins := make(map[string]struct{}, len(fingerprints))
+
+	for _, fp := range fingerprints {
+		normalized := strings.ToLower(strings.ReplaceAll(fp, ":", ""))
+
+		if b, err := hex.DecodeString(normalized); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("invalid SHA-256 fingerprint %q", fp)
+		}
+
+		pins[normalized] = struct{}{}
+	}
+
+	if len(pins) == 0 {
+		pins = nil
+	}
+
+	wcf.certLock.Lock()
+	defer wcf.certLock.Unlock()
+
+	wcf.pins = pins
+
+	return nil
+}
+
+func (wcf *DefaultWSConnectionFactory) pinnedFingerprints() map[string]struct{} {
+	wcf.certLock.RLock()
+	defer wcf.certLock.RUnlock()
+
+	return wcf.pins
+}
+
+// verifyPinned returns a tls.Config.VerifyPeerCertificate callback that
+// calls next, if not nil, and then checks the presented certificates
+// against pins.
+func verifyPinned(pins map[string]struct{}, next func([][]byte, [][]*x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, chains); err != nil {
+				return err
+			}
+		}
+
+		for _, raw := range rawCerts {
+			sum := sha256.Sum256(raw)
+			if _, ok := pins[hex.EncodeToString(sum[:])]; ok {
+				return nil
+			}
+		}
+
+		return ErrCertificateNotPinned
+	}
+}
+
+// withPins returns a copy of cfg that also checks the certificates
+// presented against pins.
+func withPins(cfg *tls.Config, pins map[string]struct{}) *tls.Config {
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		cfg = cfg.Clone()
+	}
+
+	cfg.VerifyPeerCertificate = verifyPinned(pins, cfg.VerifyPeerCertificate)
+
+	return cfg
+}
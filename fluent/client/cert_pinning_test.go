@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FingerprintFromCert", func() {
+	It("matches openssl", func() {
+		data, err := os.ReadFile("clientfakes/cert.pem")
+		Expect(err).ToNot(HaveOccurred())
+
+		block, _ := pem.Decode(data)
+		cert, err := x509.ParseCertificate(block.Bytes)
+		Expect(err).ToNot(HaveOccurred())
+
+		// openssl x509 -in clientfakes/cert.pem -noout -fingerprint -sha256
+		Expect(FingerprintFromCert(cert)).To(Equal("876c0aef407e85ab5e86aff2740859fce822e678fa3d4124544f67465c3fa030"))
+	})
+})
+
+var _ = Describe("DefaultWSConnectionFactory with pinned certificates", func() {
+	var (
+		svr     *httptest.Server
+		factory *DefaultWSConnectionFactory
+		pin     string
+	)
+
+	BeforeEach(func() {
+		svr = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				wc.Close()
+			}
+		}))
+
+		cas := x509.NewCertPool()
+		cas.AddCert(svr.Certificate())
+
+		factory = &DefaultWSConnectionFactory{
+			URL:       "wss" + strings.TrimPrefix(svr.URL, "https"),
+			TLSConfig: &tls.Config{RootCAs: cas, MinVersion: tls.VersionTLS12},
+		}
+
+		pin = FingerprintFromCert(svr.Certificate())
+	})
+
+	AfterEach(func() {
+		svr.Close()
+	})
+
+	dial := func() error {
+		conn, err := factory.New(context.Background())
+		if err == nil {
+			conn.Close()
+		}
+
+		return err
+	}
+
+	It("accepts a server with a pinned certificate", func() {
+		other := strings.Repeat("ab", 32)
+
+		Expect(factory.PinCertificateSHA256(other, pin)).To(Succeed())
+		Expect(dial()).To(Succeed())
+		Expect(factory.TLSConfig.VerifyPeerCertificate).To(BeNil())
+	})
+
+	It("accepts fingerprints in the openssl format", func() {
+		var colons []string
+		for i := 0; i < len(pin); i += 2 {
+			colons = append(colons, strings.ToUpper(pin[i:i+2]))
+		}
+
+		Expect(factory.PinCertificateSHA256(strings.Join(colons, ":"))).To(Succeed())
+		Expect(dial()).To(Succeed())
+	})
+
+	It("rejects a server without a pinned certificate", func() {
+		Expect(factory.PinCertificateSHA256(strings.Repeat("ab", 32))).To(Succeed())
+		Expect(errors.Is(dial(), ErrCertificateNotPinned)).To(BeTrue())
+	})
+
+	It("still verifies the chain", func() {
+		factory.TLSConfig.RootCAs = x509.NewCertPool()
+
+		Expect(factory.PinCertificateSHA256(pin)).To(Succeed())
+		err := dial()
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, ErrCertificateNotPinned)).To(BeFalse())
+	})
+
+	It("calls the configured VerifyPeerCertificate", func() {
+		verifyErr := errors.New("not today")
+		factory.TLSConfig.VerifyPeerCertificate = func([][]byte, [][]*x509.Certificate) error {
+			return verifyErr
+		}
+
+		Expect(factory.PinCertificateSHA256(pin)).To(Succeed())
+		Expect(errors.Is(dial(), verifyErr)).To(BeTrue())
+	})
+
+	It("removes the pins when called with none", func() {
+		Expect(factory.PinCertificateSHA256(strings.Repeat("ab", 32))).To(Succeed())
+		Expect(factory.PinCertificateSHA256()).To(Succeed())
+		Expect(dial()).To(Succeed())
+	})
+
+	It("rejects a malformed fingerprint", func() {
+		Expect(factory.PinCertificateSHA256("abc")).To(MatchError(ContainSubstring("invalid SHA-256 fingerprint")))
+		Expect(factory.PinCertificateSHA256(strings.Repeat("zz", 32))).To(HaveOccurred())
+	})
+})
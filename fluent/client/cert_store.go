@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"sync"
+)
+
+// RotatingCertStore holds a client certificate that can be replaced while
+// it is in use, e.g. a short-lived one issued by SPIFFE/SPIRE or Vault
+// PKI. Set its GetClientCertificate as that of a tls.Config, such as the
+// TLSConfig of a DefaultWSConnectionFactory, and every handshake from then
+// on presents the latest certificate passed to Rotate, so a connection
+// made by Reconnect picks up a rotated certificate without any change to
+// the factory. Existing connections are unaffected. The zero value holds
+// no certificate, and it is thread safe.
+//
+// tls.Config prefers GetClientCertificate to Certificates, so it also
+// takes precedence over a certificate set with
+// DefaultWSConnectionFactory.ReloadCertificate.
+type RotatingCertStore struct {
+	cert *tls.Certificate
+	lock sync.RWMutex
+}
+
+func NewRotatingCertStore(cert tls.Certificate) *RotatingCertStore {
+	s := &RotatingCertStore{}
+	s.Rotate(cert)
+
+	return s
+}
+
+// Rotate replaces the certificate presented from the next handshake on.
+func (s *RotatingCertStore) Rotate(cert tls.Certificate) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.cert = &cert
+}
+
+// Certificate returns the current certificate, or nil if none is set.
+func (s *RotatingCertStore) Certificate() *tls.Certificate {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.cert
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate. It
+// returns the current certificate whatever the server asks for, or an
+// empty one, so that none is sent, if none is set.
+func (s *RotatingCertStore) GetClientCertificate(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if cert := s.Certificate(); cert != nil {
+		return cert, nil
+	}
+
+	return &tls.Certificate{}, nil
+}
@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RotatingCertStore", func() {
+	var (
+		svr        *httptest.Server
+		peerCerts  chan []*x509.Certificate
+		clientCert tls.Certificate
+		cas        *x509.CertPool
+	)
+
+	BeforeEach(func() {
+		var err error
+		clientCert, err = tls.LoadX509KeyPair("clientfakes/cert.pem", "clientfakes/key.pem")
+		Expect(err).ToNot(HaveOccurred())
+
+		peerCerts = make(chan []*x509.Certificate, 2)
+
+		svr = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			peerCerts <- r.TLS.PeerCertificates
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				defer wc.Close()
+
+				// keep the connection open until the client closes it
+				_, _, _ = wc.ReadMessage()
+			}
+		}))
+		svr.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+		svr.StartTLS()
+
+		cas = x509.NewCertPool()
+		cas.AddCert(svr.Certificate())
+	})
+
+	AfterEach(func() {
+		svr.Close()
+	})
+
+	It("presents the rotated certificate after a reconnect", func() {
+		store := NewRotatingCertStore(clientCert)
+
+		cli := NewWS(WSConnectionOptions{
+			Factory: &DefaultWSConnectionFactory{
+				URL: "wss" + strings.TrimPrefix(svr.URL, "https"),
+				TLSConfig: &tls.Config{
+					RootCAs:              cas,
+					MinVersion:           tls.VersionTLS12,
+					GetClientCertificate: store.GetClientCertificate,
+				},
+			},
+		})
+
+		Expect(cli.Connect()).To(Succeed())
+		defer cli.Disconnect()
+
+		var peer []*x509.Certificate
+		Eventually(peerCerts).Should(Receive(&peer))
+		Expect(peer[0].Raw).To(Equal(clientCert.Certificate[0]))
+
+		rotated := svr.TLS.Certificates[0]
+		store.Rotate(rotated)
+		Expect(store.Certificate().Certificate[0]).To(Equal(rotated.Certificate[0]))
+
+		Expect(cli.Reconnect()).To(Succeed())
+		Eventually(peerCerts).Should(Receive(&peer))
+		Expect(peer[0].Raw).To(Equal(rotated.Certificate[0]))
+	})
+
+	It("sends no certificate when it holds none", func() {
+		store := &RotatingCertStore{}
+		Expect(store.Certificate()).To(BeNil())
+
+		cert, err := store.GetClientCertificate(&tls.CertificateRequestInfo{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cert.Certificate).To(BeEmpty())
+	})
+})
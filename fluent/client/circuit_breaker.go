@@ -0,0 +1,227 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerCoolDown         = 30 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed allows all attempts.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all attempts until the cool-down has passed.
+	CircuitOpen
+	// CircuitHalfOpen allows a single probe attempt. Its result decides
+	// whether the breaker closes or opens again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// StateChangeHandler is called by a CircuitBreaker after it moves from one
+// state to another.
+type StateChangeHandler func(from, to CircuitState)
+
+// CircuitBreaker stops send attempts after repeated failures. It opens
+// after FailureThreshold consecutive failures within Window, rejects
+// attempts with ErrCircuitOpen for CoolDown, then lets a single probe
+// through. A successful probe closes the breaker; a failed one reopens it.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures that opens
+	// the breaker. If zero, DefaultBreakerFailureThreshold is used.
+	FailureThreshold int
+	// Window limits how far apart the counted failures may be. A failure
+	// that occurs more than Window after the first one in the current run
+	// starts a new run. If zero, failures are counted regardless of age.
+	Window time.Duration
+	// CoolDown is how long the breaker stays open before allowing a probe.
+	// If zero, DefaultBreakerCoolDown is used.
+	CoolDown time.Duration
+	// OnStateChange, if not nil, is called after every state transition.
+	// It is called without the breaker's lock held.
+	OnStateChange StateChangeHandler
+
+	lock         sync.Mutex
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker.
+func NewCircuitBreaker(threshold int, window, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: threshold,
+		Window:           window,
+		CoolDown:         coolDown,
+	}
+}
+
+// State returns the current state of the breaker.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return cb.state
+}
+
+// Allow reports whether an attempt may be made. It returns ErrCircuitOpen
+// while the breaker is open, or while a half-open probe is in flight.
+// Every allowed attempt must be followed by a call to Record.
+func (cb *CircuitBreaker) Allow() error {
+	cb.lock.Lock()
+
+	from := cb.state
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.coolDown() {
+			cb.lock.Unlock()
+			return ErrCircuitOpen
+		}
+
+		cb.state = CircuitHalfOpen
+		cb.probing = true
+	case CircuitHalfOpen:
+		if cb.probing {
+			cb.lock.Unlock()
+			return ErrCircuitOpen
+		}
+
+		cb.probing = true
+	}
+
+	to := cb.state
+	cb.lock.Unlock()
+
+	cb.notify(from, to)
+
+	return nil
+}
+
+// Record reports the result of an attempt allowed by Allow.
+func (cb *CircuitBreaker) Record(err error) {
+	cb.lock.Lock()
+
+	from := cb.state
+
+	if err == nil {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		cb.probing = false
+	} else {
+		cb.recordFailure()
+	}
+
+	to := cb.state
+	cb.lock.Unlock()
+
+	cb.notify(from, to)
+}
+
+// recordFailure must be called with the lock held.
+func (cb *CircuitBreaker) recordFailure() {
+	now := time.Now()
+
+	if cb.state == CircuitHalfOpen {
+		cb.trip(now)
+		return
+	}
+
+	if cb.failures == 0 || (cb.Window > 0 && now.Sub(cb.firstFailure) > cb.Window) {
+		cb.failures = 0
+		cb.firstFailure = now
+	}
+
+	cb.failures++
+
+	if cb.failures >= cb.threshold() {
+		cb.trip(now)
+	}
+}
+
+func (cb *CircuitBreaker) trip(now time.Time) {
+	cb.state = CircuitOpen
+	cb.openedAt = now
+	cb.failures = 0
+	cb.probing = false
+}
+
+// Reset closes the breaker and clears its failure count.
+func (cb *CircuitBreaker) Reset() {
+	cb.lock.Lock()
+
+	from := cb.state
+	cb.state = CircuitClosed
+	cb.failures = 0
+	cb.probing = false
+
+	cb.lock.Unlock()
+
+	cb.notify(from, CircuitClosed)
+}
+
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.OnStateChange != nil {
+		cb.OnStateChange(from, to)
+	}
+}
+
+func (cb *CircuitBreaker) threshold() int {
+	if cb.FailureThreshold <= 0 {
+		return DefaultBreakerFailureThreshold
+	}
+
+	return cb.FailureThreshold
+}
+
+func (cb *CircuitBreaker) coolDown() time.Duration {
+	if cb.CoolDown <= 0 {
+		return DefaultBreakerCoolDown
+	}
+
+	return cb.CoolDown
+}
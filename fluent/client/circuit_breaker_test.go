@@ -0,0 +1,137 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+var _ = Describe("CircuitBreaker", func() {
+	var (
+		breaker     *CircuitBreaker
+		transitions [][2]CircuitState
+		errFail     = errors.New("fail")
+	)
+
+	fail := func(n int) {
+		for i := 0; i < n; i++ {
+			Expect(breaker.Allow()).ToNot(HaveOccurred())
+			breaker.Record(errFail)
+		}
+	}
+
+	BeforeEach(func() {
+		transitions = nil
+		breaker = NewCircuitBreaker(3, 0, 20*time.Millisecond)
+		breaker.OnStateChange = func(from, to CircuitState) {
+			transitions = append(transitions, [2]CircuitState{from, to})
+		}
+	})
+
+	It("starts closed", func() {
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+		Expect(breaker.Allow()).ToNot(HaveOccurred())
+	})
+
+	It("opens after consecutive failures", func() {
+		fail(2)
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+
+		fail(1)
+		Expect(breaker.State()).To(Equal(CircuitOpen))
+		Expect(breaker.Allow()).To(MatchError(ErrCircuitOpen))
+		Expect(transitions).To(Equal([][2]CircuitState{{CircuitClosed, CircuitOpen}}))
+	})
+
+	It("resets the count after a success", func() {
+		fail(2)
+		Expect(breaker.Allow()).ToNot(HaveOccurred())
+		breaker.Record(nil)
+		fail(2)
+
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+	})
+
+	When("a window is set", func() {
+		BeforeEach(func() {
+			breaker.Window = 10 * time.Millisecond
+		})
+
+		It("does not count failures outside the window", func() {
+			fail(2)
+			time.Sleep(15 * time.Millisecond)
+			fail(2)
+
+			Expect(breaker.State()).To(Equal(CircuitClosed))
+		})
+	})
+
+	When("the cool-down has passed", func() {
+		BeforeEach(func() {
+			fail(3)
+			time.Sleep(25 * time.Millisecond)
+		})
+
+		It("allows a single probe", func() {
+			Expect(breaker.Allow()).ToNot(HaveOccurred())
+			Expect(breaker.State()).To(Equal(CircuitHalfOpen))
+			Expect(breaker.Allow()).To(MatchError(ErrCircuitOpen))
+		})
+
+		It("closes after a successful probe", func() {
+			Expect(breaker.Allow()).ToNot(HaveOccurred())
+			breaker.Record(nil)
+
+			Expect(breaker.State()).To(Equal(CircuitClosed))
+			Expect(transitions).To(Equal([][2]CircuitState{
+				{CircuitClosed, CircuitOpen},
+				{CircuitOpen, CircuitHalfOpen},
+				{CircuitHalfOpen, CircuitClosed},
+			}))
+		})
+
+		It("reopens after a failed probe", func() {
+			Expect(breaker.Allow()).ToNot(HaveOccurred())
+			breaker.Record(errFail)
+
+			Expect(breaker.State()).To(Equal(CircuitOpen))
+			Expect(breaker.Allow()).To(MatchError(ErrCircuitOpen))
+		})
+	})
+
+	It("defaults unset fields", func() {
+		breaker = &CircuitBreaker{}
+		fail(DefaultBreakerFailureThreshold)
+
+		Expect(breaker.State()).To(Equal(CircuitOpen))
+		Expect(breaker.Allow()).To(MatchError(ErrCircuitOpen))
+	})
+})
@@ -25,6 +25,7 @@ SOFTWARE.
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -62,6 +63,17 @@ type MessageClient interface {
 	SendRaw(raw []byte) error
 }
 
+// MessageSender implementations send MessagePack messages to a peer over
+// any transport. Client, WSClient, FailoverClient, RoundRobinClient and
+// udp.UDPClient all implement it, so callers can swap transports, or mix
+// them, at runtime.
+//
+//counterfeiter:generate . MessageSender
+type MessageSender interface {
+	Send(e protocol.ChunkEncoder) error
+	SendRaw(raw []byte) error
+}
+
 // ConnectionFactory implementations create new connections
 //
 //counterfeiter:generate . ConnectionFactory
@@ -75,6 +87,7 @@ type Client struct {
 	Timeout     time.Duration
 	AuthInfo    AuthInfo
 	Hostname    string
+	RetryPolicy RetryPolicy
 	session     *Session
 	ackLock     sync.Mutex
 	sessionLock sync.RWMutex
@@ -87,7 +100,8 @@ type ConnectionOptions struct {
 	// TODO:
 	// ReadTimeout       time.Duration
 	// WriteTimeout      time.Duration
-	AuthInfo AuthInfo
+	AuthInfo    AuthInfo
+	RetryPolicy RetryPolicy
 }
 
 type AuthInfo struct {
@@ -119,6 +133,7 @@ func New(opts ConnectionOptions) *Client {
 		AuthInfo:          opts.AuthInfo,
 		RequireAck:        opts.RequireAck,
 		Timeout:           opts.ConnectionTimeout,
+		RetryPolicy:       opts.RetryPolicy,
 	}
 }
 
@@ -245,6 +260,41 @@ func (c *Client) Reconnect() error {
 	return c.connect()
 }
 
+// ReconnectWithRetry calls Reconnect until it succeeds, the RetryPolicy
+// runs out of attempts, or the context is done, waiting between attempts
+// for the delay given by the policy. Permanent errors (see IsPermanent)
+// end the retries at once. If RetryPolicy is nil, a single reconnect is
+// attempted.
+func (c *Client) ReconnectWithRetry(ctx context.Context) error {
+	if c.RetryPolicy == nil {
+		return c.Reconnect()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.Reconnect()
+		if err == nil {
+			return nil
+		}
+
+		if IsPermanent(err) {
+			return err
+		}
+
+		if maxAttempts := c.RetryPolicy.MaxAttempts(); maxAttempts > 0 && attempt >= maxAttempts {
+			return fmt.Errorf("reconnect failed after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(c.RetryPolicy.NextDelay(attempt))
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("reconnect: %w", ctx.Err())
+		}
+	}
+}
+
 func (c *Client) checkAck(chunk string) error {
 	if c.Timeout != 0 {
 		if err := c.session.Connection.SetReadDeadline(time.Now().Add(c.Timeout)); err != nil {
@@ -258,7 +308,7 @@ func (c *Client) checkAck(chunk string) error {
 	}
 
 	if ack.Ack != chunk {
-		return fmt.Errorf("Expected chunk %s, but got %s", chunk, ack.Ack)
+		return Permanent(fmt.Errorf("Expected chunk %s, but got %s", chunk, ack.Ack))
 	}
 
 	return nil
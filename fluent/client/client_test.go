@@ -25,6 +25,7 @@ SOFTWARE.
 package client_test
 
 import (
+	"context"
 	"errors"
 	"math/rand"
 	"net"
@@ -127,6 +128,43 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("ReconnectWithRetry", func() {
+		BeforeEach(func() {
+			client.RetryPolicy = &DefaultExponentialBackoff{
+				BaseDelay: time.Millisecond,
+				Attempts:  3,
+			}
+		})
+
+		It("retries until the factory succeeds", func() {
+			factory.NewReturnsOnCall(0, nil, errors.New("nope"))
+			factory.NewReturnsOnCall(1, clientSide, nil)
+
+			Expect(client.ReconnectWithRetry(context.Background())).To(Succeed())
+			Expect(factory.NewCallCount()).To(Equal(2))
+		})
+
+		It("gives up after MaxAttempts", func() {
+			factory.NewReturnsOnCall(0, nil, errors.New("nope"))
+			factory.NewReturnsOnCall(1, nil, errors.New("nope"))
+			factory.NewReturnsOnCall(2, nil, errors.New("nope"))
+
+			err := client.ReconnectWithRetry(context.Background())
+			Expect(err).To(MatchError(MatchRegexp("after 3 attempts: nope")))
+			Expect(factory.NewCallCount()).To(Equal(3))
+		})
+
+		It("stops when the context is done", func() {
+			factory.NewReturns(nil, errors.New("nope"))
+			client.RetryPolicy = &DefaultExponentialBackoff{BaseDelay: time.Minute}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			Expect(client.ReconnectWithRetry(ctx)).To(MatchError(context.DeadlineExceeded))
+		})
+	})
+
 	Describe("Send", func() {
 		var (
 			serverSide net.Conn
@@ -0,0 +1,118 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package clientfakes
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+type FakeAuthProvider struct {
+	HeadersStub        func(context.Context) (http.Header, error)
+	headersMutex       sync.RWMutex
+	headersArgsForCall []struct {
+		arg1 context.Context
+	}
+	headersReturns struct {
+		result1 http.Header
+		result2 error
+	}
+	headersReturnsOnCall map[int]struct {
+		result1 http.Header
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAuthProvider) Headers(arg1 context.Context) (http.Header, error) {
+	fake.headersMutex.Lock()
+	ret, specificReturn := fake.headersReturnsOnCall[len(fake.headersArgsForCall)]
+	fake.headersArgsForCall = append(fake.headersArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	stub := fake.HeadersStub
+	fakeReturns := fake.headersReturns
+	fake.recordInvocation("Headers", []interface{}{arg1})
+	fake.headersMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAuthProvider) HeadersCallCount() int {
+	fake.headersMutex.RLock()
+	defer fake.headersMutex.RUnlock()
+	return len(fake.headersArgsForCall)
+}
+
+func (fake *FakeAuthProvider) HeadersCalls(stub func(context.Context) (http.Header, error)) {
+	fake.headersMutex.Lock()
+	defer fake.headersMutex.Unlock()
+	fake.HeadersStub = stub
+}
+
+func (fake *FakeAuthProvider) HeadersArgsForCall(i int) context.Context {
+	fake.headersMutex.RLock()
+	defer fake.headersMutex.RUnlock()
+	argsForCall := fake.headersArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeAuthProvider) HeadersReturns(result1 http.Header, result2 error) {
+	fake.headersMutex.Lock()
+	defer fake.headersMutex.Unlock()
+	fake.HeadersStub = nil
+	fake.headersReturns = struct {
+		result1 http.Header
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAuthProvider) HeadersReturnsOnCall(i int, result1 http.Header, result2 error) {
+	fake.headersMutex.Lock()
+	defer fake.headersMutex.Unlock()
+	fake.HeadersStub = nil
+	if fake.headersReturnsOnCall == nil {
+		fake.headersReturnsOnCall = make(map[int]struct {
+			result1 http.Header
+			result2 error
+		})
+	}
+	fake.headersReturnsOnCall[i] = struct {
+		result1 http.Header
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAuthProvider) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.headersMutex.RLock()
+	defer fake.headersMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeAuthProvider) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ client.AuthProvider = new(FakeAuthProvider)
@@ -2,6 +2,7 @@
 package clientfakes
 
 import (
+	"context"
 	"sync"
 
 	"github.com/IBM/fluent-forward-go/fluent/client"
@@ -10,9 +11,10 @@ import (
 )
 
 type FakeClientFactory struct {
-	NewStub        func() (ext.Conn, error)
+	NewStub        func(context.Context) (ext.Conn, error)
 	newMutex       sync.RWMutex
 	newArgsForCall []struct {
+		arg1 context.Context
 	}
 	newReturns struct {
 		result1 ext.Conn
@@ -37,17 +39,18 @@ type FakeClientFactory struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeClientFactory) New() (ext.Conn, error) {
+func (fake *FakeClientFactory) New(arg1 context.Context) (ext.Conn, error) {
 	fake.newMutex.Lock()
 	ret, specificReturn := fake.newReturnsOnCall[len(fake.newArgsForCall)]
 	fake.newArgsForCall = append(fake.newArgsForCall, struct {
-	}{})
+		arg1 context.Context
+	}{arg1})
 	stub := fake.NewStub
 	fakeReturns := fake.newReturns
-	fake.recordInvocation("New", []interface{}{})
+	fake.recordInvocation("New", []interface{}{arg1})
 	fake.newMutex.Unlock()
 	if stub != nil {
-		return stub()
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -61,12 +64,19 @@ func (fake *FakeClientFactory) NewCallCount() int {
 	return len(fake.newArgsForCall)
 }
 
-func (fake *FakeClientFactory) NewCalls(stub func() (ext.Conn, error)) {
+func (fake *FakeClientFactory) NewCalls(stub func(context.Context) (ext.Conn, error)) {
 	fake.newMutex.Lock()
 	defer fake.newMutex.Unlock()
 	fake.NewStub = stub
 }
 
+func (fake *FakeClientFactory) NewArgsForCall(i int) context.Context {
+	fake.newMutex.RLock()
+	defer fake.newMutex.RUnlock()
+	argsForCall := fake.newArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeClientFactory) NewReturns(result1 ext.Conn, result2 error) {
 	fake.newMutex.Lock()
 	defer fake.newMutex.Unlock()
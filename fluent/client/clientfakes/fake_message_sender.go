@@ -0,0 +1,191 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package clientfakes
+
+import (
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+type FakeMessageSender struct {
+	SendStub        func(protocol.ChunkEncoder) error
+	sendMutex       sync.RWMutex
+	sendArgsForCall []struct {
+		arg1 protocol.ChunkEncoder
+	}
+	sendReturns struct {
+		result1 error
+	}
+	sendReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SendRawStub        func([]byte) error
+	sendRawMutex       sync.RWMutex
+	sendRawArgsForCall []struct {
+		arg1 []byte
+	}
+	sendRawReturns struct {
+		result1 error
+	}
+	sendRawReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeMessageSender) Send(arg1 protocol.ChunkEncoder) error {
+	fake.sendMutex.Lock()
+	ret, specificReturn := fake.sendReturnsOnCall[len(fake.sendArgsForCall)]
+	fake.sendArgsForCall = append(fake.sendArgsForCall, struct {
+		arg1 protocol.ChunkEncoder
+	}{arg1})
+	stub := fake.SendStub
+	fakeReturns := fake.sendReturns
+	fake.recordInvocation("Send", []interface{}{arg1})
+	fake.sendMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeMessageSender) SendCallCount() int {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	return len(fake.sendArgsForCall)
+}
+
+func (fake *FakeMessageSender) SendCalls(stub func(protocol.ChunkEncoder) error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = stub
+}
+
+func (fake *FakeMessageSender) SendArgsForCall(i int) protocol.ChunkEncoder {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	argsForCall := fake.sendArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeMessageSender) SendReturns(result1 error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = nil
+	fake.sendReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeMessageSender) SendReturnsOnCall(i int, result1 error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = nil
+	if fake.sendReturnsOnCall == nil {
+		fake.sendReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.sendReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeMessageSender) SendRaw(arg1 []byte) error {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.sendRawMutex.Lock()
+	ret, specificReturn := fake.sendRawReturnsOnCall[len(fake.sendRawArgsForCall)]
+	fake.sendRawArgsForCall = append(fake.sendRawArgsForCall, struct {
+		arg1 []byte
+	}{arg1Copy})
+	stub := fake.SendRawStub
+	fakeReturns := fake.sendRawReturns
+	fake.recordInvocation("SendRaw", []interface{}{arg1Copy})
+	fake.sendRawMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeMessageSender) SendRawCallCount() int {
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	return len(fake.sendRawArgsForCall)
+}
+
+func (fake *FakeMessageSender) SendRawCalls(stub func([]byte) error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = stub
+}
+
+func (fake *FakeMessageSender) SendRawArgsForCall(i int) []byte {
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	argsForCall := fake.sendRawArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeMessageSender) SendRawReturns(result1 error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = nil
+	fake.sendRawReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeMessageSender) SendRawReturnsOnCall(i int, result1 error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = nil
+	if fake.sendRawReturnsOnCall == nil {
+		fake.sendRawReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.sendRawReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeMessageSender) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeMessageSender) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ client.MessageSender = new(FakeMessageSender)
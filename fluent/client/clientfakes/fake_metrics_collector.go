@@ -0,0 +1,122 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package clientfakes
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+type FakeMetricsCollector struct {
+	RecordReconnectStub        func(int, error)
+	recordReconnectMutex       sync.RWMutex
+	recordReconnectArgsForCall []struct {
+		arg1 int
+		arg2 error
+	}
+	RecordSendStub        func(string, time.Duration, error)
+	recordSendMutex       sync.RWMutex
+	recordSendArgsForCall []struct {
+		arg1 string
+		arg2 time.Duration
+		arg3 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeMetricsCollector) RecordReconnect(arg1 int, arg2 error) {
+	fake.recordReconnectMutex.Lock()
+	fake.recordReconnectArgsForCall = append(fake.recordReconnectArgsForCall, struct {
+		arg1 int
+		arg2 error
+	}{arg1, arg2})
+	stub := fake.RecordReconnectStub
+	fake.recordInvocation("RecordReconnect", []interface{}{arg1, arg2})
+	fake.recordReconnectMutex.Unlock()
+	if stub != nil {
+		fake.RecordReconnectStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeMetricsCollector) RecordReconnectCallCount() int {
+	fake.recordReconnectMutex.RLock()
+	defer fake.recordReconnectMutex.RUnlock()
+	return len(fake.recordReconnectArgsForCall)
+}
+
+func (fake *FakeMetricsCollector) RecordReconnectCalls(stub func(int, error)) {
+	fake.recordReconnectMutex.Lock()
+	defer fake.recordReconnectMutex.Unlock()
+	fake.RecordReconnectStub = stub
+}
+
+func (fake *FakeMetricsCollector) RecordReconnectArgsForCall(i int) (int, error) {
+	fake.recordReconnectMutex.RLock()
+	defer fake.recordReconnectMutex.RUnlock()
+	argsForCall := fake.recordReconnectArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeMetricsCollector) RecordSend(arg1 string, arg2 time.Duration, arg3 error) {
+	fake.recordSendMutex.Lock()
+	fake.recordSendArgsForCall = append(fake.recordSendArgsForCall, struct {
+		arg1 string
+		arg2 time.Duration
+		arg3 error
+	}{arg1, arg2, arg3})
+	stub := fake.RecordSendStub
+	fake.recordInvocation("RecordSend", []interface{}{arg1, arg2, arg3})
+	fake.recordSendMutex.Unlock()
+	if stub != nil {
+		fake.RecordSendStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeMetricsCollector) RecordSendCallCount() int {
+	fake.recordSendMutex.RLock()
+	defer fake.recordSendMutex.RUnlock()
+	return len(fake.recordSendArgsForCall)
+}
+
+func (fake *FakeMetricsCollector) RecordSendCalls(stub func(string, time.Duration, error)) {
+	fake.recordSendMutex.Lock()
+	defer fake.recordSendMutex.Unlock()
+	fake.RecordSendStub = stub
+}
+
+func (fake *FakeMetricsCollector) RecordSendArgsForCall(i int) (string, time.Duration, error) {
+	fake.recordSendMutex.RLock()
+	defer fake.recordSendMutex.RUnlock()
+	argsForCall := fake.recordSendArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeMetricsCollector) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.recordReconnectMutex.RLock()
+	defer fake.recordReconnectMutex.RUnlock()
+	fake.recordSendMutex.RLock()
+	defer fake.recordSendMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeMetricsCollector) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ client.MetricsCollector = new(FakeMetricsCollector)
@@ -2,6 +2,7 @@
 package clientfakes
 
 import (
+	"context"
 	"sync"
 
 	"github.com/IBM/fluent-forward-go/fluent/client"
@@ -10,9 +11,10 @@ import (
 )
 
 type FakeWSConnectionFactory struct {
-	NewStub        func() (ext.Conn, error)
+	NewStub        func(context.Context) (ext.Conn, error)
 	newMutex       sync.RWMutex
 	newArgsForCall []struct {
+		arg1 context.Context
 	}
 	newReturns struct {
 		result1 ext.Conn
@@ -37,17 +39,18 @@ type FakeWSConnectionFactory struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeWSConnectionFactory) New() (ext.Conn, error) {
+func (fake *FakeWSConnectionFactory) New(arg1 context.Context) (ext.Conn, error) {
 	fake.newMutex.Lock()
 	ret, specificReturn := fake.newReturnsOnCall[len(fake.newArgsForCall)]
 	fake.newArgsForCall = append(fake.newArgsForCall, struct {
-	}{})
+		arg1 context.Context
+	}{arg1})
 	stub := fake.NewStub
 	fakeReturns := fake.newReturns
-	fake.recordInvocation("New", []interface{}{})
+	fake.recordInvocation("New", []interface{}{arg1})
 	fake.newMutex.Unlock()
 	if stub != nil {
-		return stub()
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -61,12 +64,19 @@ func (fake *FakeWSConnectionFactory) NewCallCount() int {
 	return len(fake.newArgsForCall)
 }
 
-func (fake *FakeWSConnectionFactory) NewCalls(stub func() (ext.Conn, error)) {
+func (fake *FakeWSConnectionFactory) NewCalls(stub func(context.Context) (ext.Conn, error)) {
 	fake.newMutex.Lock()
 	defer fake.newMutex.Unlock()
 	fake.NewStub = stub
 }
 
+func (fake *FakeWSConnectionFactory) NewArgsForCall(i int) context.Context {
+	fake.newMutex.RLock()
+	defer fake.newMutex.RUnlock()
+	argsForCall := fake.newArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeWSConnectionFactory) NewReturns(result1 ext.Conn, result2 error) {
 	fake.newMutex.Lock()
 	defer fake.newMutex.Unlock()
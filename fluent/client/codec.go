@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	MsgpackContentType = "application/msgpack"
+	JSONContentType    = "application/json"
+)
+
+// Codec encodes the messages a WSClient sends. Replace the default
+// MsgpackCodec to send to downstream processors that do not read
+// MessagePack, or to plug in a faster encoder.
+type Codec interface {
+	Encode(w io.Writer, v interface{}) error
+	// ContentType is the MIME type of the encoded messages.
+	ContentType() string
+}
+
+// MsgpackCodec encodes messages as MessagePack, as the Forward protocol
+// requires. Values that implement msgp.Encodable use their generated
+// encoders; others are encoded by reflection.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Encode(w io.Writer, v interface{}) error {
+	if e, ok := v.(msgp.Encodable); ok {
+		return msgp.Encode(w, e)
+	}
+
+	mw := msgp.NewWriter(w)
+	if err := mw.WriteIntf(v); err != nil {
+		return err
+	}
+
+	return mw.Flush()
+}
+
+func (MsgpackCodec) ContentType() string {
+	return MsgpackContentType
+}
+
+// JSONCodec encodes messages with encoding/json, one JSON document per
+// message. Protocol messages are encoded as JSON objects of their fields,
+// not in the Forward protocol's array layout.
+type JSONCodec struct{}
+
+func (JSONCodec) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) ContentType() string {
+	return JSONContentType
+}
@@ -0,0 +1,116 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("Codec", func() {
+	Describe("MsgpackCodec", func() {
+		It("encodes protocol messages with their generated encoders", func() {
+			msg := protocol.NewMessage("foo", map[string]interface{}{"log": "oi"})
+
+			buf := &bytes.Buffer{}
+			Expect(MsgpackCodec{}.Encode(buf, msg)).To(Succeed())
+
+			var decoded protocol.Message
+			Expect(msgp.Decode(buf, &decoded)).To(Succeed())
+			Expect(decoded.Tag).To(Equal("foo"))
+			Expect(MsgpackCodec{}.ContentType()).To(Equal("application/msgpack"))
+		})
+
+		It("encodes other values by reflection", func() {
+			buf := &bytes.Buffer{}
+			Expect(MsgpackCodec{}.Encode(buf, map[string]interface{}{"log": "oi"})).To(Succeed())
+
+			v, _, err := msgp.ReadIntfBytes(buf.Bytes())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v).To(Equal(map[string]interface{}{"log": "oi"}))
+		})
+	})
+
+	Describe("JSONCodec", func() {
+		It("encodes values as JSON", func() {
+			buf := &bytes.Buffer{}
+			Expect(JSONCodec{}.Encode(buf, map[string]interface{}{"log": "oi"})).To(Succeed())
+
+			Expect(buf.String()).To(MatchJSON(`{"log":"oi"}`))
+			Expect(JSONCodec{}.ContentType()).To(Equal("application/json"))
+		})
+	})
+
+	Describe("WSClient", func() {
+		var (
+			conn *wsfakes.FakeConnection
+			wc   *WSClient
+		)
+
+		BeforeEach(func() {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn = &wsfakes.FakeConnection{}
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			wc = NewWS(WSConnectionOptions{
+				Factory: factory,
+				Codec:   JSONCodec{},
+			})
+			Expect(wc.Connect()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(wc.Disconnect()).To(Succeed())
+		})
+
+		It("encodes sent messages with its Codec", func() {
+			Expect(wc.Send(protocol.NewMessage("foo", map[string]interface{}{"log": "oi"}))).To(Succeed())
+
+			Expect(conn.WriteCallCount()).To(Equal(1))
+
+			var decoded map[string]interface{}
+			Expect(json.Unmarshal(conn.WriteArgsForCall(0), &decoded)).To(Succeed())
+			Expect(decoded).To(HaveKeyWithValue("Tag", "foo"))
+			Expect(decoded).To(HaveKeyWithValue("Record", map[string]interface{}{"log": "oi"}))
+		})
+
+		It("respects MaxMessageBytes", func() {
+			wc.MaxMessageBytes = 8
+
+			Expect(wc.Send(protocol.NewMessage("foo", nil))).To(MatchError(ErrMessageTooLarge))
+			Expect(conn.WriteCallCount()).To(BeZero())
+		})
+	})
+})
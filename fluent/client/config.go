@@ -0,0 +1,511 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"gopkg.in/yaml.v3"
+)
+
+// The environment variables read by WSClientConfigFromEnv.
+const (
+	EnvPrefix          = "FLUENT_"
+	EnvServerHost      = EnvPrefix + "SERVER_HOST"
+	EnvServerPort      = EnvPrefix + "SERVER_PORT"
+	EnvIAMToken        = EnvPrefix + "IAM_TOKEN"
+	EnvTLSCert         = EnvPrefix + "TLS_CERT"
+	EnvTLSKey          = EnvPrefix + "TLS_KEY"
+	EnvTLSCA           = EnvPrefix + "TLS_CA"
+	EnvPingInterval    = EnvPrefix + "PING_INTERVAL"
+	EnvWriteTimeout    = EnvPrefix + "WRITE_TIMEOUT"
+	EnvMaxMessageBytes = EnvPrefix + "MAX_MESSAGE_BYTES"
+	EnvTagPrefix       = EnvPrefix + "TAG_PREFIX"
+)
+
+// WSClientConfig describes a WSClient in plain values, e.g. as read from
+// the environment by WSClientConfigFromEnv or from a file by LoadConfig.
+// Build makes the client.
+type WSClientConfig struct {
+	Server     ServerConfig     `json:"server" yaml:"server"`
+	Auth       AuthConfig       `json:"auth" yaml:"auth"`
+	TLS        TLSFilesConfig   `json:"tls" yaml:"tls"`
+	Connection ConnectionConfig `json:"connection" yaml:"connection"`
+	Retry      RetryConfig      `json:"retry" yaml:"retry"`
+	Metrics    MetricsConfig    `json:"metrics" yaml:"metrics"`
+	RateLimit  RateLimitConfig  `json:"rate_limit" yaml:"rate_limit"`
+	Log        LogConfig        `json:"log" yaml:"log"`
+	// TagPrefix is prepended to the tag of every message. See
+	// WSClient.TagPrefix.
+	TagPrefix string `json:"tag_prefix" yaml:"tag_prefix"`
+}
+
+// ServerConfig is the address of the server.
+type ServerConfig struct {
+	Host string `json:"host" yaml:"host"`
+	// Port is appended to Host if it is not zero.
+	Port int `json:"port" yaml:"port"`
+}
+
+// AuthConfig holds the credentials sent to the server.
+type AuthConfig struct {
+	// IAMToken, if not empty, is sent as a bearer token.
+	IAMToken string `json:"iam_token" yaml:"iam_token"`
+}
+
+// TLSFilesConfig names the PEM files used for TLS. If any is set, the
+// client connects with wss://.
+type TLSFilesConfig struct {
+	// CertFile and KeyFile are the client certificate and its key, for
+	// mutual TLS. They must be set together.
+	CertFile string `json:"cert_file" yaml:"cert_file"`
+	KeyFile  string `json:"key_file" yaml:"key_file"`
+	// CAFile holds the CA certificates that the server's certificate is
+	// verified against. If empty, the host's root CA set is used.
+	CAFile string `json:"ca_file" yaml:"ca_file"`
+}
+
+// ConnectionConfig tunes the websocket connection. Zero values keep the
+// defaults.
+type ConnectionConfig struct {
+	PingInterval    Duration `json:"ping_interval" yaml:"ping_interval"`
+	WriteTimeout    Duration `json:"write_timeout" yaml:"write_timeout"`
+	MaxMessageBytes int64    `json:"max_message_bytes" yaml:"max_message_bytes"`
+}
+
+// RetryConfig is the DefaultExponentialBackoff used by ReconnectWithRetry.
+// If it is the zero value, a single reconnect attempt is made.
+type RetryConfig struct {
+	BaseDelay  Duration `json:"base_delay" yaml:"base_delay"`
+	Multiplier float64  `json:"multiplier" yaml:"multiplier"`
+	MaxDelay   Duration `json:"max_delay" yaml:"max_delay"`
+	Jitter     float64  `json:"jitter" yaml:"jitter"`
+	Attempts   int      `json:"attempts" yaml:"attempts"`
+}
+
+// MetricsConfig chooses the MetricsCollector. If Provider is empty, no
+// measurements are collected.
+type MetricsConfig struct {
+	// Provider names a MetricsProvider registered with
+	// RegisterMetricsProvider, e.g. "prometheus" once the metrics package
+	// is imported.
+	Provider string `json:"provider" yaml:"provider"`
+	// Namespace prefixes the metric names, if the provider has them.
+	Namespace string `json:"namespace" yaml:"namespace"`
+	// ClientName tells apart the measurements of clients that share a
+	// provider.
+	ClientName string `json:"client_name" yaml:"client_name"`
+}
+
+// RateLimitConfig limits how often the client sends. If PerSecond is zero,
+// sends are not limited.
+type RateLimitConfig struct {
+	PerSecond float64 `json:"per_second" yaml:"per_second"`
+	// Burst is the number of sends that may be made at once. If zero,
+	// bursts of up to one second's worth are allowed.
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// The levels of LogConfig.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelNone  = "none"
+)
+
+// LogConfig chooses the client's Logger. If Level is empty, the Logger is
+// left as it is.
+type LogConfig struct {
+	// Level is LogLevelDebug or LogLevelInfo to log to the standard logger
+	// with or without debug lines, or LogLevelNone to log nothing.
+	Level string `json:"level" yaml:"level"`
+}
+
+// MetricsProvider makes the MetricsCollector of a client built from a
+// WSClientConfig. server is the URL the client connects to.
+type MetricsProvider func(cfg MetricsConfig, server string) (MetricsCollector, error)
+
+var (
+	metricsProviders     = map[string]MetricsProvider{}
+	metricsProvidersLock sync.RWMutex
+)
+
+// RegisterMetricsProvider makes provider available to WSClientConfig.Build
+// under name, replacing any provider registered under it before. It is
+// meant to be called from the init function of the provider's package.
+func RegisterMetricsProvider(name string, provider MetricsProvider) {
+	metricsProvidersLock.Lock()
+	defer metricsProvidersLock.Unlock()
+
+	metricsProviders[name] = provider
+}
+
+func metricsProvider(name string) (MetricsProvider, bool) {
+	metricsProvidersLock.RLock()
+	defer metricsProvidersLock.RUnlock()
+
+	provider, ok := metricsProviders[name]
+
+	return provider, ok
+}
+
+// Duration is a time.Duration that configuration files give as a string
+// parsed by time.ParseDuration, e.g. "30s".
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string, e.g. \"30s\": %w", err)
+	}
+
+	return d.parse(s)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return err
+	}
+
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	d.Duration = duration
+
+	return nil
+}
+
+var envVars = map[string]bool{
+	EnvServerHost:      true,
+	EnvServerPort:      true,
+	EnvIAMToken:        true,
+	EnvTLSCert:         true,
+	EnvTLSKey:          true,
+	EnvTLSCA:           true,
+	EnvPingInterval:    true,
+	EnvWriteTimeout:    true,
+	EnvMaxMessageBytes: true,
+	EnvTagPrefix:       true,
+}
+
+// WSClientConfigFromEnv reads a WSClientConfig from the FLUENT_ environment
+// variables, e.g. FLUENT_SERVER_HOST. Durations are parsed with
+// time.ParseDuration. Other variables with the FLUENT_ prefix, which are
+// likely misspelt, are logged as warnings to the standard logger.
+func WSClientConfigFromEnv() (WSClientConfig, error) {
+	var (
+		cfg WSClientConfig
+		err error
+	)
+
+	logger := NewStandardLogger(nil)
+
+	for _, kv := range os.Environ() {
+		name := strings.SplitN(kv, "=", 2)[0]
+		if strings.HasPrefix(name, EnvPrefix) && !envVars[name] {
+			logger.Warnf("unknown environment variable %s", name)
+		}
+	}
+
+	cfg.Server.Host = os.Getenv(EnvServerHost)
+	cfg.Auth.IAMToken = os.Getenv(EnvIAMToken)
+	cfg.TLS.CertFile = os.Getenv(EnvTLSCert)
+	cfg.TLS.KeyFile = os.Getenv(EnvTLSKey)
+	cfg.TLS.CAFile = os.Getenv(EnvTLSCA)
+	cfg.TagPrefix = os.Getenv(EnvTagPrefix)
+
+	if v := os.Getenv(EnvServerPort); v != "" {
+		if cfg.Server.Port, err = strconv.Atoi(v); err != nil {
+			return WSClientConfig{}, fmt.Errorf("%s: %w", EnvServerPort, err)
+		}
+	}
+
+	if v := os.Getenv(EnvPingInterval); v != "" {
+		if err = cfg.Connection.PingInterval.parse(v); err != nil {
+			return WSClientConfig{}, fmt.Errorf("%s: %w", EnvPingInterval, err)
+		}
+	}
+
+	if v := os.Getenv(EnvWriteTimeout); v != "" {
+		if err = cfg.Connection.WriteTimeout.parse(v); err != nil {
+			return WSClientConfig{}, fmt.Errorf("%s: %w", EnvWriteTimeout, err)
+		}
+	}
+
+	if v := os.Getenv(EnvMaxMessageBytes); v != "" {
+		if cfg.Connection.MaxMessageBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return WSClientConfig{}, fmt.Errorf("%s: %w", EnvMaxMessageBytes, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// LoadConfig reads a WSClientConfig from the file at path, as YAML if its
+// extension is .yaml or .yml and as JSON if it is .json. Fields the
+// configuration does not have are rejected, so that misspelt ones are not
+// ignored. See the package documentation for the format.
+func LoadConfig(path string) (WSClientConfig, error) {
+	var cfg WSClientConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&cfg)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	default:
+		return cfg, fmt.Errorf("config file %s: unknown extension %q", path, ext)
+	}
+
+	if err != nil {
+		return WSClientConfig{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// NewWSClientFromEnv builds a WSClient from the configuration read by
+// WSClientConfigFromEnv.
+func NewWSClientFromEnv() (*WSClient, error) {
+	cfg, err := WSClientConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.Build()
+}
+
+// URL returns the websocket URL of the server: wss:// if any TLS file is
+// configured, ws:// otherwise.
+func (cfg WSClientConfig) URL() string {
+	scheme := "ws"
+	if cfg.TLS != (TLSFilesConfig{}) {
+		scheme = "wss"
+	}
+
+	host := cfg.Server.Host
+	if cfg.Server.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+	}
+
+	return scheme + "://" + host
+}
+
+// Build returns a WSClient configured by cfg, then by opts, which may set
+// what the configuration cannot express. It reads the TLS files, and fails
+// if they cannot be loaded, if no server host is configured, or if the
+// metrics provider is not registered.
+func (cfg WSClientConfig) Build(opts ...ClientOption) (*WSClient, error) {
+	factory, err := cfg.factory()
+	if err != nil {
+		return nil, err
+	}
+
+	settings, err := cfg.settings(factory.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	configured := []ClientOption{
+		WithConnectionFactory(factory),
+		WithConnectionOptions(ws.ConnectionOptions{
+			PingInterval: cfg.Connection.PingInterval.Duration,
+			WriteTimeout: cfg.Connection.WriteTimeout.Duration,
+		}),
+		WithMaxMessageBytes(settings.maxMessageBytes),
+		WithRetryPolicy(settings.retryPolicy),
+		WithRateLimit(settings.rateLimit),
+		WithTagPrefix(settings.tagPrefix),
+	}
+
+	if settings.metrics != nil {
+		configured = append(configured, WithMetrics(settings.metrics))
+	}
+
+	if settings.logger != nil {
+		configured = append(configured, WithLogger(settings.logger))
+	}
+
+	c := NewWSClient(append(configured, opts...)...)
+	c.config = &cfg
+
+	return c, nil
+}
+
+// factory returns the connection factory for the server, TLS and auth
+// sections.
+func (cfg WSClientConfig) factory() (*DefaultWSConnectionFactory, error) {
+	if cfg.Server.Host == "" {
+		return nil, errors.New("no server host")
+	}
+
+	if cfg.Server.Port < 0 || cfg.Server.Port > 65535 {
+		return nil, fmt.Errorf("server port %d is out of range", cfg.Server.Port)
+	}
+
+	factory := &DefaultWSConnectionFactory{URL: cfg.URL()}
+
+	if cfg.Auth.IAMToken != "" {
+		factory.AuthInfo = NewIAMAuthInfo(cfg.Auth.IAMToken)
+	}
+
+	if cfg.TLS != (TLSFilesConfig{}) {
+		tlsConfig, cert, err := cfg.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+
+		factory.TLSConfig = tlsConfig
+
+		if cert != nil {
+			factory.ReloadCertificate(*cert)
+		}
+	}
+
+	return factory, nil
+}
+
+// settings returns the settings that can change without reconnecting. The
+// logger and metrics are nil if cfg does not choose them.
+func (cfg WSClientConfig) settings(server string) (*reloadedSettings, error) {
+	settings := &reloadedSettings{
+		maxMessageBytes: cfg.Connection.MaxMessageBytes,
+		tagPrefix:       cfg.TagPrefix,
+	}
+
+	if cfg.Retry != (RetryConfig{}) {
+		settings.retryPolicy = &DefaultExponentialBackoff{
+			BaseDelay:  cfg.Retry.BaseDelay.Duration,
+			Multiplier: cfg.Retry.Multiplier,
+			MaxDelay:   cfg.Retry.MaxDelay.Duration,
+			Jitter:     cfg.Retry.Jitter,
+			Attempts:   cfg.Retry.Attempts,
+		}
+	}
+
+	if cfg.RateLimit.PerSecond > 0 {
+		settings.rateLimit = newRateLimiter(cfg.RateLimit.PerSecond, cfg.RateLimit.Burst)
+	}
+
+	if cfg.Metrics.Provider != "" {
+		provider, ok := metricsProvider(cfg.Metrics.Provider)
+		if !ok {
+			return nil, fmt.Errorf("metrics provider %q is not registered", cfg.Metrics.Provider)
+		}
+
+		metrics, err := provider(cfg.Metrics, server)
+		if err != nil {
+			return nil, fmt.Errorf("metrics provider %q: %w", cfg.Metrics.Provider, err)
+		}
+
+		settings.metrics = metrics
+	}
+
+	switch cfg.Log.Level {
+	case "":
+	case LogLevelDebug:
+		logger := NewStandardLogger(nil)
+		logger.Debug = true
+		settings.logger = logger
+	case LogLevelInfo:
+		settings.logger = NewStandardLogger(nil)
+	case LogLevelNone:
+		settings.logger = NoopLogger{}
+	default:
+		return nil, fmt.Errorf("unknown log level %q", cfg.Log.Level)
+	}
+
+	return settings, nil
+}
+
+func (tf TLSFilesConfig) load() (*tls.Config, *tls.Certificate, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if tf.CAFile != "" {
+		data, err := os.ReadFile(tf.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read CA file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("no certificates in CA file %s", tf.CAFile)
+		}
+	}
+
+	if tf.CertFile == "" && tf.KeyFile == "" {
+		return tlsConfig, nil, nil
+	}
+
+	if tf.CertFile == "" || tf.KeyFile == "" {
+		return nil, nil, errors.New("TLS certificate and key files must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(tf.CertFile, tf.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load client certificate: %w", err)
+	}
+
+	return tlsConfig, &cert, nil
+}
@@ -0,0 +1,287 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WSClientConfigFromEnv", func() {
+	setenv := func(name, value string) {
+		Expect(os.Setenv(name, value)).To(Succeed())
+		DeferCleanup(os.Unsetenv, name)
+	}
+
+	It("reads the FLUENT_ variables", func() {
+		setenv(EnvServerHost, "fluentd.example.com")
+		setenv(EnvServerPort, "8443")
+		setenv(EnvIAMToken, "token")
+		setenv(EnvTLSCert, "cert.pem")
+		setenv(EnvTLSKey, "key.pem")
+		setenv(EnvTLSCA, "ca.pem")
+		setenv(EnvPingInterval, "30s")
+		setenv(EnvWriteTimeout, "5s")
+		setenv(EnvMaxMessageBytes, "1048576")
+		setenv(EnvTagPrefix, "payments.")
+
+		cfg, err := WSClientConfigFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(Equal(WSClientConfig{
+			Server: ServerConfig{Host: "fluentd.example.com", Port: 8443},
+			Auth:   AuthConfig{IAMToken: "token"},
+			TLS: TLSFilesConfig{
+				CertFile: "cert.pem",
+				KeyFile:  "key.pem",
+				CAFile:   "ca.pem",
+			},
+			Connection: ConnectionConfig{
+				PingInterval:    Duration{30 * time.Second},
+				WriteTimeout:    Duration{5 * time.Second},
+				MaxMessageBytes: 1 << 20,
+			},
+			TagPrefix: "payments.",
+		}))
+		Expect(cfg.URL()).To(Equal("wss://fluentd.example.com:8443"))
+	})
+
+	It("rejects malformed values", func() {
+		setenv(EnvPingInterval, "often")
+
+		_, err := WSClientConfigFromEnv()
+		Expect(err).To(MatchError(ContainSubstring(EnvPingInterval)))
+	})
+
+	It("warns about unknown variables", func() {
+		var buf bytes.Buffer
+
+		log.SetOutput(&buf)
+		DeferCleanup(log.SetOutput, os.Stderr)
+
+		setenv("FLUENT_SERVER_HSOT", "oops")
+
+		_, err := WSClientConfigFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf.String()).To(ContainSubstring("WARN unknown environment variable FLUENT_SERVER_HSOT"))
+	})
+})
+
+var _ = Describe("NewWSClientFromEnv", func() {
+	setenv := func(name, value string) {
+		Expect(os.Setenv(name, value)).To(Succeed())
+		DeferCleanup(os.Unsetenv, name)
+	}
+
+	It("builds the client", func() {
+		setenv(EnvServerHost, "127.0.0.1")
+		setenv(EnvServerPort, "24224")
+		setenv(EnvIAMToken, "token")
+		setenv(EnvTLSCert, "clientfakes/cert.pem")
+		setenv(EnvTLSKey, "clientfakes/key.pem")
+		setenv(EnvTLSCA, "clientfakes/cert.pem")
+		setenv(EnvWriteTimeout, "5s")
+		setenv(EnvMaxMessageBytes, "1024")
+
+		c, err := NewWSClientFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+
+		factory := c.ConnectionFactory.(*DefaultWSConnectionFactory)
+		Expect(factory.URL).To(Equal("wss://127.0.0.1:24224"))
+		Expect(factory.AuthInfo.IAMToken()).To(Equal("token"))
+		Expect(factory.TLSConfig.RootCAs).ToNot(BeNil())
+		Expect(factory.ClientCertificate()).ToNot(BeNil())
+		Expect(c.ConnectionOptions.WriteTimeout).To(Equal(5 * time.Second))
+		Expect(c.MaxMessageBytes).To(BeEquivalentTo(1024))
+	})
+
+	It("connects with ws:// without TLS files", func() {
+		setenv(EnvServerHost, "127.0.0.1")
+
+		c, err := NewWSClientFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.ConnectionFactory.(*DefaultWSConnectionFactory).URL).To(Equal("ws://127.0.0.1"))
+	})
+
+	It("needs a server host", func() {
+		_, err := NewWSClientFromEnv()
+		Expect(err).To(MatchError("no server host"))
+	})
+
+	It("needs a key with the certificate", func() {
+		setenv(EnvServerHost, "127.0.0.1")
+		setenv(EnvTLSCert, "clientfakes/cert.pem")
+
+		_, err := NewWSClientFromEnv()
+		Expect(err).To(MatchError(ContainSubstring("must be set together")))
+	})
+})
+
+var _ = Describe("LoadConfig", func() {
+	write := func(name, content string) string {
+		path := filepath.Join(GinkgoT().TempDir(), name)
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+
+		return path
+	}
+
+	expected := WSClientConfig{
+		Server:     ServerConfig{Host: "fluentd.example.com", Port: 8443},
+		Auth:       AuthConfig{IAMToken: "token"},
+		TLS:        TLSFilesConfig{CAFile: "ca.pem"},
+		Connection: ConnectionConfig{PingInterval: Duration{30 * time.Second}, MaxMessageBytes: 1024},
+		Retry:      RetryConfig{BaseDelay: Duration{100 * time.Millisecond}, Multiplier: 2, Attempts: 5},
+		Metrics:    MetricsConfig{Provider: "test", ClientName: "billing"},
+	}
+
+	It("reads YAML", func() {
+		cfg, err := LoadConfig(write("client.yaml", `
+server:
+  host: fluentd.example.com
+  port: 8443
+auth:
+  iam_token: token
+tls:
+  ca_file: ca.pem
+connection:
+  ping_interval: 30s
+  max_message_bytes: 1024
+retry:
+  base_delay: 100ms
+  multiplier: 2
+  attempts: 5
+metrics:
+  provider: test
+  client_name: billing
+`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(Equal(expected))
+	})
+
+	It("reads JSON", func() {
+		cfg, err := LoadConfig(write("client.json", `{
+  "server": {"host": "fluentd.example.com", "port": 8443},
+  "auth": {"iam_token": "token"},
+  "tls": {"ca_file": "ca.pem"},
+  "connection": {"ping_interval": "30s", "max_message_bytes": 1024},
+  "retry": {"base_delay": "100ms", "multiplier": 2, "attempts": 5},
+  "metrics": {"provider": "test", "client_name": "billing"}
+}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cfg).To(Equal(expected))
+	})
+
+	It("writes durations back as strings", func() {
+		data, err := json.Marshal(expected.Connection)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"ping_interval":"30s"`))
+	})
+
+	DescribeTable("rejects bad files",
+		func(name, content, message string) {
+			_, err := LoadConfig(write(name, content))
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("an unknown YAML field", "client.yml", "server:\n  hots: x\n", "field hots not found"),
+		Entry("an unknown JSON field", "client.json", `{"server": {"hots": "x"}}`, `unknown field "hots"`),
+		Entry("a bad duration", "client.yaml", "connection:\n  ping_interval: often\n", "invalid duration"),
+		Entry("a numeric JSON duration", "client.json", `{"connection": {"ping_interval": 30}}`, "duration must be a string"),
+		Entry("an unknown extension", "client.toml", "", `unknown extension ".toml"`),
+	)
+})
+
+var _ = Describe("WSClientConfig Build", func() {
+	var cfg WSClientConfig
+
+	BeforeEach(func() {
+		cfg = WSClientConfig{Server: ServerConfig{Host: "127.0.0.1", Port: 24224}}
+	})
+
+	It("configures retries", func() {
+		cfg.Retry = RetryConfig{BaseDelay: Duration{time.Second}, Attempts: 3}
+
+		c, err := cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.RetryPolicy).To(Equal(&DefaultExponentialBackoff{BaseDelay: time.Second, Attempts: 3}))
+	})
+
+	It("sets the tag prefix", func() {
+		cfg.TagPrefix = "payments."
+
+		c, err := cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.TagPrefix).To(Equal("payments."))
+	})
+
+	It("makes no retries by default", func() {
+		c, err := cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.RetryPolicy).To(BeNil())
+	})
+
+	It("uses the registered metrics provider", func() {
+		collector := &clientfakes.FakeMetricsCollector{}
+
+		var gotCfg MetricsConfig
+		var gotServer string
+
+		RegisterMetricsProvider("test", func(cfg MetricsConfig, server string) (MetricsCollector, error) {
+			gotCfg, gotServer = cfg, server
+			return collector, nil
+		})
+
+		cfg.Metrics = MetricsConfig{Provider: "test", ClientName: "billing"}
+
+		c, err := cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Metrics).To(BeIdenticalTo(collector))
+		Expect(gotCfg).To(Equal(cfg.Metrics))
+		Expect(gotServer).To(Equal("ws://127.0.0.1:24224"))
+	})
+
+	It("fails for an unregistered metrics provider", func() {
+		cfg.Metrics.Provider = "missing"
+
+		_, err := cfg.Build()
+		Expect(err).To(MatchError(`metrics provider "missing" is not registered`))
+	})
+
+	It("applies options after the configuration", func() {
+		policy := &DefaultExponentialBackoff{}
+		cfg.Retry.Attempts = 3
+
+		c, err := cfg.Build(WithRetryPolicy(policy))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.RetryPolicy).To(BeIdenticalTo(policy))
+	})
+})
@@ -39,6 +39,9 @@ type ConnFactory struct {
 	Address   string
 	TLSConfig *tls.Config
 	Timeout   time.Duration
+	// KeepAlive is the TCP keep-alive period. Zero enables keep-alives
+	// with the system default; a negative value disables them.
+	KeepAlive time.Duration
 }
 
 func (f *ConnFactory) New() (net.Conn, error) {
@@ -46,7 +49,10 @@ func (f *ConnFactory) New() (net.Conn, error) {
 		f.Network = "tcp"
 	}
 
-	dialer := &net.Dialer{Timeout: f.Timeout}
+	dialer := &net.Dialer{
+		Timeout:   f.Timeout,
+		KeepAlive: f.KeepAlive,
+	}
 
 	if f.TLSConfig != nil {
 		return tls.DialWithDialer(dialer, f.Network, f.Address, f.TLSConfig)
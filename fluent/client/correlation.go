@@ -0,0 +1,147 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// CorrelationIDGenerator makes the chunk IDs that SendMessageAck gives
+// messages without one, which the peer echoes in its ack. IDs must be
+// unique among the messages awaiting an ack. Next must be safe for
+// concurrent use.
+type CorrelationIDGenerator interface {
+	Next() string
+}
+
+// ChunkIDGenerator is another name for CorrelationIDGenerator.
+type ChunkIDGenerator = CorrelationIDGenerator
+
+// UUIDChunkIDGenerator is another name for UUIDGenerator.
+type UUIDChunkIDGenerator = UUIDGenerator
+
+// UUIDGenerator makes random (version 4) UUIDs from crypto/rand, such as
+// "1b4e28ba-2fa1-41d2-883f-0016d3cca427". Next panics if crypto/rand
+// fails, as that leaves no safe way to make an ID.
+type UUIDGenerator struct{}
+
+func (UUIDGenerator) Next() string {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("read random bytes for uuid: " + err.Error())
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	var s [36]byte
+
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+
+	return string(s[:])
+}
+
+// SequentialChunkIDGenerator makes the IDs 1, 2 and so on, in hexadecimal,
+// which are easy to follow when debugging but unique only within the
+// generator. Its zero value is ready to use.
+type SequentialChunkIDGenerator struct {
+	n uint64
+}
+
+func (g *SequentialChunkIDGenerator) Next() string {
+	return strconv.FormatUint(atomic.AddUint64(&g.n, 1), 16)
+}
+
+// prefixedGenerator is the ChunkIDGenerator of PrefixedChunkIDGenerator.
+type prefixedGenerator struct {
+	prefix string
+	inner  ChunkIDGenerator
+}
+
+func (g prefixedGenerator) Next() string {
+	return g.prefix + g.inner.Next()
+}
+
+// PrefixedChunkIDGenerator returns a ChunkIDGenerator that prepends prefix
+// to the IDs of inner, e.g. so that the IDs of a SequentialChunkIDGenerator
+// name the host that made them.
+func PrefixedChunkIDGenerator(prefix string, inner ChunkIDGenerator) ChunkIDGenerator {
+	return prefixedGenerator{prefix: prefix, inner: inner}
+}
+
+// injectCorrelationID sets the chunk option of the protocol's messages to
+// an ID from gen, unless one is already set. Other encoders are left to
+// their own Chunk method.
+func injectCorrelationID(e protocol.ChunkEncoder, gen CorrelationIDGenerator) {
+	if opts := MessageOptions(e); opts != nil && opts.Chunk == "" {
+		opts.Chunk = gen.Next()
+	}
+}
+
+// MessageOptions returns the options of the protocol's messages, creating
+// them if they are unset, or nil for other encoders and for a
+// CompressedPackedForwardMessage without its PackedForwardMessage.
+func MessageOptions(e interface{}) *protocol.MessageOptions {
+	var opts **protocol.MessageOptions
+
+	switch msg := e.(type) {
+	case *protocol.Message:
+		opts = &msg.Options
+	case *protocol.MessageExt:
+		opts = &msg.Options
+	case *protocol.ForwardMessage:
+		opts = &msg.Options
+	case *protocol.PackedForwardMessage:
+		opts = &msg.Options
+	case *protocol.CompressedPackedForwardMessage:
+		if msg.PackedForwardMessage == nil {
+			return nil
+		}
+
+		opts = &msg.Options
+	default:
+		return nil
+	}
+
+	if *opts == nil {
+		*opts = &protocol.MessageOptions{}
+	}
+
+	return *opts
+}
@@ -0,0 +1,118 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sequenceGenerator makes the IDs id-1, id-2 and so on.
+type sequenceGenerator struct {
+	n int64
+}
+
+func (g *sequenceGenerator) Next() string {
+	return fmt.Sprintf("id-%d", atomic.AddInt64(&g.n, 1))
+}
+
+var _ = Describe("UUIDGenerator", func() {
+	It("makes version 4 UUIDs", func() {
+		Expect(UUIDGenerator{}.Next()).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+	})
+
+	It("does not repeat itself", func() {
+		seen := map[string]bool{}
+
+		for i := 0; i < 10000; i++ {
+			id := UUIDGenerator{}.Next()
+			Expect(seen).ToNot(HaveKey(id))
+			seen[id] = true
+		}
+	})
+})
+
+var _ = Describe("SequentialChunkIDGenerator", func() {
+	It("counts in hexadecimal from 1", func() {
+		var gen SequentialChunkIDGenerator
+
+		var ids []string
+		for i := 0; i < 16; i++ {
+			ids = append(ids, gen.Next())
+		}
+
+		Expect(ids[0]).To(Equal("1"))
+		Expect(ids[9]).To(Equal("a"))
+		Expect(ids[15]).To(Equal("10"))
+	})
+
+	It("is safe for concurrent use", func() {
+		var (
+			gen  SequentialChunkIDGenerator
+			wg   sync.WaitGroup
+			lock sync.Mutex
+			seen = map[string]bool{}
+		)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					id := gen.Next()
+
+					lock.Lock()
+					seen[id] = true
+					lock.Unlock()
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(seen).To(HaveLen(1000))
+	})
+})
+
+var _ = Describe("PrefixedChunkIDGenerator", func() {
+	It("prefixes the IDs of the inner generator", func() {
+		gen := PrefixedChunkIDGenerator("web-1-", &SequentialChunkIDGenerator{})
+
+		Expect(gen.Next()).To(Equal("web-1-1"))
+		Expect(gen.Next()).To(Equal("web-1-2"))
+	})
+
+	It("can wrap UUIDs", func() {
+		var _ ChunkIDGenerator = UUIDChunkIDGenerator{}
+
+		Expect(PrefixedChunkIDGenerator("a.", UUIDChunkIDGenerator{}).Next()).To(MatchRegexp(`^a\.[0-9a-f-]{36}$`))
+	})
+})
@@ -0,0 +1,202 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	DefaultDedupWindow = 5 * time.Minute
+	DefaultDedupSize   = 10000
+)
+
+// DedupStats counts the sends a DeduplicatingClient has checked.
+type DedupStats struct {
+	// Hits is the number of sends skipped as duplicates.
+	Hits uint64
+	// Misses is the number of sends passed on.
+	Misses uint64
+}
+
+type dedupEntry struct {
+	hash [16]byte
+	at   time.Time
+}
+
+// DeduplicatingClient wraps a MessageSender and skips sending any message
+// whose encoded bytes are the same as one it has sent within Window, e.g.
+// so that an application which retries on error, not knowing whether the
+// first attempt got through, does not deliver a message twice. Messages
+// are compared by their 128-bit FNV-1a hash, and the most recent Size
+// hashes are kept.
+//
+// Only sends that succeed are remembered, so a send that failed is made
+// again when retried. Note that a message carrying a generated chunk ID
+// is different each time it is built; retry with the same message.
+type DeduplicatingClient struct {
+	// Sender sends the messages that are not duplicates.
+	Sender MessageSender
+	// Window is how long a message is remembered. If zero,
+	// DefaultDedupWindow is used.
+	Window time.Duration
+	// Size is the number of messages remembered. If zero,
+	// DefaultDedupSize is used.
+	Size   int
+	recent map[[16]byte]*list.Element
+	order  *list.List
+	stats  DedupStats
+	lock   sync.Mutex
+}
+
+func NewDeduplicatingClient(sender MessageSender) *DeduplicatingClient {
+	return &DeduplicatingClient{
+		Sender: sender,
+		Window: DefaultDedupWindow,
+		Size:   DefaultDedupSize,
+	}
+}
+
+// DedupStats returns the number of duplicates skipped and of messages
+// passed on.
+func (c *DeduplicatingClient) DedupStats() DedupStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats
+}
+
+func (c *DeduplicatingClient) Send(e protocol.ChunkEncoder) error {
+	var buf bytes.Buffer
+
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+
+	return c.send(buf.Bytes(), func() error { return c.Sender.Send(e) })
+}
+
+func (c *DeduplicatingClient) SendRaw(raw []byte) error {
+	return c.send(raw, func() error { return c.Sender.SendRaw(raw) })
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *DeduplicatingClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+func (c *DeduplicatingClient) send(encoded []byte, send func() error) error {
+	h := fnv.New128a()
+	_, _ = h.Write(encoded)
+
+	var hash [16]byte
+	h.Sum(hash[:0])
+
+	if c.seen(hash) {
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+
+	c.remember(hash)
+
+	return nil
+}
+
+func (c *DeduplicatingClient) window() time.Duration {
+	if c.Window <= 0 {
+		return DefaultDedupWindow
+	}
+
+	return c.Window
+}
+
+// seen reports whether hash was sent within the window, counting the
+// answer in the stats.
+func (c *DeduplicatingClient) seen(hash [16]byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.recent[hash]; ok {
+		if time.Since(el.Value.(*dedupEntry).at) < c.window() {
+			c.stats.Hits++
+			return true
+		}
+
+		c.order.Remove(el)
+		delete(c.recent, hash)
+	}
+
+	c.stats.Misses++
+
+	return false
+}
+
+func (c *DeduplicatingClient) remember(hash [16]byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.recent == nil {
+		c.recent = make(map[[16]byte]*list.Element)
+		c.order = list.New()
+	}
+
+	now := time.Now()
+
+	if el, ok := c.recent[hash]; ok {
+		// sent concurrently with itself
+		el.Value.(*dedupEntry).at = now
+		c.order.MoveToFront(el)
+
+		return
+	}
+
+	c.recent[hash] = c.order.PushFront(&dedupEntry{hash: hash, at: now})
+
+	size := c.Size
+	if size <= 0 {
+		size = DefaultDedupSize
+	}
+
+	// drop the oldest beyond the size, and any that have expired
+	for el := c.order.Back(); el != nil; el = c.order.Back() {
+		entry := el.Value.(*dedupEntry)
+		if c.order.Len() <= size && now.Sub(entry.at) < c.window() {
+			break
+		}
+
+		c.order.Remove(el)
+		delete(c.recent, entry.hash)
+	}
+}
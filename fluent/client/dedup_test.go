@@ -0,0 +1,117 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DeduplicatingClient", func() {
+	var (
+		sender *clientfakes.FakeMessageSender
+		dc     *DeduplicatingClient
+		msg    *protocol.Message
+	)
+
+	BeforeEach(func() {
+		sender = &clientfakes.FakeMessageSender{}
+		dc = NewDeduplicatingClient(sender)
+
+		msg = protocol.NewMessage("foo", "oi")
+		msg.Timestamp = 1257894000
+	})
+
+	It("is a MessageSender", func() {
+		var s MessageSender = dc
+		Expect(s).ToNot(BeNil())
+	})
+
+	It("skips a message it has already sent", func() {
+		Expect(dc.Send(msg)).To(Succeed())
+		Expect(dc.Send(msg)).To(Succeed())
+
+		Expect(sender.SendCallCount()).To(Equal(1))
+		Expect(dc.DedupStats()).To(Equal(DedupStats{Hits: 1, Misses: 1}))
+	})
+
+	It("sends messages that differ", func() {
+		Expect(dc.Send(msg)).To(Succeed())
+
+		other := *msg
+		other.Record = "ola"
+		Expect(dc.Send(&other)).To(Succeed())
+
+		Expect(sender.SendCallCount()).To(Equal(2))
+		Expect(dc.DedupStats()).To(Equal(DedupStats{Misses: 2}))
+	})
+
+	It("deduplicates raw messages", func() {
+		Expect(dc.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(dc.SendRaw([]byte("oi"))).To(Succeed())
+
+		Expect(sender.SendRawCallCount()).To(Equal(1))
+	})
+
+	It("sends again a message that failed", func() {
+		sender.SendReturnsOnCall(0, errors.New("nope"))
+
+		Expect(dc.Send(msg)).To(MatchError("nope"))
+		Expect(dc.Send(msg)).To(Succeed())
+		Expect(sender.SendCallCount()).To(Equal(2))
+	})
+
+	It("forgets messages after the window", func() {
+		dc.Window = 20 * time.Millisecond
+
+		Expect(dc.Send(msg)).To(Succeed())
+		time.Sleep(30 * time.Millisecond)
+		Expect(dc.Send(msg)).To(Succeed())
+
+		Expect(sender.SendCallCount()).To(Equal(2))
+	})
+
+	It("remembers only the most recent Size messages", func() {
+		dc.Size = 3
+
+		for i := 0; i < 4; i++ {
+			Expect(dc.SendRaw([]byte(fmt.Sprint(i)))).To(Succeed())
+		}
+
+		// 0 was evicted, 3 was not
+		Expect(dc.SendRaw([]byte("0"))).To(Succeed())
+		Expect(dc.SendRaw([]byte("3"))).To(Succeed())
+
+		Expect(sender.SendRawCallCount()).To(Equal(5))
+		Expect(dc.DedupStats().Hits).To(Equal(uint64(1)))
+	})
+})
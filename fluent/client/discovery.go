@@ -0,0 +1,166 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DiscoveryProvider finds the servers to send to, e.g. from a service
+// registry, and reports when they change.
+type DiscoveryProvider interface {
+	// Watch returns a channel that receives the current servers, and again
+	// each time they change, until ctx is done, when it is closed.
+	Watch(ctx context.Context) (<-chan []ServerAddress, error)
+}
+
+// SetServers replaces the client's servers. Servers that are not among
+// them any more are disconnected. If the active server is among them, it
+// stays active; otherwise, if it was connected, the new servers are tried
+// in the order given by the Policy, as with Connect. An empty list is
+// rejected with ErrNoServers, and the servers are kept.
+func (c *FailoverClient) SetServers(servers ...FailoverServer) error {
+	if len(servers) == 0 {
+		return ErrNoServers
+	}
+
+	c.lock.Lock()
+
+	var (
+		active    *WSClient
+		connected bool
+		kept      bool
+	)
+
+	if c.active < len(c.servers) {
+		active = c.servers[c.active].Client
+		connected = active.Session() != nil
+	}
+
+	previous := c.servers
+	c.servers = servers
+	c.active = 0
+	c.generation++
+	generation := c.generation
+
+	for i, s := range servers {
+		if s.Client == active {
+			c.active = i
+			kept = true
+
+			break
+		}
+	}
+
+	c.lock.Unlock()
+
+	// connections are closed and dialed without the lock, so that sends
+	// do not wait for them
+	listed := map[*WSClient]bool{}
+	for _, s := range servers {
+		listed[s.Client] = true
+	}
+
+	for _, s := range previous {
+		if !listed[s.Client] {
+			_ = s.Client.Disconnect()
+		}
+	}
+
+	if kept || !connected {
+		return nil
+	}
+
+	return c.connectFirst(servers, generation)
+}
+
+// Discover keeps the client's servers up to date with those found by
+// provider until ctx is done, calling newClient for each server that is
+// found for the first time. It waits for the first list of servers that
+// is not empty, then follows changes in the background; errors connecting
+// to a new list, and ErrNoServers for an empty one, which leaves the
+// servers as they were, are passed to onError, if it is not nil.
+func (c *FailoverClient) Discover(
+	ctx context.Context, provider DiscoveryProvider, newClient func(ServerAddress) *WSClient, onError func(error),
+) error {
+	updates, err := provider.Watch(ctx)
+	if err != nil {
+		return fmt.Errorf("discover servers: %w", err)
+	}
+
+	var addresses []ServerAddress
+
+	for len(addresses) == 0 {
+		select {
+		case list, ok := <-updates:
+			if !ok {
+				return errors.New("discover servers: provider stopped")
+			}
+
+			addresses = list
+		case <-ctx.Done():
+			return fmt.Errorf("discover servers: %w", ctx.Err())
+		}
+	}
+
+	if err = c.SetServers(c.serversFor(addresses, newClient)...); err != nil {
+		return err
+	}
+
+	go func() {
+		for list := range updates {
+			if err := c.SetServers(c.serversFor(list, newClient)...); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// serversFor returns a server for each address, reusing the clients of
+// the current servers.
+func (c *FailoverClient) serversFor(addresses []ServerAddress, newClient func(ServerAddress) *WSClient) []FailoverServer {
+	c.lock.RLock()
+	current := map[ServerAddress]*WSClient{}
+	for _, s := range c.servers {
+		current[s.Address] = s.Client
+	}
+	c.lock.RUnlock()
+
+	servers := make([]FailoverServer, 0, len(addresses))
+	for _, address := range addresses {
+		wc, ok := current[address]
+		if !ok {
+			wc = newClient(address)
+		}
+
+		servers = append(servers, FailoverServer{Address: address, Client: wc})
+	}
+
+	return servers
+}
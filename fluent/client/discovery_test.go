@@ -0,0 +1,183 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// chanProvider is a DiscoveryProvider whose updates are sent by the test.
+type chanProvider chan []ServerAddress
+
+func (p chanProvider) Watch(_ context.Context) (<-chan []ServerAddress, error) {
+	return p, nil
+}
+
+var _ = Describe("FailoverClient discovery", func() {
+	var (
+		lock    sync.Mutex
+		conns   map[ServerAddress]*wsfakes.FakeConnection
+		clients map[ServerAddress]*WSClient
+		fc      *FailoverClient
+	)
+
+	newClient := func(address ServerAddress) *WSClient {
+		lock.Lock()
+		defer lock.Unlock()
+
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn := &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		conns[address] = conn
+		clients[address] = NewWS(WSConnectionOptions{Factory: factory})
+
+		return clients[address]
+	}
+
+	BeforeEach(func() {
+		conns = map[ServerAddress]*wsfakes.FakeConnection{}
+		clients = map[ServerAddress]*WSClient{}
+		fc = NewFailoverClient(nil)
+	})
+
+	AfterEach(func() {
+		Expect(fc.Disconnect()).To(Succeed())
+	})
+
+	Describe("SetServers", func() {
+		It("keeps the active server when it is still listed", func() {
+			a, b := newClient("wss://a"), newClient("wss://b")
+			Expect(fc.SetServers(FailoverServer{Address: "wss://a", Client: a})).To(Succeed())
+			Expect(fc.Connect()).To(Succeed())
+
+			Expect(fc.SetServers(
+				FailoverServer{Address: "wss://b", Client: b},
+				FailoverServer{Address: "wss://a", Client: a},
+			)).To(Succeed())
+
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://a")))
+			Expect(a.Session()).ToNot(BeNil())
+			Expect(b.Session()).To(BeNil())
+		})
+
+		It("disconnects a removed active server and connects another", func() {
+			a, b := newClient("wss://a"), newClient("wss://b")
+			Expect(fc.SetServers(FailoverServer{Address: "wss://a", Client: a})).To(Succeed())
+			Expect(fc.Connect()).To(Succeed())
+
+			Expect(fc.SetServers(FailoverServer{Address: "wss://b", Client: b})).To(Succeed())
+
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://b")))
+			Expect(a.Session()).To(BeNil())
+			Expect(b.Session()).ToNot(BeNil())
+		})
+
+		It("keeps the servers when the list is empty", func() {
+			a := newClient("wss://a")
+			Expect(fc.SetServers(FailoverServer{Address: "wss://a", Client: a})).To(Succeed())
+			Expect(fc.Connect()).To(Succeed())
+
+			Expect(fc.SetServers()).To(MatchError(ErrNoServers))
+
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://a")))
+			Expect(a.Session()).ToNot(BeNil())
+			Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+		})
+	})
+
+	Describe("Discover", func() {
+		It("follows the servers found by the provider", func() {
+			updates := make(chanProvider, 1)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			updates <- []ServerAddress{"wss://a", "wss://b"}
+			Expect(fc.Discover(ctx, updates, newClient, nil)).To(Succeed())
+			Expect(fc.Connect()).To(Succeed())
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://a")))
+
+			updates <- []ServerAddress{"wss://c", "wss://a"}
+			Eventually(func() int {
+				lock.Lock()
+				defer lock.Unlock()
+
+				return len(clients)
+			}).Should(Equal(3))
+			Consistently(fc.ActiveServer).Should(Equal(ServerAddress("wss://a")))
+
+			updates <- []ServerAddress{"wss://c"}
+			Eventually(fc.ActiveServer).Should(Equal(ServerAddress("wss://c")))
+
+			Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+
+			lock.Lock()
+			defer lock.Unlock()
+			Expect(conns["wss://c"].WriteCallCount()).To(Equal(1))
+			close(updates)
+		})
+
+		It("waits for servers and keeps them when none are found", func() {
+			updates := make(chanProvider, 1)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errs := make(chan error, 1)
+			discovered := make(chan error, 1)
+			go func() {
+				discovered <- fc.Discover(ctx, updates, newClient, func(err error) { errs <- err })
+			}()
+
+			updates <- nil
+			Consistently(discovered).ShouldNot(Receive())
+
+			updates <- []ServerAddress{"wss://a"}
+			Eventually(discovered).Should(Receive(BeNil()))
+			Expect(fc.Connect()).To(Succeed())
+
+			updates <- nil
+			Eventually(errs).Should(Receive(MatchError(ErrNoServers)))
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://a")))
+			Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+			close(updates)
+		})
+
+		It("fails when the context is done before any servers are found", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(fc.Discover(ctx, make(chanProvider), newClient, nil)).To(MatchError(context.Canceled))
+		})
+	})
+})
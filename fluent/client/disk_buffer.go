@@ -0,0 +1,362 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	// DefaultCompactAfterBytes is the default CompactAfterBytes of a
+	// DiskBuffer.
+	DefaultCompactAfterBytes = 16 << 20
+
+	// Each record in the log is a type byte and a big-endian uint32
+	// payload length, followed by the payload. Both payloads start with
+	// the big-endian uint64 sequence number of the message.
+	recordMessage byte = 1
+	recordSent    byte = 2
+
+	recordHeaderSize = 5
+	seqSize          = 8
+)
+
+// DiskBuffer wraps a MessageSender and writes every message to an
+// append-only log file before sending it, so that messages which have
+// not been sent when the process stops are sent by the next Open.
+//
+// A message is marked sent once the wrapped sender returns without error;
+// messages that fail stay in the log until the next Open. Delivery is at
+// least once: a message may be sent again if the process stops between
+// sending it and marking it. DiskBuffer is safe for concurrent use, but
+// only one process may use a log file at a time.
+type DiskBuffer struct {
+	// Path is the log file. It is created by Open if it does not exist.
+	Path string
+	// Sender sends the messages.
+	Sender MessageSender
+	// CompactAfterBytes is how many bytes may be appended to the log before
+	// it is rewritten without the messages that have been sent.
+	CompactAfterBytes int64
+
+	file    *os.File
+	next    uint64
+	written int64
+	lock    sync.Mutex
+}
+
+// NewDiskBuffer returns a DiskBuffer that logs messages to path and sends
+// them with sender. Call Open before sending.
+func NewDiskBuffer(path string, sender MessageSender) *DiskBuffer {
+	return &DiskBuffer{
+		Path:              path,
+		Sender:            sender,
+		CompactAfterBytes: DefaultCompactAfterBytes,
+	}
+}
+
+type logEntry struct {
+	seq  uint64
+	data []byte
+}
+
+// Open replays the messages left in the log by a previous process to
+// Sender, in the order they were logged, then opens the log for new
+// messages. If a replay fails, the messages not yet sent are kept for the
+// next Open and the error is returned.
+func (b *DiskBuffer) Open() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.file != nil {
+		return errors.New("disk buffer is already open")
+	}
+
+	entries, next, err := readLog(b.Path)
+	if err != nil {
+		return err
+	}
+
+	b.next = next
+
+	for i, entry := range entries {
+		if err = b.Sender.SendRaw(entry.data); err != nil {
+			if werr := b.rewrite(entries[i:]); werr != nil {
+				return werr
+			}
+
+			b.file.Close()
+			b.file = nil
+
+			return fmt.Errorf("replay disk buffer: %w", err)
+		}
+	}
+
+	return b.rewrite(nil)
+}
+
+// Close closes the log. Messages that have not been sent stay in it.
+func (b *DiskBuffer) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.file == nil {
+		return nil
+	}
+
+	err := b.file.Close()
+	b.file = nil
+
+	return err
+}
+
+// Send logs e, then sends it with Sender.Send.
+func (b *DiskBuffer) Send(e protocol.ChunkEncoder) error {
+	buf := getEncodeBuffer()
+	defer putEncodeBuffer(buf)
+
+	if err := msgp.Encode(buf, e); err != nil {
+		return err
+	}
+
+	return b.send(buf.Bytes(), func() error { return b.Sender.Send(e) })
+}
+
+// SendRaw logs raw, then sends it with Sender.SendRaw.
+func (b *DiskBuffer) SendRaw(raw []byte) error {
+	return b.send(raw, func() error { return b.Sender.SendRaw(raw) })
+}
+
+func (b *DiskBuffer) send(data []byte, send func() error) error {
+	seq, err := b.logMessage(data)
+	if err != nil {
+		return err
+	}
+
+	if err = send(); err != nil {
+		return err
+	}
+
+	return b.logSent(seq)
+}
+
+func (b *DiskBuffer) logMessage(data []byte) (uint64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.file == nil {
+		return 0, ErrDiskBufferClosed
+	}
+
+	seq := b.next
+	if err := b.append(recordMessage, seq, data); err != nil {
+		return 0, err
+	}
+
+	// the message must be on disk before it is sent, or a crash could
+	// lose it after the caller considered it buffered
+	if err := b.file.Sync(); err != nil {
+		return 0, err
+	}
+
+	b.next++
+
+	return seq, nil
+}
+
+func (b *DiskBuffer) logSent(seq uint64) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.file == nil {
+		return ErrDiskBufferClosed
+	}
+
+	// not synced: losing the record only means sending the message again
+	if err := b.append(recordSent, seq, nil); err != nil {
+		return err
+	}
+
+	if b.CompactAfterBytes > 0 && b.written >= b.CompactAfterBytes {
+		entries, _, err := readLog(b.Path)
+		if err != nil {
+			return err
+		}
+
+		return b.rewrite(entries)
+	}
+
+	return nil
+}
+
+// append writes a record to the log. The caller must hold the lock.
+func (b *DiskBuffer) append(kind byte, seq uint64, data []byte) error {
+	n, err := writeRecord(b.file, kind, seq, data)
+	b.written += int64(n)
+
+	return err
+}
+
+// rewrite replaces the log with one holding only entries and opens it for
+// appending. The caller must hold the lock.
+func (b *DiskBuffer) rewrite(entries []logEntry) error {
+	tmp := b.Path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		if _, err = writeRecord(w, recordMessage, entry.seq, entry.data); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, b.Path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if b.file != nil {
+		b.file.Close()
+	}
+
+	b.file, err = os.OpenFile(b.Path, os.O_APPEND|os.O_WRONLY, 0o600)
+	b.written = 0
+
+	return err
+}
+
+func writeRecord(w io.Writer, kind byte, seq uint64, data []byte) (int, error) {
+	var header [recordHeaderSize + seqSize]byte
+
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:recordHeaderSize], uint32(seqSize+len(data)))
+	binary.BigEndian.PutUint64(header[recordHeaderSize:], seq)
+
+	n, err := w.Write(header[:])
+	if err != nil {
+		return n, err
+	}
+
+	m, err := w.Write(data)
+
+	return n + m, err
+}
+
+// readLog returns the messages in the log at path that have not been
+// marked sent, and the sequence number to give the next message. A record
+// cut short by a crash ends the log.
+func readLog(path string) ([]logEntry, uint64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer f.Close()
+
+	var (
+		entries []logEntry
+		sent    = map[uint64]bool{}
+		next    uint64
+		r       = bufio.NewReader(f)
+		header  [recordHeaderSize]byte
+	)
+
+	for {
+		if _, err = io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+
+		size := binary.BigEndian.Uint32(header[1:])
+		if size < seqSize {
+			return nil, 0, fmt.Errorf("disk buffer %s: bad record size %d", path, size)
+		}
+
+		payload := make([]byte, size)
+		if _, err = io.ReadFull(r, payload); err != nil {
+			break
+		}
+
+		seq := binary.BigEndian.Uint64(payload)
+
+		switch header[0] {
+		case recordMessage:
+			entries = append(entries, logEntry{seq: seq, data: payload[seqSize:]})
+
+			if seq >= next {
+				next = seq + 1
+			}
+		case recordSent:
+			sent[seq] = true
+		default:
+			return nil, 0, fmt.Errorf("disk buffer %s: bad record type %d", path, header[0])
+		}
+	}
+
+	if err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, 0, err
+	}
+
+	unsent := entries[:0]
+	for _, entry := range entries {
+		if !sent[entry.seq] {
+			unsent = append(unsent, entry)
+		}
+	}
+
+	return unsent, next, nil
+}
@@ -0,0 +1,198 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiskBuffer", func() {
+	var (
+		path   string
+		sender *clientfakes.FakeMessageSender
+		db     *DiskBuffer
+	)
+
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "ffg")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+
+		path = filepath.Join(dir, "buffer.log")
+		sender = &clientfakes.FakeMessageSender{}
+		db = NewDiskBuffer(path, sender)
+	})
+
+	AfterEach(func() {
+		Expect(db.Close()).To(Succeed())
+	})
+
+	rawSent := func() []string {
+		var raws []string
+		for i := 0; i < sender.SendRawCallCount(); i++ {
+			raws = append(raws, string(sender.SendRawArgsForCall(i)))
+		}
+
+		return raws
+	}
+
+	It("rejects sends before Open", func() {
+		Expect(db.SendRaw([]byte("oi"))).To(MatchError(ErrDiskBufferClosed))
+	})
+
+	It("sends through the wrapped sender", func() {
+		Expect(db.Open()).To(Succeed())
+
+		msg := protocol.NewMessage("foo", nil)
+		Expect(db.Send(msg)).To(Succeed())
+		Expect(db.SendRaw([]byte("oi"))).To(Succeed())
+
+		Expect(sender.SendCallCount()).To(Equal(1))
+		Expect(sender.SendArgsForCall(0)).To(Equal(msg))
+		Expect(rawSent()).To(Equal([]string{"oi"}))
+	})
+
+	It("replays messages that were not sent on the next Open", func() {
+		Expect(db.Open()).To(Succeed())
+
+		sender.SendRawReturnsOnCall(1, errors.New("nope"))
+		sender.SendReturns(errors.New("nope"))
+
+		Expect(db.SendRaw([]byte("one"))).To(Succeed())
+		Expect(db.SendRaw([]byte("two"))).To(MatchError("nope"))
+		Expect(db.Send(protocol.NewMessage("foo", nil))).To(MatchError("nope"))
+		Expect(db.Close()).To(Succeed())
+
+		sender = &clientfakes.FakeMessageSender{}
+		db = NewDiskBuffer(path, sender)
+		Expect(db.Open()).To(Succeed())
+
+		Expect(sender.SendRawCallCount()).To(Equal(2))
+		Expect(string(sender.SendRawArgsForCall(0))).To(Equal("two"))
+
+		var replayed protocol.Message
+		_, err := replayed.UnmarshalMsg(sender.SendRawArgsForCall(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(replayed.Tag).To(Equal("foo"))
+	})
+
+	It("keeps the messages a failed replay did not send", func() {
+		Expect(db.Open()).To(Succeed())
+
+		sender.SendRawReturns(errors.New("nope"))
+		for _, raw := range []string{"one", "two", "three"} {
+			Expect(db.SendRaw([]byte(raw))).To(MatchError("nope"))
+		}
+		Expect(db.Close()).To(Succeed())
+
+		sender = &clientfakes.FakeMessageSender{}
+		sender.SendRawReturnsOnCall(1, errors.New("nope"))
+		db = NewDiskBuffer(path, sender)
+
+		Expect(db.Open()).To(MatchError("replay disk buffer: nope"))
+		Expect(db.SendRaw([]byte("four"))).To(MatchError(ErrDiskBufferClosed))
+		Expect(rawSent()).To(Equal([]string{"one", "two"}))
+
+		Expect(db.Open()).To(Succeed())
+		Expect(rawSent()).To(Equal([]string{"one", "two", "two", "three"}))
+	})
+
+	It("ignores a record cut short by a crash", func() {
+		Expect(db.Open()).To(Succeed())
+
+		sender.SendRawReturns(errors.New("nope"))
+		Expect(db.SendRaw([]byte("one"))).To(MatchError("nope"))
+		Expect(db.SendRaw([]byte("two"))).To(MatchError("nope"))
+		Expect(db.Close()).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.Truncate(path, info.Size()-1)).To(Succeed())
+
+		sender = &clientfakes.FakeMessageSender{}
+		db = NewDiskBuffer(path, sender)
+		Expect(db.Open()).To(Succeed())
+		Expect(rawSent()).To(Equal([]string{"one"}))
+	})
+
+	It("compacts the log once CompactAfterBytes have been appended", func() {
+		db.CompactAfterBytes = 256
+		Expect(db.Open()).To(Succeed())
+
+		sender.SendRawReturnsOnCall(0, errors.New("nope"))
+		Expect(db.SendRaw([]byte("kept"))).To(MatchError("nope"))
+
+		for i := 0; i < 100; i++ {
+			Expect(db.SendRaw(make([]byte, 32))).To(Succeed())
+		}
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeNumerically("<", 512))
+		Expect(db.Close()).To(Succeed())
+
+		sender = &clientfakes.FakeMessageSender{}
+		db = NewDiskBuffer(path, sender)
+		Expect(db.Open()).To(Succeed())
+		Expect(rawSent()).To(Equal([]string{"kept"}))
+	})
+
+	It("is safe for concurrent producers", func() {
+		db.CompactAfterBytes = 1024
+		Expect(db.Open()).To(Succeed())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for j := 0; j < 50; j++ {
+					Expect(db.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+				}
+			}()
+		}
+
+		wg.Wait()
+		Expect(sender.SendCallCount()).To(Equal(400))
+		Expect(db.Close()).To(Succeed())
+
+		sender = &clientfakes.FakeMessageSender{}
+		db = NewDiskBuffer(path, sender)
+		Expect(db.Open()).To(Succeed())
+		Expect(sender.SendRawCallCount()).To(BeZero())
+	})
+})
@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package client sends events to Fluentd and Fluent Bit servers with the
+Forward protocol, over TCP, TLS or websockets.
+
+# Configuration files
+
+LoadConfig reads a WSClientConfig from a YAML or JSON file, and Build makes
+the WSClient it describes. Durations are strings such as "30s". Every
+section and field is optional, except server.host. For example:
+
+	server:
+	  host: fluentd.example.com
+	  port: 8443
+	auth:
+	  iam_token: s3cr3t
+	tls:
+	  cert_file: /etc/fluent/client.pem
+	  key_file: /etc/fluent/client-key.pem
+	  ca_file: /etc/fluent/ca.pem
+	connection:
+	  ping_interval: 30s
+	  write_timeout: 5s
+	  max_message_bytes: 1048576
+	retry:
+	  base_delay: 100ms
+	  multiplier: 2
+	  max_delay: 30s
+	  jitter: 0.2
+	  attempts: 10
+	metrics:
+	  provider: prometheus
+	  namespace: fluent_forward
+	  client_name: billing
+	rate_limit:
+	  per_second: 500
+	  burst: 1000
+	log:
+	  level: info
+	tag_prefix: billing.
+
+The JSON Schema of the format is:
+
+	{
+	  "$schema": "https://json-schema.org/draft/2020-12/schema",
+	  "title": "WSClientConfig",
+	  "type": "object",
+	  "additionalProperties": false,
+	  "required": ["server"],
+	  "$defs": {
+	    "duration": {"type": "string", "pattern": "^([0-9]+(\\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$"}
+	  },
+	  "properties": {
+	    "server": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "required": ["host"],
+	      "properties": {
+	        "host": {"type": "string", "minLength": 1},
+	        "port": {"type": "integer", "minimum": 0, "maximum": 65535}
+	      }
+	    },
+	    "auth": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "iam_token": {"type": "string"}
+	      }
+	    },
+	    "tls": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "dependentRequired": {"cert_file": ["key_file"], "key_file": ["cert_file"]},
+	      "properties": {
+	        "cert_file": {"type": "string"},
+	        "key_file": {"type": "string"},
+	        "ca_file": {"type": "string"}
+	      }
+	    },
+	    "connection": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "ping_interval": {"$ref": "#/$defs/duration"},
+	        "write_timeout": {"$ref": "#/$defs/duration"},
+	        "max_message_bytes": {"type": "integer", "minimum": 0}
+	      }
+	    },
+	    "retry": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "base_delay": {"$ref": "#/$defs/duration"},
+	        "multiplier": {"type": "number", "minimum": 1},
+	        "max_delay": {"$ref": "#/$defs/duration"},
+	        "jitter": {"type": "number", "minimum": 0, "maximum": 1},
+	        "attempts": {"type": "integer", "minimum": 0}
+	      }
+	    },
+	    "metrics": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "provider": {"type": "string"},
+	        "namespace": {"type": "string"},
+	        "client_name": {"type": "string"}
+	      }
+	    },
+	    "rate_limit": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "per_second": {"type": "number", "minimum": 0},
+	        "burst": {"type": "integer", "minimum": 0}
+	      }
+	    },
+	    "log": {
+	      "type": "object",
+	      "additionalProperties": false,
+	      "properties": {
+	        "level": {"enum": ["", "debug", "info", "none"]}
+	      }
+	    },
+	    "tag_prefix": {"type": "string"}
+	  }
+	}
+
+The "prometheus" metrics provider is registered by importing the
+fluent/client/metrics package.
+
+WSClient.ReloadConfig applies a changed configuration to a running client,
+e.g. on SIGHUP; see its example.
+*/
+package client
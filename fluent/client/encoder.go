@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// MessageEncoder is a message that encodes itself. Implement it to send
+// types that have no generated msgp code; EncodeTo must write exactly one
+// message to w.
+type MessageEncoder interface {
+	EncodeTo(w io.Writer) error
+}
+
+// MsgpAdapter returns a MessageEncoder that encodes e with its generated
+// msgp code. Protocol messages passed through it are still seen as such
+// by middleware and metrics.
+func MsgpAdapter(e msgp.Encodable) MessageEncoder {
+	return msgpEncoder{Encodable: e}
+}
+
+type msgpEncoder struct {
+	msgp.Encodable
+}
+
+func (e msgpEncoder) EncodeTo(w io.Writer) error {
+	return msgp.Encode(w, e.Encodable)
+}
+
+// MapEncoder returns a MessageEncoder that encodes m as a msgpack map,
+// using reflection for its values. It is convenient for records built at
+// runtime, but slower than generated code.
+func MapEncoder(m map[string]interface{}) MessageEncoder {
+	return mapEncoder(m)
+}
+
+type mapEncoder map[string]interface{}
+
+func (m mapEncoder) EncodeTo(w io.Writer) error {
+	mw := msgp.NewWriter(w)
+	if err := mw.WriteMapStrIntf(m); err != nil {
+		return err
+	}
+
+	return mw.Flush()
+}
+
+// customEncodable carries a MessageEncoder through the send path, which
+// deals in msgp.Encodable. encode writes it with EncodeTo, bypassing the
+// client's Codec.
+type customEncodable struct {
+	MessageEncoder
+}
+
+func (e customEncodable) EncodeMsg(w *msgp.Writer) error {
+	return e.EncodeTo(w)
+}
+
+// encodable returns the msgp.Encodable to send for e.
+func encodable(e MessageEncoder) msgp.Encodable {
+	if me, ok := e.(msgpEncoder); ok {
+		return me.Encodable
+	}
+
+	return customEncodable{MessageEncoder: e}
+}
+
+// SendMessage sends a single message that encodes itself. Wrap types with
+// generated msgp code in MsgpAdapter.
+func (c *WSClient) SendMessage(e MessageEncoder) error {
+	return c.SendMessageContext(context.Background(), encodable(e))
+}
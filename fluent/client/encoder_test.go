@@ -0,0 +1,126 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// rawEncoder writes its bytes as they are.
+type rawEncoder []byte
+
+func (e rawEncoder) EncodeTo(w io.Writer) error {
+	_, err := w.Write(e)
+	return err
+}
+
+var _ = Describe("MessageEncoder", func() {
+	It("MsgpAdapter encodes with the generated code", func() {
+		msg := protocol.NewMessage("foo", map[string]interface{}{"log": "oi"})
+
+		buf := &bytes.Buffer{}
+		Expect(MsgpAdapter(msg).EncodeTo(buf)).To(Succeed())
+
+		expected, err := msg.MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(buf.Bytes()).To(Equal(expected))
+	})
+
+	It("MapEncoder encodes a msgpack map", func() {
+		buf := &bytes.Buffer{}
+		Expect(MapEncoder(map[string]interface{}{"log": "oi", "n": int64(1)}).EncodeTo(buf)).To(Succeed())
+
+		v, _, err := msgp.ReadMapStrIntfBytes(buf.Bytes(), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v).To(Equal(map[string]interface{}{"log": "oi", "n": int64(1)}))
+	})
+
+	Describe("WSClient.SendMessage", func() {
+		var (
+			conn *wsfakes.FakeConnection
+			wc   *WSClient
+		)
+
+		BeforeEach(func() {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn = &wsfakes.FakeConnection{}
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			wc = NewWS(WSConnectionOptions{Factory: factory})
+			Expect(wc.Connect()).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(wc.Disconnect()).To(Succeed())
+		})
+
+		It("writes what the encoder produces", func() {
+			Expect(wc.SendMessage(rawEncoder("oi"))).To(Succeed())
+
+			Expect(conn.WriteCallCount()).To(Equal(1))
+			Expect(conn.WriteArgsForCall(0)).To(Equal([]byte("oi")))
+		})
+
+		It("bypasses the Codec for custom encoders", func() {
+			wc.Codec = JSONCodec{}
+
+			Expect(wc.SendMessage(rawEncoder("oi"))).To(Succeed())
+			Expect(conn.WriteArgsForCall(0)).To(Equal([]byte("oi")))
+		})
+
+		It("passes adapted protocol messages to middleware", func() {
+			var tags []string
+			wc.Use(func(next SendFunc) SendFunc {
+				return func(ctx context.Context, e msgp.Encodable) error {
+					tags = append(tags, MessageTag(e))
+					return next(ctx, e)
+				}
+			})
+			Expect(wc.Reconnect()).To(Succeed())
+
+			Expect(wc.SendMessage(MsgpAdapter(protocol.NewMessage("foo", nil)))).To(Succeed())
+			Expect(tags).To(Equal([]string{"foo"}))
+		})
+
+		It("respects MaxMessageBytes", func() {
+			wc.MaxMessageBytes = 1
+
+			Expect(wc.SendMessage(rawEncoder("oi"))).To(MatchError(ErrMessageTooLarge))
+			Expect(conn.WriteCallCount()).To(BeZero())
+		})
+	})
+})
@@ -1,10 +1,148 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 )
 
+// ErrRetriable is matched, with errors.Is, by errors that a later attempt
+// may not meet, such as network timeouts and temporary server errors.
+var ErrRetriable = errors.New("retriable")
+
+// ErrPermanent is matched, with errors.Is, by errors that every attempt
+// will meet, such as authentication failures, messages that are too large
+// and protocol violations.
+var ErrPermanent = errors.New("permanent")
+
+// classifiedError wraps an error so that it also matches ErrRetriable or
+// ErrPermanent.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// Retriable wraps err so that it matches ErrRetriable. It returns nil if
+// err is nil.
+func Retriable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &classifiedError{class: ErrRetriable, err: err}
+}
+
+// Permanent wraps err so that it matches ErrPermanent. It returns nil if
+// err is nil.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &classifiedError{class: ErrPermanent, err: err}
+}
+
+// IsRetriable reports whether err, or an error it wraps, is retriable:
+// it matches ErrRetriable or is a network timeout. An error that is also
+// permanent is not retriable.
+func IsRetriable(err error) bool {
+	if err == nil || IsPermanent(err) {
+		return false
+	}
+
+	if errors.Is(err, ErrRetriable) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsPermanent reports whether err, or an error it wraps, matches
+// ErrPermanent. Retrying the call that returned it is pointless.
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// ErrWriteTimeout is returned when a message could not be written before
+// its deadline. The websocket connection is unusable after a write timeout
+// and must be reconnected.
+var ErrWriteTimeout = Retriable(errors.New("write deadline exceeded"))
+
+// ErrAckTimeout is returned when the peer does not acknowledge a message
+// before the ack timeout. The message may or may not have been received.
+var ErrAckTimeout = Retriable(errors.New("ack timeout exceeded"))
+
+// ErrCircuitOpen is returned without attempting a write when the client's
+// CircuitBreaker is open.
+var ErrCircuitOpen = Retriable(errors.New("circuit breaker is open"))
+
+// ErrBufferFull is returned when a message cannot be buffered because the
+// buffer is full and its policy is BufferReturnError.
+var ErrBufferFull = Retriable(errors.New("message buffer is full"))
+
+// ErrTokenExpired is returned by DefaultWSConnectionFactory when the IAM
+// token has expired and could not be refreshed before dialing.
+var ErrTokenExpired = Permanent(errors.New("IAM token has expired"))
+
+// ErrShuttingDown is returned by sends made after GracefulDisconnect has
+// been called.
+var ErrShuttingDown = Permanent(errors.New("client is shutting down"))
+
+// ErrDrainTimeout is returned by DrainAndDisconnect when sends in progress
+// did not finish within its timeout.
+var ErrDrainTimeout = errors.New("timed out draining sends")
+
+// ErrQueueFull is returned by SendMessageAsync when its queue is full.
+var ErrQueueFull = Retriable(errors.New("async queue is full"))
+
+// ErrMessageTooLarge is returned when an encoded message is larger than
+// the client's MaxMessageBytes.
+var ErrMessageTooLarge = Permanent(errors.New("message too large"))
+
+// ErrNoRoute is returned by TagRouter when no route matches a tag, and by
+// ConsistentHashRouter when it has no nodes.
+var ErrNoRoute = Permanent(errors.New("no route for tag"))
+
+// ErrNoServers is returned by FailoverClient when it has no servers, and
+// by SetServers for an empty list, e.g. once a service has no ready
+// endpoints left.
+var ErrNoServers = Retriable(errors.New("no servers"))
+
+// ErrDiskBufferClosed is returned by DiskBuffer sends made before Open or
+// after Close.
+var ErrDiskBufferClosed = errors.New("disk buffer is not open")
+
+// ErrSessionClosed is returned by BiDiSession calls that are waiting when
+// its connection closes, and by those made after.
+var ErrSessionClosed = Retriable(errors.New("session closed"))
+
+// ErrPanic is returned by Listen, with the panic value, when a ReadHandler
+// panicked and the client's RestartOnPanic is false.
+var ErrPanic = errors.New("read handler panicked")
+
+// ErrAckUnsupported is returned by SendMessageAck when the server's
+// negotiated capabilities do not include chunk acknowledgements.
+var ErrAckUnsupported = Permanent(errors.New("server does not support acks"))
+
+// ErrCertificateNotPinned is returned when none of the certificates a
+// server presents matches the fingerprints set with PinCertificateSHA256.
+var ErrCertificateNotPinned = Permanent(errors.New("server certificate is not pinned"))
+
 type WSConnError struct {
 	StatusCode   int
 	ResponseBody string
@@ -34,6 +172,16 @@ func (e *WSConnError) IsRetryable() bool {
 	return e.retryable
 }
 
+// Is makes the error match ErrRetriable or ErrPermanent, as IsRetryable
+// reports.
+func (e *WSConnError) Is(target error) bool {
+	if e.retryable {
+		return target == ErrRetriable
+	}
+
+	return target == ErrPermanent
+}
+
 func NewWSConnError(err error, statusCode int, respBody string) *WSConnError {
 	return &WSConnError{ConnErr: err,
 		StatusCode:   statusCode,
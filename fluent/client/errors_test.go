@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retriable and Permanent", func() {
+	cause := errors.New("cause")
+
+	It("wrap the cause", func() {
+		err := Retriable(cause)
+		Expect(err).To(MatchError("cause"))
+		Expect(errors.Is(err, cause)).To(BeTrue())
+		Expect(errors.Is(err, ErrRetriable)).To(BeTrue())
+		Expect(errors.Is(err, ErrPermanent)).To(BeFalse())
+
+		err = Permanent(cause)
+		Expect(errors.Is(err, cause)).To(BeTrue())
+		Expect(errors.Is(err, ErrPermanent)).To(BeTrue())
+		Expect(errors.Is(err, ErrRetriable)).To(BeFalse())
+	})
+
+	It("return nil for nil", func() {
+		Expect(Retriable(nil)).To(BeNil())
+		Expect(Permanent(nil)).To(BeNil())
+	})
+})
+
+var _ = Describe("IsRetriable and IsPermanent", func() {
+	DescribeTable("classify errors",
+		func(err error, retriable, permanent bool) {
+			Expect(IsRetriable(err)).To(Equal(retriable))
+			Expect(IsPermanent(err)).To(Equal(permanent))
+		},
+		Entry("nil", nil, false, false),
+		Entry("an unclassified error", errors.New("unknown"), false, false),
+		Entry("a write timeout", fmt.Errorf("%w: i/o timeout", ErrWriteTimeout), true, false),
+		Entry("an ack timeout", ErrAckTimeout, true, false),
+		Entry("a network timeout", fmt.Errorf("dial: %w", &timeoutError{}), true, false),
+		Entry("a temporary server error", NewWSConnError(nil, http.StatusServiceUnavailable, ""), true, false),
+		Entry("an auth failure", NewWSConnError(nil, http.StatusUnauthorized, ""), false, true),
+		Entry("an expired token", ErrTokenExpired, false, true),
+		Entry("a message that is too large", fmt.Errorf("%w: exceeds 10 bytes", ErrMessageTooLarge), false, true),
+		Entry("a permanent error wrapped as retriable", Retriable(ErrMessageTooLarge), false, true),
+	)
+
+	It("keep the sentinels matchable", func() {
+		err := fmt.Errorf("send: %w", ErrMessageTooLarge)
+		Expect(errors.Is(err, ErrMessageTooLarge)).To(BeTrue())
+		Expect(errors.Is(err, ErrWriteTimeout)).To(BeFalse())
+		Expect(ErrMessageTooLarge).To(MatchError("message too large"))
+	})
+})
@@ -0,0 +1,93 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+// A WSClient is an io.Writer, so a log.Logger can write to it directly.
+// Each line is sent as an event tagged WriterTag.
+func ExampleWSClient_Write() {
+	c := client.NewWS(client.WSConnectionOptions{
+		Factory: &client.DefaultWSConnectionFactory{
+			URL: "ws://127.0.0.1:8083",
+		},
+		WriterTag: "app.log",
+	})
+
+	if err := c.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	logger := log.New(c, "", 0)
+	logger.Println("service started")
+}
+
+// ReloadConfig can be called on SIGHUP to apply changes to a configuration
+// file without restarting. Changes to the server, auth or TLS move the
+// client to a new connection without failing sends.
+func ExampleWSClient_ReloadConfig() {
+	const path = "/etc/fluent/client.yaml"
+
+	cfg, err := client.LoadConfig(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	c, err := cfg.Build()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = c.Connect(); err != nil {
+		log.Fatal(err)
+	}
+	defer c.Disconnect()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		for range hup {
+			cfg, err := client.LoadConfig(path)
+			if err == nil {
+				err = c.ReloadConfig(cfg)
+			}
+
+			if err != nil {
+				log.Printf("reload %s: %v", path, err)
+			}
+		}
+	}()
+
+	// ...
+}
@@ -0,0 +1,275 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// ServerAddress identifies a server, such as the URL of a websocket
+// endpoint.
+type ServerAddress string
+
+// FailoverServer pairs a WSClient with the address of the server it
+// connects to.
+type FailoverServer struct {
+	Address ServerAddress
+	Client  *WSClient
+}
+
+// FailoverPolicy decides which of a FailoverClient's servers to use.
+type FailoverPolicy interface {
+	// Order returns the indexes of n servers in the order that Connect
+	// tries them.
+	Order(n int) []int
+	// Next is called when a send to server current fails with err. It
+	// returns the index of the server to send to next; returning current
+	// retries the same server.
+	Next(current, n int, err error) int
+}
+
+// LinearFailoverPolicy tries the servers in the order they were given,
+// wrapping around after the last.
+type LinearFailoverPolicy struct{}
+
+func (LinearFailoverPolicy) Order(n int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+
+	return order
+}
+
+func (LinearFailoverPolicy) Next(current, n int, _ error) int {
+	if n < 2 {
+		return current
+	}
+
+	return (current + 1) % n
+}
+
+// RandomFailoverPolicy tries the servers in random order, and on failure
+// moves to a random server other than the one that failed.
+type RandomFailoverPolicy struct{}
+
+func (RandomFailoverPolicy) Order(n int) []int {
+	return rand.Perm(n) //#nosec
+}
+
+func (RandomFailoverPolicy) Next(current, n int, _ error) int {
+	if n < 2 {
+		return current
+	}
+
+	next := rand.Intn(n - 1) //#nosec
+	if next >= current {
+		next++
+	}
+
+	return next
+}
+
+// FailoverClient sends to one of several servers at a time, switching to
+// another, as chosen by its Policy, when a send fails. A failed send is
+// tried at most once per server.
+type FailoverClient struct {
+	// Policy chooses the servers. If nil, LinearFailoverPolicy is used.
+	Policy  FailoverPolicy
+	servers []FailoverServer
+	active  int
+	// generation counts the server lists set, so that a server connected
+	// outside the lock is only made active if the list has not changed
+	generation uint64
+	lock       sync.RWMutex
+}
+
+func NewFailoverClient(policy FailoverPolicy, servers ...FailoverServer) *FailoverClient {
+	if policy == nil {
+		policy = LinearFailoverPolicy{}
+	}
+
+	return &FailoverClient{
+		Policy:  policy,
+		servers: servers,
+	}
+}
+
+// ActiveServer returns the address of the server that sends go to.
+func (c *FailoverClient) ActiveServer() ServerAddress {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if len(c.servers) == 0 {
+		return ""
+	}
+
+	return c.servers[c.active].Address
+}
+
+// Connect tries the servers in the order given by the Policy and makes the
+// first that connects the active server.
+func (c *FailoverClient) Connect() error {
+	c.lock.RLock()
+	servers, generation := c.servers, c.generation
+	c.lock.RUnlock()
+
+	return c.connectFirst(servers, generation)
+}
+
+// connectFirst connects to the first of servers, in the order given by the
+// Policy, that connects, and makes it the active server if servers are
+// still those of generation. It must be called without the lock held.
+func (c *FailoverClient) connectFirst(servers []FailoverServer, generation uint64) error {
+	if len(servers) == 0 {
+		return ErrNoServers
+	}
+
+	var err error
+
+	for _, i := range c.Policy.Order(len(servers)) {
+		if err = servers[i].Client.Connect(); err == nil {
+			c.lock.Lock()
+			if c.generation == generation {
+				c.active = i
+			}
+			c.lock.Unlock()
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("connect to all %d servers failed: %w", len(servers), err)
+}
+
+// Disconnect disconnects every server.
+func (c *FailoverClient) Disconnect() (err error) {
+	c.lock.RLock()
+	servers := c.servers
+	c.lock.RUnlock()
+
+	for _, s := range servers {
+		if derr := s.Client.Disconnect(); derr != nil {
+			err = derr
+		}
+	}
+
+	return
+}
+
+func (c *FailoverClient) Send(e protocol.ChunkEncoder) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.Send(e)
+	})
+}
+
+func (c *FailoverClient) SendRaw(raw []byte) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.SendRaw(raw)
+	})
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *FailoverClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+func (c *FailoverClient) send(send func(wc *WSClient) error) error {
+	c.lock.RLock()
+	n := len(c.servers)
+	c.lock.RUnlock()
+
+	if n == 0 {
+		return ErrNoServers
+	}
+
+	var err error
+
+	for attempt := 0; attempt < n; attempt++ {
+		// SetServers may have replaced the servers since the last attempt
+		c.lock.RLock()
+		if c.active >= len(c.servers) {
+			c.lock.RUnlock()
+			return ErrNoServers
+		}
+
+		current := c.active
+		wc := c.servers[current].Client
+		c.lock.RUnlock()
+
+		if err = send(wc); err == nil {
+			return nil
+		}
+
+		c.failover(current, err)
+	}
+
+	return err
+}
+
+// failover moves off the failed server, unless a concurrent send has
+// already done so. The next server is dialed without the lock, so that
+// sends carry on meanwhile.
+func (c *FailoverClient) failover(failed int, err error) {
+	c.lock.RLock()
+	if c.active != failed || failed >= len(c.servers) {
+		c.lock.RUnlock()
+		return
+	}
+
+	next := c.Policy.Next(failed, len(c.servers), err)
+	if next == failed || next < 0 || next >= len(c.servers) {
+		c.lock.RUnlock()
+		return
+	}
+
+	from, to, generation := c.servers[failed].Client, c.servers[next].Client, c.generation
+	c.lock.RUnlock()
+
+	dialed := false
+	if to.Session() == nil {
+		// a server that will not connect fails the next send, which
+		// moves on again
+		dialed = to.Connect() == nil
+	}
+
+	c.lock.Lock()
+	switched := c.generation == generation && c.active == failed
+	if switched {
+		c.active = next
+	}
+	c.lock.Unlock()
+
+	if switched {
+		_ = from.Disconnect()
+	} else if dialed {
+		// a concurrent send or SetServers moved elsewhere first
+		_ = to.Disconnect()
+	}
+}
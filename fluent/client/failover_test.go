@@ -0,0 +1,197 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FailoverClient", func() {
+	var (
+		factories []*clientfakes.FakeWSConnectionFactory
+		conns     []*wsfakes.FakeConnection
+		servers   []FailoverServer
+		policy    FailoverPolicy
+		fc        *FailoverClient
+	)
+
+	BeforeEach(func() {
+		factories, conns, servers = nil, nil, nil
+		policy = LinearFailoverPolicy{}
+
+		for _, addr := range []ServerAddress{"wss://a", "wss://b", "wss://c"} {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn := &wsfakes.FakeConnection{}
+
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			factories = append(factories, factory)
+			conns = append(conns, conn)
+			servers = append(servers, FailoverServer{
+				Address: addr,
+				Client:  NewWS(WSConnectionOptions{Factory: factory}),
+			})
+		}
+	})
+
+	JustBeforeEach(func() {
+		fc = NewFailoverClient(policy, servers...)
+	})
+
+	AfterEach(func() {
+		Expect(fc.Disconnect()).To(Succeed())
+	})
+
+	Describe("Connect", func() {
+		It("uses the first server that connects", func() {
+			factories[0].NewReturns(nil, errors.New("nope"))
+
+			Expect(fc.Connect()).To(Succeed())
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://b")))
+			Expect(factories[2].NewCallCount()).To(BeZero())
+		})
+
+		It("fails when no server connects", func() {
+			for _, f := range factories {
+				f.NewReturns(nil, errors.New("nope"))
+			}
+
+			Expect(fc.Connect()).To(MatchError(MatchRegexp("all 3 servers failed: nope")))
+		})
+	})
+
+	Describe("Send", func() {
+		JustBeforeEach(func() {
+			Expect(fc.Connect()).To(Succeed())
+		})
+
+		It("sends to the active server", func() {
+			Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+			Expect(conns[0].WriteCallCount()).To(Equal(1))
+			Expect(factories[1].NewCallCount()).To(BeZero())
+		})
+
+		It("fails over to the next server", func() {
+			conns[0].WriteReturns(0, errors.New("nope"))
+
+			Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://b")))
+			Expect(conns[1].WriteCallCount()).To(Equal(1))
+		})
+
+		It("keeps sending to the active server while the next one dials", func() {
+			dialing, dialed := make(chan struct{}), make(chan struct{})
+			factories[1].NewStub = func(context.Context) (ext.Conn, error) {
+				close(dialing)
+				<-dialed
+
+				return &extfakes.FakeConn{}, nil
+			}
+			conns[0].WriteReturnsOnCall(0, 0, errors.New("nope"))
+
+			sent := make(chan error, 1)
+			go func() { sent <- fc.SendRaw([]byte("first")) }()
+			Eventually(dialing).Should(BeClosed())
+
+			Expect(fc.SendRaw([]byte("second"))).To(Succeed())
+			Expect(conns[0].WriteCallCount()).To(Equal(2))
+
+			close(dialed)
+			Eventually(sent).Should(Receive(BeNil()))
+			Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://b")))
+		})
+
+		It("gives up after trying every server", func() {
+			for _, conn := range conns {
+				conn.WriteReturns(0, errors.New("nope"))
+			}
+
+			Expect(fc.SendRaw([]byte("oi"))).To(MatchError("nope"))
+
+			for _, conn := range conns {
+				Expect(conn.WriteCallCount()).To(Equal(1))
+			}
+		})
+
+		When("the policy retries the same server", func() {
+			BeforeEach(func() {
+				policy = &stickyPolicy{}
+			})
+
+			It("does not switch", func() {
+				conns[0].WriteReturnsOnCall(0, 0, errors.New("nope"))
+
+				Expect(fc.SendRaw([]byte("oi"))).To(Succeed())
+				Expect(fc.ActiveServer()).To(Equal(ServerAddress("wss://a")))
+				Expect(conns[0].WriteCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("LinearFailoverPolicy", func() {
+		It("moves to the next server, wrapping around", func() {
+			Expect(LinearFailoverPolicy{}.Next(1, 3, nil)).To(Equal(2))
+			Expect(LinearFailoverPolicy{}.Next(2, 3, nil)).To(Equal(0))
+		})
+
+		It("stays put without another server", func() {
+			Expect(LinearFailoverPolicy{}.Next(0, 1, nil)).To(Equal(0))
+			Expect(LinearFailoverPolicy{}.Next(0, 0, nil)).To(Equal(0))
+		})
+	})
+
+	Describe("RandomFailoverPolicy", func() {
+		It("orders every server", func() {
+			Expect(RandomFailoverPolicy{}.Order(5)).To(ConsistOf(0, 1, 2, 3, 4))
+		})
+
+		It("never picks the server that failed", func() {
+			for i := 0; i < 100; i++ {
+				next := RandomFailoverPolicy{}.Next(1, 3, nil)
+				Expect(next).To(BeElementOf(0, 2))
+			}
+
+			Expect(RandomFailoverPolicy{}.Next(0, 1, nil)).To(Equal(0))
+		})
+	})
+})
+
+type stickyPolicy struct {
+	LinearFailoverPolicy
+}
+
+func (*stickyPolicy) Next(current, _ int, _ error) int {
+	return current
+}
@@ -0,0 +1,135 @@
+// MIT License
+//
+// Copyright contributors to the fluent-forward-go project
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: forward.proto
+
+package forwardpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Forward_Send_FullMethodName = "/fluent.forward.v1.Forward/Send"
+)
+
+// ForwardClient is the client API for Forward service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ForwardClient interface {
+	// Send delivers one message. It returns once the server has accepted the
+	// message; a failed call means the message may not have been received.
+	Send(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Ack, error)
+}
+
+type forwardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewForwardClient(cc grpc.ClientConnInterface) ForwardClient {
+	return &forwardClient{cc}
+}
+
+func (c *forwardClient) Send(ctx context.Context, in *Message, opts ...grpc.CallOption) (*Ack, error) {
+	out := new(Ack)
+	err := c.cc.Invoke(ctx, Forward_Send_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ForwardServer is the server API for Forward service.
+// All implementations must embed UnimplementedForwardServer
+// for forward compatibility
+type ForwardServer interface {
+	// Send delivers one message. It returns once the server has accepted the
+	// message; a failed call means the message may not have been received.
+	Send(context.Context, *Message) (*Ack, error)
+	mustEmbedUnimplementedForwardServer()
+}
+
+// UnimplementedForwardServer must be embedded to have forward compatible implementations.
+type UnimplementedForwardServer struct {
+}
+
+func (UnimplementedForwardServer) Send(context.Context, *Message) (*Ack, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedForwardServer) mustEmbedUnimplementedForwardServer() {}
+
+// UnsafeForwardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ForwardServer will
+// result in compilation errors.
+type UnsafeForwardServer interface {
+	mustEmbedUnimplementedForwardServer()
+}
+
+func RegisterForwardServer(s grpc.ServiceRegistrar, srv ForwardServer) {
+	s.RegisterService(&Forward_ServiceDesc, srv)
+}
+
+func _Forward_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Message)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ForwardServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Forward_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ForwardServer).Send(ctx, req.(*Message))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Forward_ServiceDesc is the grpc.ServiceDesc for Forward service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Forward_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fluent.forward.v1.Forward",
+	HandlerType: (*ForwardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _Forward_Send_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "forward.proto",
+}
@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package grpc sends Fluent Forward messages over gRPC, using the Forward
+// service defined in forwardpb/forward.proto. Messages are carried as
+// MessagePack, so a receiver can decode them exactly as it would from the
+// websocket or TCP transports.
+//
+// The generated code is kept in forwardpb; regenerate it with protoc,
+// protoc-gen-go and protoc-gen-go-grpc from that directory:
+//
+//	protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. forward.proto
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/grpc/forwardpb"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+	grpclib "google.golang.org/grpc"
+)
+
+type ConnectionOptions struct {
+	Target      string
+	DialOptions []grpclib.DialOption
+}
+
+// GRPCTransport sends each message with a call to the Forward service's
+// Send method, which returns once the server has accepted it. Like the
+// other transports it is a client.MessageSender, so it can be swapped in
+// for, or mixed with, them.
+type GRPCTransport struct {
+	// Target is the server to dial, in gRPC's name syntax, such as
+	// "dns:///fluentd:24225".
+	Target string
+	// DialOptions are passed to grpc.Dial, e.g. to set the transport
+	// credentials, which gRPC requires.
+	DialOptions []grpclib.DialOption
+	conn        *grpclib.ClientConn
+	forward     forwardpb.ForwardClient
+	connLock    sync.RWMutex
+}
+
+func New(opts ConnectionOptions) *GRPCTransport {
+	return &GRPCTransport{
+		Target:      opts.Target,
+		DialOptions: opts.DialOptions,
+	}
+}
+
+// Connect dials the server. gRPC connects in the background and reconnects
+// by itself, so Connect fails only if the target or options are invalid.
+func (t *GRPCTransport) Connect() error {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if t.conn != nil {
+		return nil
+	}
+
+	conn, err := grpclib.Dial(t.Target, t.DialOptions...)
+	if err != nil {
+		return err
+	}
+
+	t.conn = conn
+	t.forward = forwardpb.NewForwardClient(conn)
+
+	return nil
+}
+
+// Disconnect closes the connection. A later Connect dials again.
+func (t *GRPCTransport) Disconnect() (err error) {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if t.conn != nil {
+		err = t.conn.Close()
+		t.conn, t.forward = nil, nil
+	}
+
+	return
+}
+
+// Send sends e, with its chunk option set so that the server can ack it.
+func (t *GRPCTransport) Send(e protocol.ChunkEncoder) error {
+	chunk, err := e.Chunk()
+	if err != nil {
+		return err
+	}
+
+	return t.send(context.Background(), e, chunk)
+}
+
+// SendContext sends e, giving up when ctx is done. Unlike Send it does not
+// set a chunk option, so e need only be encodable.
+func (t *GRPCTransport) SendContext(ctx context.Context, e msgp.Encodable) error {
+	return t.send(ctx, e, "")
+}
+
+func (t *GRPCTransport) send(ctx context.Context, e msgp.Encodable, chunk string) error {
+	var buf bytes.Buffer
+
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+
+	return t.sendRaw(ctx, buf.Bytes(), chunk)
+}
+
+// SendRaw sends raw, which must be an encoded forward protocol message.
+func (t *GRPCTransport) SendRaw(raw []byte) error {
+	return t.sendRaw(context.Background(), raw, "")
+}
+
+// SendMessage sends a single event in Message mode.
+func (t *GRPCTransport) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessage(tag, record))
+}
+
+func (t *GRPCTransport) sendRaw(ctx context.Context, raw []byte, chunk string) error {
+	t.connLock.RLock()
+	forward := t.forward
+	t.connLock.RUnlock()
+
+	if forward == nil {
+		return errors.New("not connected")
+	}
+
+	ack, err := forward.Send(ctx, &forwardpb.Message{Data: raw, Chunk: chunk})
+	if err != nil {
+		return err
+	}
+
+	if ack.GetChunk() != chunk {
+		return errors.New("ack chunk does not match message chunk")
+	}
+
+	return nil
+}
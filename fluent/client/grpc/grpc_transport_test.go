@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/client/grpc"
+	"github.com/IBM/fluent-forward-go/fluent/client/grpc/forwardpb"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// forwardServer records the messages it receives and acks each with its
+// chunk, unless told to fail.
+type forwardServer struct {
+	forwardpb.UnimplementedForwardServer
+
+	lock     sync.Mutex
+	messages []*forwardpb.Message
+	err      error
+	badAck   bool
+}
+
+func (s *forwardServer) Send(ctx context.Context, msg *forwardpb.Message) (*forwardpb.Ack, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	s.messages = append(s.messages, msg)
+
+	if s.badAck {
+		return &forwardpb.Ack{Chunk: "nope"}, nil
+	}
+
+	return &forwardpb.Ack{Chunk: msg.Chunk}, nil
+}
+
+func (s *forwardServer) Received() []*forwardpb.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.messages
+}
+
+var _ = Describe("GRPCTransport", func() {
+	var (
+		server    *forwardServer
+		transport *GRPCTransport
+	)
+
+	BeforeEach(func() {
+		listener := bufconn.Listen(1 << 20)
+		server = &forwardServer{}
+
+		svr := grpclib.NewServer()
+		forwardpb.RegisterForwardServer(svr, server)
+
+		go func() {
+			defer GinkgoRecover()
+			Expect(svr.Serve(listener)).To(Succeed())
+		}()
+
+		DeferCleanup(svr.Stop)
+
+		transport = New(ConnectionOptions{
+			Target: "bufnet",
+			DialOptions: []grpclib.DialOption{
+				grpclib.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return listener.DialContext(ctx)
+				}),
+				grpclib.WithTransportCredentials(insecure.NewCredentials()),
+			},
+		})
+
+		Expect(transport.Connect()).To(Succeed())
+		DeferCleanup(transport.Disconnect)
+	})
+
+	It("is a MessageSender", func() {
+		var sender client.MessageSender = transport
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("sends messages as MessagePack with their chunk", func() {
+		msg := protocol.NewMessage("foo", map[string]interface{}{"msg": "oi"})
+		Expect(transport.Send(msg)).To(Succeed())
+
+		received := server.Received()
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Chunk).To(Equal(msg.Options.Chunk))
+		Expect(received[0].Chunk).ToNot(BeEmpty())
+
+		var out protocol.Message
+		_, err := out.UnmarshalMsg(received[0].Data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(out.Tag).To(Equal("foo"))
+		Expect(out.Record).To(HaveKeyWithValue("msg", "oi"))
+	})
+
+	It("sends raw messages", func() {
+		raw, err := protocol.NewMessage("foo", "oi").MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(transport.SendRaw(raw)).To(Succeed())
+		Expect(server.Received()[0].Data).To(Equal(raw))
+	})
+
+	It("honours the context", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		Expect(transport.SendContext(ctx, protocol.NewMessage("foo", "oi"))).To(Succeed())
+
+		cancel()
+		Expect(transport.SendContext(ctx, protocol.NewMessage("foo", "oi"))).ToNot(Succeed())
+		Expect(server.Received()).To(HaveLen(1))
+	})
+
+	It("returns the server's error", func() {
+		server.err = errors.New("nope")
+
+		Expect(transport.SendMessage("foo", "oi")).To(MatchError(ContainSubstring("nope")))
+	})
+
+	It("fails when the ack does not match", func() {
+		server.badAck = true
+
+		Expect(transport.SendMessage("foo", "oi")).To(MatchError(ContainSubstring("does not match")))
+	})
+
+	It("fails when not connected", func() {
+		Expect(transport.Disconnect()).To(Succeed())
+		Expect(transport.SendMessage("foo", "oi")).To(MatchError("not connected"))
+	})
+})
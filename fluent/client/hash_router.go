@@ -0,0 +1,187 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultHashReplicas = 100
+)
+
+// ConsistentHashRouter sends each message to one of several nodes chosen
+// by hashing its tag, so that messages with the same tag always go to the
+// same node, e.g. to keep them in order when each receiver owns part of
+// the tag namespace. The zero value has no nodes and is ready to use.
+//
+// Each node is placed on a hash ring at Replicas points, and a tag goes
+// to the node at the first point after the tag's hash. Adding a node
+// therefore moves only the tags that hash just before its points, about
+// 1/N of them with N nodes, all to the new node; removing a node moves
+// only its own tags, spread over the others. Every other tag keeps its
+// node.
+type ConsistentHashRouter struct {
+	// Replicas is the number of points each node has on the ring. More
+	// points spread tags more evenly. If zero, DefaultHashReplicas is
+	// used. It must not be changed once nodes have been added.
+	Replicas int
+	nodes    map[string]MessageSender
+	points   []uint64
+	owners   map[uint64]string
+	lock     sync.RWMutex
+}
+
+func (r *ConsistentHashRouter) replicas() int {
+	if r.Replicas <= 0 {
+		return DefaultHashReplicas
+	}
+
+	return r.Replicas
+}
+
+// ringHash is FNV-1a with the splitmix64 finalizer, which spreads the
+// similar keys that tags and points tend to be evenly around the ring.
+func ringHash(s string) uint64 {
+	x := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		x ^= uint64(s[i])
+		x *= 1099511628211
+	}
+
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+func pointKey(id string, i int) string {
+	return strconv.Itoa(i) + "#" + id
+}
+
+// AddNode adds a node, or replaces the sender of the node with id.
+func (r *ConsistentHashRouter) AddNode(id string, c MessageSender) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.nodes == nil {
+		r.nodes = map[string]MessageSender{}
+		r.owners = map[uint64]string{}
+	}
+
+	if _, ok := r.nodes[id]; !ok {
+		for i := 0; i < r.replicas(); i++ {
+			point := ringHash(pointKey(id, i))
+
+			// on a collision the point keeps its first owner
+			if _, taken := r.owners[point]; !taken {
+				r.owners[point] = id
+				r.points = append(r.points, point)
+			}
+		}
+
+		sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	}
+
+	r.nodes[id] = c
+}
+
+// RemoveNode removes the node with id, if there is one.
+func (r *ConsistentHashRouter) RemoveNode(id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.nodes[id]; !ok {
+		return
+	}
+
+	delete(r.nodes, id)
+
+	points := r.points[:0]
+
+	for _, point := range r.points {
+		if r.owners[point] == id {
+			delete(r.owners, point)
+			continue
+		}
+
+		points = append(points, point)
+	}
+
+	r.points = points
+}
+
+// Nodes returns the ids of the nodes, in no particular order.
+func (r *ConsistentHashRouter) Nodes() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ids := make([]string, 0, len(r.nodes))
+	for id := range r.nodes {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Route returns the id and sender of the node that messages tagged tag are
+// sent to. The sender is nil if there are no nodes.
+func (r *ConsistentHashRouter) Route(tag string) (string, MessageSender) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if len(r.points) == 0 {
+		return "", nil
+	}
+
+	hash := ringHash(tag)
+
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	id := r.owners[r.points[i]]
+
+	return id, r.nodes[id]
+}
+
+// SendMessage sends a single event in Message mode to the node for tag.
+func (r *ConsistentHashRouter) SendMessage(tag string, record interface{}) error {
+	_, sender := r.Route(tag)
+	if sender == nil {
+		return fmt.Errorf("%w %q", ErrNoRoute, tag)
+	}
+
+	return sender.Send(protocol.NewMessage(tag, record))
+}
@@ -0,0 +1,138 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"fmt"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsistentHashRouter", func() {
+	const tagCount = 10000
+
+	var (
+		router *ConsistentHashRouter
+		nodes  map[string]*clientfakes.FakeMessageSender
+	)
+
+	owners := func() map[string]string {
+		owned := map[string]string{}
+
+		for i := 0; i < tagCount; i++ {
+			tag := fmt.Sprintf("app.%d.logs", i)
+			owned[tag], _ = router.Route(tag)
+		}
+
+		return owned
+	}
+
+	BeforeEach(func() {
+		router = &ConsistentHashRouter{}
+		nodes = map[string]*clientfakes.FakeMessageSender{}
+
+		for _, id := range []string{"fluentd-0", "fluentd-1", "fluentd-2", "fluentd-3"} {
+			nodes[id] = &clientfakes.FakeMessageSender{}
+			router.AddNode(id, nodes[id])
+		}
+	})
+
+	It("always sends a tag to the same node", func() {
+		Expect(router.SendMessage("app.logs", "oi")).To(Succeed())
+		Expect(router.SendMessage("app.logs", "oi")).To(Succeed())
+
+		id, sender := router.Route("app.logs")
+		Expect(sender).To(BeIdenticalTo(nodes[id]))
+		Expect(nodes[id].SendCallCount()).To(Equal(2))
+
+		msg, ok := nodes[id].SendArgsForCall(0).(*protocol.Message)
+		Expect(ok).To(BeTrue())
+		Expect(msg.Tag).To(Equal("app.logs"))
+	})
+
+	It("spreads tags over every node", func() {
+		counts := map[string]int{}
+		for _, id := range owners() {
+			counts[id]++
+		}
+
+		Expect(counts).To(HaveLen(4))
+
+		for _, n := range counts {
+			Expect(n).To(BeNumerically("~", tagCount/4, tagCount/8))
+		}
+	})
+
+	It("moves only about 1/N of the tags to an added node", func() {
+		before := owners()
+
+		nodes["fluentd-4"] = &clientfakes.FakeMessageSender{}
+		router.AddNode("fluentd-4", nodes["fluentd-4"])
+
+		moved := 0
+
+		for tag, id := range owners() {
+			if id != before[tag] {
+				Expect(id).To(Equal("fluentd-4"))
+				moved++
+			}
+		}
+
+		Expect(moved).To(BeNumerically("~", tagCount/5, tagCount/10))
+	})
+
+	It("moves only the tags of a removed node", func() {
+		before := owners()
+
+		router.RemoveNode("fluentd-1")
+		Expect(router.Nodes()).To(ConsistOf("fluentd-0", "fluentd-2", "fluentd-3"))
+
+		for tag, id := range owners() {
+			Expect(id).ToNot(Equal("fluentd-1"))
+
+			if before[tag] != "fluentd-1" {
+				Expect(id).To(Equal(before[tag]))
+			}
+		}
+	})
+
+	It("replaces the sender of an existing node without moving tags", func() {
+		before := owners()
+
+		replacement := &clientfakes.FakeMessageSender{}
+		router.AddNode("fluentd-2", replacement)
+
+		Expect(owners()).To(Equal(before))
+		Expect(router.Nodes()).To(HaveLen(4))
+	})
+
+	It("fails when there are no nodes", func() {
+		Expect((&ConsistentHashRouter{}).SendMessage("app.logs", "oi")).To(MatchError(ErrNoRoute))
+	})
+})
@@ -0,0 +1,329 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultStandbyRetryInterval = time.Second
+)
+
+type hotConn struct {
+	address ServerAddress
+	client  *WSClient
+}
+
+// HotStandbyClient sends to a primary server while keeping a connection
+// open to a standby. When a send to the primary fails, the standby is
+// swapped in with a single atomic store and the send is retried on it, so
+// failing over costs the time taken to notice the failure, which is
+// bounded by the client's write timeout, rather than the time to dial.
+// A new standby is then dialed in the background, taking the next server
+// in turn.
+//
+// The standby is only checked when it is needed: if its connection has
+// closed by the time the primary fails, the send fails and a new standby
+// is dialed.
+type HotStandbyClient struct {
+	// NewClient creates the client for a server. Each connection gets a
+	// client of its own.
+	NewClient func(address ServerAddress) *WSClient
+	// StandbyRetryInterval is how long to wait after a standby fails to
+	// connect before trying the next server. If zero,
+	// DefaultStandbyRetryInterval is used.
+	StandbyRetryInterval time.Duration
+	// OnStandbyError, if not nil, is called when a standby fails to
+	// connect.
+	OnStandbyError func(address ServerAddress, err error)
+	servers        []ServerAddress
+	primary        atomic.Value
+	lock           sync.Mutex
+	standby        *hotConn
+	next           int
+	dialing        bool
+	done           chan struct{}
+	dials          sync.WaitGroup
+}
+
+func NewHotStandbyClient(newClient func(address ServerAddress) *WSClient, servers ...ServerAddress) *HotStandbyClient {
+	return &HotStandbyClient{
+		NewClient:            newClient,
+		StandbyRetryInterval: DefaultStandbyRetryInterval,
+		servers:              servers,
+	}
+}
+
+// Primary returns the address of the server that sends go to, or "" if
+// not connected.
+func (c *HotStandbyClient) Primary() ServerAddress {
+	if p, ok := c.primary.Load().(*hotConn); ok && p != nil {
+		return p.address
+	}
+
+	return ""
+}
+
+// Standby returns the address of the standby server, or "" while there is
+// no standby connection.
+func (c *HotStandbyClient) Standby() ServerAddress {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.standby == nil {
+		return ""
+	}
+
+	return c.standby.address
+}
+
+// Connect connects to the first of the servers that it can as the
+// primary, then to the next as the standby. It fails only if no primary
+// connects; a standby that does not connect is retried in the background.
+func (c *HotStandbyClient) Connect() error {
+	if len(c.servers) == 0 {
+		return errors.New("no servers")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.done != nil {
+		return nil
+	}
+
+	var err error
+
+	for i, address := range c.servers {
+		wc := c.NewClient(address)
+		if err = wc.Connect(); err != nil {
+			continue
+		}
+
+		c.primary.Store(&hotConn{address: address, client: wc})
+		c.next = (i + 1) % len(c.servers)
+		c.done = make(chan struct{})
+
+		c.dialStandby()
+
+		return nil
+	}
+
+	return fmt.Errorf("connect to all %d servers failed: %w", len(c.servers), err)
+}
+
+// Disconnect stops dialing standbys and disconnects the primary and
+// standby.
+func (c *HotStandbyClient) Disconnect() (err error) {
+	c.lock.Lock()
+	if c.done == nil {
+		c.lock.Unlock()
+		return nil
+	}
+
+	close(c.done)
+	c.done = nil
+	standby := c.standby
+	c.standby = nil
+	c.lock.Unlock()
+
+	c.dials.Wait()
+
+	c.lock.Lock()
+	c.dialing = false
+	c.lock.Unlock()
+
+	if p, ok := c.primary.Load().(*hotConn); ok && p != nil {
+		err = p.client.Disconnect()
+	}
+
+	c.primary.Store((*hotConn)(nil))
+
+	if standby != nil {
+		if derr := standby.client.Disconnect(); derr != nil {
+			err = derr
+		}
+	}
+
+	return
+}
+
+func (c *HotStandbyClient) Send(e protocol.ChunkEncoder) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.Send(e)
+	})
+}
+
+func (c *HotStandbyClient) SendRaw(raw []byte) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.SendRaw(raw)
+	})
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *HotStandbyClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+func (c *HotStandbyClient) send(send func(wc *WSClient) error) error {
+	p, _ := c.primary.Load().(*hotConn)
+	if p == nil {
+		return errors.New("not connected")
+	}
+
+	err := send(p.client)
+	if err == nil {
+		return nil
+	}
+
+	if p = c.failover(p); p == nil {
+		return err
+	}
+
+	return send(p.client)
+}
+
+// failover swaps the standby in for the failed primary and returns it.
+// If a concurrent send has already swapped, the new primary is returned.
+// It returns nil if there is no healthy standby.
+func (c *HotStandbyClient) failover(failed *hotConn) *hotConn {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if p, _ := c.primary.Load().(*hotConn); p != failed {
+		return p
+	}
+
+	standby := c.standby
+	if standby == nil {
+		return nil
+	}
+
+	c.standby = nil
+
+	if !healthy(standby.client) {
+		go standby.client.Disconnect() //nolint:errcheck
+		c.dialStandby()
+
+		return nil
+	}
+
+	c.primary.Store(standby)
+
+	go failed.client.Disconnect() //nolint:errcheck
+	c.dialStandby()
+
+	return standby
+}
+
+// dialStandby starts dialing a standby in the background, unless one is
+// already being dialed. It must be called with the lock held.
+func (c *HotStandbyClient) dialStandby() {
+	if c.dialing || c.done == nil {
+		return
+	}
+
+	c.dialing = true
+	c.dials.Add(1)
+
+	go func(done chan struct{}) {
+		defer c.dials.Done()
+
+		for {
+			address, ok := c.nextStandby(done)
+			if !ok {
+				return
+			}
+
+			wc := c.NewClient(address)
+
+			err := wc.Connect()
+			if err == nil {
+				c.lock.Lock()
+				c.dialing = false
+
+				if c.done != done {
+					c.lock.Unlock()
+					_ = wc.Disconnect()
+
+					return
+				}
+
+				c.standby = &hotConn{address: address, client: wc}
+				c.lock.Unlock()
+
+				return
+			}
+
+			if c.OnStandbyError != nil {
+				c.OnStandbyError(address, err)
+			}
+
+			interval := c.StandbyRetryInterval
+			if interval <= 0 {
+				interval = DefaultStandbyRetryInterval
+			}
+
+			select {
+			case <-done:
+				return
+			case <-time.After(interval):
+			}
+		}
+	}(c.done)
+}
+
+// nextStandby returns the next server in turn that is not the primary,
+// unless there is only one server. It reports false once Disconnect has
+// been called.
+func (c *HotStandbyClient) nextStandby(done chan struct{}) (ServerAddress, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.done != done {
+		c.dialing = false
+		return "", false
+	}
+
+	primary := c.Primary()
+
+	for range c.servers {
+		address := c.servers[c.next]
+		c.next = (c.next + 1) % len(c.servers)
+
+		if address != primary || len(c.servers) == 1 {
+			return address, true
+		}
+	}
+
+	return c.servers[0], true
+}
@@ -0,0 +1,174 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HotStandbyClient", func() {
+	const dialDelay = 100 * time.Millisecond
+
+	var (
+		lock     sync.Mutex
+		conns    map[ServerAddress][]*wsfakes.FakeConnection
+		dialErrs map[ServerAddress]error
+		hs       *HotStandbyClient
+	)
+
+	connsTo := func(address ServerAddress) []*wsfakes.FakeConnection {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return conns[address]
+	}
+
+	BeforeEach(func() {
+		conns = map[ServerAddress][]*wsfakes.FakeConnection{}
+		dialErrs = map[ServerAddress]error{}
+
+		hs = NewHotStandbyClient(func(address ServerAddress) *WSClient {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn := &wsfakes.FakeConnection{}
+
+			factory.NewStub = func(context.Context) (ext.Conn, error) {
+				time.Sleep(dialDelay)
+
+				lock.Lock()
+				defer lock.Unlock()
+
+				if err := dialErrs[address]; err != nil {
+					return nil, err
+				}
+
+				conns[address] = append(conns[address], conn)
+
+				return &extfakes.FakeConn{}, nil
+			}
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			return NewWS(WSConnectionOptions{Factory: factory})
+		}, "wss://a", "wss://b", "wss://c")
+		hs.StandbyRetryInterval = 10 * time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		Expect(hs.Connect()).To(Succeed())
+		Eventually(hs.Standby).ShouldNot(BeEmpty())
+	})
+
+	AfterEach(func() {
+		Expect(hs.Disconnect()).To(Succeed())
+	})
+
+	It("is a MessageSender", func() {
+		var sender MessageSender = hs
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("sends to the primary while keeping a standby", func() {
+		Expect(hs.Primary()).To(Equal(ServerAddress("wss://a")))
+		Expect(hs.Standby()).To(Equal(ServerAddress("wss://b")))
+
+		Expect(hs.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(connsTo("wss://a")[0].WriteCallCount()).To(Equal(1))
+		Expect(connsTo("wss://b")[0].WriteCallCount()).To(BeZero())
+	})
+
+	It("fails over to the standby without dialing", func() {
+		connsTo("wss://a")[0].WriteReturns(0, errors.New("nope"))
+
+		start := time.Now()
+		Expect(hs.SendRaw([]byte("oi"))).To(Succeed())
+		elapsed := time.Since(start)
+
+		GinkgoWriter.Printf("failover took %s, dialing takes %s\n", elapsed, dialDelay)
+		Expect(elapsed).To(BeNumerically("<", dialDelay/2))
+
+		Expect(hs.Primary()).To(Equal(ServerAddress("wss://b")))
+		Expect(connsTo("wss://b")[0].WriteCallCount()).To(Equal(1))
+		Eventually(connsTo("wss://a")[0].CloseCallCount).Should(Equal(1))
+	})
+
+	It("dials a new standby after failing over", func() {
+		connsTo("wss://a")[0].WriteReturns(0, errors.New("nope"))
+		Expect(hs.SendRaw([]byte("oi"))).To(Succeed())
+
+		Expect(hs.Standby()).To(BeEmpty())
+		Eventually(hs.Standby).Should(Equal(ServerAddress("wss://c")))
+
+		connsTo("wss://b")[0].WriteReturns(0, errors.New("nope"))
+		Expect(hs.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(hs.Primary()).To(Equal(ServerAddress("wss://c")))
+		Eventually(hs.Standby).Should(Equal(ServerAddress("wss://a")))
+	})
+
+	It("fails the send while there is no standby", func() {
+		connsTo("wss://a")[0].WriteReturns(0, errors.New("nope"))
+		Expect(hs.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(hs.Standby()).To(BeEmpty())
+
+		connsTo("wss://b")[0].WriteReturns(0, errors.New("nope"))
+		Expect(hs.SendRaw([]byte("oi"))).To(MatchError("nope"))
+		Expect(hs.Primary()).To(Equal(ServerAddress("wss://b")))
+	})
+
+	It("replaces a standby whose connection has closed", func() {
+		connsTo("wss://b")[0].ClosedReturns(true)
+		connsTo("wss://a")[0].WriteReturns(0, errors.New("nope"))
+
+		Expect(hs.SendRaw([]byte("oi"))).To(MatchError("nope"))
+		Expect(hs.Primary()).To(Equal(ServerAddress("wss://a")))
+		Eventually(hs.Standby).Should(Equal(ServerAddress("wss://c")))
+	})
+
+	When("a standby does not connect", func() {
+		var failed chan ServerAddress
+
+		BeforeEach(func() {
+			dialErrs["wss://b"] = errors.New("nope")
+			failed = make(chan ServerAddress, 10)
+			hs.OnStandbyError = func(address ServerAddress, err error) {
+				failed <- address
+			}
+		})
+
+		It("tries the next server", func() {
+			Expect(failed).To(Receive(Equal(ServerAddress("wss://b"))))
+			Expect(hs.Standby()).To(Equal(ServerAddress("wss://c")))
+		})
+	})
+})
@@ -0,0 +1,307 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package http sends Fluent Forward messages in HTTP/1.1 POSTs, for
+// networks that allow plain HTTPS but block websocket upgrades.
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	DefaultFlushInterval = time.Second
+	DefaultFlushBytes    = 1 << 20
+	DefaultMaxAttempts   = 4
+	// maxErrorBody is how much of a failed response's body is kept in its
+	// ResponseError.
+	maxErrorBody = 4 << 10
+)
+
+// ResponseError is returned when the server responds to a POST with a
+// status other than 2xx.
+type ResponseError struct {
+	StatusCode int
+	// Body is the start of the response body, which usually says what
+	// went wrong.
+	Body string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("http status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("http status %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the request may succeed if repeated, which is
+// the case for server errors.
+func (e *ResponseError) Retryable() bool {
+	return e.StatusCode >= 500
+}
+
+// Is makes the error match client.ErrRetriable or client.ErrPermanent, as
+// Retryable reports.
+func (e *ResponseError) Is(target error) bool {
+	if e.Retryable() {
+		return target == client.ErrRetriable
+	}
+
+	return target == client.ErrPermanent
+}
+
+type ConnectionOptions struct {
+	URL           string
+	Client        *nethttp.Client
+	Header        nethttp.Header
+	FlushInterval time.Duration
+	FlushBytes    int
+	RetryPolicy   client.RetryPolicy
+}
+
+// HTTPChunkedTransport collects encoded messages and POSTs them to URL as
+// a single MessagePack stream, using chunked transfer encoding, once
+// FlushBytes have been collected or FlushInterval has passed, whichever is
+// first, and on Flush and Close.
+//
+// A POST that fails with a 5xx status or a network error is retried as
+// RetryPolicy directs; one that fails otherwise is not. A batch that still
+// fails is dropped, and the error returned by the send or Flush that sent
+// it, or passed to OnFlushError for the periodic flushes.
+type HTTPChunkedTransport struct {
+	// URL is the endpoint the batches are POSTed to.
+	URL string
+	// Client makes the requests. If nil, http.DefaultClient is used.
+	Client *nethttp.Client
+	// Header is added to every request, e.g. for authorization.
+	Header nethttp.Header
+	// FlushInterval is the longest a message waits to be sent. If zero,
+	// DefaultFlushInterval is used.
+	FlushInterval time.Duration
+	// FlushBytes is the batch size that is sent at once. If zero,
+	// DefaultFlushBytes is used.
+	FlushBytes int
+	// RetryPolicy spaces out the attempts to POST a batch. If nil, a
+	// DefaultExponentialBackoff making DefaultMaxAttempts is used.
+	RetryPolicy client.RetryPolicy
+	// OnFlushError, if not nil, is called when a periodic flush fails.
+	OnFlushError func(err error)
+	batch        bytes.Buffer
+	lock         sync.Mutex
+	sendLock     sync.Mutex
+	stop         chan struct{}
+	flushes      sync.WaitGroup
+}
+
+func New(opts ConnectionOptions) *HTTPChunkedTransport {
+	return &HTTPChunkedTransport{
+		URL:           opts.URL,
+		Client:        opts.Client,
+		Header:        opts.Header,
+		FlushInterval: opts.FlushInterval,
+		FlushBytes:    opts.FlushBytes,
+		RetryPolicy:   opts.RetryPolicy,
+	}
+}
+
+// Send adds e to the batch.
+func (t *HTTPChunkedTransport) Send(e protocol.ChunkEncoder) error {
+	var buf bytes.Buffer
+
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+
+	return t.SendRaw(buf.Bytes())
+}
+
+// SendMessage adds a single event in Message mode to the batch.
+func (t *HTTPChunkedTransport) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessage(tag, record))
+}
+
+// SendRaw adds raw, which must be an encoded forward protocol message, to
+// the batch. If that fills the batch, it is sent before SendRaw returns.
+func (t *HTTPChunkedTransport) SendRaw(raw []byte) error {
+	t.lock.Lock()
+
+	t.batch.Write(raw)
+
+	if t.stop == nil {
+		t.stop = make(chan struct{})
+		t.flushes.Add(1)
+
+		go t.flushEvery(t.stop)
+	}
+
+	full := t.batch.Len() >= t.flushBytes()
+	t.lock.Unlock()
+
+	if full {
+		return t.Flush()
+	}
+
+	return nil
+}
+
+func (t *HTTPChunkedTransport) flushBytes() int {
+	if t.FlushBytes <= 0 {
+		return DefaultFlushBytes
+	}
+
+	return t.FlushBytes
+}
+
+// Flush sends the batch now.
+func (t *HTTPChunkedTransport) Flush() error {
+	t.sendLock.Lock()
+	defer t.sendLock.Unlock()
+
+	t.lock.Lock()
+	batch := append([]byte(nil), t.batch.Bytes()...)
+	t.batch.Reset()
+	t.lock.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return t.post(batch)
+}
+
+func (t *HTTPChunkedTransport) retryPolicy() client.RetryPolicy {
+	if t.RetryPolicy == nil {
+		return &client.DefaultExponentialBackoff{Attempts: DefaultMaxAttempts}
+	}
+
+	return t.RetryPolicy
+}
+
+// post sends batch, retrying as the RetryPolicy directs. Holding sendLock
+// throughout keeps the batches in order.
+func (t *HTTPChunkedTransport) post(batch []byte) error {
+	policy := t.retryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		err := t.postOnce(batch)
+		if err == nil {
+			return nil
+		}
+
+		if client.IsPermanent(err) {
+			return err
+		}
+
+		if max := policy.MaxAttempts(); max > 0 && attempt >= max {
+			return fmt.Errorf("post failed after %d attempts: %w", attempt, err)
+		}
+
+		time.Sleep(policy.NextDelay(attempt))
+	}
+}
+
+func (t *HTTPChunkedTransport) postOnce(batch []byte) error {
+	// a body of unknown length is sent chunked
+	req, err := nethttp.NewRequest(nethttp.MethodPost, t.URL, io.NopCloser(bytes.NewReader(batch)))
+	if err != nil {
+		return err
+	}
+
+	for key, values := range t.Header {
+		req.Header[key] = values
+	}
+
+	req.Header.Set("Content-Type", client.MsgpackContentType)
+
+	hc := t.Client
+	if hc == nil {
+		hc = nethttp.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	return &ResponseError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(body))}
+}
+
+func (t *HTTPChunkedTransport) flushEvery(stop chan struct{}) {
+	defer t.flushes.Done()
+
+	interval := t.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := t.Flush(); err != nil && t.OnFlushError != nil {
+			t.OnFlushError(err)
+		}
+	}
+}
+
+// Close stops the periodic flushes and sends the batch. The transport may
+// be used again afterwards.
+func (t *HTTPChunkedTransport) Close() error {
+	t.lock.Lock()
+	stop := t.stop
+	t.stop = nil
+	t.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		t.flushes.Wait()
+	}
+
+	return t.Flush()
+}
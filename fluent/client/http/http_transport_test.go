@@ -0,0 +1,199 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package http_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/client/http"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+type post struct {
+	header   nethttp.Header
+	encoding []string
+	tags     []string
+}
+
+var _ = Describe("HTTPChunkedTransport", func() {
+	var (
+		lock      sync.Mutex
+		posts     []post
+		failures  []int
+		svr       *httptest.Server
+		transport *HTTPChunkedTransport
+	)
+
+	received := func() []post {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]post(nil), posts...)
+	}
+
+	BeforeEach(func() {
+		posts, failures = nil, nil
+
+		svr = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			defer GinkgoRecover()
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if len(failures) > 0 {
+				status := failures[0]
+				failures = failures[1:]
+				nethttp.Error(w, "buffer is full", status)
+
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			p := post{header: r.Header, encoding: r.TransferEncoding}
+
+			for rd := msgp.NewReader(bytes.NewReader(body)); ; {
+				var msg protocol.Message
+				if err := msg.DecodeMsg(rd); err != nil {
+					Expect(errors.Is(err, io.EOF)).To(BeTrue())
+					break
+				}
+
+				p.tags = append(p.tags, msg.Tag)
+			}
+
+			posts = append(posts, p)
+		}))
+
+		transport = New(ConnectionOptions{
+			URL:           svr.URL,
+			Header:        nethttp.Header{"Authorization": {"Bearer oi"}},
+			FlushInterval: time.Hour,
+			FlushBytes:    1 << 10,
+			RetryPolicy: &client.DefaultExponentialBackoff{
+				BaseDelay: time.Millisecond,
+				Attempts:  3,
+			},
+		})
+	})
+
+	AfterEach(func() {
+		lock.Lock()
+		failures = nil
+		lock.Unlock()
+
+		Expect(transport.Close()).To(Succeed())
+		svr.Close()
+	})
+
+	It("is a MessageSender", func() {
+		var sender client.MessageSender = transport
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("POSTs the batch chunked on Flush", func() {
+		Expect(transport.SendMessage("foo", "oi")).To(Succeed())
+		Expect(transport.SendMessage("bar", "oi")).To(Succeed())
+		Expect(received()).To(BeEmpty())
+
+		Expect(transport.Flush()).To(Succeed())
+
+		p := received()
+		Expect(p).To(HaveLen(1))
+		Expect(p[0].tags).To(Equal([]string{"foo", "bar"}))
+		Expect(p[0].encoding).To(Equal([]string{"chunked"}))
+		Expect(p[0].header.Get("Content-Type")).To(Equal(client.MsgpackContentType))
+		Expect(p[0].header.Get("Authorization")).To(Equal("Bearer oi"))
+	})
+
+	It("POSTs once FlushBytes are batched", func() {
+		record := string(make([]byte, 600))
+
+		Expect(transport.SendMessage("foo", record)).To(Succeed())
+		Expect(received()).To(BeEmpty())
+
+		Expect(transport.SendMessage("bar", record)).To(Succeed())
+		Expect(received()).To(HaveLen(1))
+	})
+
+	It("POSTs every FlushInterval", func() {
+		transport.FlushInterval = 10 * time.Millisecond
+
+		Expect(transport.SendMessage("foo", "oi")).To(Succeed())
+		Eventually(received).Should(HaveLen(1))
+	})
+
+	It("does not POST an empty batch", func() {
+		Expect(transport.Flush()).To(Succeed())
+		Expect(received()).To(BeEmpty())
+	})
+
+	It("retries server errors", func() {
+		failures = []int{503, 500}
+
+		Expect(transport.SendMessage("foo", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+		Expect(received()).To(HaveLen(1))
+	})
+
+	It("gives up after the policy's attempts", func() {
+		failures = []int{503, 503, 503}
+
+		Expect(transport.SendMessage("foo", "oi")).To(Succeed())
+
+		err := transport.Flush()
+		Expect(err).To(MatchError(ContainSubstring("after 3 attempts: http status 503: buffer is full")))
+
+		var re *ResponseError
+		Expect(errors.As(err, &re)).To(BeTrue())
+		Expect(re.StatusCode).To(Equal(503))
+
+		// the batch is dropped
+		Expect(transport.Flush()).To(Succeed())
+		Expect(received()).To(BeEmpty())
+	})
+
+	It("does not retry client errors", func() {
+		failures = []int{400, 400}
+
+		Expect(transport.SendMessage("foo", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(MatchError("http status 400: buffer is full"))
+
+		lock.Lock()
+		Expect(failures).To(HaveLen(1))
+		lock.Unlock()
+	})
+})
@@ -0,0 +1,195 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultJWTLeadTime is how long before its expiry JWTAuth replaces a
+// token, if LeadTime is zero.
+const DefaultJWTLeadTime = 30 * time.Second
+
+// TokenSource returns a new JWT each time it is called, like the
+// TokenSource of golang.org/x/oauth2.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// JWTAuth sends a JWT from Source in the Authorization header as a bearer
+// token. The token is kept until LeadTime before the time in its "exp"
+// claim, and a token without one is kept for good. Headers fetches a new
+// token when it needs one; StartAutoRefresh fetches them in the background
+// ahead of time instead, with the same goroutine IAMAuthInfo uses, so that
+// dials do not wait for Source.
+type JWTAuth struct {
+	Source TokenSource
+	// LeadTime is how long before its expiry a token is replaced. If zero,
+	// DefaultJWTLeadTime is used.
+	LeadTime time.Duration
+	// OnRefreshError, if not nil, is called with every error Source
+	// returns to the StartAutoRefresh goroutine.
+	OnRefreshError func(err error)
+	// RefreshRetryInterval is how long the StartAutoRefresh goroutine waits
+	// after a failed fetch, and the least it waits between fetches. If zero,
+	// DefaultIAMRefreshRetryInterval is used.
+	RefreshRetryInterval time.Duration
+	info                 IAMAuthInfo
+	fetchLock            sync.Mutex
+}
+
+func NewJWTAuth(source TokenSource) *JWTAuth {
+	return &JWTAuth{Source: source, LeadTime: DefaultJWTLeadTime}
+}
+
+func (a *JWTAuth) Headers(_ context.Context) (http.Header, error) {
+	token, err := a.token()
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set(AuthorizationHeader, "Bearer "+token)
+
+	return header, nil
+}
+
+func (a *JWTAuth) leadTime() time.Duration {
+	if a.LeadTime <= 0 {
+		return DefaultJWTLeadTime
+	}
+
+	return a.LeadTime
+}
+
+// token returns the cached token, or a new one from Source if it is due
+// to be replaced.
+func (a *JWTAuth) token() (string, error) {
+	if token, ok := a.cached(); ok {
+		return token, nil
+	}
+
+	a.fetchLock.Lock()
+	defer a.fetchLock.Unlock()
+
+	// another caller may have fetched one while this one waited
+	if token, ok := a.cached(); ok {
+		return token, nil
+	}
+
+	token, expiresAt, err := a.fetch(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	a.info.SetIAMTokenWithExpiry(token, expiresAt)
+
+	return token, nil
+}
+
+func (a *JWTAuth) cached() (string, bool) {
+	a.info.mutex.RLock()
+	defer a.info.mutex.RUnlock()
+
+	if a.info.token == "" {
+		return "", false
+	}
+
+	if !a.info.ExpiresAt.IsZero() && !time.Now().Before(a.info.ExpiresAt.Add(-a.leadTime())) {
+		return "", false
+	}
+
+	return a.info.token, true
+}
+
+// fetch is the IAMRefreshFunc of the token cache.
+func (a *JWTAuth) fetch(_ context.Context) (string, time.Time, error) {
+	token, err := a.Source.Token()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("get JWT: %w", err)
+	}
+
+	expiresAt, err := jwtExpiry(token)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// StartAutoRefresh starts a goroutine that fetches a token now, and a new
+// one LeadTime before each expires, as IAMAuthInfo.StartAutoRefresh does.
+func (a *JWTAuth) StartAutoRefresh(ctx context.Context) {
+	a.info.RefreshFunc = a.fetch
+	a.info.OnRefreshError = a.OnRefreshError
+	a.info.RefreshRetryInterval = a.RefreshRetryInterval
+	a.info.StartAutoRefresh(ctx, a.leadTime())
+}
+
+// StopAutoRefresh stops the goroutine started by StartAutoRefresh.
+func (a *JWTAuth) StopAutoRefresh() {
+	a.info.StopAutoRefresh()
+}
+
+// jwtExpiry returns the time in the "exp" claim of token, which is not
+// verified, or the zero time if it has none.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("malformed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed JWT payload: %w", err)
+	}
+
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed JWT exp claim: %w", err)
+	}
+
+	return time.Unix(0, int64(exp*float64(time.Second))), nil
+}
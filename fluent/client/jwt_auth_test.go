@@ -0,0 +1,165 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// makeJWT returns an unsigned JWT with the given claims.
+func makeJWT(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"none"}`)) + "." + enc.EncodeToString([]byte(claims)) + "."
+}
+
+// countingSource returns a JWT expiring ttl from now, numbered by the call.
+type countingSource struct {
+	lock  sync.Mutex
+	ttl   time.Duration
+	calls int
+	err   error
+}
+
+func (s *countingSource) Token() (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+
+	return makeJWT(fmt.Sprintf(`{"n":%d,"exp":%d}`, s.calls, time.Now().Add(s.ttl).Unix())), nil
+}
+
+func (s *countingSource) Calls() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.calls
+}
+
+var _ = Describe("JWTAuth", func() {
+	var (
+		ctx    = context.Background()
+		source *countingSource
+		auth   *JWTAuth
+	)
+
+	bearer := func() string {
+		header, err := auth.Headers(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		return header.Get(AuthorizationHeader)
+	}
+
+	BeforeEach(func() {
+		source = &countingSource{ttl: time.Hour}
+		auth = NewJWTAuth(source)
+	})
+
+	It("sends the token as a bearer token", func() {
+		Expect(bearer()).To(MatchRegexp(`^Bearer [\w-]+\.[\w-]+\.$`))
+	})
+
+	It("keeps the token until LeadTime before it expires", func() {
+		first := bearer()
+		Expect(bearer()).To(Equal(first))
+		Expect(source.Calls()).To(Equal(1))
+
+		auth.LeadTime = 2 * time.Hour
+		Expect(bearer()).ToNot(Equal(first))
+		Expect(source.Calls()).To(Equal(2))
+	})
+
+	It("keeps a token without an exp claim", func() {
+		auth.Source = tokenFunc(func() (string, error) { return makeJWT(`{"sub":"me"}`), nil })
+
+		first := bearer()
+		Expect(bearer()).To(Equal(first))
+	})
+
+	It("fails for a malformed token or a failing source", func() {
+		auth.Source = tokenFunc(func() (string, error) { return "not-a-jwt", nil })
+		_, err := auth.Headers(ctx)
+		Expect(err).To(MatchError(ContainSubstring("malformed JWT")))
+
+		source.err = errors.New("nope")
+		auth.Source = source
+		_, err = auth.Headers(ctx)
+		Expect(err).To(MatchError(ContainSubstring("nope")))
+	})
+
+	It("fetches one token for concurrent dials", func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				_, err := auth.Headers(ctx)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+		}
+
+		wg.Wait()
+		Expect(source.Calls()).To(Equal(1))
+	})
+
+	It("refreshes the token in the background", func() {
+		source.ttl = 3 * time.Second
+		auth.LeadTime = time.Second
+		auth.RefreshRetryInterval = 10 * time.Millisecond
+
+		auth.StartAutoRefresh(ctx)
+		defer auth.StopAutoRefresh()
+
+		Eventually(source.Calls).Should(Equal(1))
+
+		first := bearer()
+		Expect(source.Calls()).To(Equal(1))
+
+		Eventually(source.Calls, 4*time.Second).Should(BeNumerically(">=", 2))
+		Expect(bearer()).ToNot(Equal(first))
+	})
+})
+
+type tokenFunc func() (string, error)
+
+func (f tokenFunc) Token() (string, error) {
+	return f()
+}
@@ -0,0 +1,145 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+)
+
+// MessageHandler is called by ListenWith with each message received on
+// the connection.
+type MessageHandler func(msg []byte) error
+
+// MultiHandler returns a MessageHandler that calls each handler in order,
+// stopping at the first one that returns an error.
+func MultiHandler(handlers ...MessageHandler) MessageHandler {
+	return func(msg []byte) error {
+		for _, h := range handlers {
+			if err := h(msg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// listener is a MessageHandler registered by ListenWith.
+type listener struct {
+	handler MessageHandler
+	done    chan error
+	lock    sync.Mutex
+	stopped bool
+}
+
+// deliver passes a message to the handler. The first error, whether
+// from the connection or the handler, stops the listener.
+func (l *listener) deliver(p []byte, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped {
+		return
+	}
+
+	if err == nil {
+		err = l.handler(p)
+	}
+
+	if err != nil {
+		l.stopped = true
+		l.done <- err
+	}
+}
+
+// ListenWith calls handler with each message received on the current
+// connection until the context is done, the handler returns an error, or
+// the connection fails, and returns the error that ended it. Messages are
+// delivered from the connection's read loop, so a handler that blocks
+// holds up reading; any ReadHandler in ConnectionOptions is still called.
+// In AckMode, the server's acks are matched to the SendMessageAck calls
+// waiting on them instead of being passed to handler. Only one ListenWith
+// may be active at a time.
+func (c *WSClient) ListenWith(ctx context.Context, handler MessageHandler) error {
+	if session := c.Session(); session == nil || session.Connection.Closed() {
+		return errors.New("no active session")
+	}
+
+	l := &listener{handler: handler, done: make(chan error, 1)}
+
+	c.listenerLock.Lock()
+	if c.listener != nil {
+		c.listenerLock.Unlock()
+		return errors.New("already listening")
+	}
+
+	c.listener = l
+	c.listenerLock.Unlock()
+
+	defer func() {
+		c.listenerLock.Lock()
+		c.listener = nil
+		c.listenerLock.Unlock()
+	}()
+
+	select {
+	case err := <-l.done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("listen: %w", ctx.Err())
+	}
+}
+
+func (c *WSClient) currentListener() *listener {
+	c.listenerLock.Lock()
+	defer c.listenerLock.Unlock()
+
+	return c.listener
+}
+
+// listenReadHandler passes messages to the handler registered by
+// ListenWith before calling next.
+func (c *WSClient) listenReadHandler(next ws.ReadHandler) ws.ReadHandler {
+	return func(conn ws.Connection, messageType int, p []byte, err error) error {
+		if l := c.currentListener(); l != nil {
+			l.deliver(p, err)
+		}
+
+		if next != nil {
+			return next(conn, messageType, p, err)
+		}
+
+		if err != nil {
+			_ = conn.Close()
+		}
+
+		return err
+	}
+}
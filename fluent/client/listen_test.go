@@ -0,0 +1,178 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+var _ = Describe("MultiHandler", func() {
+	It("calls the handlers in order until one fails", func() {
+		var calls []string
+
+		record := func(name string, err error) MessageHandler {
+			return func(msg []byte) error {
+				calls = append(calls, name+":"+string(msg))
+				return err
+			}
+		}
+
+		h := MultiHandler(record("a", nil), record("b", errors.New("nope")), record("c", nil))
+
+		Expect(h([]byte("oi"))).To(MatchError("nope"))
+		Expect(calls).To(Equal([]string{"a:oi", "b:oi"}))
+	})
+})
+
+var _ = Describe("WSClient ListenWith", func() {
+	var (
+		svr *httptest.Server
+		cli *WSClient
+	)
+
+	BeforeEach(func() {
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+
+			defer wc.Close()
+
+			for {
+				mt, p, err := wc.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				if string(p) == "bye" {
+					return
+				}
+
+				if err = wc.WriteMessage(mt, p); err != nil {
+					return
+				}
+			}
+		}))
+
+		cli = NewWS(WSConnectionOptions{
+			Factory: &DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = cli.Disconnect()
+		svr.Close()
+	})
+
+	It("passes each message to the handler until it fails", func() {
+		received := make(chan string, 3)
+		errStop := errors.New("stop")
+		done := make(chan error, 1)
+
+		go func() {
+			done <- cli.ListenWith(context.Background(), func(msg []byte) error {
+				received <- string(msg)
+
+				if string(msg) == "2" {
+					return errStop
+				}
+
+				return nil
+			})
+		}()
+
+		// the handler is registered once the first echo arrives
+		Eventually(func() string {
+			Expect(cli.SendRaw([]byte("1"))).ToNot(HaveOccurred())
+			select {
+			case m := <-received:
+				return m
+			case <-time.After(10 * time.Millisecond):
+				return ""
+			}
+		}).Should(Equal("1"))
+
+		// drop the echoes of any extra attempts
+		time.Sleep(20 * time.Millisecond)
+
+		for len(received) > 0 {
+			Expect(<-received).To(Equal("1"))
+		}
+
+		Expect(cli.ListenWith(context.Background(), nil)).To(MatchError("already listening"))
+
+		for _, m := range []string{"2", "3"} {
+			Expect(cli.SendRaw([]byte(m))).ToNot(HaveOccurred())
+		}
+
+		Eventually(done).Should(Receive(MatchError(errStop)))
+		Expect(received).To(Receive(Equal("2")))
+		Consistently(received).ShouldNot(Receive())
+	})
+
+	It("returns when the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err := cli.ListenWith(ctx, func([]byte) error { return nil })
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+	})
+
+	It("returns when the connection fails", func() {
+		done := make(chan error, 1)
+
+		go func() {
+			done <- cli.ListenWith(context.Background(), func([]byte) error { return nil })
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		Expect(cli.SendRaw([]byte("bye"))).ToNot(HaveOccurred())
+
+		Eventually(done).Should(Receive(HaveOccurred()))
+	})
+
+	It("requires a session", func() {
+		Expect(cli.Disconnect()).ToNot(HaveOccurred())
+		Expect(cli.ListenWith(context.Background(), nil)).To(MatchError("no active session"))
+	})
+})
@@ -0,0 +1,92 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"log"
+)
+
+// Logger receives the client's diagnostics, such as reconnect attempts,
+// send failures and dropped messages. Adapt structured loggers such as
+// zap or logrus by implementing it. The client never logs while holding
+// one of its locks, so a Logger may call back into the client.
+type Logger interface {
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// NoopLogger discards everything. It is used when no Logger is set.
+type NoopLogger struct{}
+
+func (NoopLogger) Debugf(_ string, _ ...interface{}) {}
+
+func (NoopLogger) Infof(_ string, _ ...interface{}) {}
+
+func (NoopLogger) Warnf(_ string, _ ...interface{}) {}
+
+func (NoopLogger) Errorf(_ string, _ ...interface{}) {}
+
+// StandardLogger is a Logger that writes to a log.Logger, prefixing each
+// line with its level. Debug lines are written only if Debug is set.
+type StandardLogger struct {
+	Logger *log.Logger
+	Debug  bool
+}
+
+// NewStandardLogger returns a StandardLogger writing to l, or to the
+// standard logger if l is nil.
+func NewStandardLogger(l *log.Logger) *StandardLogger {
+	if l == nil {
+		l = log.Default()
+	}
+
+	return &StandardLogger{Logger: l}
+}
+
+func (l *StandardLogger) output(level, format string, v ...interface{}) {
+	// skip output and the Logger method when reporting the caller
+	_ = l.Logger.Output(3, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (l *StandardLogger) Debugf(format string, v ...interface{}) {
+	if l.Debug {
+		l.output("DEBUG", format, v...)
+	}
+}
+
+func (l *StandardLogger) Infof(format string, v ...interface{}) {
+	l.output("INFO", format, v...)
+}
+
+func (l *StandardLogger) Warnf(format string, v ...interface{}) {
+	l.output("WARN", format, v...)
+}
+
+func (l *StandardLogger) Errorf(format string, v ...interface{}) {
+	l.output("ERROR", format, v...)
+}
@@ -0,0 +1,174 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// recordingLogger keeps every line logged, prefixed with its level.
+type recordingLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(level, format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Lines() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return append([]string(nil), l.lines...)
+}
+
+func (l *recordingLogger) Debugf(format string, v ...interface{}) { l.record("DEBUG", format, v...) }
+
+func (l *recordingLogger) Infof(format string, v ...interface{}) { l.record("INFO", format, v...) }
+
+func (l *recordingLogger) Warnf(format string, v ...interface{}) { l.record("WARN", format, v...) }
+
+func (l *recordingLogger) Errorf(format string, v ...interface{}) { l.record("ERROR", format, v...) }
+
+var _ = Describe("Logger", func() {
+	Describe("StandardLogger", func() {
+		var (
+			out    *bytes.Buffer
+			logger *StandardLogger
+		)
+
+		BeforeEach(func() {
+			out = &bytes.Buffer{}
+			logger = NewStandardLogger(log.New(out, "", log.Lshortfile))
+		})
+
+		It("prefixes lines with their level and the caller", func() {
+			logger.Infof("oi %d", 1)
+			logger.Warnf("oi %d", 2)
+			logger.Errorf("oi %d", 3)
+
+			Expect(out.String()).To(MatchRegexp(
+				`^logger_test.go:\d+: INFO oi 1\nlogger_test.go:\d+: WARN oi 2\nlogger_test.go:\d+: ERROR oi 3\n$`))
+		})
+
+		It("writes debug lines only when Debug is set", func() {
+			logger.Debugf("oi")
+			Expect(out.Len()).To(BeZero())
+
+			logger.Debug = true
+			logger.Debugf("oi")
+			Expect(out.String()).To(ContainSubstring("DEBUG oi"))
+		})
+	})
+
+	Describe("WSClient logging", func() {
+		var (
+			factory *clientfakes.FakeWSConnectionFactory
+			conn    *wsfakes.FakeConnection
+			logger  *recordingLogger
+			wc      *WSClient
+		)
+
+		BeforeEach(func() {
+			factory = &clientfakes.FakeWSConnectionFactory{}
+			conn = &wsfakes.FakeConnection{}
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			logger = &recordingLogger{}
+			wc = NewWS(WSConnectionOptions{
+				Factory: factory,
+				RetryPolicy: &DefaultExponentialBackoff{
+					BaseDelay: time.Millisecond,
+					Attempts:  2,
+				},
+			})
+			wc.Logger = logger
+		})
+
+		It("logs connects, disconnects and failed sends", func() {
+			Expect(wc.Connect()).To(Succeed())
+
+			conn.WriteReturns(0, errors.New("nope"))
+			Expect(wc.Send(protocol.NewMessage("foo", nil))).ToNot(Succeed())
+
+			Expect(wc.Disconnect()).To(Succeed())
+
+			Expect(logger.Lines()).To(Equal([]string{
+				"INFO connected",
+				`WARN send "foo" failed: nope`,
+				"INFO disconnected",
+			}))
+		})
+
+		It("logs reconnect attempts", func() {
+			factory.NewReturns(nil, errors.New("nope"))
+
+			Expect(wc.ReconnectWithRetry(context.Background())).ToNot(Succeed())
+
+			Expect(logger.Lines()).To(ConsistOf(
+				MatchRegexp(`^WARN reconnect attempt 1 failed, retrying in .*: nope$`),
+				"ERROR reconnect failed after 2 attempts: nope",
+			))
+		})
+
+		It("logs errors that end Listen", func() {
+			conn.ListenReturns(errors.New("nope"))
+
+			Expect(wc.Connect()).To(Succeed())
+			Eventually(logger.Lines).Should(ContainElement("ERROR connection failed: nope"))
+			Expect(wc.Disconnect()).To(Succeed())
+		})
+
+		It("logs messages dropped from a full buffer", func() {
+			wc.Buffer = BufferOptions{Enabled: true, MaxBufferSize: 1, OnBufferFull: BufferDropNewest}
+
+			Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+			Expect(wc.Send(protocol.NewMessage("bar", nil))).To(Succeed())
+
+			Expect(logger.Lines()).To(Equal([]string{
+				"WARN message buffer is full, dropped the newest message",
+			}))
+		})
+	})
+})
@@ -0,0 +1,112 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// limitWriter fails a write that would take the total past limit.
+type limitWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.written+int64(len(p)) > lw.limit {
+		return 0, fmt.Errorf("%w: exceeds %d bytes", ErrMessageTooLarge, lw.limit)
+	}
+
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+
+	return n, err
+}
+
+// maxPooledBufferSize is the capacity above which an encode buffer is
+// left for the garbage collector rather than pooled, so that one large
+// message does not pin its memory for good.
+const maxPooledBufferSize = 64 * 1024
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getEncodeBuffer returns an empty buffer from the pool. Messages are
+// encoded into pooled buffers so that sending does not allocate.
+func getEncodeBuffer() *bytes.Buffer {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+
+	return buf
+}
+
+// putEncodeBuffer returns buf to the pool. Its contents must no longer be
+// referenced.
+func putEncodeBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		encodeBuffers.Put(buf)
+	}
+}
+
+// encode encodes e into buf with the client's Codec, or with its own
+// EncodeTo if it was sent as a MessageEncoder. If MaxMessageBytes is set,
+// encoding stops as soon as the message exceeds it and ErrMessageTooLarge
+// is returned.
+func (c *WSClient) encode(buf *bytes.Buffer, e msgp.Encodable) error {
+	var w io.Writer = buf
+	if limit := c.maxMessageBytes(); limit > 0 {
+		w = &limitWriter{w: buf, limit: limit}
+	}
+
+	if ce, ok := e.(customEncodable); ok {
+		return ce.EncodeTo(w)
+	}
+
+	codec := c.Codec
+	if codec == nil {
+		codec = MsgpackCodec{}
+	}
+
+	return codec.Encode(w, e)
+}
+
+// checkSize returns ErrMessageTooLarge if a message of n bytes exceeds
+// MaxMessageBytes.
+func (c *WSClient) checkSize(n int) error {
+	if limit := c.maxMessageBytes(); limit > 0 && int64(n) > limit {
+		return fmt.Errorf("%w: %d bytes exceeds %d bytes", ErrMessageTooLarge, n, limit)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// MetricsCollector receives measurements from WSClient. Implementations
+// must be safe for concurrent use. See the metrics package for a
+// Prometheus implementation.
+//
+//counterfeiter:generate . MetricsCollector
+type MetricsCollector interface {
+	// RecordSend is called after every send attempt with the message tag,
+	// if one could be determined, the time spent, and the resulting error.
+	RecordSend(tag string, duration time.Duration, err error)
+	// RecordReconnect is called after every reconnect attempt. Attempts
+	// are numbered from 1.
+	RecordReconnect(attempt int, err error)
+}
+
+// PanicCollector may be implemented by a MetricsCollector to count the
+// ReadHandler panics WSClient recovers from.
+type PanicCollector interface {
+	RecordPanic()
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RecordSend(_ string, _ time.Duration, _ error) {}
+
+func (noopMetrics) RecordReconnect(_ int, _ error) {}
+
+// MessageTag returns the tag of the message, or an empty string if the
+// type is not one of the protocol messages.
+func MessageTag(e msgp.Encodable) string {
+	switch msg := e.(type) {
+	case *protocol.Message:
+		return msg.Tag
+	case *protocol.MessageExt:
+		return msg.Tag
+	case *protocol.ForwardMessage:
+		return msg.Tag
+	case *protocol.PackedForwardMessage:
+		return msg.Tag
+	case *protocol.CompressedPackedForwardMessage:
+		return msg.Tag
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,206 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package metrics provides client.MetricsCollector implementations.
+// Importing it registers the "prometheus" metrics provider, which makes a
+// PrometheusCollector registered with prometheus.DefaultRegisterer, for
+// clients built from a client.WSClientConfig.
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProviderName is the name the PrometheusCollector is registered under
+// with client.RegisterMetricsProvider.
+const ProviderName = "prometheus"
+
+func init() {
+	client.RegisterMetricsProvider(ProviderName, func(cfg client.MetricsConfig, server string) (client.MetricsCollector, error) {
+		return NewPrometheusCollector(PrometheusOptions{
+			Namespace:     cfg.Namespace,
+			ServerAddress: server,
+			ClientName:    cfg.ClientName,
+		})
+	})
+}
+
+const (
+	DefaultNamespace = "fluent_forward"
+
+	labelServer = "server"
+	labelClient = "client"
+	labelResult = "result"
+
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// PrometheusOptions configures a PrometheusCollector.
+type PrometheusOptions struct {
+	// Namespace prefixes every metric name. Defaults to DefaultNamespace.
+	Namespace string
+	// ServerAddress and ClientName are attached to every metric as the
+	// "server" and "client" labels.
+	ServerAddress string
+	ClientName    string
+	// Registerer is where the metrics are registered. Defaults to
+	// prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Buckets are the send duration histogram buckets, in seconds.
+	// Defaults to prometheus.DefBuckets.
+	Buckets []float64
+}
+
+// PrometheusCollector is a client.MetricsCollector that exports counters
+// of sends, reconnects and recovered panics, and a histogram of send durations. Collectors
+// sharing a namespace and Registerer share the underlying metrics and are
+// told apart by their labels. Message tags are not used as labels, to keep
+// the number of series bounded.
+type PrometheusCollector struct {
+	sends        *prometheus.CounterVec
+	sendDuration prometheus.ObserverVec
+	reconnects   *prometheus.CounterVec
+	panics       *prometheus.CounterVec
+	labels       prometheus.Labels
+}
+
+// NewPrometheusCollector creates the collector's metrics and registers
+// them, reusing any that are already registered.
+func NewPrometheusCollector(opts PrometheusOptions) (*PrometheusCollector, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+
+	pc := &PrometheusCollector{
+		labels: prometheus.Labels{
+			labelServer: opts.ServerAddress,
+			labelClient: opts.ClientName,
+		},
+	}
+
+	sends := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Name:      "sends_total",
+		Help:      "Number of messages sent, by result.",
+	}, []string{labelServer, labelClient, labelResult})
+
+	sendDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Name:      "send_duration_seconds",
+		Help:      "Time spent sending a message.",
+		Buckets:   opts.Buckets,
+	}, []string{labelServer, labelClient})
+
+	reconnects := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Name:      "reconnects_total",
+		Help:      "Number of reconnect attempts, by result.",
+	}, []string{labelServer, labelClient, labelResult})
+
+	panics := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Name:      "panics_total",
+		Help:      "Number of read handler panics recovered.",
+	}, []string{labelServer, labelClient})
+
+	var err error
+
+	if pc.sends, err = register(opts.Registerer, sends); err != nil {
+		return nil, err
+	}
+
+	if pc.sendDuration, err = register(opts.Registerer, sendDuration); err != nil {
+		return nil, err
+	}
+
+	if pc.reconnects, err = register(opts.Registerer, reconnects); err != nil {
+		return nil, err
+	}
+
+	if pc.panics, err = register(opts.Registerer, panics); err != nil {
+		return nil, err
+	}
+
+	return pc, nil
+}
+
+// register registers c, returning the existing collector instead if an
+// identical one is already registered.
+func register[C prometheus.Collector](r prometheus.Registerer, c C) (C, error) {
+	err := r.Register(c)
+	if err == nil {
+		return c, nil
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+
+	return c, err
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+
+	return resultSuccess
+}
+
+func (pc *PrometheusCollector) with(err error) prometheus.Labels {
+	return prometheus.Labels{
+		labelServer: pc.labels[labelServer],
+		labelClient: pc.labels[labelClient],
+		labelResult: result(err),
+	}
+}
+
+func (pc *PrometheusCollector) RecordSend(_ string, duration time.Duration, err error) {
+	pc.sends.With(pc.with(err)).Inc()
+	pc.sendDuration.With(pc.labels).Observe(duration.Seconds())
+}
+
+func (pc *PrometheusCollector) RecordReconnect(_ int, err error) {
+	pc.reconnects.With(pc.with(err)).Inc()
+}
+
+func (pc *PrometheusCollector) RecordPanic() {
+	pc.panics.With(pc.labels).Inc()
+}
@@ -0,0 +1,134 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/metrics"
+)
+
+var _ client.MetricsCollector = &metrics.PrometheusCollector{}
+
+var _ client.PanicCollector = &metrics.PrometheusCollector{}
+
+var _ = Describe("PrometheusCollector", func() {
+	var (
+		registry *prometheus.Registry
+		pc       *metrics.PrometheusCollector
+	)
+
+	BeforeEach(func() {
+		registry = prometheus.NewRegistry()
+
+		var err error
+		pc, err = metrics.NewPrometheusCollector(metrics.PrometheusOptions{
+			Namespace:     "test",
+			ServerAddress: "wss://example.com",
+			ClientName:    "oi",
+			Registerer:    registry,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("counts sends by result", func() {
+		pc.RecordSend("foo", time.Millisecond, nil)
+		pc.RecordSend("foo", time.Millisecond, nil)
+		pc.RecordSend("foo", time.Millisecond, errors.New("nope"))
+
+		expected := `
+# HELP test_sends_total Number of messages sent, by result.
+# TYPE test_sends_total counter
+test_sends_total{client="oi",result="error",server="wss://example.com"} 1
+test_sends_total{client="oi",result="success",server="wss://example.com"} 2
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_sends_total")).To(Succeed())
+	})
+
+	It("observes send durations", func() {
+		pc.RecordSend("foo", 2*time.Second, nil)
+
+		Expect(testutil.CollectAndCount(registry, "test_send_duration_seconds")).To(Equal(1))
+	})
+
+	It("counts reconnects by result", func() {
+		pc.RecordReconnect(1, errors.New("nope"))
+		pc.RecordReconnect(2, nil)
+
+		expected := `
+# HELP test_reconnects_total Number of reconnect attempts, by result.
+# TYPE test_reconnects_total counter
+test_reconnects_total{client="oi",result="error",server="wss://example.com"} 1
+test_reconnects_total{client="oi",result="success",server="wss://example.com"} 1
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_reconnects_total")).To(Succeed())
+	})
+
+	It("counts panics", func() {
+		pc.RecordPanic()
+
+		expected := `
+# HELP test_panics_total Number of read handler panics recovered.
+# TYPE test_panics_total counter
+test_panics_total{client="oi",server="wss://example.com"} 1
+`
+		Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_panics_total")).To(Succeed())
+	})
+
+	It("shares metrics between collectors in the same namespace", func() {
+		other, err := metrics.NewPrometheusCollector(metrics.PrometheusOptions{
+			Namespace:  "test",
+			ClientName: "other",
+			Registerer: registry,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		pc.RecordSend("foo", time.Millisecond, nil)
+		other.RecordSend("foo", time.Millisecond, nil)
+
+		Expect(testutil.CollectAndCount(registry, "test_sends_total")).To(Equal(2))
+	})
+})
+
+var _ = Describe("the prometheus metrics provider", func() {
+	It("is registered", func() {
+		cfg := client.WSClientConfig{
+			Server:  client.ServerConfig{Host: "127.0.0.1"},
+			Metrics: client.MetricsConfig{Provider: metrics.ProviderName, Namespace: "provider_test"},
+		}
+
+		c, err := cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Metrics).To(BeAssignableToTypeOf(&metrics.PrometheusCollector{}))
+	})
+})
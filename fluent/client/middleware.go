@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// SendFunc sends a single message.
+type SendFunc func(ctx context.Context, e msgp.Encodable) error
+
+// Middleware decorates a SendFunc, e.g. to log, trace or modify messages.
+// It calls next to pass the message on, or returns without calling it to
+// drop the message.
+type Middleware func(next SendFunc) SendFunc
+
+// Use adds middleware around the sending of every message by Send,
+// SendMessageContext and the methods built on them. The first middleware
+// added is the outermost. The chain is built by Connect, so middleware
+// added while connected takes effect at the next Connect or Reconnect.
+func (c *WSClient) Use(m ...Middleware) {
+	c.chainLock.Lock()
+	defer c.chainLock.Unlock()
+
+	c.middleware = append(c.middleware, m...)
+}
+
+func (c *WSClient) buildChain() SendFunc {
+	c.chainLock.Lock()
+	defer c.chainLock.Unlock()
+
+	c.chain = c.sendMessage
+
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		c.chain = c.middleware[i](c.chain)
+	}
+
+	return c.chain
+}
+
+// sendChain returns the chain built by the last Connect, building it now
+// if there has not been one, so that messages buffered before connecting
+// pass through the middleware too.
+func (c *WSClient) sendChain() SendFunc {
+	c.chainLock.RLock()
+	chain := c.chain
+	c.chainLock.RUnlock()
+
+	if chain == nil {
+		chain = c.buildChain()
+	}
+
+	return chain
+}
+
+// LoggingMiddleware logs the tag and duration of every send at debug
+// level, and failed sends at error level.
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, e msgp.Encodable) error {
+			start := time.Now()
+			err := next(ctx, e)
+
+			if err != nil {
+				logger.Errorf("send %q failed after %s: %v", MessageTag(e), time.Since(start), err)
+			} else {
+				logger.Debugf("sent %q in %s", MessageTag(e), time.Since(start))
+			}
+
+			return err
+		}
+	}
+}
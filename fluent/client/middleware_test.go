@@ -0,0 +1,121 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("Middleware", func() {
+	var (
+		conn  *wsfakes.FakeConnection
+		wc    *WSClient
+		calls []string
+	)
+
+	named := func(name string) Middleware {
+		return func(next SendFunc) SendFunc {
+			return func(ctx context.Context, e msgp.Encodable) error {
+				calls = append(calls, name)
+				return next(ctx, e)
+			}
+		}
+	}
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		wc = NewWS(WSConnectionOptions{Factory: factory})
+		calls = nil
+	})
+
+	AfterEach(func() {
+		Expect(wc.Disconnect()).To(Succeed())
+	})
+
+	It("runs the middleware in the order it was added", func() {
+		wc.Use(named("a"), named("b"))
+		wc.Use(named("c"))
+		Expect(wc.Connect()).To(Succeed())
+
+		Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+		Expect(calls).To(Equal([]string{"a", "b", "c"}))
+		Expect(conn.WriteCallCount()).To(Equal(1))
+	})
+
+	It("lets middleware drop messages", func() {
+		wc.Use(func(next SendFunc) SendFunc {
+			return func(ctx context.Context, e msgp.Encodable) error {
+				return nil
+			}
+		})
+		Expect(wc.Connect()).To(Succeed())
+
+		Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+		Expect(conn.WriteCallCount()).To(BeZero())
+	})
+
+	It("applies middleware added while connected at the next Connect", func() {
+		Expect(wc.Connect()).To(Succeed())
+		wc.Use(named("a"))
+
+		Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+		Expect(calls).To(BeEmpty())
+
+		Expect(wc.Reconnect()).To(Succeed())
+		Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+		Expect(calls).To(Equal([]string{"a"}))
+	})
+
+	Describe("LoggingMiddleware", func() {
+		It("logs the tag and result of each send", func() {
+			logger := &recordingLogger{}
+			wc.Use(LoggingMiddleware(logger))
+			Expect(wc.Connect()).To(Succeed())
+
+			Expect(wc.Send(protocol.NewMessage("foo", nil))).To(Succeed())
+
+			conn.WriteReturns(0, errors.New("nope"))
+			Expect(wc.Send(protocol.NewMessage("bar", nil))).To(HaveOccurred())
+
+			Expect(logger.Lines()).To(HaveLen(2))
+			Expect(logger.Lines()[0]).To(MatchRegexp(`^DEBUG sent "foo" in `))
+			Expect(logger.Lines()[1]).To(MatchRegexp(`^ERROR send "bar" failed after .*: nope`))
+		})
+	})
+})
@@ -0,0 +1,382 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// ConnSelector chooses which of a MultiConnClient's connections a send
+// goes to. It must be safe for concurrent use.
+type ConnSelector interface {
+	// Select returns the index of one of clients, of which there is at
+	// least one.
+	Select(clients []*WSClient) int
+}
+
+// RoundRobinSelector sends to each connection in turn.
+type RoundRobinSelector struct {
+	next uint64
+}
+
+func (s *RoundRobinSelector) Select(clients []*WSClient) int {
+	return int((atomic.AddUint64(&s.next, 1) - 1) % uint64(len(clients)))
+}
+
+// RandomSelector sends to a connection chosen at random.
+type RandomSelector struct{}
+
+func (RandomSelector) Select(clients []*WSClient) int {
+	return rand.Intn(len(clients)) //#nosec
+}
+
+// LeastBacklogSelector sends to the connection with the fewest messages
+// waiting, counting both those queued by SendMessageAsync and those
+// buffered while disconnected.
+type LeastBacklogSelector struct{}
+
+func (LeastBacklogSelector) Select(clients []*WSClient) int {
+	best, least := 0, -1
+
+	for i, wc := range clients {
+		backlog := wc.Stats().Messages + wc.Buffered()
+		if least < 0 || backlog < least {
+			best, least = i, backlog
+		}
+	}
+
+	return best
+}
+
+// MultiConnClient spreads sends across several connections at once, for
+// when a single connection, rather than the server, limits throughput.
+// Connection i goes to server i modulo the number of servers, so the
+// connections may all go to one server or be spread over several.
+//
+// A send that fails is tried on another connection, and the failed
+// connection is replaced in the background using ReconnectWithRetry.
+type MultiConnClient struct {
+	// NewClient creates the client for a connection to address.
+	NewClient func(address ServerAddress) *WSClient
+	// Selector chooses the connection for each send. If nil, a
+	// RoundRobinSelector is used.
+	Selector      ConnSelector
+	servers       []ServerAddress
+	n             int
+	clients       []*WSClient
+	lock          sync.RWMutex
+	ctx           context.Context
+	stop          context.CancelFunc
+	replaces      sync.WaitGroup
+	replacing     map[*WSClient]bool
+	replacingLock sync.Mutex
+}
+
+func NewMultiConnClient(newClient func(address ServerAddress) *WSClient, n int, servers ...ServerAddress) *MultiConnClient {
+	return &MultiConnClient{
+		NewClient: newClient,
+		Selector:  &RoundRobinSelector{},
+		servers:   servers,
+		n:         n,
+	}
+}
+
+// Concurrency returns the number of connections.
+func (c *MultiConnClient) Concurrency() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.n
+}
+
+// Connect opens the connections. It fails only if none connects; the
+// others are retried in the background.
+func (c *MultiConnClient) Connect() error {
+	if len(c.servers) == 0 {
+		return errors.New("no servers")
+	}
+
+	if c.n < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stop != nil {
+		return nil
+	}
+
+	c.ctx, c.stop = context.WithCancel(context.Background())
+
+	clients, err := c.open(0, c.n)
+	if err != nil {
+		c.stop()
+		c.ctx, c.stop = nil, nil
+
+		return err
+	}
+
+	c.clients = clients
+
+	return nil
+}
+
+// open creates and connects the clients for connections from to to. It
+// fails only if none connects. It must be called with the lock held.
+func (c *MultiConnClient) open(from, to int) ([]*WSClient, error) {
+	var (
+		clients   []*WSClient
+		failed    []*WSClient
+		err       error
+		connected int
+	)
+
+	for i := from; i < to; i++ {
+		wc := c.NewClient(c.servers[i%len(c.servers)])
+		clients = append(clients, wc)
+
+		if cerr := wc.Connect(); cerr != nil {
+			err = cerr
+			failed = append(failed, wc)
+
+			continue
+		}
+
+		connected++
+	}
+
+	if connected == 0 {
+		return nil, fmt.Errorf("connect %d connections failed: %w", to-from, err)
+	}
+
+	for _, wc := range failed {
+		c.replace(wc)
+	}
+
+	return clients, nil
+}
+
+// SetConcurrency changes the number of connections to n. New connections
+// are opened before it returns, and fail it only if none of them
+// connects. Removed connections are disconnected once the sends using
+// them have finished.
+func (c *MultiConnClient) SetConcurrency(n int) error {
+	if n < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	removed, err := c.resize(n)
+
+	// sends are made without the lock, so the removed connections are
+	// disconnected gracefully, after it is released
+	for _, wc := range removed {
+		_ = wc.GracefulDisconnect(context.Background())
+	}
+
+	return err
+}
+
+// resize changes the number of connections to n, and returns those that
+// were removed.
+func (c *MultiConnClient) resize(n int) ([]*WSClient, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var removed []*WSClient
+
+	if c.stop == nil {
+		c.n = n
+		return nil, nil
+	}
+
+	switch {
+	case n > c.n:
+		clients, err := c.open(c.n, n)
+		if err != nil {
+			return nil, err
+		}
+
+		c.clients = append(c.clients, clients...)
+	case n < c.n:
+		removed = c.clients[n:]
+		c.clients = c.clients[:n:n]
+	}
+
+	c.n = n
+
+	return removed, nil
+}
+
+// Disconnect stops replacing connections and disconnects every one.
+func (c *MultiConnClient) Disconnect() (err error) {
+	c.lock.Lock()
+	if c.stop != nil {
+		c.stop()
+		c.ctx, c.stop = nil, nil
+	}
+
+	clients := c.clients
+	c.clients = nil
+	c.lock.Unlock()
+
+	c.replaces.Wait()
+
+	for _, wc := range clients {
+		if derr := wc.Disconnect(); derr != nil {
+			err = derr
+		}
+	}
+
+	return
+}
+
+// replace reconnects wc in the background, unless it is already being
+// reconnected. It must be called with the lock, or the read lock, held.
+func (c *MultiConnClient) replace(wc *WSClient) {
+	if c.ctx == nil {
+		return
+	}
+
+	c.replacingLock.Lock()
+	defer c.replacingLock.Unlock()
+
+	if c.replacing[wc] {
+		return
+	}
+
+	if c.replacing == nil {
+		c.replacing = map[*WSClient]bool{}
+	}
+
+	c.replacing[wc] = true
+	c.replaces.Add(1)
+
+	go func(ctx context.Context) {
+		defer c.replaces.Done()
+
+		err := wc.ReconnectWithRetry(ctx)
+
+		c.replacingLock.Lock()
+		delete(c.replacing, wc)
+		c.replacingLock.Unlock()
+
+		if err == nil && !c.has(wc) {
+			// removed by SetConcurrency while reconnecting
+			_ = wc.Disconnect()
+		}
+	}(c.ctx)
+}
+
+func (c *MultiConnClient) has(wc *WSClient) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, other := range c.clients {
+		if other == wc {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *MultiConnClient) Send(e protocol.ChunkEncoder) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.Send(e)
+	})
+}
+
+func (c *MultiConnClient) SendRaw(raw []byte) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.SendRaw(raw)
+	})
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *MultiConnClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+// send sends to a snapshot of the connections, taken under the read lock,
+// so that a slow send does not hold up SetConcurrency or Disconnect. A
+// connection that SetConcurrency removes meanwhile finishes its sends in
+// progress before it is disconnected, and refuses new ones, which are
+// tried on the next connection.
+func (c *MultiConnClient) send(send func(wc *WSClient) error) error {
+	c.lock.RLock()
+	clients := c.clients
+	c.lock.RUnlock()
+
+	n := len(clients)
+	if n == 0 {
+		return errors.New("not connected")
+	}
+
+	selector := c.Selector
+	if selector == nil {
+		selector = &RoundRobinSelector{}
+	}
+
+	err := errors.New("no healthy connections")
+	start := selector.Select(clients)
+
+	for attempt := 0; attempt < n; attempt++ {
+		wc := clients[(start+attempt)%n]
+		if !healthy(wc) {
+			c.replaceMember(wc)
+			continue
+		}
+
+		if err = send(wc); err == nil {
+			return nil
+		}
+
+		c.replaceMember(wc)
+	}
+
+	return err
+}
+
+// replaceMember replaces wc, unless it has been removed from the
+// connections.
+func (c *MultiConnClient) replaceMember(wc *WSClient) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, other := range c.clients {
+		if other == wc {
+			c.replace(wc)
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+)
+
+// throttledConnection is a ws.Connection that takes a fixed time for each
+// write, one write at a time, so that the connection is what limits
+// throughput, as a saturated socket would.
+type throttledConnection struct {
+	discardConnection
+	lock sync.Mutex
+}
+
+func (c *throttledConnection) Write(p []byte) (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	time.Sleep(50 * time.Microsecond)
+
+	return len(p), nil
+}
+
+func (*throttledConnection) Close() error { return nil }
+
+// Benchmark_MultiConnClient_Scaling sends from many goroutines over 1 to 8
+// throttled connections. The msgs/s it reports grows in line with the
+// number of connections, until something other than the connections,
+// such as the network card, becomes the limit.
+func Benchmark_MultiConnClient_Scaling(b *testing.B) {
+	raw := []byte("oi")
+
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("conns-%d", n), func(b *testing.B) {
+			mc := client.NewMultiConnClient(func(client.ServerAddress) *client.WSClient {
+				factory := &clientfakes.FakeWSConnectionFactory{}
+				factory.NewReturns(&extfakes.FakeConn{}, nil)
+				factory.NewSessionReturns(&client.WSSession{Connection: &throttledConnection{}})
+
+				return client.NewWS(client.WSConnectionOptions{Factory: factory})
+			}, n, "ws://bench")
+
+			if err := mc.Connect(); err != nil {
+				b.Fatal(err)
+			}
+
+			defer mc.Disconnect() //nolint:errcheck
+
+			// enough senders to keep every connection busy
+			b.SetParallelism(n)
+			b.ResetTimer()
+
+			start := time.Now()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := mc.SendRaw(raw); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+
+			b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "msgs/s")
+		})
+	}
+}
@@ -0,0 +1,260 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"sync"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiConnClient", func() {
+	var (
+		lock      sync.Mutex
+		addresses []ServerAddress
+		factories []*clientfakes.FakeWSConnectionFactory
+		conns     []*wsfakes.FakeConnection
+		mc        *MultiConnClient
+	)
+
+	conn := func(i int) *wsfakes.FakeConnection {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return conns[i]
+	}
+
+	BeforeEach(func() {
+		addresses, factories, conns = nil, nil, nil
+
+		mc = NewMultiConnClient(func(address ServerAddress) *WSClient {
+			lock.Lock()
+			defer lock.Unlock()
+
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn := &wsfakes.FakeConnection{}
+
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			addresses = append(addresses, address)
+			factories = append(factories, factory)
+			conns = append(conns, conn)
+
+			return NewWS(WSConnectionOptions{Factory: factory})
+		}, 4, "wss://a", "wss://b")
+	})
+
+	JustBeforeEach(func() {
+		Expect(mc.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(mc.Disconnect()).To(Succeed())
+	})
+
+	It("is a MessageSender", func() {
+		var sender MessageSender = mc
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("spreads the connections over the servers", func() {
+		Expect(mc.Concurrency()).To(Equal(4))
+		Expect(addresses).To(Equal([]ServerAddress{"wss://a", "wss://b", "wss://a", "wss://b"}))
+	})
+
+	It("sends to each connection in turn", func() {
+		for i := 0; i < 8; i++ {
+			Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+		}
+
+		for i := range conns {
+			Expect(conn(i).WriteCallCount()).To(Equal(2))
+		}
+	})
+
+	It("tries another connection and replaces the one that failed", func() {
+		conn(0).WriteReturns(0, errors.New("nope"))
+
+		Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(conn(1).WriteCallCount()).To(Equal(1))
+		Eventually(factories[0].NewCallCount).Should(Equal(2))
+	})
+
+	It("skips connections that have closed", func() {
+		conn(0).ClosedReturns(true)
+
+		Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(conn(0).WriteCallCount()).To(BeZero())
+		Expect(conn(1).WriteCallCount()).To(Equal(1))
+	})
+
+	It("fails when every connection fails", func() {
+		for i := range conns {
+			conn(i).WriteReturns(0, errors.New("nope"))
+		}
+
+		Expect(mc.SendRaw([]byte("oi"))).To(MatchError("nope"))
+	})
+
+	When("a connection does not connect", func() {
+		BeforeEach(func() {
+			newClient := mc.NewClient
+			mc.NewClient = func(address ServerAddress) *WSClient {
+				wc := newClient(address)
+				if len(factories) == 1 {
+					factories[0].NewReturnsOnCall(0, nil, errors.New("nope"))
+				}
+
+				return wc
+			}
+		})
+
+		It("connects the others and retries it in the background", func() {
+			Expect(conn(0).WriteCallCount()).To(BeZero())
+			Eventually(factories[0].NewCallCount).Should(Equal(2))
+		})
+	})
+
+	Describe("SetConcurrency", func() {
+		It("opens more connections", func() {
+			Expect(mc.SetConcurrency(6)).To(Succeed())
+			Expect(mc.Concurrency()).To(Equal(6))
+			Expect(addresses).To(HaveLen(6))
+
+			for i := 0; i < 6; i++ {
+				Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+			}
+
+			Expect(conn(5).WriteCallCount()).To(Equal(1))
+		})
+
+		It("closes connections that are removed", func() {
+			Expect(mc.SetConcurrency(2)).To(Succeed())
+			Expect(mc.Concurrency()).To(Equal(2))
+			Expect(conn(2).CloseCallCount()).To(Equal(1))
+			Expect(conn(3).CloseCallCount()).To(Equal(1))
+
+			for i := 0; i < 4; i++ {
+				Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+			}
+
+			Expect(conn(0).WriteCallCount()).To(Equal(2))
+			Expect(conn(2).WriteCallCount()).To(BeZero())
+		})
+
+		It("does not wait for sends in progress to change the connections", func() {
+			release := make(chan struct{})
+			conn(0).WriteStub = func(data []byte) (int, error) {
+				<-release
+				return len(data), nil
+			}
+
+			sent := make(chan error, 1)
+			go func() { sent <- mc.SendRaw([]byte("oi")) }()
+			Eventually(conn(0).WriteCallCount).Should(Equal(1))
+
+			Expect(mc.SetConcurrency(6)).To(Succeed())
+			Expect(mc.Concurrency()).To(Equal(6))
+
+			close(release)
+			Eventually(sent).Should(Receive(BeNil()))
+		})
+
+		It("disconnects a removed connection once its send has finished", func() {
+			release := make(chan struct{})
+			conn(3).WriteStub = func(data []byte) (int, error) {
+				<-release
+				return len(data), nil
+			}
+
+			for i := 0; i < 3; i++ {
+				Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+			}
+
+			sent := make(chan error, 1)
+			go func() { sent <- mc.SendRaw([]byte("oi")) }()
+			Eventually(conn(3).WriteCallCount).Should(Equal(1))
+
+			resized := make(chan error, 1)
+			go func() { resized <- mc.SetConcurrency(2) }()
+
+			Eventually(mc.Concurrency).Should(Equal(2))
+			Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+			Consistently(conn(3).CloseCallCount).Should(BeZero())
+
+			close(release)
+			Eventually(sent).Should(Receive(BeNil()))
+			Eventually(resized).Should(Receive(BeNil()))
+			Expect(conn(3).CloseCallCount()).To(Equal(1))
+		})
+
+		It("rejects less than one connection", func() {
+			Expect(mc.SetConcurrency(0)).ToNot(Succeed())
+		})
+	})
+
+	Describe("selectors", func() {
+		It("picks at random", func() {
+			mc.Selector = RandomSelector{}
+
+			for i := 0; i < 100; i++ {
+				Expect(mc.SendRaw([]byte("oi"))).To(Succeed())
+			}
+
+			total := 0
+			for i := range conns {
+				total += conn(i).WriteCallCount()
+			}
+
+			Expect(total).To(Equal(100))
+		})
+
+		It("picks the connection with the least backlog", func() {
+			var clients []*WSClient
+
+			for i := 0; i < 3; i++ {
+				clients = append(clients, NewWS(WSConnectionOptions{
+					Buffer: BufferOptions{Enabled: true},
+				}))
+			}
+
+			for i, n := range []int{2, 1, 3} {
+				for j := 0; j < n; j++ {
+					Expect(clients[i].Send(protocol.NewMessage("foo", "oi"))).To(Succeed())
+				}
+			}
+
+			Expect(LeastBacklogSelector{}.Select(clients)).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package nats
+
+import (
+	"errors"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+// TokenAuth returns a nats.Option that authenticates with the token of
+// auth. The token is read at every connect and reconnect, so tokens set by
+// the IAMAuthInfo's auto refresh are used.
+func TokenAuth(auth *client.IAMAuthInfo) nats.Option {
+	return nats.TokenHandler(auth.IAMToken)
+}
+
+// UserPasswordAuth returns a nats.Option that authenticates as user, with
+// the token of auth as the password. The token is read when the option is
+// applied, by nats.Connect.
+func UserPasswordAuth(user string, auth *client.IAMAuthInfo) nats.Option {
+	return func(o *nats.Options) error {
+		return nats.UserInfo(user, auth.IAMToken())(o)
+	}
+}
+
+// NkeyAuth returns a nats.Option that authenticates with the user nkey
+// whose seed, e.g. "SUAM...", is the token of auth. The seed is read again
+// to sign the nonce of every connect, so it may be replaced by one of the
+// same key, but not by another key.
+func NkeyAuth(auth *client.IAMAuthInfo) (nats.Option, error) {
+	kp, err := nkeys.FromSeed([]byte(auth.IAMToken()))
+	if err != nil {
+		return nil, err
+	}
+
+	defer kp.Wipe()
+
+	pub, err := kp.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return nats.Nkey(pub, func(nonce []byte) ([]byte, error) {
+		kp, err := nkeys.FromSeed([]byte(auth.IAMToken()))
+		if err != nil {
+			return nil, err
+		}
+
+		defer kp.Wipe()
+
+		if key, err := kp.PublicKey(); err != nil || key != pub {
+			return nil, errors.New("nkey seed no longer matches its public key")
+		}
+
+		return kp.Sign(nonce)
+	}), nil
+}
@@ -0,0 +1,118 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package nats_test
+
+import (
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	fnats "github.com/IBM/fluent-forward-go/fluent/client/nats"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auth", func() {
+	var (
+		auth *client.IAMAuthInfo
+		opts nats.Options
+	)
+
+	BeforeEach(func() {
+		auth = client.NewIAMAuthInfo("first")
+		opts = nats.GetDefaultOptions()
+	})
+
+	Describe("TokenAuth", func() {
+		It("reads the current token", func() {
+			Expect(fnats.TokenAuth(auth)(&opts)).To(Succeed())
+			Expect(opts.TokenHandler()).To(Equal("first"))
+
+			auth.SetIAMToken("second")
+			Expect(opts.TokenHandler()).To(Equal("second"))
+		})
+	})
+
+	Describe("UserPasswordAuth", func() {
+		It("uses the token as the password", func() {
+			Expect(fnats.UserPasswordAuth("fluent", auth)(&opts)).To(Succeed())
+			Expect(opts.User).To(Equal("fluent"))
+			Expect(opts.Password).To(Equal("first"))
+		})
+	})
+
+	Describe("NkeyAuth", func() {
+		var (
+			kp   nkeys.KeyPair
+			seed []byte
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			kp, err = nkeys.CreateUser()
+			Expect(err).ToNot(HaveOccurred())
+			seed, err = kp.Seed()
+			Expect(err).ToNot(HaveOccurred())
+
+			auth.SetIAMToken(string(seed))
+		})
+
+		It("signs nonces with the seed's key", func() {
+			opt, err := fnats.NkeyAuth(auth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opt(&opts)).To(Succeed())
+
+			pub, err := kp.PublicKey()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opts.Nkey).To(Equal(pub))
+
+			sig, err := opts.SignatureCB([]byte("nonce"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(kp.Verify([]byte("nonce"), sig)).To(Succeed())
+		})
+
+		It("refuses to sign once the seed is of another key", func() {
+			opt, err := fnats.NkeyAuth(auth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(opt(&opts)).To(Succeed())
+
+			other, err := nkeys.CreateUser()
+			Expect(err).ToNot(HaveOccurred())
+			otherSeed, err := other.Seed()
+			Expect(err).ToNot(HaveOccurred())
+			auth.SetIAMToken(string(otherSeed))
+
+			_, err = opts.SignatureCB([]byte("nonce"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error for a token that is not a seed", func() {
+			auth.SetIAMToken("not a seed")
+
+			_, err := fnats.NkeyAuth(auth)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,270 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package nats sends Fluent Forward messages over NATS, for architectures
+// where NATS JetStream carries events from applications to Fluentd. Each
+// message is published, as MessagePack, to a subject made from its tag.
+package nats
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/nats-io/nats.go"
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
+
+// Publisher publishes messages without waiting for them to be stored.
+// *nats.Conn implements it.
+//
+//counterfeiter:generate . Publisher
+type Publisher interface {
+	Publish(subj string, data []byte) error
+}
+
+// JetStreamPublisher publishes messages to a stream and waits for the
+// stream's PubAck. nats.JetStreamContext implements it.
+//
+//counterfeiter:generate . JetStreamPublisher
+type JetStreamPublisher interface {
+	PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error)
+}
+
+// ErrNoSubject is returned for a message whose tag makes no subject, e.g.
+// because it is empty.
+var ErrNoSubject = errors.New("tag makes no NATS subject")
+
+type Options struct {
+	// SubjectPrefix is prepended to every subject, e.g. "fluent." so that
+	// a stream can capture "fluent.>".
+	SubjectPrefix string
+	// JetStream, if true, makes Send publish with JetStream and wait for
+	// the stream to store each message.
+	JetStream bool
+	// PublishOptions are passed to every JetStream publish, e.g.
+	// nats.ExpectStream.
+	PublishOptions []nats.PubOpt
+}
+
+// NATSTransport publishes each message to the subject made from its tag
+// by Subject. Send publishes best-effort with Publish, or, if JetStream is
+// set, durably with PublishAck. It is safe for concurrent use.
+type NATSTransport struct {
+	// Conn publishes the best-effort messages.
+	Conn Publisher
+	// JetStream, if not nil, is used by Send in place of Conn.
+	JetStream JetStreamPublisher
+	// SubjectPrefix is prepended to every subject.
+	SubjectPrefix string
+	// PublishOptions are passed to every JetStream publish.
+	PublishOptions []nats.PubOpt
+	nc             *nats.Conn
+}
+
+// New returns a NATSTransport that publishes on nc, with its JetStream
+// context if opts.JetStream is set. nc is left open by Close.
+func New(nc *nats.Conn, opts Options) (*NATSTransport, error) {
+	t := &NATSTransport{
+		Conn:           nc,
+		SubjectPrefix:  opts.SubjectPrefix,
+		PublishOptions: opts.PublishOptions,
+	}
+
+	if opts.JetStream {
+		js, err := nc.JetStream()
+		if err != nil {
+			return nil, fmt.Errorf("jetstream: %w", err)
+		}
+
+		t.JetStream = js
+	}
+
+	return t, nil
+}
+
+// Connect connects to the NATS servers at url, e.g. with the options of
+// TokenAuth, NkeyAuth or UserPasswordAuth, and returns a NATSTransport
+// that publishes on the connection. Close drains the connection.
+func Connect(url string, opts Options, natsOpts ...nats.Option) (*NATSTransport, error) {
+	nc, err := nats.Connect(url, natsOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := New(nc, opts)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	t.nc = nc
+
+	return t, nil
+}
+
+// Subject returns the NATS subject the messages tagged tag are published
+// to. The dots of a tag already separate the tokens of a subject, so
+// "app.db" is published to "app.db" after the SubjectPrefix. Empty tokens
+// are dropped, and whitespace and the wildcards "*" and ">", which cannot
+// be published to, are replaced with "_".
+func (t *NATSTransport) Subject(tag string) (string, error) {
+	tokens := strings.FieldsFunc(tag, func(r rune) bool { return r == '.' })
+	if len(tokens) == 0 {
+		return "", fmt.Errorf("%w: %q", ErrNoSubject, tag)
+	}
+
+	for i, token := range tokens {
+		tokens[i] = strings.Map(func(r rune) rune {
+			if r == '*' || r == '>' || unicode.IsSpace(r) {
+				return '_'
+			}
+
+			return r
+		}, token)
+	}
+
+	return t.SubjectPrefix + strings.Join(tokens, "."), nil
+}
+
+// Send publishes e with PublishAck if JetStream is set, and with Publish
+// otherwise.
+func (t *NATSTransport) Send(e protocol.ChunkEncoder) error {
+	if t.JetStream != nil {
+		_, err := t.PublishAck(e)
+		return err
+	}
+
+	return t.Publish(e)
+}
+
+// SendRaw sends a message that is already encoded, like Send. Its tag is
+// read from the encoded message.
+func (t *NATSTransport) SendRaw(raw []byte) error {
+	if t.JetStream != nil {
+		_, err := t.publishAck(raw)
+		return err
+	}
+
+	return t.publish(raw)
+}
+
+// SendMessage sends a single event in Message mode.
+func (t *NATSTransport) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessage(tag, record))
+}
+
+// Publish publishes e with the core NATS protocol, without waiting for it
+// to be received: messages published while no subscriber or stream is
+// listening are lost.
+func (t *NATSTransport) Publish(e msgp.Encodable) error {
+	raw, err := encode(e)
+	if err != nil {
+		return err
+	}
+
+	return t.publish(raw)
+}
+
+// PublishAck publishes e with JetStream and returns the PubAck of the
+// stream that stored it. The message's chunk option, if it has one, is its
+// Nats-Msg-Id, so that the stream discards a resent copy that it has
+// already stored.
+func (t *NATSTransport) PublishAck(e msgp.Encodable) (*nats.PubAck, error) {
+	raw, err := encode(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.publishAck(raw)
+}
+
+func (t *NATSTransport) publish(raw []byte) error {
+	subject, err := t.subjectOf(raw)
+	if err != nil {
+		return err
+	}
+
+	return t.Conn.Publish(subject, raw)
+}
+
+func (t *NATSTransport) publishAck(raw []byte) (*nats.PubAck, error) {
+	if t.JetStream == nil {
+		return nil, errors.New("jetstream is not enabled")
+	}
+
+	subject, err := t.subjectOf(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := t.PublishOptions
+	if chunk, cerr := protocol.GetChunk(raw); cerr == nil && chunk != "" {
+		opts = append(opts[:len(opts):len(opts)], nats.MsgId(chunk))
+	}
+
+	return t.JetStream.PublishMsg(&nats.Msg{Subject: subject, Data: raw}, opts...)
+}
+
+// subjectOf returns the subject of an encoded message, from its tag.
+func (t *NATSTransport) subjectOf(raw []byte) (string, error) {
+	_, b, err := msgp.ReadArrayHeaderBytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("read tag: %w", err)
+	}
+
+	tag, _, err := msgp.ReadStringBytes(b)
+	if err != nil {
+		return "", fmt.Errorf("read tag: %w", err)
+	}
+
+	return t.Subject(tag)
+}
+
+// Close drains the connection made by Connect, so that the messages
+// published are flushed first. A connection passed to New is left to its
+// owner.
+func (t *NATSTransport) Close() error {
+	if t.nc == nil {
+		return nil
+	}
+
+	return t.nc.Drain()
+}
+
+func encode(e msgp.Encodable) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := msgp.Encode(&buf, e); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,195 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package nats_test
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	fnats "github.com/IBM/fluent-forward-go/fluent/client/nats"
+	"github.com/IBM/fluent-forward-go/fluent/client/nats/natsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/nats-io/nats.go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("NATSTransport", func() {
+	var (
+		conn *natsfakes.FakePublisher
+		js   *natsfakes.FakeJetStreamPublisher
+		t    *fnats.NATSTransport
+	)
+
+	encode := func(e msgp.Encodable) []byte {
+		var buf bytes.Buffer
+
+		Expect(msgp.Encode(&buf, e)).To(Succeed())
+
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		conn = &natsfakes.FakePublisher{}
+		js = &natsfakes.FakeJetStreamPublisher{}
+		js.PublishMsgReturns(&nats.PubAck{Stream: "FLUENT", Sequence: 7}, nil)
+
+		t = &fnats.NATSTransport{
+			Conn:          conn,
+			SubjectPrefix: "fluent.",
+		}
+	})
+
+	It("is a MessageSender", func() {
+		var sender client.MessageSender = t
+		Expect(sender).ToNot(BeNil())
+	})
+
+	Describe("Subject", func() {
+		It("maps the tag to a subject after the prefix", func() {
+			Expect(t.Subject("app.db")).To(Equal("fluent.app.db"))
+		})
+
+		It("drops empty tokens and replaces wildcards and whitespace", func() {
+			Expect(t.Subject(".app..my db.*.>.")).To(Equal("fluent.app.my_db._._"))
+		})
+
+		It("returns ErrNoSubject for a tag without tokens", func() {
+			_, err := t.Subject("..")
+			Expect(err).To(MatchError(fnats.ErrNoSubject))
+		})
+	})
+
+	Describe("Send", func() {
+		It("publishes the message best-effort to its tag's subject", func() {
+			msg := protocol.NewMessage("app.db", map[string]string{"a": "b"})
+
+			Expect(t.Send(msg)).To(Succeed())
+
+			Expect(conn.PublishCallCount()).To(Equal(1))
+			subject, data := conn.PublishArgsForCall(0)
+			Expect(subject).To(Equal("fluent.app.db"))
+			Expect(data).To(Equal(encode(msg)))
+		})
+
+		It("returns the publish error", func() {
+			conn.PublishReturns(errors.New("nope"))
+
+			Expect(t.SendMessage("app", "rec")).To(MatchError("nope"))
+		})
+
+		It("does not publish a message without a subject", func() {
+			Expect(t.SendMessage("", "rec")).To(MatchError(fnats.ErrNoSubject))
+			Expect(conn.PublishCallCount()).To(BeZero())
+		})
+
+		When("JetStream is set", func() {
+			BeforeEach(func() {
+				t.JetStream = js
+			})
+
+			It("publishes with JetStream instead", func() {
+				Expect(t.SendMessage("app", "rec")).To(Succeed())
+
+				Expect(conn.PublishCallCount()).To(BeZero())
+				Expect(js.PublishMsgCallCount()).To(Equal(1))
+				m, _ := js.PublishMsgArgsForCall(0)
+				Expect(m.Subject).To(Equal("fluent.app"))
+			})
+		})
+	})
+
+	Describe("SendRaw", func() {
+		It("publishes to the subject of the encoded tag", func() {
+			raw := encode(protocol.NewMessage("raw.tag", "rec"))
+
+			Expect(t.SendRaw(raw)).To(Succeed())
+
+			subject, data := conn.PublishArgsForCall(0)
+			Expect(subject).To(Equal("fluent.raw.tag"))
+			Expect(data).To(Equal(raw))
+		})
+
+		It("returns an error for bytes that are not a message", func() {
+			Expect(t.SendRaw([]byte{0xc0})).To(HaveOccurred())
+			Expect(conn.PublishCallCount()).To(BeZero())
+		})
+	})
+
+	Describe("PublishAck", func() {
+		It("returns an error when JetStream is not set", func() {
+			_, err := t.PublishAck(protocol.NewMessage("app", "rec"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		When("JetStream is set", func() {
+			BeforeEach(func() {
+				t.JetStream = js
+				t.PublishOptions = []nats.PubOpt{nats.ExpectStream("FLUENT")}
+			})
+
+			It("returns the stream's PubAck", func() {
+				msg := protocol.NewMessage("app", "rec")
+
+				ack, err := t.PublishAck(msg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ack.Sequence).To(BeEquivalentTo(7))
+
+				m, opts := js.PublishMsgArgsForCall(0)
+				Expect(m.Subject).To(Equal("fluent.app"))
+				Expect(m.Data).To(Equal(encode(msg)))
+				Expect(opts).To(HaveLen(1))
+			})
+
+			It("sets the message ID to the chunk", func() {
+				msg := protocol.NewMessage("app", "rec")
+				_, err := msg.Chunk()
+				Expect(err).ToNot(HaveOccurred())
+
+				_, err = t.PublishAck(msg)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, opts := js.PublishMsgArgsForCall(0)
+				Expect(opts).To(HaveLen(2))
+				Expect(t.PublishOptions).To(HaveLen(1))
+			})
+
+			It("returns the publish error", func() {
+				js.PublishMsgReturns(nil, nats.ErrNoStreamResponse)
+
+				_, err := t.PublishAck(protocol.NewMessage("app", "rec"))
+				Expect(err).To(MatchError(nats.ErrNoStreamResponse))
+			})
+		})
+	})
+
+	Describe("Close", func() {
+		It("leaves a connection it did not make", func() {
+			Expect(t.Close()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package natsfakes
+
+import (
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/nats"
+	natsa "github.com/nats-io/nats.go"
+)
+
+type FakeJetStreamPublisher struct {
+	PublishMsgStub        func(*natsa.Msg, ...natsa.PubOpt) (*natsa.PubAck, error)
+	publishMsgMutex       sync.RWMutex
+	publishMsgArgsForCall []struct {
+		arg1 *natsa.Msg
+		arg2 []natsa.PubOpt
+	}
+	publishMsgReturns struct {
+		result1 *natsa.PubAck
+		result2 error
+	}
+	publishMsgReturnsOnCall map[int]struct {
+		result1 *natsa.PubAck
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsg(arg1 *natsa.Msg, arg2 ...natsa.PubOpt) (*natsa.PubAck, error) {
+	fake.publishMsgMutex.Lock()
+	ret, specificReturn := fake.publishMsgReturnsOnCall[len(fake.publishMsgArgsForCall)]
+	fake.publishMsgArgsForCall = append(fake.publishMsgArgsForCall, struct {
+		arg1 *natsa.Msg
+		arg2 []natsa.PubOpt
+	}{arg1, arg2})
+	stub := fake.PublishMsgStub
+	fakeReturns := fake.publishMsgReturns
+	fake.recordInvocation("PublishMsg", []interface{}{arg1, arg2})
+	fake.publishMsgMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2...)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsgCallCount() int {
+	fake.publishMsgMutex.RLock()
+	defer fake.publishMsgMutex.RUnlock()
+	return len(fake.publishMsgArgsForCall)
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsgCalls(stub func(*natsa.Msg, ...natsa.PubOpt) (*natsa.PubAck, error)) {
+	fake.publishMsgMutex.Lock()
+	defer fake.publishMsgMutex.Unlock()
+	fake.PublishMsgStub = stub
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsgArgsForCall(i int) (*natsa.Msg, []natsa.PubOpt) {
+	fake.publishMsgMutex.RLock()
+	defer fake.publishMsgMutex.RUnlock()
+	argsForCall := fake.publishMsgArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsgReturns(result1 *natsa.PubAck, result2 error) {
+	fake.publishMsgMutex.Lock()
+	defer fake.publishMsgMutex.Unlock()
+	fake.PublishMsgStub = nil
+	fake.publishMsgReturns = struct {
+		result1 *natsa.PubAck
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeJetStreamPublisher) PublishMsgReturnsOnCall(i int, result1 *natsa.PubAck, result2 error) {
+	fake.publishMsgMutex.Lock()
+	defer fake.publishMsgMutex.Unlock()
+	fake.PublishMsgStub = nil
+	if fake.publishMsgReturnsOnCall == nil {
+		fake.publishMsgReturnsOnCall = make(map[int]struct {
+			result1 *natsa.PubAck
+			result2 error
+		})
+	}
+	fake.publishMsgReturnsOnCall[i] = struct {
+		result1 *natsa.PubAck
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeJetStreamPublisher) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.publishMsgMutex.RLock()
+	defer fake.publishMsgMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeJetStreamPublisher) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ nats.JetStreamPublisher = new(FakeJetStreamPublisher)
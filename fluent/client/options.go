@@ -0,0 +1,157 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"golang.org/x/time/rate"
+)
+
+// ClientOption configures the WSClient made by NewWSClient.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	WSConnectionOptions
+	address  ServerAddress
+	authInfo *IAMAuthInfo
+	logger   Logger
+}
+
+// NewWSClient returns a WSClient configured by opts, which are applied in
+// order. Unless WithConnectionFactory is given, connections are made by a
+// DefaultWSConnectionFactory to the address from WithServerAddress,
+// authenticated with the IAMAuthInfo from WithAuthInfo. Options not
+// covered by a ClientOption may be set on the returned client before it
+// connects.
+func NewWSClient(opts ...ClientOption) *WSClient {
+	var co clientOptions
+
+	for _, opt := range opts {
+		opt(&co)
+	}
+
+	if co.Factory == nil && (co.address != "" || co.authInfo != nil) {
+		co.Factory = &DefaultWSConnectionFactory{
+			URL:      string(co.address),
+			AuthInfo: co.authInfo,
+		}
+	}
+
+	c := NewWS(co.WSConnectionOptions)
+	c.Logger = co.logger
+
+	return c
+}
+
+// WithServerAddress sets the URL of the server, e.g.
+// "wss://example.com:8083".
+func WithServerAddress(addr ServerAddress) ClientOption {
+	return func(co *clientOptions) {
+		co.address = addr
+	}
+}
+
+// WithAuthInfo authenticates the connections with the token in auth.
+func WithAuthInfo(auth *IAMAuthInfo) ClientOption {
+	return func(co *clientOptions) {
+		co.authInfo = auth
+	}
+}
+
+// WithConnectionFactory sets the factory that makes the connections, in
+// place of the DefaultWSConnectionFactory. WithServerAddress and
+// WithAuthInfo are then ignored.
+func WithConnectionFactory(factory WSConnectionFactory) ClientOption {
+	return func(co *clientOptions) {
+		co.Factory = factory
+	}
+}
+
+// WithConnectionOptions sets the options of each websocket connection.
+func WithConnectionOptions(opts ws.ConnectionOptions) ClientOption {
+	return func(co *clientOptions) {
+		co.ConnectionOptions = opts
+	}
+}
+
+// WithMaxMessageBytes sets the largest encoded message that may be sent.
+// Zero means no limit.
+func WithMaxMessageBytes(n int64) ClientOption {
+	return func(co *clientOptions) {
+		co.MaxMessageBytes = n
+	}
+}
+
+// WithChunkIDGenerator sets the generator of the chunk IDs that
+// SendMessageAck gives messages without one.
+func WithChunkIDGenerator(gen ChunkIDGenerator) ClientOption {
+	return func(co *clientOptions) {
+		co.CorrelationIDs = gen
+	}
+}
+
+// WithTagTransformer sets the function that rewrites the tag of every
+// message sent. See WSClient.TagTransformer.
+func WithTagTransformer(t func(tag string) string) ClientOption {
+	return func(co *clientOptions) {
+		co.TagTransformer = t
+	}
+}
+
+// WithTagPrefix sets the prefix prepended to the tag of every message
+// sent. See WSClient.TagPrefix.
+func WithTagPrefix(prefix string) ClientOption {
+	return func(co *clientOptions) {
+		co.TagPrefix = prefix
+	}
+}
+
+// WithRateLimit sets the limiter that sends wait for.
+func WithRateLimit(l *rate.Limiter) ClientOption {
+	return func(co *clientOptions) {
+		co.RateLimit = l
+	}
+}
+
+// WithLogger sets the client's Logger.
+func WithLogger(l Logger) ClientOption {
+	return func(co *clientOptions) {
+		co.logger = l
+	}
+}
+
+// WithRetryPolicy sets the RetryPolicy used by ReconnectWithRetry.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(co *clientOptions) {
+		co.RetryPolicy = p
+	}
+}
+
+// WithMetrics sets the collector of the client's measurements.
+func WithMetrics(m MetricsCollector) ClientOption {
+	return func(co *clientOptions) {
+		co.Metrics = m
+	}
+}
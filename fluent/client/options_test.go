@@ -0,0 +1,88 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewWSClient", func() {
+	It("applies the options", func() {
+		auth := NewIAMAuthInfo("token")
+		policy := &DefaultExponentialBackoff{Attempts: 3}
+		metrics := &clientfakes.FakeMetricsCollector{}
+		logger := NoopLogger{}
+		ids := &SequentialChunkIDGenerator{}
+
+		c := NewWSClient(
+			WithServerAddress("wss://example.com:8083"),
+			WithAuthInfo(auth),
+			WithConnectionOptions(ws.ConnectionOptions{PingInterval: time.Second}),
+			WithLogger(logger),
+			WithRetryPolicy(policy),
+			WithMetrics(metrics),
+			WithChunkIDGenerator(ids),
+			WithTagPrefix("payments."),
+			WithTagTransformer(LowercaseTag),
+		)
+
+		Expect(c.ConnectionFactory).To(Equal(&DefaultWSConnectionFactory{
+			URL:      "wss://example.com:8083",
+			AuthInfo: auth,
+		}))
+		Expect(c.ConnectionOptions.PingInterval).To(Equal(time.Second))
+		Expect(c.Logger).To(Equal(logger))
+		Expect(c.RetryPolicy).To(BeIdenticalTo(policy))
+		Expect(c.Metrics).To(BeIdenticalTo(metrics))
+		Expect(c.CorrelationIDs).To(BeIdenticalTo(ids))
+		Expect(c.TagPrefix).To(Equal("payments."))
+		Expect(c.TagTransformer("A")).To(Equal("a"))
+	})
+
+	It("prefers the connection factory", func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+
+		c := NewWSClient(
+			WithServerAddress("wss://example.com:8083"),
+			WithConnectionFactory(factory),
+		)
+
+		Expect(c.ConnectionFactory).To(BeIdenticalTo(factory))
+	})
+
+	It("has the defaults of NewWS", func() {
+		c := NewWSClient()
+
+		Expect(c.ConnectionFactory).To(Equal(NewWS(WSConnectionOptions{}).ConnectionFactory))
+		Expect(c.Metrics).ToNot(BeNil())
+		Expect(c.Logger).To(BeNil())
+	})
+})
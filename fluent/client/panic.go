@@ -0,0 +1,103 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+)
+
+// recoverReadHandler recovers from panics in next, so that a bad handler
+// or a message it cannot cope with does not kill the goroutine running
+// Listen without a trace. Unless RestartOnPanic is set, the messages
+// read after a panic, while the connection closes, are dropped. As Listen
+// calls the handler from one goroutine, panicked needs no lock.
+func (c *WSClient) recoverReadHandler(next ws.ReadHandler) ws.ReadHandler {
+	panicked := false
+
+	return func(conn ws.Connection, messageType int, p []byte, err error) (rerr error) {
+		if panicked {
+			// the handlers are not called again, but a ListenWith still
+			// learns why the connection ended
+			c.deliverError(err)
+			return err
+		}
+
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+
+			c.recordPanic()
+			c.logger().Errorf("read handler panicked: %v\n%s", r, debug.Stack())
+
+			if c.RestartOnPanic && err == nil {
+				rerr = nil
+				return
+			}
+
+			panicked = true
+			rerr = fmt.Errorf("%w: %v", ErrPanic, r)
+			c.deliverError(rerr)
+
+			// Close waits for the read loop to end, which it cannot while
+			// this handler is running
+			go func() {
+				_ = conn.Close()
+			}()
+		}()
+
+		return next(conn, messageType, p, err)
+	}
+}
+
+// deliverError ends the active ListenWith, if any, with err.
+func (c *WSClient) deliverError(err error) {
+	if l := c.currentListener(); l != nil && err != nil {
+		l.deliver(nil, err)
+	}
+}
+
+func (c *WSClient) recordPanic() {
+	c.errLock.Lock()
+	c.panics++
+	c.errLock.Unlock()
+
+	if pc, ok := c.metrics().(PanicCollector); ok {
+		pc.RecordPanic()
+	}
+}
+
+// PanicCount returns the number of ReadHandler panics the client has
+// recovered from.
+func (c *WSClient) PanicCount() int64 {
+	c.errLock.RLock()
+	defer c.errLock.RUnlock()
+
+	return c.panics
+}
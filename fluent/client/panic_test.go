@@ -0,0 +1,179 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type panicMetrics struct {
+	client.MetricsCollector
+	panics chan struct{}
+}
+
+func (m *panicMetrics) RecordPanic() {
+	m.panics <- struct{}{}
+}
+
+var _ = Describe("WSClient with a panicking ReadHandler", func() {
+	var (
+		svr     *httptest.Server
+		cli     *client.WSClient
+		metrics *panicMetrics
+		errs    chan error
+		restart bool
+	)
+
+	BeforeEach(func() {
+		restart = false
+
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer wc.Close()
+
+			_ = wc.WriteMessage(websocket.BinaryMessage, []byte("boom"))
+			_ = wc.WriteMessage(websocket.BinaryMessage, []byte("ok"))
+
+			for {
+				if _, _, err := wc.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+	})
+
+	JustBeforeEach(func() {
+		metrics = &panicMetrics{
+			MetricsCollector: &clientfakes.FakeMetricsCollector{},
+			panics:           make(chan struct{}, 1),
+		}
+		errs = make(chan error, 1)
+
+		// errors may be reported after the spec, so they must not reach
+		// the channel of the next one
+		errCh := errs
+		cli = client.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+			Metrics:        metrics,
+			RestartOnPanic: restart,
+			OnError: func(err error) {
+				errCh <- err
+			},
+		})
+	})
+
+	AfterEach(func() {
+		_ = cli.Disconnect()
+		svr.Close()
+	})
+
+	handle := func(received chan string) {
+		cli.ConnectionOptions.ReadHandler = func(_ ws.Connection, _ int, msg []byte, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if string(msg) == "boom" {
+				panic("boom")
+			}
+
+			received <- string(msg)
+
+			return nil
+		}
+	}
+
+	It("ends Listen with ErrPanic", func() {
+		received := make(chan string, 1)
+
+		handle(received)
+		Expect(cli.Connect()).To(Succeed())
+
+		Eventually(metrics.panics).Should(Receive())
+		Expect(cli.PanicCount()).To(BeEquivalentTo(1))
+
+		var err error
+		Eventually(errs).Should(Receive(&err))
+		Expect(errors.Is(err, client.ErrPanic)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("boom")))
+		Consistently(received).ShouldNot(Receive())
+	})
+
+	It("ends ListenWith with ErrPanic when its handler panics", func() {
+		Expect(cli.Connect()).To(Succeed())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := cli.ListenWith(ctx, func(msg []byte) error {
+			if string(msg) == "boom" {
+				panic("boom")
+			}
+
+			return nil
+		})
+
+		Expect(errors.Is(err, client.ErrPanic)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("boom")))
+		Expect(cli.PanicCount()).To(BeEquivalentTo(1))
+	})
+
+	When("RestartOnPanic is set", func() {
+		BeforeEach(func() {
+			restart = true
+		})
+
+		It("drops the message and keeps reading", func() {
+			received := make(chan string, 1)
+
+			handle(received)
+			Expect(cli.Connect()).To(Succeed())
+
+			Eventually(metrics.panics).Should(Receive())
+			Eventually(received).Should(Receive(Equal("ok")))
+			Expect(cli.PanicCount()).To(BeEquivalentTo(1))
+			Expect(errs).ToNot(Receive())
+		})
+	})
+})
@@ -0,0 +1,531 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package pool keeps a pool of connections to a server, for callers that
+// need more than the single connection a WSClient manages.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxSize          = 10
+	DefaultMaintainInterval = time.Second
+)
+
+// ErrPoolClosed is returned by Acquire once the pool has been closed.
+var ErrPoolClosed = errors.New("connection pool is closed")
+
+// ErrPoolExhausted is returned by Acquire when MaxSize connections are
+// active and none is released in time, as the ExhaustionPolicy decides.
+var ErrPoolExhausted = errors.New("connection pool is exhausted")
+
+// ExhaustionPolicy decides what Acquire does when MaxSize connections are
+// active.
+type ExhaustionPolicy int
+
+const (
+	// BlockUntilAvailable waits for a connection to be released, for at
+	// most AcquireTimeout if it is set, after which ErrPoolExhausted is
+	// returned.
+	BlockUntilAvailable ExhaustionPolicy = iota
+	// ReturnError returns ErrPoolExhausted without waiting.
+	ReturnError
+	// CreateEphemeral dials a connection outside the limit, e.g. to ride
+	// out a brief burst. It is closed, not pooled, when released.
+	CreateEphemeral
+)
+
+// Conn is a pooled connection, e.g. an ext.Conn returned by
+// client.DefaultWSConnectionFactory or a net.Conn. Its dynamic type must be
+// comparable, such as a pointer.
+type Conn interface {
+	Close() error
+}
+
+// DialFunc opens a new connection.
+type DialFunc func(ctx context.Context) (Conn, error)
+
+// Stats is a snapshot of a ConnectionPool.
+type Stats struct {
+	// Idle is the number of open connections waiting to be acquired.
+	Idle int
+	// Active is the number of connections acquired and not yet released.
+	Active int
+	// Waiting is the number of Acquire calls waiting for a connection.
+	Waiting int
+	// Ephemeral is the number of active connections dialed by the
+	// CreateEphemeral policy. They are not counted in Active.
+	Ephemeral int
+}
+
+// ConnectionPool hands out connections opened with Dial, at most MaxSize of
+// them at a time, and reuses those released. A goroutine, started by Start
+// or the first Acquire, dials in the background to keep MinIdle
+// connections ready, and closes idle ones older than MaxLifetime.
+type ConnectionPool struct {
+	// Dial opens the connections.
+	Dial DialFunc
+	// MinIdle is the number of idle connections kept open, as far as
+	// MaxSize allows.
+	MinIdle int
+	// MaxSize is the most connections, idle and active, open at once. If
+	// zero, DefaultMaxSize is used.
+	MaxSize int
+	// MaxLifetime is how long a connection is used before it is closed. If
+	// zero, connections are used until they are unhealthy.
+	MaxLifetime time.Duration
+	// Healthy, if not nil, reports whether a released connection can be
+	// reused. Those that cannot are closed.
+	Healthy func(c Conn) bool
+	// ExhaustionPolicy decides what Acquire does when MaxSize connections
+	// are active. The default is BlockUntilAvailable.
+	ExhaustionPolicy ExhaustionPolicy
+	// AcquireTimeout, if not zero, is the longest Acquire waits for a
+	// connection under BlockUntilAvailable.
+	AcquireTimeout time.Duration
+	// MaintainInterval is how often idle connections are expired and
+	// replenished. If zero, DefaultMaintainInterval is used.
+	MaintainInterval time.Duration
+	// OnDialError, if not nil, is called when a background dial fails.
+	OnDialError func(err error)
+	idle        []idleConn
+	opened      map[Conn]time.Time
+	ephemeral   map[Conn]struct{}
+	dialing     int
+	waiting     int
+	// released is closed, and replaced, whenever a connection is released
+	// or closed, to wake the Acquire calls waiting for one.
+	released chan struct{}
+	closed   bool
+	lock     sync.Mutex
+	stop     context.CancelFunc
+	maintain sync.WaitGroup
+}
+
+type idleConn struct {
+	conn   Conn
+	opened time.Time
+}
+
+func NewConnectionPool(dial DialFunc) *ConnectionPool {
+	return &ConnectionPool{
+		Dial:             dial,
+		MaxSize:          DefaultMaxSize,
+		MaintainInterval: DefaultMaintainInterval,
+	}
+}
+
+func (p *ConnectionPool) maxSize() int {
+	if p.MaxSize <= 0 {
+		return DefaultMaxSize
+	}
+
+	return p.MaxSize
+}
+
+// size returns the number of connections open or being opened. It must be
+// called with the lock held.
+func (p *ConnectionPool) size() int {
+	return len(p.opened) + p.dialing
+}
+
+func (p *ConnectionPool) expired(opened time.Time) bool {
+	return p.MaxLifetime > 0 && time.Since(opened) >= p.MaxLifetime
+}
+
+// notify wakes the waiting Acquire calls. It must be called with the lock
+// held.
+func (p *ConnectionPool) notify() {
+	if p.released != nil {
+		close(p.released)
+		p.released = nil
+	}
+}
+
+// forget removes c from the pool, so that another connection may take its
+// place. It must be called with the lock held.
+func (p *ConnectionPool) forget(c Conn) {
+	delete(p.opened, c)
+	p.notify()
+}
+
+// Start starts the goroutine that keeps MinIdle connections open, if it is
+// not running already.
+func (p *ConnectionPool) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.start()
+}
+
+// start must be called with the lock held.
+func (p *ConnectionPool) start() {
+	if p.stop != nil || p.closed {
+		return
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	p.stop = stop
+	p.maintain.Add(1)
+
+	go p.maintainEvery(ctx)
+}
+
+// Acquire returns an idle connection, or dials a new one if fewer than
+// MaxSize are open. Otherwise it does as the ExhaustionPolicy decides; a
+// wait ends early if ctx is done. The connection must be given back with
+// Release.
+func (p *ConnectionPool) Acquire(ctx context.Context) (Conn, error) {
+	var timeout <-chan time.Time
+
+	if p.ExhaustionPolicy == BlockUntilAvailable && p.AcquireTimeout > 0 {
+		timer := time.NewTimer(p.AcquireTimeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	for {
+		p.lock.Lock()
+
+		if p.closed {
+			p.lock.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		p.start()
+
+		if c, ok := p.popIdle(); ok {
+			p.lock.Unlock()
+			return c, nil
+		}
+
+		if p.size() < p.maxSize() {
+			p.dialing++
+			p.lock.Unlock()
+
+			return p.dial(ctx)
+		}
+
+		switch p.ExhaustionPolicy {
+		case ReturnError:
+			p.lock.Unlock()
+			return nil, ErrPoolExhausted
+		case CreateEphemeral:
+			p.lock.Unlock()
+			return p.dialEphemeral(ctx)
+		}
+
+		if p.released == nil {
+			p.released = make(chan struct{})
+		}
+
+		released := p.released
+		p.waiting++
+		p.lock.Unlock()
+
+		var err error
+
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timeout:
+			err = ErrPoolExhausted
+		case <-released:
+		}
+
+		p.lock.Lock()
+		p.waiting--
+		p.lock.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// popIdle returns the most recently released idle connection, closing
+// those that have expired. It must be called with the lock held.
+func (p *ConnectionPool) popIdle() (Conn, bool) {
+	for len(p.idle) > 0 {
+		ic := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+
+		if !p.expired(ic.opened) {
+			return ic.conn, true
+		}
+
+		p.forget(ic.conn)
+		_ = ic.conn.Close()
+	}
+
+	return nil, false
+}
+
+// dial opens a connection counted against MaxSize. The caller must have
+// incremented dialing, having checked there is room for it, and must not
+// hold the lock.
+func (p *ConnectionPool) dial(ctx context.Context) (Conn, error) {
+	c, err := p.Dial(ctx)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.dialing--
+
+	if err != nil {
+		p.notify()
+		return nil, err
+	}
+
+	if p.closed {
+		_ = c.Close()
+		return nil, ErrPoolClosed
+	}
+
+	if p.opened == nil {
+		p.opened = map[Conn]time.Time{}
+	}
+
+	p.opened[c] = time.Now()
+
+	return c, nil
+}
+
+// dialEphemeral opens a connection that is not counted against MaxSize.
+// The caller must not hold the lock.
+func (p *ConnectionPool) dialEphemeral(ctx context.Context) (Conn, error) {
+	c, err := p.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		_ = c.Close()
+		return nil, ErrPoolClosed
+	}
+
+	if p.ephemeral == nil {
+		p.ephemeral = map[Conn]struct{}{}
+	}
+
+	p.ephemeral[c] = struct{}{}
+
+	return c, nil
+}
+
+// closeEphemeral closes c and returns true if it was dialed by
+// dialEphemeral. It must be called with the lock held.
+func (p *ConnectionPool) closeEphemeral(c Conn) bool {
+	if _, ok := p.ephemeral[c]; !ok {
+		return false
+	}
+
+	delete(p.ephemeral, c)
+	_ = c.Close()
+
+	return true
+}
+
+// Release gives back a connection returned by Acquire. It is kept for
+// reuse unless it is older than MaxLifetime, Healthy reports it is not
+// usable, the pool is closed, or it was dialed by the CreateEphemeral
+// policy, in which case it is closed.
+func (p *ConnectionPool) Release(c Conn) {
+	p.lock.Lock()
+	ephemeral := p.closeEphemeral(c)
+	p.lock.Unlock()
+
+	if ephemeral {
+		return
+	}
+
+	healthy := p.Healthy == nil || p.Healthy(c)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	opened, ok := p.opened[c]
+	if !ok {
+		return
+	}
+
+	if !healthy || p.closed || p.expired(opened) {
+		p.forget(c)
+		_ = c.Close()
+
+		return
+	}
+
+	p.idle = append(p.idle, idleConn{conn: c, opened: opened})
+	p.notify()
+}
+
+// Discard closes a connection returned by Acquire instead of releasing
+// it, e.g. after a write to it failed.
+func (p *ConnectionPool) Discard(c Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closeEphemeral(c) {
+		return
+	}
+
+	if _, ok := p.opened[c]; !ok {
+		return
+	}
+
+	p.forget(c)
+	_ = c.Close()
+}
+
+// Stats returns the number of idle and active connections, and of Acquire
+// calls waiting for one.
+func (p *ConnectionPool) Stats() Stats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return Stats{
+		Idle:      len(p.idle),
+		Active:    len(p.opened) - len(p.idle),
+		Waiting:   p.waiting,
+		Ephemeral: len(p.ephemeral),
+	}
+}
+
+func (p *ConnectionPool) maintainEvery(ctx context.Context) {
+	defer p.maintain.Done()
+
+	interval := p.MaintainInterval
+	if interval <= 0 {
+		interval = DefaultMaintainInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.expireIdle()
+		p.fillIdle(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireIdle closes the idle connections older than MaxLifetime.
+func (p *ConnectionPool) expireIdle() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	kept := p.idle[:0]
+
+	for _, ic := range p.idle {
+		if p.expired(ic.opened) {
+			p.forget(ic.conn)
+			_ = ic.conn.Close()
+
+			continue
+		}
+
+		kept = append(kept, ic)
+	}
+
+	p.idle = kept
+}
+
+// fillIdle dials until MinIdle connections are idle or MaxSize are open.
+func (p *ConnectionPool) fillIdle(ctx context.Context) {
+	for ctx.Err() == nil {
+		p.lock.Lock()
+
+		room := len(p.idle)+p.dialing < p.MinIdle && p.size() < p.maxSize()
+		if room {
+			p.dialing++
+		}
+
+		p.lock.Unlock()
+
+		if !room {
+			return
+		}
+
+		c, err := p.dial(ctx)
+		if err != nil {
+			// errors caused by Close are not worth reporting
+			if p.OnDialError != nil && ctx.Err() == nil && !errors.Is(err, ErrPoolClosed) {
+				p.OnDialError(err)
+			}
+
+			return
+		}
+
+		p.Release(c)
+	}
+}
+
+// Close stops the background goroutine and closes the idle connections.
+// Active connections are closed when they are released. Acquire fails
+// with ErrPoolClosed afterwards.
+func (p *ConnectionPool) Close() error {
+	p.lock.Lock()
+	p.closed = true
+	stop := p.stop
+	p.stop = nil
+	idle := p.idle
+	p.idle = nil
+
+	for _, ic := range idle {
+		delete(p.opened, ic.conn)
+	}
+
+	p.notify()
+	p.lock.Unlock()
+
+	if stop != nil {
+		stop()
+		p.maintain.Wait()
+	}
+
+	var err error
+
+	for _, ic := range idle {
+		if cerr := ic.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
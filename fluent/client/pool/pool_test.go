@@ -0,0 +1,396 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client/pool"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type fakeConn struct {
+	closed int32
+}
+
+func (c *fakeConn) Close() error {
+	atomic.AddInt32(&c.closed, 1)
+	return nil
+}
+
+func (c *fakeConn) Closed() bool {
+	return atomic.LoadInt32(&c.closed) > 0
+}
+
+var _ = Describe("ConnectionPool", func() {
+	var (
+		pool    *ConnectionPool
+		dials   int32
+		open    int32
+		maxOpen int32
+		dialErr error
+		lock    sync.Mutex
+		ctx     context.Context
+	)
+
+	dialed := func() int32 { return atomic.LoadInt32(&dials) }
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		dials, open, maxOpen, dialErr = 0, 0, 0, nil
+
+		pool = NewConnectionPool(func(context.Context) (Conn, error) {
+			atomic.AddInt32(&dials, 1)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if dialErr != nil {
+				return nil, dialErr
+			}
+
+			if n := atomic.AddInt32(&open, 1); n > maxOpen {
+				maxOpen = n
+			}
+
+			return &fakeConn{}, nil
+		})
+		pool.MaxSize = 2
+		pool.MaintainInterval = 10 * time.Millisecond
+	})
+
+	AfterEach(func() {
+		Expect(pool.Close()).To(Succeed())
+	})
+
+	It("reuses released connections", func() {
+		c, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Stats()).To(Equal(Stats{Active: 1}))
+
+		pool.Release(c)
+		Expect(pool.Stats()).To(Equal(Stats{Idle: 1}))
+
+		again, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(c))
+		Expect(dialed()).To(BeEquivalentTo(1))
+	})
+
+	It("waits for a connection once MaxSize are active", func() {
+		first, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		acquired := make(chan Conn)
+
+		go func() {
+			defer GinkgoRecover()
+
+			c, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			acquired <- c
+		}()
+
+		Eventually(pool.Stats).Should(Equal(Stats{Active: 2, Waiting: 1}))
+		Consistently(acquired).ShouldNot(Receive())
+
+		pool.Release(first)
+		Eventually(acquired).Should(Receive(BeIdenticalTo(first)))
+		Expect(pool.Stats()).To(Equal(Stats{Active: 2}))
+		Expect(dialed()).To(BeEquivalentTo(2))
+	})
+
+	It("stops waiting when the context is done", func() {
+		for i := 0; i < 2; i++ {
+			_, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		_, err := pool.Acquire(ctx)
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+		Expect(pool.Stats().Waiting).To(BeZero())
+	})
+
+	It("dials in place of a discarded connection", func() {
+		for i := 0; i < 2; i++ {
+			c, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			if i == 0 {
+				pool.Discard(c)
+				Expect(c.(*fakeConn).Closed()).To(BeTrue())
+			}
+		}
+
+		_, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(dialed()).To(BeEquivalentTo(3))
+	})
+
+	It("closes unhealthy connections on release", func() {
+		pool.Healthy = func(Conn) bool { return false }
+
+		c, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		pool.Release(c)
+		Expect(c.(*fakeConn).Closed()).To(BeTrue())
+		Expect(pool.Stats()).To(Equal(Stats{}))
+	})
+
+	It("closes connections older than MaxLifetime", func() {
+		pool.MaxLifetime = 30 * time.Millisecond
+
+		c, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		idle, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		pool.Release(idle)
+
+		time.Sleep(40 * time.Millisecond)
+
+		pool.Release(c)
+		Expect(c.(*fakeConn).Closed()).To(BeTrue())
+		Eventually(idle.(*fakeConn).Closed).Should(BeTrue())
+
+		fresh, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fresh).ToNot(BeIdenticalTo(c))
+		Expect(fresh).ToNot(BeIdenticalTo(idle))
+	})
+
+	It("keeps MinIdle connections open", func() {
+		pool.MinIdle = 2
+		pool.MaxSize = 3
+		pool.Start()
+
+		Eventually(pool.Stats).Should(Equal(Stats{Idle: 2}))
+
+		_, err := pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(pool.Stats).Should(Equal(Stats{Idle: 2, Active: 1}))
+
+		_, err = pool.Acquire(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		// MaxSize leaves room for only one more
+		Eventually(pool.Stats).Should(Equal(Stats{Idle: 1, Active: 2}))
+		Consistently(dialed).Should(BeEquivalentTo(3))
+	})
+
+	It("reports background dial errors", func() {
+		dialErr = errors.New("refused")
+		errs := make(chan error, 10)
+
+		pool.MinIdle = 1
+		pool.OnDialError = func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		}
+		pool.Start()
+
+		Eventually(errs).Should(Receive(MatchError("refused")))
+
+		_, err := pool.Acquire(ctx)
+		Expect(err).To(MatchError("refused"))
+		Expect(pool.Stats()).To(Equal(Stats{}))
+	})
+
+	It("never opens more than MaxSize connections", func() {
+		pool.MaxSize = 3
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				for j := 0; j < 20; j++ {
+					c, err := pool.Acquire(ctx)
+					Expect(err).ToNot(HaveOccurred())
+
+					if (i+j)%7 == 0 {
+						atomic.AddInt32(&open, -1)
+						pool.Discard(c)
+					} else {
+						pool.Release(c)
+					}
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		Expect(maxOpen).To(BeNumerically("<=", 3))
+	})
+
+	Describe("when exhausted", func() {
+		var active []Conn
+
+		JustBeforeEach(func() {
+			active = nil
+
+			for i := 0; i < 2; i++ {
+				c, err := pool.Acquire(ctx)
+				Expect(err).ToNot(HaveOccurred())
+
+				active = append(active, c)
+			}
+		})
+
+		It("waits for at most AcquireTimeout", func() {
+			pool.AcquireTimeout = 20 * time.Millisecond
+
+			start := time.Now()
+			_, err := pool.Acquire(ctx)
+			Expect(err).To(MatchError(ErrPoolExhausted))
+			Expect(time.Since(start)).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(pool.Stats().Waiting).To(BeZero())
+		})
+
+		It("returns a connection released within AcquireTimeout", func() {
+			pool.AcquireTimeout = time.Second
+
+			time.AfterFunc(10*time.Millisecond, func() { pool.Release(active[0]) })
+
+			c, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(BeIdenticalTo(active[0]))
+		})
+
+		When("the policy is ReturnError", func() {
+			BeforeEach(func() {
+				pool.ExhaustionPolicy = ReturnError
+				pool.AcquireTimeout = time.Second
+			})
+
+			It("fails without waiting", func() {
+				start := time.Now()
+				_, err := pool.Acquire(ctx)
+				Expect(err).To(MatchError(ErrPoolExhausted))
+				Expect(time.Since(start)).To(BeNumerically("<", 100*time.Millisecond))
+				Expect(dialed()).To(BeEquivalentTo(2))
+			})
+		})
+
+		When("the policy is CreateEphemeral", func() {
+			BeforeEach(func() {
+				pool.ExhaustionPolicy = CreateEphemeral
+			})
+
+			It("dials a connection that is closed on release", func() {
+				c, err := pool.Acquire(ctx)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dialed()).To(BeEquivalentTo(3))
+				Expect(pool.Stats()).To(Equal(Stats{Active: 2, Ephemeral: 1}))
+
+				pool.Release(c)
+				Expect(c.(*fakeConn).Closed()).To(BeTrue())
+				Expect(pool.Stats()).To(Equal(Stats{Active: 2}))
+
+				pool.Release(active[0])
+				Expect(pool.Stats()).To(Equal(Stats{Idle: 1, Active: 1}))
+				Expect(active[0].(*fakeConn).Closed()).To(BeFalse())
+			})
+
+			It("closes a discarded one", func() {
+				c, err := pool.Acquire(ctx)
+				Expect(err).ToNot(HaveOccurred())
+
+				pool.Discard(c)
+				Expect(c.(*fakeConn).Closed()).To(BeTrue())
+				Expect(pool.Stats()).To(Equal(Stats{Active: 2}))
+			})
+
+			It("returns the dial error", func() {
+				dialErr = errors.New("refused")
+
+				_, err := pool.Acquire(ctx)
+				Expect(err).To(MatchError("refused"))
+				Expect(pool.Stats()).To(Equal(Stats{Active: 2}))
+			})
+		})
+	})
+
+	When("closed", func() {
+		It("closes idle connections and fails Acquire", func() {
+			active, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+
+			idle, err := pool.Acquire(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			pool.Release(idle)
+
+			Expect(pool.Close()).To(Succeed())
+			Expect(idle.(*fakeConn).Closed()).To(BeTrue())
+
+			_, err = pool.Acquire(ctx)
+			Expect(err).To(MatchError(ErrPoolClosed))
+
+			Expect(active.(*fakeConn).Closed()).To(BeFalse())
+			pool.Release(active)
+			Expect(active.(*fakeConn).Closed()).To(BeTrue())
+		})
+
+		It("fails the waiting Acquire calls", func() {
+			for i := 0; i < 2; i++ {
+				_, err := pool.Acquire(ctx)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			waiting := make(chan error)
+
+			go func() {
+				_, err := pool.Acquire(ctx)
+				waiting <- err
+			}()
+
+			Eventually(pool.Stats).Should(HaveField("Waiting", 1))
+
+			Expect(pool.Close()).To(Succeed())
+			Eventually(waiting).Should(Receive(MatchError(ErrPoolClosed)))
+		})
+	})
+})
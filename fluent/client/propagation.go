@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// OptionsCarrier is a propagation.TextMapCarrier backed by the options of
+// a Fluent message. Only the W3C trace context keys, traceparent and
+// tracestate, are carried; other keys, such as baggage, are dropped.
+type OptionsCarrier struct {
+	Options *protocol.MessageOptions
+}
+
+var _ propagation.TextMapCarrier = OptionsCarrier{}
+
+func (c OptionsCarrier) Get(key string) string {
+	switch key {
+	case protocol.OptTraceParent:
+		return c.Options.TraceParent
+	case protocol.OptTraceState:
+		return c.Options.TraceState
+	default:
+		return ""
+	}
+}
+
+func (c OptionsCarrier) Set(key, value string) {
+	switch key {
+	case protocol.OptTraceParent:
+		c.Options.TraceParent = value
+	case protocol.OptTraceState:
+		c.Options.TraceState = value
+	}
+}
+
+func (c OptionsCarrier) Keys() []string {
+	var keys []string
+
+	if c.Options.TraceParent != "" {
+		keys = append(keys, protocol.OptTraceParent)
+	}
+
+	if c.Options.TraceState != "" {
+		keys = append(keys, protocol.OptTraceState)
+	}
+
+	return keys
+}
+
+// injectTraceContext injects the trace context of ctx into the options of
+// e with the OTelPropagator, if one is set. Messages of types without
+// options are left unchanged.
+func (c *WSClient) injectTraceContext(ctx context.Context, e msgp.Encodable) {
+	if c.OTelPropagator == nil {
+		return
+	}
+
+	if opts := MessageOptions(e); opts != nil {
+		c.OTelPropagator.Inject(ctx, OptionsCarrier{Options: opts})
+	}
+}
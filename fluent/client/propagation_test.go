@@ -0,0 +1,81 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("OTelPropagator", func() {
+	var (
+		conn *wsfakes.FakeConnection
+		cli  *WSClient
+		ctx  context.Context
+		sc   trace.SpanContext
+	)
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		cli = NewWS(WSConnectionOptions{
+			Factory:        factory,
+			OTelPropagator: propagation.TraceContext{},
+		})
+		Expect(cli.Connect()).To(Succeed())
+
+		sc = trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{0x01},
+			SpanID:     trace.SpanID{0x02},
+			TraceFlags: trace.FlagsSampled,
+		})
+		ctx = trace.ContextWithSpanContext(context.Background(), sc)
+	})
+
+	It("injects the trace context into the encoded options", func() {
+		Expect(cli.SendMessageContext(ctx, protocol.NewMessage("foo", map[string]string{"oi": "hi"}))).To(Succeed())
+		Expect(conn.WriteCallCount()).To(Equal(1))
+
+		var decoded protocol.Message
+		_, err := decoded.UnmarshalMsg(conn.WriteArgsForCall(0))
+		Expect(err).ToNot(HaveOccurred())
+
+		extracted := propagation.TraceContext{}.Extract(context.Background(), OptionsCarrier{Options: decoded.Options})
+		Expect(trace.SpanContextFromContext(extracted).TraceID()).To(Equal(sc.TraceID()))
+		Expect(trace.SpanContextFromContext(extracted).SpanID()).To(Equal(sc.SpanID()))
+	})
+})
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+const ProxyAuthorizationHeader = "Proxy-Authorization"
+
+// ProxyDialFunc opens a network connection, e.g. through a proxy.
+type ProxyDialFunc func(network, addr string) (net.Conn, error)
+
+// SOCKS5ProxyDialer returns a ProxyDialFunc that connects through the
+// SOCKS5 proxy at proxyAddr. If username is empty, no authentication is
+// attempted.
+func SOCKS5ProxyDialer(proxyAddr, username, password string) ProxyDialFunc {
+	var auth *proxy.Auth
+	if username != "" {
+		auth = &proxy.Auth{User: username, Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, proxy.Direct)
+	if err != nil {
+		return func(_, _ string) (net.Conn, error) {
+			return nil, fmt.Errorf("socks5 proxy: %w", err)
+		}
+	}
+
+	return dialer.Dial
+}
+
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// validateProxyURL checks that the proxy scheme is one that
+// DefaultWSConnectionFactory can dial.
+func validateProxyURL(u *url.URL) error {
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+}
+
+// dialProxy opens a tunnel to addr through the HTTP CONNECT proxy at
+// proxyURL, using TLS to reach the proxy if its scheme is "https".
+// Credentials in proxyURL.User are sent as basic authentication.
+func dialProxy(ctx context.Context, dial dialContextFunc, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy: %w", err)
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: proxyURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		})
+
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake: %w", err)
+		}
+
+		conn = tlsConn
+	}
+
+	header := http.Header{}
+
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		header.Set(ProxyAuthorizationHeader, "Basic "+creds)
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: header,
+	}
+
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+
+	// the peer does not speak until spoken to, so nothing beyond the
+	// response is buffered and the reader can be discarded
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT: %s", resp.Status)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,231 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+// startSOCKS5 runs a minimal SOCKS5 proxy that supports CONNECT and,
+// if user is not empty, username/password authentication. The address of
+// every tunneled connection is sent on the returned channel.
+func startSOCKS5(user, pass string) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+	DeferCleanup(ln.Close)
+
+	tunneled := make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSOCKS5(conn, user, pass, tunneled)
+		}
+	}()
+
+	return ln.Addr().String(), tunneled
+}
+
+func serveSOCKS5(conn net.Conn, user, pass string, tunneled chan string) {
+	defer conn.Close()
+
+	buf := make([]byte, 262)
+
+	// greeting: version, method count, methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return
+	}
+
+	if user == "" {
+		_, _ = conn.Write([]byte{5, 0})
+	} else {
+		_, _ = conn.Write([]byte{5, 2})
+
+		// version, username, password
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+
+		u := make([]byte, buf[1])
+		if _, err := io.ReadFull(conn, u); err != nil {
+			return
+		}
+
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+
+		p := make([]byte, buf[0])
+		if _, err := io.ReadFull(conn, p); err != nil {
+			return
+		}
+
+		if string(u) != user || string(p) != pass {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+
+		_, _ = conn.Write([]byte{1, 0})
+	}
+
+	// request: version, command, reserved, address type
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+
+	var host string
+
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+
+		host = string(buf[:n])
+	default:
+		return
+	}
+
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+
+	tunneled <- addr
+
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+
+	_, _ = io.Copy(conn, upstream)
+}
+
+var _ = Describe("SOCKS5ProxyDialer", func() {
+	var (
+		echoAddr string
+	)
+
+	BeforeEach(func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(ln.Close)
+
+		echoAddr = ln.Addr().String()
+
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					defer conn.Close()
+					_, _ = io.Copy(conn, conn)
+				}()
+			}
+		}()
+	})
+
+	roundTrip := func(dial ProxyDialFunc) error {
+		conn, err := dial("tcp", echoAddr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if _, err = conn.Write([]byte("oi")); err != nil {
+			return err
+		}
+
+		reply := make([]byte, 2)
+		if _, err = io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+
+		Expect(string(reply)).To(Equal("oi"))
+
+		return nil
+	}
+
+	It("dials through an unauthenticated proxy", func() {
+		proxyAddr, tunneled := startSOCKS5("", "")
+
+		Expect(roundTrip(SOCKS5ProxyDialer(proxyAddr, "", ""))).To(Succeed())
+		Expect(tunneled).To(Receive(Equal(echoAddr)))
+	})
+
+	It("authenticates with the proxy", func() {
+		proxyAddr, tunneled := startSOCKS5("user", "pass")
+
+		Expect(roundTrip(SOCKS5ProxyDialer(proxyAddr, "user", "pass"))).To(Succeed())
+		Expect(tunneled).To(Receive(Equal(echoAddr)))
+	})
+
+	It("fails with the wrong credentials", func() {
+		proxyAddr, tunneled := startSOCKS5("user", "pass")
+
+		Expect(roundTrip(SOCKS5ProxyDialer(proxyAddr, "user", "nope"))).ToNot(Succeed())
+		Expect(tunneled).ToNot(Receive())
+	})
+})
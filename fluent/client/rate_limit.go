@@ -0,0 +1,73 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"golang.org/x/time/rate"
+)
+
+// NewWSClientWithRateLimit is like NewWS, but the client sends at most
+// rps messages per second, with bursts of up to one second's worth.
+func NewWSClientWithRateLimit(opts WSConnectionOptions, rps float64) *WSClient {
+	opts.RateLimit = newRateLimiter(rps, 0)
+
+	return NewWS(opts)
+}
+
+// newRateLimiter returns a limiter of rps per second. If burst is zero,
+// bursts of up to one second's worth are allowed.
+func newRateLimiter(rps float64, burst int) *rate.Limiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(rps))
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// waitRateLimit blocks until the RateLimit, if any, allows another send.
+func (c *WSClient) waitRateLimit(ctx context.Context) error {
+	limiter := c.RateLimit
+	if s := c.reloadedSettings(); s != nil {
+		limiter = s.rateLimit
+	}
+
+	if limiter == nil {
+		return nil
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limit: %w", err)
+	}
+
+	return nil
+}
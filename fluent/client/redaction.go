@@ -0,0 +1,160 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// Redacted replaces the values masked by MaskAll.
+const Redacted = "[REDACTED]"
+
+// RedactionFunc returns the value to send in place of v.
+type RedactionFunc func(v interface{}) interface{}
+
+// MaskAll replaces any value with Redacted.
+func MaskAll(_ interface{}) interface{} {
+	return Redacted
+}
+
+// HashSHA256 replaces a value with the hex encoded SHA-256 digest of its
+// string form, so that equal values can still be correlated.
+func HashSHA256(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		s = fmt.Sprint(v)
+	}
+
+	sum := sha256.Sum256([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// TruncateTo shortens strings to at most n runes. Other values are left
+// alone.
+func TruncateTo(n int) RedactionFunc {
+	return func(v interface{}) interface{} {
+		s, ok := v.(string)
+		if !ok {
+			return v
+		}
+
+		if runes := []rune(s); len(runes) > n {
+			return string(runes[:n])
+		}
+
+		return s
+	}
+}
+
+// Redact returns record with the fields named by the keys of fields
+// replaced by the result of their RedactionFunc. A key may name a nested
+// field using dot notation, e.g. "user.email". Fields that are missing
+// are ignored. Record is not modified: the maps along the path to a
+// redacted field are copied.
+func Redact(record map[string]interface{}, fields map[string]RedactionFunc) map[string]interface{} {
+	for path, redact := range fields {
+		record = redactPath(record, strings.Split(path, "."), redact)
+	}
+
+	return record
+}
+
+func redactPath(record map[string]interface{}, path []string, redact RedactionFunc) map[string]interface{} {
+	v, ok := record[path[0]]
+	if !ok {
+		return record
+	}
+
+	if len(path) > 1 {
+		nested, ok := v.(map[string]interface{})
+		if !ok {
+			return record
+		}
+
+		v = redactPath(nested, path[1:], redact)
+	} else {
+		v = redact(v)
+	}
+
+	redacted := make(map[string]interface{}, len(record))
+	for k, rv := range record {
+		redacted[k] = rv
+	}
+
+	redacted[path[0]] = v
+
+	return redacted
+}
+
+func redactRecord(record interface{}, fields map[string]RedactionFunc) interface{} {
+	if m, ok := record.(map[string]interface{}); ok {
+		return Redact(m, fields)
+	}
+
+	return record
+}
+
+// RedactionTransformer is Middleware that redacts fields of the records
+// in Message, MessageExt and ForwardMessage messages, as Redact does, before
+// they are encoded. Only records of type map[string]interface{} are
+// redacted; messages containing records that are already encoded, such
+// as PackedForwardMessage, pass through unchanged. The caller's message
+// and records are not modified.
+func RedactionTransformer(fields map[string]RedactionFunc) Middleware {
+	return func(next SendFunc) SendFunc {
+		return func(ctx context.Context, e msgp.Encodable) error {
+			switch msg := e.(type) {
+			case *protocol.Message:
+				redacted := *msg
+				redacted.Record = redactRecord(msg.Record, fields)
+				e = &redacted
+			case *protocol.MessageExt:
+				redacted := *msg
+				redacted.Record = redactRecord(msg.Record, fields)
+				e = &redacted
+			case *protocol.ForwardMessage:
+				redacted := *msg
+				redacted.Entries = make(protocol.EntryList, len(msg.Entries))
+
+				for i, entry := range msg.Entries {
+					entry.Record = redactRecord(entry.Record, fields)
+					redacted.Entries[i] = entry
+				}
+
+				e = &redacted
+			}
+
+			return next(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("Redaction", func() {
+	var record map[string]interface{}
+
+	BeforeEach(func() {
+		record = map[string]interface{}{
+			"msg": "hello",
+			"user": map[string]interface{}{
+				"name":  "Robin",
+				"email": "robin@example.com",
+			},
+		}
+	})
+
+	Describe("Redact", func() {
+		It("redacts nested fields without modifying the record", func() {
+			redacted := Redact(record, map[string]RedactionFunc{
+				"user.email": MaskAll,
+				"msg":        TruncateTo(2),
+			})
+
+			Expect(redacted).To(Equal(map[string]interface{}{
+				"msg": "he",
+				"user": map[string]interface{}{
+					"name":  "Robin",
+					"email": Redacted,
+				},
+			}))
+
+			Expect(record["msg"]).To(Equal("hello"))
+			Expect(record["user"]).To(HaveKeyWithValue("email", "robin@example.com"))
+		})
+
+		It("ignores missing fields", func() {
+			redacted := Redact(record, map[string]RedactionFunc{
+				"user.phone": MaskAll,
+				"msg.nested": MaskAll,
+				"nope":       MaskAll,
+			})
+
+			Expect(redacted).To(Equal(record))
+		})
+	})
+
+	Describe("RedactionFuncs", func() {
+		It("hashes the string form of values", func() {
+			Expect(HashSHA256("oi")).To(Equal("87f633634cc4b02f628685651f0a29b7bfa22a0bd841f725c6772dd00a58d489"))
+			Expect(HashSHA256(42)).To(Equal(HashSHA256("42")))
+			Expect(HashSHA256("oi")).ToNot(Equal(HashSHA256("hi")))
+		})
+
+		It("truncates by rune and leaves other values alone", func() {
+			Expect(TruncateTo(2)("héllo")).To(Equal("hé"))
+			Expect(TruncateTo(10)("hello")).To(Equal("hello"))
+			Expect(TruncateTo(1)(42)).To(Equal(42))
+		})
+	})
+
+	Describe("RedactionTransformer", func() {
+		var sent msgp.Encodable
+
+		send := RedactionTransformer(map[string]RedactionFunc{"user.email": MaskAll})(
+			func(ctx context.Context, e msgp.Encodable) error {
+				sent = e
+				return nil
+			})
+
+		It("redacts Message records", func() {
+			msg := protocol.NewMessage("foo", record)
+			Expect(send(context.Background(), msg)).To(Succeed())
+
+			Expect(sent.(*protocol.Message).Record).To(HaveKeyWithValue("user", HaveKeyWithValue("email", Redacted)))
+			Expect(msg.Record).To(HaveKeyWithValue("user", HaveKeyWithValue("email", "robin@example.com")))
+		})
+
+		It("redacts every ForwardMessage entry", func() {
+			msg := protocol.NewForwardMessage("foo", protocol.EntryList{
+				{Timestamp: protocol.EventTimeNow(), Record: record},
+				{Timestamp: protocol.EventTimeNow(), Record: record},
+			})
+			Expect(send(context.Background(), msg)).To(Succeed())
+
+			for _, entry := range sent.(*protocol.ForwardMessage).Entries {
+				Expect(entry.Record).To(HaveKeyWithValue("user", HaveKeyWithValue("email", Redacted)))
+			}
+
+			Expect(msg.Entries[0].Record).To(HaveKeyWithValue("user", HaveKeyWithValue("email", "robin@example.com")))
+		})
+
+		It("passes other messages through", func() {
+			msg := protocol.NewPackedForwardMessageFromBytes("foo", []byte{0x90})
+			Expect(send(context.Background(), msg)).To(Succeed())
+			Expect(sent).To(BeIdenticalTo(msg))
+		})
+	})
+})
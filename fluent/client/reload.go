@@ -0,0 +1,135 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// reloadedSettings are the settings ReloadConfig changes without
+// reconnecting. Once stored, they are used in place of the fields they
+// mirror; logger and metrics only if they are not nil.
+type reloadedSettings struct {
+	logger          Logger
+	metrics         MetricsCollector
+	rateLimit       *rate.Limiter
+	retryPolicy     RetryPolicy
+	maxMessageBytes int64
+	tagPrefix       string
+}
+
+func (c *WSClient) reloadedSettings() *reloadedSettings {
+	s, _ := c.reloaded.Load().(*reloadedSettings)
+	return s
+}
+
+func (c *WSClient) retryPolicy() RetryPolicy {
+	if s := c.reloadedSettings(); s != nil {
+		return s.retryPolicy
+	}
+
+	return c.RetryPolicy
+}
+
+func (c *WSClient) maxMessageBytes() int64 {
+	if s := c.reloadedSettings(); s != nil {
+		return s.maxMessageBytes
+	}
+
+	return c.MaxMessageBytes
+}
+
+func (c *WSClient) tagPrefix() string {
+	if s := c.reloadedSettings(); s != nil {
+		return s.tagPrefix
+	}
+
+	return c.TagPrefix
+}
+
+// ReloadConfig applies cfg to the running client, as if it had been built
+// by cfg.Build. The rate limit, retry policy, message size limit, tag
+// prefix, metrics and logger apply at once, to the sends that follow; the
+// fields they mirror, such as RateLimit, are left as they were. A change
+// to the server, auth, TLS or the ping interval or write timeout of the
+// connection section is applied by HotReconnect, if the client is
+// connected, so that no send fails. If the configuration is invalid,
+// nothing is applied and the error is returned; if HotReconnect fails, the
+// old connection is kept, the new settings are used from the next
+// reconnect, and the error is returned.
+//
+// Concurrent calls are applied one after the other.
+func (c *WSClient) ReloadConfig(cfg WSClientConfig) error {
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+
+	factory, err := cfg.factory()
+	if err != nil {
+		return err
+	}
+
+	settings, err := cfg.settings(factory.URL)
+	if err != nil {
+		return err
+	}
+
+	c.reloaded.Store(settings)
+
+	old := c.config
+	c.config = &cfg
+
+	if old != nil && !cfg.breaksConnection(*old) {
+		c.logger().Infof("configuration reloaded")
+		return nil
+	}
+
+	c.sessionLock.Lock()
+	c.ConnectionFactory = factory
+	c.ConnectionOptions.PingInterval = cfg.Connection.PingInterval.Duration
+	c.ConnectionOptions.WriteTimeout = cfg.Connection.WriteTimeout.Duration
+	connected := c.Session() != nil
+	c.sessionLock.Unlock()
+
+	if !connected {
+		c.logger().Infof("configuration reloaded")
+		return nil
+	}
+
+	c.logger().Infof("configuration reloaded; reconnecting to %s", factory.URL)
+
+	return c.HotReconnect(context.Background())
+}
+
+// breaksConnection reports whether changing from old to cfg needs a new
+// connection.
+func (cfg WSClientConfig) breaksConnection(old WSClientConfig) bool {
+	return cfg.Server != old.Server ||
+		cfg.Auth != old.Auth ||
+		cfg.TLS != old.TLS ||
+		cfg.Connection.PingInterval != old.Connection.PingInterval ||
+		cfg.Connection.WriteTimeout != old.Connection.WriteTimeout
+}
@@ -0,0 +1,193 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingServer is a websocket server that counts the upgrades and the
+// messages it receives.
+type countingServer struct {
+	*httptest.Server
+	upgrades int64
+	received int64
+}
+
+func newCountingServer() *countingServer {
+	cs := &countingServer{}
+
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+
+		wc, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer wc.Close()
+
+		atomic.AddInt64(&cs.upgrades, 1)
+
+		for {
+			if _, _, err := wc.ReadMessage(); err != nil {
+				return
+			}
+
+			atomic.AddInt64(&cs.received, 1)
+		}
+	}))
+
+	return cs
+}
+
+func (cs *countingServer) config() WSClientConfig {
+	host, port, err := net.SplitHostPort(cs.Listener.Addr().String())
+	Expect(err).ToNot(HaveOccurred())
+
+	p, err := strconv.Atoi(port)
+	Expect(err).ToNot(HaveOccurred())
+
+	return WSClientConfig{Server: ServerConfig{Host: host, Port: p}}
+}
+
+func (cs *countingServer) Upgrades() int64 {
+	return atomic.LoadInt64(&cs.upgrades)
+}
+
+func (cs *countingServer) Received() int64 {
+	return atomic.LoadInt64(&cs.received)
+}
+
+var _ = Describe("WSClient ReloadConfig", func() {
+	var (
+		first, second *countingServer
+		cli           *WSClient
+		cfg           WSClientConfig
+	)
+
+	send := func() error {
+		return cli.Send(protocol.NewMessage("tag", map[string]interface{}{"a": "b"}))
+	}
+
+	BeforeEach(func() {
+		first, second = newCountingServer(), newCountingServer()
+		cfg = first.config()
+
+		var err error
+		cli, err = cfg.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cli.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = cli.Disconnect()
+		first.Close()
+		second.Close()
+	})
+
+	It("applies settings without reconnecting", func() {
+		cfg.Connection.MaxMessageBytes = 10
+		cfg.RateLimit = RateLimitConfig{PerSecond: 1000}
+		cfg.Retry = RetryConfig{Attempts: 2}
+		cfg.TagPrefix = "payments."
+
+		Expect(cli.ReloadConfig(cfg)).To(Succeed())
+		Expect(first.Upgrades()).To(BeEquivalentTo(1))
+
+		Expect(send()).To(MatchError(ErrMessageTooLarge))
+
+		// the fields are left as they were
+		Expect(cli.MaxMessageBytes).To(BeZero())
+		Expect(cli.RateLimit).To(BeNil())
+		Expect(cli.TagPrefix).To(BeEmpty())
+	})
+
+	It("moves to a new server with HotReconnect", func() {
+		Expect(send()).To(Succeed())
+		Eventually(first.Received).Should(BeEquivalentTo(1))
+
+		Expect(cli.ReloadConfig(second.config())).To(Succeed())
+		Expect(second.Upgrades()).To(BeEquivalentTo(1))
+
+		Expect(send()).To(Succeed())
+		Eventually(second.Received).Should(BeEquivalentTo(1))
+		Expect(first.Received()).To(BeEquivalentTo(1))
+	})
+
+	It("reconnects when the connection options change", func() {
+		cfg.Connection.WriteTimeout = Duration{time.Second}
+
+		Expect(cli.ReloadConfig(cfg)).To(Succeed())
+		Expect(first.Upgrades()).To(BeEquivalentTo(2))
+		Expect(cli.ConnectionOptions.WriteTimeout).To(Equal(time.Second))
+	})
+
+	It("applies nothing from an invalid configuration", func() {
+		bad := cfg
+		bad.Connection.MaxMessageBytes = 10
+		bad.Log.Level = "loud"
+
+		Expect(cli.ReloadConfig(bad)).To(MatchError(`unknown log level "loud"`))
+		Expect(send()).To(Succeed())
+	})
+
+	It("serializes concurrent calls", func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				next := first.config()
+				if i%2 == 1 {
+					next = second.config()
+				}
+
+				next.RateLimit.PerSecond = float64(1000 + i)
+
+				Expect(cli.ReloadConfig(next)).To(Succeed())
+				Expect(send()).To(Succeed())
+			}(i)
+		}
+
+		wg.Wait()
+		Expect(first.Upgrades() + second.Upgrades()).To(BeNumerically("<=", 11))
+	})
+})
@@ -0,0 +1,272 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReorderWindow  = 100
+	DefaultReorderTimeout = time.Second
+)
+
+// ReorderBuffer is a MessageHandler, for ListenWith, that passes each
+// tag's messages to its Handler in the order of their "seq" option, as set
+// by a sender with Sequence enabled. A message that arrives ahead of its
+// turn is held until the messages before it arrive.
+//
+// A missing message is given up on once more than Window messages of its
+// tag are held, or the oldest of them has been held for Timeout, and the
+// held messages are delivered with the gap. Messages without a seq, and
+// those that arrive after their turn was given up on, are delivered at
+// once.
+type ReorderBuffer struct {
+	// Handler receives the messages in order.
+	Handler MessageHandler
+	// Window is the number of messages of a tag that may be held. If zero,
+	// DefaultReorderWindow is used.
+	Window int
+	// Timeout is how long a message may be held. If zero,
+	// DefaultReorderTimeout is used.
+	Timeout time.Duration
+	// OnDeliverError, if not nil, is called when the Handler fails on a
+	// message delivered after a Timeout, which no Handle call returns.
+	OnDeliverError func(tag string, err error)
+	tags           map[string]*reorderTag
+	lock           sync.Mutex
+	stop           chan struct{}
+	expiries       sync.WaitGroup
+}
+
+// reorderTag is the state of one tag's messages.
+type reorderTag struct {
+	// next is the seq of the next message to deliver.
+	next uint64
+	held map[uint64][]byte
+	// since is when the oldest held message was held.
+	since time.Time
+}
+
+func NewReorderBuffer(handler MessageHandler) *ReorderBuffer {
+	return &ReorderBuffer{
+		Handler: handler,
+		Window:  DefaultReorderWindow,
+		Timeout: DefaultReorderTimeout,
+	}
+}
+
+// Handle delivers msg, and any held messages that follow it, or holds it
+// until its turn. It returns the first error from the Handler.
+func (b *ReorderBuffer) Handle(msg []byte) error {
+	tag, seq, ok := messageSeq(msg)
+	if !ok {
+		return b.Handler(msg)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.tags == nil {
+		b.tags = map[string]*reorderTag{}
+	}
+
+	t, ok := b.tags[tag]
+	if !ok {
+		t = &reorderTag{next: 1, held: map[uint64][]byte{}}
+		b.tags[tag] = t
+	}
+
+	switch {
+	case seq < t.next:
+		return b.Handler(msg)
+	case seq == t.next:
+		t.next++
+		err := b.Handler(msg)
+
+		return firstError(err, b.deliverReady(t))
+	}
+
+	if len(t.held) == 0 {
+		t.since = time.Now()
+	}
+
+	// the connection may reuse its read buffer
+	t.held[seq] = append([]byte(nil), msg...)
+
+	if b.stop == nil {
+		b.stop = make(chan struct{})
+		b.expiries.Add(1)
+
+		go b.expireEvery(b.stop)
+	}
+
+	var err error
+
+	for len(t.held) > b.window() && err == nil {
+		err = b.skipGap(t)
+	}
+
+	return err
+}
+
+func (b *ReorderBuffer) window() int {
+	if b.Window <= 0 {
+		return DefaultReorderWindow
+	}
+
+	return b.Window
+}
+
+func (b *ReorderBuffer) timeout() time.Duration {
+	if b.Timeout <= 0 {
+		return DefaultReorderTimeout
+	}
+
+	return b.Timeout
+}
+
+// deliverReady delivers the held messages that are next in turn. It must
+// be called with the lock held.
+func (b *ReorderBuffer) deliverReady(t *reorderTag) error {
+	var err error
+
+	for {
+		msg, ok := t.held[t.next]
+		if !ok {
+			break
+		}
+
+		delete(t.held, t.next)
+		t.next++
+
+		err = firstError(err, b.Handler(msg))
+	}
+
+	if len(t.held) > 0 {
+		t.since = time.Now()
+	}
+
+	return err
+}
+
+// skipGap gives up on the messages missing before the first held one and
+// delivers those that are then ready. It must be called with the lock
+// held.
+func (b *ReorderBuffer) skipGap(t *reorderTag) error {
+	first := uint64(0)
+	for seq := range t.held {
+		if first == 0 || seq < first {
+			first = seq
+		}
+	}
+
+	t.next = first
+
+	return b.deliverReady(t)
+}
+
+// deliverAll delivers every held message of every tag, in order, skipping
+// the gaps between them if expiredOnly is false, or only those of tags
+// whose oldest message has been held for Timeout if it is true.
+func (b *ReorderBuffer) deliverAll(expiredOnly bool, onError func(tag string, err error)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	tags := make([]string, 0, len(b.tags))
+	for tag := range b.tags {
+		tags = append(tags, tag)
+	}
+
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		t := b.tags[tag]
+		if expiredOnly && (len(t.held) == 0 || time.Since(t.since) < b.timeout()) {
+			continue
+		}
+
+		for len(t.held) > 0 {
+			if err := b.skipGap(t); err != nil {
+				onError(tag, err)
+			}
+		}
+	}
+}
+
+func (b *ReorderBuffer) expireEvery(stop chan struct{}) {
+	defer b.expiries.Done()
+
+	// check often enough that no message is held much longer than Timeout
+	ticker := time.NewTicker(b.timeout() / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		b.deliverAll(true, func(tag string, err error) {
+			if b.OnDeliverError != nil {
+				b.OnDeliverError(tag, err)
+			}
+		})
+	}
+}
+
+// Close stops the Timeout checks and delivers every held message, in
+// order, returning the first error from the Handler. The buffer may be
+// used again afterwards.
+func (b *ReorderBuffer) Close() error {
+	b.lock.Lock()
+	stop := b.stop
+	b.stop = nil
+	b.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		b.expiries.Wait()
+	}
+
+	var err error
+
+	b.deliverAll(false, func(_ string, derr error) {
+		err = firstError(err, derr)
+	})
+
+	return err
+}
+
+func firstError(err, next error) error {
+	if err != nil {
+		return err
+	}
+
+	return next
+}
@@ -0,0 +1,177 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("ReorderBuffer", func() {
+	type delivery struct {
+		tag string
+		seq uint64
+	}
+
+	var (
+		lock      sync.Mutex
+		delivered []delivery
+		buf       *ReorderBuffer
+	)
+
+	frame := func(tag string, seq uint64) []byte {
+		msg := &protocol.Message{
+			Tag:       tag,
+			Timestamp: time.Now().Unix(),
+			Record:    map[string]interface{}{"oi": "hi"},
+		}
+
+		if seq > 0 {
+			msg.Options = &protocol.MessageOptions{Seq: seq}
+		}
+
+		b, err := msg.MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		return b
+	}
+
+	deliveries := func() []delivery {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]delivery(nil), delivered...)
+	}
+
+	BeforeEach(func() {
+		delivered = nil
+		buf = NewReorderBuffer(func(msg []byte) error {
+			var m protocol.Message
+			_, err := m.UnmarshalMsg(msg)
+			Expect(err).ToNot(HaveOccurred())
+
+			d := delivery{tag: m.Tag}
+			if m.Options != nil {
+				d.seq = m.Options.Seq
+			}
+
+			lock.Lock()
+			delivered = append(delivered, d)
+			lock.Unlock()
+
+			return nil
+		})
+	})
+
+	AfterEach(func() {
+		Expect(buf.Close()).To(Succeed())
+	})
+
+	handle := func(tag string, seqs ...uint64) {
+		for _, seq := range seqs {
+			Expect(buf.Handle(frame(tag, seq))).To(Succeed())
+		}
+	}
+
+	It("delivers each tag's messages in order", func() {
+		handle("a", 2)
+		handle("b", 1)
+		handle("a", 4, 1)
+		handle("b", 3, 2)
+		handle("a", 3)
+
+		Expect(deliveries()).To(Equal([]delivery{
+			{"b", 1},
+			{"a", 1}, {"a", 2},
+			{"b", 2}, {"b", 3},
+			{"a", 3}, {"a", 4},
+		}))
+	})
+
+	It("passes messages without a seq straight through", func() {
+		handle("a", 2)
+		handle("a", 0)
+
+		Expect(deliveries()).To(Equal([]delivery{{"a", 0}}))
+	})
+
+	It("gives up on a gap once the window is full", func() {
+		buf.Window = 2
+
+		handle("a", 3, 4)
+		Expect(deliveries()).To(BeEmpty())
+
+		handle("a", 6)
+		Expect(deliveries()).To(Equal([]delivery{{"a", 3}, {"a", 4}}))
+
+		By("delivering a message that arrives after its turn at once")
+		handle("a", 1)
+		Expect(deliveries()).To(Equal([]delivery{{"a", 3}, {"a", 4}, {"a", 1}}))
+
+		By("still holding the messages after the next gap")
+		handle("a", 5)
+		Expect(deliveries()).To(Equal([]delivery{{"a", 3}, {"a", 4}, {"a", 1}, {"a", 5}, {"a", 6}}))
+	})
+
+	It("delivers held messages after the timeout", func() {
+		buf.Timeout = 50 * time.Millisecond
+
+		handle("a", 2, 3)
+		Expect(deliveries()).To(BeEmpty())
+
+		Eventually(deliveries).Should(Equal([]delivery{{"a", 2}, {"a", 3}}))
+
+		handle("a", 4)
+		Expect(deliveries()).To(Equal([]delivery{{"a", 2}, {"a", 3}, {"a", 4}}))
+	})
+
+	It("delivers held messages on Close", func() {
+		handle("a", 5, 3)
+		handle("b", 2)
+
+		Expect(buf.Close()).To(Succeed())
+		Expect(deliveries()).To(Equal([]delivery{{"a", 3}, {"a", 5}, {"b", 2}}))
+	})
+
+	It("returns the Handler's errors", func() {
+		handler := buf.Handler
+		buf.Handler = func(msg []byte) error {
+			_ = handler(msg)
+			return msgp.ErrShortBytes
+		}
+
+		Expect(buf.Handle(frame("a", 2))).To(Succeed())
+		Expect(buf.Handle(frame("a", 1))).To(MatchError(msgp.ErrShortBytes))
+		Expect(deliveries()).To(Equal([]delivery{{"a", 1}, {"a", 2}}))
+
+		buf.Handler = handler
+	})
+})
@@ -0,0 +1,98 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultRetryBaseDelay  = 100 * time.Millisecond
+	DefaultRetryMultiplier = 2.0
+	DefaultRetryMaxDelay   = 30 * time.Second
+)
+
+// RetryPolicy controls how often, and how far apart, reconnect attempts
+// are made.
+type RetryPolicy interface {
+	// NextDelay returns how long to wait after the given failed attempt
+	// before making the next one. Attempts are numbered from 1.
+	NextDelay(attempt int) time.Duration
+	// MaxAttempts returns the number of attempts to make before giving up.
+	// A value less than 1 means attempts are made until one succeeds.
+	MaxAttempts() int
+}
+
+// DefaultExponentialBackoff is a RetryPolicy whose delay grows by
+// Multiplier after every failed attempt, starting at BaseDelay and never
+// exceeding MaxDelay. Zero values are replaced by the package defaults.
+type DefaultExponentialBackoff struct {
+	BaseDelay  time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration
+	// Jitter randomly shortens each delay by up to the given fraction,
+	// e.g. 0.2 yields delays between 80% and 100% of the computed value.
+	// It is clamped to [0, 1].
+	Jitter float64
+	// Attempts is returned by MaxAttempts.
+	Attempts int
+}
+
+func (b *DefaultExponentialBackoff) NextDelay(attempt int) time.Duration {
+	base, multiplier, maxDelay := b.BaseDelay, b.Multiplier, b.MaxDelay
+
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+
+	if multiplier < 1 {
+		multiplier = DefaultRetryMultiplier
+	}
+
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(base) * math.Pow(multiplier, float64(attempt-1))
+	if delay > float64(maxDelay) {
+		delay = float64(maxDelay)
+	}
+
+	if jitter := math.Min(math.Max(b.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64() //#nosec
+	}
+
+	return time.Duration(delay)
+}
+
+func (b *DefaultExponentialBackoff) MaxAttempts() int {
+	return b.Attempts
+}
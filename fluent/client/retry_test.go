@@ -0,0 +1,86 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+var _ = Describe("DefaultExponentialBackoff", func() {
+	var (
+		backoff *DefaultExponentialBackoff
+	)
+
+	BeforeEach(func() {
+		backoff = &DefaultExponentialBackoff{
+			BaseDelay:  10 * time.Millisecond,
+			Multiplier: 3,
+			MaxDelay:   time.Second,
+			Attempts:   4,
+		}
+	})
+
+	It("grows the delay by the multiplier", func() {
+		Expect(backoff.NextDelay(1)).To(Equal(10 * time.Millisecond))
+		Expect(backoff.NextDelay(2)).To(Equal(30 * time.Millisecond))
+		Expect(backoff.NextDelay(3)).To(Equal(90 * time.Millisecond))
+	})
+
+	It("caps the delay", func() {
+		Expect(backoff.NextDelay(10)).To(Equal(time.Second))
+	})
+
+	It("returns the configured attempts", func() {
+		Expect(backoff.MaxAttempts()).To(Equal(4))
+	})
+
+	It("applies jitter within bounds", func() {
+		backoff.Jitter = 0.5
+
+		for i := 0; i < 100; i++ {
+			d := backoff.NextDelay(2)
+			Expect(d).To(BeNumerically(">=", 15*time.Millisecond))
+			Expect(d).To(BeNumerically("<=", 30*time.Millisecond))
+		}
+	})
+
+	When("fields are unset", func() {
+		BeforeEach(func() {
+			backoff = &DefaultExponentialBackoff{}
+		})
+
+		It("uses the defaults", func() {
+			Expect(backoff.NextDelay(1)).To(Equal(DefaultRetryBaseDelay))
+			Expect(backoff.NextDelay(2)).To(Equal(2 * DefaultRetryBaseDelay))
+			Expect(backoff.NextDelay(100)).To(Equal(DefaultRetryMaxDelay))
+			Expect(backoff.MaxAttempts()).To(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,222 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultHealthCheckInterval = 30 * time.Second
+)
+
+// RoundRobinClient spreads sends across a pool of WSClients, each send
+// going to the next healthy client in turn. A client is healthy when it
+// has a session whose connection is open. A send that fails is tried on
+// the next client, and the failed client is reconnected in the
+// background.
+//
+// While connected, clients that have not been sent to since the previous
+// check are checked every HealthCheckInterval, and reconnected in the
+// background if they are unhealthy.
+type RoundRobinClient struct {
+	// HealthCheckInterval is how often idle clients are checked. If zero,
+	// DefaultHealthCheckInterval is used.
+	HealthCheckInterval time.Duration
+	clients             []*WSClient
+	used                []int32
+	next                uint64
+	stopLock            sync.Mutex
+	ctx                 context.Context
+	stop                context.CancelFunc
+	checks              sync.WaitGroup
+}
+
+func NewRoundRobinClient(clients ...*WSClient) *RoundRobinClient {
+	return &RoundRobinClient{
+		HealthCheckInterval: DefaultHealthCheckInterval,
+		clients:             clients,
+		used:                make([]int32, len(clients)),
+	}
+}
+
+func healthy(wc *WSClient) bool {
+	session := wc.Session()
+	return session != nil && !session.Connection.Closed()
+}
+
+// Connect connects every client and starts the health checks. It fails
+// only if no client connects; the others are left to the health checks.
+func (c *RoundRobinClient) Connect() error {
+	if len(c.clients) == 0 {
+		return errors.New("no servers")
+	}
+
+	var (
+		err       error
+		connected int
+	)
+
+	for _, wc := range c.clients {
+		if cerr := wc.Connect(); cerr != nil {
+			err = cerr
+			continue
+		}
+
+		connected++
+	}
+
+	if connected == 0 {
+		return fmt.Errorf("connect to all %d servers failed: %w", len(c.clients), err)
+	}
+
+	c.stopLock.Lock()
+	defer c.stopLock.Unlock()
+
+	if c.stop == nil {
+		c.ctx, c.stop = context.WithCancel(context.Background())
+
+		c.checks.Add(1)
+
+		go c.checkHealth(c.ctx)
+	}
+
+	return nil
+}
+
+// Disconnect stops the health checks and disconnects every client.
+func (c *RoundRobinClient) Disconnect() (err error) {
+	c.stopLock.Lock()
+	if c.stop != nil {
+		c.stop()
+		c.ctx, c.stop = nil, nil
+	}
+	c.stopLock.Unlock()
+
+	c.checks.Wait()
+
+	for _, wc := range c.clients {
+		if derr := wc.Disconnect(); derr != nil {
+			err = derr
+		}
+	}
+
+	return
+}
+
+func (c *RoundRobinClient) checkHealth(ctx context.Context) {
+	defer c.checks.Done()
+
+	interval := c.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for i, wc := range c.clients {
+			// a client in use is checked by its sends
+			if atomic.SwapInt32(&c.used[i], 0) == 0 && !healthy(wc) {
+				c.reconnect(ctx, wc)
+			}
+		}
+	}
+}
+
+// reconnect reconnects wc in the background. ReconnectWithRetry joins
+// calls that overlap, so a client is never reconnected twice at once.
+func (c *RoundRobinClient) reconnect(ctx context.Context, wc *WSClient) {
+	c.checks.Add(1)
+
+	go func() {
+		defer c.checks.Done()
+
+		_ = wc.ReconnectWithRetry(ctx)
+	}()
+}
+
+func (c *RoundRobinClient) Send(e protocol.ChunkEncoder) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.Send(e)
+	})
+}
+
+func (c *RoundRobinClient) SendRaw(raw []byte) error {
+	return c.send(func(wc *WSClient) error {
+		return wc.SendRaw(raw)
+	})
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *RoundRobinClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+func (c *RoundRobinClient) send(send func(wc *WSClient) error) error {
+	n := uint64(len(c.clients))
+	if n == 0 {
+		return errors.New("no servers")
+	}
+
+	err := errors.New("no healthy servers")
+
+	for attempt := uint64(0); attempt < n; attempt++ {
+		i := (atomic.AddUint64(&c.next, 1) - 1) % n
+
+		wc := c.clients[i]
+		if !healthy(wc) {
+			continue
+		}
+
+		atomic.StoreInt32(&c.used[i], 1)
+
+		if err = send(wc); err == nil {
+			return nil
+		}
+
+		c.stopLock.Lock()
+		if c.ctx != nil {
+			c.reconnect(c.ctx, wc)
+		}
+		c.stopLock.Unlock()
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RoundRobinClient", func() {
+	var (
+		factories []*clientfakes.FakeWSConnectionFactory
+		conns     []*wsfakes.FakeConnection
+		rr        *RoundRobinClient
+	)
+
+	BeforeEach(func() {
+		factories, conns = nil, nil
+
+		var clients []*WSClient
+
+		for i := 0; i < 3; i++ {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn := &wsfakes.FakeConnection{}
+
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&WSSession{Connection: conn})
+
+			factories = append(factories, factory)
+			conns = append(conns, conn)
+			clients = append(clients, NewWS(WSConnectionOptions{Factory: factory}))
+		}
+
+		rr = NewRoundRobinClient(clients...)
+		rr.HealthCheckInterval = 10 * time.Millisecond
+	})
+
+	JustBeforeEach(func() {
+		Expect(rr.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(rr.Disconnect()).To(Succeed())
+	})
+
+	It("is a MessageSender", func() {
+		var sender MessageSender = rr
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("sends to each client in turn", func() {
+		for i := 0; i < 6; i++ {
+			Expect(rr.SendRaw([]byte("oi"))).To(Succeed())
+		}
+
+		for _, conn := range conns {
+			Expect(conn.WriteCallCount()).To(Equal(2))
+		}
+	})
+
+	It("skips unhealthy clients", func() {
+		conns[1].ClosedReturns(true)
+
+		for i := 0; i < 4; i++ {
+			Expect(rr.SendRaw([]byte("oi"))).To(Succeed())
+		}
+
+		Expect(conns[0].WriteCallCount()).To(Equal(2))
+		Expect(conns[1].WriteCallCount()).To(BeZero())
+		Expect(conns[2].WriteCallCount()).To(Equal(2))
+	})
+
+	When("a send fails", func() {
+		It("tries the next client and reconnects the failed one", func() {
+			conns[0].WriteReturns(0, errors.New("nope"))
+
+			Expect(rr.SendRaw([]byte("oi"))).To(Succeed())
+			Expect(conns[1].WriteCallCount()).To(Equal(1))
+			Eventually(factories[0].NewCallCount).Should(Equal(2))
+		})
+
+		It("returns the error when every client fails", func() {
+			for _, conn := range conns {
+				conn.WriteReturns(0, errors.New("nope"))
+			}
+
+			Expect(rr.SendRaw([]byte("oi"))).To(MatchError("nope"))
+		})
+	})
+
+	Describe("health checks", func() {
+		It("reconnects idle clients that are unhealthy", func() {
+			conns[2].ClosedReturns(true)
+
+			Eventually(factories[2].NewCallCount).Should(BeNumerically(">", 1))
+			Expect(factories[0].NewCallCount()).To(Equal(1))
+		})
+
+		It("stops on Disconnect", func() {
+			conns[2].ClosedReturns(true)
+
+			Expect(rr.Disconnect()).To(Succeed())
+			calls := factories[2].NewCallCount()
+			Consistently(factories[2].NewCallCount, 50*time.Millisecond).Should(Equal(calls))
+		})
+	})
+
+	When("no client connects", func() {
+		It("fails", func() {
+			for _, f := range factories {
+				f.NewReturns(nil, errors.New("nope"))
+			}
+
+			Expect(rr.Disconnect()).To(Succeed())
+			Expect(rr.Connect()).To(MatchError(MatchRegexp("all 3 servers failed: nope")))
+		})
+	})
+})
@@ -0,0 +1,132 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// SequenceGap is called by SequenceGapHandler when a message's seq is not
+// the one after the last seen for its tag, e.g. because a message was lost
+// or arrived out of order.
+type SequenceGap func(tag string, expected, got uint64)
+
+// injectSequence gives e the next seq of its tag, unless it already has
+// one. Encoders other than the protocol's messages are left unnumbered.
+func (c *WSClient) injectSequence(e msgp.Encodable) {
+	opts := MessageOptions(e)
+	if opts == nil || opts.Seq != 0 {
+		return
+	}
+
+	tag := MessageTag(e)
+
+	c.seqLock.Lock()
+	defer c.seqLock.Unlock()
+
+	if c.seqs == nil {
+		c.seqs = make(map[string]uint64)
+	}
+
+	c.seqs[tag]++
+	opts.Seq = c.seqs[tag]
+}
+
+// SequenceGapHandler returns a MessageHandler that tracks the seq option of
+// each tag's messages and calls onGap when one is skipped or repeated.
+// Messages without a seq, and frames that are not forward messages, are
+// ignored. It can be combined with other handlers by MultiHandler.
+func SequenceGapHandler(onGap SequenceGap) MessageHandler {
+	var (
+		lock sync.Mutex
+		last = make(map[string]uint64)
+	)
+
+	return func(msg []byte) error {
+		tag, seq, ok := messageSeq(msg)
+		if !ok {
+			return nil
+		}
+
+		lock.Lock()
+		expected := last[tag] + 1
+
+		if seq > last[tag] {
+			last[tag] = seq
+		}
+		lock.Unlock()
+
+		if seq != expected {
+			onGap(tag, expected, seq)
+		}
+
+		return nil
+	}
+}
+
+// messageSeq reads the tag and seq option of an encoded forward message,
+// reporting false if it has no seq or is not a forward message.
+func messageSeq(b []byte) (tag string, seq uint64, ok bool) {
+	sz, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil || sz < 3 {
+		return "", 0, false
+	}
+
+	tag, b, err = msgp.ReadStringBytes(b)
+	if err != nil {
+		return "", 0, false
+	}
+
+	// the options are the third element in forward and packed forward
+	// mode, where entries are an array or bytes, and the fourth in message
+	// mode, after the time and record
+	optsIndex := uint32(3)
+
+	switch msgp.NextType(b) {
+	case msgp.ArrayType, msgp.BinType, msgp.StrType:
+		optsIndex = 2
+	}
+
+	if sz != optsIndex+1 {
+		return "", 0, false
+	}
+
+	for i := uint32(1); i < optsIndex; i++ {
+		if b, err = msgp.Skip(b); err != nil {
+			return "", 0, false
+		}
+	}
+
+	var opts protocol.MessageOptions
+	if _, err = opts.UnmarshalMsg(b); err != nil || opts.Seq == 0 {
+		return "", 0, false
+	}
+
+	return tag, opts.Seq, true
+}
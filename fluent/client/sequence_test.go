@@ -0,0 +1,177 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sequence", func() {
+	var (
+		conn *wsfakes.FakeConnection
+		cli  *WSClient
+	)
+
+	newMessage := func(tag string) *protocol.Message {
+		return &protocol.Message{
+			Tag:       tag,
+			Timestamp: time.Now().Unix(),
+			Record:    map[string]interface{}{"oi": "hi"},
+		}
+	}
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		cli = NewWS(WSConnectionOptions{
+			Factory:  factory,
+			Sequence: true,
+		})
+		Expect(cli.Connect()).To(Succeed())
+	})
+
+	It("numbers the messages of each tag from 1", func() {
+		var msgs []*protocol.Message
+
+		for _, tag := range []string{"a", "b", "a", "a", "b"} {
+			msg := newMessage(tag)
+			Expect(cli.Send(msg)).To(Succeed())
+			msgs = append(msgs, msg)
+		}
+
+		var seqs []uint64
+		for _, msg := range msgs {
+			seqs = append(seqs, msg.Options.Seq)
+		}
+
+		Expect(seqs).To(Equal([]uint64{1, 1, 2, 3, 2}))
+	})
+
+	It("keeps the seq of a message that is sent again", func() {
+		msg := newMessage("a")
+		Expect(cli.Send(msg)).To(Succeed())
+		Expect(cli.Send(msg)).To(Succeed())
+		Expect(msg.Options.Seq).To(Equal(uint64(1)))
+
+		next := newMessage("a")
+		Expect(cli.Send(next)).To(Succeed())
+		Expect(next.Options.Seq).To(Equal(uint64(2)))
+	})
+
+	It("leaves messages unnumbered when it is off", func() {
+		cli.Sequence = false
+
+		msg := newMessage("a")
+		Expect(cli.Send(msg)).To(Succeed())
+		Expect(msg.Options).To(BeNil())
+	})
+
+	Describe("SequenceGapHandler", func() {
+		type gap struct {
+			tag           string
+			expected, got uint64
+		}
+
+		var (
+			gaps    []gap
+			handler MessageHandler
+		)
+
+		BeforeEach(func() {
+			gaps = nil
+			handler = SequenceGapHandler(func(tag string, expected, got uint64) {
+				gaps = append(gaps, gap{tag, expected, got})
+			})
+		})
+
+		// receive passes the frames the client wrote to the handler,
+		// skipping those at the given indexes.
+		receive := func(skip ...int) {
+			skipped := map[int]bool{}
+			for _, i := range skip {
+				skipped[i] = true
+			}
+
+			for i := 0; i < conn.WriteCallCount(); i++ {
+				if !skipped[i] {
+					Expect(handler(conn.WriteArgsForCall(i))).To(Succeed())
+				}
+			}
+		}
+
+		It("reports nothing when no message is lost", func() {
+			for _, tag := range []string{"a", "b", "a", "b"} {
+				Expect(cli.Send(newMessage(tag))).To(Succeed())
+			}
+
+			receive()
+			Expect(gaps).To(BeEmpty())
+		})
+
+		It("reports a lost message of a tag", func() {
+			for _, tag := range []string{"a", "b", "a", "b", "a"} {
+				Expect(cli.Send(newMessage(tag))).To(Succeed())
+			}
+
+			receive(2)
+			Expect(gaps).To(Equal([]gap{{"a", 2, 3}}))
+		})
+
+		It("reads the seq of forward and packed forward messages", func() {
+			entries := protocol.EntryList{{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"oi": "hi"}}}
+
+			Expect(cli.Send(protocol.NewForwardMessage("fwd", entries))).To(Succeed())
+			Expect(cli.Send(protocol.NewForwardMessage("fwd", entries))).To(Succeed())
+			Expect(cli.Send(protocol.NewForwardMessage("fwd", entries))).To(Succeed())
+
+			packed, err := protocol.NewPackedForwardMessage("packed", entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cli.Send(packed)).To(Succeed())
+
+			packed, err = protocol.NewPackedForwardMessage("packed", entries)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cli.Send(packed)).To(Succeed())
+
+			receive(1)
+			Expect(gaps).To(Equal([]gap{{"fwd", 2, 3}}))
+		})
+
+		It("ignores frames without a seq", func() {
+			Expect(handler([]byte{0x81, 0xa3, 'a', 'c', 'k', 0xa1, 'x'})).To(Succeed())
+			Expect(gaps).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,170 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+)
+
+// spillSegmentBytes is the most bytes written to one spill file before
+// another is started, so that files can be deleted as they are read back.
+const spillSegmentBytes = 1 << 20
+
+const spillHeaderSize = 4
+
+// spill is a FIFO of encoded messages kept in files in dir. Each record is
+// a big-endian uint32 length followed by the encoded message. It is not
+// safe for concurrent use; WSClient guards it with bufferLock.
+type spill struct {
+	dir        string
+	maxBytes   int64
+	segmentMax int64
+	segments   []*spillSegment
+	size       int64
+	count      int
+	// freed is closed, and replaced, when files are deleted
+	freed chan struct{}
+	// wake asks the refiller to read messages back into the buffer
+	wake chan struct{}
+	// closed is closed when the files have been deleted by close
+	closed chan struct{}
+}
+
+type spillSegment struct {
+	file    *os.File
+	written int64
+	read    int64
+}
+
+func newSpill(dir string, maxBytes int64) *spill {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	segmentMax := int64(spillSegmentBytes)
+	if maxBytes > 0 && maxBytes/4 < segmentMax {
+		segmentMax = maxBytes / 4
+	}
+
+	return &spill{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		segmentMax: segmentMax,
+		freed:      make(chan struct{}),
+		wake:       make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+	}
+}
+
+// fits reports whether a message of n bytes can be written without going
+// over maxBytes.
+func (s *spill) fits(n int) bool {
+	return s.maxBytes <= 0 || s.size+int64(spillHeaderSize+n) <= s.maxBytes
+}
+
+func (s *spill) write(data []byte) error {
+	size := int64(spillHeaderSize + len(data))
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return errors.New("message is larger than SpillDirMaxBytes")
+	}
+
+	var last *spillSegment
+	if len(s.segments) > 0 {
+		last = s.segments[len(s.segments)-1]
+	}
+
+	if last == nil || (last.written > 0 && last.written+size > s.segmentMax) {
+		file, err := os.CreateTemp(s.dir, "spill-*.msgpack")
+		if err != nil {
+			return err
+		}
+
+		last = &spillSegment{file: file}
+		s.segments = append(s.segments, last)
+	}
+
+	record := make([]byte, size)
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[spillHeaderSize:], data)
+
+	if _, err := last.file.Write(record); err != nil {
+		return err
+	}
+
+	last.written += size
+	s.size += size
+	s.count++
+
+	return nil
+}
+
+// read returns the oldest message, deleting its file once every message in
+// it has been read.
+func (s *spill) read() ([]byte, error) {
+	seg := s.segments[0]
+
+	var header [spillHeaderSize]byte
+	if _, err := seg.file.ReadAt(header[:], seg.read); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := seg.file.ReadAt(data, seg.read+spillHeaderSize); err != nil {
+		return nil, err
+	}
+
+	seg.read += int64(spillHeaderSize + len(data))
+	s.count--
+
+	if seg.read == seg.written {
+		s.segments = s.segments[1:]
+		s.size -= seg.written
+
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+
+		close(s.freed)
+		s.freed = make(chan struct{})
+	}
+
+	return data, nil
+}
+
+// close deletes the files, discarding the messages left in them.
+func (s *spill) close() {
+	for _, seg := range s.segments {
+		seg.file.Close()
+		os.Remove(seg.file.Name())
+	}
+
+	s.segments = nil
+	s.size = 0
+	s.count = 0
+
+	close(s.freed)
+	close(s.closed)
+}
@@ -0,0 +1,472 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package splunk sends events to a Splunk HTTP Event Collector (HEC), for
+// organizations that run Splunk alongside or instead of Fluentd.
+package splunk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+	DefaultMaxAttempts   = 4
+	// maxErrorBody is how much of a failed response's body is kept in its
+	// ResponseError.
+	maxErrorBody = 4 << 10
+)
+
+// ResponseError is returned when the collector responds to a POST with a
+// status other than 2xx.
+type ResponseError struct {
+	StatusCode int
+	// Body is the start of the response body, which HEC fills with a JSON
+	// object describing the error.
+	Body string
+	// RetryAfter is the wait the Retry-After header asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *ResponseError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("hec status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("hec status %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the request may succeed if repeated, which is
+// the case when the collector is busy or unavailable.
+func (e *ResponseError) Retryable() bool {
+	return e.StatusCode == nethttp.StatusTooManyRequests || e.StatusCode == nethttp.StatusServiceUnavailable
+}
+
+// Is makes the error match client.ErrRetriable or client.ErrPermanent, as
+// Retryable reports.
+func (e *ResponseError) Is(target error) bool {
+	if e.Retryable() {
+		return target == client.ErrRetriable
+	}
+
+	return target == client.ErrPermanent
+}
+
+type ConnectionOptions struct {
+	URL           string
+	Client        *nethttp.Client
+	AuthInfo      *client.IAMAuthInfo
+	Host          string
+	Source        string
+	SourceType    string
+	Index         string
+	BatchSize     int
+	FlushInterval time.Duration
+	RetryPolicy   client.RetryPolicy
+}
+
+// SplunkHECTransport converts messages to HEC JSON events and POSTs them
+// to URL, several to a request, once BatchSize events have been collected
+// or FlushInterval has passed, whichever is first, and on Flush and Close.
+//
+// A POST that fails with 429 or 503, or a network error, is retried after
+// the wait the Retry-After header asks for, or else as RetryPolicy directs;
+// one that fails otherwise is not. A batch that still fails is dropped, and
+// the error returned by the send or Flush that sent it, or passed to
+// OnFlushError for the periodic flushes.
+type SplunkHECTransport struct {
+	// URL is the collector's event endpoint, e.g.
+	// https://splunk.example.com:8088/services/collector/event.
+	URL string
+	// Client makes the requests. If nil, http.DefaultClient is used.
+	Client *nethttp.Client
+	// AuthInfo holds the HEC token. It is read for every request, so a
+	// token set with SetIAMToken is used from the next POST on, over the
+	// same connections. An expired token is refreshed with its OnExpired
+	// first.
+	AuthInfo *client.IAMAuthInfo
+	// Host, Source, SourceType and Index are the metadata of every event.
+	// If Source is empty, the message's tag is used; the others are left
+	// for the collector to fill in.
+	Host       string
+	Source     string
+	SourceType string
+	Index      string
+	// BatchSize is the number of events sent in one POST. If zero,
+	// DefaultBatchSize is used.
+	BatchSize int
+	// FlushInterval is the longest an event waits to be sent. If zero,
+	// DefaultFlushInterval is used.
+	FlushInterval time.Duration
+	// RetryPolicy spaces out the attempts to POST a batch. If nil, a
+	// DefaultExponentialBackoff making DefaultMaxAttempts is used.
+	RetryPolicy client.RetryPolicy
+	// OnFlushError, if not nil, is called when a periodic flush fails.
+	OnFlushError func(err error)
+	batch        bytes.Buffer
+	events       int
+	lock         sync.Mutex
+	sendLock     sync.Mutex
+	stop         chan struct{}
+	flushes      sync.WaitGroup
+}
+
+func New(opts ConnectionOptions) *SplunkHECTransport {
+	return &SplunkHECTransport{
+		URL:           opts.URL,
+		Client:        opts.Client,
+		AuthInfo:      opts.AuthInfo,
+		Host:          opts.Host,
+		Source:        opts.Source,
+		SourceType:    opts.SourceType,
+		Index:         opts.Index,
+		BatchSize:     opts.BatchSize,
+		FlushInterval: opts.FlushInterval,
+		RetryPolicy:   opts.RetryPolicy,
+	}
+}
+
+// event is the HEC JSON event format.
+type event struct {
+	Time       json.Number `json:"time,omitempty"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	SourceType string      `json:"sourcetype,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+// Send adds the events of e, which may be a *protocol.Message,
+// *protocol.MessageExt, *protocol.ForwardMessage or a packed forward
+// message, to the batch.
+func (t *SplunkHECTransport) Send(e protocol.ChunkEncoder) error {
+	tag, entries, err := entriesOf(e)
+	if err != nil {
+		return err
+	}
+
+	source := t.Source
+	if source == "" {
+		source = tag
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+
+	for _, entry := range entries {
+		err := enc.Encode(event{
+			Time:       epoch(entry.Timestamp.Time),
+			Host:       t.Host,
+			Source:     source,
+			SourceType: t.SourceType,
+			Index:      t.Index,
+			Event:      entry.Record,
+		})
+		if err != nil {
+			return fmt.Errorf("encode event: %w", err)
+		}
+	}
+
+	return t.add(buf.Bytes(), len(entries))
+}
+
+// SendMessage adds a single event, timestamped now, to the batch.
+func (t *SplunkHECTransport) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessageExt(tag, record))
+}
+
+// SendRaw adds raw, which must be a HEC JSON event, to the batch. If that
+// fills the batch, it is sent before SendRaw returns.
+func (t *SplunkHECTransport) SendRaw(raw []byte) error {
+	return t.add(raw, 1)
+}
+
+func (t *SplunkHECTransport) add(events []byte, n int) error {
+	t.lock.Lock()
+
+	t.batch.Write(events)
+	t.events += n
+
+	if t.stop == nil {
+		t.stop = make(chan struct{})
+		t.flushes.Add(1)
+
+		go t.flushEvery(t.stop)
+	}
+
+	full := t.events >= t.batchSize()
+	t.lock.Unlock()
+
+	if full {
+		return t.Flush()
+	}
+
+	return nil
+}
+
+func (t *SplunkHECTransport) batchSize() int {
+	if t.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+
+	return t.BatchSize
+}
+
+// Flush sends the batch now.
+func (t *SplunkHECTransport) Flush() error {
+	t.sendLock.Lock()
+	defer t.sendLock.Unlock()
+
+	t.lock.Lock()
+	batch := append([]byte(nil), t.batch.Bytes()...)
+	t.batch.Reset()
+	t.events = 0
+	t.lock.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	return t.post(batch)
+}
+
+func (t *SplunkHECTransport) retryPolicy() client.RetryPolicy {
+	if t.RetryPolicy == nil {
+		return &client.DefaultExponentialBackoff{Attempts: DefaultMaxAttempts}
+	}
+
+	return t.RetryPolicy
+}
+
+// post sends batch, retrying as the RetryPolicy directs. Holding sendLock
+// throughout keeps the batches in order.
+func (t *SplunkHECTransport) post(batch []byte) error {
+	policy := t.retryPolicy()
+
+	for attempt := 1; ; attempt++ {
+		// the token is read again for each attempt, so that one set while
+		// waiting to retry is used
+		auth, err := t.authorization()
+		if err != nil {
+			return err
+		}
+
+		err = t.postOnce(batch, auth)
+		if err == nil {
+			return nil
+		}
+
+		delay := policy.NextDelay(attempt)
+
+		var re *ResponseError
+		if errors.As(err, &re) {
+			if !re.Retryable() {
+				return err
+			}
+
+			if re.RetryAfter > 0 {
+				delay = re.RetryAfter
+			}
+		}
+
+		if max := policy.MaxAttempts(); max > 0 && attempt >= max {
+			return fmt.Errorf("post failed after %d attempts: %w", attempt, err)
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// authorization returns the Authorization header for the current token.
+func (t *SplunkHECTransport) authorization() (string, error) {
+	if t.AuthInfo == nil {
+		return "", nil
+	}
+
+	header, err := (&client.IAMBearerAuth{AuthInfo: t.AuthInfo}).Headers(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	if token := header.Get(client.AuthorizationHeader); token != "" {
+		return "Splunk " + token, nil
+	}
+
+	return "", nil
+}
+
+func (t *SplunkHECTransport) postOnce(batch []byte, auth string) error {
+	req, err := nethttp.NewRequest(nethttp.MethodPost, t.URL, bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", client.JSONContentType)
+
+	if auth != "" {
+		req.Header.Set(client.AuthorizationHeader, auth)
+	}
+
+	hc := t.Client
+	if hc == nil {
+		hc = nethttp.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// reading the body to the end lets the connection be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	return &ResponseError{
+		StatusCode: resp.StatusCode,
+		Body:       string(bytes.TrimSpace(body)),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	if at, err := nethttp.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+
+	return 0
+}
+
+func (t *SplunkHECTransport) flushEvery(stop chan struct{}) {
+	defer t.flushes.Done()
+
+	interval := t.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := t.Flush(); err != nil && t.OnFlushError != nil {
+			t.OnFlushError(err)
+		}
+	}
+}
+
+// Close stops the periodic flushes and sends the batch. The transport may
+// be used again afterwards.
+func (t *SplunkHECTransport) Close() error {
+	t.lock.Lock()
+	stop := t.stop
+	t.stop = nil
+	t.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		t.flushes.Wait()
+	}
+
+	return t.Flush()
+}
+
+// epoch formats t as HEC expects, in seconds since the epoch with
+// milliseconds.
+func epoch(t time.Time) json.Number {
+	if t.IsZero() {
+		return ""
+	}
+
+	return json.Number(strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64))
+}
+
+func entriesOf(e protocol.ChunkEncoder) (string, protocol.EntryList, error) {
+	switch msg := e.(type) {
+	case *protocol.Message:
+		return msg.Tag, protocol.EntryList{{
+			Timestamp: protocol.EventTime{Time: time.Unix(msg.Timestamp, 0)},
+			Record:    msg.Record,
+		}}, nil
+	case *protocol.MessageExt:
+		return msg.Tag, protocol.EntryList{{Timestamp: msg.Timestamp, Record: msg.Record}}, nil
+	case *protocol.ForwardMessage:
+		return msg.Tag, msg.Entries, nil
+	case *protocol.CompressedPackedForwardMessage:
+		// the wrapped event stream is not compressed until it is encoded
+		return unpack(msg.PackedForwardMessage)
+	case *protocol.PackedForwardMessage:
+		if msg.Options != nil && msg.Options.Compressed != "" {
+			return "", nil, errors.New("cannot send a compressed event stream")
+		}
+
+		return unpack(msg)
+	default:
+		return "", nil, fmt.Errorf("cannot send %T as HEC events", e)
+	}
+}
+
+func unpack(msg *protocol.PackedForwardMessage) (string, protocol.EntryList, error) {
+	var entries protocol.EntryList
+	if _, err := entries.UnmarshalPacked(msg.EventStream); err != nil {
+		return "", nil, fmt.Errorf("decode event stream: %w", err)
+	}
+
+	return msg.Tag, entries, nil
+}
@@ -0,0 +1,254 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package splunk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/client/splunk"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ client.MessageSender = &SplunkHECTransport{}
+
+type request struct {
+	auth       string
+	remoteAddr string
+	at         time.Time
+	events     []map[string]interface{}
+}
+
+var _ = Describe("SplunkHECTransport", func() {
+	var (
+		lock      sync.Mutex
+		requests  []request
+		failures  []func(nethttp.ResponseWriter)
+		svr       *httptest.Server
+		authInfo  *client.IAMAuthInfo
+		transport *SplunkHECTransport
+	)
+
+	received := func() []request {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return append([]request(nil), requests...)
+	}
+
+	BeforeEach(func() {
+		requests, failures = nil, nil
+
+		svr = httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			defer GinkgoRecover()
+
+			body, err := io.ReadAll(r.Body)
+			Expect(err).ToNot(HaveOccurred())
+
+			req := request{auth: r.Header.Get("Authorization"), remoteAddr: r.RemoteAddr, at: time.Now()}
+
+			dec := json.NewDecoder(bytes.NewReader(body))
+			for dec.More() {
+				var ev map[string]interface{}
+				Expect(dec.Decode(&ev)).To(Succeed())
+				req.events = append(req.events, ev)
+			}
+
+			lock.Lock()
+			requests = append(requests, req)
+
+			var fail func(nethttp.ResponseWriter)
+			if len(failures) > 0 {
+				fail, failures = failures[0], failures[1:]
+			}
+			lock.Unlock()
+
+			if fail != nil {
+				fail(w)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"text":"Success","code":0}`))
+		}))
+
+		authInfo = client.NewIAMAuthInfo("token-1")
+		transport = New(ConnectionOptions{
+			URL:        svr.URL + "/services/collector/event",
+			AuthInfo:   authInfo,
+			Host:       "web-1",
+			SourceType: "_json",
+			Index:      "main",
+			BatchSize:  3,
+			RetryPolicy: &client.DefaultExponentialBackoff{
+				BaseDelay: time.Millisecond,
+				Attempts:  3,
+			},
+		})
+	})
+
+	AfterEach(func() {
+		Expect(transport.Close()).To(Succeed())
+		svr.Close()
+	})
+
+	status := func(code int, header ...string) func(nethttp.ResponseWriter) {
+		return func(w nethttp.ResponseWriter) {
+			for i := 0; i < len(header); i += 2 {
+				w.Header().Set(header[i], header[i+1])
+			}
+
+			w.WriteHeader(code)
+			_, _ = w.Write([]byte(`{"text":"Server is busy","code":9}`))
+		}
+	}
+
+	It("posts a batch of events in the multi-event format", func() {
+		at := protocol.EventTime{Time: time.Unix(1646136000, 123e6)}
+
+		Expect(transport.Send(protocol.NewForwardMessage("app.web", protocol.EntryList{
+			{Timestamp: at, Record: map[string]interface{}{"n": 1}},
+			{Timestamp: at, Record: map[string]interface{}{"n": 2}},
+		}))).To(Succeed())
+		Expect(received()).To(BeEmpty())
+
+		Expect(transport.SendMessage("app.db", map[string]interface{}{"n": 3})).To(Succeed())
+
+		reqs := received()
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].auth).To(Equal("Splunk token-1"))
+		Expect(reqs[0].events).To(HaveLen(3))
+		Expect(reqs[0].events[0]).To(Equal(map[string]interface{}{
+			"time":       1646136000.123,
+			"host":       "web-1",
+			"source":     "app.web",
+			"sourcetype": "_json",
+			"index":      "main",
+			"event":      map[string]interface{}{"n": 1.0},
+		}))
+		Expect(reqs[0].events[2]).To(HaveKeyWithValue("source", "app.db"))
+	})
+
+	It("sends the events of packed forward messages", func() {
+		entries := protocol.EntryList{{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"n": 1}}}
+
+		msg, err := protocol.NewPackedForwardMessage("app.web", entries)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(transport.Send(msg)).To(Succeed())
+		Expect(transport.Send(protocol.NewCompressedPackedForward(msg))).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+
+		reqs := received()
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].events).To(HaveLen(2))
+		Expect(reqs[0].events[1]).To(HaveKeyWithValue("event", map[string]interface{}{"n": 1.0}))
+	})
+
+	It("adds raw HEC events to the batch", func() {
+		Expect(transport.SendRaw([]byte(`{"event":"oi","source":"raw"}`))).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+
+		reqs := received()
+		Expect(reqs).To(HaveLen(1))
+		Expect(reqs[0].events).To(Equal([]map[string]interface{}{{"event": "oi", "source": "raw"}}))
+	})
+
+	It("waits as long as Retry-After asks when the collector is busy", func() {
+		failures = append(failures, status(nethttp.StatusTooManyRequests, "Retry-After", "1"))
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+
+		reqs := received()
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[1].at.Sub(reqs[0].at)).To(BeNumerically(">=", 900*time.Millisecond))
+		Expect(reqs[1].events).To(Equal(reqs[0].events))
+	})
+
+	It("retries when the collector is unavailable", func() {
+		failures = append(failures, status(nethttp.StatusServiceUnavailable), status(nethttp.StatusServiceUnavailable))
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+		Expect(received()).To(HaveLen(3))
+	})
+
+	It("drops the batch after the last attempt", func() {
+		for i := 0; i < 3; i++ {
+			failures = append(failures, status(nethttp.StatusServiceUnavailable))
+		}
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+
+		err := transport.Flush()
+		Expect(err).To(MatchError(ContainSubstring("after 3 attempts")))
+
+		var re *ResponseError
+		Expect(errors.As(err, &re)).To(BeTrue())
+		Expect(re.StatusCode).To(Equal(nethttp.StatusServiceUnavailable))
+		Expect(transport.Flush()).To(Succeed())
+		Expect(received()).To(HaveLen(3))
+	})
+
+	It("does not retry other errors", func() {
+		failures = append(failures, status(nethttp.StatusForbidden))
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(MatchError(ContainSubstring("hec status 403")))
+		Expect(received()).To(HaveLen(1))
+	})
+
+	It("uses a rotated token on the same connection", func() {
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+
+		authInfo.SetIAMToken("token-2")
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+
+		reqs := received()
+		Expect(reqs).To(HaveLen(2))
+		Expect(reqs[0].auth).To(Equal("Splunk token-1"))
+		Expect(reqs[1].auth).To(Equal("Splunk token-2"))
+		Expect(reqs[1].remoteAddr).To(Equal(reqs[0].remoteAddr))
+	})
+
+	It("does not post with an expired token", func() {
+		authInfo.SetIAMTokenWithExpiry("token-1", time.Now().Add(-time.Minute))
+
+		Expect(transport.SendMessage("app", "oi")).To(Succeed())
+		Expect(transport.Flush()).To(MatchError(client.ErrTokenExpired))
+		Expect(received()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,254 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// TagAffinityRouter spreads tags over a pool of connections, but sends
+// every message with a given tag on the same connection, so that each
+// tag's messages stay in order when several connections are in use, which
+// MultiConnClient does not guarantee.
+//
+// A tag is assigned the connection at its Hash modulo the pool size the
+// first time it is sent, and keeps it when connections are added: call
+// RebalanceOnNodeChange to spread the tags over the new pool. A tag whose
+// connection is removed is reassigned on its next send.
+//
+// The router remembers every tag it has sent, so it suits a bounded set
+// of tags.
+type TagAffinityRouter struct {
+	// Hash maps a tag to a connection. If nil, the FNV-1a based hash of
+	// ConsistentHashRouter is used. It must not be changed once messages
+	// have been sent.
+	Hash  func(tag string) uint64
+	conns []MessageSender
+	tags  map[string]*tagAffinity
+	lock  sync.RWMutex
+}
+
+// tagAffinity is the connection assigned to a tag. Sends hold lock for
+// reading, so a move takes it for writing, which waits for those on the
+// old connection to finish and holds back new ones until it is done.
+type tagAffinity struct {
+	conn MessageSender
+	lock sync.RWMutex
+}
+
+func NewTagAffinityRouter(conns ...MessageSender) *TagAffinityRouter {
+	return &TagAffinityRouter{conns: conns}
+}
+
+func (r *TagAffinityRouter) hash(tag string) uint64 {
+	if r.Hash == nil {
+		return ringHash(tag)
+	}
+
+	return r.Hash(tag)
+}
+
+// AddConn adds a connection to the pool. No tag is moved to it until
+// RebalanceOnNodeChange is called.
+func (r *TagAffinityRouter) AddConn(c MessageSender) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.conns = append(r.conns, c)
+}
+
+// RemoveConn removes a connection from the pool, if it is in it. The tags
+// that were sent on it are reassigned on their next send.
+func (r *TagAffinityRouter) RemoveConn(c MessageSender) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for i, conn := range r.conns {
+		if conn == c {
+			r.conns = append(r.conns[:i:i], r.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// Size returns the number of connections in the pool.
+func (r *TagAffinityRouter) Size() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return len(r.conns)
+}
+
+// slot returns the connection tag hashes to, or nil if there are none. It
+// must be called with the lock held.
+func (r *TagAffinityRouter) slot(tag string) MessageSender {
+	if len(r.conns) == 0 {
+		return nil
+	}
+
+	return r.conns[r.hash(tag)%uint64(len(r.conns))]
+}
+
+// pooled reports whether c is in the pool. It must be called with the lock
+// held.
+func (r *TagAffinityRouter) pooled(c MessageSender) bool {
+	for _, conn := range r.conns {
+		if conn == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// affinity returns the connection assignment for tag, assigning one if it
+// has none or its connection has been removed.
+func (r *TagAffinityRouter) affinity(tag string) *tagAffinity {
+	r.lock.RLock()
+	a, ok := r.tags[tag]
+	r.lock.RUnlock()
+
+	if !ok {
+		r.lock.Lock()
+
+		if a, ok = r.tags[tag]; !ok {
+			if r.tags == nil {
+				r.tags = map[string]*tagAffinity{}
+			}
+
+			a = &tagAffinity{conn: r.slot(tag)}
+			r.tags[tag] = a
+		}
+
+		r.lock.Unlock()
+	}
+
+	return a
+}
+
+// Route returns the connection that messages tagged tag are sent on, or
+// nil if the pool is empty.
+func (r *TagAffinityRouter) Route(tag string) MessageSender {
+	conn, done := r.acquire(tag)
+	defer done()
+
+	return conn
+}
+
+// acquire returns the connection for tag, held so that it is not moved
+// until done is called.
+func (r *TagAffinityRouter) acquire(tag string) (MessageSender, func()) {
+	for {
+		a := r.affinity(tag)
+		a.lock.RLock()
+
+		r.lock.RLock()
+		ok := a.conn != nil && r.pooled(a.conn)
+		r.lock.RUnlock()
+
+		if ok {
+			return a.conn, a.lock.RUnlock
+		}
+
+		a.lock.RUnlock()
+
+		// the connection was removed, or the pool was empty
+		r.lock.RLock()
+		target := r.slot(tag)
+		r.lock.RUnlock()
+
+		if target == nil {
+			return nil, func() {}
+		}
+
+		r.move(a, target)
+	}
+}
+
+// move assigns a tag to target, waiting for its sends in progress.
+func (r *TagAffinityRouter) move(a *tagAffinity, target MessageSender) bool {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.conn == target {
+		return false
+	}
+
+	a.conn = target
+
+	return true
+}
+
+// RebalanceOnNodeChange reassigns every tag to the connection it hashes to
+// in the current pool, and returns the number of tags moved. Each tag
+// moved is locked out while the sends in progress on its old connection
+// finish, so that none of its messages overtakes another; other tags are
+// sent as usual meanwhile.
+func (r *TagAffinityRouter) RebalanceOnNodeChange() int {
+	r.lock.RLock()
+	tags := make(map[string]*tagAffinity, len(r.tags))
+	targets := make(map[string]MessageSender, len(r.tags))
+
+	for tag, a := range r.tags {
+		tags[tag] = a
+		targets[tag] = r.slot(tag)
+	}
+	r.lock.RUnlock()
+
+	moved := 0
+
+	for tag, a := range tags {
+		if targets[tag] != nil && r.move(a, targets[tag]) {
+			moved++
+		}
+	}
+
+	return moved
+}
+
+func (r *TagAffinityRouter) send(tag string, e protocol.ChunkEncoder) error {
+	conn, done := r.acquire(tag)
+	defer done()
+
+	if conn == nil {
+		return fmt.Errorf("%w %q", ErrNoRoute, tag)
+	}
+
+	return conn.Send(e)
+}
+
+// SendMessage sends a single event in Message mode on the connection for
+// tag.
+func (r *TagAffinityRouter) SendMessage(tag string, record interface{}) error {
+	return r.send(tag, protocol.NewMessage(tag, record))
+}
+
+// SendForward sends entries in Forward mode on the connection for tag.
+func (r *TagAffinityRouter) SendForward(tag string, entries protocol.EntryList) error {
+	return r.send(tag, &protocol.ForwardMessage{Tag: tag, Entries: entries})
+}
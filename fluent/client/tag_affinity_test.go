@@ -0,0 +1,169 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TagAffinityRouter", func() {
+	var (
+		router *TagAffinityRouter
+		conns  []*clientfakes.FakeMessageSender
+	)
+
+	// slots hashes "slot.N" to N
+	slots := func(tag string) uint64 {
+		var n uint64
+		_, _ = fmt.Sscanf(tag, "slot.%d", &n)
+
+		return n
+	}
+
+	BeforeEach(func() {
+		conns = []*clientfakes.FakeMessageSender{{}, {}, {}}
+		router = NewTagAffinityRouter(conns[0], conns[1], conns[2])
+	})
+
+	It("always sends a tag on the same connection", func() {
+		for i := 0; i < 3; i++ {
+			Expect(router.SendMessage("app.logs", "oi")).To(Succeed())
+		}
+
+		sender := router.Route("app.logs")
+		Expect(sender.(*clientfakes.FakeMessageSender).SendCallCount()).To(Equal(3))
+
+		msg, ok := sender.(*clientfakes.FakeMessageSender).SendArgsForCall(0).(*protocol.Message)
+		Expect(ok).To(BeTrue())
+		Expect(msg.Tag).To(Equal("app.logs"))
+	})
+
+	It("spreads tags over every connection", func() {
+		for i := 0; i < 300; i++ {
+			Expect(router.SendMessage(fmt.Sprintf("app.%d.logs", i), "oi")).To(Succeed())
+		}
+
+		for _, conn := range conns {
+			Expect(conn.SendCallCount()).To(BeNumerically(">", 50))
+		}
+	})
+
+	It("uses the Hash", func() {
+		router.Hash = slots
+
+		Expect(router.SendForward("slot.4", protocol.EntryList{})).To(Succeed())
+		Expect(conns[1].SendCallCount()).To(Equal(1))
+		Expect(conns[1].SendArgsForCall(0)).To(BeAssignableToTypeOf(&protocol.ForwardMessage{}))
+	})
+
+	It("keeps tags on their connection until rebalanced", func() {
+		router.Hash = slots
+
+		Expect(router.Route("slot.3")).To(BeIdenticalTo(conns[0]))
+		Expect(router.Route("slot.4")).To(BeIdenticalTo(conns[1]))
+
+		added := &clientfakes.FakeMessageSender{}
+		router.AddConn(added)
+		Expect(router.Size()).To(Equal(4))
+		Expect(router.Route("slot.3")).To(BeIdenticalTo(conns[0]))
+
+		// slot.3 moves to the new connection and slot.4 to conns[0]
+		Expect(router.RebalanceOnNodeChange()).To(Equal(2))
+		Expect(router.Route("slot.3")).To(BeIdenticalTo(added))
+		Expect(router.Route("slot.4")).To(BeIdenticalTo(conns[0]))
+		Expect(router.RebalanceOnNodeChange()).To(BeZero())
+	})
+
+	It("moves the tags of a removed connection on their next send", func() {
+		router.Hash = slots
+
+		Expect(router.Route("slot.2")).To(BeIdenticalTo(conns[2]))
+		Expect(router.Route("slot.1")).To(BeIdenticalTo(conns[1]))
+
+		router.RemoveConn(conns[2])
+
+		Expect(router.SendMessage("slot.2", "oi")).To(Succeed())
+		Expect(conns[2].SendCallCount()).To(BeZero())
+		Expect(conns[0].SendCallCount()).To(Equal(1))
+		Expect(router.Route("slot.1")).To(BeIdenticalTo(conns[1]))
+	})
+
+	It("locks a tag out while its sends on the old connection finish", func() {
+		router.Hash = slots
+
+		sending := make(chan struct{})
+		release := make(chan struct{})
+		conns[1].SendCalls(func(protocol.ChunkEncoder) error {
+			close(sending)
+			<-release
+
+			return nil
+		})
+
+		Expect(router.SendMessage("slot.3", "other tag")).To(Succeed())
+
+		sent := make(chan error)
+		go func() { sent <- router.SendMessage("slot.4", "first") }()
+		Eventually(sending).Should(BeClosed())
+
+		router.AddConn(&clientfakes.FakeMessageSender{})
+
+		rebalanced := make(chan int)
+		go func() { rebalanced <- router.RebalanceOnNodeChange() }()
+
+		Consistently(rebalanced).ShouldNot(Receive())
+		Expect(router.SendMessage("slot.3", "other tag")).To(Succeed())
+
+		close(release)
+		Eventually(sent).Should(Receive(BeNil()))
+		Eventually(rebalanced).Should(Receive(Equal(2)))
+
+		Expect(router.SendMessage("slot.4", "second")).To(Succeed())
+		Expect(conns[1].SendCallCount()).To(Equal(1))
+
+		last := conns[0].SendArgsForCall(conns[0].SendCallCount() - 1)
+		Expect(last.(*protocol.Message).Record).To(Equal("second"))
+	})
+
+	It("returns ErrNoRoute when the pool is empty", func() {
+		router = NewTagAffinityRouter()
+
+		err := router.SendMessage("app.logs", "oi")
+		Expect(errors.Is(err, ErrNoRoute)).To(BeTrue())
+		Expect(router.Route("app.logs")).To(BeNil())
+
+		conn := &clientfakes.FakeMessageSender{}
+		router.AddConn(conn)
+		Expect(router.SendMessage("app.logs", "oi")).To(Succeed())
+		Expect(conn.SendCallCount()).To(Equal(1))
+	})
+})
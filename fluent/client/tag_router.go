@@ -0,0 +1,221 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+type tagRoute struct {
+	pattern string
+	match   *regexp.Regexp
+	sender  MessageSender
+}
+
+// TagRouter sends each message to the MessageSender whose route matches
+// its tag, trying routes in the order they were added. The zero value
+// has no routes and is ready to use.
+//
+// Patterns follow Fluentd's <match> syntax: "*" matches one tag part,
+// "**" matches zero or more parts, and "{a,b}" matches either a or b, so
+// "app.{logs,metrics}.**" matches "app.logs" and "app.metrics.cpu". A
+// pattern enclosed in slashes, such as "/^app\.(logs|metrics)$/", is a
+// regular expression instead.
+type TagRouter struct {
+	// Fanout sends each message to every matching route rather than only
+	// the first.
+	Fanout bool
+	routes []tagRoute
+	lock   sync.RWMutex
+}
+
+var _ MessageSender = (*TagRouter)(nil)
+
+// AddRoute adds a route for tags matching pattern. It fails only if the
+// pattern is an invalid regular expression.
+func (r *TagRouter) AddRoute(pattern string, c MessageSender) error {
+	match, err := compileTagPattern(pattern)
+	if err != nil {
+		return fmt.Errorf("route %q: %w", pattern, err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.routes = append(r.routes, tagRoute{
+		pattern: pattern,
+		match:   match,
+		sender:  c,
+	})
+
+	return nil
+}
+
+// RemoveRoute removes every route added with pattern.
+func (r *TagRouter) RemoveRoute(pattern string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	routes := r.routes[:0]
+
+	for _, route := range r.routes {
+		if route.pattern != pattern {
+			routes = append(routes, route)
+		}
+	}
+
+	// release the senders of the removed routes
+	for i := len(routes); i < len(r.routes); i++ {
+		r.routes[i] = tagRoute{}
+	}
+
+	r.routes = routes
+}
+
+// Route returns the senders that a message tagged tag is sent to.
+func (r *TagRouter) Route(tag string) []MessageSender {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var senders []MessageSender
+
+	for _, route := range r.routes {
+		if route.match.MatchString(tag) {
+			senders = append(senders, route.sender)
+
+			if !r.Fanout {
+				break
+			}
+		}
+	}
+
+	return senders
+}
+
+// Send sends e to the routes matching its tag. In Fanout mode a failure
+// does not stop the message going to the other routes; the first error is
+// returned.
+func (r *TagRouter) Send(e protocol.ChunkEncoder) error {
+	return r.send(MessageTag(e), func(sender MessageSender) error {
+		return sender.Send(e)
+	})
+}
+
+// SendRaw sends an encoded message to the routes matching its tag, which
+// is read from the message, as Send does.
+func (r *TagRouter) SendRaw(raw []byte) error {
+	_, b, err := msgp.ReadArrayHeaderBytes(raw)
+	if err != nil {
+		return fmt.Errorf("read tag: %w", err)
+	}
+
+	tag, _, err := msgp.ReadStringBytes(b)
+	if err != nil {
+		return fmt.Errorf("read tag: %w", err)
+	}
+
+	return r.send(tag, func(sender MessageSender) error {
+		return sender.SendRaw(raw)
+	})
+}
+
+// SendMessage sends a single event in Message mode to the matching
+// routes, as Send does.
+func (r *TagRouter) SendMessage(tag string, record interface{}) error {
+	msg := protocol.NewMessage(tag, record)
+
+	return r.send(tag, func(sender MessageSender) error {
+		return sender.Send(msg)
+	})
+}
+
+func (r *TagRouter) send(tag string, send func(MessageSender) error) error {
+	senders := r.Route(tag)
+	if len(senders) == 0 {
+		return fmt.Errorf("%w %q", ErrNoRoute, tag)
+	}
+
+	var err error
+
+	for _, sender := range senders {
+		if serr := send(sender); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+func compileTagPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+
+	return regexp.Compile("^" + globToRegexp(pattern) + "$")
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], ".**"):
+			sb.WriteString(`(?:\..*)?`)
+			i += 2
+		case strings.HasPrefix(glob[i:], "**."):
+			sb.WriteString(`(?:.*\.)?`)
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(`.*`)
+			i++
+		case glob[i] == '*':
+			sb.WriteString(`[^.]+`)
+		case glob[i] == '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta(glob[i:]))
+				return sb.String()
+			}
+
+			alts := strings.Split(glob[i+1:i+end], ",")
+			for j, alt := range alts {
+				alts[j] = globToRegexp(alt)
+			}
+
+			sb.WriteString("(?:" + strings.Join(alts, "|") + ")")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	return sb.String()
+}
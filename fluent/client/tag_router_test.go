@@ -0,0 +1,214 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TagRouter", func() {
+	var (
+		router           *TagRouter
+		logs, metrics, x *clientfakes.FakeMessageSender
+	)
+
+	BeforeEach(func() {
+		router = &TagRouter{}
+		logs = &clientfakes.FakeMessageSender{}
+		metrics = &clientfakes.FakeMessageSender{}
+		x = &clientfakes.FakeMessageSender{}
+	})
+
+	DescribeTable("patterns",
+		func(pattern, tag string, matches bool) {
+			Expect(router.AddRoute(pattern, x)).To(Succeed())
+			Expect(router.Route(tag)).To(HaveLen(map[bool]int{true: 1, false: 0}[matches]))
+		},
+		Entry("exact", "app.logs", "app.logs", true),
+		Entry("exact mismatch", "app.logs", "app.log", false),
+		Entry("* matches one part", "app.*", "app.logs", true),
+		Entry("* does not match two parts", "app.*", "app.logs.err", false),
+		Entry("* does not match no part", "app.*", "app", false),
+		Entry("trailing ** matches no part", "app.**", "app", true),
+		Entry("trailing ** matches several parts", "app.**", "app.logs.err", true),
+		Entry("leading ** matches several parts", "**.err", "app.logs.err", true),
+		Entry("inner ** matches no part", "app.**.err", "app.err", true),
+		Entry("braces", "app.{logs,metrics}", "app.metrics", true),
+		Entry("braces mismatch", "app.{logs,metrics}", "app.traces", false),
+		Entry("braces with globs", "{app.*,sys}", "app.logs", true),
+		Entry("regexp", `/^app\.(logs|metrics)$/`, "app.logs", true),
+		Entry("regexp mismatch", `/^app\.(logs|metrics)$/`, "app.traces", false),
+		Entry("dots are literal", "app.logs", "appxlogs", false),
+	)
+
+	It("rejects invalid regular expressions", func() {
+		Expect(router.AddRoute("/(/", x)).To(MatchError(MatchRegexp(`route "/\(/"`)))
+	})
+
+	Describe("SendMessage", func() {
+		BeforeEach(func() {
+			Expect(router.AddRoute("app.logs", logs)).To(Succeed())
+			Expect(router.AddRoute("app.*", metrics)).To(Succeed())
+		})
+
+		It("sends to the first matching route", func() {
+			Expect(router.SendMessage("app.logs", map[string]string{"oi": "hi"})).To(Succeed())
+			Expect(logs.SendCallCount()).To(Equal(1))
+			Expect(metrics.SendCallCount()).To(BeZero())
+
+			msg, ok := logs.SendArgsForCall(0).(*protocol.Message)
+			Expect(ok).To(BeTrue())
+			Expect(msg.Tag).To(Equal("app.logs"))
+
+			Expect(router.SendMessage("app.metrics", map[string]string{"oi": "hi"})).To(Succeed())
+			Expect(metrics.SendCallCount()).To(Equal(1))
+		})
+
+		It("fails when no route matches", func() {
+			err := router.SendMessage("sys.logs", map[string]string{"oi": "hi"})
+			Expect(errors.Is(err, ErrNoRoute)).To(BeTrue())
+		})
+
+		It("stops routing to removed routes", func() {
+			router.RemoveRoute("app.logs")
+
+			Expect(router.SendMessage("app.logs", map[string]string{"oi": "hi"})).To(Succeed())
+			Expect(logs.SendCallCount()).To(BeZero())
+			Expect(metrics.SendCallCount()).To(Equal(1))
+		})
+
+		When("Fanout is set", func() {
+			BeforeEach(func() {
+				router.Fanout = true
+			})
+
+			It("sends to every matching route", func() {
+				Expect(router.SendMessage("app.logs", map[string]string{"oi": "hi"})).To(Succeed())
+				Expect(logs.SendCallCount()).To(Equal(1))
+				Expect(metrics.SendCallCount()).To(Equal(1))
+			})
+
+			It("returns the first error after trying every route", func() {
+				logs.SendReturns(errors.New("nope"))
+
+				Expect(router.SendMessage("app.logs", map[string]string{"oi": "hi"})).To(MatchError("nope"))
+				Expect(metrics.SendCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Send", func() {
+		BeforeEach(func() {
+			Expect(router.AddRoute("app.logs", logs)).To(Succeed())
+			Expect(router.AddRoute("app.*", metrics)).To(Succeed())
+		})
+
+		It("sends to the route matching the tag of the message", func() {
+			msg := protocol.NewMessage("app.metrics", map[string]string{"oi": "hi"})
+
+			Expect(router.Send(msg)).To(Succeed())
+			Expect(logs.SendCallCount()).To(BeZero())
+			Expect(metrics.SendCallCount()).To(Equal(1))
+			Expect(metrics.SendArgsForCall(0)).To(BeIdenticalTo(msg))
+		})
+
+		It("fails when no route matches", func() {
+			err := router.Send(protocol.NewMessage("sys.logs", map[string]string{"oi": "hi"}))
+			Expect(errors.Is(err, ErrNoRoute)).To(BeTrue())
+		})
+
+		It("returns the first error after trying every route in Fanout mode", func() {
+			router.Fanout = true
+			logs.SendReturns(errors.New("nope"))
+
+			Expect(router.Send(protocol.NewMessage("app.logs", nil))).To(MatchError("nope"))
+			Expect(metrics.SendCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("SendRaw", func() {
+		BeforeEach(func() {
+			Expect(router.AddRoute("app.logs", logs)).To(Succeed())
+			Expect(router.AddRoute("app.*", metrics)).To(Succeed())
+		})
+
+		It("sends to the route matching the tag read from the message", func() {
+			raw, err := protocol.NewMessage("app.metrics", map[string]string{"oi": "hi"}).MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(router.SendRaw(raw)).To(Succeed())
+			Expect(logs.SendRawCallCount()).To(BeZero())
+			Expect(metrics.SendRawCallCount()).To(Equal(1))
+			Expect(metrics.SendRawArgsForCall(0)).To(Equal(raw))
+		})
+
+		It("fails when no route matches", func() {
+			raw, err := protocol.NewMessage("sys.logs", nil).MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(errors.Is(router.SendRaw(raw), ErrNoRoute)).To(BeTrue())
+		})
+
+		It("fails for bytes that are not a message", func() {
+			Expect(router.SendRaw([]byte("oi"))).To(MatchError(ContainSubstring("read tag")))
+			Expect(logs.SendRawCallCount()).To(BeZero())
+			Expect(metrics.SendRawCallCount()).To(BeZero())
+		})
+	})
+
+	It("is safe for concurrent use", func() {
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+
+			pattern := fmt.Sprintf("app.%d", i)
+
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				Expect(router.AddRoute(pattern, x)).To(Succeed())
+				_ = router.SendMessage(pattern, map[string]string{"oi": "hi"})
+			}()
+
+			go func() {
+				defer wg.Done()
+
+				router.RemoveRoute(pattern)
+			}()
+		}
+
+		wg.Wait()
+	})
+})
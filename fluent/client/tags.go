@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// LowercaseTag is a TagTransformer that lowercases tags.
+func LowercaseTag(tag string) string {
+	return strings.ToLower(tag)
+}
+
+// TruncateTag returns a TagTransformer that cuts tags to at most maxLen
+// bytes, without splitting a UTF-8 character.
+func TruncateTag(maxLen int) func(string) string {
+	return func(tag string) string {
+		if len(tag) <= maxLen {
+			return tag
+		}
+
+		end := maxLen
+		for end > 0 && !utf8.RuneStart(tag[end]) {
+			end--
+		}
+
+		return tag[:end]
+	}
+}
+
+// ReplaceTagChars returns a TagTransformer that replaces each character of
+// old in tags with new, e.g. ReplaceTagChars(" /", ".") turns "my app/db"
+// into "my.app.db".
+func ReplaceTagChars(old, new string) func(string) string {
+	return func(tag string) string {
+		if !strings.ContainsAny(tag, old) {
+			return tag
+		}
+
+		var b strings.Builder
+
+		b.Grow(len(tag))
+
+		for _, r := range tag {
+			if strings.ContainsRune(old, r) {
+				b.WriteString(new)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+
+		return b.String()
+	}
+}
+
+// ChainTagTransformers returns a TagTransformer that applies ts in order.
+// Nil transformers are skipped.
+func ChainTagTransformers(ts ...func(string) string) func(string) string {
+	return func(tag string) string {
+		for _, t := range ts {
+			if t != nil {
+				tag = t(tag)
+			}
+		}
+
+		return tag
+	}
+}
+
+// retag returns e with the tag it is sent with: its tag passed through the
+// TagTransformer, if one is set, then prefixed with the TagPrefix.
+func (c *WSClient) retag(e msgp.Encodable) msgp.Encodable {
+	transform, prefix := c.TagTransformer, c.tagPrefix()
+	if transform == nil && prefix == "" {
+		return e
+	}
+
+	return withTag(e, func(tag string) string {
+		if transform != nil {
+			tag = transform(tag)
+		}
+
+		return prefix + tag
+	})
+}
+
+// withTag returns e with its tag replaced by f(tag). The protocol's
+// messages are copied, so that a message that is buffered or retried is
+// not retagged twice; other encoders are returned as they are.
+func withTag(e msgp.Encodable, f func(string) string) msgp.Encodable {
+	switch msg := e.(type) {
+	case *protocol.Message:
+		m := *msg
+		m.Tag = f(m.Tag)
+
+		return &m
+	case *protocol.MessageExt:
+		m := *msg
+		m.Tag = f(m.Tag)
+
+		return &m
+	case *protocol.ForwardMessage:
+		m := *msg
+		m.Tag = f(m.Tag)
+
+		return &m
+	case *protocol.PackedForwardMessage:
+		m := *msg
+		m.Tag = f(m.Tag)
+
+		return &m
+	case *protocol.CompressedPackedForwardMessage:
+		m := *msg.PackedForwardMessage
+		m.Tag = f(m.Tag)
+
+		return &protocol.CompressedPackedForwardMessage{
+			PackedForwardMessage: &m,
+			CompressionAlgorithm: msg.CompressionAlgorithm,
+			CompressionLevel:     msg.CompressionLevel,
+		}
+	default:
+		return e
+	}
+}
@@ -0,0 +1,193 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"context"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("WSClient TagPrefix", func() {
+	var (
+		conn *wsfakes.FakeConnection
+		cli  *WSClient
+	)
+
+	writtenTag := func(i int) string {
+		_, b, err := msgp.ReadArrayHeaderBytes(conn.WriteArgsForCall(i))
+		Expect(err).ToNot(HaveOccurred())
+
+		tag, _, err := msgp.ReadStringBytes(b)
+		Expect(err).ToNot(HaveOccurred())
+
+		return tag
+	}
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		cli = NewWSClient(WithConnectionFactory(factory), WithTagPrefix("payments."))
+		Expect(cli.Connect()).To(Succeed())
+	})
+
+	It("prefixes the tag of each message type", func() {
+		record := map[string]interface{}{"a": "b"}
+		entries := protocol.EntryList{{Timestamp: protocol.EventTimeNow(), Record: record}}
+
+		packed, err := protocol.NewPackedForwardMessage("packed", entries)
+		Expect(err).ToNot(HaveOccurred())
+
+		compressedPacked, err := protocol.NewPackedForwardMessage("compressed", entries)
+		Expect(err).ToNot(HaveOccurred())
+
+		compressed := protocol.NewCompressedPackedForward(compressedPacked)
+
+		Expect(cli.Send(protocol.NewMessage("message", record))).To(Succeed())
+		Expect(cli.Send(protocol.NewMessageExt("ext", record))).To(Succeed())
+		Expect(cli.SendMessages("forward", entries, "")).To(Succeed())
+		Expect(cli.Send(packed)).To(Succeed())
+		Expect(cli.Send(compressed)).To(Succeed())
+
+		Expect(writtenTag(0)).To(Equal("payments.message"))
+		Expect(writtenTag(1)).To(Equal("payments.ext"))
+		Expect(writtenTag(2)).To(Equal("payments.forward"))
+		Expect(writtenTag(3)).To(Equal("payments.packed"))
+		Expect(writtenTag(4)).To(Equal("payments.compressed"))
+		Expect(compressed.Tag).To(Equal("compressed"))
+	})
+
+	It("does not change the message", func() {
+		msg := protocol.NewMessage("db.query", map[string]interface{}{"a": "b"})
+
+		Expect(cli.Send(msg)).To(Succeed())
+		Expect(cli.Send(msg)).To(Succeed())
+
+		Expect(msg.Tag).To(Equal("db.query"))
+		Expect(writtenTag(1)).To(Equal("payments.db.query"))
+	})
+
+	It("is applied after the middleware", func() {
+		var seen string
+
+		cli.Use(func(next SendFunc) SendFunc {
+			return func(ctx context.Context, e msgp.Encodable) error {
+				msg := e.(*protocol.Message)
+				seen = msg.Tag
+				msg.Tag = "rewritten"
+
+				return next(ctx, e)
+			}
+		})
+		Expect(cli.Reconnect()).To(Succeed())
+
+		Expect(cli.Send(protocol.NewMessage("original", "a"))).To(Succeed())
+		Expect(seen).To(Equal("original"))
+		Expect(writtenTag(0)).To(Equal("payments.rewritten"))
+	})
+
+	It("is disabled when empty", func() {
+		cli.TagPrefix = ""
+
+		Expect(cli.Send(protocol.NewMessage("tag", "a"))).To(Succeed())
+		Expect(writtenTag(0)).To(Equal("tag"))
+	})
+})
+
+var _ = Describe("WSClient TagTransformer", func() {
+	var (
+		conn *wsfakes.FakeConnection
+		cli  *WSClient
+	)
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		cli = NewWSClient(WithConnectionFactory(factory), WithTagTransformer(LowercaseTag))
+		Expect(cli.Connect()).To(Succeed())
+	})
+
+	writtenTag := func(i int) string {
+		var msg protocol.ForwardMessage
+
+		_, err := msg.UnmarshalMsg(conn.WriteArgsForCall(i))
+		Expect(err).ToNot(HaveOccurred())
+
+		return msg.Tag
+	}
+
+	It("rewrites the tag of SendMessages", func() {
+		entries := protocol.EntryList{{Timestamp: protocol.EventTimeNow(), Record: "a"}}
+
+		Expect(cli.SendMessages("My.App", entries, "")).To(Succeed())
+		Expect(writtenTag(0)).To(Equal("my.app"))
+	})
+
+	It("is applied before the TagPrefix", func() {
+		cli.TagPrefix = "Payments."
+		msg := protocol.NewForwardMessage("DB", nil)
+
+		Expect(cli.Send(msg)).To(Succeed())
+		Expect(writtenTag(0)).To(Equal("Payments.db"))
+		Expect(msg.Tag).To(Equal("DB"))
+	})
+
+	It("is a no-op when nil", func() {
+		cli.TagTransformer = nil
+
+		Expect(cli.Send(protocol.NewForwardMessage("DB", nil))).To(Succeed())
+		Expect(writtenTag(0)).To(Equal("DB"))
+	})
+})
+
+var _ = Describe("Tag transformers", func() {
+	DescribeTable("transform tags",
+		func(transform func(string) string, tag, expected string) {
+			Expect(transform(tag)).To(Equal(expected))
+		},
+		Entry("LowercaseTag", LowercaseTag, "App.DB", "app.db"),
+		Entry("TruncateTag of a long tag", TruncateTag(5), "app.db.query", "app.d"),
+		Entry("TruncateTag of a short tag", TruncateTag(255), "app.db", "app.db"),
+		Entry("TruncateTag within a character", TruncateTag(5), "app.é", "app."),
+		Entry("ReplaceTagChars", ReplaceTagChars(" /", "."), "my app/db", "my.app.db"),
+		Entry("ReplaceTagChars with nothing to replace", ReplaceTagChars(" ", "."), "app.db", "app.db"),
+		Entry("ChainTagTransformers", ChainTagTransformers(ReplaceTagChars(" ", "."), LowercaseTag, nil, TruncateTag(6)),
+			"My App Db", "my.app"),
+		Entry("ChainTagTransformers of nothing", ChainTagTransformers(), "App", "App"),
+	)
+})
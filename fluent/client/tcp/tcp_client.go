@@ -0,0 +1,133 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tcp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+)
+
+const (
+	// DefaultAddress is where Fluentd's forward input listens by default.
+	DefaultAddress = "localhost:24224"
+)
+
+// Dialer is a client.ConnectionFactory that opens raw TCP connections
+// with a client.ConnFactory.
+type Dialer struct {
+	// Address is the host:port of the server. The default value is
+	// DefaultAddress.
+	Address string
+	// Timeout bounds how long a dial may take. Zero means no timeout.
+	Timeout time.Duration
+	// KeepAlive is the TCP keep-alive period. Zero enables keep-alives
+	// with the system default; a negative value disables them.
+	KeepAlive time.Duration
+
+	lock sync.Mutex
+}
+
+// SetAddress changes the address that later dials connect to. It is safe
+// to call while a dial is in progress.
+func (d *Dialer) SetAddress(address string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.Address = address
+}
+
+func (d *Dialer) New() (net.Conn, error) {
+	d.lock.Lock()
+	address := d.Address
+	d.lock.Unlock()
+
+	if len(address) == 0 {
+		address = DefaultAddress
+	}
+
+	factory := &client.ConnFactory{
+		Network:   "tcp",
+		Address:   address,
+		Timeout:   d.Timeout,
+		KeepAlive: d.KeepAlive,
+	}
+
+	return factory.New()
+}
+
+type Options struct {
+	Address     string
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+	RequireAck  bool
+	AckTimeout  time.Duration
+	AuthInfo    client.AuthInfo
+	RetryPolicy client.RetryPolicy
+}
+
+// TCPClient sends Fluent Forward messages over a raw TCP connection, for
+// servers such as Fluentd's in_forward that do not speak websocket. It
+// has the same Connect, Disconnect, Reconnect, ReconnectWithRetry and
+// Send* methods as client.WSClient.
+//
+// Messages are written as bare MessagePack values, as the Forward
+// protocol requires; MessagePack is self-delimiting, so no length prefix
+// is added.
+type TCPClient struct {
+	*client.Client
+
+	dialer *Dialer
+}
+
+// New returns a TCPClient for the server at opts.Address. AckTimeout is
+// how long to wait for an ack when RequireAck is set; see
+// client.ConnectionOptions.ConnectionTimeout.
+func New(opts Options) *TCPClient {
+	dialer := &Dialer{
+		Address:   opts.Address,
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+
+	return &TCPClient{
+		Client: client.New(client.ConnectionOptions{
+			Factory:           dialer,
+			RequireAck:        opts.RequireAck,
+			ConnectionTimeout: opts.AckTimeout,
+			AuthInfo:          opts.AuthInfo,
+			RetryPolicy:       opts.RetryPolicy,
+		}),
+		dialer: dialer,
+	}
+}
+
+// SetAddress changes the server that the next Connect or Reconnect
+// connects to. The current connection is not affected.
+func (c *TCPClient) SetAddress(address string) {
+	c.dialer.SetAddress(address)
+}
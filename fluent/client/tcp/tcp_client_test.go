@@ -0,0 +1,205 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tcp_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/tcp"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// forwardServer is a minimal stand-in for Fluentd's in_forward: it decodes
+// Message mode events and acks the ones that carry a chunk.
+type forwardServer struct {
+	listener net.Listener
+	messages chan protocol.Message
+	conns    chan net.Conn
+}
+
+func newForwardServer(address string) *forwardServer {
+	l, err := net.Listen("tcp", address)
+	Expect(err).ToNot(HaveOccurred())
+
+	s := &forwardServer{
+		listener: l,
+		messages: make(chan protocol.Message, 10),
+		conns:    make(chan net.Conn, 10),
+	}
+
+	go s.serve()
+
+	return s
+}
+
+func (s *forwardServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.conns <- conn
+
+		go s.handle(conn)
+	}
+}
+
+func (s *forwardServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := msgp.NewReader(conn)
+
+	for {
+		var msg protocol.Message
+		if err := msg.DecodeMsg(r); err != nil {
+			return
+		}
+
+		if msg.Options != nil && msg.Options.Chunk != "" {
+			ack := protocol.AckMessage{Ack: msg.Options.Chunk}
+			if err := msgp.Encode(conn, &ack); err != nil {
+				return
+			}
+		}
+
+		s.messages <- msg
+	}
+}
+
+func (s *forwardServer) Close() {
+	_ = s.listener.Close()
+}
+
+var _ = Describe("TCPClient", func() {
+	var (
+		svr  *forwardServer
+		opts tcp.Options
+		c    *tcp.TCPClient
+	)
+
+	BeforeEach(func() {
+		svr = newForwardServer("127.0.0.1:0")
+		opts = tcp.Options{
+			Address:     svr.listener.Addr().String(),
+			DialTimeout: time.Second,
+		}
+	})
+
+	JustBeforeEach(func() {
+		c = tcp.New(opts)
+		Expect(c.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = c.Disconnect()
+		svr.Close()
+	})
+
+	It("sends messages in the Forward wire format", func() {
+		for _, tag := range []string{"foo", "bar", "baz"} {
+			Expect(c.SendMessage(tag, map[string]interface{}{"hello": "world"})).To(Succeed())
+		}
+
+		for _, tag := range []string{"foo", "bar", "baz"} {
+			var msg protocol.Message
+			Eventually(svr.messages).Should(Receive(&msg))
+			Expect(msg.Tag).To(Equal(tag))
+			Expect(msg.Record).To(HaveKeyWithValue("hello", "world"))
+		}
+	})
+
+	It("connects to a new address set with SetAddress on Reconnect", func() {
+		other := newForwardServer("127.0.0.1:0")
+		defer other.Close()
+
+		c.SetAddress(other.listener.Addr().String())
+		Expect(c.Reconnect()).To(Succeed())
+
+		Expect(c.SendMessage("foo", nil)).To(Succeed())
+		Eventually(other.messages).Should(Receive())
+		Expect(svr.messages).ToNot(Receive())
+	})
+
+	When("RequireAck is set", func() {
+		BeforeEach(func() {
+			opts.RequireAck = true
+			opts.AckTimeout = time.Second
+		})
+
+		It("waits for the ack", func() {
+			Expect(c.SendMessage("foo", map[string]interface{}{"hello": "world"})).To(Succeed())
+
+			var msg protocol.Message
+			Expect(svr.messages).To(Receive(&msg))
+			Expect(msg.Options.Chunk).ToNot(BeEmpty())
+		})
+	})
+
+	Describe("Reconnect", func() {
+		It("replaces a connection closed by the server", func() {
+			var conn net.Conn
+			Eventually(svr.conns).Should(Receive(&conn))
+			Expect(conn.Close()).To(Succeed())
+
+			Expect(c.Reconnect()).To(Succeed())
+			Expect(c.SendMessage("foo", map[string]interface{}{"hello": "world"})).To(Succeed())
+			Eventually(svr.messages).Should(Receive())
+		})
+	})
+
+	Describe("ReconnectWithRetry", func() {
+		BeforeEach(func() {
+			opts.RetryPolicy = &client.DefaultExponentialBackoff{
+				BaseDelay:  10 * time.Millisecond,
+				Multiplier: 1,
+				Attempts:   100,
+			}
+		})
+
+		It("retries until the server is back", func() {
+			address := svr.listener.Addr().String()
+			svr.Close()
+
+			restarted := make(chan *forwardServer, 1)
+
+			go func() {
+				defer GinkgoRecover()
+
+				time.Sleep(50 * time.Millisecond)
+				restarted <- newForwardServer(address)
+			}()
+
+			Expect(c.ReconnectWithRetry(context.Background())).To(Succeed())
+			svr = <-restarted
+		})
+	})
+})
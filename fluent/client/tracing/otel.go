@@ -0,0 +1,71 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package tracing provides client.Middleware that traces sends.
+package tracing
+
+import (
+	"context"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// SpanName is the name of the span created for each send.
+	SpanName = "fluent.send"
+	// TagKey is the span attribute holding the message tag.
+	TagKey = attribute.Key("fluent.tag")
+)
+
+// OpenTelemetryMiddleware starts a producer span around every send, as a
+// child of any span in the send's context. A failed send records the
+// error on the span and sets its status to Error.
+func OpenTelemetryMiddleware(tracer trace.Tracer) client.Middleware {
+	return func(next client.SendFunc) client.SendFunc {
+		return func(ctx context.Context, e msgp.Encodable) error {
+			ctx, span := tracer.Start(ctx, SpanName,
+				trace.WithSpanKind(trace.SpanKindProducer),
+				trace.WithAttributes(TagKey.String(client.MessageTag(e))),
+			)
+			defer span.End()
+
+			err := next(ctx, e)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err
+		}
+	}
+}
+
+// TracingMiddleware is an alias of OpenTelemetryMiddleware.
+func TracingMiddleware(tracer trace.Tracer) client.Middleware {
+	return OpenTelemetryMiddleware(tracer)
+}
@@ -0,0 +1,102 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tracing_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/tracing"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("OpenTelemetryMiddleware", func() {
+	var (
+		recorder *tracetest.SpanRecorder
+		tracer   trace.Tracer
+		sendErr  error
+		sendCtx  context.Context
+	)
+
+	send := func(ctx context.Context, e msgp.Encodable) error {
+		sendCtx = ctx
+		return sendErr
+	}
+
+	BeforeEach(func() {
+		recorder = tracetest.NewSpanRecorder()
+		tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+		sendErr = nil
+	})
+
+	It("wraps the send in a span", func() {
+		parentCtx, parent := tracer.Start(context.Background(), "parent")
+
+		err := tracing.OpenTelemetryMiddleware(tracer)(send)(parentCtx, protocol.NewMessage("foo", nil))
+		Expect(err).ToNot(HaveOccurred())
+		parent.End()
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(2))
+
+		span := spans[0]
+		Expect(span.Name()).To(Equal(tracing.SpanName))
+		Expect(span.SpanKind()).To(Equal(trace.SpanKindProducer))
+		Expect(span.Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(span.Attributes()).To(ContainElement(tracing.TagKey.String("foo")))
+		Expect(span.Status().Code).To(Equal(codes.Unset))
+
+		Expect(trace.SpanFromContext(sendCtx).SpanContext().SpanID()).To(Equal(span.SpanContext().SpanID()))
+	})
+
+	It("records errors", func() {
+		sendErr = errors.New("nope")
+
+		err := tracing.OpenTelemetryMiddleware(tracer)(send)(context.Background(), protocol.NewMessage("foo", nil))
+		Expect(err).To(MatchError("nope"))
+
+		span := recorder.Ended()[0]
+		Expect(span.Status().Code).To(Equal(codes.Error))
+		Expect(span.Status().Description).To(Equal("nope"))
+		Expect(span.Events()).To(HaveLen(1))
+	})
+
+	It("is also available as TracingMiddleware", func() {
+		err := tracing.TracingMiddleware(tracer)(send)(context.Background(), protocol.NewMessage("foo", nil))
+		Expect(err).ToNot(HaveOccurred())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal(tracing.SpanName))
+		Expect(spans[0].Attributes()).To(ContainElement(tracing.TagKey.String("foo")))
+	})
+})
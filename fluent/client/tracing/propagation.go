@@ -0,0 +1,58 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tracing
+
+import (
+	"context"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// OptionsCarrier is a propagation.TextMapCarrier backed by the options of
+// a Fluent message.
+type OptionsCarrier = client.OptionsCarrier
+
+// PropagationMiddleware injects the trace context of each send's context
+// into the message options, using propagator, before the message is
+// encoded. The message is modified in place, like Chunk does. Messages
+// of types without options are sent unchanged.
+//
+// Add it after OpenTelemetryMiddleware to propagate the send span rather
+// than its parent:
+//
+//	c.Use(tracing.OpenTelemetryMiddleware(tracer), tracing.PropagationMiddleware(propagation.TraceContext{}))
+func PropagationMiddleware(propagator propagation.TextMapPropagator) client.Middleware {
+	return func(next client.SendFunc) client.SendFunc {
+		return func(ctx context.Context, e msgp.Encodable) error {
+			if opts := client.MessageOptions(e); opts != nil {
+				propagator.Inject(ctx, OptionsCarrier{Options: opts})
+			}
+
+			return next(ctx, e)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package tracing_test
+
+import (
+	"context"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/tracing"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("PropagationMiddleware", func() {
+	var (
+		propagator propagation.TextMapPropagator
+		ctx        context.Context
+		span       trace.Span
+		sent       msgp.Encodable
+		send       func(ctx context.Context, e msgp.Encodable) error
+	)
+
+	BeforeEach(func() {
+		propagator = propagation.TraceContext{}
+
+		tracer := sdktrace.NewTracerProvider().Tracer("test")
+		ctx, span = tracer.Start(context.Background(), "parent")
+
+		state, err := trace.ParseTraceState("vendor=oi")
+		Expect(err).ToNot(HaveOccurred())
+		ctx = trace.ContextWithSpanContext(ctx, span.SpanContext().WithTraceState(state))
+
+		send = tracing.PropagationMiddleware(propagator)(func(ctx context.Context, e msgp.Encodable) error {
+			sent = e
+			return nil
+		})
+	})
+
+	AfterEach(func() {
+		span.End()
+	})
+
+	It("injects the trace context into the message options", func() {
+		msg := protocol.NewMessage("foo", nil)
+		Expect(send(ctx, msg)).To(Succeed())
+
+		Expect(msg.Options.TraceParent).To(HavePrefix("00-" + span.SpanContext().TraceID().String()))
+		Expect(msg.Options.TraceState).To(Equal("vendor=oi"))
+	})
+
+	It("round-trips through encoding", func() {
+		msg := protocol.NewMessage("foo", map[string]string{"oi": "hi"})
+		Expect(send(ctx, msg)).To(Succeed())
+
+		bits, err := msg.MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		var decoded protocol.Message
+		_, err = decoded.UnmarshalMsg(bits)
+		Expect(err).ToNot(HaveOccurred())
+
+		extracted := propagator.Extract(context.Background(), tracing.OptionsCarrier{Options: decoded.Options})
+		sc := trace.SpanContextFromContext(extracted)
+		Expect(sc.TraceID()).To(Equal(span.SpanContext().TraceID()))
+		Expect(sc.SpanID()).To(Equal(span.SpanContext().SpanID()))
+		Expect(sc.TraceState().Get("vendor")).To(Equal("oi"))
+	})
+
+	It("keeps existing options", func() {
+		msg := protocol.NewForwardMessage("foo", nil)
+		msg.Options = &protocol.MessageOptions{Chunk: "abc"}
+		Expect(send(ctx, msg)).To(Succeed())
+
+		Expect(msg.Options.Chunk).To(Equal("abc"))
+		Expect(msg.Options.TraceParent).ToNot(BeEmpty())
+	})
+
+	It("leaves messages alone when there is no trace", func() {
+		msg := protocol.NewMessage("foo", nil)
+		Expect(send(context.Background(), msg)).To(Succeed())
+
+		Expect(msg.Options.TraceParent).To(BeEmpty())
+		Expect(tracing.OptionsCarrier{Options: msg.Options}.Keys()).To(BeEmpty())
+	})
+
+	It("sends a compressed message without its packed message unchanged", func() {
+		msg := &protocol.CompressedPackedForwardMessage{}
+		Expect(send(ctx, msg)).To(Succeed())
+		Expect(sent).To(BeIdenticalTo(msg))
+	})
+
+	It("sends messages without options unchanged", func() {
+		raw := protocol.RawMessage{0x90}
+		Expect(send(ctx, raw)).To(Succeed())
+		Expect(sent).To(Equal(raw))
+	})
+})
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package udp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+const (
+	// DefaultMaxDatagramSize fits a datagram into a single unfragmented
+	// packet on a 1500 byte MTU: 1500 less the IPv4 and UDP headers.
+	DefaultMaxDatagramSize = 1472
+)
+
+// OversizePolicy decides what UDPClient does with a message that does not
+// fit in a datagram.
+type OversizePolicy uint8
+
+const (
+	// ErrorOnOversize drops the message and returns an error wrapping
+	// client.ErrMessageTooLarge.
+	ErrorOnOversize OversizePolicy = iota
+	// TruncateOnOversize sends the first MaxDatagramSize bytes of the
+	// message. The result is not valid MessagePack, so this is only useful
+	// for receivers that salvage what they can.
+	TruncateOnOversize
+)
+
+type Options struct {
+	Address         string
+	MaxDatagramSize int
+	OnOversize      OversizePolicy
+}
+
+// UDPClient sends each message as a single UDP datagram. Delivery is best
+// effort: datagrams may be lost, duplicated or reordered, and acks are
+// never requested. Because UDP is connectionless there is no session to
+// manage; the socket is opened on the first send and released by Close.
+type UDPClient struct {
+	// Address is the host:port of the server.
+	Address string
+	// MaxDatagramSize is the largest datagram sent. If zero,
+	// DefaultMaxDatagramSize is used.
+	MaxDatagramSize int
+	// OnOversize is applied to messages larger than MaxDatagramSize.
+	OnOversize OversizePolicy
+	conn       net.Conn
+	connLock   sync.Mutex
+}
+
+func New(opts Options) *UDPClient {
+	if opts.MaxDatagramSize <= 0 {
+		opts.MaxDatagramSize = DefaultMaxDatagramSize
+	}
+
+	return &UDPClient{
+		Address:         opts.Address,
+		MaxDatagramSize: opts.MaxDatagramSize,
+		OnOversize:      opts.OnOversize,
+	}
+}
+
+// Send encodes e and writes it as one datagram.
+func (c *UDPClient) Send(e protocol.ChunkEncoder) error {
+	var buf bytes.Buffer
+
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+
+	return c.SendRaw(buf.Bytes())
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *UDPClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
+
+// SendRaw writes raw as one datagram, subject to OnOversize.
+func (c *UDPClient) SendRaw(raw []byte) error {
+	if maxSize := c.MaxDatagramSize; maxSize > 0 && len(raw) > maxSize {
+		if c.OnOversize != TruncateOnOversize {
+			return fmt.Errorf("%d byte datagram exceeds %d: %w", len(raw), maxSize, client.ErrMessageTooLarge)
+		}
+
+		raw = raw[:maxSize]
+	}
+
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+
+	if c.conn == nil {
+		conn, err := net.Dial("udp", c.Address)
+		if err != nil {
+			return err
+		}
+
+		c.conn = conn
+	}
+
+	_, err := c.conn.Write(raw)
+
+	return err
+}
+
+// Close releases the socket. A later send opens a new one.
+func (c *UDPClient) Close() (err error) {
+	c.connLock.Lock()
+	defer c.connLock.Unlock()
+
+	if c.conn != nil {
+		err = c.conn.Close()
+		c.conn = nil
+	}
+
+	return
+}
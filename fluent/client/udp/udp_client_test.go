@@ -0,0 +1,131 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package udp_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/udp"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("UDPClient", func() {
+	var (
+		svr  net.PacketConn
+		opts udp.Options
+		c    *udp.UDPClient
+	)
+
+	read := func() []byte {
+		buf := make([]byte, 64*1024)
+
+		Expect(svr.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		n, _, err := svr.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+
+		return buf[:n]
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		svr, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		opts = udp.Options{
+			Address:         svr.LocalAddr().String(),
+			MaxDatagramSize: 100,
+		}
+	})
+
+	JustBeforeEach(func() {
+		c = udp.New(opts)
+	})
+
+	AfterEach(func() {
+		Expect(c.Close()).To(Succeed())
+		Expect(svr.Close()).To(Succeed())
+	})
+
+	It("is a MessageSender", func() {
+		var sender client.MessageSender = c
+		Expect(sender).ToNot(BeNil())
+	})
+
+	It("sends each message as a datagram", func() {
+		Expect(c.SendMessage("foo", map[string]interface{}{"hello": "world"})).To(Succeed())
+		Expect(c.SendMessage("bar", map[string]interface{}{"hello": "world"})).To(Succeed())
+
+		for _, tag := range []string{"foo", "bar"} {
+			var msg protocol.Message
+			_, err := msg.UnmarshalMsg(read())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(msg.Tag).To(Equal(tag))
+			Expect(msg.Record).To(HaveKeyWithValue("hello", "world"))
+		}
+	})
+
+	It("uses DefaultMaxDatagramSize by default", func() {
+		Expect(udp.New(udp.Options{}).MaxDatagramSize).To(Equal(udp.DefaultMaxDatagramSize))
+	})
+
+	When("a message is too large", func() {
+		var big map[string]interface{}
+
+		BeforeEach(func() {
+			big = map[string]interface{}{"msg": strings.Repeat("x", 200)}
+		})
+
+		It("returns an error by default", func() {
+			err := c.SendMessage("foo", big)
+			Expect(errors.Is(err, client.ErrMessageTooLarge)).To(BeTrue())
+		})
+
+		When("the policy is TruncateOnOversize", func() {
+			BeforeEach(func() {
+				opts.OnOversize = udp.TruncateOnOversize
+			})
+
+			It("sends the first MaxDatagramSize bytes", func() {
+				Expect(c.SendMessage("foo", big)).To(Succeed())
+
+				bits, err := msgp.AppendIntf(nil, protocol.NewMessage("foo", big))
+				Expect(err).ToNot(HaveOccurred())
+
+				datagram := read()
+				Expect(datagram).To(HaveLen(100))
+				// array header and tag; the timestamp may have moved on
+				Expect(datagram[:5]).To(Equal(bits[:5]))
+			})
+		})
+	})
+})
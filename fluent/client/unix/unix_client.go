@@ -0,0 +1,153 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package unix
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// Dialer is a client.ConnectionFactory that opens unix domain socket
+// connections with a client.ConnFactory.
+type Dialer struct {
+	// Path is the file path of the server's socket.
+	Path string
+	// Timeout bounds how long a dial may take. Zero means no timeout.
+	Timeout time.Duration
+}
+
+func (d *Dialer) New() (net.Conn, error) {
+	if len(d.Path) == 0 {
+		return nil, errors.New("no socket path")
+	}
+
+	factory := &client.ConnFactory{
+		Network: "unix",
+		Address: d.Path,
+		Timeout: d.Timeout,
+	}
+
+	return factory.New()
+}
+
+type Options struct {
+	Path        string
+	DialTimeout time.Duration
+	RequireAck  bool
+	AckTimeout  time.Duration
+	AuthInfo    client.AuthInfo
+	RetryPolicy client.RetryPolicy
+}
+
+// UnixClient sends Fluent Forward messages over a unix domain socket, for
+// a Fluentd or Fluent Bit agent on the same host. It has the same Connect,
+// Disconnect, Reconnect, ReconnectWithRetry and Send* methods as
+// client.WSClient.
+//
+// Go does not let SIGPIPE kill the process for sockets, so a write to an
+// agent that has gone away fails with a broken pipe instead. When a send
+// fails that way, UnixClient reconnects with ReconnectWithRetry and sends
+// the message again once.
+type UnixClient struct {
+	*client.Client
+}
+
+// New returns a UnixClient for the socket at opts.Path. AckTimeout is how
+// long to wait for an ack when RequireAck is set; see
+// client.ConnectionOptions.ConnectionTimeout.
+func New(opts Options) *UnixClient {
+	return &UnixClient{
+		Client: client.New(client.ConnectionOptions{
+			Factory: &Dialer{
+				Path:    opts.Path,
+				Timeout: opts.DialTimeout,
+			},
+			RequireAck:        opts.RequireAck,
+			ConnectionTimeout: opts.AckTimeout,
+			AuthInfo:          opts.AuthInfo,
+			RetryPolicy:       opts.RetryPolicy,
+		}),
+	}
+}
+
+// IsBrokenPipe reports whether err means the peer closed the socket.
+func IsBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func (c *UnixClient) resend(send func() error) error {
+	err := send()
+	if !IsBrokenPipe(err) {
+		return err
+	}
+
+	if rerr := c.ReconnectWithRetry(context.Background()); rerr != nil {
+		return rerr
+	}
+
+	return send()
+}
+
+func (c *UnixClient) Send(e protocol.ChunkEncoder) error {
+	return c.resend(func() error { return c.Client.Send(e) })
+}
+
+func (c *UnixClient) SendRaw(raw []byte) error {
+	return c.resend(func() error { return c.Client.SendRaw(raw) })
+}
+
+func (c *UnixClient) SendMessage(tag string, record interface{}) error {
+	return c.resend(func() error { return c.Client.SendMessage(tag, record) })
+}
+
+func (c *UnixClient) SendMessageExt(tag string, record interface{}) error {
+	return c.resend(func() error { return c.Client.SendMessageExt(tag, record) })
+}
+
+func (c *UnixClient) SendForward(tag string, entries protocol.EntryList) error {
+	return c.resend(func() error { return c.Client.SendForward(tag, entries) })
+}
+
+func (c *UnixClient) SendPacked(tag string, entries protocol.EntryList) error {
+	return c.resend(func() error { return c.Client.SendPacked(tag, entries) })
+}
+
+func (c *UnixClient) SendPackedFromBytes(tag string, entries []byte) error {
+	return c.resend(func() error { return c.Client.SendPackedFromBytes(tag, entries) })
+}
+
+func (c *UnixClient) SendCompressed(tag string, entries protocol.EntryList) error {
+	return c.resend(func() error { return c.Client.SendCompressed(tag, entries) })
+}
+
+func (c *UnixClient) SendCompressedFromBytes(tag string, entries []byte) error {
+	return c.resend(func() error { return c.Client.SendCompressedFromBytes(tag, entries) })
+}
@@ -0,0 +1,146 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package unix_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/unix"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
+)
+
+var _ = Describe("UnixClient", func() {
+	var (
+		dir      string
+		listener net.Listener
+		conns    chan net.Conn
+		messages chan protocol.Message
+		opts     unix.Options
+		c        *unix.UnixClient
+	)
+
+	handle := func(conn net.Conn) {
+		defer conn.Close()
+
+		r := msgp.NewReader(conn)
+
+		for {
+			var msg protocol.Message
+			if err := msg.DecodeMsg(r); err != nil {
+				return
+			}
+
+			messages <- msg
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		// keep the path short; sockets are limited to about 100 bytes
+		dir, err = os.MkdirTemp("", "ffg")
+		Expect(err).ToNot(HaveOccurred())
+
+		listener, err = net.Listen("unix", filepath.Join(dir, "fluent.sock"))
+		Expect(err).ToNot(HaveOccurred())
+
+		conns = make(chan net.Conn, 10)
+		messages = make(chan protocol.Message, 10)
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				conns <- conn
+			}
+		}()
+
+		opts = unix.Options{
+			Path:        listener.Addr().String(),
+			DialTimeout: time.Second,
+			RetryPolicy: &client.DefaultExponentialBackoff{
+				BaseDelay: time.Millisecond,
+				Attempts:  3,
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		c = unix.New(opts)
+		Expect(c.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		_ = c.Disconnect()
+		_ = listener.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("sends messages over the socket", func() {
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		go handle(conn)
+
+		Expect(c.SendMessage("foo", map[string]interface{}{"hello": "world"})).To(Succeed())
+
+		var msg protocol.Message
+		Eventually(messages).Should(Receive(&msg))
+		Expect(msg.Tag).To(Equal("foo"))
+	})
+
+	When("the agent closes the socket", func() {
+		It("reconnects and sends the message again", func() {
+			var conn net.Conn
+			Eventually(conns).Should(Receive(&conn))
+			Expect(conn.Close()).To(Succeed())
+
+			Expect(c.SendMessage("foo", map[string]interface{}{"hello": "world"})).To(Succeed())
+
+			Eventually(conns).Should(Receive(&conn))
+			go handle(conn)
+
+			var msg protocol.Message
+			Eventually(messages).Should(Receive(&msg))
+			Expect(msg.Tag).To(Equal("foo"))
+		})
+	})
+
+	Describe("IsBrokenPipe", func() {
+		It("is false for other errors", func() {
+			Expect(unix.IsBrokenPipe(nil)).To(BeFalse())
+			Expect(unix.IsBrokenPipe(os.ErrClosed)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,148 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+// WeightedNode pairs a MessageSender with its share of a WeightedClient's
+// traffic.
+type WeightedNode struct {
+	Weight int
+	Client MessageSender
+}
+
+// WeightedClient sends each message to one of several nodes chosen at
+// random in proportion to their weights, so that a node with weight 2
+// receives twice the traffic of one with weight 1. A node with weight 0
+// receives nothing, but keeps its place so that SetWeight can bring it
+// back at once.
+type WeightedClient struct {
+	nodes []WeightedNode
+	total int
+	lock  sync.RWMutex
+}
+
+// NewWeightedClient returns a client for nodes. Negative weights are
+// treated as 0.
+func NewWeightedClient(nodes ...WeightedNode) *WeightedClient {
+	c := &WeightedClient{nodes: make([]WeightedNode, len(nodes))}
+
+	for i, node := range nodes {
+		if node.Weight < 0 {
+			node.Weight = 0
+		}
+
+		c.nodes[i] = node
+		c.total += node.Weight
+	}
+
+	return c
+}
+
+// SetWeight changes the weight of the node at index. It takes effect from
+// the next send, and sends in progress are not affected.
+func (c *WeightedClient) SetWeight(index, weight int) error {
+	if weight < 0 {
+		return fmt.Errorf("weight %d is negative", weight)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if index < 0 || index >= len(c.nodes) {
+		return fmt.Errorf("no node %d", index)
+	}
+
+	c.total += weight - c.nodes[index].Weight
+	c.nodes[index].Weight = weight
+
+	return nil
+}
+
+// Weights returns the weight of each node.
+func (c *WeightedClient) Weights() []int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	weights := make([]int, len(c.nodes))
+	for i, node := range c.nodes {
+		weights[i] = node.Weight
+	}
+
+	return weights
+}
+
+// pick returns a node chosen by weighted random sampling, or nil if every
+// weight is 0.
+func (c *WeightedClient) pick() MessageSender {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.total == 0 {
+		return nil
+	}
+
+	n := rand.Intn(c.total) //#nosec
+
+	for _, node := range c.nodes {
+		if n < node.Weight {
+			return node.Client
+		}
+
+		n -= node.Weight
+	}
+
+	return nil
+}
+
+func (c *WeightedClient) Send(e protocol.ChunkEncoder) error {
+	sender := c.pick()
+	if sender == nil {
+		return errors.New("no nodes with weight")
+	}
+
+	return sender.Send(e)
+}
+
+func (c *WeightedClient) SendRaw(raw []byte) error {
+	sender := c.pick()
+	if sender == nil {
+		return errors.New("no nodes with weight")
+	}
+
+	return sender.SendRaw(raw)
+}
+
+// SendMessage sends a single event in Message mode.
+func (c *WeightedClient) SendMessage(tag string, record interface{}) error {
+	return c.Send(protocol.NewMessage(tag, record))
+}
@@ -0,0 +1,122 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WeightedClient", func() {
+	const sends = 20000
+
+	var (
+		senders []*clientfakes.FakeMessageSender
+		wc      *WeightedClient
+	)
+
+	// chiSquared sends and returns Pearson's statistic for how far the
+	// counts of each node with weight are from their expected shares.
+	chiSquared := func() float64 {
+		for i := 0; i < sends; i++ {
+			Expect(wc.SendRaw([]byte("oi"))).To(Succeed())
+		}
+
+		weights := wc.Weights()
+
+		total := 0
+		for _, w := range weights {
+			total += w
+		}
+
+		var chi2 float64
+
+		for i, w := range weights {
+			observed := float64(senders[i].SendRawCallCount())
+
+			if w == 0 {
+				Expect(observed).To(BeZero())
+				continue
+			}
+
+			expected := float64(sends) * float64(w) / float64(total)
+			chi2 += (observed - expected) * (observed - expected) / expected
+		}
+
+		return chi2
+	}
+
+	BeforeEach(func() {
+		senders = nil
+
+		var nodes []WeightedNode
+
+		for _, w := range []int{1, 2, 3, 4, 0} {
+			s := &clientfakes.FakeMessageSender{}
+			senders = append(senders, s)
+			nodes = append(nodes, WeightedNode{Weight: w, Client: s})
+		}
+
+		wc = NewWeightedClient(nodes...)
+	})
+
+	It("is a MessageSender", func() {
+		var sender MessageSender = wc
+		Expect(sender).ToNot(BeNil())
+	})
+
+	// the critical values are for p = 0.0001, so a correct sampler fails
+	// one run in ten thousand
+
+	It("sends in proportion to the weights", func() {
+		// 3 degrees of freedom
+		Expect(chiSquared()).To(BeNumerically("<", 21.11))
+	})
+
+	It("follows weights changed at runtime", func() {
+		Expect(wc.SetWeight(0, 0)).To(Succeed())
+		Expect(wc.SetWeight(4, 6)).To(Succeed())
+		Expect(wc.Weights()).To(Equal([]int{0, 2, 3, 4, 6}))
+
+		// 3 degrees of freedom
+		Expect(chiSquared()).To(BeNumerically("<", 21.11))
+	})
+
+	It("rejects bad weights and indexes", func() {
+		Expect(wc.SetWeight(0, -1)).ToNot(Succeed())
+		Expect(wc.SetWeight(5, 1)).ToNot(Succeed())
+		Expect(wc.Weights()).To(Equal([]int{1, 2, 3, 4, 0}))
+	})
+
+	It("fails when every weight is 0", func() {
+		for i := range senders {
+			Expect(wc.SetWeight(i, 0)).To(Succeed())
+		}
+
+		Expect(wc.SendMessage("foo", "oi")).To(MatchError("no nodes with weight"))
+	})
+})
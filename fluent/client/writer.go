@@ -0,0 +1,67 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client
+
+import (
+	"bytes"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	DefaultWriterTag = "log"
+	// WriterRecordKey is the record key under which Write puts the bytes
+	// it is given.
+	WriterRecordKey = "message"
+)
+
+// Write implements io.Writer, so that the client can be given to loggers
+// that write to one, e.g. log.New(c, "", 0). Each call sends p, less one
+// trailing newline, as the WriterRecordKey field of an event tagged
+// WriterTag and timestamped now, in a single-entry ForwardMessage. p is
+// copied, and Write is safe for concurrent use. It returns len(p) once the
+// message has been sent, or buffered as Send would, and 0 with the error
+// otherwise.
+func (c *WSClient) Write(p []byte) (int, error) {
+	tag := c.WriterTag
+	if tag == "" {
+		tag = DefaultWriterTag
+	}
+
+	msg := protocol.NewForwardMessage(tag, protocol.EntryList{
+		{
+			Timestamp: protocol.EventTimeNow(),
+			Record: map[string]interface{}{
+				WriterRecordKey: string(bytes.TrimSuffix(p, []byte("\n"))),
+			},
+		},
+	})
+
+	if err := c.Send(msg); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,123 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+
+	. "github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ io.Writer = &WSClient{}
+
+var _ = Describe("WSClient Write", func() {
+	var (
+		conn *wsfakes.FakeConnection
+		cli  *WSClient
+	)
+
+	written := func(i int) *protocol.ForwardMessage {
+		var msg protocol.ForwardMessage
+
+		_, err := msg.UnmarshalMsg(conn.WriteArgsForCall(i))
+		Expect(err).ToNot(HaveOccurred())
+
+		return &msg
+	}
+
+	BeforeEach(func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn = &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&WSSession{Connection: conn})
+
+		cli = NewWS(WSConnectionOptions{
+			Factory: factory,
+		})
+		Expect(cli.Connect()).To(Succeed())
+	})
+
+	It("sends each write as an event", func() {
+		n, err := cli.Write([]byte("hello\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+
+		msg := written(0)
+		Expect(msg.Tag).To(Equal(DefaultWriterTag))
+		Expect(msg.Entries).To(HaveLen(1))
+		Expect(msg.Entries[0].Record).To(HaveKeyWithValue(WriterRecordKey, "hello"))
+	})
+
+	It("uses the WriterTag", func() {
+		cli.WriterTag = "app.log"
+
+		_, err := cli.Write([]byte("hello"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(written(0).Tag).To(Equal("app.log"))
+	})
+
+	It("returns the send error", func() {
+		conn.WriteReturns(0, errors.New("nope"))
+
+		n, err := cli.Write([]byte("hello"))
+		Expect(err).To(MatchError("nope"))
+		Expect(n).To(BeZero())
+	})
+
+	It("can be used by a log.Logger from many goroutines", func() {
+		logger := log.New(cli, "", 0)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+				logger.Printf("line %d", i)
+			}(i)
+		}
+
+		wg.Wait()
+
+		Expect(conn.WriteCallCount()).To(Equal(10))
+
+		var lines []interface{}
+		for i := 0; i < 10; i++ {
+			lines = append(lines, written(i).Entries[0].Record.(map[string]interface{})[WriterRecordKey])
+		}
+
+		Expect(lines).To(ContainElements("line 0", "line 9"))
+	})
+})
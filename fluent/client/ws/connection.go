@@ -25,6 +25,7 @@ SOFTWARE.
 package ws
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
@@ -41,6 +42,22 @@ const (
 	DefaultCloseDeadline = 5 * time.Second
 )
 
+// ErrPongTimeout is returned by Listen when the peer does not answer a
+// heartbeat ping within the pong timeout.
+var ErrPongTimeout = errors.New("pong not received before timeout")
+
+// Clock is the source of timers for the heartbeat. It exists so that
+// tests can control time.
+type Clock interface {
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
 type Logger interface {
 	Println(v ...interface{})
 	Printf(format string, v ...interface{})
@@ -65,10 +82,57 @@ type ConnectionOptions struct {
 	// is received the client MUST call `Close`. An error returned by ReadHandler
 	// will be retured by `Listen`.
 	ReadHandler ReadHandler
+	// ReadTimeout, if greater than zero, fails the connection when nothing
+	// is received from the peer for that long while listening. The read
+	// deadline is extended after every message and every pong, so it may be
+	// combined with PingInterval to detect a peer that has gone silent.
+	ReadTimeout time.Duration
 	// TODO: should be a duration and added to `now` before every operation
 	WriteDeadline time.Time
+	// WriteTimeout, if greater than zero, limits how long each write may
+	// take. The deadline is set afresh before every write, unless an
+	// earlier one was set with SetWriteDeadline, and restored afterward.
+	WriteTimeout time.Duration
 	// Logger is an optional debug log writer.
 	Logger Logger
+	// PingInterval, if greater than zero, is how often a ping is sent while
+	// the connection is listening. If the peer does not answer with a pong
+	// within PongTimeout, the connection fails and Listen returns
+	// ErrPongTimeout.
+	PingInterval time.Duration
+	// PongTimeout is how long to wait for a pong. If zero, PingInterval
+	// is used.
+	PongTimeout time.Duration
+	// Clock is used to time the heartbeat. If nil, the system clock is used.
+	Clock Clock
+	// NoDelay sets TCP_NODELAY on the TCP connection under the websocket,
+	// turning off Nagle's algorithm so that small writes are sent at once
+	// instead of being held back to coalesce with later ones. It trades CPU,
+	// in more syscalls and packets, for latency. Go's own dialer already
+	// sets it; NoDelay ensures it for connections from any dialer.
+	NoDelay bool
+	// TCPKeepAlive, if greater than zero, turns on TCP keepalive for the
+	// connection under the websocket, with probes starting after it has
+	// been idle this long. Unlike PingInterval, the kernel sends the probes,
+	// so they keep an idle connection open through load balancers and
+	// detect a peer that vanished without the application's involvement.
+	TCPKeepAlive time.Duration
+	// TCPKeepAliveInterval is the time between unanswered keepalive probes.
+	// If zero, the system default is used. It is only applied on Linux.
+	TCPKeepAliveInterval time.Duration
+	// TCPKeepAliveCount is the number of unanswered keepalive probes after
+	// which the connection is dropped. If zero, the system default is used.
+	// It is only applied on Linux.
+	TCPKeepAliveCount int
+	// SocketSendBufferBytes, if greater than zero, sets the size of the
+	// kernel's send buffer (SO_SNDBUF) for the connection under the
+	// websocket. A larger buffer lets high-throughput writers run further
+	// ahead of the network before blocking. The kernel may adjust the size;
+	// Linux doubles it. It is only applied on Unix.
+	SocketSendBufferBytes int
+	// SocketRecvBufferBytes, if greater than zero, sets the size of the
+	// kernel's receive buffer (SO_RCVBUF). It is only applied on Unix.
+	SocketRecvBufferBytes int
 }
 
 type ConnState uint8
@@ -92,6 +156,10 @@ type Connection interface {
 	ReadHandler() ReadHandler
 	SetReadHandler(rh ReadHandler)
 	Write(data []byte) (int, error)
+	// WriteContext is like Write, but the context's deadline also bounds
+	// the write, which is abandoned if the context is done first. Other
+	// writes are not affected.
+	WriteContext(ctx context.Context, data []byte) (int, error)
 }
 
 type connection struct {
@@ -105,6 +173,15 @@ type connection struct {
 	done          chan struct{}
 	connState     ConnState
 	closeDeadline time.Duration
+	clock         Clock
+	pingInterval  time.Duration
+	pongTimeout   time.Duration
+	pongs         chan struct{}
+	heartbeatErr  error
+	readTimeout   time.Duration
+	writeTimeout  time.Duration
+	deadlineLock  sync.Mutex
+	writeDeadline time.Time
 }
 
 func NewConnection(conn ext.Conn, opts ConnectionOptions) (Connection, error) {
@@ -133,8 +210,41 @@ func NewConnection(conn ext.Conn, opts ConnectionOptions) (Connection, error) {
 		})
 	}
 
-	if opts.PongHandler != nil {
+	if opts.PingInterval > 0 {
+		wsc.pingInterval = opts.PingInterval
+		wsc.pongTimeout = opts.PongTimeout
+		wsc.pongs = make(chan struct{}, 1)
+		wsc.clock = opts.Clock
+
+		if wsc.pongTimeout <= 0 {
+			wsc.pongTimeout = wsc.pingInterval
+		}
+
+		if wsc.clock == nil {
+			wsc.clock = realClock{}
+		}
+	}
+
+	wsc.readTimeout = opts.ReadTimeout
+
+	if opts.PongHandler != nil || wsc.pongs != nil || wsc.readTimeout > 0 {
 		wsc.SetPongHandler(func(appData string) error {
+			// a pong is proof of life, so it counts against the read timeout
+			if err := wsc.extendReadDeadline(); err != nil {
+				return err
+			}
+
+			if wsc.pongs != nil {
+				select {
+				case wsc.pongs <- struct{}{}:
+				default:
+				}
+			}
+
+			if opts.PongHandler == nil {
+				return nil
+			}
+
 			return opts.PongHandler(wsc, appData)
 		})
 	}
@@ -158,6 +268,11 @@ func NewConnection(conn ext.Conn, opts ConnectionOptions) (Connection, error) {
 	}
 
 	wsc.closeDeadline = opts.CloseDeadline
+	wsc.writeTimeout = opts.WriteTimeout
+
+	if err := setSocketOptions(conn, opts); err != nil {
+		return nil, err
+	}
 
 	if err := wsc.SetReadDeadline(opts.ReadDeadline); err != nil {
 		return nil, err
@@ -291,7 +406,9 @@ func (wsc *connection) runReadLoop(nextMsg chan connMsg) {
 	msg := connMsg{}
 
 	for {
-		msg.mt, msg.message, msg.err = wsc.Conn.ReadMessage()
+		if msg.err = wsc.extendReadDeadline(); msg.err == nil {
+			msg.mt, msg.message, msg.err = wsc.Conn.ReadMessage()
+		}
 
 		if msg.err != nil {
 			if wsc.hasConnState(ConnStateClosed) && errors.Is(msg.err, net.ErrClosed) {
@@ -331,6 +448,10 @@ func (wsc *connection) Listen() error {
 	nextMsg := make(chan connMsg)
 	go wsc.runReadLoop(nextMsg)
 
+	if wsc.pingInterval > 0 {
+		go wsc.heartbeat()
+	}
+
 	var err error
 
 	for msg := range nextMsg {
@@ -346,9 +467,67 @@ func (wsc *connection) Listen() error {
 		}
 	}
 
+	wsc.stateLock.RLock()
+	if wsc.heartbeatErr != nil {
+		err = wsc.heartbeatErr
+	}
+	wsc.stateLock.RUnlock()
+
 	return err
 }
 
+// extendReadDeadline pushes the read deadline readTimeout into the future.
+// It is called from the read loop only, both before each read and from the
+// pong handler, which gorilla runs while reading.
+func (wsc *connection) extendReadDeadline() error {
+	if wsc.readTimeout <= 0 {
+		return nil
+	}
+
+	return wsc.Conn.SetReadDeadline(time.Now().Add(wsc.readTimeout))
+}
+
+// heartbeat pings the peer every pingInterval until the read loop exits.
+// If a pong does not arrive in time, the connection is marked as failed
+// and closed, which ends the read loop.
+func (wsc *connection) heartbeat() {
+	for {
+		select {
+		case <-wsc.done:
+			return
+		case <-wsc.clock.After(wsc.pingInterval):
+		}
+
+		// drop a pong that arrived after the previous timeout
+		select {
+		case <-wsc.pongs:
+		default:
+		}
+
+		if err := wsc.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsc.pongTimeout)); err != nil {
+			wsc.logger.Println("ping failed:", err)
+			return
+		}
+
+		select {
+		case <-wsc.done:
+			return
+		case <-wsc.pongs:
+		case <-wsc.clock.After(wsc.pongTimeout):
+			wsc.logger.Println("pong timeout; closing the connection")
+
+			wsc.stateLock.Lock()
+			wsc.heartbeatErr = ErrPongTimeout
+			wsc.connState |= ConnStateError
+			wsc.stateLock.Unlock()
+
+			_ = wsc.Conn.Close()
+
+			return
+		}
+	}
+}
+
 func (wsc *connection) NextReader() (messageType int, r io.Reader, err error) {
 	panic("use ReadHandler instead")
 }
@@ -366,12 +545,103 @@ func (wsc *connection) ReadHandler() ReadHandler {
 }
 
 func (wsc *connection) WriteMessage(messageType int, data []byte) error {
+	return wsc.writeMessage(context.Background(), messageType, data)
+}
+
+// writeMessage writes a message within the earliest of the deadline set
+// with SetWriteDeadline, the WriteTimeout and the context's deadline. The
+// deadline is applied, and restored afterward, under the write lock, so
+// that concurrent writes keep their own.
+func (wsc *connection) writeMessage(ctx context.Context, messageType int, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	wsc.writeLock.Lock()
 	defer wsc.writeLock.Unlock()
 
+	explicit := wsc.explicitWriteDeadline()
+	deadline := explicit
+
+	if wsc.writeTimeout > 0 {
+		if timeout := time.Now().Add(wsc.writeTimeout); deadline.IsZero() || timeout.Before(deadline) {
+			deadline = timeout
+		}
+	}
+
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+
+	if !deadline.Equal(explicit) {
+		if err := wsc.Conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+
+		// restore the deadline set by the caller, which may have changed
+		// while writing
+		defer func() {
+			_ = wsc.Conn.SetWriteDeadline(wsc.explicitWriteDeadline())
+		}()
+	}
+
+	if ctx.Done() != nil {
+		done := make(chan struct{})
+		finished := make(chan struct{})
+
+		// a canceled context expires the deadline of the network
+		// connection, which, unlike that of the websocket, may be set
+		// during the write. It is set until the write returns, as the
+		// websocket sets its own before writing each frame.
+		go func() {
+			defer close(finished)
+
+			select {
+			case <-ctx.Done():
+			case <-done:
+				return
+			}
+
+			ticker := time.NewTicker(time.Millisecond)
+			defer ticker.Stop()
+
+			for {
+				_ = wsc.Conn.UnderlyingConn().SetWriteDeadline(time.Now())
+
+				select {
+				case <-ticker.C:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		defer func() {
+			close(done)
+			<-finished
+		}()
+	}
+
 	return wsc.Conn.WriteMessage(messageType, data)
 }
 
+// SetWriteDeadline sets the write deadline on the underlying connection.
+// With a WriteTimeout, the deadline caps that of each write.
+func (wsc *connection) SetWriteDeadline(t time.Time) error {
+	wsc.deadlineLock.Lock()
+	wsc.writeDeadline = t
+	wsc.deadlineLock.Unlock()
+
+	return wsc.Conn.SetWriteDeadline(t)
+}
+
+func (wsc *connection) explicitWriteDeadline() time.Time {
+	wsc.deadlineLock.Lock()
+	defer wsc.deadlineLock.Unlock()
+
+	return wsc.writeDeadline
+}
+
 func (wsc *connection) Write(data []byte) (int, error) {
 	if err := wsc.WriteMessage(websocket.BinaryMessage, data); err != nil {
 		return 0, err
@@ -379,3 +649,11 @@ func (wsc *connection) Write(data []byte) (int, error) {
 
 	return len(data), nil
 }
+
+func (wsc *connection) WriteContext(ctx context.Context, data []byte) (int, error) {
+	if err := wsc.writeMessage(ctx, websocket.BinaryMessage, data); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
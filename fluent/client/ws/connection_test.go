@@ -26,11 +26,17 @@ package ws_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/fluent-forward-go/fluent/client/ws"
@@ -40,6 +46,65 @@ import (
 	"github.com/onsi/gomega/gbytes"
 )
 
+type after struct {
+	d  time.Duration
+	ch chan time.Time
+}
+
+// fakeClock hands every timer to the test so that it decides when
+// each one fires.
+type fakeClock struct {
+	afters chan after
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.afters <- after{d: d, ch: ch}
+
+	return ch
+}
+
+// blockingConn stalls writes, once blocked, until its write deadline
+// passes, like a connection whose peer has stopped reading.
+type blockingConn struct {
+	net.Conn
+	blocked  int32
+	lock     sync.Mutex
+	deadline time.Time
+}
+
+func (bc *blockingConn) SetWriteDeadline(t time.Time) error {
+	bc.lock.Lock()
+	bc.deadline = t
+	bc.lock.Unlock()
+
+	return bc.Conn.SetWriteDeadline(t)
+}
+
+func (bc *blockingConn) Write(p []byte) (int, error) {
+	if atomic.LoadInt32(&bc.blocked) == 0 {
+		return bc.Conn.Write(p)
+	}
+
+	timeout := time.Now().Add(time.Minute)
+
+	for {
+		bc.lock.Lock()
+		deadline := bc.deadline
+		bc.lock.Unlock()
+
+		if deadline.IsZero() {
+			deadline = timeout
+		}
+
+		if !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
 type message struct {
 	mt  int
 	msg []byte
@@ -56,6 +121,7 @@ var _ = Describe("Connection", func() {
 		listenErrs                      chan error
 		exitConnState, svrExitConnState ws.ConnState
 		logBuffer                       *gbytes.Buffer
+		svrPingHandler                  func(conn ws.Connection, appData string) error
 	)
 
 	var makeOpts = func(logBuffer *gbytes.Buffer, msgChan chan message, name string) ws.ConnectionOptions {
@@ -88,6 +154,7 @@ var _ = Describe("Connection", func() {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer GinkgoRecover()
 			svrOpts := makeOpts(logBuffer, svrRcvdMsgs, "server")
+			svrOpts.PingHandler = svrPingHandler
 
 			var upgrader websocket.Upgrader
 			wc, _ := upgrader.Upgrade(w, r, nil)
@@ -110,6 +177,7 @@ var _ = Describe("Connection", func() {
 		svrExitConnState = ws.ConnStateCloseReceived | ws.ConnStateCloseSent | ws.ConnStateClosed
 
 		checkSvrClose = true
+		svrPingHandler = nil
 		svrRcvdMsgs = make(chan message, 1)
 		svr = httptest.NewServer(newHandler(logBuffer, svrRcvdMsgs))
 
@@ -265,6 +333,121 @@ var _ = Describe("Connection", func() {
 		})
 	})
 
+	Describe("heartbeat", func() {
+		var (
+			clock *fakeClock
+			pongs chan string
+		)
+
+		nextAfter := func() after {
+			var a after
+			Eventually(clock.afters).Should(Receive(&a))
+			return a
+		}
+
+		BeforeEach(func() {
+			clock = &fakeClock{afters: make(chan after, 10)}
+			pongs = make(chan string, 1)
+
+			opts.PingInterval = time.Minute
+			opts.PongTimeout = time.Second
+			opts.Clock = clock
+			opts.PongHandler = func(conn ws.Connection, appData string) error {
+				pongs <- appData
+				return nil
+			}
+		})
+
+		When("the peer answers", func() {
+			It("keeps pinging", func() {
+				for i := 0; i < 2; i++ {
+					interval := nextAfter()
+					Expect(interval.d).To(Equal(time.Minute))
+					interval.ch <- time.Now()
+
+					Expect(nextAfter().d).To(Equal(time.Second))
+					Eventually(pongs).Should(Receive())
+				}
+
+				Expect(nextAfter().d).To(Equal(time.Minute))
+				Consistently(listenErrs).ShouldNot(Receive())
+			})
+		})
+
+		When("the pong does not arrive in time", func() {
+			BeforeEach(func() {
+				checkClose = false
+				checkSvrClose = false
+				exitConnState = ws.ConnStateClosed | ws.ConnStateError
+				svrExitConnState = exitConnState
+
+				svrPingHandler = func(conn ws.Connection, appData string) error {
+					return nil
+				}
+			})
+
+			It("fails the connection", func() {
+				nextAfter().ch <- time.Now()
+
+				timeout := nextAfter()
+				Expect(timeout.d).To(Equal(time.Second))
+				Consistently(pongs).ShouldNot(Receive())
+				timeout.ch <- time.Now()
+
+				Eventually(listenErrs).Should(Receive(MatchError(ws.ErrPongTimeout)))
+				Eventually(connection.Closed).Should(BeTrue())
+			})
+		})
+	})
+
+	Describe("read timeout", func() {
+		BeforeEach(func() {
+			opts.ReadTimeout = 100 * time.Millisecond
+		})
+
+		When("the peer goes silent", func() {
+			BeforeEach(func() {
+				checkClose = false
+				checkSvrClose = false
+			})
+
+			It("fails the connection", func() {
+				start := time.Now()
+
+				var err error
+				Eventually(listenErrs, time.Second).Should(Receive(&err))
+
+				var netErr net.Error
+				Expect(errors.As(err, &netErr)).To(BeTrue())
+				Expect(netErr.Timeout()).To(BeTrue())
+				Expect(time.Since(start)).To(BeNumerically("~", 100*time.Millisecond, 50*time.Millisecond))
+				Eventually(connection.Closed).Should(BeTrue())
+			})
+		})
+
+		When("messages keep arriving", func() {
+			It("extends the deadline", func() {
+				for i := 0; i < 5; i++ {
+					time.Sleep(50 * time.Millisecond)
+					_, err := svrConnection.Write([]byte("oi"))
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				Consistently(listenErrs, 50*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+
+		When("the heartbeat is enabled", func() {
+			BeforeEach(func() {
+				opts.PingInterval = 30 * time.Millisecond
+			})
+
+			It("extends the deadline on every pong", func() {
+				Consistently(listenErrs, 300*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+
 	Describe("CloseWithMsg", func() {
 		When("everything is copacetic", func() {
 			It("sends a signal", func() {
@@ -330,3 +513,135 @@ var _ = Describe("Connection", func() {
 		})
 	})
 })
+
+var _ = Describe("Connection with a WriteTimeout", func() {
+	var (
+		svr        *httptest.Server
+		netConn    *blockingConn
+		connection ws.Connection
+	)
+
+	BeforeEach(func() {
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+
+			defer wc.Close()
+
+			for {
+				if _, _, err := wc.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+
+		dialer := websocket.Dialer{
+			NetDial: func(network, addr string) (net.Conn, error) {
+				c, err := net.Dial(network, addr)
+				if err != nil {
+					return nil, err
+				}
+
+				netConn = &blockingConn{Conn: c}
+
+				return netConn, nil
+			},
+		}
+
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(svr.URL, "http"), nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		connection, err = ws.NewConnection(conn, ws.ConnectionOptions{
+			WriteTimeout: 50 * time.Millisecond,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = connection.UnderlyingConn().Close()
+		svr.Close()
+	})
+
+	It("writes while the peer keeps up", func() {
+		for i := 0; i < 3; i++ {
+			_, err := connection.Write([]byte("oi"))
+			Expect(err).ToNot(HaveOccurred())
+		}
+	})
+
+	It("fails a write that blocks past the timeout", func() {
+		atomic.StoreInt32(&netConn.blocked, 1)
+
+		start := time.Now()
+		_, err := connection.Write([]byte("oi"))
+
+		var netErr net.Error
+		Expect(errors.As(err, &netErr)).To(BeTrue())
+		Expect(netErr.Timeout()).To(BeTrue())
+		Expect(time.Since(start)).To(BeNumerically("~", 50*time.Millisecond, 30*time.Millisecond))
+	})
+
+	Describe("WriteContext", func() {
+		It("fails a write that blocks past the context's deadline", func() {
+			atomic.StoreInt32(&netConn.blocked, 1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := connection.WriteContext(ctx, []byte("oi"))
+
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 40*time.Millisecond))
+		})
+
+		It("abandons a blocked write when the context is canceled", func() {
+			atomic.StoreInt32(&netConn.blocked, 1)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+
+			start := time.Now()
+			_, err := connection.WriteContext(ctx, []byte("oi"))
+
+			Expect(err).To(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 40*time.Millisecond))
+		})
+
+		It("leaves the deadline of other writes alone", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			_, err := connection.WriteContext(ctx, []byte("oi"))
+			Expect(err).ToNot(HaveOccurred())
+
+			<-ctx.Done()
+			_, err = connection.Write([]byte("oi"))
+			Expect(err).ToNot(HaveOccurred())
+
+			canceled, cancelNow := context.WithCancel(context.Background())
+			cancelNow()
+
+			_, err = connection.WriteContext(canceled, []byte("oi"))
+			Expect(err).To(MatchError(context.Canceled))
+
+			_, err = connection.Write([]byte("oi"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	It("keeps an earlier deadline set by the caller", func() {
+		atomic.StoreInt32(&netConn.blocked, 1)
+		Expect(connection.SetWriteDeadline(time.Now().Add(10 * time.Millisecond))).To(Succeed())
+
+		start := time.Now()
+		_, err := connection.Write([]byte("oi"))
+
+		Expect(err).To(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically("<", 40*time.Millisecond))
+	})
+})
@@ -0,0 +1,83 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ws
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext"
+)
+
+// tcpConn returns the TCP connection under conn, looking through TLS, or
+// nil if there is none, e.g. for a connection over a unix socket.
+func tcpConn(conn ext.Conn) *net.TCPConn {
+	nc := conn.UnderlyingConn()
+	if tc, ok := nc.(*tls.Conn); ok {
+		nc = tc.NetConn()
+	}
+
+	tcp, _ := nc.(*net.TCPConn)
+
+	return tcp
+}
+
+// setSocketOptions applies the socket options in opts to the TCP
+// connection under conn. Connections that are not over TCP are left as
+// they are.
+func setSocketOptions(conn ext.Conn, opts ConnectionOptions) error {
+	tcp := tcpConn(conn)
+	if tcp == nil {
+		return nil
+	}
+
+	if opts.NoDelay {
+		if err := tcp.SetNoDelay(true); err != nil {
+			return err
+		}
+	}
+
+	if opts.TCPKeepAlive > 0 {
+		if err := tcp.SetKeepAlive(true); err != nil {
+			return err
+		}
+
+		if err := tcp.SetKeepAlivePeriod(opts.TCPKeepAlive); err != nil {
+			return err
+		}
+
+		if err := setKeepAliveProbes(tcp, opts.TCPKeepAliveInterval, opts.TCPKeepAliveCount); err != nil {
+			return err
+		}
+	}
+
+	if opts.SocketSendBufferBytes > 0 || opts.SocketRecvBufferBytes > 0 {
+		if err := setBufferSizes(tcp, opts.SocketSendBufferBytes, opts.SocketRecvBufferBytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ws
+
+import (
+	"net"
+	"syscall"
+	"time"
+)
+
+// setKeepAliveProbes sets the interval between, and number of, unanswered
+// keepalive probes sent before the connection is dropped.
+func setKeepAliveProbes(tcp *net.TCPConn, interval time.Duration, count int) error {
+	raw, err := tcp.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var serr error
+
+	err = raw.Control(func(fd uintptr) {
+		if interval > 0 {
+			secs := int((interval + time.Second - 1) / time.Second)
+			if serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL, secs); serr != nil {
+				return
+			}
+		}
+
+		if count > 0 {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT, count)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return serr
+}
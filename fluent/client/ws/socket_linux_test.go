@@ -0,0 +1,55 @@
+//go:build linux
+
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ws_test
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection keepalive probes", func() {
+	It("sets the keepalive idle time, interval and count", func() {
+		svr, tcpConn, conn := dialTCP()
+		defer svr.Close()
+		defer conn.Close()
+
+		_, err := ws.NewConnection(conn, ws.ConnectionOptions{
+			TCPKeepAlive:         45 * time.Second,
+			TCPKeepAliveInterval: 10 * time.Second,
+			TCPKeepAliveCount:    3,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)).To(Equal(45))
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL)).To(Equal(10))
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT)).To(Equal(3))
+	})
+})
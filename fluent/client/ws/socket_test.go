@@ -0,0 +1,159 @@
+//go:build linux || darwin
+
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ws_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// getsockopt reads an integer socket option from conn.
+func getsockopt(conn *net.TCPConn, level, opt int) int {
+	raw, err := conn.SyscallConn()
+	Expect(err).ToNot(HaveOccurred())
+
+	var (
+		value int
+		gerr  error
+	)
+
+	Expect(raw.Control(func(fd uintptr) {
+		value, gerr = syscall.GetsockoptInt(int(fd), level, opt)
+	})).To(Succeed())
+	Expect(gerr).ToNot(HaveOccurred())
+
+	return value
+}
+
+// dialTCP starts a websocket server that reads until the connection
+// closes, and dials it. It returns the TCP connection under the websocket.
+func dialTCP() (*httptest.Server, *net.TCPConn, *websocket.Conn) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+
+		wc, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer wc.Close()
+
+		for {
+			if _, _, err := wc.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	var tcpConn *net.TCPConn
+
+	dialer := websocket.Dialer{
+		NetDial: func(network, addr string) (net.Conn, error) {
+			c, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+
+			tcpConn = c.(*net.TCPConn)
+
+			return c, nil
+		},
+	}
+
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(svr.URL, "http"), nil)
+	Expect(err).ToNot(HaveOccurred())
+
+	return svr, tcpConn, conn
+}
+
+var _ = Describe("Connection socket options", func() {
+	var (
+		svr     *httptest.Server
+		tcpConn *net.TCPConn
+		conn    *websocket.Conn
+	)
+
+	BeforeEach(func() {
+		svr, tcpConn, conn = dialTCP()
+	})
+
+	AfterEach(func() {
+		_ = conn.Close()
+		svr.Close()
+	})
+
+	It("sets TCP_NODELAY when NoDelay is set", func() {
+		Expect(tcpConn.SetNoDelay(false)).To(Succeed())
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)).To(BeZero())
+
+		_, err := ws.NewConnection(conn, ws.ConnectionOptions{NoDelay: true})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)).ToNot(BeZero())
+	})
+
+	It("turns on TCP keepalive when TCPKeepAlive is set", func() {
+		// Go's dialer turns it on by default
+		Expect(tcpConn.SetKeepAlive(false)).To(Succeed())
+		Expect(getsockopt(tcpConn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)).To(BeZero())
+
+		_, err := ws.NewConnection(conn, ws.ConnectionOptions{TCPKeepAlive: 30 * time.Second})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getsockopt(tcpConn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE)).ToNot(BeZero())
+	})
+
+	It("sets the socket buffer sizes", func() {
+		_, err := ws.NewConnection(conn, ws.ConnectionOptions{
+			SocketSendBufferBytes: 256 << 10,
+			SocketRecvBufferBytes: 128 << 10,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		// the kernel may round the sizes up, and Linux doubles them
+		Expect(getsockopt(tcpConn, syscall.SOL_SOCKET, syscall.SO_SNDBUF)).To(BeNumerically(">=", 256<<10))
+		Expect(getsockopt(tcpConn, syscall.SOL_SOCKET, syscall.SO_RCVBUF)).To(BeNumerically(">=", 128<<10))
+	})
+
+	It("leaves TCP_NODELAY alone otherwise", func() {
+		Expect(tcpConn.SetNoDelay(false)).To(Succeed())
+
+		_, err := ws.NewConnection(conn, ws.ConnectionOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(getsockopt(tcpConn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY)).To(BeZero())
+	})
+})
@@ -2,6 +2,7 @@
 package wsfakes
 
 import (
+	"context"
 	"io"
 	"net"
 	"sync"
@@ -261,6 +262,20 @@ type FakeConnection struct {
 		result1 int
 		result2 error
 	}
+	WriteContextStub        func(context.Context, []byte) (int, error)
+	writeContextMutex       sync.RWMutex
+	writeContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 []byte
+	}
+	writeContextReturns struct {
+		result1 int
+		result2 error
+	}
+	writeContextReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
 	WriteControlStub        func(int, []byte, time.Time) error
 	writeControlMutex       sync.RWMutex
 	writeControlArgsForCall []struct {
@@ -1625,6 +1640,76 @@ func (fake *FakeConnection) WriteReturnsOnCall(i int, result1 int, result2 error
 	}{result1, result2}
 }
 
+func (fake *FakeConnection) WriteContext(arg1 context.Context, arg2 []byte) (int, error) {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.writeContextMutex.Lock()
+	ret, specificReturn := fake.writeContextReturnsOnCall[len(fake.writeContextArgsForCall)]
+	fake.writeContextArgsForCall = append(fake.writeContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 []byte
+	}{arg1, arg2Copy})
+	stub := fake.WriteContextStub
+	fakeReturns := fake.writeContextReturns
+	fake.recordInvocation("WriteContext", []interface{}{arg1, arg2Copy})
+	fake.writeContextMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeConnection) WriteContextCallCount() int {
+	fake.writeContextMutex.RLock()
+	defer fake.writeContextMutex.RUnlock()
+	return len(fake.writeContextArgsForCall)
+}
+
+func (fake *FakeConnection) WriteContextCalls(stub func(context.Context, []byte) (int, error)) {
+	fake.writeContextMutex.Lock()
+	defer fake.writeContextMutex.Unlock()
+	fake.WriteContextStub = stub
+}
+
+func (fake *FakeConnection) WriteContextArgsForCall(i int) (context.Context, []byte) {
+	fake.writeContextMutex.RLock()
+	defer fake.writeContextMutex.RUnlock()
+	argsForCall := fake.writeContextArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeConnection) WriteContextReturns(result1 int, result2 error) {
+	fake.writeContextMutex.Lock()
+	defer fake.writeContextMutex.Unlock()
+	fake.WriteContextStub = nil
+	fake.writeContextReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeConnection) WriteContextReturnsOnCall(i int, result1 int, result2 error) {
+	fake.writeContextMutex.Lock()
+	defer fake.writeContextMutex.Unlock()
+	fake.WriteContextStub = nil
+	if fake.writeContextReturnsOnCall == nil {
+		fake.writeContextReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.writeContextReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeConnection) WriteControl(arg1 int, arg2 []byte, arg3 time.Time) error {
 	var arg2Copy []byte
 	if arg2 != nil {
@@ -1876,6 +1961,8 @@ func (fake *FakeConnection) Invocations() map[string][][]interface{} {
 	defer fake.underlyingConnMutex.RUnlock()
 	fake.writeMutex.RLock()
 	defer fake.writeMutex.RUnlock()
+	fake.writeContextMutex.RLock()
+	defer fake.writeContextMutex.RUnlock()
 	fake.writeControlMutex.RLock()
 	defer fake.writeControlMutex.RUnlock()
 	fake.writeMessageMutex.RLock()
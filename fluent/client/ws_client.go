@@ -25,25 +25,35 @@ SOFTWARE.
 package client
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/IBM/fluent-forward-go/fluent/client/ws"
 	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext"
 	"github.com/IBM/fluent-forward-go/fluent/protocol"
 	"github.com/gorilla/websocket"
+	"golang.org/x/time/rate"
 
 	"github.com/tinylib/msgp/msgp"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
 
 const (
 	AuthorizationHeader = "Authorization"
+	DefaultAckTimeout   = 60 * time.Second
 )
 
 // Expose message types as defined in underlying websocket library
@@ -54,72 +64,252 @@ const (
 
 //counterfeiter:generate . WSConnectionFactory
 type WSConnectionFactory interface {
-	New() (ext.Conn, error)
+	New(ctx context.Context) (ext.Conn, error)
 	NewSession(ws.Connection) *WSSession
 }
 
-type IAMAuthInfo struct {
-	token string
-	mutex sync.RWMutex
+// WSSession represents a single websocket connection.
+type WSSession struct {
+	URL        string
+	Connection ws.Connection
+	// Capabilities are those the server advertised when the client's
+	// Negotiate option is set. They withhold the features the server does
+	// not support.
+	Capabilities ServerCapabilities
+	// writes is held for reading by each write to Connection, and for
+	// writing while buffered messages are drained and when HotReconnect
+	// retires the session.
+	writes  sync.RWMutex
+	retired bool
 }
 
-// IAMToken returns the current token value. It is thread safe.
-func (ai *IAMAuthInfo) IAMToken() string {
-	ai.mutex.RLock()
-	defer ai.mutex.RUnlock()
+func (s *WSSession) isRetired() bool {
+	s.writes.RLock()
+	defer s.writes.RUnlock()
 
-	return ai.token
+	return s.retired
+}
+
+// DefaultWSConnectionFactory is used by the client if no other
+// ConnectionFactory is provided.
+type DefaultWSConnectionFactory struct {
+	URL string
+	// Auth, if not nil, supplies the authentication headers for each dial.
+	// If nil, AuthInfo is used as an IAMBearerAuth; if both are nil, the
+	// connection is unauthenticated.
+	Auth     AuthProvider
+	AuthInfo *IAMAuthInfo
+	// TLSConfig, if not nil, is used for the TLS handshake instead of the
+	// defaults. It may only be set when URL uses the "wss" scheme. Its
+	// GetClientCertificate may be that of a RotatingCertStore.
+	TLSConfig *tls.Config
+	// Header holds additional headers, e.g. for tracing or routing, that
+	// are sent with the upgrade request of every dial. Headers from the
+	// AuthProvider replace those with the same name unless
+	// HeaderOverridesAuth is set.
+	Header http.Header
+	// HeaderOverridesAuth gives the values in Header precedence over the
+	// headers supplied by the AuthProvider.
+	HeaderOverridesAuth bool
+	// NetDialer, if not nil, opens the TCP connection, e.g. to set
+	// KeepAlive, Timeout, LocalAddr or a custom Resolver.
+	NetDialer *net.Dialer
+	// ProxyURL, if not nil, is the proxy the connection is tunneled
+	// through. The "http" and "https" schemes use HTTP CONNECT and
+	// "socks5" uses SOCKS5. Credentials in the URL authenticate with the
+	// proxy.
+	ProxyURL *url.URL
+	// ProxyDialer, if not nil, opens the network connections in place of
+	// NetDialer, e.g. one returned by SOCKS5ProxyDialer. The context of the
+	// dial is not passed to it, but a connection it returns is still
+	// subject to the context during the handshake.
+	ProxyDialer ProxyDialFunc
+	// clientCert is presented to the server during the TLS handshake. Set
+	// with NewMTLSConnectionFactory or ReloadCertificate.
+	clientCert *tls.Certificate
+	// pins are the certificate fingerprints set with PinCertificateSHA256.
+	pins     map[string]struct{}
+	certLock sync.RWMutex
 }
 
-// SetIAMToken updates the token returned by IAMToken(). It is thread safe.
-func (ai *IAMAuthInfo) SetIAMToken(token string) {
-	ai.mutex.Lock()
-	defer ai.mutex.Unlock()
+// NewMTLSConnectionFactory returns a factory that authenticates with the
+// client certificate cert and verifies the server's certificate against
+// cas. If cas is nil, the host's root CA set is used.
+func NewMTLSConnectionFactory(rawURL string, cert tls.Certificate, cas *x509.CertPool) *DefaultWSConnectionFactory {
+	wcf := &DefaultWSConnectionFactory{
+		URL: rawURL,
+		TLSConfig: &tls.Config{
+			RootCAs:    cas,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
 
-	ai.token = token
+	wcf.ReloadCertificate(cert)
+
+	return wcf
 }
 
-func NewIAMAuthInfo(token string) *IAMAuthInfo {
-	return &IAMAuthInfo{token: token}
+// ReloadCertificate replaces the client certificate presented to the
+// server. Existing connections are unaffected; the new certificate is used
+// from the next call to New onward. It is thread safe.
+func (wcf *DefaultWSConnectionFactory) ReloadCertificate(cert tls.Certificate) {
+	wcf.certLock.Lock()
+	defer wcf.certLock.Unlock()
+
+	wcf.clientCert = &cert
 }
 
-// WSSession represents a single websocket connection.
-type WSSession struct {
-	URL        string
-	Connection ws.Connection
+// ClientCertificate returns the current client certificate, or nil if
+// none is set. It is thread safe.
+func (wcf *DefaultWSConnectionFactory) ClientCertificate() *tls.Certificate {
+	wcf.certLock.RLock()
+	defer wcf.certLock.RUnlock()
+
+	return wcf.clientCert
 }
 
-// DefaultWSConnectionFactory is used by the client if no other
-// ConnectionFactory is provided.
-type DefaultWSConnectionFactory struct {
-	URL       string
-	AuthInfo  *IAMAuthInfo
-	TLSConfig *tls.Config
-	Header    http.Header
+// tlsConfig returns the TLS configuration for a single dial, or nil if
+// the defaults should be used.
+func (wcf *DefaultWSConnectionFactory) tlsConfig() *tls.Config {
+	cfg := wcf.TLSConfig
+	if pins := wcf.pinnedFingerprints(); pins != nil {
+		cfg = withPins(cfg, pins)
+	}
+
+	cert := wcf.ClientCertificate()
+	if cert == nil {
+		return cfg
+	}
+
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		cfg = cfg.Clone()
+	}
+
+	cfg.Certificates = []tls.Certificate{*cert}
+
+	return cfg
+}
+
+func (wcf *DefaultWSConnectionFactory) authProvider() AuthProvider {
+	if wcf.Auth != nil {
+		return wcf.Auth
+	}
+
+	if wcf.AuthInfo != nil {
+		return &IAMBearerAuth{AuthInfo: wcf.AuthInfo}
+	}
+
+	return nil
 }
 
-func (wcf *DefaultWSConnectionFactory) New() (ext.Conn, error) {
+// New dials the configured URL. The context bounds the whole dial,
+// including the websocket handshake; if it is canceled or expires first,
+// the returned error wraps the context's error.
+func (wcf *DefaultWSConnectionFactory) New(ctx context.Context) (ext.Conn, error) {
 	var (
 		dialer websocket.Dialer
 		header = http.Header{}
 	)
 
+	tlsConfig := wcf.tlsConfig()
+	if tlsConfig != nil {
+		u, err := url.Parse(wcf.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.Scheme != "wss" {
+			return nil, Permanent(fmt.Errorf("TLSConfig requires a wss:// URL, got scheme %q", u.Scheme))
+		}
+
+		dialer.TLSClientConfig = tlsConfig
+	}
+
 	// set additional custom headers. here we do not validate
 	// header names and values. Caller should make sure the
 	// headers provided are not conflict with protocols
 	if wcf.Header != nil {
-		header = wcf.Header
+		header = wcf.Header.Clone()
+	}
+
+	if auth := wcf.authProvider(); auth != nil {
+		authHeader, err := auth.Headers(withDialURL(ctx, wcf.URL))
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range authHeader {
+			if _, ok := header[k]; ok && wcf.HeaderOverridesAuth {
+				continue
+			}
+
+			header[k] = v
+		}
+	}
+
+	// gorilla only honors the context during the TCP dial, so abort a
+	// stalled handshake by expiring the deadline on the raw connection.
+	var (
+		dialDone = make(chan struct{})
+		watchers sync.WaitGroup
+	)
+
+	nd := wcf.NetDialer
+	if nd == nil {
+		nd = &net.Dialer{}
+	}
+
+	dial := dialContextFunc(nd.DialContext)
+
+	if wcf.ProxyDialer != nil {
+		dial = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return wcf.ProxyDialer(network, addr)
+		}
 	}
 
-	if wcf.AuthInfo != nil && len(wcf.AuthInfo.IAMToken()) > 0 {
-		header.Set(AuthorizationHeader, wcf.AuthInfo.IAMToken())
+	if wcf.ProxyURL != nil {
+		if err := validateProxyURL(wcf.ProxyURL); err != nil {
+			return nil, err
+		}
+
+		if wcf.ProxyURL.Scheme == "socks5" {
+			dialer.Proxy = http.ProxyURL(wcf.ProxyURL)
+		} else {
+			base := dial
+			dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialProxy(ctx, base, wcf.ProxyURL, addr)
+			}
+		}
 	}
 
-	if wcf.TLSConfig != nil {
-		dialer.TLSClientConfig = wcf.TLSConfig
+	dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		netConn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		watchers.Add(1)
+
+		go func() {
+			defer watchers.Done()
+
+			select {
+			case <-ctx.Done():
+				_ = netConn.SetDeadline(time.Now())
+			case <-dialDone:
+			}
+		}()
+
+		return netConn, nil
 	}
 
-	conn, resp, err := dialer.Dial(wcf.URL, header)
+	conn, resp, err := dialer.DialContext(ctx, wcf.URL, header)
+
+	close(dialDone)
+	watchers.Wait()
+
 	if resp != nil && resp.Body != nil {
 		defer resp.Body.Close()
 
@@ -133,6 +323,16 @@ func (wcf *DefaultWSConnectionFactory) New() (ext.Conn, error) {
 		}
 	}
 
+	// the watcher may have expired the deadline on an otherwise healthy
+	// connection, so it cannot be handed back once the context is done
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if conn != nil {
+			_ = conn.Close()
+		}
+
+		return nil, fmt.Errorf("dial %s: %w", wcf.URL, ctxErr)
+	}
+
 	return conn, err
 }
 
@@ -145,17 +345,211 @@ func (wcf *DefaultWSConnectionFactory) NewSession(connection ws.Connection) *WSS
 
 type WSConnectionOptions struct {
 	ws.ConnectionOptions
-	Factory WSConnectionFactory
+	Factory          WSConnectionFactory
+	RetryPolicy      RetryPolicy
+	AckMode          bool
+	AckTimeout       time.Duration
+	AckRetryCount    int
+	AckRetryBackoff  time.Duration
+	CorrelationIDs   CorrelationIDGenerator
+	Sequence         bool
+	Negotiate        bool
+	HandshakeTimeout time.Duration
+	OnUnacked        UnackedHandler
+	Metrics          MetricsCollector
+	Breaker          *CircuitBreaker
+	RateLimit        *rate.Limiter
+	MaxMessageBytes  int64
+	Codec            Codec
+	Buffer           BufferOptions
+	AutoReconnect    bool
+	OnConnect        func()
+	OnDisconnect     func(err error)
+	OnError          func(err error)
+	RestartOnPanic   bool
+	MaxQueueMessages int
+	MaxQueueBytes    int64
+	OnAsyncError     AsyncErrorHandler
+	WriterTag        string
+	TagTransformer   func(tag string) string
+	TagPrefix        string
+	OTelPropagator   propagation.TextMapPropagator
 }
 
+// UnackedHandler is called with a message sent by SendMessageAck that
+// was not acknowledged, e.g. so that it can be queued for another attempt.
+type UnackedHandler func(chunk string, msg protocol.ChunkEncoder)
+
 // WSClient manages the lifetime of a single websocket connection.
 type WSClient struct {
 	ConnectionFactory WSConnectionFactory
 	ConnectionOptions ws.ConnectionOptions
-	session           *WSSession
-	errLock           sync.RWMutex
-	sessionLock       sync.RWMutex
-	err               error
+	// RetryPolicy is used by ReconnectWithRetry. If nil, a single
+	// attempt is made.
+	RetryPolicy RetryPolicy
+	// AckMode enables reading the acknowledgements the peer sends in reply
+	// to messages that carry a "chunk" option. It must be set before
+	// connecting and is required by SendMessageAck.
+	AckMode bool
+	// AckTimeout is how long SendMessageAck waits for an acknowledgement
+	// once the message has been written. It is independent of the write
+	// timeout in ConnectionOptions, which bounds only the write: Fluentd
+	// acks a chunk once it is buffered, which may be long after it was
+	// received. An AckTimeout shorter than the flush interval of Fluentd's
+	// buffer causes spurious resends of messages that were delivered. If
+	// zero, DefaultAckTimeout is used.
+	AckTimeout time.Duration
+	// AckRetryCount is how many times SendMessageAck resends a message
+	// that was not acknowledged within AckTimeout before giving up on it.
+	// A message that was buffered is resent as it was written once drained;
+	// one still buffered is waited for again instead. If zero, it is not
+	// resent.
+	AckRetryCount int
+	// AckRetryBackoff is how long SendMessageAck waits after an ack
+	// timeout before resending.
+	AckRetryBackoff time.Duration
+	// CorrelationIDs makes the chunk IDs that SendMessageAck gives messages
+	// without one, e.g. a SequentialChunkIDGenerator for debugging. If nil,
+	// UUIDGenerator is used.
+	CorrelationIDs CorrelationIDGenerator
+	// Sequence, if true, makes SendMessageContext number the messages of
+	// each tag with the "seq" option, from 1. Messages that already have a
+	// seq, e.g. because they are being retried, keep it.
+	Sequence bool
+	// Negotiate, if true, makes Connect run the Handshake on each new
+	// connection and keep the server's capabilities in the session. While
+	// the server's capabilities are known, messages compressed with an
+	// algorithm it does not accept are sent uncompressed, and
+	// SendMessageAck fails with ErrAckUnsupported if it does not
+	// acknowledge chunks.
+	Negotiate bool
+	// HandshakeTimeout is how long the Handshake waits for the server's
+	// reply. If zero, DefaultHandshakeTimeout is used.
+	HandshakeTimeout time.Duration
+	// OnUnacked, if not nil, is called by SendMessageAck when a message
+	// is not acknowledged, after any retries, e.g. to put it on a dead
+	// letter queue.
+	OnUnacked UnackedHandler
+	// Metrics receives send and reconnect measurements. NewWS sets it to
+	// a no-op collector if none is provided.
+	Metrics MetricsCollector
+	// Breaker, if not nil, guards Send, SendMessageContext and SendRaw.
+	// While it is open, they return ErrCircuitOpen without writing.
+	Breaker *CircuitBreaker
+	// MaxMessageBytes, if greater than zero, is the largest encoded message
+	// that may be sent. Larger messages are rejected with
+	// ErrMessageTooLarge before anything is written.
+	MaxMessageBytes int64
+	// Codec encodes the messages sent by Send, SendMessageContext and the
+	// methods built on them. If nil, MsgpackCodec is used. SendRaw writes
+	// its bytes as they are.
+	Codec Codec
+	// RateLimit, if not nil, limits how often Send, SendMessageContext and
+	// SendRaw write. They wait for the limiter before each message.
+	RateLimit *rate.Limiter
+	// Buffer configures the queuing of messages sent by Send and
+	// SendMessageContext while there is no active session. Buffered
+	// messages are written, in order, once a connection is established.
+	Buffer BufferOptions
+	// AutoReconnect, if true, makes the client call ReconnectWithRetry in
+	// the background when Listen returns an error, e.g. ws.ErrPongTimeout
+	// from a missed heartbeat. The RetryPolicy bounds the attempts.
+	AutoReconnect bool
+	// OnConnect, if not nil, is called after a connection is established
+	// by Connect or Reconnect. Like OnDisconnect and OnError, it is called
+	// synchronously and without any of the client's locks held.
+	OnConnect func()
+	// OnDisconnect, if not nil, is called after Disconnect or Reconnect
+	// closes a connection, with the error, if any, from closing it.
+	// Reconnect calls it too, so calling Reconnect from OnDisconnect
+	// recurses without end; start a goroutine to reconnect instead.
+	OnDisconnect func(err error)
+	// OnError, if not nil, is called with the error that ended Listen on
+	// the current connection, before any AutoReconnect.
+	OnError func(err error)
+	// RestartOnPanic decides what happens when a ReadHandler, including a
+	// MessageHandler passed to ListenWith, panics. The panic is recovered,
+	// logged and counted in PanicCount either way. If RestartOnPanic is
+	// true, the message is dropped and reading continues; otherwise the
+	// connection is closed and Listen returns ErrPanic.
+	RestartOnPanic bool
+	// MaxQueueMessages is the number of messages SendMessageAsync can
+	// queue. If zero, DefaultMaxQueueMessages is used. It must be set before
+	// the first call to SendMessageAsync.
+	MaxQueueMessages int
+	// MaxQueueBytes, if greater than zero, limits the estimated encoded
+	// size of the messages SendMessageAsync can queue.
+	MaxQueueBytes int64
+	// OnAsyncError, if not nil, is called with each message queued by
+	// SendMessageAsync that could not be sent.
+	OnAsyncError AsyncErrorHandler
+	// WriterTag is the tag of the events written with Write. If empty,
+	// DefaultWriterTag is used.
+	WriterTag string
+	// TagTransformer, if not nil, rewrites the tag of every message sent,
+	// e.g. to canonicalize it with LowercaseTag, TruncateTag or
+	// ReplaceTagChars, combined by ChainTagTransformers. Like TagPrefix, it
+	// is applied after the Middleware, just before encoding, and the
+	// message itself is not changed.
+	TagTransformer func(tag string) string
+	// TagPrefix, if not empty, is prepended to the tag of every message
+	// sent, e.g. "payments." to keep the messages of an application's
+	// components apart. Dots in the prefix add levels to the tag, which
+	// Fluentd's <match> patterns route by, so "payments." turns "db.query"
+	// into "payments.db.query". It is applied after the Middleware, which
+	// see the tag unprefixed, and the TagTransformer, just before encoding;
+	// the message itself is not changed. Messages sent with SendRaw or as a
+	// MessageEncoder are not prefixed. An empty TagPrefix disables it.
+	TagPrefix string
+	// OTelPropagator, if set, injects the trace context of each send's
+	// context into the options of the message, e.g. as the W3C traceparent
+	// and tracestate with propagation.TraceContext, just before it is
+	// encoded, so that a span started by the Middleware is the one
+	// propagated. The message is modified in place, like Chunk does.
+	// Messages of types without options, and those sent with SendRaw, are
+	// sent unchanged.
+	OTelPropagator propagation.TextMapPropagator
+	// Logger receives diagnostics about connections, reconnects, failed
+	// sends and dropped messages. If nil, nothing is logged. It may be set
+	// after NewWS, but not after Connect.
+	Logger        Logger
+	asyncLock     sync.Mutex
+	asyncQueue    chan queuedMessage
+	asyncPending  int
+	asyncBytes    int64
+	asyncIdle     chan struct{}
+	asyncStopped  chan struct{}
+	listenerLock  sync.Mutex
+	listener      *listener
+	chainLock     sync.RWMutex
+	middleware    []Middleware
+	chain         SendFunc
+	inflight      sync.WaitGroup
+	inflightLock  sync.Mutex
+	shuttingDown  bool
+	bufferLock    sync.Mutex
+	buffer        chan msgp.Encodable
+	spill         *spill
+	session       atomic.Value // *WSSession
+	errLock       sync.RWMutex
+	sessionLock   sync.RWMutex
+	reconnectLock sync.Mutex
+	reconnecting  *reconnectCall
+	pendingAcks   sync.Map // chunk -> *pendingAck
+	seqLock       sync.Mutex
+	seqs          map[string]uint64
+	err           error
+	panics        int64
+	reloadLock    sync.Mutex
+	config        *WSClientConfig
+	reloaded      atomic.Value // *reloadedSettings
+}
+
+// reconnectCall tracks a ReconnectWithRetry in progress so that
+// concurrent callers can share its result.
+type reconnectCall struct {
+	done chan struct{}
+	err  error
 }
 
 func NewWS(opts WSConnectionOptions) *WSClient {
@@ -165,9 +559,69 @@ func NewWS(opts WSConnectionOptions) *WSClient {
 		}
 	}
 
+	if opts.Metrics == nil {
+		opts.Metrics = noopMetrics{}
+	}
+
 	return &WSClient{
 		ConnectionOptions: opts.ConnectionOptions,
 		ConnectionFactory: opts.Factory,
+		RetryPolicy:       opts.RetryPolicy,
+		AckMode:           opts.AckMode,
+		AckTimeout:        opts.AckTimeout,
+		AckRetryCount:     opts.AckRetryCount,
+		AckRetryBackoff:   opts.AckRetryBackoff,
+		CorrelationIDs:    opts.CorrelationIDs,
+		Sequence:          opts.Sequence,
+		Negotiate:         opts.Negotiate,
+		HandshakeTimeout:  opts.HandshakeTimeout,
+		OnUnacked:         opts.OnUnacked,
+		Metrics:           opts.Metrics,
+		Breaker:           opts.Breaker,
+		RateLimit:         opts.RateLimit,
+		MaxMessageBytes:   opts.MaxMessageBytes,
+		Codec:             opts.Codec,
+		Buffer:            opts.Buffer,
+		AutoReconnect:     opts.AutoReconnect,
+		OnConnect:         opts.OnConnect,
+		OnDisconnect:      opts.OnDisconnect,
+		OnError:           opts.OnError,
+		RestartOnPanic:    opts.RestartOnPanic,
+		MaxQueueMessages:  opts.MaxQueueMessages,
+		MaxQueueBytes:     opts.MaxQueueBytes,
+		OnAsyncError:      opts.OnAsyncError,
+		WriterTag:         opts.WriterTag,
+		TagTransformer:    opts.TagTransformer,
+		TagPrefix:         opts.TagPrefix,
+		OTelPropagator:    opts.OTelPropagator,
+	}
+}
+
+func (c *WSClient) notifyConnect() {
+	c.logger().Infof("connected")
+
+	if c.OnConnect != nil {
+		c.OnConnect()
+	}
+}
+
+func (c *WSClient) notifyDisconnect(err error) {
+	if err != nil {
+		c.logger().Warnf("disconnected: %v", err)
+	} else {
+		c.logger().Infof("disconnected")
+	}
+
+	if c.OnDisconnect != nil {
+		c.OnDisconnect(err)
+	}
+}
+
+func (c *WSClient) notifyError(err error) {
+	c.logger().Errorf("connection failed: %v", err)
+
+	if c.OnError != nil {
+		c.OnError(err)
 	}
 }
 
@@ -185,30 +639,126 @@ func (c *WSClient) getErr() error {
 	return c.err
 }
 
-// Session provides the web socket session instance
+func (c *WSClient) logger() Logger {
+	if s := c.reloadedSettings(); s != nil && s.logger != nil {
+		return s.logger
+	}
+
+	if c.Logger == nil {
+		return NoopLogger{}
+	}
+
+	return c.Logger
+}
+
+func (c *WSClient) metrics() MetricsCollector {
+	if s := c.reloadedSettings(); s != nil && s.metrics != nil {
+		return s.metrics
+	}
+
+	if c.Metrics == nil {
+		return noopMetrics{}
+	}
+
+	return c.Metrics
+}
+
+// Session provides the web socket session instance. It does not lock, so
+// that sends need not wait for one another.
 func (c *WSClient) Session() *WSSession {
-	c.sessionLock.RLock()
-	defer c.sessionLock.RUnlock()
+	session, _ := c.session.Load().(*WSSession)
+	return session
+}
 
-	return c.session
+// setSession must be called within the scope of an acquired
+// 'c.sessionLock.Lock()'.
+func (c *WSClient) setSession(session *WSSession) {
+	c.session.Store(session)
+}
+
+// writeSession returns the current session, held so that it is not
+// retired by HotReconnect until its writes.RUnlock is called, or nil if
+// there is none. The caller must still check whether its connection is
+// closed.
+func (c *WSClient) writeSession() *WSSession {
+	for {
+		session := c.Session()
+		if session == nil {
+			return nil
+		}
+
+		session.writes.RLock()
+
+		// a retired session has already been replaced
+		if !session.retired {
+			return session
+		}
+
+		session.writes.RUnlock()
+	}
 }
 
 // connect is for internal use and should be called within
 // the scope of an acquired 'c.sessionLock.Lock()'
 //
 // extracted for internal re-use.
-func (c *WSClient) connect() error {
-	conn, err := c.ConnectionFactory.New()
+func (c *WSClient) connect(ctx context.Context) error {
+	conn, err := c.ConnectionFactory.New(ctx)
 	if err != nil {
 		return err
 	}
 
-	connection, err := ws.NewConnection(conn, c.ConnectionOptions)
+	return c.startSession(ctx, conn)
+}
+
+// startSession makes conn the connection of a new current session, drains
+// the buffer to it and starts reading from it. It must be called within
+// the scope of an acquired 'c.sessionLock.Lock()'.
+func (c *WSClient) startSession(ctx context.Context, conn ext.Conn) error {
+	var (
+		caps ServerCapabilities
+		err  error
+	)
+
+	if c.Negotiate {
+		if conn, caps, err = c.negotiate(ctx, conn); err != nil {
+			return err
+		}
+	}
+
+	opts := c.ConnectionOptions
+	next := opts.ReadHandler
+	opts.ReadHandler = c.listenReadHandler(next)
+
+	if c.AckMode {
+		opts.ReadHandler = c.ackReadHandler(opts.ReadHandler, next)
+	}
+
+	opts.ReadHandler = c.recoverReadHandler(opts.ReadHandler)
+
+	connection, err := ws.NewConnection(conn, opts)
 	if err != nil {
+		_ = conn.Close()
 		return err
 	}
 
-	c.session = c.ConnectionFactory.NewSession(connection)
+	c.buildChain()
+
+	session := c.ConnectionFactory.NewSession(connection)
+	session.Capabilities = caps
+
+	// sends to the new session wait until the buffer has been drained
+	session.writes.Lock()
+	c.setSession(session)
+	err = c.drain(ctx, session)
+	session.writes.Unlock()
+
+	if err != nil {
+		_ = session.Connection.Close()
+		c.setSession(nil)
+
+		return err
+	}
 
 	go func() {
 		// There is a race condition where session is set to nil before
@@ -226,54 +776,268 @@ func (c *WSClient) connect() error {
 		// sufficient for most cases where the client cares only about sending.
 		// If the client really cares about handling reads, they will define a
 		// custom ReadHandler that will receive the error synchronously.
-		if err := c.session.Connection.Listen(); err != nil {
+		// a session retired by HotReconnect was closed on purpose
+		if err := session.Connection.Listen(); err != nil && !session.isRetired() {
 			c.setErr(err)
+			c.notifyError(err)
+
+			// reconnect only if the session was not replaced or ended by
+			// the caller in the meantime
+			if c.AutoReconnect && c.Session() == session {
+				_ = c.ReconnectWithRetry(context.Background())
+			}
 		}
 	}()
 
 	return nil
 }
 
+// watchWriteContext applies the context deadline to the connection's
+// writes and, should the context be canceled first, expires the deadline
+// so that a blocked write returns. The returned func stops the watch and
+// reports whether the write deadline was modified. Contexts that can never
+// be done are ignored.
+func watchWriteContext(ctx context.Context, conn ws.Connection) (func() bool, error) {
+	if ctx.Done() == nil {
+		return func() bool { return false }, nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		select {
+		case <-ctx.Done():
+			_ = conn.SetWriteDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		<-finished
+
+		return true
+	}, nil
+}
+
+// closeContext closes the connection, abandoning the close message
+// write if ctx is done first.
+func closeContext(ctx context.Context, conn ws.Connection) error {
+	stop, err := watchWriteContext(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	defer stop()
+
+	return conn.Close()
+}
+
 // Connect initializes the Session and Connection objects by opening
 // a websocket connection. If AuthInfo is not nil, the token it returns
 // will be passed via the "Authentication" header during the initial
 // HTTP call.
 func (c *WSClient) Connect() error {
+	return c.ConnectContext(context.Background())
+}
+
+// ConnectContext is like Connect, but the context bounds the dial. If the
+// context is canceled or expires before the connection is established, the
+// returned error wraps the context's error.
+func (c *WSClient) ConnectContext(ctx context.Context) error {
+	if err := c.connectSession(ctx); err != nil {
+		return err
+	}
+
+	c.inflightLock.Lock()
+	c.shuttingDown = false
+	c.inflightLock.Unlock()
+
+	c.notifyConnect()
+
+	return nil
+}
+
+func (c *WSClient) connectSession(ctx context.Context) error {
 	c.sessionLock.Lock()
 	defer c.sessionLock.Unlock()
 
-	if c.session != nil {
+	if c.Session() != nil {
 		return errors.New("a session is already active")
 	}
 
-	return c.connect()
+	return c.connect(ctx)
 }
 
 // Disconnect ends the current Session and terminates its websocket connection.
-func (c *WSClient) Disconnect() (err error) {
+// It does not wait for sends in progress, whose writes may be cut short;
+// use GracefulDisconnect to let them finish. The SendMessageAsync writer is
+// stopped, and the messages still queued fail, or are buffered. Messages
+// spilled to disk by BufferSpillToDisk are discarded, with their files.
+func (c *WSClient) Disconnect() error {
+	return c.DisconnectContext(context.Background())
+}
+
+// DisconnectContext is like Disconnect, but a write of the close message
+// that is still blocked when the context is done is abandoned.
+func (c *WSClient) DisconnectContext(ctx context.Context) error {
+	c.stopAsyncWriter()
+	defer c.closeSpill()
+
+	ended, err := c.disconnectSession(ctx)
+	if ended {
+		c.notifyDisconnect(err)
+	}
+
+	return err
+}
+
+// disconnectSession ends the session and reports whether there was one.
+func (c *WSClient) disconnectSession(ctx context.Context) (ended bool, err error) {
 	c.sessionLock.Lock()
 	defer c.sessionLock.Unlock()
 
-	if c.session != nil && !c.session.Connection.Closed() {
-		err = c.session.Connection.Close()
+	session := c.Session()
+	ended = session != nil
+
+	if session != nil && !session.Connection.Closed() {
+		err = closeContext(ctx, session.Connection)
 	}
 
-	c.session = nil
+	c.setSession(nil)
 
 	return
 }
 
+// GracefulDisconnect flushes the messages queued by SendMessageAsync,
+// stops accepting sends, which then return ErrShuttingDown, waits for the
+// sends in progress to finish, and ends the current Session. If the
+// context is done first, the connection is closed regardless and the
+// context's error is returned. Sends are accepted again after the next
+// successful Connect.
+func (c *WSClient) GracefulDisconnect(ctx context.Context) error {
+	if err := c.awaitSends(ctx); err != nil {
+		return fmt.Errorf("graceful disconnect: %w", err)
+	}
+
+	return c.DisconnectContext(ctx)
+}
+
+// DrainAndDisconnect is like GracefulDisconnect, but waits at most timeout
+// for the queued messages and the sends in progress. If the timeout elapses, the connection is
+// closed regardless and ErrDrainTimeout is returned. An error closing the
+// connection once the sends have finished is returned as it is.
+func (c *WSClient) DrainAndDisconnect(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := c.awaitSends(ctx); err != nil {
+		return ErrDrainTimeout
+	}
+
+	return c.DisconnectContext(ctx)
+}
+
+// awaitSends flushes the SendMessageAsync queue, stops accepting sends and
+// waits for those in progress to finish. If the context is done first, it
+// closes the connection and returns the context's error.
+func (c *WSClient) awaitSends(ctx context.Context) error {
+	// queued messages are flushed first, as they would be refused once
+	// shutting down
+	if err := c.Flush(ctx); err != nil {
+		_ = c.Disconnect()
+		return ctx.Err()
+	}
+
+	c.inflightLock.Lock()
+	c.shuttingDown = true
+	c.inflightLock.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		c.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		_ = c.Disconnect()
+		return ctx.Err()
+	}
+}
+
+// beginSend registers a send with GracefulDisconnect. If it returns nil,
+// endSend must be called once the send is done.
+func (c *WSClient) beginSend() error {
+	c.inflightLock.Lock()
+	defer c.inflightLock.Unlock()
+
+	if c.shuttingDown {
+		return ErrShuttingDown
+	}
+
+	c.inflight.Add(1)
+
+	return nil
+}
+
+func (c *WSClient) endSend() {
+	c.inflight.Done()
+}
+
 // Reconnect terminates the existing Session and creates a new one.
-func (c *WSClient) Reconnect() (err error) {
+func (c *WSClient) Reconnect() error {
+	return c.ReconnectContext(context.Background())
+}
+
+// ReconnectContext is like Reconnect, but the context bounds both closing
+// the existing connection and dialing the new one.
+func (c *WSClient) ReconnectContext(ctx context.Context) error {
+	err := c.reconnect(ctx)
+	c.metrics().RecordReconnect(1, err)
+
+	return err
+}
+
+func (c *WSClient) reconnect(ctx context.Context) error {
+	closed, closeErr, err := c.reconnectSession(ctx)
+
+	if closed {
+		c.notifyDisconnect(closeErr)
+	}
+
+	if err == nil {
+		c.notifyConnect()
+	}
+
+	return err
+}
+
+// reconnectSession replaces the session and reports whether an open
+// connection was closed to do so.
+func (c *WSClient) reconnectSession(ctx context.Context) (closed bool, closeErr, err error) {
 	c.sessionLock.Lock()
 	defer c.sessionLock.Unlock()
 
-	if c.session != nil && !c.session.Connection.Closed() {
-		_ = c.session.Connection.Close()
+	if session := c.Session(); session != nil && !session.Connection.Closed() {
+		closed = true
+		closeErr = closeContext(ctx, session.Connection)
 	}
 
-	if err = c.connect(); err != nil {
-		c.session = nil
+	if err = c.connect(ctx); err != nil {
+		c.setSession(nil)
 	}
 
 	c.setErr(err)
@@ -281,12 +1045,228 @@ func (c *WSClient) Reconnect() (err error) {
 	return
 }
 
+// HotReconnect replaces the connection without the window in which
+// Reconnect fails sends: it dials the new connection while sends carry on
+// over the old one, switches sends over to the new one, and only then
+// closes the old one, once the writes in progress on it have finished and,
+// with AckMode, the acknowledgements awaited for them have arrived or
+// timed out. If the dial fails, the old connection is kept and the error
+// returned. If there is no session, HotReconnect connects.
+//
+// The context bounds the dial, and the wait for the old connection.
+func (c *WSClient) HotReconnect(ctx context.Context) error {
+	closed, closeErr, err := c.hotReconnectSession(ctx)
+
+	if closed {
+		c.notifyDisconnect(closeErr)
+	}
+
+	if err == nil {
+		c.notifyConnect()
+	}
+
+	c.metrics().RecordReconnect(1, err)
+
+	return err
+}
+
+func (c *WSClient) hotReconnectSession(ctx context.Context) (closed bool, closeErr, err error) {
+	c.sessionLock.Lock()
+	defer c.sessionLock.Unlock()
+
+	old := c.Session()
+
+	conn, err := c.ConnectionFactory.New(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if err = c.startSession(ctx, conn); err != nil {
+		// sends carry on over the old connection, whose error still stands
+		_ = conn.Close()
+		c.setSession(old)
+
+		return false, nil, err
+	}
+
+	// the error of the old connection is no longer relevant
+	c.setErr(nil)
+
+	// a factory may hand back the same session for every connection
+	if old == nil || old == c.Session() {
+		return false, nil, nil
+	}
+
+	// wait for the writes to the old connection, and have any that
+	// arrive later retry on the new one
+	old.writes.Lock()
+	old.retired = true
+	old.writes.Unlock()
+
+	if c.AckMode {
+		c.awaitPendingAcks(ctx)
+	}
+
+	if old.Connection.Closed() {
+		return false, nil, nil
+	}
+
+	return true, closeContext(ctx, old.Connection), nil
+}
+
+// awaitPendingAcks waits until the acknowledgements awaited now have
+// arrived or been given up on, or ctx is done.
+func (c *WSClient) awaitPendingAcks(ctx context.Context) {
+	var chunks []interface{}
+
+	c.pendingAcks.Range(func(chunk, _ interface{}) bool {
+		chunks = append(chunks, chunk)
+		return true
+	})
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for len(chunks) > 0 {
+		if _, ok := c.pendingAcks.Load(chunks[0]); !ok {
+			chunks = chunks[1:]
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReconnectWithRetry calls ReconnectContext until it succeeds, the
+// RetryPolicy runs out of attempts, or the context is done, waiting
+// between attempts for the delay given by the policy. Permanent errors
+// (see IsPermanent), such as an authorization failure, end the retries at
+// once.
+//
+// Only one reconnect is in flight at a time: a caller that arrives while
+// one is in progress waits for it and receives its result.
+func (c *WSClient) ReconnectWithRetry(ctx context.Context) error {
+	c.reconnectLock.Lock()
+
+	if call := c.reconnecting; call != nil {
+		c.reconnectLock.Unlock()
+
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	call := &reconnectCall{done: make(chan struct{})}
+	c.reconnecting = call
+	c.reconnectLock.Unlock()
+
+	call.err = c.reconnectWithRetry(ctx)
+
+	c.reconnectLock.Lock()
+	c.reconnecting = nil
+	c.reconnectLock.Unlock()
+	close(call.done)
+
+	return call.err
+}
+
+func (c *WSClient) reconnectWithRetry(ctx context.Context) error {
+	policy := c.retryPolicy()
+	if policy == nil {
+		return c.ReconnectContext(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.reconnect(ctx)
+		c.metrics().RecordReconnect(attempt, err)
+
+		if err == nil {
+			return nil
+		}
+
+		if IsPermanent(err) {
+			return err
+		}
+
+		if maxAttempts := policy.MaxAttempts(); maxAttempts > 0 && attempt >= maxAttempts {
+			c.logger().Errorf("reconnect failed after %d attempts: %v", attempt, err)
+			return fmt.Errorf("reconnect failed after %d attempts: %w", attempt, err)
+		}
+
+		delay := policy.NextDelay(attempt)
+		c.logger().Warnf("reconnect attempt %d failed, retrying in %s: %v", attempt, delay, err)
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("reconnect: %w", ctx.Err())
+		}
+	}
+}
+
 // Send sends a single msgp.Encodable across the wire.
 func (c *WSClient) Send(e protocol.ChunkEncoder) error {
-	var (
-		err            error
-		rawMessageData bytes.Buffer
-	)
+	return c.SendMessageContext(context.Background(), e)
+}
+
+// SendMessageContext is like Send, but the context bounds the write. If the
+// context deadline passes before the message is written, ErrWriteTimeout is
+// returned. The write deadline in ConnectionOptions is restored afterward.
+func (c *WSClient) SendMessageContext(ctx context.Context, e msgp.Encodable) error {
+	if err := c.waitRateLimit(ctx); err != nil {
+		return err
+	}
+
+	if err := c.beginSend(); err != nil {
+		return err
+	}
+
+	defer c.endSend()
+
+	if c.Sequence {
+		c.injectSequence(e)
+	}
+
+	start := time.Now()
+	send := c.sendChain()
+	err := c.guard(func() error { return send(ctx, e) })
+	c.metrics().RecordSend(MessageTag(e), time.Since(start), err)
+
+	if err != nil {
+		c.logger().Warnf("send %q failed: %v", MessageTag(e), err)
+	}
+
+	return err
+}
+
+// guard runs send through the Breaker, if one is set.
+func (c *WSClient) guard(send func() error) error {
+	if c.Breaker == nil {
+		return send()
+	}
+
+	if err := c.Breaker.Allow(); err != nil {
+		return err
+	}
+
+	err := send()
+	c.Breaker.Record(err)
+
+	return err
+}
+
+func (c *WSClient) sendMessage(ctx context.Context, e msgp.Encodable) error {
+	var err error
 	// Check for an async connection error and return it here.
 	// In most cases, the client will not care about reading from
 	// the connection, so checking for the error here is sufficient.
@@ -294,27 +1274,320 @@ func (c *WSClient) Send(e protocol.ChunkEncoder) error {
 		return err // TODO: wrap this
 	}
 
+	c.injectTraceContext(ctx, e)
+
 	// prevent this from raise conditions by copy the session pointer
-	session := c.Session()
+	session := c.writeSession()
 	if session == nil || session.Connection.Closed() {
+		if session != nil {
+			session.writes.RUnlock()
+		}
+
+		if c.Buffer.Enabled {
+			// buffered and spilled messages are drained as they are, so
+			// they are retagged now
+			if err = c.enqueue(ctx, c.retag(e)); err != errSpillFreed {
+				return err
+			}
+
+			return c.sendMessage(ctx, e)
+		}
+
 		return errors.New("no active session")
 	}
 
-	err = msgp.Encode(&rawMessageData, e)
+	defer session.writes.RUnlock()
+
+	rawMessageData := getEncodeBuffer()
+	defer putEncodeBuffer(rawMessageData)
+
+	err = c.encode(rawMessageData, session.Capabilities.gate(c.retag(e)))
 	if err != nil {
 		return err
 	}
 
-	bytesData := rawMessageData.Bytes()
+	if pending := pendingAckFrom(ctx); pending != nil {
+		pending.keep(rawMessageData.Bytes())
+	}
+
+	return c.writeContext(ctx, session.Connection, rawMessageData.Bytes())
+}
+
+// writeContext writes data to the connection within the bounds of ctx.
+// The connection applies the context to this write alone, so concurrent
+// sends keep their own deadlines. Contexts that can never be done are
+// written without one.
+func (c *WSClient) writeContext(ctx context.Context, conn ws.Connection, data []byte) error {
+	var err error
+
 	// Write function does not accurately return the number of bytes written
 	// so it would be ineffective to compare
-	_, err = c.session.Connection.Write(bytesData)
+	if ctx.Done() == nil {
+		_, err = conn.Write(data)
+	} else {
+		_, err = conn.WriteContext(ctx, data)
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	// a canceled context surfaces as a deadline error from the connection,
+	// so it must be checked first
+	ctxErr := ctx.Err()
+	if errors.Is(ctxErr, context.Canceled) {
+		return fmt.Errorf("write: %w", ctxErr)
+	}
+
+	if ctxErr != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return fmt.Errorf("%w: %s", ErrWriteTimeout, err.Error())
+	}
 
 	return err
 }
 
+// SendMessages sends the entries in a single Forward mode message, which is
+// far cheaper than sending each entry on its own. If chunk is not empty, it
+// is set as the message's "chunk" option so that the peer's ack can be
+// matched to the message.
+func (c *WSClient) SendMessages(tag string, entries protocol.EntryList, chunk string) error {
+	return c.SendMessagesContext(context.Background(), tag, entries, chunk)
+}
+
+// SendMessagesContext is like SendMessages, but the context bounds the write.
+// See SendMessageContext.
+func (c *WSClient) SendMessagesContext(
+	ctx context.Context, tag string, entries protocol.EntryList, chunk string,
+) error {
+	msg := protocol.NewForwardMessage(tag, entries)
+	msg.Options.Chunk = chunk
+
+	return c.SendMessageContext(ctx, msg)
+}
+
+// ackReadHandler returns a ReadHandler that routes ack frames to the
+// SendMessageAck waiting on their chunk, then to next, and every other
+// frame, or read error, to listen. Acks thus never reach the ListenWith
+// handler that listen adds, even if they arrive after their wait has ended.
+func (c *WSClient) ackReadHandler(listen, next ws.ReadHandler) ws.ReadHandler {
+	return func(conn ws.Connection, messageType int, p []byte, err error) error {
+		if err != nil {
+			return listen(conn, messageType, p, err)
+		}
+
+		var ack protocol.AckMessage
+		if _, uerr := ack.UnmarshalMsg(p); uerr != nil || ack.Ack == "" {
+			return listen(conn, messageType, p, err)
+		}
+
+		c.resolveAck(ack.Ack)
+
+		if next != nil {
+			return next(conn, messageType, p, err)
+		}
+
+		return nil
+	}
+}
+
+// pendingAck is a SendMessageAck waiting for the acknowledgement of its
+// chunk.
+type pendingAck struct {
+	acked chan struct{}
+	lock  sync.Mutex
+	data  []byte
+}
+
+// pendingAckKey is the context key under which SendMessageAck passes its
+// pendingAck to sendMessage, so that the message is kept as written.
+type pendingAckKey struct{}
+
+// keep stores a copy of the message as written, for resending.
+func (p *pendingAck) keep(data []byte) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.data = append(p.data[:0], data...)
+}
+
+// written returns the message as written, or nil if it has not been.
+func (p *pendingAck) written() []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.data
+}
+
+// keepDrained keeps a message written by drain as written if its ack is
+// awaited, so that it is resent like one written directly.
+func (c *WSClient) keepDrained(data []byte) {
+	chunk, err := protocol.GetChunk(data)
+	if err != nil {
+		return
+	}
+
+	if p, ok := c.pendingAcks.Load(chunk); ok {
+		p.(*pendingAck).keep(data)
+	}
+}
+
+func pendingAckFrom(ctx context.Context) *pendingAck {
+	p, _ := ctx.Value(pendingAckKey{}).(*pendingAck)
+	return p
+}
+
+func (c *WSClient) awaitAck(chunk string) *pendingAck {
+	p := &pendingAck{acked: make(chan struct{}, 1)}
+	c.pendingAcks.Store(chunk, p)
+
+	return p
+}
+
+func (c *WSClient) cancelAck(chunk string) {
+	c.pendingAcks.Delete(chunk)
+}
+
+func (c *WSClient) resolveAck(chunk string) {
+	if p, ok := c.pendingAcks.Load(chunk); ok {
+		// the acks of resent messages may all arrive
+		select {
+		case p.(*pendingAck).acked <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SendMessageAck sets the message's "chunk" option, taking an ID from
+// CorrelationIDs if one is not already set, sends it, and waits up to
+// AckTimeout for the peer to acknowledge it. If no acknowledgement
+// arrives, the message is resent as it was first written up to
+// AckRetryCount times, AckRetryBackoff after each timeout; if none of the
+// attempts is acknowledged, ErrAckTimeout is returned and the message is
+// passed to OnUnacked. AckMode must be enabled.
+func (c *WSClient) SendMessageAck(ctx context.Context, e protocol.ChunkEncoder) (string, error) {
+	if !c.AckMode {
+		return "", Permanent(errors.New("ack mode is not enabled"))
+	}
+
+	if session := c.Session(); session != nil && !session.Capabilities.SupportsAck() {
+		return "", ErrAckUnsupported
+	}
+
+	gen := c.CorrelationIDs
+	if gen == nil {
+		gen = UUIDGenerator{}
+	}
+
+	injectCorrelationID(e, gen)
+
+	chunk, err := e.Chunk()
+	if err != nil {
+		return "", err
+	}
+
+	pending := c.awaitAck(chunk)
+	defer c.cancelAck(chunk)
+
+	if err = c.SendMessageContext(context.WithValue(ctx, pendingAckKey{}, pending), e); err != nil {
+		return chunk, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err = c.waitAck(ctx, pending); err == nil {
+			return chunk, nil
+		}
+
+		if !errors.Is(err, ErrAckTimeout) || attempt > c.AckRetryCount {
+			break
+		}
+
+		if err = c.resendUnacked(ctx, chunk, pending, attempt); err != nil {
+			break
+		}
+	}
+
+	if c.OnUnacked != nil {
+		c.OnUnacked(chunk, e)
+	}
+
+	return chunk, err
+}
+
+// waitAck waits up to AckTimeout for the acknowledgement of pending.
+func (c *WSClient) waitAck(ctx context.Context, pending *pendingAck) error {
+	timeout := c.AckTimeout
+	if timeout == 0 {
+		timeout = DefaultAckTimeout
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-pending.acked:
+		return nil
+	case <-timer.C:
+		return ErrAckTimeout
+	case <-ctx.Done():
+		return fmt.Errorf("await ack: %w", ctx.Err())
+	}
+}
+
+// resendUnacked waits AckRetryBackoff, then resends the message of
+// pending as it was written. A message that has not been written yet, e.g.
+// because it is buffered, is not resent but waited for again.
+func (c *WSClient) resendUnacked(ctx context.Context, chunk string, pending *pendingAck, attempt int) error {
+	if c.AckRetryBackoff > 0 {
+		timer := time.NewTimer(c.AckRetryBackoff)
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("await ack: %w", ctx.Err())
+		}
+	}
+
+	data := pending.written()
+	if data == nil {
+		return nil
+	}
+
+	c.logger().Warnf("no ack for chunk %s; resending, retry %d of %d", chunk, attempt, c.AckRetryCount)
+
+	return c.sendRawContext(ctx, data)
+}
+
 // SendRaw sends an array of bytes across the wire.
 func (c *WSClient) SendRaw(m []byte) error {
+	return c.sendRawContext(context.Background(), m)
+}
+
+// sendRawContext is SendRaw, but gives up waiting for the RateLimit when
+// ctx is done.
+func (c *WSClient) sendRawContext(ctx context.Context, m []byte) error {
+	if err := c.waitRateLimit(ctx); err != nil {
+		return err
+	}
+
+	if err := c.beginSend(); err != nil {
+		return err
+	}
+
+	defer c.endSend()
+
+	start := time.Now()
+	err := c.guard(func() error { return c.sendRaw(m) })
+	c.metrics().RecordSend("", time.Since(start), err)
+
+	return err
+}
+
+func (c *WSClient) sendRaw(m []byte) error {
+	if err := c.checkSize(len(m)); err != nil {
+		return err
+	}
+
 	// Check for an async connection error and return it here.
 	// In most cases, the client will not care about reading from
 	// the connection, so checking for the error here is sufficient.
@@ -323,8 +1596,14 @@ func (c *WSClient) SendRaw(m []byte) error {
 	}
 
 	// prevent this from raise conditions by copy the session pointer
-	session := c.Session()
-	if session == nil || session.Connection.Closed() {
+	session := c.writeSession()
+	if session == nil {
+		return errors.New("no active session")
+	}
+
+	defer session.writes.RUnlock()
+
+	if session.Connection.Closed() {
 		return errors.New("no active session")
 	}
 
@@ -0,0 +1,202 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package client_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const bmRecordCount = 1000
+
+// newBenchmarkWSClient returns a client connected to a local websocket
+// server that discards everything it reads.
+func newBenchmarkWSClient(b *testing.B) (*client.WSClient, func()) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+
+		wc, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer wc.Close()
+
+		for {
+			if _, _, err := wc.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	c := client.NewWS(client.WSConnectionOptions{
+		Factory: &client.DefaultWSConnectionFactory{
+			URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+		},
+	})
+
+	if err := c.Connect(); err != nil {
+		svr.Close()
+		b.Fatal(err)
+	}
+
+	return c, func() {
+		_ = c.Disconnect()
+		svr.Close()
+	}
+}
+
+func makeBenchmarkEntries() protocol.EntryList {
+	entries := make(protocol.EntryList, bmRecordCount)
+	for i := range entries {
+		entries[i] = protocol.EntryExt{
+			Timestamp: protocol.EventTimeNow(),
+			Record:    map[string]interface{}{"msg": "oi"},
+		}
+	}
+
+	return entries
+}
+
+func Benchmark_WSClient_SendLoop(b *testing.B) {
+	c, cleanup := newBenchmarkWSClient(b)
+	defer cleanup()
+
+	entries := makeBenchmarkEntries()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, e := range entries {
+			msg := &protocol.MessageExt{Tag: "foo", Timestamp: e.Timestamp, Record: e.Record}
+			if err := c.Send(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func Benchmark_WSClient_SendMessages(b *testing.B) {
+	c, cleanup := newBenchmarkWSClient(b)
+	defer cleanup()
+
+	entries := makeBenchmarkEntries()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.SendMessages("foo", entries, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// discardConnection is a ws.Connection that accepts every write without
+// doing anything, so that only the client's own allocations are counted.
+type discardConnection struct {
+	ws.Connection
+}
+
+func (discardConnection) Write(p []byte) (int, error) { return len(p), nil }
+
+func (discardConnection) Closed() bool { return false }
+
+func (discardConnection) Listen() error { select {} }
+
+// newAllocsWSClient returns a client connected to a discardConnection, and
+// the message that allocation tests send with it.
+func newAllocsWSClient() (*client.WSClient, *protocol.Message, error) {
+	factory := &clientfakes.FakeWSConnectionFactory{}
+	factory.NewReturns(&extfakes.FakeConn{}, nil)
+	factory.NewSessionReturns(&client.WSSession{Connection: discardConnection{}})
+
+	c := client.NewWS(client.WSConnectionOptions{Factory: factory})
+	if err := c.Connect(); err != nil {
+		return nil, nil, err
+	}
+
+	msg := &protocol.Message{
+		Tag:       "foo",
+		Timestamp: protocol.EventTimeNow().Unix(),
+		Record:    map[string]interface{}{"msg": "oi"},
+	}
+
+	return c, msg, nil
+}
+
+// sendAllocs returns the allocations per Send of msg. gorilla/websocket
+// allocates a message writer for every message a client sends, so a real
+// connection adds one allocation that this leaves out.
+func sendAllocs(c *client.WSClient, msg *protocol.Message) float64 {
+	return testing.AllocsPerRun(100, func() { _ = c.Send(msg) })
+}
+
+var _ = Describe("WSClient allocations", func() {
+	It("sends a small message without allocating", func() {
+		if raceEnabled {
+			Skip("the race detector allocates")
+		}
+
+		c, msg, err := newAllocsWSClient()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(sendAllocs(c, msg)).To(BeZero())
+	})
+})
+
+// Benchmark_WSClient_SendMessageAllocs fails if sending a small message
+// allocates; the suite checks the same with every test run.
+func Benchmark_WSClient_SendMessageAllocs(b *testing.B) {
+	c, msg, err := newAllocsWSClient()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	if allocs := sendAllocs(c, msg); allocs > 0 {
+		b.Fatalf("Send allocated %.0f times per message; want 0", allocs)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.Send(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
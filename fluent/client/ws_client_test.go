@@ -26,12 +26,22 @@ package client_test
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime/pprof"
 	"strings"
+	"sync/atomic"
+	"syscall"
 
 	"time"
 
@@ -48,17 +58,9 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/tinylib/msgp/msgp"
+	"golang.org/x/time/rate"
 )
 
-var _ = Describe("IAMAuthInfo", func() {
-	It("gets and sets an IAM token", func() {
-		iai := NewIAMAuthInfo("a")
-		Expect(iai.IAMToken()).To(Equal("a"))
-		iai.SetIAMToken("b")
-		Expect(iai.IAMToken()).To(Equal("b"))
-	})
-})
-
 var _ = Describe("DefaultWSConnectionFactory", func() {
 	var (
 		svr               *httptest.Server
@@ -125,10 +127,7 @@ var _ = Describe("DefaultWSConnectionFactory", func() {
 
 		cli := fclient.NewWS(client.WSConnectionOptions{
 			Factory: &client.DefaultWSConnectionFactory{
-				URL: u,
-				TLSConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
+				URL:      u,
 				AuthInfo: NewIAMAuthInfo("oi"),
 			},
 		})
@@ -149,10 +148,7 @@ var _ = Describe("DefaultWSConnectionFactory", func() {
 
 		cli := fclient.NewWS(client.WSConnectionOptions{
 			Factory: &client.DefaultWSConnectionFactory{
-				URL: u,
-				TLSConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
+				URL:      u,
 				AuthInfo: NewIAMAuthInfo("oi"),
 				Header:   testHeaders,
 			},
@@ -163,6 +159,277 @@ var _ = Describe("DefaultWSConnectionFactory", func() {
 		Expect(cli.Disconnect()).ToNot(HaveOccurred())
 	})
 
+	It("lets the auth headers win over Header", func() {
+		testHeaders = http.Header{"X-B3-Traceid": []string{"abc"}}
+
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+				AuthInfo: NewIAMAuthInfo("oi"),
+				Header: http.Header{
+					"X-B3-Traceid":              []string{"abc"},
+					fclient.AuthorizationHeader: []string{"other"},
+				},
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+		Eventually(ch).Should(Receive())
+		Expect(cli.Disconnect()).ToNot(HaveOccurred())
+	})
+
+	It("lets Header win over the auth headers with HeaderOverridesAuth", func() {
+		header := http.Header{fclient.AuthorizationHeader: []string{"oi"}}
+
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL:                 "ws" + strings.TrimPrefix(svr.URL, "http"),
+				AuthInfo:            NewIAMAuthInfo("other"),
+				Header:              header,
+				HeaderOverridesAuth: true,
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+		Eventually(ch).Should(Receive())
+		Expect(cli.Disconnect()).ToNot(HaveOccurred())
+		Expect(header).To(HaveLen(1))
+	})
+
+	It("dials with the NetDialer", func() {
+		var dialed int32
+
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+				AuthInfo: NewIAMAuthInfo("oi"),
+				NetDialer: &net.Dialer{
+					Timeout: time.Second,
+					Control: func(network, address string, c syscall.RawConn) error {
+						atomic.AddInt32(&dialed, 1)
+						return nil
+					},
+				},
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+		Eventually(ch).Should(Receive())
+		Expect(cli.Disconnect()).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&dialed)).To(Equal(int32(1)))
+	})
+
+	Describe("ProxyURL", func() {
+		var (
+			proxy      *httptest.Server
+			tunneled   chan string
+			proxyAuths chan string
+		)
+
+		BeforeEach(func() {
+			tunneled = make(chan string, 1)
+			proxyAuths = make(chan string, 1)
+
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodConnect {
+					http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+					return
+				}
+
+				upstream, err := net.Dial("tcp", r.Host)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadGateway)
+					return
+				}
+
+				tunneled <- r.Host
+				proxyAuths <- r.Header.Get("Proxy-Authorization")
+
+				downstream, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					upstream.Close()
+					return
+				}
+
+				_, _ = downstream.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+
+				go func() {
+					_, _ = io.Copy(upstream, downstream)
+					upstream.Close()
+				}()
+
+				_, _ = io.Copy(downstream, upstream)
+				downstream.Close()
+			}))
+		})
+
+		AfterEach(func() {
+			proxy.Close()
+		})
+
+		It("tunnels the connection through the proxy", func() {
+			proxyURL, err := url.Parse(proxy.URL)
+			Expect(err).ToNot(HaveOccurred())
+			proxyURL.User = url.UserPassword("user", "pass")
+
+			cli := fclient.NewWS(client.WSConnectionOptions{
+				Factory: &client.DefaultWSConnectionFactory{
+					URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+					AuthInfo: NewIAMAuthInfo("oi"),
+					ProxyURL: proxyURL,
+				},
+			})
+
+			Expect(cli.Connect()).ToNot(HaveOccurred())
+			Eventually(ch).Should(Receive())
+			Expect(cli.Disconnect()).ToNot(HaveOccurred())
+
+			Expect(tunneled).To(Receive(Equal(strings.TrimPrefix(svr.URL, "http://"))))
+			Expect(proxyAuths).To(Receive(Equal("Basic dXNlcjpwYXNz")))
+		})
+
+		It("returns the proxy's refusal", func() {
+			refusing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", http.StatusProxyAuthRequired)
+			}))
+			defer refusing.Close()
+
+			proxyURL, err := url.Parse(refusing.URL)
+			Expect(err).ToNot(HaveOccurred())
+
+			factory := &client.DefaultWSConnectionFactory{
+				URL:      "ws://127.0.0.1:1",
+				ProxyURL: proxyURL,
+			}
+
+			_, err = factory.New(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("proxy CONNECT: 407")))
+		})
+
+		It("dials with the ProxyDialer", func() {
+			proxyAddr, socksTunneled := startSOCKS5("user", "pass")
+
+			cli := fclient.NewWS(client.WSConnectionOptions{
+				Factory: &client.DefaultWSConnectionFactory{
+					URL:         "ws" + strings.TrimPrefix(svr.URL, "http"),
+					AuthInfo:    NewIAMAuthInfo("oi"),
+					ProxyDialer: SOCKS5ProxyDialer(proxyAddr, "user", "pass"),
+				},
+			})
+
+			Expect(cli.Connect()).ToNot(HaveOccurred())
+			Eventually(ch).Should(Receive())
+			Expect(cli.Disconnect()).ToNot(HaveOccurred())
+
+			Expect(socksTunneled).To(Receive(Equal(strings.TrimPrefix(svr.URL, "http://"))))
+		})
+
+		It("tunnels a socks5 ProxyURL", func() {
+			proxyAddr, socksTunneled := startSOCKS5("", "")
+
+			cli := fclient.NewWS(client.WSConnectionOptions{
+				Factory: &client.DefaultWSConnectionFactory{
+					URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+					AuthInfo: NewIAMAuthInfo("oi"),
+					ProxyURL: &url.URL{Scheme: "socks5", Host: proxyAddr},
+				},
+			})
+
+			Expect(cli.Connect()).ToNot(HaveOccurred())
+			Eventually(ch).Should(Receive())
+			Expect(cli.Disconnect()).ToNot(HaveOccurred())
+
+			Expect(socksTunneled).To(Receive(Equal(strings.TrimPrefix(svr.URL, "http://"))))
+		})
+
+		It("rejects unsupported schemes", func() {
+			factory := &client.DefaultWSConnectionFactory{
+				URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+				ProxyURL: &url.URL{Scheme: "ftp", Host: "127.0.0.1:21"},
+			}
+
+			_, err := factory.New(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("unsupported proxy scheme")))
+		})
+	})
+
+	It("sends the headers from the AuthProvider", func() {
+		auth := &clientfakes.FakeAuthProvider{}
+		auth.HeadersReturns(http.Header{fclient.AuthorizationHeader: []string{"oi"}}, nil)
+
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+				Auth:     auth,
+				AuthInfo: NewIAMAuthInfo("ignored"),
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+		Eventually(ch).Should(Receive())
+		Expect(cli.Disconnect()).ToNot(HaveOccurred())
+		Expect(auth.HeadersCallCount()).To(Equal(1))
+	})
+
+	It("does not dial if the AuthProvider fails", func() {
+		auth := &clientfakes.FakeAuthProvider{}
+		auth.HeadersReturns(nil, errors.New("nope"))
+
+		factory := &client.DefaultWSConnectionFactory{
+			URL:  "ws" + strings.TrimPrefix(svr.URL, "http"),
+			Auth: auth,
+		}
+
+		_, err := factory.New(context.Background())
+		Expect(err).To(MatchError("nope"))
+		Consistently(ch).ShouldNot(Receive())
+	})
+
+	When("the token has expired", func() {
+		var (
+			authInfo *IAMAuthInfo
+			factory  *client.DefaultWSConnectionFactory
+		)
+
+		JustBeforeEach(func() {
+			authInfo = NewIAMAuthInfo("expired")
+			authInfo.ExpiresAt = time.Now().Add(-time.Minute)
+			factory = &client.DefaultWSConnectionFactory{
+				URL:      "ws" + strings.TrimPrefix(svr.URL, "http"),
+				AuthInfo: authInfo,
+			}
+		})
+
+		It("refreshes it with OnExpired before dialing", func() {
+			authInfo.OnExpired = func() error {
+				authInfo.SetIAMTokenWithExpiry("oi", time.Now().Add(time.Minute))
+				return nil
+			}
+
+			cli := fclient.NewWS(client.WSConnectionOptions{Factory: factory})
+
+			Expect(cli.Connect()).ToNot(HaveOccurred())
+			Eventually(ch).Should(Receive())
+			Expect(cli.Disconnect()).ToNot(HaveOccurred())
+		})
+
+		It("does not dial if OnExpired fails", func() {
+			authInfo.OnExpired = func() error {
+				return errors.New("nope")
+			}
+
+			_, err := factory.New(context.Background())
+			Expect(err).To(MatchError(ContainSubstring("nope")))
+			Consistently(ch).ShouldNot(Receive())
+		})
+
+		It("returns ErrTokenExpired without OnExpired", func() {
+			_, err := factory.New(context.Background())
+			Expect(err).To(MatchError(ErrTokenExpired))
+			Consistently(ch).ShouldNot(Receive())
+		})
+	})
+
 	When("sends wrong url, expects error", func() {
 
 		BeforeEach(func() {
@@ -215,212 +482,1815 @@ var _ = Describe("DefaultWSConnectionFactory", func() {
 			err := cli.Connect()
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("rejects a URL that does not use wss", func() {
+			u := "ws" + strings.TrimPrefix(svr.URL, "https")
+
+			factory := &client.DefaultWSConnectionFactory{
+				URL: u,
+				TLSConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			}
+
+			conn, err := factory.New(context.Background())
+			Expect(conn).To(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("requires a wss:// URL")))
+		})
 	})
 })
 
-var _ = Describe("WSClient", func() {
+var _ = Describe("DefaultWSConnectionFactory with mutual TLS", func() {
 	var (
-		factory    *clientfakes.FakeWSConnectionFactory
-		client     *WSClient
-		clientSide ext.Conn
-		conn       *wsfakes.FakeConnection
-		session    *WSSession
+		svr        *httptest.Server
+		peerCerts  chan *x509.Certificate
+		clientCert tls.Certificate
+		cas        *x509.CertPool
+		u          string
 	)
 
 	BeforeEach(func() {
-		factory = &clientfakes.FakeWSConnectionFactory{}
-		client = fclient.NewWS(fclient.WSConnectionOptions{
-			Factory: factory,
-		})
-		clientSide = &extfakes.FakeConn{}
-		conn = &wsfakes.FakeConnection{}
-		session = &WSSession{Connection: conn}
+		var err error
+		clientCert, err = tls.LoadX509KeyPair("clientfakes/cert.pem", "clientfakes/key.pem")
+		Expect(err).ToNot(HaveOccurred())
 
-		Expect(factory.NewCallCount()).To(Equal(0))
-		Expect(client.Session()).To(BeNil())
-	})
+		peerCerts = make(chan *x509.Certificate, 1)
 
-	JustBeforeEach(func() {
-		factory.NewReturns(clientSide, nil)
-		factory.NewSessionReturns(session)
-	})
+		svr = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
 
-	Describe("Connect", func() {
-		It("Does not return an error", func() {
-			Expect(client.Connect()).ToNot(HaveOccurred())
-		})
+			peerCerts <- r.TLS.PeerCertificates[0]
 
-		It("Gets the connection from the ConnectionFactory", func() {
-			err := client.Connect()
-			Expect(err).NotTo(HaveOccurred())
-			Expect(factory.NewCallCount()).To(Equal(1))
-			Expect(factory.NewSessionCallCount()).To(Equal(1))
-			Expect(client.Session()).To(Equal(session))
-			Expect(client.Session().Connection).To(Equal(conn))
-		})
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err == nil {
+				wc.Close()
+			}
+		}))
+		svr.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		svr.StartTLS()
 
-		When("the factory returns an error", func() {
-			var (
-				connectionError error
-			)
+		cas = x509.NewCertPool()
+		cas.AddCert(svr.Certificate())
 
-			JustBeforeEach(func() {
-				connectionError = errors.New("Nope")
-				factory.NewReturns(nil, connectionError)
-			})
+		u = "wss" + strings.TrimPrefix(svr.URL, "https")
+	})
 
-			It("Returns an error", func() {
-				err := client.Connect()
-				Expect(err).To(HaveOccurred())
-				Expect(err).To(BeIdenticalTo(connectionError))
-			})
-		})
+	AfterEach(func() {
+		svr.Close()
+	})
+
+	It("presents the client certificate", func() {
+		factory := NewMTLSConnectionFactory(u, clientCert, cas)
 
+		conn, err := factory.New(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		var peer *x509.Certificate
+		Eventually(peerCerts).Should(Receive(&peer))
+		Expect(peer.Raw).To(Equal(clientCert.Certificate[0]))
 	})
 
-	Describe("Disconnect", func() {
-		When("the session is not nil", func() {
-			JustBeforeEach(func() {
-				err := client.Connect()
-				Expect(err).NotTo(HaveOccurred())
-				time.Sleep(100 * time.Millisecond)
-			})
+	It("uses a reloaded certificate for new connections", func() {
+		factory := NewMTLSConnectionFactory(u, clientCert, cas)
 
-			It("closes the connection", func() {
-				Expect(client.Disconnect()).ToNot(HaveOccurred())
-				Expect(conn.CloseCallCount()).To(Equal(1))
-			})
-		})
+		reloaded := svr.TLS.Certificates[0]
+		factory.ReloadCertificate(reloaded)
+		Expect(factory.ClientCertificate().Certificate[0]).To(Equal(reloaded.Certificate[0]))
 
-		// When("the session is nil", func() {
-		// 	JustBeforeEach(func() {
-		// 		client.Session = nil
-		// 	})
+		conn, err := factory.New(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
 
-		// 	It("does not error or panic", func() {
-		// 		Expect(func() {
-		// 			Expect(client.Disconnect()).ToNot(HaveOccurred())
-		// 		}).ToNot(Panic())
-		// 	})
-		// })
+		var peer *x509.Certificate
+		Eventually(peerCerts).Should(Receive(&peer))
+		Expect(peer.Raw).To(Equal(reloaded.Certificate[0]))
 	})
 
-	Describe("Reconnect", func() {
-		JustBeforeEach(func() {
-			err := client.Connect()
-			Expect(err).NotTo(HaveOccurred())
-			time.Sleep(100 * time.Millisecond)
-		})
+	It("does not modify the configured TLSConfig", func() {
+		factory := NewMTLSConnectionFactory(u, clientCert, cas)
 
-		It("calls Disconnect and creates a new Session", func() {
-			Expect(client.Reconnect()).ToNot(HaveOccurred())
+		conn, err := factory.New(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
 
-			Expect(conn.CloseCallCount()).To(Equal(1))
+		Expect(factory.TLSConfig.Certificates).To(BeEmpty())
+	})
+})
 
-			Expect(factory.NewSessionCallCount()).To(Equal(2))
-			Expect(client.Session().Connection).ToNot(BeNil())
-		})
+var _ = Describe("DefaultWSConnectionFactory with a context", func() {
+	var (
+		listener net.Listener
+		accepted chan net.Conn
+	)
 
-		It("works if no active session", func() {
-			Expect(client.Disconnect()).ToNot(HaveOccurred())
-			Expect(conn.CloseCallCount()).To(Equal(1))
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
 
-			Expect(client.Reconnect()).ToNot(HaveOccurred())
-			Expect(conn.CloseCallCount()).To(Equal(1))
+		accepted = make(chan net.Conn, 1)
 
-			Expect(factory.NewSessionCallCount()).To(Equal(2))
-			Expect(client.Session().Connection).ToNot(BeNil())
-		})
+		// accept the TCP connection but never answer the upgrade request
+		go func() {
+			conn, err := listener.Accept()
+			if err == nil {
+				accepted <- conn
+			}
+		}()
 	})
 
-	Describe("Send", func() {
+	AfterEach(func() {
+		listener.Close()
+
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	})
+
+	It("returns an error wrapping context.Canceled when canceled mid-dial", func() {
+		factory := &client.DefaultWSConnectionFactory{
+			URL: "ws://" + listener.Addr().String(),
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		conn, err := factory.New(ctx)
+		Expect(conn).To(BeNil())
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+
+	It("returns an error wrapping context.DeadlineExceeded when the deadline passes", func() {
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws://" + listener.Addr().String(),
+			},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := cli.ConnectContext(ctx)
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(cli.Session()).To(BeNil())
+	})
+})
+
+var _ = Describe("WSClient with AckMode", func() {
+	var (
+		svr      *httptest.Server
+		sendAcks bool
+		skipAcks int
+		frames   chan []byte
+		ackDelay time.Duration
+		timeout  time.Duration
+		reply    []byte
+		cli      *WSClient
+		unacked  chan string
+		msg      *protocol.Message
+	)
+
+	BeforeEach(func() {
+		sendAcks = true
+		skipAcks = 0
+		frames = make(chan []byte, 10)
+		ackDelay = 0
+		timeout = 0
+		reply = nil
+		unacked = make(chan string, 1)
+		msg = protocol.NewMessage("foo.bar", map[string]interface{}{"first": "Sir"})
+	})
+
+	JustBeforeEach(func() {
+		acks, skip, received, delay, reply := sendAcks, skipAcks, frames, ackDelay, reply
+
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+
+			defer wc.Close()
+
+			for {
+				_, p, err := wc.ReadMessage()
+				if err != nil {
+					return
+				}
+
+				select {
+				case received <- p:
+				default:
+				}
+
+				chunk, err := protocol.GetChunk(p)
+				if err != nil || !acks {
+					continue
+				}
+
+				if skip > 0 {
+					skip--
+					continue
+				}
+
+				time.Sleep(delay)
+
+				ack, _ := (&protocol.AckMessage{Ack: chunk}).MarshalMsg(nil)
+				if err = wc.WriteMessage(websocket.BinaryMessage, ack); err != nil {
+					return
+				}
+
+				if reply != nil {
+					if err = wc.WriteMessage(websocket.BinaryMessage, reply); err != nil {
+						return
+					}
+				}
+			}
+		}))
+
+		cli = fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+			ConnectionOptions: ws.ConnectionOptions{
+				WriteTimeout: timeout,
+			},
+			AckMode:    true,
+			AckTimeout: 100 * time.Millisecond,
+			OnUnacked: func(chunk string, _ protocol.ChunkEncoder) {
+				unacked <- chunk
+			},
+		})
+
+		Expect(cli.Connect()).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = cli.Disconnect()
+		svr.Close()
+	})
+
+	It("returns once the message is acknowledged", func() {
+		chunk, err := cli.SendMessageAck(context.Background(), msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunk).ToNot(BeEmpty())
+		Expect(msg.Options.Chunk).To(Equal(chunk))
+		Expect(unacked).ToNot(Receive())
+	})
+
+	It("gives the message a UUID", func() {
+		chunk, err := cli.SendMessageAck(context.Background(), msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunk).To(MatchRegexp(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`))
+	})
+
+	It("takes IDs from CorrelationIDs", func() {
+		cli.CorrelationIDs = &sequenceGenerator{}
+
+		fwd := protocol.NewForwardMessage("foo.bar", protocol.EntryList{{
+			Timestamp: protocol.EventTimeNow(),
+			Record:    "oi",
+		}})
+		fwd.Options = nil
+
+		chunk, err := cli.SendMessageAck(context.Background(), fwd)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunk).To(Equal("id-1"))
+
+		chunk, err = cli.SendMessageAck(context.Background(), msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunk).To(Equal("id-2"))
+	})
+
+	It("keeps a chunk that is already set", func() {
+		msg.Options = &protocol.MessageOptions{Chunk: "abc123"}
+
+		chunk, err := cli.SendMessageAck(context.Background(), msg)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chunk).To(Equal("abc123"))
+	})
+
+	When("the ack arrives after the write timeout", func() {
+		BeforeEach(func() {
+			ackDelay = 50 * time.Millisecond
+			timeout = 10 * time.Millisecond
+		})
+
+		It("waits for it up to the AckTimeout", func() {
+			_, err := cli.SendMessageAck(context.Background(), msg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unacked).ToNot(Receive())
+		})
+	})
+
+	When("retries are enabled", func() {
+		JustBeforeEach(func() {
+			cli.AckRetryCount = 2
+			cli.AckRetryBackoff = 10 * time.Millisecond
+		})
+
+		When("a resent message is acknowledged", func() {
+			BeforeEach(func() {
+				skipAcks = 2
+			})
+
+			It("resends the message as it was written", func() {
+				_, err := cli.SendMessageAck(context.Background(), msg)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(unacked).ToNot(Receive())
+
+				Expect(frames).To(HaveLen(3))
+				first := <-frames
+				Expect(<-frames).To(Equal(first))
+				Expect(<-frames).To(Equal(first))
+			})
+		})
+
+		When("the message was buffered", func() {
+			BeforeEach(func() {
+				skipAcks = 2
+			})
+
+			It("resends the message as it was drained", func() {
+				Expect(cli.Disconnect()).To(Succeed())
+				cli.Buffer.Enabled = true
+
+				acked := make(chan error, 1)
+				go func() {
+					_, err := cli.SendMessageAck(context.Background(), msg)
+					acked <- err
+				}()
+
+				Eventually(cli.Buffered).Should(Equal(1))
+				Expect(cli.Connect()).To(Succeed())
+
+				Eventually(acked).Should(Receive(BeNil()))
+				Expect(unacked).ToNot(Receive())
+
+				Expect(frames).To(HaveLen(3))
+				first := <-frames
+				Expect(<-frames).To(Equal(first))
+				Expect(<-frames).To(Equal(first))
+			})
+		})
+
+		When("no attempt is acknowledged", func() {
+			BeforeEach(func() {
+				skipAcks = 3
+			})
+
+			It("gives up after AckRetryCount retries", func() {
+				chunk, err := cli.SendMessageAck(context.Background(), msg)
+				Expect(err).To(MatchError(ErrAckTimeout))
+				Expect(unacked).To(Receive(Equal(chunk)))
+				Expect(frames).To(HaveLen(3))
+			})
+
+			It("stops when the context is done", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+				defer cancel()
+
+				_, err := cli.SendMessageAck(ctx, msg)
+				Expect(err).To(MatchError(context.DeadlineExceeded))
+				Expect(unacked).To(Receive())
+			})
+
+			It("stops waiting for the rate limit to resend when the context is done", func() {
+				cli.RateLimit = rate.NewLimiter(rate.Every(time.Hour), 1)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				time.AfterFunc(150*time.Millisecond, cancel)
+
+				acked := make(chan error, 1)
+				go func() {
+					_, err := cli.SendMessageAck(ctx, msg)
+					acked <- err
+				}()
+
+				Eventually(acked, time.Second).Should(Receive(MatchError(context.Canceled)))
+				Expect(unacked).To(Receive())
+				Expect(frames).To(HaveLen(1))
+			})
+		})
+	})
+
+	When("the peer does not acknowledge", func() {
+		BeforeEach(func() {
+			sendAcks = false
+		})
+
+		It("returns ErrAckTimeout and reports the message as unacked", func() {
+			chunk, err := cli.SendMessageAck(context.Background(), msg)
+			Expect(err).To(MatchError(ErrAckTimeout))
+			Expect(unacked).To(Receive(Equal(chunk)))
+		})
+
+		It("does not reuse the chunk of a message that timed out", func() {
+			first, err := cli.SendMessageAck(context.Background(), msg)
+			Expect(err).To(MatchError(ErrAckTimeout))
+			Expect(unacked).To(Receive())
+
+			second, err := cli.SendMessageAck(context.Background(), protocol.NewMessage("foo.bar", "oi"))
+			Expect(err).To(MatchError(ErrAckTimeout))
+			Expect(second).ToNot(Equal(first))
+		})
+	})
+
+	When("listening", func() {
+		BeforeEach(func() {
+			var err error
+			reply, err = protocol.NewMessage("reply", "oi").MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("passes the frames that are not acks to the handler", func() {
+			received := make(chan []byte, 100)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func() {
+				_ = cli.ListenWith(ctx, func(m []byte) error {
+					received <- append([]byte(nil), m...)
+					return nil
+				})
+			}()
+
+			// the handler may not be registered before the first replies
+			Eventually(func() int {
+				_, err := cli.SendMessageAck(context.Background(), protocol.NewMessage("foo.bar", "oi"))
+				Expect(err).ToNot(HaveOccurred())
+
+				return len(received)
+			}).Should(BeNumerically(">", 0))
+
+			cancel()
+
+			for len(received) > 0 {
+				Expect(<-received).To(Equal(reply))
+			}
+		})
+	})
+
+	When("ack mode is off", func() {
+		JustBeforeEach(func() {
+			cli.AckMode = false
+		})
+
+		It("returns an error", func() {
+			_, err := cli.SendMessageAck(context.Background(), msg)
+			Expect(err).To(MatchError("ack mode is not enabled"))
+		})
+	})
+})
+
+var _ = Describe("WSClient", func() {
+	var (
+		factory    *clientfakes.FakeWSConnectionFactory
+		client     *WSClient
+		clientSide ext.Conn
+		conn       *wsfakes.FakeConnection
+		session    *WSSession
+	)
+
+	BeforeEach(func() {
+		factory = &clientfakes.FakeWSConnectionFactory{}
+		client = fclient.NewWS(fclient.WSConnectionOptions{
+			Factory: factory,
+		})
+		clientSide = &extfakes.FakeConn{}
+		conn = &wsfakes.FakeConnection{}
+		session = &WSSession{Connection: conn}
+
+		Expect(factory.NewCallCount()).To(Equal(0))
+		Expect(client.Session()).To(BeNil())
+	})
+
+	JustBeforeEach(func() {
+		factory.NewReturns(clientSide, nil)
+		factory.NewSessionReturns(session)
+	})
+
+	Describe("Connect", func() {
+		It("Does not return an error", func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+		})
+
+		It("Gets the connection from the ConnectionFactory", func() {
+			err := client.Connect()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(factory.NewCallCount()).To(Equal(1))
+			Expect(factory.NewSessionCallCount()).To(Equal(1))
+			Expect(client.Session()).To(Equal(session))
+			Expect(client.Session().Connection).To(Equal(conn))
+		})
+
+		It("passes the context to the ConnectionFactory", func() {
+			type ctxKey struct{}
+			ctx := context.WithValue(context.Background(), ctxKey{}, "oi")
+
+			Expect(client.ConnectContext(ctx)).ToNot(HaveOccurred())
+			Expect(factory.NewArgsForCall(0).Value(ctxKey{})).To(Equal("oi"))
+		})
+
+		When("the factory returns an error", func() {
+			var (
+				connectionError error
+			)
+
+			JustBeforeEach(func() {
+				connectionError = errors.New("Nope")
+				factory.NewReturns(nil, connectionError)
+			})
+
+			It("Returns an error", func() {
+				err := client.Connect()
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeIdenticalTo(connectionError))
+			})
+		})
+
+	})
+
+	Describe("Disconnect", func() {
+		When("the session is not nil", func() {
+			JustBeforeEach(func() {
+				err := client.Connect()
+				Expect(err).NotTo(HaveOccurred())
+				time.Sleep(100 * time.Millisecond)
+			})
+
+			It("closes the connection", func() {
+				Expect(client.Disconnect()).ToNot(HaveOccurred())
+				Expect(conn.CloseCallCount()).To(Equal(1))
+			})
+
+			It("applies the context deadline to the close write", func() {
+				deadline := time.Now().Add(time.Minute)
+				ctx, cancel := context.WithDeadline(context.Background(), deadline)
+				defer cancel()
+
+				Expect(client.DisconnectContext(ctx)).ToNot(HaveOccurred())
+				Expect(conn.SetWriteDeadlineCallCount()).To(Equal(1))
+				Expect(conn.SetWriteDeadlineArgsForCall(0)).To(BeTemporally("==", deadline))
+				Expect(conn.CloseCallCount()).To(Equal(1))
+				Expect(client.Session()).To(BeNil())
+			})
+		})
+
+		// When("the session is nil", func() {
+		// 	JustBeforeEach(func() {
+		// 		client.Session = nil
+		// 	})
+
+		// 	It("does not error or panic", func() {
+		// 		Expect(func() {
+		// 			Expect(client.Disconnect()).ToNot(HaveOccurred())
+		// 		}).ToNot(Panic())
+		// 	})
+		// })
+	})
+
+	Describe("Reconnect", func() {
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).NotTo(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("calls Disconnect and creates a new Session", func() {
+			Expect(client.Reconnect()).ToNot(HaveOccurred())
+
+			Expect(conn.CloseCallCount()).To(Equal(1))
+
+			Expect(factory.NewSessionCallCount()).To(Equal(2))
+			Expect(client.Session().Connection).ToNot(BeNil())
+		})
+
+		It("works if no active session", func() {
+			Expect(client.Disconnect()).ToNot(HaveOccurred())
+			Expect(conn.CloseCallCount()).To(Equal(1))
+
+			Expect(client.Reconnect()).ToNot(HaveOccurred())
+			Expect(conn.CloseCallCount()).To(Equal(1))
+
+			Expect(factory.NewSessionCallCount()).To(Equal(2))
+			Expect(client.Session().Connection).ToNot(BeNil())
+		})
+	})
+
+	Describe("HotReconnect", func() {
+		var newConn *wsfakes.FakeConnection
+
+		JustBeforeEach(func() {
+			newConn = &wsfakes.FakeConnection{}
+			factory.NewSessionReturnsOnCall(1, &WSSession{Connection: newConn})
+
+			Expect(client.Connect()).To(Succeed())
+		})
+
+		It("switches to a new connection before closing the old one", func() {
+			Expect(client.HotReconnect(context.Background())).To(Succeed())
+
+			Expect(client.Session().Connection).To(BeIdenticalTo(newConn))
+			Expect(conn.CloseCallCount()).To(Equal(1))
+			Expect(newConn.CloseCallCount()).To(BeZero())
+
+			Expect(client.Send(protocol.NewMessage("tag", "oi"))).To(Succeed())
+			Expect(conn.WriteCallCount()).To(BeZero())
+			Expect(newConn.WriteCallCount()).To(Equal(1))
+		})
+
+		It("closes the old connection once its writes have finished", func() {
+			writing := make(chan struct{})
+			release := make(chan struct{})
+			conn.WriteStub = func([]byte) (int, error) {
+				close(writing)
+				<-release
+
+				return 0, nil
+			}
+
+			sent := make(chan error)
+			go func() { sent <- client.Send(protocol.NewMessage("tag", "first")) }()
+			Eventually(writing).Should(BeClosed())
+
+			reconnected := make(chan error)
+			go func() { reconnected <- client.HotReconnect(context.Background()) }()
+
+			Eventually(client.Session).Should(HaveField("Connection", BeIdenticalTo(newConn)))
+			Expect(client.Send(protocol.NewMessage("tag", "second"))).To(Succeed())
+			Expect(newConn.WriteCallCount()).To(Equal(1))
+
+			Consistently(reconnected).ShouldNot(Receive())
+			Expect(conn.CloseCallCount()).To(BeZero())
+
+			close(release)
+			Eventually(sent).Should(Receive(BeNil()))
+			Eventually(reconnected).Should(Receive(BeNil()))
+			Expect(conn.CloseCallCount()).To(Equal(1))
+		})
+
+		It("keeps the old connection if the dial fails", func() {
+			dialErr := errors.New("nope")
+			factory.NewReturns(nil, dialErr)
+
+			Expect(client.HotReconnect(context.Background())).To(MatchError(dialErr))
+			Expect(client.Session()).To(BeIdenticalTo(session))
+			Expect(conn.CloseCallCount()).To(BeZero())
+			Expect(client.Send(protocol.NewMessage("tag", "oi"))).To(Succeed())
+		})
+
+		When("the new session fails to start", func() {
+			var (
+				listenErr  error
+				failListen chan struct{}
+			)
+
+			BeforeEach(func() {
+				listenErr = errors.New("read failed")
+				failListen = make(chan struct{})
+
+				fail, err := failListen, listenErr
+				conn.ListenStub = func() error {
+					<-fail
+					return err
+				}
+			})
+
+			It("closes the new connection and keeps the old one's error", func() {
+				client.Buffer.Enabled = true
+				conn.ClosedReturns(true)
+				Expect(client.Send(protocol.NewMessage("tag", "buffered"))).To(Succeed())
+
+				close(failListen)
+				Eventually(func() error {
+					return client.Send(protocol.NewMessage("tag", "oi"))
+				}).Should(MatchError(listenErr))
+
+				newSide := &extfakes.FakeConn{}
+				factory.NewReturns(newSide, nil)
+
+				// a failed write only drops the message, so the drain is
+				// failed by ending the context
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				newConn.WriteContextStub = func(context.Context, []byte) (int, error) {
+					cancel()
+					return 0, errors.New("write failed")
+				}
+
+				Expect(client.HotReconnect(ctx)).To(MatchError(context.Canceled))
+				Expect(newSide.CloseCallCount()).To(Equal(1))
+				Expect(newConn.CloseCallCount()).To(Equal(1))
+				Expect(client.Session()).To(BeIdenticalTo(session))
+				Expect(client.Send(protocol.NewMessage("tag", "oi"))).To(MatchError(listenErr))
+			})
+		})
+
+		It("connects if there is no session", func() {
+			Expect(client.Disconnect()).To(Succeed())
+
+			Expect(client.HotReconnect(context.Background())).To(Succeed())
+			Expect(client.Session().Connection).To(BeIdenticalTo(newConn))
+			Expect(conn.CloseCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("ReconnectWithRetry", func() {
+		var (
+			dialErr error
+		)
+
+		BeforeEach(func() {
+			dialErr = errors.New("Nope")
+			client.RetryPolicy = &DefaultExponentialBackoff{
+				BaseDelay: time.Millisecond,
+				Attempts:  3,
+			}
+		})
+
+		JustBeforeEach(func() {
+			factory.NewReturnsOnCall(0, nil, dialErr)
+			factory.NewReturnsOnCall(1, nil, dialErr)
+		})
+
+		It("retries until the connection succeeds", func() {
+			Expect(client.ReconnectWithRetry(context.Background())).ToNot(HaveOccurred())
+			Expect(factory.NewCallCount()).To(Equal(3))
+			Expect(client.Session()).To(Equal(session))
+		})
+
+		When("every attempt fails", func() {
+			JustBeforeEach(func() {
+				factory.NewReturnsOnCall(2, nil, dialErr)
+			})
+
+			It("stops after MaxAttempts", func() {
+				err := client.ReconnectWithRetry(context.Background())
+				Expect(errors.Is(err, dialErr)).To(BeTrue())
+				Expect(factory.NewCallCount()).To(Equal(3))
+				Expect(client.Session()).To(BeNil())
+			})
+		})
+
+		When("the error is not retryable", func() {
+			BeforeEach(func() {
+				dialErr = NewWSConnError(errors.New("bad handshake"), http.StatusUnauthorized, "")
+			})
+
+			It("does not retry", func() {
+				err := client.ReconnectWithRetry(context.Background())
+				Expect(err).To(BeIdenticalTo(dialErr))
+				Expect(factory.NewCallCount()).To(Equal(1))
+			})
+		})
+
+		When("the error is permanent", func() {
+			BeforeEach(func() {
+				dialErr = Permanent(errors.New("protocol violation"))
+			})
+
+			It("does not retry", func() {
+				err := client.ReconnectWithRetry(context.Background())
+				Expect(err).To(BeIdenticalTo(dialErr))
+				Expect(factory.NewCallCount()).To(Equal(1))
+			})
+		})
+
+		When("the context is canceled while waiting", func() {
+			BeforeEach(func() {
+				client.RetryPolicy = &DefaultExponentialBackoff{BaseDelay: time.Minute}
+			})
+
+			It("returns the context error", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+
+				err := client.ReconnectWithRetry(ctx)
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+				Expect(factory.NewCallCount()).To(Equal(1))
+			})
+		})
+
+		When("called concurrently", func() {
+			BeforeEach(func() {
+				client.RetryPolicy = &DefaultExponentialBackoff{BaseDelay: 50 * time.Millisecond}
+			})
+
+			It("shares a single reconnect", func() {
+				errs := make(chan error, 3)
+				for i := 0; i < 3; i++ {
+					go func() {
+						defer GinkgoRecover()
+						errs <- client.ReconnectWithRetry(context.Background())
+					}()
+				}
+
+				for i := 0; i < 3; i++ {
+					Eventually(errs).Should(Receive(BeNil()))
+				}
+
+				Expect(factory.NewCallCount()).To(Equal(3))
+			})
+		})
+	})
+
+	Describe("Send", func() {
+		var (
+			msg protocol.MessageExt
+		)
+
+		BeforeEach(func() {
+			msg = protocol.MessageExt{
+				Tag:       "foo.bar",
+				Timestamp: protocol.EventTime{Time: time.Now()},
+				Record:    map[string]interface{}{},
+				Options:   &protocol.MessageOptions{},
+			}
+		})
+
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("Sends the message", func() {
+			bits, _ := msg.MarshalMsg(nil)
+			Expect(client.Send(&msg)).ToNot(HaveOccurred())
+
+			writtenbits := conn.WriteArgsForCall(0)
+			Expect(bytes.Equal(bits, writtenbits)).To(BeTrue())
+		})
+
+		It("Sends the message", func() {
+			msgBytes, _ := msg.MarshalMsg(nil)
+			Expect(client.Send(&msg)).ToNot(HaveOccurred())
+
+			writtenBytes := conn.WriteArgsForCall(0)
+			Expect(bytes.Equal(msgBytes, writtenBytes)).To(BeTrue())
+		})
+
+		When("The message is large", func() {
+			const charset = "abcdefghijklmnopqrstuvwxyz" + "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+			var (
+				expectedBytes int
+				messageSize   = 65536
+			)
+
+			JustBeforeEach(func() {
+				seededRand := rand.New(
+					rand.NewSource(time.Now().UnixNano()))
+				m := make([]byte, messageSize)
+				for i := range m {
+					m[i] = charset[seededRand.Intn(len(charset))]
+				}
+				msg.Record = m
+
+				var b bytes.Buffer
+				Expect(msgp.Encode(&b, &msg)).ToNot(HaveOccurred())
+				expectedBytes = len(b.Bytes())
+			})
+
+			It("Sends the correct number of bits", func() {
+				Expect(client.Send(&msg)).ToNot(HaveOccurred())
+				Expect(conn.WriteCallCount()).To(Equal(1))
+				writtenBytes := len(conn.WriteArgsForCall(0))
+				Expect(writtenBytes).To(Equal(expectedBytes))
+			})
+		})
+
+		When("the connection is disconnected", func() {
+			JustBeforeEach(func() {
+				err := client.Disconnect()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				Expect(client.Send(&msg)).To(MatchError("no active session"))
+			})
+		})
+
+		When("the connection is closed with an error", func() {
+			BeforeEach(func() {
+				conn.ListenReturns(errors.New("BOOM"))
+			})
+
+			It("returns the error", func() {
+				Expect(client.Send(&msg)).To(MatchError("BOOM"))
+			})
+		})
+	})
+
+	Describe("SendMessageContext", func() {
 		var (
 			msg protocol.MessageExt
 		)
 
 		BeforeEach(func() {
-			msg = protocol.MessageExt{
-				Tag:       "foo.bar",
-				Timestamp: protocol.EventTime{time.Now()}, //nolint
-				Record:    map[string]interface{}{},
-				Options:   &protocol.MessageOptions{},
+			msg = protocol.MessageExt{
+				Tag:       "foo.bar",
+				Timestamp: protocol.EventTime{Time: time.Now()},
+				Record:    map[string]interface{}{},
+			}
+		})
+
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("passes the context to the write, leaving the shared deadline alone", func() {
+			deadline := time.Now().Add(time.Minute)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+
+			Expect(client.SendMessageContext(ctx, &msg)).ToNot(HaveOccurred())
+			Expect(conn.WriteContextCallCount()).To(Equal(1))
+			Expect(conn.WriteCallCount()).To(BeZero())
+			Expect(conn.SetWriteDeadlineCallCount()).To(BeZero())
+
+			writeCtx, _ := conn.WriteContextArgsForCall(0)
+			writeDeadline, ok := writeCtx.Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(writeDeadline).To(BeTemporally("==", deadline))
+		})
+
+		It("writes without a context for a background context", func() {
+			Expect(client.SendMessageContext(context.Background(), &msg)).ToNot(HaveOccurred())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+			Expect(conn.WriteContextCallCount()).To(BeZero())
+		})
+
+		When("the write stalls", func() {
+			BeforeEach(func() {
+				conn.WriteContextStub = func(ctx context.Context, _ []byte) (int, error) {
+					<-ctx.Done()
+					return 0, os.ErrDeadlineExceeded
+				}
+			})
+
+			It("returns ErrWriteTimeout when the deadline passes", func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+
+				err := client.SendMessageContext(ctx, &msg)
+				Expect(errors.Is(err, ErrWriteTimeout)).To(BeTrue())
+			})
+
+			It("returns an error wrapping context.Canceled when canceled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(50*time.Millisecond, cancel)
+
+				err := client.SendMessageContext(ctx, &msg)
+				Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Breaker", func() {
+		BeforeEach(func() {
+			client.Breaker = NewCircuitBreaker(2, 0, time.Minute)
+		})
+
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("stops writing once the breaker opens", func() {
+			conn.WriteReturns(0, errors.New("nope"))
+			msg := protocol.NewMessage("foo.bar", map[string]interface{}{})
+
+			Expect(client.Send(msg)).To(MatchError("nope"))
+			Expect(client.SendRaw([]byte("oi"))).To(MatchError("nope"))
+			Expect(client.Breaker.State()).To(Equal(CircuitOpen))
+
+			Expect(client.Send(msg)).To(MatchError(ErrCircuitOpen))
+			Expect(conn.WriteCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("MaxMessageBytes", func() {
+		BeforeEach(func() {
+			client.MaxMessageBytes = 64
+		})
+
+		JustBeforeEach(func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+		})
+
+		It("rejects oversized messages without writing", func() {
+			msg := protocol.NewMessage("foo.bar", map[string]interface{}{
+				"log": strings.Repeat("x", 4096),
+			})
+
+			Expect(client.Send(msg)).To(MatchError(ErrMessageTooLarge))
+			Expect(client.SendRaw(make([]byte, 65))).To(MatchError(ErrMessageTooLarge))
+			Expect(conn.WriteCallCount()).To(BeZero())
+		})
+
+		It("sends messages within the limit", func() {
+			msg := protocol.NewMessage("foo.bar", map[string]interface{}{"log": "oi"})
+
+			Expect(client.Send(msg)).ToNot(HaveOccurred())
+			Expect(client.SendRaw(make([]byte, 64))).ToNot(HaveOccurred())
+			Expect(conn.WriteCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("RateLimit", func() {
+		var (
+			msg *protocol.Message
+		)
+
+		BeforeEach(func() {
+			msg = protocol.NewMessage("foo.bar", map[string]interface{}{})
+			client = NewWSClientWithRateLimit(WSConnectionOptions{Factory: factory}, 100)
+			client.RateLimit.SetBurst(1)
+		})
+
+		JustBeforeEach(func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+		})
+
+		It("spaces out the sends", func() {
+			start := time.Now()
+
+			for i := 0; i < 21; i++ {
+				Expect(client.Send(msg)).ToNot(HaveOccurred())
+			}
+
+			// 20 sends wait 10ms each behind the first; a loaded machine
+			// may take longer, so only the lower bound is checked
+			Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+			Expect(conn.WriteCallCount()).To(Equal(21))
+		})
+
+		It("returns the context error while waiting", func() {
+			Expect(client.Send(msg)).ToNot(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			Expect(client.SendMessageContext(ctx, msg)).To(MatchError(context.Canceled))
+			Expect(conn.WriteCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("SendMessageAsync", func() {
+		var (
+			msg *protocol.Message
+		)
+
+		BeforeEach(func() {
+			msg = protocol.NewMessage("foo.bar", map[string]interface{}{})
+		})
+
+		JustBeforeEach(func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+		})
+
+		It("writes the queued messages without concurrent writes", func() {
+			var (
+				writing, overlaps int32
+			)
+
+			conn.WriteStub = func(data []byte) (int, error) {
+				if atomic.AddInt32(&writing, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+
+				time.Sleep(time.Microsecond)
+				atomic.AddInt32(&writing, -1)
+
+				return len(data), nil
+			}
+
+			done := make(chan struct{})
+
+			for i := 0; i < 10; i++ {
+				go func() {
+					defer GinkgoRecover()
+
+					for j := 0; j < 50; j++ {
+						Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+					}
+
+					done <- struct{}{}
+				}()
+			}
+
+			for i := 0; i < 10; i++ {
+				Eventually(done).Should(Receive())
+			}
+
+			Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+			Expect(conn.WriteCallCount()).To(Equal(500))
+			Expect(atomic.LoadInt32(&overlaps)).To(BeZero())
+		})
+
+		When("the queue is full", func() {
+			var (
+				release chan struct{}
+			)
+
+			BeforeEach(func() {
+				client.MaxQueueMessages = 2
+				release = make(chan struct{})
+
+				conn.WriteStub = func(data []byte) (int, error) {
+					<-release
+					return len(data), nil
+				}
+			})
+
+			It("returns ErrQueueFull until there is room", func() {
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Eventually(conn.WriteCallCount).Should(Equal(1))
+
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).To(MatchError(ErrQueueFull))
+
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				Expect(client.Flush(ctx)).To(MatchError(context.DeadlineExceeded))
+
+				close(release)
+
+				Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+				Expect(conn.WriteCallCount()).To(Equal(3))
+			})
+
+			It("reports the queue size in Stats", func() {
+				size := int64(msg.Msgsize())
+
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.Stats()).To(Equal(QueueStats{Messages: 2, Bytes: 2 * size}))
+
+				close(release)
+
+				Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+				Expect(client.Stats()).To(Equal(QueueStats{}))
+			})
+
+			It("sends the queued messages before GracefulDisconnect closes", func() {
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+
+				disconnected := make(chan error, 1)
+				cli := client
+				go func() {
+					disconnected <- cli.GracefulDisconnect(context.Background())
+				}()
+
+				Consistently(disconnected).ShouldNot(Receive())
+				Expect(conn.CloseCallCount()).To(Equal(0))
+
+				close(release)
+
+				Eventually(disconnected).Should(Receive(BeNil()))
+				Expect(conn.WriteCallCount()).To(Equal(2))
+				Expect(conn.CloseCallCount()).To(Equal(1))
+			})
+		})
+
+		When("MaxQueueBytes is set", func() {
+			var (
+				release chan struct{}
+			)
+
+			BeforeEach(func() {
+				client.MaxQueueBytes = int64(msg.Msgsize()) * 2
+				release = make(chan struct{})
+
+				conn.WriteStub = func(data []byte) (int, error) {
+					<-release
+					return len(data), nil
+				}
+			})
+
+			It("returns ErrQueueFull once the bytes queued would pass it", func() {
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).To(MatchError(ErrQueueFull))
+
+				close(release)
+
+				Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+				Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+				Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+				Expect(conn.WriteCallCount()).To(Equal(3))
+			})
+		})
+
+		It("stops the writer on Disconnect and starts one again on the next send", func() {
+			Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+			Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+
+			// the writers of other specs' clients may still be running
+			writers := asyncWriters()
+			Expect(client.Disconnect()).ToNot(HaveOccurred())
+			Eventually(asyncWriters).Should(BeNumerically("<", writers))
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+			Expect(client.Flush(context.Background())).ToNot(HaveOccurred())
+			Expect(conn.WriteCallCount()).To(Equal(2))
+		})
+
+		It("reports errors to OnAsyncError", func() {
+			errs := make(chan error, 1)
+			client.OnAsyncError = func(e msgp.Encodable, err error) {
+				Expect(e).To(Equal(msg))
+				errs <- err
+			}
+
+			conn.WriteReturns(0, errors.New("nope"))
+
+			Expect(client.SendMessageAsync(msg)).ToNot(HaveOccurred())
+			Eventually(errs).Should(Receive(MatchError("nope")))
+		})
+	})
+
+	Describe("GracefulDisconnect", func() {
+		var (
+			release chan struct{}
+			sent    chan error
+		)
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			sent = make(chan error, 1)
+
+			// the send may outlive the spec, so it must not read the
+			// variables the next spec reassigns
+			released := release
+			conn.WriteStub = func(data []byte) (int, error) {
+				if string(data) == "oi" {
+					<-released
+				}
+
+				return len(data), nil
+			}
+		})
+
+		JustBeforeEach(func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+
+			cli, result := client, sent
+			go func() {
+				result <- cli.SendRaw([]byte("oi"))
+			}()
+
+			Eventually(conn.WriteCallCount).Should(Equal(1))
+		})
+
+		It("waits for sends in progress before closing", func() {
+			disconnected := make(chan error, 1)
+
+			go func() {
+				disconnected <- client.GracefulDisconnect(context.Background())
+			}()
+
+			Eventually(func() error {
+				return client.SendRaw([]byte("late"))
+			}).Should(MatchError(ErrShuttingDown))
+			Consistently(conn.CloseCallCount).Should(Equal(0))
+
+			close(release)
+
+			Eventually(sent).Should(Receive(BeNil()))
+			Eventually(disconnected).Should(Receive(BeNil()))
+			Expect(conn.CloseCallCount()).To(Equal(1))
+			Expect(client.Session()).To(BeNil())
+		})
+
+		It("closes anyway when the context is done", func() {
+			defer close(release)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err := client.GracefulDisconnect(ctx)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(conn.CloseCallCount()).To(Equal(1))
+			Expect(client.Session()).To(BeNil())
+		})
+
+		Describe("DrainAndDisconnect", func() {
+			It("waits for sends in progress", func() {
+				cli, released := client, release
+				go func() {
+					defer GinkgoRecover()
+
+					Eventually(cli.SendRaw).WithArguments([]byte("late")).Should(MatchError(ErrShuttingDown))
+					close(released)
+				}()
+
+				Expect(client.DrainAndDisconnect(time.Second)).To(Succeed())
+				Eventually(sent).Should(Receive(BeNil()))
+				Expect(conn.CloseCallCount()).To(Equal(1))
+			})
+
+			It("closes anyway and returns ErrDrainTimeout when the timeout elapses", func() {
+				defer close(release)
+
+				Expect(client.DrainAndDisconnect(50 * time.Millisecond)).To(MatchError(ErrDrainTimeout))
+				Expect(conn.CloseCallCount()).To(Equal(1))
+				Expect(client.Session()).To(BeNil())
+			})
+
+			It("returns the close error once the sends have finished", func() {
+				closeErr := fmt.Errorf("close: %w", context.DeadlineExceeded)
+				conn.CloseReturns(closeErr)
+				close(release)
+				Eventually(sent).Should(Receive(BeNil()))
+
+				err := client.DrainAndDisconnect(time.Second)
+				Expect(err).To(MatchError(closeErr))
+				Expect(err).ToNot(MatchError(ErrDrainTimeout))
+			})
+		})
+
+		It("accepts sends again after connecting", func() {
+			close(release)
+			Expect(client.GracefulDisconnect(context.Background())).ToNot(HaveOccurred())
+			Expect(client.SendRaw([]byte("oi"))).To(MatchError(ErrShuttingDown))
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(client.SendRaw([]byte("again"))).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("lifecycle hooks", func() {
+		var (
+			events chan string
+		)
+
+		BeforeEach(func() {
+			events = make(chan string, 10)
+
+			client.OnConnect = func() {
+				// the client's locks must not be held
+				Expect(client.Session()).ToNot(BeNil())
+				events <- "connect"
+			}
+			client.OnDisconnect = func(err error) {
+				Expect(client.Session()).To(BeNil())
+				events <- "disconnect"
+			}
+			client.OnError = func(err error) {
+				events <- "error: " + err.Error()
+			}
+		})
+
+		It("calls OnConnect and OnDisconnect", func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(events).To(Receive(Equal("connect")))
+
+			Expect(client.Disconnect()).ToNot(HaveOccurred())
+			Expect(events).To(Receive(Equal("disconnect")))
+		})
+
+		It("calls both on Reconnect", func() {
+			client.OnDisconnect = func(err error) {
+				events <- "disconnect"
+			}
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(client.Reconnect()).ToNot(HaveOccurred())
+
+			Expect(events).To(Receive(Equal("connect")))
+			Expect(events).To(Receive(Equal("disconnect")))
+			Expect(events).To(Receive(Equal("connect")))
+		})
+
+		It("does not call OnDisconnect without a session", func() {
+			Expect(client.Disconnect()).ToNot(HaveOccurred())
+			Expect(events).ToNot(Receive())
+		})
+
+		It("does not call OnConnect when the connection fails", func() {
+			factory.NewReturns(nil, errors.New("nope"))
+
+			Expect(client.Connect()).To(HaveOccurred())
+			Expect(events).ToNot(Receive())
+		})
+
+		It("calls OnError when Listen fails", func() {
+			conn.ListenReturns(errors.New("BOOM"))
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Eventually(events).Should(Receive(Equal("error: BOOM")))
+		})
+	})
+
+	Describe("AutoReconnect", func() {
+		BeforeEach(func() {
+			conn.ListenReturnsOnCall(0, ws.ErrPongTimeout)
+		})
+
+		It("reconnects when the connection fails", func() {
+			client.AutoReconnect = true
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Eventually(factory.NewCallCount).Should(Equal(2))
+			Eventually(conn.ListenCallCount).Should(Equal(2))
+			Expect(client.Send(protocol.NewMessage("foo.bar", map[string]interface{}{}))).ToNot(HaveOccurred())
+		})
+
+		It("does not reconnect when disabled", func() {
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Eventually(conn.ListenCallCount).Should(Equal(1))
+			Consistently(factory.NewCallCount).Should(Equal(1))
+			Expect(client.Send(protocol.NewMessage("foo.bar", map[string]interface{}{}))).To(MatchError(ws.ErrPongTimeout))
+		})
+	})
+
+	Describe("Buffer", func() {
+		newMsg := func(i int) *protocol.Message {
+			return protocol.NewMessage("foo.bar", map[string]interface{}{"i": i})
+		}
+
+		written := func() []int {
+			var is []int
+
+			for i := 0; i < conn.WriteCallCount(); i++ {
+				msg := &protocol.Message{}
+				_, err := msg.UnmarshalMsg(conn.WriteArgsForCall(i))
+				Expect(err).ToNot(HaveOccurred())
+				is = append(is, int(msg.Record.(map[string]interface{})["i"].(int64)))
+			}
+
+			return is
+		}
+
+		tags := func() []string {
+			var tags []string
+
+			for i := 0; i < conn.WriteCallCount(); i++ {
+				msg := &protocol.Message{}
+				_, err := msg.UnmarshalMsg(conn.WriteArgsForCall(i))
+				Expect(err).ToNot(HaveOccurred())
+				tags = append(tags, msg.Tag)
 			}
+
+			return tags
+		}
+
+		BeforeEach(func() {
+			client.Buffer = BufferOptions{Enabled: true, MaxBufferSize: 3}
 		})
 
-		JustBeforeEach(func() {
-			err := client.Connect()
+		It("sends messages queued while disconnected in order after connecting", func() {
+			for i := 0; i < 3; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
+
+			Expect(client.Buffered()).To(Equal(3))
+			Expect(conn.WriteCallCount()).To(Equal(0))
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(client.Send(newMsg(3))).ToNot(HaveOccurred())
+
+			Expect(client.Buffered()).To(Equal(0))
+			Expect(written()).To(Equal([]int{0, 1, 2, 3}))
+		})
+
+		It("prefixes the tags of messages queued while disconnected", func() {
+			client.TagPrefix = "edge."
+
+			Expect(client.Send(newMsg(0))).ToNot(HaveOccurred())
+			Expect(client.Connect()).ToNot(HaveOccurred())
+
+			Expect(tags()).To(Equal([]string{"edge.foo.bar"}))
+		})
+
+		It("transforms the tags of messages queued while disconnected", func() {
+			client.TagTransformer = ChainTagTransformers(LowercaseTag, TruncateTag(6))
+
+			Expect(client.Send(protocol.NewMessage("FOO.BAR", map[string]interface{}{"i": 0}))).ToNot(HaveOccurred())
+			Expect(client.Connect()).ToNot(HaveOccurred())
+
+			Expect(tags()).To(Equal([]string{"foo.ba"}))
+		})
+
+		It("drops the oldest message by default when full", func() {
+			for i := 0; i < 5; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(written()).To(Equal([]int{2, 3, 4}))
+		})
+
+		It("drops the newest message when configured", func() {
+			client.Buffer.OnBufferFull = BufferDropNewest
+
+			for i := 0; i < 5; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
+
+			Expect(client.Connect()).ToNot(HaveOccurred())
+			Expect(written()).To(Equal([]int{0, 1, 2}))
+		})
+
+		It("returns an error when full and configured", func() {
+			client.Buffer.OnBufferFull = BufferReturnError
+
+			for i := 0; i < 3; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
+
+			Expect(client.Send(newMsg(3))).To(MatchError(ErrBufferFull))
+			Expect(client.Buffered()).To(Equal(3))
+		})
+
+		It("drops a message whose write fails while draining and sends the rest", func() {
+			for i := 0; i < 3; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
+
+			conn.WriteReturnsOnCall(1, 0, errors.New("nope"))
+
+			Expect(client.Connect()).To(Succeed())
+			Expect(client.Session()).ToNot(BeNil())
+			Expect(client.Buffered()).To(BeZero())
+			Expect(conn.WriteCallCount()).To(Equal(3))
+
+			msg := &protocol.Message{}
+			_, err := msg.UnmarshalMsg(conn.WriteArgsForCall(2))
 			Expect(err).ToNot(HaveOccurred())
-			time.Sleep(100 * time.Millisecond)
+			Expect(msg.Record).To(HaveKeyWithValue("i", BeNumerically("==", 2)))
 		})
 
-		It("Sends the message", func() {
-			bits, _ := msg.MarshalMsg(nil)
-			Expect(client.Send(&msg)).ToNot(HaveOccurred())
+		It("drops a message that fails to encode while draining and sends the rest", func() {
+			Expect(client.Send(newMsg(0))).ToNot(HaveOccurred())
+			Expect(client.Send(protocol.NewMessage("foo.bar", map[string]interface{}{"i": make(chan int)}))).ToNot(HaveOccurred())
+			Expect(client.Send(newMsg(2))).ToNot(HaveOccurred())
 
-			writtenbits := conn.WriteArgsForCall(0)
-			Expect(bytes.Equal(bits, writtenbits)).To(BeTrue())
+			Expect(client.Connect()).To(Succeed())
+			Expect(client.Session()).ToNot(BeNil())
+			Expect(written()).To(Equal([]int{0, 2}))
 		})
 
-		It("Sends the message", func() {
-			msgBytes, _ := msg.MarshalMsg(nil)
-			Expect(client.Send(&msg)).ToNot(HaveOccurred())
+		It("keeps the rest of the buffer when the connection closes while draining", func() {
+			for i := 0; i < 3; i++ {
+				Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+			}
 
-			writtenBytes := conn.WriteArgsForCall(0)
-			Expect(bytes.Equal(msgBytes, writtenBytes)).To(BeTrue())
+			var closed int32
+			conn.ClosedStub = func() bool { return atomic.LoadInt32(&closed) == 1 }
+			conn.WriteStub = func(data []byte) (int, error) {
+				if conn.WriteCallCount() == 2 {
+					atomic.StoreInt32(&closed, 1)
+					return 0, errors.New("nope")
+				}
+
+				return len(data), nil
+			}
+
+			Expect(client.Connect()).To(Succeed())
+			Expect(conn.WriteCallCount()).To(Equal(2))
+			Expect(client.Buffered()).To(Equal(1))
 		})
 
-		When("The message is large", func() {
-			const charset = "abcdefghijklmnopqrstuvwxyz" + "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		When("spilling to disk", func() {
+			var dir string
 
-			var (
-				expectedBytes int
-				messageSize   = 65536
-			)
+			spilled := func() []os.DirEntry {
+				entries, err := os.ReadDir(dir)
+				Expect(err).ToNot(HaveOccurred())
 
-			JustBeforeEach(func() {
-				seededRand := rand.New(
-					rand.NewSource(time.Now().UnixNano()))
-				m := make([]byte, messageSize)
-				for i := range m {
-					m[i] = charset[seededRand.Intn(len(charset))]
+				return entries
+			}
+
+			BeforeEach(func() {
+				var err error
+				dir, err = os.MkdirTemp("", "ffg")
+				Expect(err).ToNot(HaveOccurred())
+				DeferCleanup(os.RemoveAll, dir)
+
+				client.Buffer.OnBufferFull = BufferSpillToDisk
+				client.Buffer.SpillDir = dir
+			})
+
+			It("sends spilled messages in order after connecting", func() {
+				for i := 0; i < 10; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
 				}
-				msg.Record = m
 
-				var b bytes.Buffer
-				Expect(msgp.Encode(&b, &msg)).ToNot(HaveOccurred())
-				expectedBytes = len(b.Bytes())
+				Expect(client.Buffered()).To(Equal(10))
+				Expect(spilled()).ToNot(BeEmpty())
+
+				Expect(client.Connect()).ToNot(HaveOccurred())
+				Expect(client.Send(newMsg(10))).ToNot(HaveOccurred())
+
+				Expect(written()).To(Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}))
+				Expect(client.Buffered()).To(Equal(0))
+				Expect(spilled()).To(BeEmpty())
 			})
 
-			It("Sends the correct number of bits", func() {
-				Expect(client.Send(&msg)).ToNot(HaveOccurred())
-				Expect(conn.WriteCallCount()).To(Equal(1))
-				writtenBytes := len(conn.WriteArgsForCall(0))
-				Expect(writtenBytes).To(Equal(expectedBytes))
+			It("transforms and prefixes the tags of spilled messages", func() {
+				client.TagPrefix = "edge."
+				client.TagTransformer = ReplaceTagChars(".", "_")
+
+				for i := 0; i < 5; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+				}
+
+				Expect(spilled()).ToNot(BeEmpty())
+
+				Expect(client.Connect()).ToNot(HaveOccurred())
+				Expect(tags()).To(HaveLen(5))
+				Expect(tags()).To(HaveEach("edge.foo_bar"))
 			})
-		})
 
-		When("the connection is disconnected", func() {
-			JustBeforeEach(func() {
-				err := client.Disconnect()
-				Expect(err).ToNot(HaveOccurred())
+			It("blocks sends once SpillDirMaxBytes is reached", func() {
+				client.Buffer.SpillDirMaxBytes = 64
+
+				for i := 0; i < 5; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				defer cancel()
+
+				Expect(client.SendMessageContext(ctx, newMsg(5))).To(MatchError(context.DeadlineExceeded))
+				Expect(client.Buffered()).To(Equal(5))
 			})
 
-			It("returns an error", func() {
-				Expect(client.Send(&msg)).To(MatchError("no active session"))
+			It("unblocks sends as spilled messages are drained", func() {
+				client.Buffer.SpillDirMaxBytes = 64
+
+				for i := 0; i < 5; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+				}
+
+				sent := make(chan error, 1)
+				go func() { sent <- client.Send(newMsg(5)) }()
+				Consistently(sent).ShouldNot(Receive())
+
+				Expect(client.Connect()).ToNot(HaveOccurred())
+				Eventually(sent).Should(Receive(BeNil()))
+				Expect(client.Send(newMsg(6))).ToNot(HaveOccurred())
+				Expect(written()).To(Equal([]int{0, 1, 2, 3, 4, 5, 6}))
 			})
-		})
 
-		When("the connection is closed with an error", func() {
-			BeforeEach(func() {
-				conn.ListenReturns(errors.New("BOOM"))
+			It("refills the buffer from disk in the background as it drains", func() {
+				// one message per file, so that each is deleted as it is
+				// read back
+				client.Buffer.SpillDirMaxBytes = 64
+				client.Buffer.LowWaterMark = 3
+
+				for i := 0; i < 5; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+				}
+
+				Expect(spilled()).To(HaveLen(2))
+
+				release := make(chan struct{})
+				sink, released := conn, release
+				conn.WriteStub = func(data []byte) (int, error) {
+					if sink.WriteCallCount() == 1 {
+						<-released
+					}
+
+					return len(data), nil
+				}
+
+				cli := client
+				connected := make(chan error, 1)
+				go func() { connected <- cli.Connect() }()
+
+				// the first write is blocked with two messages left in
+				// memory, below the mark, so one is read back from disk
+				Eventually(conn.WriteCallCount).Should(Equal(1))
+				Eventually(spilled).Should(HaveLen(1))
+				Expect(client.Buffered()).To(Equal(4))
+
+				close(release)
+				Eventually(connected).Should(Receive(BeNil()))
+				Expect(written()).To(Equal([]int{0, 1, 2, 3, 4}))
 			})
 
-			It("returns the error", func() {
-				Expect(client.Send(&msg)).To(MatchError("BOOM"))
+			It("deletes the spill files on Disconnect", func() {
+				for i := 0; i < 5; i++ {
+					Expect(client.Send(newMsg(i))).ToNot(HaveOccurred())
+				}
+
+				Expect(spilled()).ToNot(BeEmpty())
+
+				Expect(client.Disconnect()).To(Succeed())
+				Expect(spilled()).To(BeEmpty())
+				Expect(client.Buffered()).To(Equal(3))
+
+				Expect(client.Connect()).To(Succeed())
+				Expect(written()).To(Equal([]int{0, 1, 2}))
 			})
 		})
 	})
 
+	Describe("Metrics", func() {
+		var (
+			collector *clientfakes.FakeMetricsCollector
+		)
+
+		BeforeEach(func() {
+			collector = &clientfakes.FakeMetricsCollector{}
+			client.Metrics = collector
+		})
+
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("records sends with the message tag", func() {
+			Expect(client.Send(protocol.NewMessage("foo.bar", map[string]interface{}{}))).ToNot(HaveOccurred())
+			Expect(collector.RecordSendCallCount()).To(Equal(1))
+
+			tag, _, err := collector.RecordSendArgsForCall(0)
+			Expect(tag).To(Equal("foo.bar"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("records failed sends", func() {
+			conn.WriteReturns(0, errors.New("nope"))
+
+			Expect(client.SendRaw([]byte("oi"))).To(MatchError("nope"))
+			Expect(collector.RecordSendCallCount()).To(Equal(1))
+
+			tag, _, err := collector.RecordSendArgsForCall(0)
+			Expect(tag).To(BeEmpty())
+			Expect(err).To(MatchError("nope"))
+		})
+
+		It("records reconnects", func() {
+			Expect(client.Reconnect()).ToNot(HaveOccurred())
+			Expect(collector.RecordReconnectCallCount()).To(Equal(1))
+
+			attempt, err := collector.RecordReconnectArgsForCall(0)
+			Expect(attempt).To(Equal(1))
+			Expect(err).ToNot(HaveOccurred())
+		})
+	})
+
+	Describe("SendMessages", func() {
+		var (
+			entries protocol.EntryList
+		)
+
+		BeforeEach(func() {
+			entries = protocol.EntryList{
+				{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"first": "Sir"}},
+				{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"last": "Gawain"}},
+			}
+		})
+
+		JustBeforeEach(func() {
+			err := client.Connect()
+			Expect(err).ToNot(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+		})
+
+		It("sends the entries as a single forward message", func() {
+			Expect(client.SendMessages("foo.bar", entries, "")).ToNot(HaveOccurred())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+
+			var fm protocol.ForwardMessage
+			_, err := fm.UnmarshalMsg(conn.WriteArgsForCall(0))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fm.Tag).To(Equal("foo.bar"))
+			Expect(fm.Entries.Equal(entries)).To(BeTrue())
+			Expect(*fm.Options.Size).To(Equal(2))
+			Expect(fm.Options.Chunk).To(BeEmpty())
+		})
+
+		It("sets the chunk option when one is given", func() {
+			Expect(client.SendMessages("foo.bar", entries, "abc123")).ToNot(HaveOccurred())
+
+			chunk, err := protocol.GetChunk(conn.WriteArgsForCall(0))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chunk).To(Equal("abc123"))
+		})
+	})
+
 	Describe("SendRaw", func() {
 		var (
 			bits []byte
@@ -465,3 +2335,79 @@ var _ = Describe("WSClient", func() {
 		})
 	})
 })
+
+var _ = Describe("WSClient HotReconnect with a server", func() {
+	var (
+		svr      *httptest.Server
+		received int64
+		upgrades int64
+	)
+
+	BeforeEach(func() {
+		received, upgrades = 0, 0
+
+		svr = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var upgrader websocket.Upgrader
+
+			wc, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer wc.Close()
+
+			atomic.AddInt64(&upgrades, 1)
+
+			for {
+				if _, _, err := wc.ReadMessage(); err != nil {
+					return
+				}
+
+				atomic.AddInt64(&received, 1)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		svr.Close()
+	})
+
+	It("does not fail sends made while it reconnects", func() {
+		cli := fclient.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+		})
+
+		Expect(cli.Connect()).To(Succeed())
+		defer cli.Disconnect()
+
+		const sends = 2000
+
+		done := make(chan struct{})
+
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+
+			for i := 0; i < sends; i++ {
+				Expect(cli.Send(protocol.NewMessage("tag", map[string]interface{}{"i": i}))).To(Succeed())
+			}
+		}()
+
+		for reconnects := 0; reconnects < 5; reconnects++ {
+			Expect(cli.HotReconnect(context.Background())).To(Succeed())
+		}
+
+		Eventually(done, 10*time.Second).Should(BeClosed())
+		Eventually(func() int64 { return atomic.LoadInt64(&received) }).Should(BeEquivalentTo(sends))
+		Expect(atomic.LoadInt64(&upgrades)).To(BeEquivalentTo(6))
+	})
+})
+
+// asyncWriters counts the goroutines writing SendMessageAsync queues.
+func asyncWriters() int {
+	var buf bytes.Buffer
+	_ = pprof.Lookup("goroutine").WriteTo(&buf, 2)
+
+	return strings.Count(buf.String(), "(*WSClient).asyncWriter")
+}
@@ -0,0 +1,144 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package avro_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/IBM/fluent-forward-go/fluent/codec/avro"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const schema = `{
+	"type": "record",
+	"name": "LogLine",
+	"fields": [
+		{"name": "message", "type": "string"},
+		{"name": "level", "type": "int"}
+	]
+}`
+
+var _ = Describe("AvroEncoder", func() {
+	var (
+		enc    *AvroEncoder
+		record map[string]interface{}
+	)
+
+	decode := func(b []byte) interface{} {
+		native, rest, err := enc.Codec.NativeFromBinary(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rest).To(BeEmpty())
+
+		return native
+	}
+
+	BeforeEach(func() {
+		var err error
+		enc, err = NewAvroEncoder(schema)
+		Expect(err).ToNot(HaveOccurred())
+
+		record = map[string]interface{}{"message": "oi", "level": 3}
+	})
+
+	It("rejects an invalid schema", func() {
+		_, err := NewAvroEncoder(`{"type": "record"}`)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("encodes records in binary Avro", func() {
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, record)).To(Succeed())
+		Expect(decode(buf.Bytes())).To(Equal(map[string]interface{}{"message": "oi", "level": int32(3)}))
+		Expect(enc.ContentType()).To(Equal(ContentType))
+	})
+
+	It("encodes the record of a protocol message", func() {
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, &protocol.Message{Tag: "foo", Record: record})).To(Succeed())
+		Expect(decode(buf.Bytes())).To(HaveKeyWithValue("message", "oi"))
+	})
+
+	It("rejects values without a record map", func() {
+		Expect(enc.Encode(&bytes.Buffer{}, "oi")).ToNot(Succeed())
+		Expect(enc.Encode(&bytes.Buffer{}, &protocol.Message{Record: []string{"oi"}})).ToNot(Succeed())
+	})
+
+	DescribeTable("rejects records that do not fit the schema without writing",
+		func(record map[string]interface{}) {
+			var buf bytes.Buffer
+			Expect(enc.Encode(&buf, record)).To(MatchError(ContainSubstring("invalid record")))
+			Expect(buf.Len()).To(BeZero())
+		},
+		Entry("a missing field", map[string]interface{}{"message": "oi"}),
+		Entry("a field of the wrong type", map[string]interface{}{"message": "oi", "level": "high"}),
+	)
+
+	Describe("as a WSClient's encoder", func() {
+		var (
+			conn *wsfakes.FakeConnection
+			cli  *client.WSClient
+		)
+
+		BeforeEach(func() {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn = &wsfakes.FakeConnection{}
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&client.WSSession{Connection: conn})
+
+			cli = client.NewWS(client.WSConnectionOptions{
+				Factory: factory,
+				Codec:   enc,
+			})
+			Expect(cli.Connect()).To(Succeed())
+		})
+
+		It("encodes the records sent as the Codec", func() {
+			msg := protocol.NewMessage("foo", record)
+			msg.Timestamp = time.Now().Unix()
+
+			Expect(cli.Send(msg)).To(Succeed())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+			Expect(decode(conn.WriteArgsForCall(0))).To(HaveKeyWithValue("level", int32(3)))
+		})
+
+		It("encodes the records sent as a MessageEncoder", func() {
+			Expect(cli.SendMessage(enc.Record(record))).To(Succeed())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+			Expect(decode(conn.WriteArgsForCall(0))).To(HaveKeyWithValue("message", "oi"))
+		})
+
+		It("fails a send of an invalid record", func() {
+			Expect(cli.SendMessage(enc.Record(map[string]interface{}{}))).ToNot(Succeed())
+			Expect(conn.WriteCallCount()).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,303 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package gelf formats events as GELF 1.1 messages and sends them to
+// Graylog over UDP or TCP.
+package gelf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	ContentType = "application/json"
+	Version     = "1.1"
+)
+
+// severities are the syslog levels GELF uses, by name.
+var severities = map[string]int{
+	"emerg": 0, "emergency": 0, "alert": 1, "crit": 2, "critical": 2,
+	"err": 3, "error": 3, "warning": 4, "warn": 4, "notice": 5, "info": 6,
+	"informational": 6, "debug": 7,
+}
+
+// invalidFieldChars are those GELF does not allow in additional field
+// names.
+var invalidFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+// GELFEncoder converts records to GELF messages. The "host",
+// "short_message", "full_message", "timestamp" and "level" fields of a
+// record are mapped to the GELF fields of the same name; "message" or
+// "log" stands in for a missing "short_message", and the event time for a
+// missing "timestamp". A level may be a syslog severity's number or name.
+//
+// The other fields are sent as additional fields, prefixed with "_", and
+// the tag as "_tag". Maps and slices are sent as JSON strings, since GELF
+// only allows strings and numbers, and "id", which GELF reserves, is sent
+// as "__id".
+type GELFEncoder struct {
+	// Host is the host of records without a "host" field. NewGELFEncoder
+	// sets it to the hostname.
+	Host string
+}
+
+func NewGELFEncoder() *GELFEncoder {
+	host, _ := os.Hostname()
+
+	return &GELFEncoder{Host: host}
+}
+
+func (e *GELFEncoder) ContentType() string {
+	return ContentType
+}
+
+// Encode writes the GELF messages of the events of v, which may be a
+// *protocol.Message, *protocol.MessageExt, *protocol.ForwardMessage or a
+// packed forward message, each followed by a null byte as GELF over TCP
+// frames them.
+func (e *GELFEncoder) Encode(w io.Writer, v interface{}) error {
+	msgs, err := e.Messages(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	for _, msg := range msgs {
+		buf.Write(msg)
+		buf.WriteByte(0)
+	}
+
+	_, err = w.Write(buf.Bytes())
+
+	return err
+}
+
+// Messages returns the GELF message of each event of v.
+func (e *GELFEncoder) Messages(v interface{}) ([][]byte, error) {
+	tag, entries, err := entriesOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([][]byte, len(entries))
+
+	for i, entry := range entries {
+		record, ok := entry.Record.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("gelf: cannot encode record of type %T", entry.Record)
+		}
+
+		if msgs[i], err = e.Marshal(tag, entry.Timestamp.Time, record); err != nil {
+			return nil, err
+		}
+	}
+
+	return msgs, nil
+}
+
+// Marshal returns the GELF message of a single event.
+func (e *GELFEncoder) Marshal(tag string, t time.Time, record map[string]interface{}) ([]byte, error) {
+	msg := map[string]interface{}{"version": Version}
+
+	var (
+		shortKey     string
+		shortMessage interface{}
+	)
+
+	for _, key := range []string{"short_message", "message", "log"} {
+		if v, ok := record[key]; ok {
+			shortKey, shortMessage = key, v
+			break
+		}
+	}
+
+	if shortMessage == nil || stringOf(shortMessage) == "" {
+		return nil, errors.New("gelf: record has no short_message")
+	}
+
+	msg["short_message"] = stringOf(shortMessage)
+
+	host := e.Host
+	if v, ok := record["host"]; ok {
+		host = stringOf(v)
+	}
+
+	if host == "" {
+		return nil, errors.New("gelf: record has no host")
+	}
+
+	msg["host"] = host
+
+	if v, ok := record["timestamp"]; ok {
+		ts, err := toFloat(v)
+		if err != nil {
+			return nil, fmt.Errorf("gelf: timestamp: %w", err)
+		}
+
+		msg["timestamp"] = ts
+	} else if !t.IsZero() {
+		msg["timestamp"] = float64(t.UnixMilli()) / 1000
+	}
+
+	if v, ok := record["level"]; ok {
+		level, err := toLevel(v)
+		if err != nil {
+			return nil, fmt.Errorf("gelf: level: %w", err)
+		}
+
+		msg["level"] = level
+	}
+
+	if v, ok := record["full_message"]; ok {
+		msg["full_message"] = stringOf(v)
+	}
+
+	if tag != "" {
+		msg["_tag"] = tag
+	}
+
+	for key, v := range record {
+		switch key {
+		case shortKey, "host", "full_message", "timestamp", "level":
+			continue
+		}
+
+		msg[fieldName(key)] = fieldValue(v)
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("gelf: %w", err)
+	}
+
+	return b, nil
+}
+
+func fieldName(key string) string {
+	if key == "id" {
+		return "__id"
+	}
+
+	return "_" + invalidFieldChars.ReplaceAllString(key, "_")
+}
+
+func fieldValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return v
+	default:
+		return stringOf(v)
+	}
+}
+
+func stringOf(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	default:
+		return strconv.ParseFloat(fmt.Sprint(v), 64)
+	}
+}
+
+func toLevel(v interface{}) (int, error) {
+	s := stringOf(v)
+	if n, ok := severities[strings.ToLower(s)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 7 {
+		return 0, fmt.Errorf("unknown level %q", s)
+	}
+
+	return n, nil
+}
+
+func entriesOf(v interface{}) (string, protocol.EntryList, error) {
+	switch msg := v.(type) {
+	case *protocol.Message:
+		return msg.Tag, protocol.EntryList{{
+			Timestamp: protocol.EventTime{Time: time.Unix(msg.Timestamp, 0)},
+			Record:    msg.Record,
+		}}, nil
+	case *protocol.MessageExt:
+		return msg.Tag, protocol.EntryList{{Timestamp: msg.Timestamp, Record: msg.Record}}, nil
+	case *protocol.ForwardMessage:
+		return msg.Tag, msg.Entries, nil
+	case *protocol.CompressedPackedForwardMessage:
+		// the wrapped event stream is not compressed until it is encoded
+		return unpack(msg.PackedForwardMessage)
+	case *protocol.PackedForwardMessage:
+		if msg.Options != nil && msg.Options.Compressed != "" {
+			return "", nil, errors.New("gelf: cannot encode a compressed event stream")
+		}
+
+		return unpack(msg)
+	default:
+		return "", nil, fmt.Errorf("gelf: cannot encode %T", v)
+	}
+}
+
+func unpack(msg *protocol.PackedForwardMessage) (string, protocol.EntryList, error) {
+	var entries protocol.EntryList
+	if _, err := entries.UnmarshalPacked(msg.EventStream); err != nil {
+		return "", nil, fmt.Errorf("gelf: decode event stream: %w", err)
+	}
+
+	return msg.Tag, entries, nil
+}
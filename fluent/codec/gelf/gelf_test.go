@@ -0,0 +1,127 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package gelf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	. "github.com/IBM/fluent-forward-go/fluent/codec/gelf"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GELFEncoder", func() {
+	var enc *GELFEncoder
+
+	at := time.Unix(1646136000, 250e6)
+
+	marshal := func(record map[string]interface{}) map[string]interface{} {
+		b, err := enc.Marshal("app.web", at, record)
+		Expect(err).ToNot(HaveOccurred())
+
+		var msg map[string]interface{}
+		Expect(json.Unmarshal(b, &msg)).To(Succeed())
+
+		return msg
+	}
+
+	BeforeEach(func() {
+		enc = &GELFEncoder{Host: "web-1"}
+	})
+
+	It("maps the well-known fields and prefixes the rest", func() {
+		Expect(marshal(map[string]interface{}{
+			"short_message": "request failed",
+			"full_message":  "request failed\nstack...",
+			"level":         "error",
+			"host":          "web-2",
+			"status":        500,
+			"user.name":     "ana",
+			"bad key!":      true,
+			"id":            "abc",
+			"ctx":           map[string]interface{}{"a": 1},
+		})).To(Equal(map[string]interface{}{
+			"version":       "1.1",
+			"host":          "web-2",
+			"short_message": "request failed",
+			"full_message":  "request failed\nstack...",
+			"timestamp":     1646136000.25,
+			"level":         3.0,
+			"_tag":          "app.web",
+			"_status":       500.0,
+			"_user.name":    "ana",
+			"_bad_key_":     "true",
+			"__id":          "abc",
+			"_ctx":          `{"a":1}`,
+		}))
+	})
+
+	It("falls back to message or log, the encoder's host and the event time", func() {
+		msg := marshal(map[string]interface{}{"message": "oi", "log": "raw line"})
+		Expect(msg).To(HaveKeyWithValue("short_message", "oi"))
+		Expect(msg).To(HaveKeyWithValue("_log", "raw line"))
+		Expect(msg).To(HaveKeyWithValue("host", "web-1"))
+		Expect(msg).To(HaveKeyWithValue("timestamp", 1646136000.25))
+		Expect(msg).ToNot(HaveKey("level"))
+
+		Expect(marshal(map[string]interface{}{"log": "raw line", "timestamp": 1.5, "level": 4})).To(And(
+			HaveKeyWithValue("short_message", "raw line"),
+			HaveKeyWithValue("timestamp", 1.5),
+			HaveKeyWithValue("level", 4.0),
+		))
+	})
+
+	It("rejects records it cannot make valid GELF of", func() {
+		_, err := enc.Marshal("app", at, map[string]interface{}{"status": 1})
+		Expect(err).To(MatchError(ContainSubstring("short_message")))
+
+		_, err = enc.Marshal("app", at, map[string]interface{}{"message": "oi", "level": "loud"})
+		Expect(err).To(MatchError(ContainSubstring("level")))
+
+		_, err = (&GELFEncoder{}).Marshal("app", at, map[string]interface{}{"message": "oi"})
+		Expect(err).To(MatchError(ContainSubstring("host")))
+	})
+
+	It("encodes each event followed by a null byte", func() {
+		entries := protocol.EntryList{
+			{Timestamp: protocol.EventTime{Time: at}, Record: map[string]interface{}{"message": "one"}},
+			{Timestamp: protocol.EventTime{Time: at}, Record: map[string]interface{}{"message": "two"}},
+		}
+
+		msg, err := protocol.NewPackedForwardMessage("app", entries)
+		Expect(err).ToNot(HaveOccurred())
+
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, msg)).To(Succeed())
+
+		frames := bytes.Split(buf.Bytes(), []byte{0})
+		Expect(frames).To(HaveLen(3))
+		Expect(frames[2]).To(BeEmpty())
+		Expect(string(frames[1])).To(ContainSubstring(`"short_message":"two"`))
+	})
+})
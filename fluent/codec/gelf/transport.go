@@ -0,0 +1,363 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	// DefaultChunkSize is the largest UDP datagram sent, as Graylog
+	// recommends for networks whose MTU is not known.
+	DefaultChunkSize = 1420
+	// MaxChunks is the most chunks GELF allows a message.
+	MaxChunks = 128
+	// chunkHeaderSize is the size of the magic bytes, message ID, sequence
+	// number and sequence count that start each chunk.
+	chunkHeaderSize = 12
+)
+
+// chunkMagic starts each chunk of a chunked GELF message.
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// Compression is how GELFTransportUDP compresses its messages.
+type Compression uint8
+
+const (
+	CompressNone Compression = iota
+	CompressGzip
+	CompressZlib
+)
+
+type UDPOptions struct {
+	Address     string
+	Encoder     *GELFEncoder
+	ChunkSize   int
+	Compression Compression
+}
+
+// GELFTransportUDP sends GELF messages over UDP, split into chunks when
+// they do not fit in a datagram. Delivery is best effort, as for
+// udp.UDPClient; the socket is opened on the first send and released by
+// Close.
+type GELFTransportUDP struct {
+	// Address is the host:port of the Graylog GELF UDP input.
+	Address string
+	// Encoder converts messages to GELF. If nil, NewGELFEncoder is used.
+	Encoder *GELFEncoder
+	// ChunkSize is the largest datagram sent. If zero, DefaultChunkSize is
+	// used. Messages that need more than MaxChunks chunks are rejected.
+	ChunkSize int
+	// Compression is applied to each message before it is chunked.
+	Compression Compression
+	conn        net.Conn
+	connLock    sync.Mutex
+}
+
+func NewGELFTransportUDP(opts UDPOptions) *GELFTransportUDP {
+	if opts.Encoder == nil {
+		opts.Encoder = NewGELFEncoder()
+	}
+
+	return &GELFTransportUDP{
+		Address:     opts.Address,
+		Encoder:     opts.Encoder,
+		ChunkSize:   opts.ChunkSize,
+		Compression: opts.Compression,
+	}
+}
+
+// Send sends the GELF message of each event of e.
+func (t *GELFTransportUDP) Send(e protocol.ChunkEncoder) error {
+	return sendEach(t.Encoder, e, t.SendRaw)
+}
+
+// SendMessage sends a single event, timestamped now.
+func (t *GELFTransportUDP) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessageExt(tag, record))
+}
+
+// SendRaw sends raw, which must be a GELF message, compressed and chunked
+// as the transport is configured.
+func (t *GELFTransportUDP) SendRaw(raw []byte) error {
+	msg, err := compress(raw, t.Compression)
+	if err != nil {
+		return err
+	}
+
+	datagrams, err := chunk(msg, t.chunkSize())
+	if err != nil {
+		return err
+	}
+
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if t.conn == nil {
+		conn, err := net.Dial("udp", t.Address)
+		if err != nil {
+			return err
+		}
+
+		t.conn = conn
+	}
+
+	for _, d := range datagrams {
+		if _, err := t.conn.Write(d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *GELFTransportUDP) chunkSize() int {
+	if t.ChunkSize <= 0 {
+		return DefaultChunkSize
+	}
+
+	return t.ChunkSize
+}
+
+// Close releases the socket. A later send opens a new one.
+func (t *GELFTransportUDP) Close() (err error) {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if t.conn != nil {
+		err = t.conn.Close()
+		t.conn = nil
+	}
+
+	return
+}
+
+func compress(msg []byte, c Compression) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+
+	switch c {
+	case CompressNone:
+		return msg, nil
+	case CompressGzip:
+		w = gzip.NewWriter(&buf)
+	case CompressZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("gelf: unknown compression %d", c)
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// chunk splits msg into datagrams of at most size bytes. A message that
+// fits is sent whole, without a chunk header.
+func chunk(msg []byte, size int) ([][]byte, error) {
+	if len(msg) <= size {
+		return [][]byte{msg}, nil
+	}
+
+	payload := size - chunkHeaderSize
+	if payload <= 0 {
+		return nil, fmt.Errorf("gelf: chunk size %d is too small", size)
+	}
+
+	count := (len(msg) + payload - 1) / payload
+	if count > MaxChunks {
+		return nil, fmt.Errorf("gelf: %d byte message needs %d chunks, more than %d: %w",
+			len(msg), count, MaxChunks, client.ErrMessageTooLarge)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("gelf: message id: %w", err)
+	}
+
+	chunks := make([][]byte, 0, count)
+
+	for seq := 0; seq < count; seq++ {
+		end := (seq + 1) * payload
+		if end > len(msg) {
+			end = len(msg)
+		}
+
+		c := make([]byte, 0, chunkHeaderSize+end-seq*payload)
+		c = append(c, chunkMagic...)
+		c = append(c, id...)
+		c = append(c, byte(seq), byte(count))
+		c = append(c, msg[seq*payload:end]...)
+
+		chunks = append(chunks, c)
+	}
+
+	return chunks, nil
+}
+
+type TCPOptions struct {
+	Address     string
+	Encoder     *GELFEncoder
+	DialTimeout time.Duration
+}
+
+// GELFTransportTCP streams GELF messages over TCP, each followed by a null
+// byte. The connection is opened by Connect, or by the first send, and
+// one that fails a write is closed, so that the next send opens another.
+type GELFTransportTCP struct {
+	// Address is the host:port of the Graylog GELF TCP input.
+	Address string
+	// Encoder converts messages to GELF. If nil, NewGELFEncoder is used.
+	Encoder *GELFEncoder
+	// DialTimeout bounds each dial. If zero, there is no timeout.
+	DialTimeout time.Duration
+	conn        net.Conn
+	connLock    sync.Mutex
+}
+
+func NewGELFTransportTCP(opts TCPOptions) *GELFTransportTCP {
+	if opts.Encoder == nil {
+		opts.Encoder = NewGELFEncoder()
+	}
+
+	return &GELFTransportTCP{
+		Address:     opts.Address,
+		Encoder:     opts.Encoder,
+		DialTimeout: opts.DialTimeout,
+	}
+}
+
+// Connect opens the connection, if it is not open.
+func (t *GELFTransportTCP) Connect() error {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	return t.connect()
+}
+
+func (t *GELFTransportTCP) connect() error {
+	if t.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", t.Address, t.DialTimeout)
+	if err != nil {
+		return err
+	}
+
+	t.conn = conn
+
+	return nil
+}
+
+// Send sends the GELF message of each event of e.
+func (t *GELFTransportTCP) Send(e protocol.ChunkEncoder) error {
+	return sendEach(t.Encoder, e, t.SendRaw)
+}
+
+// SendMessage sends a single event, timestamped now.
+func (t *GELFTransportTCP) SendMessage(tag string, record interface{}) error {
+	return t.Send(protocol.NewMessageExt(tag, record))
+}
+
+// SendRaw sends raw, which must be a GELF message without null bytes,
+// followed by the null byte that frames it.
+func (t *GELFTransportTCP) SendRaw(raw []byte) error {
+	if bytes.IndexByte(raw, 0) >= 0 {
+		return errors.New("gelf: message contains a null byte")
+	}
+
+	frame := make([]byte, 0, len(raw)+1)
+	frame = append(frame, raw...)
+	frame = append(frame, 0)
+
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if err := t.connect(); err != nil {
+		return err
+	}
+
+	if _, err := t.conn.Write(frame); err != nil {
+		t.conn.Close()
+		t.conn = nil
+
+		return err
+	}
+
+	return nil
+}
+
+// Close closes the connection. A later send opens a new one.
+func (t *GELFTransportTCP) Close() (err error) {
+	t.connLock.Lock()
+	defer t.connLock.Unlock()
+
+	if t.conn != nil {
+		err = t.conn.Close()
+		t.conn = nil
+	}
+
+	return
+}
+
+func sendEach(enc *GELFEncoder, e protocol.ChunkEncoder, send func([]byte) error) error {
+	if enc == nil {
+		enc = NewGELFEncoder()
+	}
+
+	msgs, err := enc.Messages(e)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package gelf_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/codec/gelf"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	_ client.MessageSender = &GELFTransportUDP{}
+	_ client.MessageSender = &GELFTransportTCP{}
+)
+
+var _ = Describe("GELFTransportUDP", func() {
+	var (
+		pc        net.PacketConn
+		transport *GELFTransportUDP
+	)
+
+	read := func() []byte {
+		buf := make([]byte, 65536)
+		n, _, err := pc.ReadFrom(buf)
+		Expect(err).ToNot(HaveOccurred())
+
+		return buf[:n]
+	}
+
+	BeforeEach(func() {
+		var err error
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		transport = NewGELFTransportUDP(UDPOptions{
+			Address: pc.LocalAddr().String(),
+			Encoder: &GELFEncoder{Host: "web-1"},
+		})
+	})
+
+	AfterEach(func() {
+		Expect(transport.Close()).To(Succeed())
+		pc.Close()
+	})
+
+	It("sends a small message in a single datagram", func() {
+		Expect(transport.SendMessage("app", map[string]interface{}{"message": "oi"})).To(Succeed())
+
+		var msg map[string]interface{}
+		Expect(json.Unmarshal(read(), &msg)).To(Succeed())
+		Expect(msg).To(HaveKeyWithValue("short_message", "oi"))
+	})
+
+	It("chunks a large message", func() {
+		transport.ChunkSize = 100
+		long := strings.Repeat("x", 1000)
+
+		Expect(transport.SendMessage("app", map[string]interface{}{"message": long})).To(Succeed())
+
+		var (
+			id      []byte
+			payload bytes.Buffer
+		)
+
+		first := read()
+		count := int(first[11])
+		Expect(count).To(BeNumerically(">", 1))
+
+		for seq := 0; seq < count; seq++ {
+			d := first
+			if seq > 0 {
+				d = read()
+			}
+
+			Expect(len(d)).To(BeNumerically("<=", 100))
+			Expect(d[:2]).To(Equal([]byte{0x1e, 0x0f}))
+
+			if id == nil {
+				id = d[2:10]
+			}
+
+			Expect(d[2:10]).To(Equal(id))
+			Expect(int(d[10])).To(Equal(seq))
+			Expect(int(d[11])).To(Equal(count))
+
+			payload.Write(d[12:])
+		}
+
+		var msg map[string]interface{}
+		Expect(json.Unmarshal(payload.Bytes(), &msg)).To(Succeed())
+		Expect(msg).To(HaveKeyWithValue("short_message", long))
+	})
+
+	It("compresses messages", func() {
+		transport.Compression = CompressGzip
+		Expect(transport.SendMessage("app", map[string]interface{}{"message": "oi"})).To(Succeed())
+
+		r, err := gzip.NewReader(bytes.NewReader(read()))
+		Expect(err).ToNot(HaveOccurred())
+
+		b, err := io.ReadAll(r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(b)).To(ContainSubstring(`"short_message":"oi"`))
+	})
+
+	It("rejects messages that need too many chunks", func() {
+		transport.ChunkSize = 20
+		err := transport.SendRaw(bytes.Repeat([]byte("x"), 8*MaxChunks+1))
+		Expect(err).To(MatchError(client.ErrMessageTooLarge))
+	})
+})
+
+var _ = Describe("GELFTransportTCP", func() {
+	var (
+		l         net.Listener
+		frames    chan string
+		transport *GELFTransportTCP
+	)
+
+	BeforeEach(func() {
+		var err error
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		frames = make(chan string, 10)
+
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					defer conn.Close()
+
+					r := bufio.NewReader(conn)
+					for {
+						frame, err := r.ReadString(0)
+						if err != nil {
+							return
+						}
+
+						frames <- frame
+					}
+				}()
+			}
+		}()
+
+		transport = NewGELFTransportTCP(TCPOptions{
+			Address: l.Addr().String(),
+			Encoder: &GELFEncoder{Host: "web-1"},
+		})
+	})
+
+	AfterEach(func() {
+		Expect(transport.Close()).To(Succeed())
+		l.Close()
+	})
+
+	It("writes each event followed by a null byte", func() {
+		Expect(transport.Connect()).To(Succeed())
+		Expect(transport.Send(protocol.NewForwardMessage("app", protocol.EntryList{
+			{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"message": "one"}},
+			{Timestamp: protocol.EventTimeNow(), Record: map[string]interface{}{"message": "two"}},
+		}))).To(Succeed())
+
+		Eventually(frames).Should(Receive(And(ContainSubstring(`"short_message":"one"`), HaveSuffix("\x00"))))
+		Eventually(frames).Should(Receive(ContainSubstring(`"short_message":"two"`)))
+	})
+
+	It("rejects raw messages containing a null byte", func() {
+		Expect(transport.SendRaw([]byte("{\x00}"))).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,173 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package ndjson encodes events as newline-delimited JSON, one object per
+// event, for streaming consumers such as Elasticsearch and BigQuery.
+package ndjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const ContentType = "application/x-ndjson"
+
+// NDJSONEncoder writes each event's record as a JSON object on a line of
+// its own. It can be used as a WSClient's Codec: the events of a forward
+// or packed forward message, such as a BatchingClient's batch, are written
+// as consecutive lines in a single Write.
+//
+// Only the records are written; the tag and options are not.
+type NDJSONEncoder struct {
+	// TimestampField, if not empty, is the key that each event's time is
+	// added to the object under, in RFC 3339 format with nanoseconds.
+	TimestampField string
+}
+
+func (e NDJSONEncoder) ContentType() string {
+	return ContentType
+}
+
+// Encode writes the events of v, which may be a *protocol.Message,
+// *protocol.MessageExt, *protocol.ForwardMessage, *protocol.PackedForwardMessage
+// or *protocol.CompressedPackedForwardMessage. Any other value is written
+// as a single record, without a time.
+func (e NDJSONEncoder) Encode(w io.Writer, v interface{}) error {
+	entries, err := eventsOf(v)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	for _, entry := range entries {
+		record, err := e.record(entry)
+		if err != nil {
+			return err
+		}
+
+		// Encode ends each object with the newline
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("ndjson: %w", err)
+		}
+	}
+
+	_, err = w.Write(buf.Bytes())
+
+	return err
+}
+
+// event is a record and the time it happened, if known.
+type event struct {
+	time   time.Time
+	record interface{}
+}
+
+func (e NDJSONEncoder) record(ev event) (interface{}, error) {
+	if e.TimestampField == "" || ev.time.IsZero() {
+		return ev.record, nil
+	}
+
+	m, ok := ev.record.(map[string]interface{})
+	if !ok {
+		var err error
+		if m, err = toMap(ev.record); err != nil {
+			return nil, err
+		}
+	}
+
+	// copy, to leave the caller's record as it was
+	withTime := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		withTime[k] = v
+	}
+
+	withTime[e.TimestampField] = ev.time.UTC().Format(time.RFC3339Nano)
+
+	return withTime, nil
+}
+
+// toMap converts a struct record to the map of its JSON fields, so that the
+// time can be added to it.
+func toMap(record interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("ndjson: %w", err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("ndjson: record of type %T is not an object", record)
+	}
+
+	return m, nil
+}
+
+func eventsOf(v interface{}) ([]event, error) {
+	switch msg := v.(type) {
+	case *protocol.Message:
+		return []event{{time.Unix(msg.Timestamp, 0), msg.Record}}, nil
+	case *protocol.MessageExt:
+		return []event{{msg.Timestamp.Time, msg.Record}}, nil
+	case *protocol.ForwardMessage:
+		return entryEvents(msg.Entries), nil
+	case *protocol.CompressedPackedForwardMessage:
+		// the wrapped event stream is not compressed until it is encoded
+		return packedEvents(msg.EventStream)
+	case *protocol.PackedForwardMessage:
+		if msg.Options != nil && msg.Options.Compressed != "" {
+			return nil, fmt.Errorf("ndjson: cannot encode %s-compressed event stream", msg.Options.Compressed)
+		}
+
+		return packedEvents(msg.EventStream)
+	default:
+		return []event{{record: v}}, nil
+	}
+}
+
+func packedEvents(stream []byte) ([]event, error) {
+	var entries protocol.EntryList
+	if _, err := entries.UnmarshalPacked(stream); err != nil {
+		return nil, fmt.Errorf("ndjson: decode event stream: %w", err)
+	}
+
+	return entryEvents(entries), nil
+}
+
+func entryEvents(entries protocol.EntryList) []event {
+	events := make([]event, len(entries))
+	for i, entry := range entries {
+		events[i] = event{entry.Timestamp.Time, entry.Record}
+	}
+
+	return events
+}
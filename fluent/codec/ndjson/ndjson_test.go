@@ -0,0 +1,136 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package ndjson_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/IBM/fluent-forward-go/fluent/codec/ndjson"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NDJSONEncoder", func() {
+	fixture := func(name string) string {
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(b)
+	}
+
+	at := func(value string) protocol.EventTime {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		Expect(err).ToNot(HaveOccurred())
+
+		return protocol.EventTime{Time: t}
+	}
+
+	entries := func() protocol.EntryList {
+		return protocol.EntryList{
+			{Timestamp: at("2022-03-01T12:00:00Z"), Record: map[string]interface{}{"level": "info", "message": "started"}},
+			{Timestamp: at("2022-03-01T13:00:01.5+01:00"), Record: map[string]interface{}{"level": "warn", "message": "slow <request>", "ms": 1500}},
+			{Timestamp: at("2022-03-01T12:00:02.000000123Z"), Record: map[string]interface{}{"level": "info", "message": "done", "tags": []interface{}{"a", "b"}}},
+		}
+	}
+
+	encode := func(enc NDJSONEncoder, v interface{}) string {
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, v)).To(Succeed())
+
+		return buf.String()
+	}
+
+	It("writes each event of a forward message on its own line", func() {
+		enc := NDJSONEncoder{TimestampField: "@timestamp"}
+		Expect(encode(enc, protocol.NewForwardMessage("foo", entries()))).To(Equal(fixture("forward.ndjson")))
+	})
+
+	It("writes the events of a packed forward message", func() {
+		msg, err := protocol.NewPackedForwardMessage("foo", entries())
+		Expect(err).ToNot(HaveOccurred())
+
+		enc := NDJSONEncoder{TimestampField: "@timestamp"}
+		Expect(encode(enc, msg)).To(Equal(fixture("forward.ndjson")))
+	})
+
+	It("rejects a compressed event stream", func() {
+		msg, err := protocol.NewPackedForwardMessage("foo", entries())
+		Expect(err).ToNot(HaveOccurred())
+		msg.Options = &protocol.MessageOptions{Compressed: protocol.OptValGZIP}
+
+		Expect(NDJSONEncoder{}.Encode(&bytes.Buffer{}, msg)).To(MatchError(ContainSubstring("compressed")))
+	})
+
+	It("leaves out the time unless TimestampField is set", func() {
+		record := map[string]interface{}{"message": "oi"}
+		Expect(encode(NDJSONEncoder{}, protocol.NewMessage("foo", record))).To(Equal(`{"message":"oi"}` + "\n"))
+
+		msg := &protocol.Message{Tag: "foo", Timestamp: 1646136000, Record: record}
+		Expect(encode(NDJSONEncoder{TimestampField: "time"}, msg)).To(Equal(`{"message":"oi","time":"2022-03-01T12:00:00Z"}` + "\n"))
+		Expect(record).ToNot(HaveKey("time"))
+	})
+
+	It("adds the time to struct records", func() {
+		msg := &protocol.Message{Tag: "foo", Timestamp: 1646136000, Record: struct {
+			Message string `json:"message"`
+		}{"oi"}}
+
+		Expect(encode(NDJSONEncoder{TimestampField: "time"}, msg)).To(Equal(`{"message":"oi","time":"2022-03-01T12:00:00Z"}` + "\n"))
+	})
+
+	It("writes other values as a single record", func() {
+		Expect(encode(NDJSONEncoder{TimestampField: "time"}, []int{1, 2})).To(Equal("[1,2]\n"))
+	})
+
+	It("writes a BatchingClient's batch in a single write", func() {
+		factory := &clientfakes.FakeWSConnectionFactory{}
+		conn := &wsfakes.FakeConnection{}
+		factory.NewReturns(&extfakes.FakeConn{}, nil)
+		factory.NewSessionReturns(&client.WSSession{Connection: conn})
+
+		ws := client.NewWS(client.WSConnectionOptions{
+			Factory: factory,
+			Codec:   NDJSONEncoder{},
+		})
+		Expect(ws.Connect()).To(Succeed())
+
+		batching := client.NewBatchingClient(ws)
+		for n := 1; n <= 3; n++ {
+			Expect(batching.SendMessage("foo", map[string]interface{}{"service": "api", "n": n})).To(Succeed())
+		}
+
+		Expect(batching.Close()).To(Succeed())
+		Expect(conn.WriteCallCount()).To(Equal(1))
+		Expect(string(conn.WriteArgsForCall(0))).To(Equal(fixture("batch.ndjson")))
+	})
+})
@@ -0,0 +1,151 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package proto encodes records as Protocol Buffers, for downstream
+// consumers that read proto rather than MessagePack, e.g. gRPC-based log
+// collectors.
+package proto
+
+import (
+	"fmt"
+	"io"
+
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/codec/proto/envelopepb"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const ContentType = "application/x-protobuf"
+
+// RecordToProto converts a record to the proto message that represents it.
+// MessagePack records have no schema, so the caller decides how their
+// fields map onto the message's.
+type RecordToProto func(record map[string]interface{}) (gproto.Message, error)
+
+// ProtoEncoder encodes records as proto messages of Type, converted by
+// RecordToProto. The tag and chunk of each message have no place in the
+// record's type, so every encoded message is an envelopepb.Envelope that
+// holds them, with the record as an Any.
+//
+// As a WSClient's Codec, it encodes each Message or MessageExt sent; the
+// time is not written.
+type ProtoEncoder struct {
+	// Type is the type RecordToProto must return. Records converted to
+	// another type fail to encode.
+	Type          protoreflect.MessageType
+	RecordToProto RecordToProto
+}
+
+func NewProtoEncoder(mt protoreflect.MessageType, toProto RecordToProto) *ProtoEncoder {
+	return &ProtoEncoder{
+		Type:          mt,
+		RecordToProto: toProto,
+	}
+}
+
+// Encode writes the envelope of v, which must be a map[string]interface{},
+// encoded with an empty tag and chunk, or a *protocol.Message or
+// *protocol.MessageExt whose record is one.
+func (e *ProtoEncoder) Encode(w io.Writer, v interface{}) error {
+	var (
+		tag, chunk string
+		record     interface{}
+		opts       *protocol.MessageOptions
+	)
+
+	switch msg := v.(type) {
+	case map[string]interface{}:
+		record = msg
+	case *protocol.Message:
+		tag, record, opts = msg.Tag, msg.Record, msg.Options
+	case *protocol.MessageExt:
+		tag, record, opts = msg.Tag, msg.Record, msg.Options
+	default:
+		return fmt.Errorf("proto: cannot encode %T", v)
+	}
+
+	m, ok := record.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("proto: cannot encode record of type %T", record)
+	}
+
+	if opts != nil {
+		chunk = opts.Chunk
+	}
+
+	return e.encode(w, tag, chunk, m)
+}
+
+func (e *ProtoEncoder) ContentType() string {
+	return ContentType
+}
+
+// Record returns a MessageEncoder, for WSClient.SendMessage, that encodes
+// record in an envelope with tag and no chunk.
+func (e *ProtoEncoder) Record(tag string, record map[string]interface{}) client.MessageEncoder {
+	return protoRecord{encoder: e, tag: tag, record: record}
+}
+
+func (e *ProtoEncoder) encode(w io.Writer, tag, chunk string, record map[string]interface{}) error {
+	msg, err := e.RecordToProto(record)
+	if err != nil {
+		return fmt.Errorf("proto: convert record: %w", err)
+	}
+
+	if got, want := msg.ProtoReflect().Descriptor().FullName(), e.Type.Descriptor().FullName(); got != want {
+		return fmt.Errorf("proto: record converted to %s, not %s", got, want)
+	}
+
+	a, err := anypb.New(msg)
+	if err != nil {
+		return fmt.Errorf("proto: encode record: %w", err)
+	}
+
+	b, err := gproto.Marshal(&envelopepb.Envelope{
+		Tag:    tag,
+		Chunk:  chunk,
+		Record: a,
+	})
+	if err != nil {
+		return fmt.Errorf("proto: encode envelope: %w", err)
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+type protoRecord struct {
+	encoder *ProtoEncoder
+	tag     string
+	record  map[string]interface{}
+}
+
+func (r protoRecord) EncodeTo(w io.Writer) error {
+	return r.encoder.encode(w, r.tag, "", r.record)
+}
@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package proto_test
+
+import (
+	"bytes"
+	"errors"
+
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/clientfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/ext/extfakes"
+	"github.com/IBM/fluent-forward-go/fluent/client/ws/wsfakes"
+	. "github.com/IBM/fluent-forward-go/fluent/codec/proto"
+	"github.com/IBM/fluent-forward-go/fluent/codec/proto/envelopepb"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ProtoEncoder", func() {
+	var (
+		enc    *ProtoEncoder
+		record map[string]interface{}
+	)
+
+	structType := (&structpb.Struct{}).ProtoReflect().Type()
+
+	toStruct := func(record map[string]interface{}) (gproto.Message, error) {
+		return structpb.NewStruct(record)
+	}
+
+	// open decodes an envelope and the Struct record inside it.
+	open := func(b []byte) (*envelopepb.Envelope, map[string]interface{}) {
+		var env envelopepb.Envelope
+		Expect(gproto.Unmarshal(b, &env)).To(Succeed())
+
+		var s structpb.Struct
+		Expect(env.Record.UnmarshalTo(&s)).To(Succeed())
+
+		return &env, s.AsMap()
+	}
+
+	BeforeEach(func() {
+		enc = NewProtoEncoder(structType, toStruct)
+		record = map[string]interface{}{"message": "oi", "level": 3.0}
+	})
+
+	It("wraps the record in an envelope with the tag and chunk", func() {
+		msg := protocol.NewMessage("foo.bar", record)
+		msg.Options = &protocol.MessageOptions{Chunk: "abc"}
+
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, msg)).To(Succeed())
+
+		env, got := open(buf.Bytes())
+		Expect(env.Tag).To(Equal("foo.bar"))
+		Expect(env.Chunk).To(Equal("abc"))
+		Expect(got).To(Equal(record))
+		Expect(enc.ContentType()).To(Equal(ContentType))
+	})
+
+	It("encodes a bare record with an empty tag and chunk", func() {
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, record)).To(Succeed())
+
+		env, got := open(buf.Bytes())
+		Expect(env.Tag).To(BeEmpty())
+		Expect(env.Chunk).To(BeEmpty())
+		Expect(got).To(Equal(record))
+	})
+
+	It("rejects values without a record map", func() {
+		Expect(enc.Encode(&bytes.Buffer{}, "oi")).ToNot(Succeed())
+		Expect(enc.Encode(&bytes.Buffer{}, &protocol.MessageExt{Record: 1})).ToNot(Succeed())
+	})
+
+	It("fails when the record cannot be converted", func() {
+		enc.RecordToProto = func(map[string]interface{}) (gproto.Message, error) {
+			return nil, errors.New("nope")
+		}
+
+		var buf bytes.Buffer
+		Expect(enc.Encode(&buf, record)).To(MatchError(ContainSubstring("nope")))
+		Expect(buf.Len()).To(BeZero())
+	})
+
+	It("fails when the record is converted to another type", func() {
+		enc.RecordToProto = func(map[string]interface{}) (gproto.Message, error) {
+			return wrapperspb.String("oi"), nil
+		}
+
+		Expect(enc.Encode(&bytes.Buffer{}, record)).To(MatchError(ContainSubstring("google.protobuf.StringValue")))
+	})
+
+	Describe("as a WSClient's encoder", func() {
+		var (
+			conn *wsfakes.FakeConnection
+			cli  *client.WSClient
+		)
+
+		BeforeEach(func() {
+			factory := &clientfakes.FakeWSConnectionFactory{}
+			conn = &wsfakes.FakeConnection{}
+			factory.NewReturns(&extfakes.FakeConn{}, nil)
+			factory.NewSessionReturns(&client.WSSession{Connection: conn})
+
+			cli = client.NewWS(client.WSConnectionOptions{
+				Factory: factory,
+				Codec:   enc,
+			})
+			Expect(cli.Connect()).To(Succeed())
+		})
+
+		It("encodes the messages sent as the Codec", func() {
+			Expect(cli.Send(protocol.NewMessage("foo", record))).To(Succeed())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+
+			env, got := open(conn.WriteArgsForCall(0))
+			Expect(env.Tag).To(Equal("foo"))
+			Expect(got).To(Equal(record))
+		})
+
+		It("encodes the records sent as a MessageEncoder", func() {
+			Expect(cli.SendMessage(enc.Record("foo", record))).To(Succeed())
+			Expect(conn.WriteCallCount()).To(Equal(1))
+
+			env, got := open(conn.WriteArgsForCall(0))
+			Expect(env.Tag).To(Equal("foo"))
+			Expect(got).To(Equal(record))
+		})
+	})
+})
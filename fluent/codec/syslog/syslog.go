@@ -0,0 +1,458 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package syslog formats events as RFC 5424 syslog messages, for SIEM
+// tools and audit systems that consume syslog. The messages can be sent
+// with any transport's SendRaw, e.g. the HTTP chunked transport or
+// TCPClient.
+package syslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+const (
+	ContentType = "application/syslog"
+	// DefaultSDID is the ID of the structured data element that holds the
+	// record's fields when StructuredData is nil. 32473 is the enterprise
+	// number RFC 5424 reserves for examples.
+	DefaultSDID = "fields@32473"
+	// DefaultFacility and DefaultSeverity are used when the Facility and
+	// Severity values are empty.
+	DefaultFacility = "user"
+	DefaultSeverity = "info"
+)
+
+// The largest header fields RFC 5424 allows; longer values are truncated.
+const (
+	maxHostname = 255
+	maxAppName  = 48
+	maxProcID   = 128
+	maxMsgID    = 32
+	maxSDName   = 32
+)
+
+// bom marks a MSG as UTF-8.
+const bom = "\xef\xbb\xbf"
+
+// Value is a header field of the syslog message, taken from a record field
+// or, if the record does not have it, a static value.
+type Value struct {
+	// Field is the record field the value is read from. Fields used in the
+	// header are left out of the default structured data element.
+	Field string
+	// Static is used when Field is empty or not in the record.
+	Static string
+}
+
+func (v Value) get(record map[string]interface{}) string {
+	if v.Field != "" {
+		if fv, ok := record[v.Field]; ok {
+			return stringOf(fv)
+		}
+	}
+
+	return v.Static
+}
+
+// SDElement is a structured data element whose parameters are the record
+// fields named by Params, in that order. Fields the record does not have
+// are left out.
+type SDElement struct {
+	ID     string
+	Params []string
+}
+
+// Framing separates the messages written by one Encode, as RFC 6587
+// describes for syslog over TCP.
+type Framing int
+
+const (
+	// FramingNone writes a bare message, so each Encode may write only one.
+	FramingNone Framing = iota
+	// FramingLF ends each message with a newline.
+	FramingLF
+	// FramingOctetCounting prefixes each message with its length in bytes
+	// and a space.
+	FramingOctetCounting
+)
+
+// SyslogEncoder formats events as RFC 5424 syslog messages. It can be used
+// as a WSClient's Codec, or with Marshal and a transport's SendRaw.
+type SyslogEncoder struct {
+	// AppName is the APP-NAME. If it is empty, the message's tag is used.
+	AppName  Value
+	Hostname Value
+	ProcID   Value
+	MsgID    Value
+	// Facility is a facility's name, such as "local0", or number. If it is
+	// empty, DefaultFacility is used.
+	Facility Value
+	// Severity is a severity's name, such as "warning", or number. If it is
+	// empty, DefaultSeverity is used.
+	Severity Value
+	// MessageField is the record field written as the MSG. If it is
+	// empty, or not in the record, the message has no MSG.
+	MessageField string
+	// StructuredData lists the elements to write. If it is nil, a single
+	// DefaultSDID element holds every record field not used elsewhere,
+	// sorted by name.
+	StructuredData []SDElement
+	// UTF8BOM starts each MSG with a byte order mark, which RFC 5424 uses
+	// to mark it as UTF-8.
+	UTF8BOM bool
+	Framing Framing
+}
+
+func (e *SyslogEncoder) ContentType() string {
+	return ContentType
+}
+
+// Encode writes the events of v, which may be a *protocol.Message,
+// *protocol.MessageExt, *protocol.ForwardMessage, *protocol.PackedForwardMessage
+// with an uncompressed event stream, or *protocol.CompressedPackedForwardMessage.
+// Events with more than one message need a Framing other than FramingNone.
+func (e *SyslogEncoder) Encode(w io.Writer, v interface{}) error {
+	b, err := e.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+// Marshal returns the syslog messages of the events of v, as Encode writes
+// them.
+func (e *SyslogEncoder) Marshal(v interface{}) ([]byte, error) {
+	tag, events, err := eventsOf(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) > 1 && e.Framing == FramingNone {
+		return nil, fmt.Errorf("syslog: %d events need a Framing", len(events))
+	}
+
+	var buf bytes.Buffer
+
+	for _, ev := range events {
+		msg, err := e.format(tag, ev)
+		if err != nil {
+			return nil, err
+		}
+
+		switch e.Framing {
+		case FramingOctetCounting:
+			buf.WriteString(strconv.Itoa(len(msg)))
+			buf.WriteByte(' ')
+			buf.Write(msg)
+		case FramingLF:
+			buf.Write(msg)
+			buf.WriteByte('\n')
+		default:
+			buf.Write(msg)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Format returns the syslog message of a single event.
+func (e *SyslogEncoder) Format(tag string, t time.Time, record map[string]interface{}) ([]byte, error) {
+	return e.format(tag, event{time: t, record: record})
+}
+
+func (e *SyslogEncoder) format(tag string, ev event) ([]byte, error) {
+	record, ok := ev.record.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("syslog: cannot format record of type %T", ev.record)
+	}
+
+	pri, err := e.priority(record)
+	if err != nil {
+		return nil, err
+	}
+
+	appName := e.AppName.get(record)
+	if appName == "" {
+		appName = tag
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s ",
+		pri,
+		timestamp(ev.time),
+		headerField(e.Hostname.get(record), maxHostname),
+		headerField(appName, maxAppName),
+		headerField(e.ProcID.get(record), maxProcID),
+		headerField(e.MsgID.get(record), maxMsgID),
+	)
+
+	e.writeStructuredData(&buf, record)
+
+	if msg, ok := record[e.MessageField]; ok && e.MessageField != "" {
+		buf.WriteByte(' ')
+
+		if e.UTF8BOM {
+			buf.WriteString(bom)
+		}
+
+		buf.WriteString(stringOf(msg))
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e *SyslogEncoder) priority(record map[string]interface{}) (int, error) {
+	facility, err := lookup(e.Facility.get(record), DefaultFacility, facilities, 23)
+	if err != nil {
+		return 0, fmt.Errorf("syslog: facility: %w", err)
+	}
+
+	severity, err := lookup(e.Severity.get(record), DefaultSeverity, severities, 7)
+	if err != nil {
+		return 0, fmt.Errorf("syslog: severity: %w", err)
+	}
+
+	return facility*8 + severity, nil
+}
+
+func (e *SyslogEncoder) writeStructuredData(buf *bytes.Buffer, record map[string]interface{}) {
+	elements := e.StructuredData
+	if elements == nil {
+		elements = []SDElement{{ID: DefaultSDID, Params: e.unusedFields(record)}}
+	}
+
+	wrote := false
+
+	for _, el := range elements {
+		var params []string
+
+		for _, name := range el.Params {
+			if _, ok := record[name]; ok {
+				params = append(params, name)
+			}
+		}
+
+		if len(params) == 0 {
+			continue
+		}
+
+		buf.WriteByte('[')
+		buf.WriteString(sdName(el.ID))
+
+		for _, name := range params {
+			fmt.Fprintf(buf, ` %s="%s"`, sdName(name), sdValue(stringOf(record[name])))
+		}
+
+		buf.WriteByte(']')
+
+		wrote = true
+	}
+
+	if !wrote {
+		buf.WriteByte('-')
+	}
+}
+
+// unusedFields returns the names of the record's fields that are not
+// written in the header or as the MSG, sorted.
+func (e *SyslogEncoder) unusedFields(record map[string]interface{}) []string {
+	used := map[string]bool{}
+	for _, field := range []string{e.MessageField, e.AppName.Field, e.Hostname.Field, e.ProcID.Field, e.MsgID.Field, e.Facility.Field, e.Severity.Field} {
+		if field != "" {
+			used[field] = true
+		}
+	}
+
+	var names []string
+
+	for name := range record {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "audit": 13, "alert": 14, "clock": 15, "local0": 16,
+	"local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21,
+	"local6": 22, "local7": 23,
+}
+
+var severities = map[string]int{
+	"emerg": 0, "emergency": 0, "alert": 1, "crit": 2, "critical": 2,
+	"err": 3, "error": 3, "warning": 4, "warn": 4, "notice": 5, "info": 6,
+	"informational": 6, "debug": 7,
+}
+
+// lookup returns the number of a facility or severity, given as its name
+// or number.
+func lookup(value, fallback string, names map[string]int, max int) (int, error) {
+	if value == "" {
+		value = fallback
+	}
+
+	if n, ok := names[strings.ToLower(value)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("unknown value %q", value)
+	}
+
+	return n, nil
+}
+
+// timestamp formats t as RFC 5424 requires, with at most microseconds, or
+// as the nil value if it is not known.
+func timestamp(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format("2006-01-02T15:04:05.999999Z07:00")
+}
+
+// headerField makes s a valid header field: printable ASCII without
+// spaces, at most max bytes, or the nil value if it is empty.
+func headerField(s string, max int) string {
+	if s == "" {
+		return "-"
+	}
+
+	b := []byte(s)
+	for i, c := range b {
+		if c < 33 || c > 126 {
+			b[i] = '_'
+		}
+	}
+
+	if len(b) > max {
+		b = b[:max]
+	}
+
+	return string(b)
+}
+
+// sdName makes s a valid SD-ID or PARAM-NAME, which also may not contain
+// '=', ']' or '"'.
+func sdName(s string) string {
+	s = headerField(s, maxSDName)
+
+	return strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(s)
+}
+
+// sdValue escapes the characters RFC 5424 requires escaping in a
+// PARAM-VALUE.
+func sdValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(s)
+}
+
+func stringOf(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// event is a record and the time it happened, if known.
+type event struct {
+	time   time.Time
+	record interface{}
+}
+
+func eventsOf(v interface{}) (string, []event, error) {
+	switch msg := v.(type) {
+	case *protocol.Message:
+		return msg.Tag, []event{{time.Unix(msg.Timestamp, 0).UTC(), msg.Record}}, nil
+	case *protocol.MessageExt:
+		return msg.Tag, []event{{msg.Timestamp.Time, msg.Record}}, nil
+	case *protocol.ForwardMessage:
+		return msg.Tag, entryEvents(msg.Entries), nil
+	case *protocol.CompressedPackedForwardMessage:
+		// the wrapped event stream is not compressed until it is encoded
+		events, err := packedEvents(msg.EventStream)
+		return msg.Tag, events, err
+	case *protocol.PackedForwardMessage:
+		if msg.Options != nil && msg.Options.Compressed != "" {
+			return "", nil, errors.New("syslog: cannot format a compressed event stream")
+		}
+
+		events, err := packedEvents(msg.EventStream)
+
+		return msg.Tag, events, err
+	default:
+		return "", nil, fmt.Errorf("syslog: cannot encode %T", v)
+	}
+}
+
+func packedEvents(stream []byte) ([]event, error) {
+	var entries protocol.EntryList
+	if _, err := entries.UnmarshalPacked(stream); err != nil {
+		return nil, fmt.Errorf("syslog: decode event stream: %w", err)
+	}
+
+	return entryEvents(entries), nil
+}
+
+func entryEvents(entries protocol.EntryList) []event {
+	events := make([]event, len(entries))
+	for i, entry := range entries {
+		events[i] = event{entry.Timestamp.Time, entry.Record}
+	}
+
+	return events
+}
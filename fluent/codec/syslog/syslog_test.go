@@ -0,0 +1,236 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package syslog_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client/http"
+	"github.com/IBM/fluent-forward-go/fluent/client/tcp"
+	. "github.com/IBM/fluent-forward-go/fluent/codec/syslog"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const bom = "\xef\xbb\xbf"
+
+var _ = Describe("SyslogEncoder", func() {
+	at := func(value string) time.Time {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		Expect(err).ToNot(HaveOccurred())
+
+		return t
+	}
+
+	format := func(enc *SyslogEncoder, tag string, t time.Time, record map[string]interface{}) string {
+		b, err := enc.Format(tag, t, record)
+		Expect(err).ToNot(HaveOccurred())
+
+		return string(b)
+	}
+
+	// The examples of RFC 5424, section 6.5.
+	Describe("RFC 5424 examples", func() {
+		It("formats example 1, without structured data", func() {
+			enc := &SyslogEncoder{
+				Hostname:     Value{Field: "host"},
+				MsgID:        Value{Static: "ID47"},
+				Facility:     Value{Static: "auth"},
+				Severity:     Value{Static: "crit"},
+				MessageField: "message",
+				UTF8BOM:      true,
+			}
+
+			Expect(format(enc, "su", at("2003-10-11T22:14:15.003Z"), map[string]interface{}{
+				"host":    "mymachine.example.com",
+				"message": "'su root' failed for lonvick on /dev/pts/8",
+			})).To(Equal("<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - " + bom + "'su root' failed for lonvick on /dev/pts/8"))
+		})
+
+		It("formats example 2, with a time offset and no BOM", func() {
+			enc := &SyslogEncoder{
+				Hostname:     Value{Static: "192.0.2.1"},
+				AppName:      Value{Static: "myproc"},
+				ProcID:       Value{Field: "pid"},
+				Facility:     Value{Static: "local4"},
+				Severity:     Value{Static: "notice"},
+				MessageField: "message",
+			}
+
+			Expect(format(enc, "foo", at("2003-08-24T05:14:15.000003-07:00"), map[string]interface{}{
+				"pid":     8710,
+				"message": "%% It's time to make the do-nuts.",
+			})).To(Equal("<165>1 2003-08-24T05:14:15.000003-07:00 192.0.2.1 myproc 8710 - - %% It's time to make the do-nuts."))
+		})
+
+		It("formats examples 3 and 4, with structured data elements", func() {
+			enc := &SyslogEncoder{
+				Hostname: Value{Static: "mymachine.example.com"},
+				AppName:  Value{Static: "evntslog"},
+				MsgID:    Value{Static: "ID47"},
+				Facility: Value{Static: "20"},
+				Severity: Value{Static: "5"},
+				StructuredData: []SDElement{
+					{ID: "exampleSDID@32473", Params: []string{"iut", "eventSource", "eventID"}},
+					{ID: "examplePriority@32473", Params: []string{"class"}},
+				},
+				MessageField: "message",
+				UTF8BOM:      true,
+			}
+
+			t := at("2003-10-11T22:14:15.003Z")
+			event := map[string]interface{}{"iut": 3, "eventSource": "Application", "eventID": 1011}
+
+			Expect(format(enc, "foo", t, map[string]interface{}{
+				"iut": 3, "eventSource": "Application", "eventID": 1011,
+				"message": "An application event log entry...",
+			})).To(Equal(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"] ` + bom + "An application event log entry..."))
+
+			event["class"] = "high"
+			Expect(format(enc, "foo", t, event)).To(Equal(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"]`))
+		})
+	})
+
+	It("writes unused fields to the default element and defaults the rest of the header", func() {
+		enc := &SyslogEncoder{
+			Severity:     Value{Field: "level", Static: "info"},
+			MessageField: "message",
+		}
+
+		Expect(format(enc, "app.web", time.Time{}, map[string]interface{}{
+			"level":   "warning",
+			"message": "slow",
+			"user":    "ana",
+			"ms":      1500,
+		})).To(Equal(`<12>1 - - app.web - - [fields@32473 ms="1500" user="ana"] slow`))
+
+		Expect(format(enc, "app.web", time.Time{}, map[string]interface{}{"message": "ok"})).
+			To(Equal(`<14>1 - - app.web - - - ok`))
+	})
+
+	It("escapes and sanitizes what RFC 5424 does not allow", func() {
+		enc := &SyslogEncoder{AppName: Value{Static: "my app"}}
+
+		Expect(format(enc, "foo", time.Time{}, map[string]interface{}{
+			`a"b=c`:  `x"y\z]`,
+			"nested": map[string]interface{}{"k": "v"},
+		})).To(Equal(`<14>1 - - my_app - - [fields@32473 a_b_c="x\"y\\z\]" nested="{\"k\":\"v\"}"]`))
+	})
+
+	It("rejects unknown facilities and severities", func() {
+		_, err := (&SyslogEncoder{Facility: Value{Static: "local9"}}).Format("foo", time.Time{}, nil)
+		Expect(err).To(MatchError(ContainSubstring("facility")))
+
+		_, err = (&SyslogEncoder{Severity: Value{Field: "level"}}).Format("foo", time.Time{}, map[string]interface{}{"level": 8})
+		Expect(err).To(MatchError(ContainSubstring("severity")))
+	})
+
+	Describe("Marshal", func() {
+		var entries protocol.EntryList
+
+		BeforeEach(func() {
+			t := protocol.EventTime{Time: at("2022-03-01T12:00:00Z")}
+			entries = protocol.EntryList{
+				{Timestamp: t, Record: map[string]interface{}{"message": "one"}},
+				{Timestamp: t, Record: map[string]interface{}{"message": "two"}},
+			}
+		})
+
+		It("frames a forward message's events", func() {
+			enc := &SyslogEncoder{MessageField: "message", Framing: FramingOctetCounting}
+
+			b, err := enc.Marshal(protocol.NewForwardMessage("foo", entries))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b)).To(Equal("42 <14>1 2022-03-01T12:00:00Z - foo - - - one42 <14>1 2022-03-01T12:00:00Z - foo - - - two"))
+
+			enc.Framing = FramingLF
+			msg, err := protocol.NewPackedForwardMessage("foo", entries)
+			Expect(err).ToNot(HaveOccurred())
+
+			b, err = enc.Marshal(msg)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(b)).To(Equal("<14>1 2022-03-01T12:00:00Z - foo - - - one\n<14>1 2022-03-01T12:00:00Z - foo - - - two\n"))
+		})
+
+		It("needs framing for more than one event", func() {
+			_, err := (&SyslogEncoder{}).Marshal(protocol.NewForwardMessage("foo", entries))
+			Expect(err).To(MatchError(ContainSubstring("Framing")))
+		})
+	})
+
+	It("sends over the HTTP chunked transport", func() {
+		bodies := make(chan string, 1)
+		svr := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			b, _ := io.ReadAll(r.Body)
+			bodies <- string(b)
+		}))
+		defer svr.Close()
+
+		enc := &SyslogEncoder{MessageField: "message", Framing: FramingLF}
+		b, err := enc.Marshal(protocol.NewMessage("foo", map[string]interface{}{"message": "oi"}))
+		Expect(err).ToNot(HaveOccurred())
+
+		transport := http.New(http.ConnectionOptions{URL: svr.URL})
+		Expect(transport.SendRaw(b)).To(Succeed())
+		Expect(transport.Flush()).To(Succeed())
+		Expect(bodies).To(Receive(MatchRegexp(`^<14>1 \S+ - foo - - - oi\n$`)))
+		Expect(transport.Close()).To(Succeed())
+	})
+
+	It("sends over a raw TCP connection", func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+
+		lines := make(chan string, 1)
+		go func() {
+			defer GinkgoRecover()
+
+			conn, err := l.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			defer conn.Close()
+
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			lines <- line
+		}()
+
+		enc := &SyslogEncoder{MessageField: "message", Framing: FramingLF}
+		b, err := enc.Marshal(protocol.NewMessage("foo", map[string]interface{}{"message": "oi"}))
+		Expect(err).ToNot(HaveOccurred())
+
+		c := tcp.New(tcp.Options{Address: l.Addr().String()})
+		Expect(c.Connect()).To(Succeed())
+		defer c.Disconnect()
+
+		Expect(c.SendRaw(b)).To(Succeed())
+		Eventually(lines).Should(Receive(MatchRegexp(`^<14>1 \S+ - foo - - - oi\n$`)))
+	})
+})
@@ -0,0 +1,202 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package consul discovers Fluent Forward servers from the Consul service
+// catalog.
+package consul
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// DefaultPollInterval is how often Watch queries Consul when WatchMode
+	// is off and PollInterval is zero.
+	DefaultPollInterval = 30 * time.Second
+	// DefaultRetryDelay is how long Watch waits after a failed query.
+	DefaultRetryDelay = 5 * time.Second
+)
+
+// ConsulDiscovery is a client.DiscoveryProvider that finds the instances
+// of a Consul service whose health checks are passing.
+type ConsulDiscovery struct {
+	// Client talks to the Consul agent.
+	Client *api.Client
+	// Service is the name of the service.
+	Service string
+	// Tag, if not empty, only finds instances with this tag.
+	Tag string
+	// Datacenter is the datacenter to query. If empty, the agent's own is
+	// used.
+	Datacenter string
+	// WatchMode makes Watch use blocking queries, so that changes are seen
+	// as soon as Consul makes them, instead of polling every PollInterval.
+	WatchMode bool
+	// PollInterval is how often Watch queries Consul when WatchMode is off.
+	// If zero, DefaultPollInterval is used.
+	PollInterval time.Duration
+	// Format turns an address and port into a ServerAddress. If nil, they
+	// are joined as host:port; set it to build websocket URLs.
+	Format func(host string, port int) client.ServerAddress
+	// OnError, if not nil, is called by Watch with each failed query.
+	OnError func(err error)
+}
+
+// Lookup returns the addresses of the healthy instances of the service.
+func (d *ConsulDiscovery) Lookup(ctx context.Context) ([]client.ServerAddress, error) {
+	addresses, _, err := d.query(ctx, 0)
+	return addresses, err
+}
+
+// query returns the healthy instances, and the index to wait on for the
+// next change. If index is not zero, it is a blocking query that returns
+// once the instances change or Consul's wait time passes.
+func (d *ConsulDiscovery) query(ctx context.Context, index uint64) ([]client.ServerAddress, uint64, error) {
+	opts := (&api.QueryOptions{
+		Datacenter: d.Datacenter,
+		WaitIndex:  index,
+	}).WithContext(ctx)
+
+	entries, meta, err := d.Client.Health().Service(d.Service, d.Tag, true, opts)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query consul service %s: %w", d.Service, err)
+	}
+
+	format := d.Format
+	if format == nil {
+		format = func(host string, port int) client.ServerAddress {
+			return client.ServerAddress(net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+	}
+
+	addresses := make([]client.ServerAddress, 0, len(entries))
+	for _, entry := range entries {
+		// a service registered without an address is reached at its node's
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+
+		addresses = append(addresses, format(host, entry.Service.Port))
+	}
+
+	return addresses, meta.LastIndex, nil
+}
+
+// Watch returns a channel that receives the healthy instances of the
+// service, and again whenever they change, until ctx is done, when it is
+// closed. If no instance is healthy, the last list is kept rather than
+// leaving the client with no servers. Failed queries are retried after
+// DefaultRetryDelay.
+func (d *ConsulDiscovery) Watch(ctx context.Context) (<-chan []client.ServerAddress, error) {
+	updates := make(chan []client.ServerAddress, 1)
+
+	go func() {
+		defer close(updates)
+
+		var (
+			index uint64
+			last  []client.ServerAddress
+		)
+
+		for {
+			addresses, next, err := d.query(ctx, index)
+
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				if d.OnError != nil {
+					d.OnError(err)
+				}
+
+				// the index may be stale, e.g. after a Consul restart
+				index = 0
+
+				if !sleep(ctx, DefaultRetryDelay) {
+					return
+				}
+
+				continue
+			case len(addresses) > 0 && !reflect.DeepEqual(addresses, last):
+				last = addresses
+
+				// replace an update the receiver has not taken yet
+				select {
+				case <-updates:
+				default:
+				}
+
+				updates <- addresses
+			}
+
+			if d.WatchMode {
+				// an index that goes backwards must be reset, or the
+				// query would return at once forever
+				if next < index {
+					next = 0
+				}
+
+				index = next
+
+				continue
+			}
+
+			if !sleep(ctx, d.pollInterval()) {
+				return
+			}
+		}
+	}()
+
+	return updates, nil
+}
+
+func (d *ConsulDiscovery) pollInterval() time.Duration {
+	if d.PollInterval <= 0 {
+		return DefaultPollInterval
+	}
+
+	return d.PollInterval
+}
+
+// sleep waits for d, and reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
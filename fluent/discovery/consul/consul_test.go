@@ -0,0 +1,201 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package consul_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/discovery/consul"
+	"github.com/hashicorp/consul/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// catalog is a stand-in for Consul's health endpoint. Blocking queries
+// wait until the instances change or a short wait time passes.
+type catalog struct {
+	*httptest.Server
+
+	lock      sync.Mutex
+	index     uint64
+	entries   []*api.ServiceEntry
+	changed   chan struct{}
+	queries   []*http.Request
+	blockings int
+}
+
+func newCatalog() *catalog {
+	c := &catalog{index: 1, changed: make(chan struct{})}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+
+	return c
+}
+
+func (c *catalog) serve(w http.ResponseWriter, r *http.Request) {
+	c.lock.Lock()
+	c.queries = append(c.queries, r)
+	index, changed := c.index, c.changed
+	c.lock.Unlock()
+
+	if wait := r.URL.Query().Get("index"); wait == strconv.FormatUint(index, 10) {
+		c.lock.Lock()
+		c.blockings++
+		c.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-time.After(100 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(c.index, 10))
+	_ = json.NewEncoder(w).Encode(c.entries)
+}
+
+func (c *catalog) Set(hosts ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = nil
+	for _, host := range hosts {
+		c.entries = append(c.entries, &api.ServiceEntry{
+			Node:    &api.Node{Address: "10.0.0.100"},
+			Service: &api.AgentService{Address: host, Port: 24224},
+		})
+	}
+
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *catalog) Blockings() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.blockings
+}
+
+var _ = Describe("ConsulDiscovery", func() {
+	var (
+		svr       *catalog
+		discovery *ConsulDiscovery
+		ctx       context.Context
+		cancel    context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		svr = newCatalog()
+		svr.Set("10.0.0.1")
+
+		consul, err := api.NewClient(&api.Config{Address: svr.Listener.Addr().String()})
+		Expect(err).ToNot(HaveOccurred())
+
+		discovery = &ConsulDiscovery{
+			Client:       consul,
+			Service:      "fluentd",
+			Datacenter:   "dc2",
+			PollInterval: 10 * time.Millisecond,
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+		svr.Close()
+	})
+
+	It("looks up the passing instances of the service", func() {
+		Expect(discovery.Lookup(ctx)).To(Equal([]client.ServerAddress{"10.0.0.1:24224"}))
+
+		q := svr.queries[0]
+		Expect(q.URL.Path).To(Equal("/v1/health/service/fluentd"))
+		Expect(q.URL.Query().Get("passing")).To(Equal("1"))
+		Expect(q.URL.Query().Get("dc")).To(Equal("dc2"))
+	})
+
+	It("uses the node's address for a service without one", func() {
+		svr.Set("")
+
+		Expect(discovery.Lookup(ctx)).To(Equal([]client.ServerAddress{"10.0.0.100:24224"}))
+	})
+
+	It("polls for changes", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.1:24224"})))
+
+		svr.Set("10.0.0.1", "10.0.0.2")
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.1:24224", "10.0.0.2:24224"})))
+		Expect(svr.Blockings()).To(BeZero())
+	})
+
+	It("streams changes with blocking queries in WatchMode", func() {
+		discovery.WatchMode = true
+		discovery.PollInterval = time.Hour
+
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.1:24224"})))
+		Eventually(svr.Blockings).Should(BeNumerically(">", 0))
+
+		svr.Set("10.0.0.2")
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.2:24224"})))
+	})
+
+	It("keeps the last list when no instance is healthy", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive())
+
+		svr.Set()
+		Consistently(updates, 100*time.Millisecond).ShouldNot(Receive())
+
+		svr.Set("10.0.0.3")
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.3:24224"})))
+	})
+
+	It("closes the channel when the context is done", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive())
+
+		cancel()
+		Eventually(updates).Should(BeClosed())
+	})
+})
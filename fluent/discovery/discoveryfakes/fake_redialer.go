@@ -0,0 +1,425 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package discoveryfakes
+
+import (
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/discovery"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+type FakeRedialer struct {
+	ConnectStub        func() error
+	connectMutex       sync.RWMutex
+	connectArgsForCall []struct {
+	}
+	connectReturns struct {
+		result1 error
+	}
+	connectReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DisconnectStub        func() error
+	disconnectMutex       sync.RWMutex
+	disconnectArgsForCall []struct {
+	}
+	disconnectReturns struct {
+		result1 error
+	}
+	disconnectReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ReconnectStub        func() error
+	reconnectMutex       sync.RWMutex
+	reconnectArgsForCall []struct {
+	}
+	reconnectReturns struct {
+		result1 error
+	}
+	reconnectReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SendStub        func(protocol.ChunkEncoder) error
+	sendMutex       sync.RWMutex
+	sendArgsForCall []struct {
+		arg1 protocol.ChunkEncoder
+	}
+	sendReturns struct {
+		result1 error
+	}
+	sendReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SendRawStub        func([]byte) error
+	sendRawMutex       sync.RWMutex
+	sendRawArgsForCall []struct {
+		arg1 []byte
+	}
+	sendRawReturns struct {
+		result1 error
+	}
+	sendRawReturnsOnCall map[int]struct {
+		result1 error
+	}
+	SetAddressStub        func(string)
+	setAddressMutex       sync.RWMutex
+	setAddressArgsForCall []struct {
+		arg1 string
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRedialer) Connect() error {
+	fake.connectMutex.Lock()
+	ret, specificReturn := fake.connectReturnsOnCall[len(fake.connectArgsForCall)]
+	fake.connectArgsForCall = append(fake.connectArgsForCall, struct {
+	}{})
+	stub := fake.ConnectStub
+	fakeReturns := fake.connectReturns
+	fake.recordInvocation("Connect", []interface{}{})
+	fake.connectMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRedialer) ConnectCallCount() int {
+	fake.connectMutex.RLock()
+	defer fake.connectMutex.RUnlock()
+	return len(fake.connectArgsForCall)
+}
+
+func (fake *FakeRedialer) ConnectCalls(stub func() error) {
+	fake.connectMutex.Lock()
+	defer fake.connectMutex.Unlock()
+	fake.ConnectStub = stub
+}
+
+func (fake *FakeRedialer) ConnectReturns(result1 error) {
+	fake.connectMutex.Lock()
+	defer fake.connectMutex.Unlock()
+	fake.ConnectStub = nil
+	fake.connectReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) ConnectReturnsOnCall(i int, result1 error) {
+	fake.connectMutex.Lock()
+	defer fake.connectMutex.Unlock()
+	fake.ConnectStub = nil
+	if fake.connectReturnsOnCall == nil {
+		fake.connectReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.connectReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) Disconnect() error {
+	fake.disconnectMutex.Lock()
+	ret, specificReturn := fake.disconnectReturnsOnCall[len(fake.disconnectArgsForCall)]
+	fake.disconnectArgsForCall = append(fake.disconnectArgsForCall, struct {
+	}{})
+	stub := fake.DisconnectStub
+	fakeReturns := fake.disconnectReturns
+	fake.recordInvocation("Disconnect", []interface{}{})
+	fake.disconnectMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRedialer) DisconnectCallCount() int {
+	fake.disconnectMutex.RLock()
+	defer fake.disconnectMutex.RUnlock()
+	return len(fake.disconnectArgsForCall)
+}
+
+func (fake *FakeRedialer) DisconnectCalls(stub func() error) {
+	fake.disconnectMutex.Lock()
+	defer fake.disconnectMutex.Unlock()
+	fake.DisconnectStub = stub
+}
+
+func (fake *FakeRedialer) DisconnectReturns(result1 error) {
+	fake.disconnectMutex.Lock()
+	defer fake.disconnectMutex.Unlock()
+	fake.DisconnectStub = nil
+	fake.disconnectReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) DisconnectReturnsOnCall(i int, result1 error) {
+	fake.disconnectMutex.Lock()
+	defer fake.disconnectMutex.Unlock()
+	fake.DisconnectStub = nil
+	if fake.disconnectReturnsOnCall == nil {
+		fake.disconnectReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.disconnectReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) Reconnect() error {
+	fake.reconnectMutex.Lock()
+	ret, specificReturn := fake.reconnectReturnsOnCall[len(fake.reconnectArgsForCall)]
+	fake.reconnectArgsForCall = append(fake.reconnectArgsForCall, struct {
+	}{})
+	stub := fake.ReconnectStub
+	fakeReturns := fake.reconnectReturns
+	fake.recordInvocation("Reconnect", []interface{}{})
+	fake.reconnectMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRedialer) ReconnectCallCount() int {
+	fake.reconnectMutex.RLock()
+	defer fake.reconnectMutex.RUnlock()
+	return len(fake.reconnectArgsForCall)
+}
+
+func (fake *FakeRedialer) ReconnectCalls(stub func() error) {
+	fake.reconnectMutex.Lock()
+	defer fake.reconnectMutex.Unlock()
+	fake.ReconnectStub = stub
+}
+
+func (fake *FakeRedialer) ReconnectReturns(result1 error) {
+	fake.reconnectMutex.Lock()
+	defer fake.reconnectMutex.Unlock()
+	fake.ReconnectStub = nil
+	fake.reconnectReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) ReconnectReturnsOnCall(i int, result1 error) {
+	fake.reconnectMutex.Lock()
+	defer fake.reconnectMutex.Unlock()
+	fake.ReconnectStub = nil
+	if fake.reconnectReturnsOnCall == nil {
+		fake.reconnectReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.reconnectReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) Send(arg1 protocol.ChunkEncoder) error {
+	fake.sendMutex.Lock()
+	ret, specificReturn := fake.sendReturnsOnCall[len(fake.sendArgsForCall)]
+	fake.sendArgsForCall = append(fake.sendArgsForCall, struct {
+		arg1 protocol.ChunkEncoder
+	}{arg1})
+	stub := fake.SendStub
+	fakeReturns := fake.sendReturns
+	fake.recordInvocation("Send", []interface{}{arg1})
+	fake.sendMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRedialer) SendCallCount() int {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	return len(fake.sendArgsForCall)
+}
+
+func (fake *FakeRedialer) SendCalls(stub func(protocol.ChunkEncoder) error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = stub
+}
+
+func (fake *FakeRedialer) SendArgsForCall(i int) protocol.ChunkEncoder {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	argsForCall := fake.sendArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRedialer) SendReturns(result1 error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = nil
+	fake.sendReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) SendReturnsOnCall(i int, result1 error) {
+	fake.sendMutex.Lock()
+	defer fake.sendMutex.Unlock()
+	fake.SendStub = nil
+	if fake.sendReturnsOnCall == nil {
+		fake.sendReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.sendReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) SendRaw(arg1 []byte) error {
+	var arg1Copy []byte
+	if arg1 != nil {
+		arg1Copy = make([]byte, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.sendRawMutex.Lock()
+	ret, specificReturn := fake.sendRawReturnsOnCall[len(fake.sendRawArgsForCall)]
+	fake.sendRawArgsForCall = append(fake.sendRawArgsForCall, struct {
+		arg1 []byte
+	}{arg1Copy})
+	stub := fake.SendRawStub
+	fakeReturns := fake.sendRawReturns
+	fake.recordInvocation("SendRaw", []interface{}{arg1Copy})
+	fake.sendRawMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeRedialer) SendRawCallCount() int {
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	return len(fake.sendRawArgsForCall)
+}
+
+func (fake *FakeRedialer) SendRawCalls(stub func([]byte) error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = stub
+}
+
+func (fake *FakeRedialer) SendRawArgsForCall(i int) []byte {
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	argsForCall := fake.sendRawArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRedialer) SendRawReturns(result1 error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = nil
+	fake.sendRawReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) SendRawReturnsOnCall(i int, result1 error) {
+	fake.sendRawMutex.Lock()
+	defer fake.sendRawMutex.Unlock()
+	fake.SendRawStub = nil
+	if fake.sendRawReturnsOnCall == nil {
+		fake.sendRawReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.sendRawReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRedialer) SetAddress(arg1 string) {
+	fake.setAddressMutex.Lock()
+	fake.setAddressArgsForCall = append(fake.setAddressArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.SetAddressStub
+	fake.recordInvocation("SetAddress", []interface{}{arg1})
+	fake.setAddressMutex.Unlock()
+	if stub != nil {
+		fake.SetAddressStub(arg1)
+	}
+}
+
+func (fake *FakeRedialer) SetAddressCallCount() int {
+	fake.setAddressMutex.RLock()
+	defer fake.setAddressMutex.RUnlock()
+	return len(fake.setAddressArgsForCall)
+}
+
+func (fake *FakeRedialer) SetAddressCalls(stub func(string)) {
+	fake.setAddressMutex.Lock()
+	defer fake.setAddressMutex.Unlock()
+	fake.SetAddressStub = stub
+}
+
+func (fake *FakeRedialer) SetAddressArgsForCall(i int) string {
+	fake.setAddressMutex.RLock()
+	defer fake.setAddressMutex.RUnlock()
+	argsForCall := fake.setAddressArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRedialer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.connectMutex.RLock()
+	defer fake.connectMutex.RUnlock()
+	fake.disconnectMutex.RLock()
+	defer fake.disconnectMutex.RUnlock()
+	fake.reconnectMutex.RLock()
+	defer fake.reconnectMutex.RUnlock()
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	fake.sendRawMutex.RLock()
+	defer fake.sendRawMutex.RUnlock()
+	fake.setAddressMutex.RLock()
+	defer fake.setAddressMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeRedialer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ discovery.Redialer = new(FakeRedialer)
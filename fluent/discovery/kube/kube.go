@@ -0,0 +1,209 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package kube discovers Fluent Forward servers from a Kubernetes
+// Endpoints resource, such as the one for the Service in front of a
+// Fluentd DaemonSet or StatefulSet, so that clients follow the pods as
+// they are rescheduled.
+//
+// The application's service account needs to list and watch the
+// Endpoints, e.g. with this Role in the Endpoints' namespace, bound to the
+// service account with a RoleBinding:
+//
+//	apiVersion: rbac.authorization.k8s.io/v1
+//	kind: Role
+//	metadata:
+//	  name: fluentd-endpoints-reader
+//	  namespace: logging
+//	rules:
+//	  - apiGroups: [""]
+//	    resources: ["endpoints"]
+//	    resourceNames: ["fluentd"]
+//	    verbs: ["get", "list", "watch"]
+//
+// Kubernetes does not apply resourceNames to list requests, so if the
+// API server rejects the list, drop resourceNames from the rule.
+package kube
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// KubeEndpointDiscovery is a client.DiscoveryProvider that watches the
+// ready addresses of an Endpoints resource with an informer.
+type KubeEndpointDiscovery struct {
+	// Client talks to the API server.
+	Client kubernetes.Interface
+	// Namespace and Name identify the Endpoints.
+	Namespace string
+	Name      string
+	// PortName is the name of the port to send to. If empty, the first
+	// port of each subset is used.
+	PortName string
+	// Format turns an address and port into a ServerAddress. If nil, they
+	// are joined as host:port; set it to build websocket URLs.
+	Format func(ip string, port int32) client.ServerAddress
+	// ResyncPeriod is how often the informer relists the Endpoints. Zero
+	// disables resyncs.
+	ResyncPeriod time.Duration
+}
+
+// Watch starts an informer for the Endpoints and returns a channel that
+// receives their ready addresses whenever they change. A receiver that
+// falls behind only gets the latest addresses. The informer stops, and the
+// channel is closed, when ctx is done.
+func (d *KubeEndpointDiscovery) Watch(ctx context.Context) (<-chan []client.ServerAddress, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		d.Client,
+		d.ResyncPeriod,
+		informers.WithNamespace(d.Namespace),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.FieldSelector = fields.OneTermEqualSelector("metadata.name", d.Name).String()
+		}),
+	)
+
+	informer := factory.Core().V1().Endpoints().Informer()
+	w := &watcher{discovery: d, updates: make(chan []client.ServerAddress, 1)}
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.update,
+		UpdateFunc: func(_, obj interface{}) { w.update(obj) },
+		DeleteFunc: func(_ interface{}) { w.publish(nil) },
+	})
+
+	factory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		w.close()
+		return nil, errors.New("watch endpoints: cache did not sync")
+	}
+
+	go func() {
+		<-ctx.Done()
+		w.close()
+	}()
+
+	return w.updates, nil
+}
+
+// Addresses returns the ready addresses in endpoints.
+func (d *KubeEndpointDiscovery) Addresses(endpoints *corev1.Endpoints) []client.ServerAddress {
+	format := d.Format
+	if format == nil {
+		format = func(ip string, port int32) client.ServerAddress {
+			return client.ServerAddress(net.JoinHostPort(ip, strconv.Itoa(int(port))))
+		}
+	}
+
+	var addresses []client.ServerAddress
+
+	for _, subset := range endpoints.Subsets {
+		port, ok := d.port(subset.Ports)
+		if !ok {
+			continue
+		}
+
+		// NotReadyAddresses are left out: they are pods that are
+		// starting, or failing their readiness probes
+		for _, address := range subset.Addresses {
+			addresses = append(addresses, format(address.IP, port))
+		}
+	}
+
+	return addresses
+}
+
+func (d *KubeEndpointDiscovery) port(ports []corev1.EndpointPort) (int32, bool) {
+	for _, p := range ports {
+		if d.PortName == "" || p.Name == d.PortName {
+			return p.Port, true
+		}
+	}
+
+	return 0, false
+}
+
+// watcher publishes the addresses of the watched Endpoints as they
+// change.
+type watcher struct {
+	discovery *KubeEndpointDiscovery
+	updates   chan []client.ServerAddress
+	lock      sync.Mutex
+	last      []client.ServerAddress
+	published bool
+	closed    bool
+}
+
+func (w *watcher) update(obj interface{}) {
+	endpoints, ok := obj.(*corev1.Endpoints)
+	if !ok || endpoints.Name != w.discovery.Name {
+		return
+	}
+
+	w.publish(w.discovery.Addresses(endpoints))
+}
+
+func (w *watcher) publish(addresses []client.ServerAddress) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed || (w.published && reflect.DeepEqual(addresses, w.last)) {
+		return
+	}
+
+	w.last = addresses
+	w.published = true
+
+	// replace an update the receiver has not taken yet
+	select {
+	case <-w.updates:
+	default:
+	}
+
+	w.updates <- addresses
+}
+
+func (w *watcher) close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.closed {
+		w.closed = true
+		close(w.updates)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package kube_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	. "github.com/IBM/fluent-forward-go/fluent/discovery/kube"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func endpoints(ready, notReady []string) *corev1.Endpoints {
+	subset := corev1.EndpointSubset{
+		Ports: []corev1.EndpointPort{
+			{Name: "metrics", Port: 24231},
+			{Name: "forward", Port: 24224},
+		},
+	}
+
+	for _, ip := range ready {
+		subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: ip})
+	}
+
+	for _, ip := range notReady {
+		subset.NotReadyAddresses = append(subset.NotReadyAddresses, corev1.EndpointAddress{IP: ip})
+	}
+
+	return &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "logging", Name: "fluentd"},
+		Subsets:    []corev1.EndpointSubset{subset},
+	}
+}
+
+var _ = Describe("KubeEndpointDiscovery", func() {
+	var (
+		clientset *fake.Clientset
+		discovery *KubeEndpointDiscovery
+		ctx       context.Context
+		cancel    context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		clientset = fake.NewSimpleClientset(endpoints([]string{"10.0.0.1"}, []string{"10.0.0.2"}))
+		discovery = &KubeEndpointDiscovery{
+			Client:    clientset,
+			Namespace: "logging",
+			Name:      "fluentd",
+			PortName:  "forward",
+		}
+
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("sends the ready addresses, and again when they change", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.1:24224"})))
+
+		_, err = clientset.CoreV1().Endpoints("logging").Update(
+			ctx, endpoints([]string{"10.0.0.1", "10.0.0.2"}, nil), metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(updates).Should(Receive(Equal([]client.ServerAddress{"10.0.0.1:24224", "10.0.0.2:24224"})))
+	})
+
+	It("sends an empty list when the Endpoints are deleted", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive())
+
+		Expect(clientset.CoreV1().Endpoints("logging").Delete(ctx, "fluentd", metav1.DeleteOptions{})).To(Succeed())
+
+		Eventually(updates).Should(Receive(BeEmpty()))
+	})
+
+	It("closes the channel when the context is done", func() {
+		updates, err := discovery.Watch(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(updates).Should(Receive())
+
+		cancel()
+		Eventually(updates).Should(BeClosed())
+	})
+
+	It("formats addresses with Format", func() {
+		discovery.Format = func(ip string, port int32) client.ServerAddress {
+			return client.ServerAddress(fmt.Sprintf("ws://%s:%d/ingest", ip, port))
+		}
+
+		Expect(discovery.Addresses(endpoints([]string{"10.0.0.1"}, nil))).To(Equal([]client.ServerAddress{
+			"ws://10.0.0.1:24224/ingest",
+		}))
+	})
+})
@@ -0,0 +1,178 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package discovery finds the Fluent Forward servers to send to at
+// runtime, instead of from a fixed address.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+//go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
+
+// Resolver looks up DNS SRV records. *net.Resolver implements it.
+//
+//counterfeiter:generate . Resolver
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVDiscovery finds servers from DNS SRV records, such as those
+// Kubernetes publishes for the named ports of a headless service.
+type SRVDiscovery struct {
+	// Resolver looks up the records. If nil, net.DefaultResolver is used.
+	Resolver Resolver
+}
+
+// Lookup returns the address of each server in the SRV records for
+// _service._proto.domain, ordered by priority and randomized by weight as
+// described in RFC 2782. If service and proto are empty, domain is looked
+// up as is.
+func (d *SRVDiscovery) Lookup(ctx context.Context, service, proto, domain string) ([]client.ServerAddress, error) {
+	var resolver Resolver = net.DefaultResolver
+	if d.Resolver != nil {
+		resolver = d.Resolver
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, service, proto, domain)
+	if err != nil {
+		return nil, fmt.Errorf("lookup SRV %s: %w", domain, err)
+	}
+
+	addresses := make([]client.ServerAddress, 0, len(records))
+	for _, srv := range records {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addresses = append(addresses, client.ServerAddress(net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))))
+	}
+
+	return addresses, nil
+}
+
+// Redialer is a MessageSender that can be pointed at another server,
+// which it connects to at its next Connect or Reconnect. tcp.TCPClient
+// implements it.
+//
+//counterfeiter:generate . Redialer
+type Redialer interface {
+	client.MessageSender
+	SetAddress(address string)
+	Connect() error
+	Reconnect() error
+	Disconnect() error
+}
+
+// SRVRefreshClient sends with a Redialer whose server is found by SRV
+// discovery. The records are looked up again on every Connect and
+// Reconnect, so the client follows the servers as they move.
+type SRVRefreshClient struct {
+	Discovery *SRVDiscovery
+	Service   string
+	Proto     string
+	Domain    string
+	Client    Redialer
+	// OnLookupError, if not nil, is called when a lookup fails and the
+	// client reconnects to the server it last used instead.
+	OnLookupError func(err error)
+
+	lock    sync.Mutex
+	address client.ServerAddress
+}
+
+// Connect looks up the servers and connects to the first.
+func (c *SRVRefreshClient) Connect(ctx context.Context) error {
+	if err := c.refresh(ctx); err != nil {
+		return err
+	}
+
+	return c.Client.Connect()
+}
+
+// Reconnect looks up the servers again and reconnects to the first. If the
+// lookup fails, the client reconnects to the server it last used.
+func (c *SRVRefreshClient) Reconnect(ctx context.Context) error {
+	if err := c.refresh(ctx); err != nil {
+		return err
+	}
+
+	return c.Client.Reconnect()
+}
+
+// Disconnect ends the client's connection.
+func (c *SRVRefreshClient) Disconnect() error {
+	return c.Client.Disconnect()
+}
+
+// Address returns the server the client was last pointed at.
+func (c *SRVRefreshClient) Address() client.ServerAddress {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.address
+}
+
+func (c *SRVRefreshClient) Send(e protocol.ChunkEncoder) error {
+	return c.Client.Send(e)
+}
+
+func (c *SRVRefreshClient) SendRaw(raw []byte) error {
+	return c.Client.SendRaw(raw)
+}
+
+// refresh points the client at the first server found. A failed lookup
+// is only an error if there is no previous server to fall back to.
+func (c *SRVRefreshClient) refresh(ctx context.Context) error {
+	addresses, err := c.Discovery.Lookup(ctx, c.Service, c.Proto, c.Domain)
+	if err == nil && len(addresses) == 0 {
+		err = fmt.Errorf("lookup SRV %s: no records", c.Domain)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err != nil {
+		if c.address == "" {
+			return err
+		}
+
+		if c.OnLookupError != nil {
+			c.OnLookupError(err)
+		}
+
+		return nil
+	}
+
+	c.address = addresses[0]
+	c.Client.SetAddress(string(c.address))
+
+	return nil
+}
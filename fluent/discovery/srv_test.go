@@ -0,0 +1,143 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/client/tcp"
+	. "github.com/IBM/fluent-forward-go/fluent/discovery"
+	"github.com/IBM/fluent-forward-go/fluent/discovery/discoveryfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SRVDiscovery", func() {
+	var (
+		resolver  *discoveryfakes.FakeResolver
+		discovery *SRVDiscovery
+	)
+
+	BeforeEach(func() {
+		resolver = &discoveryfakes.FakeResolver{}
+		resolver.LookupSRVReturns("", []*net.SRV{
+			{Target: "fluentd-0.fluentd.logging.svc.", Port: 24224},
+			{Target: "fluentd-1.fluentd.logging.svc.", Port: 24225},
+		}, nil)
+
+		discovery = &SRVDiscovery{Resolver: resolver}
+	})
+
+	It("maps the records to server addresses", func() {
+		addresses, err := discovery.Lookup(context.Background(), "forward", "tcp", "fluentd.logging.svc")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(addresses).To(Equal([]client.ServerAddress{
+			"fluentd-0.fluentd.logging.svc:24224",
+			"fluentd-1.fluentd.logging.svc:24225",
+		}))
+
+		_, service, proto, name := resolver.LookupSRVArgsForCall(0)
+		Expect([]string{service, proto, name}).To(Equal([]string{"forward", "tcp", "fluentd.logging.svc"}))
+	})
+
+	It("returns lookup errors", func() {
+		resolver.LookupSRVReturns("", nil, errors.New("nope"))
+
+		_, err := discovery.Lookup(context.Background(), "forward", "tcp", "fluentd.logging.svc")
+		Expect(err).To(MatchError("lookup SRV fluentd.logging.svc: nope"))
+	})
+})
+
+var _ Redialer = &tcp.TCPClient{}
+
+var _ = Describe("SRVRefreshClient", func() {
+	var (
+		resolver *discoveryfakes.FakeResolver
+		redialer *discoveryfakes.FakeRedialer
+		c        *SRVRefreshClient
+	)
+
+	BeforeEach(func() {
+		resolver = &discoveryfakes.FakeResolver{}
+		resolver.LookupSRVReturns("", []*net.SRV{{Target: "a.", Port: 1}}, nil)
+		redialer = &discoveryfakes.FakeRedialer{}
+
+		c = &SRVRefreshClient{
+			Discovery: &SRVDiscovery{Resolver: resolver},
+			Service:   "forward",
+			Proto:     "tcp",
+			Domain:    "fluentd",
+			Client:    redialer,
+		}
+	})
+
+	It("connects to the first server found", func() {
+		Expect(c.Connect(context.Background())).To(Succeed())
+
+		Expect(redialer.SetAddressArgsForCall(0)).To(Equal("a:1"))
+		Expect(redialer.ConnectCallCount()).To(Equal(1))
+		Expect(c.Address()).To(Equal(client.ServerAddress("a:1")))
+	})
+
+	It("looks the servers up again on Reconnect", func() {
+		Expect(c.Connect(context.Background())).To(Succeed())
+
+		resolver.LookupSRVReturns("", []*net.SRV{{Target: "b.", Port: 2}}, nil)
+		Expect(c.Reconnect(context.Background())).To(Succeed())
+
+		Expect(redialer.SetAddressArgsForCall(1)).To(Equal("b:2"))
+		Expect(redialer.ReconnectCallCount()).To(Equal(1))
+	})
+
+	It("reconnects to the last server when the lookup fails", func() {
+		var lookupErr error
+		c.OnLookupError = func(err error) { lookupErr = err }
+
+		Expect(c.Connect(context.Background())).To(Succeed())
+
+		resolver.LookupSRVReturns("", nil, errors.New("nope"))
+		Expect(c.Reconnect(context.Background())).To(Succeed())
+
+		Expect(lookupErr).To(MatchError(ContainSubstring("nope")))
+		Expect(redialer.SetAddressCallCount()).To(Equal(1))
+		Expect(redialer.ReconnectCallCount()).To(Equal(1))
+		Expect(c.Address()).To(Equal(client.ServerAddress("a:1")))
+	})
+
+	It("fails to connect when there is no server to fall back to", func() {
+		resolver.LookupSRVReturns("", nil, nil)
+
+		Expect(c.Connect(context.Background())).To(MatchError("lookup SRV fluentd: no records"))
+		Expect(redialer.ConnectCallCount()).To(BeZero())
+	})
+
+	It("sends with the client", func() {
+		Expect(c.SendRaw([]byte("oi"))).To(Succeed())
+		Expect(redialer.SendRawArgsForCall(0)).To(Equal([]byte("oi")))
+	})
+})
@@ -0,0 +1,201 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package durability
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// ReliableClient sends messages through a WSClient in ack mode, keeping
+// each message in a WAL until the peer acknowledges it. Messages that are
+// not acknowledged are sent again each time the client connects, including
+// messages left in the WAL by a previous process, so every message is
+// delivered at least once.
+//
+// The WSClient must have AckMode enabled and use the default msgpack
+// Codec.
+type ReliableClient struct {
+	Client *client.WSClient
+	WAL    *WAL
+	// OnResendError, if not nil, is called when resending after a connect
+	// fails. The messages that were not resent are kept for the next
+	// connect.
+	OnResendError func(err error)
+
+	inflight map[uint64]bool
+	lock     sync.Mutex
+}
+
+// NewReliableClient returns a ReliableClient that sends with c and keeps
+// messages in wal. It wraps c.OnConnect to resend unacknowledged messages,
+// so it must be called before c connects.
+func NewReliableClient(c *client.WSClient, wal *WAL) *ReliableClient {
+	r := &ReliableClient{
+		Client:   c,
+		WAL:      wal,
+		inflight: map[uint64]bool{},
+	}
+
+	onConnect := c.OnConnect
+	c.OnConnect = func() {
+		if onConnect != nil {
+			onConnect()
+		}
+
+		// resending waits for acks, which are read by the connection
+		// that is still being set up, so it cannot block the connect
+		go func() {
+			if err := r.Resend(context.Background()); err != nil && r.OnResendError != nil {
+				r.OnResendError(err)
+			}
+		}()
+	}
+
+	return r
+}
+
+// Send appends e to the WAL and syncs it, sends it, and commits it once
+// the peer acknowledges it. If Send returns an error, e stays in the WAL
+// and is sent again by Resend.
+func (r *ReliableClient) Send(ctx context.Context, e protocol.ChunkEncoder) error {
+	// the chunk must be set before encoding, so that the WAL copy can be
+	// matched to its ack when it is resent
+	if _, err := e.Chunk(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := msgp.Encode(&buf, e); err != nil {
+		return err
+	}
+
+	offset, err := r.append(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	defer r.release(offset)
+
+	if err = r.WAL.Sync(); err != nil {
+		return err
+	}
+
+	if _, err = r.Client.SendMessageAck(ctx, e); err != nil {
+		return err
+	}
+
+	return r.WAL.Commit(offset)
+}
+
+// Resend sends the uncommitted messages in the WAL that are not already
+// being sent, in the order they were appended, committing each as it is
+// acknowledged. It stops at the first error.
+func (r *ReliableClient) Resend(ctx context.Context) error {
+	for _, entry := range r.WAL.Uncommitted() {
+		if !r.claim(entry.Offset) {
+			continue
+		}
+
+		err := r.resend(ctx, entry)
+		r.release(entry.Offset)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ReliableClient) resend(ctx context.Context, entry Entry) error {
+	chunk, err := protocol.GetChunk(entry.Data)
+	if err != nil {
+		return err
+	}
+
+	if _, err = r.Client.SendMessageAck(ctx, &rawMessage{chunk: chunk, raw: entry.Data}); err != nil {
+		return err
+	}
+
+	return r.WAL.Commit(entry.Offset)
+}
+
+// append appends data to the WAL and marks it as being sent, so that a
+// concurrent Resend does not send it too.
+func (r *ReliableClient) append(data []byte) (uint64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	offset, err := r.WAL.Append(data)
+	if err == nil {
+		r.inflight[offset] = true
+	}
+
+	return offset, err
+}
+
+// claim marks the entry at offset as being sent. It returns false if it
+// already is.
+func (r *ReliableClient) claim(offset uint64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.inflight[offset] {
+		return false
+	}
+
+	r.inflight[offset] = true
+
+	return true
+}
+
+func (r *ReliableClient) release(offset uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.inflight, offset)
+}
+
+// rawMessage is a message that has already been encoded, with the chunk
+// it was encoded with.
+type rawMessage struct {
+	chunk string
+	raw   []byte
+}
+
+func (m *rawMessage) Chunk() (string, error) {
+	return m.chunk, nil
+}
+
+func (m *rawMessage) EncodeMsg(w *msgp.Writer) error {
+	_, err := w.Write(m.raw)
+	return err
+}
@@ -0,0 +1,195 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package durability_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/fluent-forward-go/fluent/client"
+	"github.com/IBM/fluent-forward-go/fluent/durability"
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// ackServer acknowledges the messages it receives while acking is set,
+// and records the tags of those it acknowledges.
+type ackServer struct {
+	*httptest.Server
+
+	acking int32
+	lock   sync.Mutex
+	tags   []string
+}
+
+func newAckServer() *ackServer {
+	s := &ackServer{}
+	atomic.StoreInt32(&s.acking, 1)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var upgrader websocket.Upgrader
+
+		wc, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		defer wc.Close()
+
+		for {
+			_, p, err := wc.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			chunk, err := protocol.GetChunk(p)
+			if err != nil || atomic.LoadInt32(&s.acking) == 0 {
+				continue
+			}
+
+			var msg protocol.Message
+			if _, err = msg.UnmarshalMsg(p); err == nil {
+				s.lock.Lock()
+				s.tags = append(s.tags, msg.Tag)
+				s.lock.Unlock()
+			}
+
+			ack, _ := (&protocol.AckMessage{Ack: chunk}).MarshalMsg(nil)
+			if err = wc.WriteMessage(websocket.BinaryMessage, ack); err != nil {
+				return
+			}
+		}
+	}))
+
+	return s
+}
+
+func (s *ackServer) Tags() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.tags...)
+}
+
+var _ = Describe("ReliableClient", func() {
+	var (
+		svr  *ackServer
+		path string
+		wal  *durability.WAL
+		wc   *client.WSClient
+		rc   *durability.ReliableClient
+	)
+
+	newClient := func() {
+		var err error
+		wal, err = durability.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+
+		wc = client.NewWS(client.WSConnectionOptions{
+			Factory: &client.DefaultWSConnectionFactory{
+				URL: "ws" + strings.TrimPrefix(svr.URL, "http"),
+			},
+			AckMode:    true,
+			AckTimeout: 100 * time.Millisecond,
+		})
+		rc = durability.NewReliableClient(wc, wal)
+	}
+
+	closeClient := func() {
+		Expect(wc.Disconnect()).To(Succeed())
+		Expect(wal.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "ffg")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+
+		path = filepath.Join(dir, "wal")
+		svr = newAckServer()
+		DeferCleanup(svr.Close)
+
+		newClient()
+		Expect(wc.Connect()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		closeClient()
+	})
+
+	It("commits acknowledged messages", func() {
+		Expect(rc.Send(context.Background(), protocol.NewMessage("foo", nil))).To(Succeed())
+
+		Expect(svr.Tags()).To(Equal([]string{"foo"}))
+		Expect(wal.Uncommitted()).To(BeEmpty())
+	})
+
+	It("keeps unacknowledged messages and resends them on reconnect", func() {
+		atomic.StoreInt32(&svr.acking, 0)
+		Expect(rc.Send(context.Background(), protocol.NewMessage("foo", nil))).To(MatchError(client.ErrAckTimeout))
+		Expect(wal.Uncommitted()).To(HaveLen(1))
+
+		atomic.StoreInt32(&svr.acking, 1)
+		Expect(wc.Reconnect()).To(Succeed())
+
+		Eventually(wal.Uncommitted).Should(BeEmpty())
+		Expect(svr.Tags()).To(Equal([]string{"foo"}))
+	})
+
+	It("resends messages left by a previous process", func() {
+		atomic.StoreInt32(&svr.acking, 0)
+		Expect(rc.Send(context.Background(), protocol.NewMessage("foo", nil))).ToNot(Succeed())
+		Expect(rc.Send(context.Background(), protocol.NewMessage("bar", nil))).ToNot(Succeed())
+		closeClient()
+
+		atomic.StoreInt32(&svr.acking, 1)
+		newClient()
+		Expect(wc.Connect()).To(Succeed())
+
+		Eventually(wal.Uncommitted).Should(BeEmpty())
+		Expect(svr.Tags()).To(Equal([]string{"foo", "bar"}))
+	})
+
+	It("reports resend failures", func() {
+		atomic.StoreInt32(&svr.acking, 0)
+		Expect(rc.Send(context.Background(), protocol.NewMessage("foo", nil))).ToNot(Succeed())
+
+		errs := make(chan error, 1)
+		rc.OnResendError = func(err error) { errs <- err }
+
+		Expect(wc.Reconnect()).To(Succeed())
+		Eventually(errs).Should(Receive(MatchError(client.ErrAckTimeout)))
+		Expect(wal.Uncommitted()).To(HaveLen(1))
+	})
+})
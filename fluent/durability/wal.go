@@ -0,0 +1,345 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package durability provides building blocks for delivering messages at
+// least once across connection failures and process restarts.
+package durability
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrClosed is returned by WAL methods called after Close.
+var ErrClosed = errors.New("WAL is closed")
+
+// ErrUnknownOffset is returned by Commit for an offset that is not an
+// uncommitted entry.
+var ErrUnknownOffset = errors.New("no uncommitted entry at offset")
+
+const (
+	// Each record is a type byte, the big-endian uint64 offset of its
+	// entry and a big-endian uint32 data length, followed by the data.
+	// Commit records have no data. A next record, with which Compact
+	// starts the file, holds the offset of the next entry to append, so
+	// that offsets are not reused after the entries before it are gone.
+	recordAppend byte = 1
+	recordCommit byte = 2
+	recordNext   byte = 3
+
+	recordHeaderSize = 13
+)
+
+// Entry is an uncommitted entry in a WAL.
+type Entry struct {
+	Offset uint64
+	Data   []byte
+}
+
+// WAL is an append-only write-ahead log of entries that are committed once
+// they no longer need to be kept, e.g. when the message they hold has been
+// acknowledged. Entries that were appended but not committed when the
+// process stopped are returned by Uncommitted after the next Open.
+//
+// Append writes each entry through to the file, so entries survive the
+// process crashing; Commit and Sync also fsync the file, so that entries
+// survive the machine crashing. A WAL is safe for concurrent use, but only
+// one process may use a file at a time.
+type WAL struct {
+	path    string
+	file    *os.File
+	w       *bufio.Writer
+	next    uint64
+	pending map[uint64][]byte
+	lock    sync.Mutex
+}
+
+// Open opens the WAL in the file at path, creating it if it does not
+// exist. A record cut short by a crash is discarded.
+func Open(path string) (*WAL, error) {
+	wal := &WAL{
+		path:    path,
+		pending: map[uint64][]byte{},
+	}
+
+	size, err := wal.load()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop any partial record so that new records follow the last whole one
+	if err = file.Truncate(size); err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	wal.file = file
+	wal.w = bufio.NewWriter(file)
+
+	return wal, nil
+}
+
+// load reads the records in the file into pending and returns the size of
+// the whole records.
+func (wal *WAL) load() (int64, error) {
+	f, err := os.Open(wal.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	var (
+		r      = bufio.NewReader(f)
+		header [recordHeaderSize]byte
+		size   int64
+	)
+
+	for {
+		if _, err = io.ReadFull(r, header[:]); err != nil {
+			break
+		}
+
+		offset := binary.BigEndian.Uint64(header[1:9])
+		data := make([]byte, binary.BigEndian.Uint32(header[9:]))
+
+		if _, err = io.ReadFull(r, data); err != nil {
+			break
+		}
+
+		switch header[0] {
+		case recordAppend:
+			wal.pending[offset] = data
+
+			if offset >= wal.next {
+				wal.next = offset + 1
+			}
+		case recordCommit:
+			delete(wal.pending, offset)
+		case recordNext:
+			if offset > wal.next {
+				wal.next = offset
+			}
+		default:
+			return 0, fmt.Errorf("WAL %s: bad record type %d", wal.path, header[0])
+		}
+
+		size += int64(recordHeaderSize + len(data))
+	}
+
+	if err != io.EOF && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// Append adds an entry holding data and returns its offset. Offsets
+// increase with each entry.
+func (wal *WAL) Append(data []byte) (uint64, error) {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.file == nil {
+		return 0, ErrClosed
+	}
+
+	offset := wal.next
+	if err := wal.write(recordAppend, offset, data); err != nil {
+		return 0, err
+	}
+
+	wal.next++
+	wal.pending[offset] = append([]byte(nil), data...)
+
+	return offset, nil
+}
+
+// Commit marks the entry at offset as no longer needed and syncs the file.
+func (wal *WAL) Commit(offset uint64) error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.file == nil {
+		return ErrClosed
+	}
+
+	if _, ok := wal.pending[offset]; !ok {
+		return fmt.Errorf("%w %d", ErrUnknownOffset, offset)
+	}
+
+	if err := wal.write(recordCommit, offset, nil); err != nil {
+		return err
+	}
+
+	delete(wal.pending, offset)
+
+	return wal.file.Sync()
+}
+
+// Sync syncs the file, so that every entry appended so far survives the
+// machine crashing.
+func (wal *WAL) Sync() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.file == nil {
+		return ErrClosed
+	}
+
+	return wal.file.Sync()
+}
+
+// Uncommitted returns the entries that have not been committed, in offset
+// order.
+func (wal *WAL) Uncommitted() []Entry {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	entries := make([]Entry, 0, len(wal.pending))
+	for offset, data := range wal.pending {
+		entries = append(entries, Entry{Offset: offset, Data: data})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Offset < entries[j].Offset })
+
+	return entries
+}
+
+// Compact rewrites the file with only the uncommitted entries, keeping
+// their offsets and the offset of the next entry.
+func (wal *WAL) Compact() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.file == nil {
+		return ErrClosed
+	}
+
+	tmp := wal.path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+
+	err = writeRecord(w, recordNext, wal.next, nil)
+	for offset, data := range wal.pending {
+		if err != nil {
+			break
+		}
+
+		err = writeRecord(w, recordAppend, offset, data)
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+
+		return err
+	}
+
+	if err = os.Rename(tmp, wal.path); err != nil {
+		f.Close()
+		os.Remove(tmp)
+
+		return err
+	}
+
+	wal.file.Close()
+	wal.file = f
+	wal.w.Reset(f)
+
+	return nil
+}
+
+// Close closes the file. Uncommitted entries are kept for the next Open.
+func (wal *WAL) Close() error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.file == nil {
+		return nil
+	}
+
+	err := wal.file.Close()
+	wal.file = nil
+
+	return err
+}
+
+// write writes a record through to the file. The caller must hold the
+// lock.
+func (wal *WAL) write(kind byte, offset uint64, data []byte) error {
+	if err := writeRecord(wal.w, kind, offset, data); err != nil {
+		return err
+	}
+
+	return wal.w.Flush()
+}
+
+func writeRecord(w io.Writer, kind byte, offset uint64, data []byte) error {
+	var header [recordHeaderSize]byte
+
+	header[0] = kind
+	binary.BigEndian.PutUint64(header[1:9], offset)
+	binary.BigEndian.PutUint32(header[9:], uint32(len(data)))
+
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+
+	return err
+}
@@ -0,0 +1,158 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package durability_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/IBM/fluent-forward-go/fluent/durability"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WAL", func() {
+	var (
+		path string
+		wal  *durability.WAL
+	)
+
+	BeforeEach(func() {
+		dir, err := os.MkdirTemp("", "ffg")
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(os.RemoveAll, dir)
+
+		path = filepath.Join(dir, "wal")
+		wal, err = durability.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(wal.Close()).To(Succeed())
+	})
+
+	reopen := func() {
+		Expect(wal.Close()).To(Succeed())
+
+		var err error
+		wal, err = durability.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	It("assigns increasing offsets", func() {
+		for i := uint64(0); i < 3; i++ {
+			Expect(wal.Append([]byte("oi"))).To(Equal(i))
+		}
+	})
+
+	It("keeps uncommitted entries across Open", func() {
+		one, err := wal.Append([]byte("one"))
+		Expect(err).ToNot(HaveOccurred())
+		two, err := wal.Append([]byte("two"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Commit(one)).To(Succeed())
+
+		reopen()
+
+		Expect(wal.Uncommitted()).To(Equal([]durability.Entry{{Offset: two, Data: []byte("two")}}))
+		Expect(wal.Append([]byte("three"))).To(Equal(two + 1))
+	})
+
+	It("rejects commits of unknown offsets", func() {
+		Expect(wal.Commit(7)).To(MatchError(durability.ErrUnknownOffset))
+
+		offset, err := wal.Append([]byte("oi"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Commit(offset)).To(Succeed())
+		Expect(wal.Commit(offset)).To(MatchError(durability.ErrUnknownOffset))
+	})
+
+	It("discards a record cut short by a crash", func() {
+		_, err := wal.Append([]byte("one"))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = wal.Append([]byte("two"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Close()).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.Truncate(path, info.Size()-1)).To(Succeed())
+
+		reopen()
+		Expect(wal.Uncommitted()).To(Equal([]durability.Entry{{Offset: 0, Data: []byte("one")}}))
+
+		_, err = wal.Append([]byte("three"))
+		Expect(err).ToNot(HaveOccurred())
+
+		reopen()
+		Expect(wal.Uncommitted()).To(Equal([]durability.Entry{
+			{Offset: 0, Data: []byte("one")},
+			{Offset: 1, Data: []byte("three")},
+		}))
+	})
+
+	It("compacts to the uncommitted entries", func() {
+		for i := 0; i < 100; i++ {
+			offset, err := wal.Append(make([]byte, 64))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(wal.Commit(offset)).To(Succeed())
+		}
+
+		kept, err := wal.Append([]byte("kept"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Compact()).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeNumerically("<", 64))
+
+		Expect(wal.Append([]byte("after"))).To(Equal(kept + 1))
+
+		reopen()
+		Expect(wal.Uncommitted()).To(Equal([]durability.Entry{
+			{Offset: kept, Data: []byte("kept")},
+			{Offset: kept + 1, Data: []byte("after")},
+		}))
+	})
+
+	It("keeps the next offset when compacting every entry away", func() {
+		offset, err := wal.Append([]byte("one"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wal.Commit(offset)).To(Succeed())
+		Expect(wal.Compact()).To(Succeed())
+
+		reopen()
+		Expect(wal.Uncommitted()).To(BeEmpty())
+		Expect(wal.Append([]byte("two"))).To(Equal(offset + 1))
+	})
+
+	It("returns ErrClosed after Close", func() {
+		Expect(wal.Close()).To(Succeed())
+
+		_, err := wal.Append([]byte("oi"))
+		Expect(err).To(MatchError(durability.ErrClosed))
+		Expect(wal.Commit(0)).To(MatchError(durability.ErrClosed))
+	})
+})
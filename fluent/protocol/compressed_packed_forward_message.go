@@ -0,0 +1,234 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// CompressionAlgorithm identifies how the event stream of a
+// CompressedPackedForwardMessage is compressed.
+type CompressionAlgorithm uint8
+
+// CompressionGzip is the zero value, so that messages built before the
+// algorithm could be chosen keep using gzip.
+const (
+	CompressionGzip CompressionAlgorithm = iota
+	CompressionZstd
+	// CompressionNone sends the event stream as it is, without setting
+	// the "compressed" option.
+	CompressionNone
+)
+
+const OptValZSTD string = "zstd"
+
+// String returns the value used for the "compressed" option.
+func (ca CompressionAlgorithm) String() string {
+	switch ca {
+	case CompressionGzip:
+		return OptValGZIP
+	case CompressionZstd:
+		return OptValZSTD
+	case CompressionNone:
+		return "none"
+	default:
+		return fmt.Sprintf("CompressionAlgorithm(%d)", uint8(ca))
+	}
+}
+
+var zstdEncoders sync.Map // zstd.EncoderLevel -> *zstd.Encoder
+
+// CompressedPackedForwardMessage wraps a PackedForwardMessage whose event
+// stream is uncompressed. The stream is compressed each time the message
+// is encoded or marshaled, and the "compressed" option is set accordingly.
+// The wrapped message is not modified, except by Chunk.
+//
+//msgp:ignore CompressedPackedForwardMessage
+type CompressedPackedForwardMessage struct {
+	*PackedForwardMessage
+	CompressionAlgorithm CompressionAlgorithm
+	// CompressionLevel is passed to the compressor. If zero, the
+	// algorithm's default is used; for gzip, that is gzip.DefaultCompression.
+	CompressionLevel int
+}
+
+// NewCompressedPackedForward returns a CompressedPackedForwardMessage that
+// gzip-compresses msg at the default level.
+func NewCompressedPackedForward(msg *PackedForwardMessage) *CompressedPackedForwardMessage {
+	return &CompressedPackedForwardMessage{
+		PackedForwardMessage: msg,
+		CompressionAlgorithm: CompressionGzip,
+	}
+}
+
+func (msg *CompressedPackedForwardMessage) compress() (*PackedForwardMessage, error) {
+	var (
+		compressed []byte
+		err        error
+	)
+
+	switch msg.CompressionAlgorithm {
+	case CompressionNone:
+		return msg.PackedForwardMessage, nil
+	case CompressionGzip:
+		compressed, err = gzipBytes(msg.EventStream, msg.CompressionLevel)
+	case CompressionZstd:
+		compressed, err = zstdBytes(msg.EventStream, msg.CompressionLevel)
+	default:
+		err = fmt.Errorf("unsupported compression algorithm %s", msg.CompressionAlgorithm)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := MessageOptions{}
+	if msg.Options != nil {
+		opts = *msg.Options
+	}
+
+	opts.Compressed = msg.CompressionAlgorithm.String()
+
+	return &PackedForwardMessage{
+		Tag:         msg.Tag,
+		EventStream: compressed,
+		Options:     &opts,
+	}, nil
+}
+
+func gzipBytes(bits []byte, level int) ([]byte, error) {
+	if level == 0 || level == gzip.DefaultCompression {
+		mc := compressorPool.Get().(*GzipCompressor)
+		mc.Reset()
+
+		defer func() {
+			compressorPool.Put(mc)
+		}()
+
+		if err := mc.Write(bits); err != nil {
+			return nil, err
+		}
+
+		return append([]byte(nil), mc.Bytes()...), nil
+	}
+
+	var buf bytes.Buffer
+
+	gw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = gw.Write(bits); err != nil {
+		return nil, err
+	}
+
+	if err = gw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func zstdBytes(bits []byte, level int) ([]byte, error) {
+	encLevel := zstd.SpeedDefault
+	if level != 0 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	if enc, ok := zstdEncoders.Load(encLevel); ok {
+		return enc.(*zstd.Encoder).EncodeAll(bits, nil), nil
+	}
+
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := zstdEncoders.LoadOrStore(encLevel, enc)
+
+	return actual.(*zstd.Encoder).EncodeAll(bits, nil), nil
+}
+
+func (msg *CompressedPackedForwardMessage) EncodeMsg(dc *msgp.Writer) error {
+	pfm, err := msg.compress()
+	if err != nil {
+		return err
+	}
+
+	return pfm.EncodeMsg(dc)
+}
+
+func (msg *CompressedPackedForwardMessage) MarshalMsg(bits []byte) ([]byte, error) {
+	pfm, err := msg.compress()
+	if err != nil {
+		return bits, err
+	}
+
+	return pfm.MarshalMsg(bits)
+}
+
+// Msgsize returns an upper bound of the number of bytes occupied by the
+// serialized message. It allows for an event stream that does not
+// compress at all, which grows by the framing of the algorithm.
+func (msg *CompressedPackedForwardMessage) Msgsize() int {
+	if msg.CompressionAlgorithm == CompressionNone {
+		return msg.PackedForwardMessage.Msgsize()
+	}
+
+	opts := MessageOptions{}
+	if msg.Options != nil {
+		opts = *msg.Options
+	}
+
+	opts.Compressed = msg.CompressionAlgorithm.String()
+
+	pfm := PackedForwardMessage{Tag: msg.Tag, Options: &opts}
+
+	return pfm.Msgsize() + compressBound(msg.CompressionAlgorithm, len(msg.EventStream))
+}
+
+// compressBound is the largest size n bytes may be compressed to by ca.
+// For gzip, that is its 18-byte header and trailer, plus 5 bytes for each
+// stored deflate block, which holds at least 16K, and for the final and
+// flush blocks. For zstd, it is libzstd's ZSTD_COMPRESSBOUND.
+func compressBound(ca CompressionAlgorithm, n int) int {
+	if ca == CompressionGzip {
+		return n + 18 + 5*(n/(16<<10)+3)
+	}
+
+	bound := n + n>>8
+	if n < 128<<10 {
+		bound += (128<<10 - n) >> 11
+	}
+
+	return bound
+}
@@ -24,10 +24,30 @@ SOFTWARE.
 
 package protocol
 
-import "github.com/tinylib/msgp/msgp"
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tinylib/msgp/msgp"
+)
 
 //go:generate msgp
 
+// ErrReservedOption is returned when encoding a ForwardMessage whose
+// ExtraOptions set an option that MessageOptions sets, such as "chunk".
+var ErrReservedOption = errors.New("option is reserved")
+
+// reservedOptions are the keys of the options encoded from MessageOptions.
+var reservedOptions = map[string]bool{
+	OptSize:        true,
+	OptChunk:       true,
+	OptCompressed:  true,
+	OptTraceParent: true,
+	OptTraceState:  true,
+	OptSeq:         true,
+}
+
 // ForwardMessage is used in Forward mode to send multiple events in a single
 // msgpack array within a single request.
 //
@@ -47,6 +67,12 @@ type ForwardMessage struct {
 	// Options - used to control server behavior.  Same as above, may need to
 	// switch to interface{} or similar at some point.
 	Options *MessageOptions
+	// ExtraOptions are custom metadata merged into the encoded options,
+	// e.g. "source_host", which Fluentd plugins can read from the chunk's
+	// options. Their keys must not be those of MessageOptions, or encoding
+	// fails with ErrReservedOption. When decoding, the options that are
+	// not part of MessageOptions are kept here.
+	ExtraOptions map[string]interface{}
 }
 
 // NewForwardMessage creates a ForwardMessage from the supplied
@@ -72,7 +98,7 @@ func NewForwardMessage(
 
 func (fm *ForwardMessage) EncodeMsg(dc *msgp.Writer) error {
 	size := 2
-	if fm.Options != nil {
+	if fm.hasOptions() {
 		size = 3
 	}
 
@@ -92,11 +118,17 @@ func (fm *ForwardMessage) EncodeMsg(dc *msgp.Writer) error {
 	}
 
 	// if the options were included, inlcude them in our encoded message
+	if size == 3 && len(fm.ExtraOptions) == 0 {
+		return fm.Options.EncodeMsg(dc)
+	}
+
 	if size == 3 {
-		err = fm.Options.EncodeMsg(dc)
+		bits, err := fm.appendOptions(nil)
 		if err != nil {
 			return err
 		}
+
+		return dc.Append(bits...)
 	}
 
 	return nil
@@ -127,10 +159,16 @@ func (fm *ForwardMessage) DecodeMsg(dc *msgp.Reader) error {
 			return dc.ReadNil()
 		}
 
-		fm.Options = &MessageOptions{}
-		if err = fm.Options.DecodeMsg(dc); err != nil {
+		// the options are read whole so that those MessageOptions does
+		// not know can be kept in ExtraOptions
+		var raw msgp.Raw
+		if err = raw.DecodeMsg(dc); err != nil {
 			return msgp.WrapError(err, "Options")
 		}
+
+		if _, err = fm.unmarshalOptions(raw); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -142,7 +180,7 @@ func (fm *ForwardMessage) MarshalMsg(bits []byte) ([]byte, error) {
 		err error
 	)
 
-	if fm.Options != nil {
+	if fm.hasOptions() {
 		sz = 3
 	} else {
 		sz = 2
@@ -157,7 +195,7 @@ func (fm *ForwardMessage) MarshalMsg(bits []byte) ([]byte, error) {
 	}
 
 	if sz == 3 {
-		bits, err = fm.Options.MarshalMsg(bits)
+		bits, err = fm.appendOptions(bits)
 	}
 
 	return bits, err
@@ -188,15 +226,109 @@ func (fm *ForwardMessage) UnmarshalMsg(bits []byte) ([]byte, error) {
 			return msgp.ReadNilBytes(bits)
 		}
 
-		fm.Options = &MessageOptions{}
-		if bits, err = fm.Options.UnmarshalMsg(bits); err != nil {
-			return bits, msgp.WrapError(err, "Options")
-		}
+		bits, err = fm.unmarshalOptions(bits)
 	}
 
 	return bits, err
 }
 
+func (fm *ForwardMessage) hasOptions() bool {
+	return fm.Options != nil || len(fm.ExtraOptions) > 0
+}
+
+// appendOptions appends the options map: the fields of Options, if it is
+// not nil, then the ExtraOptions in key order.
+func (fm *ForwardMessage) appendOptions(bits []byte) ([]byte, error) {
+	opts := fm.Options
+	if opts == nil {
+		opts = &MessageOptions{}
+	}
+
+	if len(fm.ExtraOptions) == 0 {
+		return opts.MarshalMsg(bits)
+	}
+
+	keys := make([]string, 0, len(fm.ExtraOptions))
+
+	for k := range fm.ExtraOptions {
+		if reservedOptions[k] {
+			return bits, fmt.Errorf("%w: %q", ErrReservedOption, k)
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	encoded, err := opts.MarshalMsg(nil)
+	if err != nil {
+		return bits, err
+	}
+
+	sz, fields, err := msgp.ReadMapHeaderBytes(encoded)
+	if err != nil {
+		return bits, err
+	}
+
+	bits = msgp.AppendMapHeader(bits, sz+uint32(len(keys)))
+	bits = append(bits, fields...)
+
+	for _, k := range keys {
+		bits = msgp.AppendString(bits, k)
+
+		if bits, err = msgp.AppendIntf(bits, fm.ExtraOptions[k]); err != nil {
+			return bits, msgp.WrapError(err, "ExtraOptions", k)
+		}
+	}
+
+	return bits, nil
+}
+
+// unmarshalOptions reads the options map into Options and ExtraOptions.
+func (fm *ForwardMessage) unmarshalOptions(bits []byte) ([]byte, error) {
+	fm.Options = &MessageOptions{}
+
+	rest, err := fm.Options.UnmarshalMsg(bits)
+	if err != nil {
+		return rest, msgp.WrapError(err, "Options")
+	}
+
+	fm.ExtraOptions = nil
+
+	sz, b, err := msgp.ReadMapHeaderBytes(bits)
+	if err != nil {
+		return rest, msgp.WrapError(err, "Options")
+	}
+
+	for ; sz > 0; sz-- {
+		var key []byte
+		if key, b, err = msgp.ReadMapKeyZC(b); err != nil {
+			return rest, msgp.WrapError(err, "Options")
+		}
+
+		if reservedOptions[string(key)] {
+			if b, err = msgp.Skip(b); err != nil {
+				return rest, msgp.WrapError(err, "Options")
+			}
+
+			continue
+		}
+
+		var v interface{}
+		if v, b, err = msgp.ReadIntfBytes(b); err != nil {
+			return rest, msgp.WrapError(err, "ExtraOptions", string(key))
+		}
+
+		if fm.ExtraOptions == nil {
+			fm.ExtraOptions = map[string]interface{}{}
+		}
+
+		fm.ExtraOptions[string(key)] = v
+	}
+
+	return rest, nil
+}
+
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (fm *ForwardMessage) Msgsize() (s int) {
 	s = 1 + msgp.StringPrefixSize + len(fm.Tag) + fm.Entries.Msgsize()
@@ -204,6 +336,14 @@ func (fm *ForwardMessage) Msgsize() (s int) {
 		s += fm.Options.Msgsize()
 	}
 
+	if len(fm.ExtraOptions) > 0 {
+		s += msgp.MapHeaderSize
+
+		for k, v := range fm.ExtraOptions {
+			s += msgp.StringPrefixSize + len(k) + msgp.GuessSize(v)
+		}
+	}
+
 	return
 }
 
@@ -26,6 +26,7 @@ package protocol_test
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"time"
 
@@ -137,6 +138,197 @@ var _ = Describe("ForwardMessage", func() {
 			fwdmsg := protocol.ForwardMessage{}
 			_, err = fwdmsg.UnmarshalMsg(bits)
 			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fwdmsg.Tag).To(Equal("oi"))
+			Expect(fwdmsg.Options).To(BeNil())
+			Expect(fwdmsg.Entries).To(HaveLen(2))
+			// d7 00 60996ae4 0550e510
+			Expect(fwdmsg.Entries[0].Timestamp.Unix()).To(Equal(int64(0x60996ae4)))
+			Expect(fwdmsg.Entries[0].Timestamp.Nanosecond()).To(Equal(0x0550e510))
+			Expect(fwdmsg.Entries[0].Record).To(HaveKeyWithValue("cpu_p", 0.25))
+		})
+
+		It("Re-encodes real fluentbit messages to the same bytes", func() {
+			bits, err := ioutil.ReadFile("protocolfakes/forwarded_records.msgpack.bin")
+			Expect(err).ToNot(HaveOccurred())
+
+			fwdmsg := protocol.ForwardMessage{}
+			_, err = fwdmsg.UnmarshalMsg(bits)
+			Expect(err).NotTo(HaveOccurred())
+
+			// the records decode to maps, whose keys are encoded in no
+			// particular order, so they are re-encoded as they were read
+			records := rawRecords(bits)
+			Expect(records).To(HaveLen(len(fwdmsg.Entries)))
+
+			for i := range fwdmsg.Entries {
+				fwdmsg.Entries[i].Record = records[i]
+			}
+
+			var buf bytes.Buffer
+			Expect(msgp.Encode(&buf, &fwdmsg)).To(Succeed())
+			Expect(bytes.Equal(buf.Bytes(), bits)).To(BeTrue())
+
+			marshaled, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes.Equal(marshaled, bits)).To(BeTrue())
+		})
+
+		It("Encodes EventEntry values", func() {
+			fwdmsg := protocol.ForwardMessage{
+				Tag: "foo",
+				Entries: protocol.EntryList{
+					protocol.EventEntry{
+						Timestamp: protocol.EventTime{Time: time.Unix(1257894000, 0)},
+						Record:    map[string]interface{}{"a": 1},
+					},
+				},
+			}
+
+			bits, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			// ["foo", [[EventTime(1257894000, 0), {"a": 1}]]]
+			Expect(bits).To(Equal([]byte{
+				0x92, 0xa3, 'f', 'o', 'o',
+				0x91, 0x92, 0xd7, 0x00, 0x4a, 0xf9, 0xf0, 0x70, 0x00, 0x00, 0x00, 0x00,
+				0x81, 0xa1, 'a', 0x01,
+			}))
+		})
+
+		It("Deserializes entries with integer timestamps", func() {
+			// ["foo", [[1257894000, {"a": 1}]]]
+			bits := []byte{
+				0x92, 0xa3, 'f', 'o', 'o',
+				0x91, 0x92, 0xce, 0x4a, 0xf9, 0xf0, 0x70, 0x81, 0xa1, 'a', 0x01,
+			}
+
+			fwdmsg := protocol.ForwardMessage{}
+			_, err := fwdmsg.UnmarshalMsg(bits)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fwdmsg.Entries).To(HaveLen(1))
+			Expect(fwdmsg.Entries[0].Timestamp.Unix()).To(Equal(int64(1257894000)))
+
+			fwdmsg = protocol.ForwardMessage{}
+			Expect(fwdmsg.DecodeMsg(msgp.NewReader(bytes.NewReader(bits)))).To(Succeed())
+			Expect(fwdmsg.Entries[0].Record).To(HaveKeyWithValue("a", BeNumerically("==", 1)))
+		})
+	})
+
+	Describe("ExtraOptions", func() {
+		BeforeEach(func() {
+			fwdmsg.Options.Chunk = "abc"
+			fwdmsg.ExtraOptions = map[string]interface{}{
+				"source_host":      "web-1",
+				"pipeline_version": int64(3),
+			}
+		})
+
+		It("merges them into the options", func() {
+			b, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(b)).To(BeNumerically("<=", fwdmsg.Msgsize()))
+
+			arr, _, err := msgp.ReadIntfBytes(b)
+			Expect(err).NotTo(HaveOccurred())
+
+			decoded := arr.([]interface{})[2].(map[string]interface{})
+
+			Expect(decoded).To(HaveKeyWithValue("chunk", "abc"))
+			Expect(decoded).To(HaveKeyWithValue("size", BeNumerically("==", 2)))
+			Expect(decoded).To(HaveKeyWithValue("source_host", "web-1"))
+			Expect(decoded).To(HaveKeyWithValue("pipeline_version", BeNumerically("==", 3)))
 		})
+
+		It("round-trips with MarshalMsg and UnmarshalMsg", func() {
+			b, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var unmfwd protocol.ForwardMessage
+			rest, err := unmfwd.UnmarshalMsg(b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rest).To(BeEmpty())
+			Expect(unmfwd.Options.Chunk).To(Equal("abc"))
+			Expect(unmfwd.ExtraOptions).To(Equal(fwdmsg.ExtraOptions))
+		})
+
+		It("round-trips with EncodeMsg and DecodeMsg", func() {
+			var buf bytes.Buffer
+			Expect(msgp.Encode(&buf, fwdmsg)).To(Succeed())
+
+			var unmfwd protocol.ForwardMessage
+			Expect(msgp.Decode(&buf, &unmfwd)).To(Succeed())
+			Expect(unmfwd.Options.Chunk).To(Equal("abc"))
+			Expect(unmfwd.ExtraOptions).To(Equal(fwdmsg.ExtraOptions))
+		})
+
+		It("encodes them without Options", func() {
+			fwdmsg.Options = nil
+
+			b, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var unmfwd protocol.ForwardMessage
+			_, err = unmfwd.UnmarshalMsg(b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unmfwd.ExtraOptions).To(HaveKeyWithValue("source_host", "web-1"))
+		})
+
+		It("decodes no ExtraOptions from plain options", func() {
+			fwdmsg.ExtraOptions = nil
+
+			b, err := fwdmsg.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			var unmfwd protocol.ForwardMessage
+			_, err = unmfwd.UnmarshalMsg(b)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unmfwd.ExtraOptions).To(BeNil())
+		})
+
+		DescribeTable("rejects reserved keys",
+			func(key string) {
+				fwdmsg.ExtraOptions[key] = "x"
+
+				_, err := fwdmsg.MarshalMsg(nil)
+				Expect(err).To(MatchError(protocol.ErrReservedOption))
+
+				err = fwdmsg.EncodeMsg(msgp.NewWriter(io.Discard))
+				Expect(err).To(MatchError(protocol.ErrReservedOption))
+			},
+			Entry("size", protocol.OptSize),
+			Entry("chunk", protocol.OptChunk),
+			Entry("compressed", protocol.OptCompressed),
+			Entry("seq", protocol.OptSeq),
+		)
 	})
 })
+
+// rawRecords returns the encoded records of the Forward mode message in
+// bits.
+func rawRecords(bits []byte) []msgp.Raw {
+	_, bits, err := msgp.ReadArrayHeaderBytes(bits)
+	Expect(err).NotTo(HaveOccurred())
+	_, bits, err = msgp.ReadStringBytes(bits)
+	Expect(err).NotTo(HaveOccurred())
+
+	n, bits, err := msgp.ReadArrayHeaderBytes(bits)
+	Expect(err).NotTo(HaveOccurred())
+
+	records := make([]msgp.Raw, 0, n)
+
+	for i := uint32(0); i < n; i++ {
+		_, bits, err = msgp.ReadArrayHeaderBytes(bits)
+		Expect(err).NotTo(HaveOccurred())
+		bits, err = msgp.Skip(bits)
+		Expect(err).NotTo(HaveOccurred())
+
+		rest, err := msgp.Skip(bits)
+		Expect(err).NotTo(HaveOccurred())
+
+		records = append(records, msgp.Raw(bits[:len(bits)-len(rest)]))
+		bits = rest
+	}
+
+	return records
+}
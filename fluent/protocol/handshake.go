@@ -38,9 +38,10 @@ import (
 // =========
 
 const (
-	MsgTypeHelo = "HELO"
-	MsgTypePing = "PING"
-	MsgTypePong = "PONG"
+	MsgTypeHelo         = "HELO"
+	MsgTypePing         = "PING"
+	MsgTypePong         = "PONG"
+	MsgTypeCapabilities = "CAPABILITIES"
 )
 
 // Remember that the handshake flow is like this:
@@ -79,6 +80,44 @@ type HeloOpts struct {
 	Keepalive bool   `msg:"keepalive"`
 }
 
+// NewCapabilityRequest returns a HELO message sent by a client, rather
+// than a server, to ask which optional features the server supports.
+// opts, which may be nil, advertises what the client supports.
+func NewCapabilityRequest(opts *CapabilityOpts) *CapabilityRequest {
+	return &CapabilityRequest{MessageType: MsgTypeHelo, Options: opts}
+}
+
+// CapabilityRequest is the first message a client sends when it negotiates
+// capabilities. A server that supports negotiation replies with
+// Capabilities; older servers do not reply.
+//
+//msgp:tuple CapabilityRequest
+type CapabilityRequest struct {
+	MessageType string
+	Options     *CapabilityOpts
+}
+
+// NewCapabilities returns a CAPABILITIES message advertising opts.
+func NewCapabilities(opts *CapabilityOpts) *Capabilities {
+	return &Capabilities{MessageType: MsgTypeCapabilities, Options: opts}
+}
+
+// Capabilities is the server's reply to a CapabilityRequest.
+//
+//msgp:tuple Capabilities
+type Capabilities struct {
+	MessageType string
+	Options     *CapabilityOpts
+}
+
+type CapabilityOpts struct {
+	// Compression lists the supported values of the "compressed" option,
+	// e.g. "gzip".
+	Compression []string `msg:"compression"`
+	// Ack is true if chunk acknowledgements are supported.
+	Ack bool `msg:"ack"`
+}
+
 // NewPing returns a PING message.  The digest is computed
 // from the hostname, key, salt, and nonce using SHA512.
 func NewPing(hostname string, sharedKey, salt, nonce []byte) (*Ping, error) {
@@ -6,6 +6,674 @@ import (
 	"github.com/tinylib/msgp/msgp"
 )
 
+// DecodeMsg implements msgp.Decodable
+func (z *Capabilities) DecodeMsg(dc *msgp.Reader) (err error) {
+	var zb0001 uint32
+	zb0001, err = dc.ReadArrayHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if zb0001 != 2 {
+		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
+		return
+	}
+	z.MessageType, err = dc.ReadString()
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if dc.IsNil() {
+		err = dc.ReadNil()
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		z.Options = nil
+	} else {
+		if z.Options == nil {
+			z.Options = new(CapabilityOpts)
+		}
+		var field []byte
+		_ = field
+		var zb0002 uint32
+		zb0002, err = dc.ReadMapHeader()
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		for zb0002 > 0 {
+			zb0002--
+			field, err = dc.ReadMapKeyPtr()
+			if err != nil {
+				err = msgp.WrapError(err, "Options")
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "compression":
+				var zb0003 uint32
+				zb0003, err = dc.ReadArrayHeader()
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Compression")
+					return
+				}
+				if cap(z.Options.Compression) >= int(zb0003) {
+					z.Options.Compression = (z.Options.Compression)[:zb0003]
+				} else {
+					z.Options.Compression = make([]string, zb0003)
+				}
+				for za0001 := range z.Options.Compression {
+					z.Options.Compression[za0001], err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "Options", "Compression", za0001)
+						return
+					}
+				}
+			case "ack":
+				z.Options.Ack, err = dc.ReadBool()
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Ack")
+					return
+				}
+			default:
+				err = dc.Skip()
+				if err != nil {
+					err = msgp.WrapError(err, "Options")
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *Capabilities) EncodeMsg(en *msgp.Writer) (err error) {
+	// array header, size 2
+	err = en.Append(0x92)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.MessageType)
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if z.Options == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		// map header, size 2
+		// write "compression"
+		err = en.Append(0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteArrayHeader(uint32(len(z.Options.Compression)))
+		if err != nil {
+			err = msgp.WrapError(err, "Options", "Compression")
+			return
+		}
+		for za0001 := range z.Options.Compression {
+			err = en.WriteString(z.Options.Compression[za0001])
+			if err != nil {
+				err = msgp.WrapError(err, "Options", "Compression", za0001)
+				return
+			}
+		}
+		// write "ack"
+		err = en.Append(0xa3, 0x61, 0x63, 0x6b)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Options.Ack)
+		if err != nil {
+			err = msgp.WrapError(err, "Options", "Ack")
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *Capabilities) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// array header, size 2
+	o = append(o, 0x92)
+	o = msgp.AppendString(o, z.MessageType)
+	if z.Options == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		// map header, size 2
+		// string "compression"
+		o = append(o, 0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Options.Compression)))
+		for za0001 := range z.Options.Compression {
+			o = msgp.AppendString(o, z.Options.Compression[za0001])
+		}
+		// string "ack"
+		o = append(o, 0xa3, 0x61, 0x63, 0x6b)
+		o = msgp.AppendBool(o, z.Options.Ack)
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *Capabilities) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if zb0001 != 2 {
+		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
+		return
+	}
+	z.MessageType, bts, err = msgp.ReadStringBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if msgp.IsNil(bts) {
+		bts, err = msgp.ReadNilBytes(bts)
+		if err != nil {
+			return
+		}
+		z.Options = nil
+	} else {
+		if z.Options == nil {
+			z.Options = new(CapabilityOpts)
+		}
+		var field []byte
+		_ = field
+		var zb0002 uint32
+		zb0002, bts, err = msgp.ReadMapHeaderBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		for zb0002 > 0 {
+			zb0002--
+			field, bts, err = msgp.ReadMapKeyZC(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Options")
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "compression":
+				var zb0003 uint32
+				zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Compression")
+					return
+				}
+				if cap(z.Options.Compression) >= int(zb0003) {
+					z.Options.Compression = (z.Options.Compression)[:zb0003]
+				} else {
+					z.Options.Compression = make([]string, zb0003)
+				}
+				for za0001 := range z.Options.Compression {
+					z.Options.Compression[za0001], bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "Options", "Compression", za0001)
+						return
+					}
+				}
+			case "ack":
+				z.Options.Ack, bts, err = msgp.ReadBoolBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Ack")
+					return
+				}
+			default:
+				bts, err = msgp.Skip(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options")
+					return
+				}
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *Capabilities) Msgsize() (s int) {
+	s = 1 + msgp.StringPrefixSize + len(z.MessageType)
+	if z.Options == nil {
+		s += msgp.NilSize
+	} else {
+		s += 1 + 12 + msgp.ArrayHeaderSize
+		for za0001 := range z.Options.Compression {
+			s += msgp.StringPrefixSize + len(z.Options.Compression[za0001])
+		}
+		s += 4 + msgp.BoolSize
+	}
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *CapabilityOpts) DecodeMsg(dc *msgp.Reader) (err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, err = dc.ReadMapHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, err = dc.ReadMapKeyPtr()
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "compression":
+			var zb0002 uint32
+			zb0002, err = dc.ReadArrayHeader()
+			if err != nil {
+				err = msgp.WrapError(err, "Compression")
+				return
+			}
+			if cap(z.Compression) >= int(zb0002) {
+				z.Compression = (z.Compression)[:zb0002]
+			} else {
+				z.Compression = make([]string, zb0002)
+			}
+			for za0001 := range z.Compression {
+				z.Compression[za0001], err = dc.ReadString()
+				if err != nil {
+					err = msgp.WrapError(err, "Compression", za0001)
+					return
+				}
+			}
+		case "ack":
+			z.Ack, err = dc.ReadBool()
+			if err != nil {
+				err = msgp.WrapError(err, "Ack")
+				return
+			}
+		default:
+			err = dc.Skip()
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *CapabilityOpts) EncodeMsg(en *msgp.Writer) (err error) {
+	// map header, size 2
+	// write "compression"
+	err = en.Append(0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+	if err != nil {
+		return
+	}
+	err = en.WriteArrayHeader(uint32(len(z.Compression)))
+	if err != nil {
+		err = msgp.WrapError(err, "Compression")
+		return
+	}
+	for za0001 := range z.Compression {
+		err = en.WriteString(z.Compression[za0001])
+		if err != nil {
+			err = msgp.WrapError(err, "Compression", za0001)
+			return
+		}
+	}
+	// write "ack"
+	err = en.Append(0xa3, 0x61, 0x63, 0x6b)
+	if err != nil {
+		return
+	}
+	err = en.WriteBool(z.Ack)
+	if err != nil {
+		err = msgp.WrapError(err, "Ack")
+		return
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *CapabilityOpts) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// map header, size 2
+	// string "compression"
+	o = append(o, 0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+	o = msgp.AppendArrayHeader(o, uint32(len(z.Compression)))
+	for za0001 := range z.Compression {
+		o = msgp.AppendString(o, z.Compression[za0001])
+	}
+	// string "ack"
+	o = append(o, 0xa3, 0x61, 0x63, 0x6b)
+	o = msgp.AppendBool(o, z.Ack)
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *CapabilityOpts) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var field []byte
+	_ = field
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadMapHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	for zb0001 > 0 {
+		zb0001--
+		field, bts, err = msgp.ReadMapKeyZC(bts)
+		if err != nil {
+			err = msgp.WrapError(err)
+			return
+		}
+		switch msgp.UnsafeString(field) {
+		case "compression":
+			var zb0002 uint32
+			zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Compression")
+				return
+			}
+			if cap(z.Compression) >= int(zb0002) {
+				z.Compression = (z.Compression)[:zb0002]
+			} else {
+				z.Compression = make([]string, zb0002)
+			}
+			for za0001 := range z.Compression {
+				z.Compression[za0001], bts, err = msgp.ReadStringBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Compression", za0001)
+					return
+				}
+			}
+		case "ack":
+			z.Ack, bts, err = msgp.ReadBoolBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Ack")
+				return
+			}
+		default:
+			bts, err = msgp.Skip(bts)
+			if err != nil {
+				err = msgp.WrapError(err)
+				return
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *CapabilityOpts) Msgsize() (s int) {
+	s = 1 + 12 + msgp.ArrayHeaderSize
+	for za0001 := range z.Compression {
+		s += msgp.StringPrefixSize + len(z.Compression[za0001])
+	}
+	s += 4 + msgp.BoolSize
+	return
+}
+
+// DecodeMsg implements msgp.Decodable
+func (z *CapabilityRequest) DecodeMsg(dc *msgp.Reader) (err error) {
+	var zb0001 uint32
+	zb0001, err = dc.ReadArrayHeader()
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if zb0001 != 2 {
+		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
+		return
+	}
+	z.MessageType, err = dc.ReadString()
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if dc.IsNil() {
+		err = dc.ReadNil()
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		z.Options = nil
+	} else {
+		if z.Options == nil {
+			z.Options = new(CapabilityOpts)
+		}
+		var field []byte
+		_ = field
+		var zb0002 uint32
+		zb0002, err = dc.ReadMapHeader()
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		for zb0002 > 0 {
+			zb0002--
+			field, err = dc.ReadMapKeyPtr()
+			if err != nil {
+				err = msgp.WrapError(err, "Options")
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "compression":
+				var zb0003 uint32
+				zb0003, err = dc.ReadArrayHeader()
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Compression")
+					return
+				}
+				if cap(z.Options.Compression) >= int(zb0003) {
+					z.Options.Compression = (z.Options.Compression)[:zb0003]
+				} else {
+					z.Options.Compression = make([]string, zb0003)
+				}
+				for za0001 := range z.Options.Compression {
+					z.Options.Compression[za0001], err = dc.ReadString()
+					if err != nil {
+						err = msgp.WrapError(err, "Options", "Compression", za0001)
+						return
+					}
+				}
+			case "ack":
+				z.Options.Ack, err = dc.ReadBool()
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Ack")
+					return
+				}
+			default:
+				err = dc.Skip()
+				if err != nil {
+					err = msgp.WrapError(err, "Options")
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// EncodeMsg implements msgp.Encodable
+func (z *CapabilityRequest) EncodeMsg(en *msgp.Writer) (err error) {
+	// array header, size 2
+	err = en.Append(0x92)
+	if err != nil {
+		return
+	}
+	err = en.WriteString(z.MessageType)
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if z.Options == nil {
+		err = en.WriteNil()
+		if err != nil {
+			return
+		}
+	} else {
+		// map header, size 2
+		// write "compression"
+		err = en.Append(0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+		if err != nil {
+			return
+		}
+		err = en.WriteArrayHeader(uint32(len(z.Options.Compression)))
+		if err != nil {
+			err = msgp.WrapError(err, "Options", "Compression")
+			return
+		}
+		for za0001 := range z.Options.Compression {
+			err = en.WriteString(z.Options.Compression[za0001])
+			if err != nil {
+				err = msgp.WrapError(err, "Options", "Compression", za0001)
+				return
+			}
+		}
+		// write "ack"
+		err = en.Append(0xa3, 0x61, 0x63, 0x6b)
+		if err != nil {
+			return
+		}
+		err = en.WriteBool(z.Options.Ack)
+		if err != nil {
+			err = msgp.WrapError(err, "Options", "Ack")
+			return
+		}
+	}
+	return
+}
+
+// MarshalMsg implements msgp.Marshaler
+func (z *CapabilityRequest) MarshalMsg(b []byte) (o []byte, err error) {
+	o = msgp.Require(b, z.Msgsize())
+	// array header, size 2
+	o = append(o, 0x92)
+	o = msgp.AppendString(o, z.MessageType)
+	if z.Options == nil {
+		o = msgp.AppendNil(o)
+	} else {
+		// map header, size 2
+		// string "compression"
+		o = append(o, 0x82, 0xab, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e)
+		o = msgp.AppendArrayHeader(o, uint32(len(z.Options.Compression)))
+		for za0001 := range z.Options.Compression {
+			o = msgp.AppendString(o, z.Options.Compression[za0001])
+		}
+		// string "ack"
+		o = append(o, 0xa3, 0x61, 0x63, 0x6b)
+		o = msgp.AppendBool(o, z.Options.Ack)
+	}
+	return
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler
+func (z *CapabilityRequest) UnmarshalMsg(bts []byte) (o []byte, err error) {
+	var zb0001 uint32
+	zb0001, bts, err = msgp.ReadArrayHeaderBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err)
+		return
+	}
+	if zb0001 != 2 {
+		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
+		return
+	}
+	z.MessageType, bts, err = msgp.ReadStringBytes(bts)
+	if err != nil {
+		err = msgp.WrapError(err, "MessageType")
+		return
+	}
+	if msgp.IsNil(bts) {
+		bts, err = msgp.ReadNilBytes(bts)
+		if err != nil {
+			return
+		}
+		z.Options = nil
+	} else {
+		if z.Options == nil {
+			z.Options = new(CapabilityOpts)
+		}
+		var field []byte
+		_ = field
+		var zb0002 uint32
+		zb0002, bts, err = msgp.ReadMapHeaderBytes(bts)
+		if err != nil {
+			err = msgp.WrapError(err, "Options")
+			return
+		}
+		for zb0002 > 0 {
+			zb0002--
+			field, bts, err = msgp.ReadMapKeyZC(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Options")
+				return
+			}
+			switch msgp.UnsafeString(field) {
+			case "compression":
+				var zb0003 uint32
+				zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Compression")
+					return
+				}
+				if cap(z.Options.Compression) >= int(zb0003) {
+					z.Options.Compression = (z.Options.Compression)[:zb0003]
+				} else {
+					z.Options.Compression = make([]string, zb0003)
+				}
+				for za0001 := range z.Options.Compression {
+					z.Options.Compression[za0001], bts, err = msgp.ReadStringBytes(bts)
+					if err != nil {
+						err = msgp.WrapError(err, "Options", "Compression", za0001)
+						return
+					}
+				}
+			case "ack":
+				z.Options.Ack, bts, err = msgp.ReadBoolBytes(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options", "Ack")
+					return
+				}
+			default:
+				bts, err = msgp.Skip(bts)
+				if err != nil {
+					err = msgp.WrapError(err, "Options")
+					return
+				}
+			}
+		}
+	}
+	o = bts
+	return
+}
+
+// Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
+func (z *CapabilityRequest) Msgsize() (s int) {
+	s = 1 + msgp.StringPrefixSize + len(z.MessageType)
+	if z.Options == nil {
+		s += msgp.NilSize
+	} else {
+		s += 1 + 12 + msgp.ArrayHeaderSize
+		for za0001 := range z.Options.Compression {
+			s += msgp.StringPrefixSize + len(z.Options.Compression[za0001])
+		}
+		s += 4 + msgp.BoolSize
+	}
+	return
+}
+
 // DecodeMsg implements msgp.Decodable
 func (z *Helo) DecodeMsg(dc *msgp.Reader) (err error) {
 	var zb0001 uint32
@@ -175,6 +175,91 @@ func (msg *PackedForwardMessage) Chunk() (string, error) {
 	return chunk, err
 }
 
+// Sender is implemented by clients that send a ChunkEncoder to a peer,
+// such as client.Client and client.WSClient.
+type Sender interface {
+	Send(e ChunkEncoder) error
+}
+
+// PackedForwardWriter builds a PackedForwardMessage by encoding entries
+// into its event stream one at a time, so that a large batch need not be
+// held in memory as an EntryList first.
+//
+//msgp:ignore PackedForwardWriter
+type PackedForwardWriter struct {
+	tag   string
+	buf   bytes.Buffer
+	w     *msgp.Writer
+	count int
+}
+
+// NewPackedForwardWriter returns an empty PackedForwardWriter for tag.
+func NewPackedForwardWriter(tag string) *PackedForwardWriter {
+	pw := &PackedForwardWriter{tag: tag}
+	pw.w = msgp.NewWriter(&pw.buf)
+
+	return pw
+}
+
+// Append encodes the entry onto the end of the event stream.
+func (pw *PackedForwardWriter) Append(entry EntryExt) error {
+	if err := entry.EncodeMsg(pw.w); err != nil {
+		return err
+	}
+
+	pw.count++
+
+	return nil
+}
+
+// Len returns the number of entries appended since the last Reset.
+func (pw *PackedForwardWriter) Len() int {
+	return pw.count
+}
+
+// Reset discards all appended entries, but retains the underlying
+// storage for reuse.
+func (pw *PackedForwardWriter) Reset() {
+	pw.buf.Reset()
+	pw.w.Reset(&pw.buf)
+	pw.count = 0
+}
+
+// Message returns a PackedForwardMessage carrying the appended entries,
+// with Options.Size set to their count. The event stream shares the
+// writer's storage, so the message must not be used after the next call
+// to Append or Reset.
+func (pw *PackedForwardWriter) Message() (*PackedForwardMessage, error) {
+	if err := pw.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	count := pw.count
+	pfm := NewPackedForwardMessageFromBytes(pw.tag, pw.buf.Bytes())
+	pfm.Options = &MessageOptions{
+		Size: &count,
+	}
+
+	return pfm, nil
+}
+
+// Send sends the appended entries as a single message and, if that
+// succeeds, resets the writer.
+func (pw *PackedForwardWriter) Send(s Sender) error {
+	msg, err := pw.Message()
+	if err != nil {
+		return err
+	}
+
+	if err = s.Send(msg); err != nil {
+		return err
+	}
+
+	pw.Reset()
+
+	return nil
+}
+
 //msgp:ignore GzipCompressor
 type GzipCompressor struct {
 	Buffer     *bytes.Buffer
@@ -210,3 +210,30 @@ func BenchmarkNCFMFB(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCompressedPackedForwardMessage(b *testing.B) {
+	for _, alg := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		b.Run(alg.String(), func(b *testing.B) {
+			pfm, err := NewPackedForwardMessage("foo.bar", makeLogEntries(1000))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			raw, _ := pfm.MarshalMsg(nil)
+			cpfm := &CompressedPackedForwardMessage{PackedForwardMessage: pfm, CompressionAlgorithm: alg}
+
+			var compressed []byte
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if compressed, err = cpfm.MarshalMsg(compressed[:0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(100*(1-float64(len(compressed))/float64(len(raw))), "%reduction")
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protocol
+
+import (
+	"sync"
+)
+
+var (
+	forwardMessagePool = sync.Pool{
+		New: func() interface{} {
+			return new(ForwardMessage)
+		},
+	}
+
+	entryExtPool = sync.Pool{
+		New: func() interface{} {
+			return new(EntryExt)
+		},
+	}
+)
+
+// GetForwardMessage returns an empty ForwardMessage from a pool. Its
+// Entries may have spare capacity left from earlier use, so appending to
+// them often does not allocate. Return it with PutForwardMessage once it
+// has been sent.
+func GetForwardMessage() *ForwardMessage {
+	return forwardMessagePool.Get().(*ForwardMessage)
+}
+
+// PutForwardMessage resets fm and returns it to the pool. The entries are
+// cleared, so that their records can be collected, but the backing array
+// is kept for the next user. fm must not be used afterward.
+func PutForwardMessage(fm *ForwardMessage) {
+	if fm == nil {
+		return
+	}
+
+	for i := range fm.Entries {
+		fm.Entries[i] = EntryExt{}
+	}
+
+	fm.Tag = ""
+	fm.Entries = fm.Entries[:0]
+	fm.Options = nil
+	fm.ExtraOptions = nil
+
+	forwardMessagePool.Put(fm)
+}
+
+// GetEntryExt returns an empty EntryExt from a pool. Return it with
+// PutEntryExt once it is no longer used.
+func GetEntryExt() *EntryExt {
+	return entryExtPool.Get().(*EntryExt)
+}
+
+// PutEntryExt resets e and returns it to the pool. e must not be used
+// afterward.
+func PutEntryExt(e *EntryExt) {
+	if e == nil {
+		return
+	}
+
+	*e = EntryExt{}
+
+	entryExtPool.Put(e)
+}
@@ -0,0 +1,108 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protocol_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+var _ = Describe("Pools", func() {
+	Describe("ForwardMessage", func() {
+		It("is reset when it is put back", func() {
+			size := 1
+
+			fm := protocol.GetForwardMessage()
+			fm.Tag = "foo"
+			fm.Entries = append(fm.Entries, protocol.EntryExt{
+				Timestamp: protocol.EventTimeNow(),
+				Record:    map[string]string{"oi": "hi"},
+			})
+			fm.Options = &protocol.MessageOptions{Size: &size}
+			fm.ExtraOptions = map[string]interface{}{"trace_id": "abc"}
+
+			entries := fm.Entries[:1]
+			protocol.PutForwardMessage(fm)
+
+			Expect(fm.Tag).To(BeEmpty())
+			Expect(fm.Entries).To(BeEmpty())
+			Expect(fm.Entries).ToNot(BeNil())
+			Expect(cap(fm.Entries)).To(BeNumerically(">=", 1))
+			Expect(fm.Options).To(BeNil())
+			Expect(fm.ExtraOptions).To(BeNil())
+			Expect(entries[0]).To(Equal(protocol.EntryExt{}))
+		})
+
+		It("ignores nil", func() {
+			Expect(func() { protocol.PutForwardMessage(nil) }).ToNot(Panic())
+		})
+	})
+
+	Describe("EntryExt", func() {
+		It("is reset when it is put back", func() {
+			e := protocol.GetEntryExt()
+			e.Timestamp = protocol.EventTimeNow()
+			e.Record = map[string]string{"oi": "hi"}
+
+			protocol.PutEntryExt(e)
+
+			Expect(*e).To(Equal(protocol.EntryExt{}))
+		})
+	})
+})
+
+func fillForwardMessage(fm *protocol.ForwardMessage, record interface{}) {
+	fm.Tag = "foo"
+
+	for i := 0; i < 10; i++ {
+		fm.Entries = append(fm.Entries, protocol.EntryExt{Record: record})
+	}
+}
+
+func Benchmark_ForwardMessage_New(b *testing.B) {
+	record := map[string]interface{}{"msg": "oi"}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		fillForwardMessage(new(protocol.ForwardMessage), record)
+	}
+}
+
+func Benchmark_ForwardMessage_Pool(b *testing.B) {
+	record := map[string]interface{}{"msg": "oi"}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		fm := protocol.GetForwardMessage()
+		fillForwardMessage(fm, record)
+		protocol.PutForwardMessage(fm)
+	}
+}
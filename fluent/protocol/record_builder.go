@@ -0,0 +1,155 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrFieldConflict is returned by RecordBuilder.Build when a field is set
+// both as a value and as a map of nested fields, e.g. "a" and "a.b".
+var ErrFieldConflict = errors.New("conflicting record fields")
+
+// recordNode is a map made by a RecordBuilder for nested fields. Its own
+// type tells it apart from map values that were set as they are.
+type recordNode map[string]interface{}
+
+// RecordBuilder builds an EntryExt with method chaining, e.g.
+//
+//	entry, err := NewRecord().
+//		Set("level", "info").
+//		SetNested("user.id", 42).
+//		SetTimestamp(time.Now()).
+//		Build()
+//
+// The first error, such as a field conflict, stops further changes and is
+// returned by Build. A RecordBuilder is not safe for concurrent use.
+type RecordBuilder struct {
+	timestamp EventTime
+	record    recordNode
+	err       error
+}
+
+// NewRecord returns an empty RecordBuilder.
+func NewRecord() *RecordBuilder {
+	return &RecordBuilder{record: recordNode{}}
+}
+
+// Set sets the top-level field key to val. Dots in key are kept as they
+// are; use SetNested for nested fields.
+func (b *RecordBuilder) Set(key string, val interface{}) *RecordBuilder {
+	return b.set([]string{key}, val)
+}
+
+// SetNested sets the field at the dot-delimited path to val, making the
+// maps that hold it as needed, e.g. "user.id" sets the "id" field of the
+// "user" map.
+func (b *RecordBuilder) SetNested(path string, val interface{}) *RecordBuilder {
+	return b.set(strings.Split(path, "."), val)
+}
+
+// SetTimestamp sets the time of the entry. If it is not set, Build uses
+// the current time.
+func (b *RecordBuilder) SetTimestamp(t time.Time) *RecordBuilder {
+	b.timestamp = NewEventTime(t)
+	return b
+}
+
+func (b *RecordBuilder) set(path []string, val interface{}) *RecordBuilder {
+	if b.err != nil {
+		return b
+	}
+
+	for _, key := range path {
+		if key == "" {
+			b.err = fmt.Errorf("record field %q has an empty name", strings.Join(path, "."))
+			return b
+		}
+	}
+
+	node := b.record
+
+	for i, key := range path[:len(path)-1] {
+		child, ok := node[key]
+		if !ok {
+			next := recordNode{}
+			node[key] = next
+			node = next
+
+			continue
+		}
+
+		if node, ok = child.(recordNode); !ok {
+			b.err = fmt.Errorf("%w: %q is a value, not a map", ErrFieldConflict, strings.Join(path[:i+1], "."))
+			return b
+		}
+	}
+
+	last := path[len(path)-1]
+	if _, ok := node[last].(recordNode); ok {
+		b.err = fmt.Errorf("%w: %q is a map, not a value", ErrFieldConflict, strings.Join(path, "."))
+		return b
+	}
+
+	node[last] = val
+
+	return b
+}
+
+// Build returns the entry, ready to be added to a ForwardMessage, or the
+// first error met while building it. The builder may be used again; the
+// entry does not share its maps.
+func (b *RecordBuilder) Build() (EntryExt, error) {
+	if b.err != nil {
+		return EntryExt{}, b.err
+	}
+
+	timestamp := b.timestamp
+	if timestamp.IsZero() {
+		timestamp = EventTimeNow()
+	}
+
+	return EntryExt{
+		Timestamp: timestamp,
+		Record:    b.record.toMap(),
+	}, nil
+}
+
+func (n recordNode) toMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(n))
+
+	for key, val := range n {
+		if child, ok := val.(recordNode); ok {
+			val = child.toMap()
+		}
+
+		m[key] = val
+	}
+
+	return m
+}
@@ -0,0 +1,130 @@
+/*
+MIT License
+
+Copyright contributors to the fluent-forward-go project
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package protocol_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/IBM/fluent-forward-go/fluent/protocol"
+)
+
+var _ = Describe("RecordBuilder", func() {
+	It("builds nested records", func() {
+		ts := time.Date(2021, time.March, 4, 5, 6, 7, 8, time.UTC)
+
+		entry, err := protocol.NewRecord().
+			Set("level", "info").
+			Set("dotted.key", true).
+			SetNested("user.id", 42).
+			SetNested("user.name.first", "Ada").
+			SetTimestamp(ts).
+			Build()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(entry.Timestamp.Time).To(Equal(ts))
+		Expect(entry.Record).To(Equal(map[string]interface{}{
+			"level":      "info",
+			"dotted.key": true,
+			"user": map[string]interface{}{
+				"id": 42,
+				"name": map[string]interface{}{
+					"first": "Ada",
+				},
+			},
+		}))
+	})
+
+	It("timestamps the entry now by default", func() {
+		entry, err := protocol.NewRecord().Set("a", 1).Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.Timestamp.Time).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("builds entries that can be sent", func() {
+		entry, err := protocol.NewRecord().SetNested("a.b", "c").Build()
+		Expect(err).ToNot(HaveOccurred())
+
+		fm := protocol.NewForwardMessage("tag", protocol.EntryList{entry})
+		bits, err := fm.MarshalMsg(nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		var decoded protocol.ForwardMessage
+		_, err = decoded.UnmarshalMsg(bits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded.Entries[0].Record).To(Equal(map[string]interface{}{
+			"a": map[string]interface{}{"b": "c"},
+		}))
+	})
+
+	It("replaces values set twice", func() {
+		entry, err := protocol.NewRecord().SetNested("a.b", 1).SetNested("a.b", 2).Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entry.Record).To(HaveKeyWithValue("a", map[string]interface{}{"b": 2}))
+	})
+
+	It("does not share its maps with built entries", func() {
+		b := protocol.NewRecord().SetNested("a.b", 1)
+
+		first, err := b.Build()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = b.SetNested("a.c", 2).Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(first.Record).To(HaveKeyWithValue("a", map[string]interface{}{"b": 1}))
+	})
+
+	DescribeTable("detects conflicting paths",
+		func(build func(*protocol.RecordBuilder) *protocol.RecordBuilder) {
+			_, err := build(protocol.NewRecord()).Build()
+			Expect(errors.Is(err, protocol.ErrFieldConflict)).To(BeTrue())
+		},
+		Entry("a value, then a map", func(b *protocol.RecordBuilder) *protocol.RecordBuilder {
+			return b.Set("a", 1).SetNested("a.b", 2)
+		}),
+		Entry("a map, then a value", func(b *protocol.RecordBuilder) *protocol.RecordBuilder {
+			return b.SetNested("a.b", 2).Set("a", 1)
+		}),
+		Entry("a deeper conflict", func(b *protocol.RecordBuilder) *protocol.RecordBuilder {
+			return b.SetNested("a.b", 1).SetNested("a.b.c", 2)
+		}),
+		Entry("a map value, then a nested field", func(b *protocol.RecordBuilder) *protocol.RecordBuilder {
+			return b.Set("a", map[string]interface{}{}).SetNested("a.b", 2)
+		}),
+	)
+
+	It("rejects empty field names", func() {
+		_, err := protocol.NewRecord().SetNested("a..b", 1).Build()
+		Expect(err).To(MatchError(ContainSubstring("empty name")))
+	})
+
+	It("keeps the first error", func() {
+		_, err := protocol.NewRecord().Set("a", 1).SetNested("a.b", 2).SetNested("", 3).Build()
+		Expect(errors.Is(err, protocol.ErrFieldConflict)).To(BeTrue())
+	})
+})
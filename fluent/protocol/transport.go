@@ -43,10 +43,13 @@ import (
 // =========
 
 const (
-	OptSize       string = "size"
-	OptChunk      string = "chunk"
-	OptCompressed string = "compressed"
-	OptValGZIP    string = "gzip"
+	OptSize        string = "size"
+	OptChunk       string = "chunk"
+	OptCompressed  string = "compressed"
+	OptTraceParent string = "traceparent"
+	OptTraceState  string = "tracestate"
+	OptSeq         string = "seq"
+	OptValGZIP     string = "gzip"
 
 	extensionType int8 = 0
 	eventTimeLen  int  = 8
@@ -78,18 +81,27 @@ func init() {
 	}
 }
 
-// EventTime is the fluent-forward representation of a timestamp
+// EventTime is the fluent-forward representation of a timestamp. It is
+// encoded as the EventTime extension, type 0, whose 8 bytes are the
+// seconds since the epoch and the nanoseconds, each a big-endian uint32,
+// so that sub-second precision is kept. Times before the epoch or after
+// 2106 cannot be represented. The embedded Time converts it back.
 type EventTime struct {
 	time.Time
 }
 
-// EventTimeNow returns an EventTime set to time.Now().UTC().
-func EventTimeNow() EventTime {
+// NewEventTime returns an EventTime set to t in UTC.
+func NewEventTime(t time.Time) EventTime {
 	return EventTime{
-		Time: time.Now().UTC(),
+		Time: t.UTC(),
 	}
 }
 
+// EventTimeNow returns an EventTime set to time.Now().UTC().
+func EventTimeNow() EventTime {
+	return NewEventTime(time.Now())
+}
+
 func (et *EventTime) ExtensionType() int8 {
 	return extensionType
 }
@@ -127,9 +139,12 @@ func (et *EventTime) UnmarshalBinary(timeBytes []byte) error {
 }
 
 // EntryExt is the basic representation of an individual event, but using the
-// msgpack extension format for the timestamp.
+// msgpack extension format for the timestamp. When decoding, integer
+// timestamps (seconds since the epoch) are accepted as well.
 //
 //msgp:tuple EntryExt
+//msgp:decode ignore EntryExt
+//msgp:unmarshal ignore EntryExt
 type EntryExt struct {
 	// Timestamp can contain the timestamp in either seconds or nanoseconds
 	Timestamp EventTime `msg:"eventTime,extension"`
@@ -139,8 +154,125 @@ type EntryExt struct {
 	Record interface{}
 }
 
+// EventEntry is an event of a ForwardMessage, PackedForwardMessage or
+// CompressedPackedForwardMessage. It is the same type as EntryExt: its
+// Timestamp is encoded as an EventTime, and decoded from either an
+// EventTime or integer seconds since the epoch.
+//
+//msgp:ignore EventEntry
+type EventEntry = EntryExt
+
+// DecodeMsg implements msgp.Decodable.
+func (e *EntryExt) DecodeMsg(dc *msgp.Reader) error {
+	sz, err := dc.ReadArrayHeader()
+	if err != nil {
+		return msgp.WrapError(err)
+	}
+
+	if sz != 2 {
+		return msgp.ArrayError{Wanted: 2, Got: sz}
+	}
+
+	t, err := dc.NextType()
+	if err != nil {
+		return msgp.WrapError(err, "Timestamp")
+	}
+
+	if t == msgp.IntType || t == msgp.UintType {
+		var seconds int64
+		if seconds, err = dc.ReadInt64(); err != nil {
+			return msgp.WrapError(err, "Timestamp")
+		}
+
+		e.Timestamp = EventTime{Time: time.Unix(seconds, 0)}
+	} else if err = dc.ReadExtension(&e.Timestamp); err != nil {
+		return msgp.WrapError(err, "Timestamp")
+	}
+
+	if e.Record, err = dc.ReadIntf(); err != nil {
+		return msgp.WrapError(err, "Record")
+	}
+
+	return nil
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (e *EntryExt) UnmarshalMsg(bits []byte) ([]byte, error) {
+	sz, bits, err := msgp.ReadArrayHeaderBytes(bits)
+	if err != nil {
+		return bits, msgp.WrapError(err)
+	}
+
+	if sz != 2 {
+		return bits, msgp.ArrayError{Wanted: 2, Got: sz}
+	}
+
+	if t := msgp.NextType(bits); t == msgp.IntType || t == msgp.UintType {
+		var seconds int64
+		if seconds, bits, err = msgp.ReadInt64Bytes(bits); err != nil {
+			return bits, msgp.WrapError(err, "Timestamp")
+		}
+
+		e.Timestamp = EventTime{Time: time.Unix(seconds, 0)}
+	} else if bits, err = msgp.ReadExtensionBytes(bits, &e.Timestamp); err != nil {
+		return bits, msgp.WrapError(err, "Timestamp")
+	}
+
+	if e.Record, bits, err = msgp.ReadIntfBytes(bits); err != nil {
+		return bits, msgp.WrapError(err, "Record")
+	}
+
+	return bits, nil
+}
+
+//msgp:decode ignore EntryList
+//msgp:unmarshal ignore EntryList
 type EntryList []EntryExt
 
+// DecodeMsg implements msgp.Decodable.
+func (el *EntryList) DecodeMsg(dc *msgp.Reader) error {
+	sz, err := dc.ReadArrayHeader()
+	if err != nil {
+		return msgp.WrapError(err)
+	}
+
+	if cap(*el) >= int(sz) {
+		*el = (*el)[:sz]
+	} else {
+		*el = make(EntryList, sz)
+	}
+
+	for i := range *el {
+		if err = (*el)[i].DecodeMsg(dc); err != nil {
+			return msgp.WrapError(err, i)
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalMsg implements msgp.Unmarshaler.
+func (el *EntryList) UnmarshalMsg(bits []byte) ([]byte, error) {
+	sz, bits, err := msgp.ReadArrayHeaderBytes(bits)
+	if err != nil {
+		return bits, msgp.WrapError(err)
+	}
+
+	if cap(*el) >= int(sz) {
+		*el = (*el)[:sz]
+	} else {
+		*el = make(EntryList, sz)
+	}
+
+	for i := range *el {
+		if bits, err = (*el)[i].UnmarshalMsg(bits); err != nil {
+			return bits, msgp.WrapError(err, i)
+		}
+	}
+
+	return bits, nil
+}
+
 func (el *EntryList) UnmarshalPacked(bits []byte) ([]byte, error) {
 	var (
 		entry EntryExt
@@ -224,6 +356,14 @@ type MessageOptions struct {
 	Size       *int   `msg:"size,omitempty"`
 	Chunk      string `msg:"chunk,omitempty"`
 	Compressed string `msg:"compressed,omitempty"`
+	// TraceParent and TraceState carry the W3C trace context of the
+	// code that sent the message, so that it can be correlated with the
+	// trace. Servers that do not know them ignore them.
+	TraceParent string `msg:"traceparent,omitempty"`
+	TraceState  string `msg:"tracestate,omitempty"`
+	// Seq numbers the messages of each tag from 1, so that a receiver can
+	// detect lost or reordered messages. Zero means unnumbered.
+	Seq uint64 `msg:"seq,omitempty"`
 }
 
 type AckMessage struct {
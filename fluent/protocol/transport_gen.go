@@ -200,31 +200,6 @@ func (z Entry) Msgsize() (s int) {
 	return
 }
 
-// DecodeMsg implements msgp.Decodable
-func (z *EntryExt) DecodeMsg(dc *msgp.Reader) (err error) {
-	var zb0001 uint32
-	zb0001, err = dc.ReadArrayHeader()
-	if err != nil {
-		err = msgp.WrapError(err)
-		return
-	}
-	if zb0001 != 2 {
-		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
-		return
-	}
-	err = dc.ReadExtension(&z.Timestamp)
-	if err != nil {
-		err = msgp.WrapError(err, "Timestamp")
-		return
-	}
-	z.Record, err = dc.ReadIntf()
-	if err != nil {
-		err = msgp.WrapError(err, "Record")
-		return
-	}
-	return
-}
-
 // EncodeMsg implements msgp.Encodable
 func (z EntryExt) EncodeMsg(en *msgp.Writer) (err error) {
 	// array header, size 2
@@ -263,76 +238,12 @@ func (z EntryExt) MarshalMsg(b []byte) (o []byte, err error) {
 	return
 }
 
-// UnmarshalMsg implements msgp.Unmarshaler
-func (z *EntryExt) UnmarshalMsg(bts []byte) (o []byte, err error) {
-	var zb0001 uint32
-	zb0001, bts, err = msgp.ReadArrayHeaderBytes(bts)
-	if err != nil {
-		err = msgp.WrapError(err)
-		return
-	}
-	if zb0001 != 2 {
-		err = msgp.ArrayError{Wanted: 2, Got: zb0001}
-		return
-	}
-	bts, err = msgp.ReadExtensionBytes(bts, &z.Timestamp)
-	if err != nil {
-		err = msgp.WrapError(err, "Timestamp")
-		return
-	}
-	z.Record, bts, err = msgp.ReadIntfBytes(bts)
-	if err != nil {
-		err = msgp.WrapError(err, "Record")
-		return
-	}
-	o = bts
-	return
-}
-
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z EntryExt) Msgsize() (s int) {
 	s = 1 + msgp.ExtensionPrefixSize + z.Timestamp.Len() + msgp.GuessSize(z.Record)
 	return
 }
 
-// DecodeMsg implements msgp.Decodable
-func (z *EntryList) DecodeMsg(dc *msgp.Reader) (err error) {
-	var zb0002 uint32
-	zb0002, err = dc.ReadArrayHeader()
-	if err != nil {
-		err = msgp.WrapError(err)
-		return
-	}
-	if cap((*z)) >= int(zb0002) {
-		(*z) = (*z)[:zb0002]
-	} else {
-		(*z) = make(EntryList, zb0002)
-	}
-	for zb0001 := range *z {
-		var zb0003 uint32
-		zb0003, err = dc.ReadArrayHeader()
-		if err != nil {
-			err = msgp.WrapError(err, zb0001)
-			return
-		}
-		if zb0003 != 2 {
-			err = msgp.ArrayError{Wanted: 2, Got: zb0003}
-			return
-		}
-		err = dc.ReadExtension(&(*z)[zb0001].Timestamp)
-		if err != nil {
-			err = msgp.WrapError(err, zb0001, "Timestamp")
-			return
-		}
-		(*z)[zb0001].Record, err = dc.ReadIntf()
-		if err != nil {
-			err = msgp.WrapError(err, zb0001, "Record")
-			return
-		}
-	}
-	return
-}
-
 // EncodeMsg implements msgp.Encodable
 func (z EntryList) EncodeMsg(en *msgp.Writer) (err error) {
 	err = en.WriteArrayHeader(uint32(len(z)))
@@ -340,20 +251,20 @@ func (z EntryList) EncodeMsg(en *msgp.Writer) (err error) {
 		err = msgp.WrapError(err)
 		return
 	}
-	for zb0004 := range z {
+	for za0001 := range z {
 		// array header, size 2
 		err = en.Append(0x92)
 		if err != nil {
 			return
 		}
-		err = en.WriteExtension(&z[zb0004].Timestamp)
+		err = en.WriteExtension(&z[za0001].Timestamp)
 		if err != nil {
-			err = msgp.WrapError(err, zb0004, "Timestamp")
+			err = msgp.WrapError(err, za0001, "Timestamp")
 			return
 		}
-		err = en.WriteIntf(z[zb0004].Record)
+		err = en.WriteIntf(z[za0001].Record)
 		if err != nil {
-			err = msgp.WrapError(err, zb0004, "Record")
+			err = msgp.WrapError(err, za0001, "Record")
 			return
 		}
 	}
@@ -364,67 +275,28 @@ func (z EntryList) EncodeMsg(en *msgp.Writer) (err error) {
 func (z EntryList) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	o = msgp.AppendArrayHeader(o, uint32(len(z)))
-	for zb0004 := range z {
+	for za0001 := range z {
 		// array header, size 2
 		o = append(o, 0x92)
-		o, err = msgp.AppendExtension(o, &z[zb0004].Timestamp)
-		if err != nil {
-			err = msgp.WrapError(err, zb0004, "Timestamp")
-			return
-		}
-		o, err = msgp.AppendIntf(o, z[zb0004].Record)
-		if err != nil {
-			err = msgp.WrapError(err, zb0004, "Record")
-			return
-		}
-	}
-	return
-}
-
-// UnmarshalMsg implements msgp.Unmarshaler
-func (z *EntryList) UnmarshalMsg(bts []byte) (o []byte, err error) {
-	var zb0002 uint32
-	zb0002, bts, err = msgp.ReadArrayHeaderBytes(bts)
-	if err != nil {
-		err = msgp.WrapError(err)
-		return
-	}
-	if cap((*z)) >= int(zb0002) {
-		(*z) = (*z)[:zb0002]
-	} else {
-		(*z) = make(EntryList, zb0002)
-	}
-	for zb0001 := range *z {
-		var zb0003 uint32
-		zb0003, bts, err = msgp.ReadArrayHeaderBytes(bts)
+		o, err = msgp.AppendExtension(o, &z[za0001].Timestamp)
 		if err != nil {
-			err = msgp.WrapError(err, zb0001)
-			return
-		}
-		if zb0003 != 2 {
-			err = msgp.ArrayError{Wanted: 2, Got: zb0003}
+			err = msgp.WrapError(err, za0001, "Timestamp")
 			return
 		}
-		bts, err = msgp.ReadExtensionBytes(bts, &(*z)[zb0001].Timestamp)
+		o, err = msgp.AppendIntf(o, z[za0001].Record)
 		if err != nil {
-			err = msgp.WrapError(err, zb0001, "Timestamp")
-			return
-		}
-		(*z)[zb0001].Record, bts, err = msgp.ReadIntfBytes(bts)
-		if err != nil {
-			err = msgp.WrapError(err, zb0001, "Record")
+			err = msgp.WrapError(err, za0001, "Record")
 			return
 		}
 	}
-	o = bts
 	return
 }
 
 // Msgsize returns an upper bound estimate of the number of bytes occupied by the serialized message
 func (z EntryList) Msgsize() (s int) {
 	s = msgp.ArrayHeaderSize
-	for zb0004 := range z {
-		s += 1 + msgp.ExtensionPrefixSize + z[zb0004].Timestamp.Len() + msgp.GuessSize(z[zb0004].Record)
+	for za0001 := range z {
+		s += 1 + msgp.ExtensionPrefixSize + z[za0001].Timestamp.Len() + msgp.GuessSize(z[za0001].Record)
 	}
 	return
 }
@@ -580,6 +452,24 @@ func (z *MessageOptions) DecodeMsg(dc *msgp.Reader) (err error) {
 				err = msgp.WrapError(err, "Compressed")
 				return
 			}
+		case "traceparent":
+			z.TraceParent, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "TraceParent")
+				return
+			}
+		case "tracestate":
+			z.TraceState, err = dc.ReadString()
+			if err != nil {
+				err = msgp.WrapError(err, "TraceState")
+				return
+			}
+		case "seq":
+			z.Seq, err = dc.ReadUint64()
+			if err != nil {
+				err = msgp.WrapError(err, "Seq")
+				return
+			}
 		default:
 			err = dc.Skip()
 			if err != nil {
@@ -594,8 +484,9 @@ func (z *MessageOptions) DecodeMsg(dc *msgp.Reader) (err error) {
 // EncodeMsg implements msgp.Encodable
 func (z *MessageOptions) EncodeMsg(en *msgp.Writer) (err error) {
 	// omitempty: check for empty values
-	zb0001Len := uint32(3)
-	var zb0001Mask uint8 /* 3 bits */
+	zb0001Len := uint32(6)
+	var zb0001Mask uint8 /* 6 bits */
+	_ = zb0001Mask
 	if z.Size == nil {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -608,6 +499,18 @@ func (z *MessageOptions) EncodeMsg(en *msgp.Writer) (err error) {
 		zb0001Len--
 		zb0001Mask |= 0x4
 	}
+	if z.TraceParent == "" {
+		zb0001Len--
+		zb0001Mask |= 0x8
+	}
+	if z.TraceState == "" {
+		zb0001Len--
+		zb0001Mask |= 0x10
+	}
+	if z.Seq == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
 	// variable map header, size zb0001Len
 	err = en.Append(0x80 | uint8(zb0001Len))
 	if err != nil {
@@ -659,6 +562,42 @@ func (z *MessageOptions) EncodeMsg(en *msgp.Writer) (err error) {
 			return
 		}
 	}
+	if (zb0001Mask & 0x8) == 0 { // if not empty
+		// write "traceparent"
+		err = en.Append(0xab, 0x74, 0x72, 0x61, 0x63, 0x65, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.TraceParent)
+		if err != nil {
+			err = msgp.WrapError(err, "TraceParent")
+			return
+		}
+	}
+	if (zb0001Mask & 0x10) == 0 { // if not empty
+		// write "tracestate"
+		err = en.Append(0xaa, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x74, 0x61, 0x74, 0x65)
+		if err != nil {
+			return
+		}
+		err = en.WriteString(z.TraceState)
+		if err != nil {
+			err = msgp.WrapError(err, "TraceState")
+			return
+		}
+	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// write "seq"
+		err = en.Append(0xa3, 0x73, 0x65, 0x71)
+		if err != nil {
+			return
+		}
+		err = en.WriteUint64(z.Seq)
+		if err != nil {
+			err = msgp.WrapError(err, "Seq")
+			return
+		}
+	}
 	return
 }
 
@@ -666,8 +605,9 @@ func (z *MessageOptions) EncodeMsg(en *msgp.Writer) (err error) {
 func (z *MessageOptions) MarshalMsg(b []byte) (o []byte, err error) {
 	o = msgp.Require(b, z.Msgsize())
 	// omitempty: check for empty values
-	zb0001Len := uint32(3)
-	var zb0001Mask uint8 /* 3 bits */
+	zb0001Len := uint32(6)
+	var zb0001Mask uint8 /* 6 bits */
+	_ = zb0001Mask
 	if z.Size == nil {
 		zb0001Len--
 		zb0001Mask |= 0x1
@@ -680,6 +620,18 @@ func (z *MessageOptions) MarshalMsg(b []byte) (o []byte, err error) {
 		zb0001Len--
 		zb0001Mask |= 0x4
 	}
+	if z.TraceParent == "" {
+		zb0001Len--
+		zb0001Mask |= 0x8
+	}
+	if z.TraceState == "" {
+		zb0001Len--
+		zb0001Mask |= 0x10
+	}
+	if z.Seq == 0 {
+		zb0001Len--
+		zb0001Mask |= 0x20
+	}
 	// variable map header, size zb0001Len
 	o = append(o, 0x80|uint8(zb0001Len))
 	if zb0001Len == 0 {
@@ -704,6 +656,21 @@ func (z *MessageOptions) MarshalMsg(b []byte) (o []byte, err error) {
 		o = append(o, 0xaa, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64)
 		o = msgp.AppendString(o, z.Compressed)
 	}
+	if (zb0001Mask & 0x8) == 0 { // if not empty
+		// string "traceparent"
+		o = append(o, 0xab, 0x74, 0x72, 0x61, 0x63, 0x65, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74)
+		o = msgp.AppendString(o, z.TraceParent)
+	}
+	if (zb0001Mask & 0x10) == 0 { // if not empty
+		// string "tracestate"
+		o = append(o, 0xaa, 0x74, 0x72, 0x61, 0x63, 0x65, 0x73, 0x74, 0x61, 0x74, 0x65)
+		o = msgp.AppendString(o, z.TraceState)
+	}
+	if (zb0001Mask & 0x20) == 0 { // if not empty
+		// string "seq"
+		o = append(o, 0xa3, 0x73, 0x65, 0x71)
+		o = msgp.AppendUint64(o, z.Seq)
+	}
 	return
 }
 
@@ -754,6 +721,24 @@ func (z *MessageOptions) UnmarshalMsg(bts []byte) (o []byte, err error) {
 				err = msgp.WrapError(err, "Compressed")
 				return
 			}
+		case "traceparent":
+			z.TraceParent, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "TraceParent")
+				return
+			}
+		case "tracestate":
+			z.TraceState, bts, err = msgp.ReadStringBytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "TraceState")
+				return
+			}
+		case "seq":
+			z.Seq, bts, err = msgp.ReadUint64Bytes(bts)
+			if err != nil {
+				err = msgp.WrapError(err, "Seq")
+				return
+			}
 		default:
 			bts, err = msgp.Skip(bts)
 			if err != nil {
@@ -774,7 +759,7 @@ func (z *MessageOptions) Msgsize() (s int) {
 	} else {
 		s += msgp.IntSize
 	}
-	s += 6 + msgp.StringPrefixSize + len(z.Chunk) + 11 + msgp.StringPrefixSize + len(z.Compressed)
+	s += 6 + msgp.StringPrefixSize + len(z.Chunk) + 11 + msgp.StringPrefixSize + len(z.Compressed) + 12 + msgp.StringPrefixSize + len(z.TraceParent) + 11 + msgp.StringPrefixSize + len(z.TraceState) + 4 + msgp.Uint64Size
 	return
 }
 
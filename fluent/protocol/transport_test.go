@@ -25,16 +25,41 @@ SOFTWARE.
 package protocol_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"strings"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/tinylib/msgp/msgp"
 
 	"github.com/IBM/fluent-forward-go/fluent/protocol"
 )
 
+// recordingSender keeps a copy of the event stream of each
+// PackedForwardMessage it is asked to send.
+type recordingSender struct {
+	sent [][]byte
+	err  error
+}
+
+func (rs *recordingSender) Send(e protocol.ChunkEncoder) error {
+	if rs.err != nil {
+		return rs.err
+	}
+
+	msg := e.(*protocol.PackedForwardMessage)
+	rs.sent = append(rs.sent, append([]byte(nil), msg.EventStream...))
+
+	return nil
+}
+
 var _ = Describe("Transport", func() {
 	Describe("EventTime", func() {
 		var (
@@ -73,6 +98,80 @@ var _ = Describe("Transport", func() {
 		})
 	})
 
+	Describe("EventTime extension", func() {
+		// each vector is the fixext8 that Fluentd's Fluent::EventTime packs
+		// to for the seconds and nanoseconds
+		DescribeTable("round-trips with nanosecond precision",
+			func(sec, nsec int64, vector string) {
+				et := protocol.NewEventTime(time.Unix(sec, nsec))
+
+				b, err := msgp.AppendExtension(nil, &et)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fmt.Sprintf("%X", b)).To(Equal(vector))
+
+				var decoded protocol.EventTime
+				rest, err := msgp.ReadExtensionBytes(b, &decoded)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rest).To(BeEmpty())
+				Expect(decoded.Unix()).To(Equal(sec))
+				Expect(int64(decoded.Nanosecond())).To(Equal(nsec))
+				Expect(decoded.Equal(et.Time)).To(BeTrue())
+			},
+			Entry("the epoch", int64(0), int64(0), "D7000000000000000000"),
+			Entry("Fluent::EventTime.new(1490061425, 123456789)", int64(1490061425), int64(123456789), "D70058D08871075BCD15"),
+			Entry("a single nanosecond", int64(1700000000), int64(1), "D7006553F10000000001"),
+			Entry("the largest time", int64(4294967295), int64(999999999), "D700FFFFFFFF3B9AC9FF"),
+		)
+
+		It("converts from and back to time.Time", func() {
+			t := time.Date(2023, 3, 4, 5, 6, 7, 891011121, time.FixedZone("CET", 3600))
+
+			et := protocol.NewEventTime(t)
+			Expect(et.Location()).To(Equal(time.UTC))
+			Expect(et.Time.Equal(t)).To(BeTrue())
+		})
+
+		It("rejects a payload of the wrong length", func() {
+			var et protocol.EventTime
+			Expect(et.UnmarshalBinary([]byte{0, 0, 0, 1})).To(MatchError("Invalid length"))
+		})
+	})
+
+	Describe("EntryExt with an integer timestamp", func() {
+		// [1257894000, {"foo": "bar"}] with the timestamp as a uint32
+		bits := []byte{
+			0x92, 0xce, 0x4a, 0xf9, 0xf0, 0x70,
+			0x81, 0xa3, 'f', 'o', 'o', 0xa3, 'b', 'a', 'r',
+		}
+
+		It("unmarshals", func() {
+			var ent protocol.EntryExt
+			rest, err := ent.UnmarshalMsg(bits)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rest).To(BeEmpty())
+			Expect(ent.Timestamp.Unix()).To(Equal(int64(1257894000)))
+			Expect(ent.Record).To(HaveKeyWithValue("foo", "bar"))
+		})
+
+		It("decodes", func() {
+			var ent protocol.EntryExt
+			err := msgp.Decode(bytes.NewReader(bits), &ent)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ent.Timestamp.Unix()).To(Equal(int64(1257894000)))
+			Expect(ent.Record).To(HaveKeyWithValue("foo", "bar"))
+		})
+
+		It("re-encodes the timestamp as an EventTime", func() {
+			var ent protocol.EntryExt
+			_, err := ent.UnmarshalMsg(bits)
+			Expect(err).NotTo(HaveOccurred())
+
+			b, err := ent.MarshalMsg(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fmt.Sprintf("%X", b)).To(HavePrefix("92D7004AF9F07000000000"))
+		})
+	})
+
 	Describe("EntryList", func() {
 		var (
 			e1 protocol.EntryList
@@ -228,6 +327,65 @@ var _ = Describe("Transport", func() {
 		})
 	})
 
+	Describe("PackedForwardWriter", func() {
+		var (
+			pw      *protocol.PackedForwardWriter
+			entries protocol.EntryList
+		)
+
+		BeforeEach(func() {
+			pw = protocol.NewPackedForwardWriter("foo")
+			entries = protocol.EntryList{
+				{
+					Timestamp: protocol.EventTimeNow(),
+					Record:    map[string]interface{}{"first": "Sir"},
+				},
+				{
+					Timestamp: protocol.EventTimeNow(),
+					Record:    map[string]interface{}{"last": "Gawain"},
+				},
+			}
+
+			for _, e := range entries {
+				Expect(pw.Append(e)).To(Succeed())
+			}
+		})
+
+		It("produces the same event stream as NewPackedForwardMessage", func() {
+			expected, err := protocol.NewPackedForwardMessage("foo", entries)
+			Expect(err).NotTo(HaveOccurred())
+
+			msg, err := pw.Message()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pw.Len()).To(Equal(2))
+			Expect(msg.Tag).To(Equal("foo"))
+			Expect(msg.EventStream).To(Equal(expected.EventStream))
+			Expect(*msg.Options.Size).To(Equal(2))
+		})
+
+		It("sends the message and resets", func() {
+			sender := &recordingSender{}
+			Expect(pw.Send(sender)).To(Succeed())
+			Expect(sender.sent).To(HaveLen(1))
+
+			var el protocol.EntryList
+			_, err := el.UnmarshalPacked(sender.sent[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(el.Equal(entries)).To(BeTrue())
+
+			Expect(pw.Len()).To(Equal(0))
+			msg, err := pw.Message()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(msg.EventStream).To(BeEmpty())
+		})
+
+		It("keeps the entries when the send fails", func() {
+			sender := &recordingSender{err: errors.New("nope")}
+			Expect(pw.Send(sender)).To(MatchError("nope"))
+			Expect(pw.Len()).To(Equal(2))
+		})
+	})
+
 	Describe("NewCompressedPackedForwardMessage", func() {
 		var (
 			tag     string
@@ -262,4 +420,192 @@ var _ = Describe("Transport", func() {
 			Expect(msg.Options.Compressed).To(Equal("gzip"))
 		})
 	})
+
+	Describe("CompressedPackedForwardMessage", func() {
+		var (
+			entries protocol.EntryList
+			pfm     *protocol.PackedForwardMessage
+			cpfm    *protocol.CompressedPackedForwardMessage
+		)
+
+		decode := func(bits []byte) (*protocol.PackedForwardMessage, protocol.EntryList) {
+			var out protocol.PackedForwardMessage
+			_, err := out.UnmarshalMsg(bits)
+			Expect(err).ToNot(HaveOccurred())
+
+			var stream []byte
+			switch out.Options.Compressed {
+			case "gzip":
+				gr, err := gzip.NewReader(bytes.NewReader(out.EventStream))
+				Expect(err).ToNot(HaveOccurred())
+				stream, err = io.ReadAll(gr)
+				Expect(err).ToNot(HaveOccurred())
+			case "zstd":
+				zr, err := zstd.NewReader(nil)
+				Expect(err).ToNot(HaveOccurred())
+				defer zr.Close()
+				stream, err = zr.DecodeAll(out.EventStream, nil)
+				Expect(err).ToNot(HaveOccurred())
+			default:
+				Fail("unexpected compression " + out.Options.Compressed)
+			}
+
+			var el protocol.EntryList
+			_, err = el.UnmarshalPacked(stream)
+			Expect(err).ToNot(HaveOccurred())
+
+			return &out, el
+		}
+
+		// numeric values don't survive the round trip with their Go types
+		// intact, so compare a string field of each record
+		expectEntries := func(el protocol.EntryList) {
+			Expect(el).To(HaveLen(len(entries)))
+
+			for i := range el {
+				Expect(el[i].Timestamp.Equal(entries[i].Timestamp.Time)).To(BeTrue())
+				Expect(el[i].Record).To(HaveKeyWithValue("request_id", entries[i].Record.(map[string]interface{})["request_id"]))
+			}
+		}
+
+		BeforeEach(func() {
+			entries = makeLogEntries(100)
+
+			var err error
+			pfm, err = protocol.NewPackedForwardMessage("foo.bar", entries)
+			Expect(err).ToNot(HaveOccurred())
+
+			cpfm = protocol.NewCompressedPackedForward(pfm)
+		})
+
+		It("defaults to gzip", func() {
+			Expect(cpfm.CompressionAlgorithm).To(Equal(protocol.CompressionGzip))
+
+			bits, err := cpfm.MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			out, el := decode(bits)
+			Expect(out.Tag).To(Equal("foo.bar"))
+			Expect(out.Options.Compressed).To(Equal("gzip"))
+			Expect(*out.Options.Size).To(Equal(100))
+			expectEntries(el)
+		})
+
+		It("honors the compression level", func() {
+			cpfm.CompressionLevel = gzip.BestSpeed
+
+			var buf bytes.Buffer
+			Expect(msgp.Encode(&buf, cpfm)).To(Succeed())
+
+			_, el := decode(buf.Bytes())
+			expectEntries(el)
+		})
+
+		It("supports zstd", func() {
+			cpfm.CompressionAlgorithm = protocol.CompressionZstd
+
+			var buf bytes.Buffer
+			Expect(msgp.Encode(&buf, cpfm)).To(Succeed())
+
+			out, el := decode(buf.Bytes())
+			Expect(out.Options.Compressed).To(Equal("zstd"))
+			expectEntries(el)
+		})
+
+		It("leaves the stream uncompressed with none", func() {
+			cpfm.CompressionAlgorithm = protocol.CompressionNone
+
+			bits, err := cpfm.MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			uncompressed, err := pfm.MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(bits).To(Equal(uncompressed))
+		})
+
+		It("rejects unknown algorithms", func() {
+			cpfm.CompressionAlgorithm = 7
+
+			_, err := cpfm.MarshalMsg(nil)
+			Expect(err).To(MatchError(ContainSubstring("unsupported compression algorithm")))
+		})
+
+		It("does not modify the wrapped message", func() {
+			_, err := cpfm.MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pfm.Options.Compressed).To(BeEmpty())
+		})
+
+		It("carries the chunk option", func() {
+			chunk, err := cpfm.Chunk()
+			Expect(err).ToNot(HaveOccurred())
+
+			bits, err := cpfm.MarshalMsg(nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			out, _ := decode(bits)
+			Expect(out.Options.Chunk).To(Equal(chunk))
+		})
+
+		It("reduces the size of structured log records by at least 60%", func() {
+			for _, alg := range []protocol.CompressionAlgorithm{protocol.CompressionGzip, protocol.CompressionZstd} {
+				cpfm.CompressionAlgorithm = alg
+
+				compressed, err := cpfm.MarshalMsg(nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				uncompressed, err := pfm.MarshalMsg(nil)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(float64(len(compressed))).To(BeNumerically("<", 0.4*float64(len(uncompressed))), alg.String())
+			}
+		})
+
+		It("bounds the size of a stream that does not compress with Msgsize", func() {
+			rnd := rand.New(rand.NewSource(1))
+
+			for _, n := range []int{0, 1, 1000, 70000, 300000} {
+				stream := make([]byte, n)
+				rnd.Read(stream)
+
+				for _, alg := range []protocol.CompressionAlgorithm{protocol.CompressionGzip, protocol.CompressionZstd} {
+					for _, level := range []int{0, 1, 9} {
+						msg := &protocol.CompressedPackedForwardMessage{
+							PackedForwardMessage: &protocol.PackedForwardMessage{Tag: "foo.bar", EventStream: stream},
+							CompressionAlgorithm: alg,
+							CompressionLevel:     level,
+						}
+
+						bits, err := msg.MarshalMsg(nil)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(len(bits)).To(BeNumerically("<=", msg.Msgsize()), fmt.Sprintf("%s level %d, %d bytes", alg, level, n))
+					}
+				}
+			}
+		})
+	})
 })
+
+// makeLogEntries returns n entries resembling structured application logs.
+func makeLogEntries(n int) protocol.EntryList {
+	levels := []string{"debug", "info", "warn", "error"}
+	entries := make(protocol.EntryList, n)
+
+	for i := range entries {
+		entries[i] = protocol.EntryExt{
+			Timestamp: protocol.EventTime{Time: time.Unix(1257894000+int64(i), 0)},
+			Record: map[string]interface{}{
+				"level":      levels[i%len(levels)],
+				"msg":        fmt.Sprintf("handled request %d", i),
+				"service":    "checkout",
+				"method":     "GET",
+				"path":       "/api/v1/orders",
+				"status":     200,
+				"latency_ms": i % 50,
+				"request_id": fmt.Sprintf("req-%08d", i),
+			},
+		}
+	}
+
+	return entries
+}